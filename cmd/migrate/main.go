@@ -2,50 +2,76 @@ package main
 
 import (
 	"flag"
-	"log"
-	"os"
 
 	"stock-intelligence-backend/internal/database"
+	"stock-intelligence-backend/internal/logging"
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+	envErr := godotenv.Load()
+
+	logger := logging.FromEnv()
+	if envErr != nil {
+		logger.Warn(".env file not found", "error", envErr)
 	}
 
 	// Parse command line flags
-	var command = flag.String("command", "up", "Migration command: up, status")
+	var command = flag.String("command", "up", "Migration command: up, down, status, create")
+	var name = flag.String("name", "", "Snake_case name of the migration to scaffold with -command=create")
+	var steps = flag.Int("steps", 1, "Number of migrations to roll back with -command=down")
+	var to = flag.Int("to", 0, "Version to migrate up to with -command=up (default: latest)")
+	var forceClean = flag.Bool("force-clean", false, "Clear the dirty flag left by a failed migration, after repairing it by hand")
 	flag.Parse()
 
+	// Scaffolding only touches the migrations directory
+	if *command == "create" {
+		upPath, downPath, err := database.CreateMigration("./migrations", *name)
+		if err != nil {
+			logging.Fatal(logger, "Failed to create migration", "error", err)
+		}
+		logger.Info("Created migration", "up", upPath, "down", downPath)
+		return
+	}
+
 	// Connect to database
 	db, err := database.Connect()
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		logging.Fatal(logger, "Failed to connect to database", "error", err)
 	}
 	defer db.Close()
 
 	// Create migrator
 	migrator := database.NewMigrator(db, "./migrations")
 
+	if *forceClean {
+		if err := migrator.ForceClean(); err != nil {
+			logging.Fatal(logger, "Failed to clear dirty flag", "error", err)
+		}
+	}
+
 	// Execute command
 	switch *command {
 	case "up":
-		if err := migrator.Up(); err != nil {
-			log.Fatal("Migration failed:", err)
+		if err := migrator.UpTo(*to); err != nil {
+			logging.Fatal(logger, "Migration failed", "error", err)
+		}
+		logger.Info("Migrations completed")
+
+	case "down":
+		if err := migrator.Down(*steps); err != nil {
+			logging.Fatal(logger, "Rollback failed", "error", err)
 		}
-		log.Println("Migrations completed successfully")
+		logger.Info("Rolled back migrations", "steps", *steps)
 
 	case "status":
 		if err := migrator.Status(); err != nil {
-			log.Fatal("Status check failed:", err)
+			logging.Fatal(logger, "Status check failed", "error", err)
 		}
 
 	default:
-		log.Printf("Unknown command: %s", *command)
-		log.Println("Available commands: up, status")
-		os.Exit(1)
+		logging.Fatal(logger, "Unknown command", "command", *command, "available", "up, down, status, create")
 	}
 }
\ No newline at end of file
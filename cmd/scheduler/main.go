@@ -1,76 +1,104 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
 	"os"
-	"os/exec"
+	"strconv"
 	"time"
 
+	"stock-intelligence-backend/internal/database"
+	"stock-intelligence-backend/internal/logging"
+	"stock-intelligence-backend/internal/services"
+
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
 )
 
 // Scheduler handles background data fetching tasks
 type Scheduler struct {
-	db *sql.DB
+	db      *sql.DB
+	client  services.MarketDataProvider
+	options services.DataFetchOptions
+	logger  *slog.Logger
 }
 
 func main() {
-	log.Println("🕐 Starting Stock Data Scheduler...")
-
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found: %v", err)
-	}
+	envErr := godotenv.Load()
 
-	// Initialize database connection
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("DATABASE_URL environment variable is required")
+	logger := logging.FromEnv()
+	logger.Info("Starting Stock Data Scheduler")
+	if envErr != nil {
+		logger.Warn(".env file not found", "error", envErr)
 	}
 
-	db, err := sql.Open("postgres", dbURL)
+	// Initialize database connection from DATABASE_URL or the DB_* variables
+	db, err := database.Connect()
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		logging.Fatal(logger, "Failed to connect to database", "error", err)
 	}
 	defer db.Close()
 
-	scheduler := &Scheduler{db: db}
+	apiKey := os.Getenv("ALPHA_VANTAGE_API_KEY")
+	if apiKey == "" {
+		logging.Fatal(logger, "ALPHA_VANTAGE_API_KEY environment variable is required")
+	}
+
+	client := services.NewAlphaVantageClient(apiKey, db)
+	client.SetLogger(logger)
+	if n, err := strconv.Atoi(os.Getenv("ALPHA_VANTAGE_CALLS_PER_MINUTE")); err == nil && n > 0 {
+		client.SetCallsPerMinute(n)
+	}
+
+	// Per-run options; by default a run spends whatever the rate limit allows
+	options := services.DataFetchOptions{Logger: logger}
+	if value := os.Getenv("DATA_FETCH_MAX_CALLS"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			options.MaxCalls = n
+		} else {
+			logger.Warn("Ignoring invalid DATA_FETCH_MAX_CALLS", "value", value)
+		}
+	}
+	if value := os.Getenv("DATA_FETCH_DELAY"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+			options.Delay = d
+		} else {
+			logger.Warn("Ignoring invalid DATA_FETCH_DELAY", "value", value)
+		}
+	}
+
+	scheduler := &Scheduler{db: db, client: client, options: options, logger: logger}
 
 	// Run initial fetch immediately
-	log.Println("🚀 Running initial data fetch...")
+	logger.Info("Running initial data fetch")
 	scheduler.runDataFetcher()
 
 	// Set up periodic scheduling for daily compliance (24 hours)
 	ticker := time.NewTicker(24 * time.Hour) // Run once daily for API compliance
 	defer ticker.Stop()
 
-	log.Println("⏰ Scheduler started - will run daily at this time for API compliance")
+	logger.Info("Scheduler started - will run daily at this time for API compliance")
 
-	for {
-		select {
-		case <-ticker.C:
-			log.Println("⏰ Scheduled run starting...")
-			scheduler.runDataFetcher()
-		}
+	for range ticker.C {
+		logger.Info("Scheduled run starting")
+		scheduler.runDataFetcher()
 	}
 }
 
-// runDataFetcher executes the data fetcher command
+// runDataFetcher runs one data fetch in process
 func (s *Scheduler) runDataFetcher() {
-	log.Println("📊 Starting data fetch process...")
-	
-	cmd := exec.Command("go", "run", "./cmd/data-fetcher/main.go")
-	cmd.Dir = "/Users/rajg/Codes/stock_app/backend"
-	
-	output, err := cmd.CombinedOutput()
+	s.logger.Info("Starting data fetch process", "max_calls", s.options.MaxCalls, "delay", s.options.Delay)
+	started := time.Now()
+
+	result, err := services.DataFetchRun(context.Background(), s.db, s.client, s.options)
 	if err != nil {
-		log.Printf("❌ Data fetcher failed: %v", err)
-		log.Printf("Output: %s", string(output))
+		s.logger.Error("Data fetch failed", "error", err, "duration", time.Since(started),
+			"successful", result.Successful, "failed", result.Failed)
 	} else {
-		log.Println("✅ Data fetcher completed successfully")
-		log.Printf("Output: %s", string(output))
+		s.logger.Info("Data fetch completed", "duration", time.Since(started), "available", result.Available,
+			"candidates", result.Candidates, "successful", result.Successful, "failed", result.Failed,
+			"rate_limited", result.RateLimited)
 	}
 
 	// Log the execution
@@ -85,12 +113,12 @@ func (s *Scheduler) logScheduledRun(success bool) {
 	}
 
 	_, err := s.db.Exec(`
-		INSERT INTO api_calls 
-		(service_name, endpoint, request_params, response_status, response_body, created_at)
-		VALUES ('scheduler', 'data_fetch', '{}', $1, $2, CURRENT_TIMESTAMP)
+		INSERT INTO api_calls
+		(service_name, endpoint, request_params, response_status, response_body, initiated_by, created_at)
+		VALUES ('scheduler', 'data_fetch', '{}', $1, $2, 'scheduler', CURRENT_TIMESTAMP)
 	`, map[bool]int{true: 200, false: 500}[success], status)
-	
+
 	if err != nil {
-		log.Printf("Warning: Failed to log scheduled run: %v", err)
+		s.logger.Warn("Failed to log scheduled run", "error", err)
 	}
-}
\ No newline at end of file
+}
@@ -2,90 +2,105 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"database/sql"
-	"log"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"strings"
 	"time"
 
 	"stock-intelligence-backend/internal/database"
+	"stock-intelligence-backend/internal/logging"
 	"stock-intelligence-backend/internal/services"
+	"stock-intelligence-backend/internal/tasks"
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	force := flag.Bool("yes", false, "Delete existing price data without asking (development and test only; or SEED_FORCE=1)")
+	stocksOnly := flag.Bool("stocks-only", false, "Seed the stock symbols only, without any API calls")
+	flag.Parse()
+
 	// Load environment variables
-	if err := godotenv.Load("../.env"); err != nil {
-		log.Printf("Warning: No .env file found: %v", err)
+	envErr := godotenv.Load("../.env")
+	logger := logging.FromEnv()
+	if envErr != nil {
+		logger.Warn(".env file not found", "error", envErr)
+	}
+	if os.Getenv("SEED_FORCE") == "1" {
+		*force = true
 	}
-
-	log.Println("=== Stock Intelligence Platform - Database Seeder ===")
-	log.Println()
 
 	// Check environment
 	env := strings.ToLower(os.Getenv("NODE_ENV"))
 	if env == "" {
 		env = "development"
 	}
-	log.Printf("Environment: %s", env)
+	logger.Info("Starting database seeder", "environment", env, "stocks_only", *stocksOnly)
+
+	if *stocksOnly {
+		db, err := database.InitializeDatabase()
+		if err != nil {
+			logging.Fatal(logger, "Failed to initialize database", "error", err)
+		}
+		defer db.Close()
+
+		// Upserts the symbols and leaves any price data alone, so there is nothing to confirm
+		if err := tasks.NewTaskRunner(db, nil).SeedStocks(); err != nil {
+			logging.Fatal(logger, "Failed to seed stocks", "error", err)
+		}
+		logger.Info("Stock symbols seeded")
+		return
+	}
 
 	// Check API key
 	apiKey := os.Getenv("ALPHA_VANTAGE_API_KEY")
-	if apiKey == "" || apiKey == "your_alpha_vantage_api_key_here" || apiKey == "your_alpha_vantage_api_key_here" {
-		log.Println("❌ ALPHA_VANTAGE_API_KEY is not configured.")
-		log.Println("📋 To get an API key:")
-		log.Println("   1. Visit: https://www.alphavantage.co/support/#api-key")
-		log.Println("   2. Fill out the form (takes 2 minutes)")
-		log.Println("   3. Update your .env file: ALPHA_VANTAGE_API_KEY=your_actual_key")
-		log.Println()
-		log.Fatal("❌ Cannot proceed without valid API key.")
+	if apiKey == "" || apiKey == "your_alpha_vantage_api_key_here" {
+		logging.Fatal(logger, "ALPHA_VANTAGE_API_KEY is not configured",
+			"hint", "get a key at https://www.alphavantage.co/support/#api-key and set ALPHA_VANTAGE_API_KEY in .env")
 	}
 
-	log.Println("✅ Alpha Vantage API key found")
-	log.Println("🚀 Starting database seeding process...")
+	logger.Info("Alpha Vantage API key found, starting database seeding")
 
 	// Initialize database
 	db, err := database.InitializeDatabase()
 	if err != nil {
-		log.Fatal("❌ Failed to initialize database:", err)
+		logging.Fatal(logger, "Failed to initialize database", "error", err)
 	}
 	defer db.Close()
 
-	// 🔒 PRODUCTION SAFETY: Check for existing data
+	// Production safety: check for existing data
 	existingCount, err := checkExistingData(db)
 	if err != nil {
-		log.Fatal("❌ Failed to check existing data:", err)
+		logging.Fatal(logger, "Failed to check existing data", "error", err)
 	}
 
 	if existingCount > 0 {
-		log.Printf("⚠️  Database already contains %d price records", existingCount)
+		logger.Warn("Database already contains price records", "records", existingCount)
 		
-		// Production protection: Never overwrite in production
-		if env == "production" {
-			log.Println()
-			log.Println("🔒 PRODUCTION PROTECTION ACTIVATED")
-			log.Println("❌ Refusing to overwrite existing data in production environment.")
-			log.Println("💡 To seed fresh data in production:")
-			log.Println("   1. Backup existing data first")
-			log.Println("   2. Clear daily_prices table manually")
-			log.Println("   3. Re-run this script")
-			log.Println()
-			log.Fatal("❌ Seeding aborted for production safety.")
+		// Only a terminal can answer the prompt; in CI or a container it would never be answered
+		var input io.Reader = os.Stdin
+		if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			input = strings.NewReader("")
 		}
-
-		// Development/Test: Ask for permission
-		if !askForPermission(existingCount, env) {
-			log.Println("✋ Seeding cancelled by user.")
+		if !confirmOverwrite(env, existingCount, *force, input) {
+			if env == "production" {
+				logging.Fatal(logger, "Seeding aborted for production safety")
+			}
+			logger.Info("Seeding cancelled")
 			return
 		}
 
 		// Clear existing data
-		log.Println("🧹 Clearing existing price data...")
+		logger.Info("Clearing existing price data")
 		if err := clearExistingData(db); err != nil {
-			log.Fatal("❌ Failed to clear existing data:", err)
+			logging.Fatal(logger, "Failed to clear existing data", "error", err)
 		}
-		log.Println("✅ Existing data cleared")
+		logger.Info("Existing data cleared")
 	}
 
 	// Create Alpha Vantage client
@@ -94,41 +109,29 @@ func main() {
 	// Get list of stocks to seed
 	stocks, err := getStocksToSeed(db)
 	if err != nil {
-		log.Fatal("❌ Failed to get stocks list:", err)
+		logging.Fatal(logger, "Failed to get stocks list", "error", err)
 	}
 
-	log.Printf("📊 Found %d stocks to seed", len(stocks))
-se
-	log.Println()
-	log.Println("📡 Starting Alpha Vantage API data fetching...")
-	log.Printf("⏱️  Rate limit: 15-second delays between calls (respecting free tier limits)")
-	log.Println()
+	logger.Info("Fetching Alpha Vantage data", "stocks", len(stocks), "call_delay", 15*time.Second)
 
 	// Seed data for each stock
 	successful := 0
 	failed := 0
 	
 	for i, symbol := range stocks {
-		log.Printf("📈 [%d/%d] Fetching data for %s...", i+1, len(stocks), symbol)
+		logger.Info("Seeding stock", "symbol", symbol, "index", i+1, "total", len(stocks))
 		
 		err := seedStockData(alphaVantageClient, symbol)
 		if err != nil {
-			log.Printf("❌ Failed to seed %s: %v", symbol, err)
+			logger.Error("Failed to seed stock", "symbol", symbol, "error", err)
 			failed++
 		} else {
-			log.Printf("✅ Successfully seeded %s", symbol)
+			logger.Info("Seeded stock", "symbol", symbol)
 			successful++
 		}
-
-		// Rate limiting: Alpha Vantage allows 5 calls per minute for free tier
-		if i < len(stocks)-1 {
-			log.Printf("⏳ Waiting 15 seconds before next API call...")
-			time.Sleep(15 * time.Second)
-		}
 	}
 
-	log.Println()
-	log.Printf("🎯 Seeding completed: %d successful, %d failed", successful, failed)
+	logger.Info("Seeding completed", "successful", successful, "failed", failed)
 	
 	// Verify seeded data
 	if successful > 0 {
@@ -165,30 +168,31 @@ func getStocksToSeed(db *sql.DB) ([]string, error) {
 
 func seedStockData(client *services.AlphaVantageClient, symbol string) error {
 	// Fetch daily time series data for the stock
-	log.Printf("Fetching Alpha Vantage data for %s", symbol)
+	slog.Debug("Fetching Alpha Vantage data", "symbol", symbol)
 	
 	// Fetch data from Alpha Vantage API
-	data, err := client.FetchDailyData(symbol)
+	data, err := client.FetchDailyData(context.Background(), symbol, services.InitiatorSeed)
 	if err != nil {
 		return err
 	}
 	
 	// Save historical data to database
-	return client.SaveHistoricalData(symbol, data)
+	_, err = client.SaveHistoricalData(context.Background(), symbol, data, services.SyncLockWait)
+	return err
 }
 
 func verifySeededData(db *sql.DB) {
-	log.Println("Verifying seeded data...")
+	slog.Info("Verifying seeded data")
 	
 	// Check total daily prices records
 	var count int
 	query := "SELECT COUNT(*) FROM daily_prices"
 	if err := db.QueryRow(query).Scan(&count); err != nil {
-		log.Printf("Error checking daily_prices count: %v", err)
+		slog.Error("Failed to count daily prices", "error", err)
 		return
 	}
 	
-	log.Printf("Total daily_prices records: %d", count)
+	slog.Info("Daily prices stored", "records", count)
 	
 	// Check latest dates
 	query = `
@@ -202,23 +206,22 @@ func verifySeededData(db *sql.DB) {
 	
 	rows, err := db.Query(query)
 	if err != nil {
-		log.Printf("Error checking latest dates: %v", err)
+		slog.Error("Failed to check latest dates", "error", err)
 		return
 	}
 	defer rows.Close()
 	
-	log.Println("Sample of latest data dates:")
 	for rows.Next() {
 		var stockId int
 		var symbol string
 		var latestDate time.Time
 		
 		if err := rows.Scan(&stockId, &symbol, &latestDate); err != nil {
-			log.Printf("Error scanning row: %v", err)
+			slog.Error("Failed to scan latest date", "error", err)
 			continue
 		}
 		
-		log.Printf("  %s: %s", symbol, latestDate.Format("2006-01-02"))
+		slog.Info("Latest price date", "symbol", symbol, "date", latestDate.Format("2006-01-02"))
 	}
 }
 
@@ -230,18 +233,34 @@ func checkExistingData(db *sql.DB) (int, error) {
 	return count, err
 }
 
-// askForPermission prompts user to confirm overwriting existing data
-func askForPermission(existingCount int, env string) bool {
-	log.Println()
-	log.Printf("⚠️  This will DELETE %d existing price records!", existingCount)
-	log.Printf("🌍 Current environment: %s", env)
-	log.Println()
-	log.Print("❓ Do you want to continue? (type 'yes' to confirm): ")
+// confirmOverwrite decides whether the existing price records may be deleted
+// for a fresh seed. Production always refuses. Elsewhere force confirms
+// without asking; otherwise the answer is read from reader, and anything but
+// yes, including no answer at all, declines.
+func confirmOverwrite(env string, existingCount int, force bool, reader io.Reader) bool {
+	if existingCount == 0 {
+		return true
+	}
 
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
-	if err != nil {
-		log.Printf("Failed to read input: %v", err)
+	// Production protection: Never overwrite in production, forced or not
+	if env == "production" {
+		slog.Error("Refusing to overwrite existing data in production",
+			"records", existingCount,
+			"hint", "back up the existing data, clear daily_prices manually, then re-run the seeder")
+		return false
+	}
+
+	if force {
+		slog.Warn("Deleting existing price records, confirmed by --yes or SEED_FORCE=1", "records", existingCount, "environment", env)
+		return true
+	}
+
+	slog.Warn("Seeding will delete the existing price records", "records", existingCount, "environment", env)
+	fmt.Fprint(os.Stderr, "Do you want to continue? (type 'yes' to confirm, or pass --yes): ")
+
+	response, err := bufio.NewReader(reader).ReadString('\n')
+	if err != nil && response == "" {
+		slog.Warn("No answer to the prompt; pass --yes or set SEED_FORCE=1 to seed non-interactively", "error", err)
 		return false
 	}
 
@@ -249,9 +268,9 @@ func askForPermission(existingCount int, env string) bool {
 	confirmed := response == "yes" || response == "y"
 	
 	if confirmed {
-		log.Println("✅ User confirmed data overwrite")
+		slog.Info("User confirmed data overwrite")
 	} else {
-		log.Println("❌ User cancelled operation")
+		slog.Info("User cancelled operation")
 	}
 	
 	return confirmed
@@ -267,8 +286,10 @@ func clearExistingData(db *sql.DB) error {
 	
 	rowsAffected, err := result.RowsAffected()
 	if err == nil {
-		log.Printf("🗑️  Deleted %d price records", rowsAffected)
+		slog.Info("Deleted price records", "records", rowsAffected)
 	}
 	
-	return nil
+	// No prices are left, so no stock has any coverage
+	_, err = db.Exec("UPDATE stocks SET first_price_date = NULL, last_price_date = NULL")
+	return err
 }
\ No newline at end of file
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unreadable fails the test when the prompt is read
+type unreadable struct{ t *testing.T }
+
+func (r unreadable) Read([]byte) (int, error) {
+	r.t.Error("the prompt must not be read")
+	return 0, io.EOF
+}
+
+func TestConfirmOverwrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		existing int
+		force    bool
+		// answer is typed at the prompt; nil when it must not be asked
+		answer *string
+		want   bool
+	}{
+		{name: "forced", env: "development", existing: 10, force: true, want: true},
+		{name: "forced in test", env: "test", existing: 10, force: true, want: true},
+		{name: "confirmed", env: "development", existing: 10, answer: stringPtr("yes\n"), want: true},
+		{name: "confirmed without newline", env: "development", existing: 10, answer: stringPtr("y"), want: true},
+		{name: "declined", env: "development", existing: 10, answer: stringPtr("no\n"), want: false},
+		{name: "no answer", env: "development", existing: 10, answer: stringPtr(""), want: false},
+		{name: "production refuses", env: "production", existing: 10, want: false},
+		{name: "production refuses forced", env: "production", existing: 10, force: true, want: false},
+		{name: "nothing to overwrite", env: "production", existing: 0, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reader io.Reader = unreadable{t}
+			if tt.answer != nil {
+				reader = strings.NewReader(*tt.answer)
+			}
+			assert.Equal(t, tt.want, confirmOverwrite(tt.env, tt.existing, tt.force, reader))
+		})
+	}
+}
+
+func stringPtr(value string) *string { return &value }
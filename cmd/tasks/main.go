@@ -1,12 +1,16 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"log"
 	"os"
+	"strconv"
 	"strings"
 
+	"stock-intelligence-backend/internal/cache"
 	"stock-intelligence-backend/internal/database"
+	"stock-intelligence-backend/internal/logging"
+	"stock-intelligence-backend/internal/models"
 	"stock-intelligence-backend/internal/services"
 	"stock-intelligence-backend/internal/tasks"
 
@@ -15,8 +19,10 @@ import (
 
 func main() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+	envErr := godotenv.Load()
+	logger := logging.FromEnv()
+	if envErr != nil {
+		logger.Warn(".env file not found", "error", envErr)
 	}
 
 	if len(os.Args) < 2 {
@@ -27,68 +33,253 @@ func main() {
 	taskName := os.Args[1]
 	taskArgs := os.Args[2:]
 
+	// Scaffolding a migration needs no database connection
+	if taskName == "db:migrate:create" {
+		if len(taskArgs) != 1 {
+			logging.Fatal(logger, "Invalid arguments", "usage", "./tasks db:migrate:create NAME")
+		}
+		upPath, downPath, err := database.CreateMigration("./migrations", taskArgs[0])
+		if err != nil {
+			logging.Fatal(logger, "Migration create task failed", "error", err)
+		}
+		logger.Info("Created migration", "up", upPath, "down", downPath)
+		return
+	}
+
+	// Response cache statistics live in Redis alone
+	if taskName == "cache:stats" {
+		redisCache, err := cache.NewRedisCache(os.Getenv("REDIS_URL"), os.Getenv("REDIS_KEY_PREFIX"))
+		if err != nil {
+			logging.Fatal(logger, "Failed to connect to Redis", "error", err)
+		}
+		defer redisCache.Close()
+		if err := tasks.CacheStats(redisCache); err != nil {
+			logging.Fatal(logger, "Cache stats task failed", "error", err)
+		}
+		return
+	}
+
 	// Connect to database
 	db, err := database.Connect()
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		logging.Fatal(logger, "Failed to connect to database", "error", err)
 	}
 	defer db.Close()
 
 	// Initialize services
 	apiKey := os.Getenv("ALPHA_VANTAGE_API_KEY")
-	alphaVantageClient := services.NewAlphaVantageClient(apiKey, db)
+	marketDataProvider, err := services.NewMarketDataProvider(os.Getenv("MARKET_DATA_PROVIDER"), apiKey, db)
+	if err != nil {
+		logging.Fatal(logger, "Failed to configure market data provider", "error", err)
+	}
 
 	// Create task runner
-	taskRunner := tasks.NewTaskRunner(db, alphaVantageClient)
+	taskRunner := tasks.NewTaskRunner(db, marketDataProvider)
 
 	// Execute task
 	switch taskName {
 	case "db:seed":
 		if err := taskRunner.SeedDatabase(); err != nil {
-			log.Fatal("Seed task failed:", err)
+			logging.Fatal(logger, "Seed task failed", "error", err)
 		}
-		log.Println("Database seeded successfully!")
+		logger.Info("Database seeded")
 
 	case "db:seed:stocks":
-		if err := taskRunner.SeedStocks(); err != nil {
-			log.Fatal("Stock seed task failed:", err)
+		flags := flag.NewFlagSet(taskName, flag.ExitOnError)
+		file := flags.String("file", "", "CSV with symbol, name, sector, industry, exchange and market_cap columns (default: the embedded list)")
+		flags.Parse(taskArgs)
+		if flags.NArg() > 0 {
+			logging.Fatal(logger, "Invalid arguments", "usage", "./tasks db:seed:stocks [--file=PATH]")
+		}
+		if *file != "" {
+			err = taskRunner.SeedStocksFromFile(*file)
+		} else {
+			err = taskRunner.SeedStocks()
+		}
+		if err != nil {
+			logging.Fatal(logger, "Stock seed task failed", "error", err)
 		}
-		log.Println("Stocks seeded successfully!")
+		logger.Info("Stocks seeded")
 
 	case "data:fetch":
 		symbol := ""
 		if len(taskArgs) > 0 {
-			symbol = strings.ToUpper(taskArgs[0])
+			if symbol, err = models.NormalizeSymbol(taskArgs[0]); err != nil {
+				logging.Fatal(logger, "Invalid symbol", "error", err)
+			}
 		}
 		if err := taskRunner.FetchHistoricalData(symbol); err != nil {
-			log.Fatal("Data fetch task failed:", err)
+			logging.Fatal(logger, "Data fetch task failed", "error", err)
 		}
 		if symbol != "" {
-			log.Printf("Historical data fetched for %s successfully!", symbol)
+			logger.Info("Historical data fetched", "symbol", symbol)
 		} else {
-			log.Println("Historical data fetched for all stocks successfully!")
+			logger.Info("Historical data fetched for all stocks")
 		}
 
+	case "data:fetch:intraday":
+		if len(taskArgs) == 0 {
+			logging.Fatal(logger, "Invalid arguments", "usage", "./tasks data:fetch:intraday SYMBOL [INTERVAL]")
+		}
+		symbol, err := models.NormalizeSymbol(taskArgs[0])
+		if err != nil {
+			logging.Fatal(logger, "Invalid symbol", "error", err)
+		}
+		interval := "5min"
+		if len(taskArgs) > 1 {
+			interval = taskArgs[1]
+		}
+		if err := taskRunner.FetchIntradayPrices(symbol, interval); err != nil {
+			logging.Fatal(logger, "Intraday fetch task failed", "error", err)
+		}
+		logger.Info("Intraday data fetched", "symbol", symbol, "interval", interval)
+
+	case "data:fetch:adjusted":
+		if len(taskArgs) == 0 {
+			logging.Fatal(logger, "Invalid arguments", "usage", "./tasks data:fetch:adjusted SYMBOL")
+		}
+		symbol, err := models.NormalizeSymbol(taskArgs[0])
+		if err != nil {
+			logging.Fatal(logger, "Invalid symbol", "error", err)
+		}
+		if err := taskRunner.FetchAdjustedHistory(symbol); err != nil {
+			logging.Fatal(logger, "Adjusted fetch task failed", "error", err)
+		}
+		logger.Info("Adjusted history and splits fetched", "symbol", symbol)
+
 	case "data:fetch:all":
 		if err := taskRunner.FetchAllHistoricalData(); err != nil {
-			log.Fatal("Fetch all data task failed:", err)
+			logging.Fatal(logger, "Fetch all data task failed", "error", err)
+		}
+		logger.Info("All historical data fetched")
+
+	case "data:verify":
+		minGapDays := 1
+		if len(taskArgs) > 0 {
+			if minGapDays, err = strconv.Atoi(taskArgs[0]); err != nil || minGapDays < 1 {
+				logging.Fatal(logger, "Invalid arguments", "usage", "./tasks data:verify [MIN_GAP_DAYS]")
+			}
+		}
+		if err := taskRunner.VerifyData(minGapDays); err != nil {
+			logging.Fatal(logger, "Data verify task failed", "error", err)
 		}
-		log.Println("All historical data fetched successfully!")
+
+	case "data:export":
+		flags := flag.NewFlagSet(taskName, flag.ExitOnError)
+		symbol := flags.String("symbol", "", "Stock to export (default: every stock, each row naming its symbol)")
+		format := flags.String("format", "", "csv or json (default: from --out's extension, else csv)")
+		out := flags.String("out", "", "File to write (default: stdout)")
+		flags.Parse(taskArgs)
+		if flags.NArg() > 0 {
+			logging.Fatal(logger, "Invalid arguments", "usage", "./tasks data:export [--symbol=SYMBOL] [--format=csv|json] [--out=PATH]")
+		}
+		if *symbol != "" {
+			if *symbol, err = models.NormalizeSymbol(*symbol); err != nil {
+				logging.Fatal(logger, "Invalid symbol", "error", err)
+			}
+		}
+		if err := taskRunner.ExportPrices(*symbol, *format, *out); err != nil {
+			logging.Fatal(logger, "Data export task failed", "error", err)
+		}
+
+	case "data:import":
+		flags := flag.NewFlagSet(taskName, flag.ExitOnError)
+		file := flags.String("file", "", "CSV or JSON written by data:export")
+		format := flags.String("format", "", "csv or json (default: from --file's extension, else csv)")
+		flags.Parse(taskArgs)
+		if *file == "" || flags.NArg() > 0 {
+			logging.Fatal(logger, "Invalid arguments", "usage", "./tasks data:import --file=PATH [--format=csv|json]")
+		}
+		if err := taskRunner.ImportPrices(*file, *format); err != nil {
+			logging.Fatal(logger, "Data import task failed", "error", err)
+		}
+		logger.Info("Daily prices imported")
+
+	case "data:prune":
+		flags := flag.NewFlagSet(taskName, flag.ExitOnError)
+		keepYears := flags.Int("keep-years", services.DefaultPriceRetentionYears, "Years of daily prices to keep, counting back from today")
+		dryRun := flags.Bool("dry-run", false, "Print how many rows of each table would be deleted, without deleting")
+		flags.Parse(taskArgs)
+		if *keepYears < 1 || flags.NArg() > 0 {
+			logging.Fatal(logger, "Invalid arguments", "usage", "./tasks data:prune [--keep-years=N] [--dry-run]")
+		}
+		// The logs are kept as long as the daily cleanup keeps them
+		policy := services.DefaultRetentionPolicy()
+		policy.PriceYears = *keepYears
+		if policy.APICalls, err = services.RetentionDaysFromEnv("API_CALL_RETENTION_DAYS", policy.APICalls); err != nil {
+			logging.Fatal(logger, "Invalid retention", "error", err)
+		}
+		if policy.SyncHistory, err = services.RetentionDaysFromEnv("SYNC_HISTORY_RETENTION_DAYS", policy.SyncHistory); err != nil {
+			logging.Fatal(logger, "Invalid retention", "error", err)
+		}
+		if err := taskRunner.PruneData(policy, *dryRun); err != nil {
+			logging.Fatal(logger, "Data prune task failed", "error", err)
+		}
+
+	case "db:rollback":
+		steps := 1
+		if len(taskArgs) > 0 {
+			if steps, err = strconv.Atoi(taskArgs[0]); err != nil || steps < 1 {
+				logging.Fatal(logger, "Invalid arguments", "usage", "./tasks db:rollback [STEPS]")
+			}
+		}
+		if err := taskRunner.RollbackMigrations(steps); err != nil {
+			logging.Fatal(logger, "Rollback task failed", "error", err)
+		}
+		logger.Info("Rolled back migrations", "steps", steps)
+
+	case "index:import":
+		if len(taskArgs) != 2 {
+			logging.Fatal(logger, "Invalid arguments", "usage", "./tasks index:import INDEX PATH")
+		}
+		if err := taskRunner.ImportIndex(taskArgs[0], taskArgs[1]); err != nil {
+			logging.Fatal(logger, "Index import task failed", "error", err)
+		}
+		logger.Info("Index constituents imported", "index", taskArgs[0])
 
 	case "db:status":
 		if err := taskRunner.DatabaseStatus(); err != nil {
-			log.Fatal("Status check failed:", err)
+			logging.Fatal(logger, "Status check failed", "error", err)
 		}
 
 	case "cache:clear":
 		if err := taskRunner.ClearCache(); err != nil {
-			log.Fatal("Cache clear failed:", err)
+			logging.Fatal(logger, "Cache clear failed", "error", err)
+		}
+		logger.Info("Cache cleared")
+
+	case "cache:warm":
+		redisCache, err := cache.NewRedisCache(os.Getenv("REDIS_URL"), os.Getenv("REDIS_KEY_PREFIX"))
+		if err != nil {
+			logging.Fatal(logger, "Failed to connect to Redis", "error", err)
+		}
+		defer redisCache.Close()
+		// The overview and rankings must leave out the outliers the server does
+		maxChangePercent := services.DefaultMaxChangePercent
+		if v := os.Getenv("MAX_CHANGE_PERCENT"); v != "" {
+			if maxChangePercent, err = strconv.ParseFloat(v, 64); err != nil {
+				logging.Fatal(logger, "Invalid MAX_CHANGE_PERCENT", "error", err)
+			}
+		}
+		if err := taskRunner.WarmCache(redisCache, maxChangePercent); err != nil {
+			logging.Fatal(logger, "Cache warm task failed", "error", err)
+		}
+		logger.Info("Cache warmed")
+
+	case "apikey:create":
+		if len(taskArgs) == 0 {
+			logging.Fatal(logger, "Invalid arguments", "usage", "./tasks apikey:create NAME")
+		}
+		key, err := taskRunner.CreateAPIKey(strings.Join(taskArgs, " "))
+		if err != nil {
+			logging.Fatal(logger, "API key creation failed", "error", err)
 		}
-		log.Println("Cache cleared successfully!")
+		fmt.Println("API key created. Send it in the X-API-Key header; it cannot be shown again:")
+		fmt.Println(key)
 
 	case "api:status":
 		if err := taskRunner.APIStatus(); err != nil {
-			log.Fatal("API status check failed:", err)
+			logging.Fatal(logger, "API status check failed", "error", err)
 		}
 
 	default:
@@ -104,16 +295,46 @@ func printUsage() {
 	fmt.Println()
 	fmt.Println("Available tasks:")
 	fmt.Println("  db:seed              - Seed database with initial data (stocks + sample historical data)")
-	fmt.Println("  db:seed:stocks       - Seed only stock symbols (no historical data)")
+	fmt.Println("  db:seed:stocks [--file=PATH]")
+	fmt.Println("                       - Seed only stock symbols (no historical data), from the embedded list or a CSV")
+	fmt.Println("                         with symbol, name, sector, industry, exchange and market_cap columns")
 	fmt.Println("  db:status            - Show database status and stock counts")
+	fmt.Println("  db:rollback [STEPS]  - Revert the most recent migrations (default 1)")
+	fmt.Println("  db:migrate:create NAME - Scaffold the next numbered up and down migration files")
 	fmt.Println("  data:fetch [SYMBOL]  - Fetch historical data for specific symbol (or all if none specified)")
 	fmt.Println("  data:fetch:all       - Fetch historical data for all stocks (respects rate limits)")
+	fmt.Println("  data:fetch:intraday SYMBOL [INTERVAL]")
+	fmt.Println("                       - Fetch intraday bars (1min, 5min, 15min, 30min, 60min; default 5min)")
+	fmt.Println("  data:fetch:adjusted SYMBOL")
+	fmt.Println("                       - Fetch split- and dividend-adjusted history and record splits")
+	fmt.Println("  data:verify [MIN_GAP_DAYS]")
+	fmt.Println("                       - List stocks missing trading days inside their stored history (default 1+ days)")
+	fmt.Println("  data:export [--symbol=SYMBOL] [--format=csv|json] [--out=PATH]")
+	fmt.Println("                       - Write daily prices for a stock, or every stock, to a file or stdout")
+	fmt.Println("  data:import --file=PATH [--format=csv|json]")
+	fmt.Println("                       - Upsert daily prices from a data:export file; its symbols must be in stocks")
+	fmt.Println("  data:prune [--keep-years=N] [--dry-run]")
+	fmt.Println("                       - Delete daily prices older than N years (default 5) in batches, and API calls and")
+	fmt.Println("                         sync history past API_CALL_RETENTION_DAYS and SYNC_HISTORY_RETENTION_DAYS")
+	fmt.Println("  index:import INDEX PATH")
+	fmt.Println("                       - Replace an index's constituents (sp500) from a CSV with a symbol column")
+	fmt.Println("                         and optional priority, weight and as_of_date columns")
 	fmt.Println("  cache:clear          - Clear all cached data")
+	fmt.Println("  cache:stats          - Show the response cache hit ratio")
+	fmt.Println("  cache:warm           - Load the stock lists, market overview and rankings into the cache")
 	fmt.Println("  api:status           - Show Alpha Vantage API status and rate limits")
+	fmt.Println("  apikey:create NAME   - Create an API key for the write and admin endpoints")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  ./tasks db:seed")
+	fmt.Println("  ./tasks db:seed:stocks --file=universe.csv")
 	fmt.Println("  ./tasks data:fetch AAPL")
 	fmt.Println("  ./tasks data:fetch:all")
+	fmt.Println("  ./tasks data:fetch:intraday AAPL 15min")
+	fmt.Println("  ./tasks data:verify 3")
+	fmt.Println("  ./tasks data:export --symbol=AAPL --format=csv --out=prices.csv")
+	fmt.Println("  ./tasks data:import --file=prices.csv")
+	fmt.Println("  ./tasks data:prune --keep-years=5 --dry-run")
+	fmt.Println("  ./tasks index:import sp500 constituents.csv")
 	fmt.Println("  ./tasks db:status")
 }
\ No newline at end of file
@@ -4,39 +4,47 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"time"
+
+	"stock-intelligence-backend/internal/logging"
 )
 
 func main() {
+	logger := logging.FromEnv()
 	baseURL := "http://localhost:8080"
 	
+	// The system endpoints require an API key; create one with: go run cmd/tasks/main.go apikey:create NAME
+	apiKey := os.Getenv("API_KEY")
+	if apiKey == "" {
+		logging.Fatal(logger, "API_KEY is not set", "hint", "create a key with: go run cmd/tasks/main.go apikey:create trigger-sync")
+	}
+	
 	// First check if the server is running
 	resp, err := http.Get(baseURL + "/health")
 	if err != nil {
-		log.Fatal("Server is not running. Please start the backend server first: cd backend && go run main.go")
+		logging.Fatal(logger, "Server is not running", "url", baseURL, "error", err, "hint", "start the backend server first: go run main.go")
 	}
 	resp.Body.Close()
 	
-	log.Println("Backend server is running. Checking system status...")
+	logger.Info("Backend server is running, checking system status")
 	
 	// Check API status
-	resp, err = http.Get(baseURL + "/api/v1/system/api-status")
+	resp, err = sendWithKey("GET", baseURL+"/api/v1/system/api-status", apiKey)
 	if err != nil {
-		log.Fatal("Failed to check API status:", err)
+		logging.Fatal(logger, "Failed to check API status", "error", err)
 	}
 	defer resp.Body.Close()
 	
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatal("Failed to read API status response:", err)
+		logging.Fatal(logger, "Failed to read API status response", "error", err)
 	}
 	
 	var apiStatus map[string]interface{}
 	if err := json.Unmarshal(body, &apiStatus); err != nil {
-		log.Fatal("Failed to parse API status:", err)
+		logging.Fatal(logger, "Failed to parse API status", "error", err)
 	}
 	
 	fmt.Printf("API Status Response: %s\n", string(body))
@@ -44,72 +52,82 @@ func main() {
 	// Get some stocks to sync
 	stockSymbols := []string{"AAPL", "MSFT", "GOOGL", "AMZN", "TSLA"}
 	
-	log.Printf("Triggering manual sync for %d stocks...", len(stockSymbols))
+	logger.Info("Triggering manual sync", "stocks", len(stockSymbols))
 	
 	successCount := 0
 	for i, symbol := range stockSymbols {
-		log.Printf("[%d/%d] Syncing %s...", i+1, len(stockSymbols), symbol)
+		logger.Info("Syncing stock", "symbol", symbol, "index", i+1, "total", len(stockSymbols))
 		
 		url := fmt.Sprintf("%s/api/v1/system/sync/%s", baseURL, symbol)
-		resp, err := http.Post(url, "application/json", nil)
+		resp, err := sendWithKey("POST", url, apiKey)
 		if err != nil {
-			log.Printf("Failed to sync %s: %v", symbol, err)
+			logger.Error("Failed to sync stock", "symbol", symbol, "error", err)
 			continue
 		}
 		
 		body, err := io.ReadAll(resp.Body)
 		resp.Body.Close()
 		if err != nil {
-			log.Printf("Failed to read sync response for %s: %v", symbol, err)
+			logger.Error("Failed to read sync response", "symbol", symbol, "error", err)
 			continue
 		}
 		
 		if resp.StatusCode == 200 {
-			log.Printf("✓ Successfully triggered sync for %s", symbol)
+			logger.Info("Triggered sync", "symbol", symbol)
 			successCount++
 		} else {
-			log.Printf("✗ Failed to sync %s: %s", symbol, string(body))
+			logger.Error("Failed to sync stock", "symbol", symbol, "status", resp.StatusCode, "response", string(body))
 		}
 		
 		// Rate limiting - wait between requests
 		if i < len(stockSymbols)-1 {
-			log.Println("Waiting 15 seconds before next sync...")
+			logger.Info("Waiting before next sync", "delay", 15*time.Second)
 			time.Sleep(15 * time.Second)
 		}
 	}
 	
-	log.Printf("Sync completed: %d/%d stocks triggered successfully", successCount, len(stockSymbols))
+	logger.Info("Sync completed", "triggered", successCount, "total", len(stockSymbols))
 	
 	// Wait a moment then check the data
-	log.Println("Waiting 10 seconds then checking database...")
+	logger.Info("Waiting before checking the database", "delay", 10*time.Second)
 	time.Sleep(10 * time.Second)
 	
 	// Check stocks endpoint
 	resp, err = http.Get(baseURL + "/api/v1/stocks")
 	if err != nil {
-		log.Printf("Failed to check stocks: %v", err)
+		logger.Error("Failed to check stocks", "error", err)
 		return
 	}
 	defer resp.Body.Close()
 	
 	body, err = io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Failed to read stocks response: %v", err)
+		logger.Error("Failed to read stocks response", "error", err)
 		return
 	}
 	
 	var stocksResp map[string]interface{}
 	if err := json.Unmarshal(body, &stocksResp); err != nil {
-		log.Printf("Failed to parse stocks response: %v", err)
+		logger.Error("Failed to parse stocks response", "error", err)
 		return
 	}
 	
 	if count, ok := stocksResp["count"].(float64); ok {
-		log.Printf("Current stocks in API: %.0f", count)
+		logger.Info("Current stocks in API", "count", int(count))
 		if count > 0 {
-			log.Println("✓ Success! Database now has stock data.")
+			logger.Info("Database now has stock data")
 		} else {
-			log.Println("⚠ No stocks returned. Check logs for API rate limits or errors.")
+			logger.Warn("No stocks returned, check the server logs for API rate limits or errors")
 		}
 	}
-}
\ No newline at end of file
+}
+// sendWithKey sends a request carrying the API key the protected endpoints require
+func sendWithKey(method, url, apiKey string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", apiKey)
+	return http.DefaultClient.Do(req)
+}
@@ -0,0 +1,25 @@
+//go:build e2e
+
+package main
+
+import (
+	"net/http"
+)
+
+// TestAPIKeyRequired checks write and admin endpoints refuse requests without
+// a valid key, accept the harness's key and record when it was used
+func (s *E2ESuite) TestAPIKeyRequired() {
+	for _, path := range []string{"/api/v1/sync/batch?limit=1", "/api/v1/system/sync/AAPL"} {
+		s.Equal(http.StatusUnauthorized, s.requestWithKey(http.MethodPost, path, "", nil, nil), path)
+		s.Equal(http.StatusUnauthorized, s.requestWithKey(http.MethodPost, path, "si_not-a-key", nil, nil), path)
+	}
+	s.Equal(http.StatusUnauthorized, s.requestWithKey(http.MethodGet, "/api/v1/system/health", "", nil, nil))
+
+	// Reads outside /system stay open
+	s.Equal(http.StatusOK, s.requestWithKey(http.MethodGet, "/api/v1/stocks", "", nil, nil))
+
+	s.Equal(http.StatusOK, s.getJSON("/api/v1/system/health", nil))
+	var used bool
+	s.Require().NoError(s.db.QueryRow("SELECT last_used_at IS NOT NULL FROM api_keys WHERE name = 'e2e'").Scan(&used))
+	s.True(used)
+}
@@ -0,0 +1,293 @@
+//go:build e2e
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/auth"
+	"stock-intelligence-backend/internal/cache"
+	"stock-intelligence-backend/internal/config"
+	"stock-intelligence-backend/internal/database"
+	"stock-intelligence-backend/internal/handlers"
+	"stock-intelligence-backend/internal/jobs"
+	"stock-intelligence-backend/internal/responsecache"
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/websocket"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+// e2eKeyPrefix namespaces the harness's Redis keys so it can share an instance
+const e2eKeyPrefix = "e2e:"
+
+// e2eTimeout bounds how long a scenario waits on a job or a WebSocket message
+const e2eTimeout = 30 * time.Second
+
+// E2ESuite runs the full router against real Postgres and Redis with the
+// sandbox market data provider. Start the dependencies with scripts/e2e.sh,
+// which sets E2E_DATABASE_URL and E2E_REDIS_URL; the suite fails rather than
+// skips without them. New async features should add their scenarios here.
+type E2ESuite struct {
+	suite.Suite
+	db        *sql.DB
+	redis     *redis.Client
+	cache     *cache.RedisCache
+	jobQueue  *jobs.Queue
+	scheduler *services.SchedulerService
+	server    *httptest.Server
+	apiKey    string
+}
+
+func TestE2E(t *testing.T) {
+	suite.Run(t, new(E2ESuite))
+}
+
+// SetupSuite resets the database, runs migrations and serves the router wired as main does
+func (s *E2ESuite) SetupSuite() {
+	gin.SetMode(gin.TestMode)
+
+	databaseURL := os.Getenv("E2E_DATABASE_URL")
+	redisURL := os.Getenv("E2E_REDIS_URL")
+	s.Require().NotEmpty(databaseURL, "E2E_DATABASE_URL is not set; run scripts/e2e.sh")
+	s.Require().NotEmpty(redisURL, "E2E_REDIS_URL is not set; run scripts/e2e.sh")
+
+	db, err := sql.Open("postgres", databaseURL)
+	s.Require().NoError(err)
+	s.Require().NoError(db.Ping())
+	s.db = db
+
+	_, err = db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public")
+	s.Require().NoError(err)
+	s.Require().NoError(database.NewMigrator(db, "migrations").Up())
+
+	redisCache, err := cache.NewRedisCache(redisURL, e2eKeyPrefix)
+	s.Require().NoError(err)
+	s.Require().NoError(redisCache.InvalidateAll(context.Background()))
+	s.cache = redisCache
+
+	opt, err := redis.ParseURL(redisURL)
+	s.Require().NoError(err)
+	s.redis = redis.NewClient(opt)
+
+	provider, err := services.NewMarketDataProvider(services.ProviderSandbox, "", db)
+	s.Require().NoError(err)
+
+	s.jobQueue = jobs.NewQueue(jobs.NewPostgresStore(db), 2)
+	stockEvents := services.NewStockEventBus()
+
+	s.scheduler = services.NewSchedulerService(db, provider, redisCache, s.jobQueue, stockEvents)
+	s.Require().NoError(s.scheduler.Start())
+
+	databaseStockService := services.NewDatabaseStockService(db, redisCache)
+	historicalDataSyncService := services.NewHistoricalDataSyncService(db, provider, s.jobQueue)
+	historicalDataSyncService.SetCache(redisCache)
+	historicalDataSyncService.SetStockEvents(stockEvents)
+	s.Require().NoError(s.jobQueue.Start())
+
+	httpConfig, err := config.LoadHTTPConfig()
+	s.Require().NoError(err)
+
+	apiKeys := auth.NewAPIKeyStore(db)
+	s.apiKey, err = apiKeys.Create("e2e")
+	s.Require().NoError(err)
+
+	systemHandler := handlers.NewSystemHandler(provider, s.scheduler, s.jobQueue)
+	systemHandler.SetHealthChecker(services.NewHealthChecker(db, redisCache))
+	systemHandler.SetCache(redisCache)
+	wsHandler := handlers.NewWebSocketHandler(services.NewHybridStockService(databaseStockService), stockEvents, nil)
+	// Requests without an API key are answered from the response cache, as in production
+	responseCache := responsecache.New(redisCache, httpConfig.ResponseCacheTTL, slog.Default()).Middleware()
+	router, err := newRouter(routeHandlers{
+		stocks:     handlers.NewDatabaseStockHandler(databaseStockService, databaseStockService),
+		graphql:    handlers.NewGraphQLHandler(databaseStockService, databaseStockService),
+		ws:         wsHandler,
+		system:     systemHandler,
+		sync:       handlers.NewHistoricalDataSyncHandler(historicalDataSyncService),
+		summary:    handlers.NewStatusSummaryHandler(provider, s.scheduler, historicalDataSyncService, redisCache, wsHandler.GetConnectedClients),
+		watchlists: handlers.NewWatchlistHandler(services.NewWatchlistService(db)),
+		portfolios: handlers.NewPortfolioHandler(services.NewPortfolioService(db)),
+		admin:      handlers.NewAdminHandler(provider, services.NewStockListingService(db, redisCache)),
+		webhooks:   handlers.NewWebhookHandler(services.NewWebhookService(db), services.NewWebhookDispatcher(services.NewWebhookService(db))),
+		docs:       handlers.NewOpenAPIHandler(nil, auth.APIKeyHeader),
+
+		responseCache: responseCache,
+
+		requireAPIKey: auth.RequireAPIKey(apiKeys),
+	}, httpConfig, slog.Default())
+	s.Require().NoError(err)
+	s.server = httptest.NewServer(router)
+}
+
+func (s *E2ESuite) TearDownSuite() {
+	if s.server != nil {
+		s.server.Close()
+	}
+	if s.scheduler != nil {
+		s.scheduler.Stop()
+	}
+	if s.jobQueue != nil {
+		s.jobQueue.Stop()
+	}
+	if s.redis != nil {
+		s.redis.Close()
+	}
+	if s.cache != nil {
+		s.cache.Close()
+	}
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+// seedStocks inserts active stocks with no price history, as cmd/seed does;
+// scenarios may share symbols, so existing stocks are left alone
+func (s *E2ESuite) seedStocks(symbols ...string) {
+	for i, symbol := range symbols {
+		_, err := s.db.Exec(`
+			INSERT INTO stocks (symbol, company_name, sector, industry, market_cap, exchange, is_active)
+			VALUES ($1, $2, 'Technology', 'Software', $3, 'NASDAQ', true)
+			ON CONFLICT (symbol) DO NOTHING`,
+			symbol, symbol+" Inc.", int64(len(symbols)-i)*1000000000)
+		s.Require().NoError(err)
+	}
+}
+
+// request sends a request with the harness's API key and decodes the JSON response into dest
+func (s *E2ESuite) request(method, path string, body interface{}, dest interface{}) int {
+	return s.requestWithKey(method, path, s.apiKey, body, dest)
+}
+
+// requestWithKey is request with the given API key; an empty key sends none
+func (s *E2ESuite) requestWithKey(method, path, apiKey string, body interface{}, dest interface{}) int {
+	var reader bytes.Buffer
+	if body != nil {
+		s.Require().NoError(json.NewEncoder(&reader).Encode(body))
+	}
+
+	req, err := http.NewRequest(method, s.server.URL+path, &reader)
+	s.Require().NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+
+	if dest != nil {
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(dest), "%s %s", method, path)
+	}
+	return resp.StatusCode
+}
+
+func (s *E2ESuite) getJSON(path string, dest interface{}) int {
+	return s.request(http.MethodGet, path, nil, dest)
+}
+
+func (s *E2ESuite) postJSON(path string, body interface{}, dest interface{}) int {
+	return s.request(http.MethodPost, path, body, dest)
+}
+
+// waitForJob polls the jobs endpoint until the job finishes and returns it
+func (s *E2ESuite) waitForJob(id int64) *jobs.Job {
+	var job *jobs.Job
+	s.eventually(func() bool {
+		var resp struct {
+			Job *jobs.Job `json:"job"`
+		}
+		s.Require().Equal(http.StatusOK, s.getJSON(fmt.Sprintf("/api/v1/system/jobs/%d", id), &resp))
+		job = resp.Job
+		return job.IsFinished()
+	}, "job %d did not finish", id)
+	return job
+}
+
+// waitForSyncJob polls the sync jobs endpoint until the batch finishes and returns it
+func (s *E2ESuite) waitForSyncJob(id int64) *services.SyncJob {
+	var job *services.SyncJob
+	s.eventually(func() bool {
+		var resp struct {
+			Data *services.SyncJob `json:"data"`
+		}
+		s.Require().Equal(http.StatusOK, s.getJSON(fmt.Sprintf("/api/v1/sync/jobs/%d", id), &resp))
+		job = resp.Data
+		return job.FinishedAt != nil
+	}, "sync job %d did not finish", id)
+	return job
+}
+
+// eventually polls condition until it holds or e2eTimeout passes
+func (s *E2ESuite) eventually(condition func() bool, msgAndArgs ...interface{}) {
+	deadline := time.Now().Add(e2eTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			s.FailNow("condition not met before timeout", msgAndArgs...)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// dialWS connects a WebSocket client to the server
+func (s *E2ESuite) dialWS() *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(s.server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	s.Require().NoError(err)
+	return conn
+}
+
+// websocketClients is the connected client count reported by /health
+func (s *E2ESuite) websocketClients() int {
+	var health struct {
+		WebSocketClients int `json:"websocket_clients"`
+	}
+	s.Require().Equal(http.StatusOK, s.getJSON("/health", &health))
+	return health.WebSocketClients
+}
+
+// waitForMessage reads WebSocket messages until one has the given type and satisfies match
+func (s *E2ESuite) waitForMessage(conn *websocket.Conn, messageType string, match func(data json.RawMessage) bool) {
+	s.Require().NoError(conn.SetReadDeadline(time.Now().Add(e2eTimeout)))
+	for {
+		var message struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		s.Require().NoError(conn.ReadJSON(&message), "waiting for %s", messageType)
+		if message.Type == messageType && match(message.Data) {
+			return
+		}
+	}
+}
+
+// cacheStatus requests path without an API key, as a browser would, and
+// returns whether the response cache served it: HIT, MISS or BYPASS
+func (s *E2ESuite) cacheStatus(path string) string {
+	resp, err := http.Get(s.server.URL + path)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Require().Equal(http.StatusOK, resp.StatusCode, path)
+	return resp.Header.Get(responsecache.StatusHeader)
+}
+
+// cacheKeyExists reports whether a cache key is present under the harness prefix
+func (s *E2ESuite) cacheKeyExists(name string) bool {
+	n, err := s.redis.Exists(context.Background(), e2eKeyPrefix+name).Result()
+	s.Require().NoError(err)
+	return n == 1
+}
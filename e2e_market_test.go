@@ -0,0 +1,368 @@
+//go:build e2e
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"stock-intelligence-backend/internal/services"
+)
+
+type marketOverviewResponse struct {
+	Data struct {
+		TotalStocks   int                        `json:"total_stocks"`
+		Advancing     int                        `json:"advancing_count"`
+		Declining     int                        `json:"declining_count"`
+		Unchanged     int                        `json:"unchanged_count"`
+		ExcludedCount int                        `json:"excluded_count"`
+		Diagnostics   services.ChangeDiagnostics `json:"diagnostics"`
+	} `json:"data"`
+}
+
+type topMoversResponse struct {
+	Data struct {
+		TopGainers []struct{ Symbol string } `json:"top_gainers"`
+		TopLosers  []struct{ Symbol string } `json:"top_losers"`
+		MostActive []struct{ Symbol string } `json:"most_active"`
+	} `json:"data"`
+}
+
+// seedDailyChange inserts an active stock whose latest close moved changePercent
+// from a previous close of 100
+func (s *E2ESuite) seedDailyChange(symbol string, changePercent float64, volume int64) {
+	var stockID int
+	s.Require().NoError(s.db.QueryRow(`
+		INSERT INTO stocks (symbol, company_name, sector, industry, market_cap, exchange, is_active)
+		VALUES ($1, $2, 'Industrials', 'Conglomerates', 1000000000, 'NYSE', true)
+		RETURNING id`, symbol, symbol+" Corp").Scan(&stockID))
+
+	previous := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	closes := []float64{100, 100 + changePercent}
+	for i, closePrice := range closes {
+		_, err := s.db.Exec(`
+			INSERT INTO daily_prices (stock_id, date, open_price, high_price, low_price, close_price, adjusted_close, volume)
+			VALUES ($1, $2, $3, $3, $3, $3, $3, $4)`,
+			stockID, previous.AddDate(0, 0, i), closePrice, volume)
+		s.Require().NoError(err)
+	}
+}
+
+// TestMarketSummary seeds 20 stocks with known moves and checks the overview
+// counts and performance rankings computed in the database, and their caching.
+// Other scenarios' stocks share the tables, so counts are compared before and
+// after seeding, and the seeded moves are large enough to top every ranking.
+// The stocks are removed again so later scenarios see only their own.
+func (s *E2ESuite) TestMarketSummary() {
+	defer func() {
+		_, err := s.db.Exec(`DELETE FROM stocks WHERE symbol LIKE 'MKT%'`)
+		s.Require().NoError(err)
+		s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+	}()
+	s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+
+	var before marketOverviewResponse
+	s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/market/overview", &before))
+	s.True(s.cacheKeyExists("market:overview"))
+
+	// 12 gainers of 11-22%, 6 losers of 11-16%, one flat and one corrupt close
+	for i := 1; i <= 20; i++ {
+		symbol := fmt.Sprintf("MKT%02d", i)
+		switch {
+		case i <= 12:
+			s.seedDailyChange(symbol, float64(10+i), int64(i)*1000000000)
+		case i <= 18:
+			s.seedDailyChange(symbol, -float64(i-2), int64(i)*1000000000)
+		case i == 19:
+			s.seedDailyChange(symbol, 0, int64(i)*1000000000)
+		default:
+			s.seedDailyChange(symbol, 80, int64(i)*1000000000)
+		}
+	}
+	s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+
+	var after marketOverviewResponse
+	s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/market/overview", &after))
+	s.Equal(before.Data.TotalStocks+20, after.Data.TotalStocks)
+	s.Equal(before.Data.Advancing+12, after.Data.Advancing)
+	s.Equal(before.Data.Declining+6, after.Data.Declining)
+	s.Equal(before.Data.Unchanged+1, after.Data.Unchanged)
+	s.Equal(before.Data.ExcludedCount+1, after.Data.ExcludedCount)
+
+	var outliers []string
+	for _, outlier := range after.Data.Diagnostics.ExcludedOutliers {
+		outliers = append(outliers, outlier.Symbol)
+	}
+	s.Contains(outliers, "MKT20")
+
+	var movers topMoversResponse
+	s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/market/performance", &movers))
+	s.True(s.cacheKeyExists("performance:rankings"))
+
+	symbols := func(stocks []struct{ Symbol string }) []string {
+		list := make([]string, len(stocks))
+		for i, stock := range stocks {
+			list[i] = stock.Symbol
+		}
+		return list
+	}
+	s.Equal([]string{"MKT12", "MKT11", "MKT10", "MKT09", "MKT08", "MKT07", "MKT06", "MKT05", "MKT04", "MKT03"},
+		symbols(movers.Data.TopGainers), "the corrupt close is not a gainer")
+	s.Require().GreaterOrEqual(len(movers.Data.TopLosers), 6)
+	s.Equal([]string{"MKT18", "MKT17", "MKT16", "MKT15", "MKT14", "MKT13"}, symbols(movers.Data.TopLosers)[:6])
+	s.Equal([]string{"MKT20", "MKT19", "MKT18", "MKT17", "MKT16", "MKT15", "MKT14", "MKT13", "MKT12", "MKT11"},
+		symbols(movers.Data.MostActive), "without the history for an average, volume ranks every stock")
+}
+
+type windowMoversResponse struct {
+	Data services.WindowMovers `json:"data"`
+}
+
+// seedCloses inserts an active stock with a close on each weekday from first to
+// last, skipping the days skip accepts; price returns each day's close
+func (s *E2ESuite) seedCloses(symbol string, first, last time.Time, skip func(time.Time) bool, price func(time.Time) float64) map[time.Time]float64 {
+	var stockID int
+	s.Require().NoError(s.db.QueryRow(`
+		INSERT INTO stocks (symbol, company_name, sector, industry, market_cap, exchange, is_active)
+		VALUES ($1, $2, 'Industrials', 'Conglomerates', 1000000000, 'NYSE', true)
+		RETURNING id`, symbol, symbol+" Corp").Scan(&stockID))
+
+	closes := map[time.Time]float64{}
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday || skip(day) {
+			continue
+		}
+		closes[day] = price(day)
+		_, err := s.db.Exec(`
+			INSERT INTO daily_prices (stock_id, date, open_price, high_price, low_price, close_price, adjusted_close, volume)
+			VALUES ($1, $2, $3, $3, $3, $3, $3, 1000000)`,
+			stockID, day, closes[day])
+		s.Require().NoError(err)
+	}
+	return closes
+}
+
+// TestMarketMovers seeds price series around a 2031-03-31 close, later than
+// any other scenario's, and checks each window's returns are taken between
+// the closest closes to its ends, leaving out stocks without them
+func (s *E2ESuite) TestMarketMovers() {
+	defer func() {
+		_, err := s.db.Exec(`DELETE FROM stocks WHERE symbol LIKE 'MOV%'`)
+		s.Require().NoError(err)
+		s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+	}()
+
+	day := func(month time.Month, d int, year ...int) time.Time {
+		y := 2031
+		if len(year) > 0 {
+			y = year[0]
+		}
+		return time.Date(y, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	asOf := day(time.March, 31)
+	start := day(time.December, 1, 2030)
+	never := func(time.Time) bool { return false }
+	trend := func(perDay float64) func(time.Time) float64 {
+		return func(d time.Time) float64 { return 100 + perDay*d.Sub(start).Hours()/24 }
+	}
+
+	series := map[string]map[time.Time]float64{
+		"MOVUP": s.seedCloses("MOVUP", start, asOf, never, trend(0.5)),
+		"MOVDN": s.seedCloses("MOVDN", start, asOf, never, trend(-0.25)),
+		// Listed two weeks before the close: enough for 1d and 7d only
+		"MOVNEW": s.seedCloses("MOVNEW", day(time.March, 17), asOf, never, trend(1)),
+		// No closes after 2031-03-20, so no close near the end of any window
+		"MOVSTALE": s.seedCloses("MOVSTALE", start, day(time.March, 20), never, trend(2)),
+		// Missing the days around the 30d window's start only
+		"MOVGAP": s.seedCloses("MOVGAP", start, asOf, func(d time.Time) bool {
+			return !d.Before(day(time.February, 24)) && !d.After(day(time.March, 4))
+		}, trend(0.1)),
+	}
+	s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+
+	var others int
+	s.Require().NoError(s.db.QueryRow(`SELECT COUNT(*) FROM stocks WHERE is_active = true AND symbol NOT LIKE 'MOV%'`).Scan(&others))
+
+	// closestClose mirrors the window lookups: the latest close on or before day, up to 4 days before it
+	closestClose := func(closes map[time.Time]float64, d time.Time) (time.Time, float64, bool) {
+		for back := 0; back <= 4; back++ {
+			candidate := d.AddDate(0, 0, -back)
+			if price, ok := closes[candidate]; ok {
+				return candidate, price, true
+			}
+		}
+		return time.Time{}, 0, false
+	}
+
+	for window, days := range map[string]int{"1d": 1, "7d": 7, "30d": 30, "90d": 90} {
+		expected := map[string]float64{}
+		excluded := others
+		for symbol, closes := range series {
+			_, endPrice, hasEnd := closestClose(closes, asOf)
+			_, startPrice, hasStart := closestClose(closes, asOf.AddDate(0, 0, -days))
+			if !hasEnd || !hasStart {
+				excluded++
+				continue
+			}
+			expected[symbol] = (endPrice - startPrice) / startPrice * 100
+		}
+
+		var movers windowMoversResponse
+		s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/market/movers?window="+window, &movers), window)
+		s.True(s.cacheKeyExists("performance:movers:"+window), window)
+		s.Equal(window, movers.Data.Window)
+		s.True(asOf.Equal(movers.Data.AsOf.UTC()), "%s: as of %v", window, movers.Data.AsOf)
+		s.Equal(excluded, movers.Data.Excluded, window)
+
+		ranked := map[string]float64{}
+		for _, mover := range append(movers.Data.TopGainers, movers.Data.TopLosers...) {
+			ranked[mover.Symbol] = mover.ChangePercent
+		}
+		s.Require().Len(ranked, len(expected), "%s: ranked %v", window, ranked)
+		for symbol, changePercent := range expected {
+			s.InDelta(changePercent, ranked[symbol], 1e-6, "%s %s", window, symbol)
+		}
+		s.Len(movers.Data.MostActive, len(expected), window)
+	}
+	s.Equal(http.StatusBadRequest, s.getJSON("/api/v1/market/movers?window=2w", nil))
+}
+
+// TestMarketHeatmap checks the database assigns stocks to their sector's
+// bucket, and those without a market cap or sector to Unknown, under a
+// sector no other scenario uses
+func (s *E2ESuite) TestMarketHeatmap() {
+	defer func() {
+		_, err := s.db.Exec(`DELETE FROM stocks WHERE symbol LIKE 'HMP%'`)
+		s.Require().NoError(err)
+		s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+	}()
+
+	for _, stock := range []struct {
+		symbol    string
+		sector    interface{}
+		marketCap interface{}
+	}{
+		{"HMPBIG", "Heatmap", int64(3000000000)},
+		{"HMPSML", "Heatmap", int64(1000000000)},
+		{"HMPNOCAP", "Heatmap", nil},
+		{"HMPNOSEC", nil, int64(2000000000)},
+	} {
+		_, err := s.db.Exec(`
+			INSERT INTO stocks (symbol, company_name, sector, industry, market_cap, exchange, is_active)
+			VALUES ($1, $1, $2, 'Testing', $3, 'NYSE', true)`,
+			stock.symbol, stock.sector, stock.marketCap)
+		s.Require().NoError(err)
+	}
+	s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+
+	var response struct {
+		Data services.MarketHeatmap `json:"data"`
+	}
+	s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/market/heatmap", &response))
+	s.True(s.cacheKeyExists("market:heatmap"))
+
+	buckets := map[string]services.HeatmapSector{}
+	for _, sector := range response.Data {
+		buckets[sector.Sector] = sector
+	}
+	s.Require().Contains(buckets, "Heatmap")
+	heatmap := buckets["Heatmap"]
+	s.Equal(int64(4000000000), heatmap.TotalMarketCap)
+	s.Require().Len(heatmap.Stocks, 2)
+	s.Equal("HMPBIG", heatmap.Stocks[0].Symbol)
+	s.Equal("HMPSML", heatmap.Stocks[1].Symbol)
+
+	var unknown []string
+	for _, tile := range buckets[services.UnknownSector].Stocks {
+		unknown = append(unknown, tile.Symbol)
+	}
+	s.Contains(unknown, "HMPNOCAP")
+	s.Contains(unknown, "HMPNOSEC")
+
+	for i := 1; i < len(response.Data); i++ {
+		s.GreaterOrEqual(response.Data[i-1].TotalMarketCap, response.Data[i].TotalMarketCap, "sectors are largest first")
+	}
+}
+
+// TestMostActiveByRelativeVolume seeds a mega-cap trading a little above its
+// usual volume, a small stock trading at five times its usual, and a new
+// stock without the history for an average trading the most of all, and
+// checks the most active are ranked by relative volume, not by volume
+func (s *E2ESuite) TestMostActiveByRelativeVolume() {
+	defer func() {
+		_, err := s.db.Exec(`DELETE FROM stocks WHERE symbol LIKE 'VOL%'`)
+		s.Require().NoError(err)
+		s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+	}()
+
+	last := time.Date(2029, time.June, 29, 0, 0, 0, 0, time.UTC)
+	never := func(time.Time) bool { return false }
+	flat := func(time.Time) float64 { return 100 }
+	// Each stock trades usual shares a day until its latest close, which trades latest
+	for _, stock := range []struct {
+		symbol        string
+		first         time.Time
+		usual, latest int64
+	}{
+		{"VOLBIG", last.AddDate(0, -2, 0), 100000000, 120000000},
+		{"VOLSPIKE", last.AddDate(0, -2, 0), 1000000, 5000000},
+		{"VOLNEW", last.AddDate(0, 0, -14), 200000000, 300000000},
+	} {
+		s.seedCloses(stock.symbol, stock.first, last, never, flat)
+		_, err := s.db.Exec(`
+			UPDATE daily_prices dp
+			SET volume = CASE WHEN dp.date = $2 THEN $4::bigint ELSE $3::bigint END
+			FROM stocks s
+			WHERE s.id = dp.stock_id AND s.symbol = $1`,
+			stock.symbol, last, stock.usual, stock.latest)
+		s.Require().NoError(err)
+	}
+	s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+
+	type activeStock struct {
+		Symbol         string   `json:"symbol"`
+		Volume         int64    `json:"volume"`
+		AvgVolume      *int64   `json:"avg_volume_30d"`
+		RelativeVolume *float64 `json:"relative_volume"`
+		RankedByVolume bool     `json:"ranked_by_volume"`
+	}
+	var movers struct {
+		Data struct {
+			MostActive []activeStock `json:"most_active"`
+		} `json:"data"`
+	}
+	s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/market/performance", &movers))
+
+	seeded := map[string]activeStock{}
+	var order []string
+	for _, stock := range movers.Data.MostActive {
+		if strings.HasPrefix(stock.Symbol, "VOL") {
+			seeded[stock.Symbol] = stock
+			order = append(order, stock.Symbol)
+		}
+	}
+	s.Equal([]string{"VOLSPIKE", "VOLBIG", "VOLNEW"}, order, "by volume the order would be reversed")
+	s.Require().NotNil(seeded["VOLSPIKE"].RelativeVolume)
+	s.Equal(5.0, *seeded["VOLSPIKE"].RelativeVolume)
+	s.Require().NotNil(seeded["VOLSPIKE"].AvgVolume)
+	s.Equal(int64(1000000), *seeded["VOLSPIKE"].AvgVolume)
+	s.Require().NotNil(seeded["VOLBIG"].RelativeVolume)
+	s.Equal(1.2, *seeded["VOLBIG"].RelativeVolume)
+	s.True(seeded["VOLNEW"].RankedByVolume)
+	s.Nil(seeded["VOLNEW"].RelativeVolume)
+
+	var spikes struct {
+		Data []activeStock `json:"data"`
+	}
+	s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/market/volume-spikes?min_ratio=3", &spikes))
+	var spiking []string
+	for _, stock := range spikes.Data {
+		spiking = append(spiking, stock.Symbol)
+	}
+	s.Contains(spiking, "VOLSPIKE")
+	s.NotContains(spiking, "VOLBIG")
+	s.NotContains(spiking, "VOLNEW")
+}
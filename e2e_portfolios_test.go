@@ -0,0 +1,77 @@
+//go:build e2e
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// TestPortfolioSummary records buys and sells against seeded closes and checks
+// the FIFO cost, valuation and oversell refusal end to end
+func (s *E2ESuite) TestPortfolioSummary() {
+	var created struct {
+		Data models.Portfolio `json:"data"`
+	}
+	defer func() {
+		// Transactions hold on to their stocks until the portfolio goes
+		_, err := s.db.Exec(`DELETE FROM portfolios WHERE id = $1`, created.Data.ID)
+		s.Require().NoError(err)
+		_, err = s.db.Exec(`DELETE FROM stocks WHERE symbol LIKE 'PFL%'`)
+		s.Require().NoError(err)
+	}()
+
+	first := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+	s.seedCloses("PFLA", first, first.AddDate(0, 0, 4), func(time.Time) bool { return false },
+		func(time.Time) float64 { return 125 })
+	s.seedStocks("PFLB")
+
+	s.Require().Equal(http.StatusCreated, s.postJSON("/api/v1/portfolios", map[string]string{"name": "Core"}, &created))
+	path := fmt.Sprintf("/api/v1/portfolios/%d", created.Data.ID)
+
+	trade := func(symbol, side string, quantity, price float64, day int) int {
+		return s.postJSON(path+"/transactions", map[string]interface{}{
+			"symbol":      symbol,
+			"side":        side,
+			"quantity":    quantity,
+			"price":       price,
+			"executed_at": time.Date(2025, time.March, day, 15, 0, 0, 0, time.UTC),
+		}, nil)
+	}
+	s.Require().Equal(http.StatusCreated, trade("PFLA", "buy", 10, 100, 3))
+	s.Require().Equal(http.StatusCreated, trade("PFLA", "buy", 10, 120, 4))
+	s.Require().Equal(http.StatusCreated, trade("PFLA", "sell", 15, 130, 5))
+	s.Require().Equal(http.StatusCreated, trade("PFLB", "buy", 2, 300, 5))
+
+	s.Equal(http.StatusUnprocessableEntity, trade("PFLA", "sell", 6, 130, 6), "only 5 shares are left")
+	s.Equal(http.StatusUnprocessableEntity, trade("PFLA", "sell", 1, 130, 3), "the shares bought on the 3rd are all sold on the 5th")
+	s.Equal(http.StatusUnprocessableEntity, trade("NOTREAL", "buy", 1, 1, 3))
+
+	var summary struct {
+		Data models.PortfolioSummary `json:"data"`
+	}
+	s.Require().Equal(http.StatusOK, s.getJSON(path+"/summary", &summary))
+	s.Require().Len(summary.Data.Positions, 2)
+
+	held := summary.Data.Positions[0]
+	s.Equal("PFLA", held.Symbol)
+	s.Equal(5.0, held.Quantity)
+	s.Equal(120.0, held.AverageCost)
+	s.Require().NotNil(held.MarketValue)
+	s.Equal(625.0, *held.MarketValue)
+	s.Equal(25.0, *held.UnrealizedPnL)
+	s.Equal(350.0, held.RealizedPnL)
+
+	unpriced := summary.Data.Positions[1]
+	s.Equal("PFLB", unpriced.Symbol)
+	s.Nil(unpriced.MarketValue)
+	s.True(unpriced.Stale)
+
+	s.Equal(1200.0, summary.Data.Totals.CostBasis)
+	s.Equal(625.0, summary.Data.Totals.MarketValue)
+
+	s.Equal(http.StatusNotFound, s.getJSON("/api/v1/portfolios/999999/summary", nil))
+}
@@ -0,0 +1,258 @@
+//go:build e2e
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"stock-intelligence-backend/internal/services"
+)
+
+type priceSeriesResponse struct {
+	Data struct {
+		Bars []services.PriceBar `json:"bars"`
+	} `json:"data"`
+}
+
+// TestPriceSeries seeds closes that rise by one each calendar day, so a bar's
+// open and close name its first and last trading days, and checks the weekly
+// and monthly aggregation done in the database
+func (s *E2ESuite) TestPriceSeries() {
+	defer func() {
+		_, err := s.db.Exec(`DELETE FROM stocks WHERE symbol LIKE 'PRC%'`)
+		s.Require().NoError(err)
+		s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+	}()
+
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	start := day(2024, time.December, 2)
+	rising := func(d time.Time) float64 { return 100 + d.Sub(start).Hours()/24 }
+	holidays := map[time.Time]bool{day(2024, time.December, 25): true, day(2025, time.January, 1): true}
+	s.seedCloses("PRCWK", start, day(2025, time.January, 17), func(d time.Time) bool { return holidays[d] }, rising)
+	// No trading days in February
+	s.seedCloses("PRCMO", day(2025, time.January, 2), day(2025, time.March, 31), func(d time.Time) bool {
+		return d.Month() == time.February
+	}, rising)
+
+	// ISO week 1 of 2025 runs from Monday 2024-12-30, across New Year's Day
+	var weekly priceSeriesResponse
+	s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/stocks/PRCWK/prices?resolution=weekly&from=2024-12-23&to=2025-01-08", &weekly))
+	s.Require().Len(weekly.Data.Bars, 3)
+	yearEnd := weekly.Data.Bars[1]
+	s.Equal(day(2024, time.December, 30), yearEnd.PeriodStart.UTC())
+	s.Equal(day(2025, time.January, 5), yearEnd.PeriodEnd.UTC())
+	s.Equal(4, yearEnd.TradingDays, "Monday, Tuesday, Thursday and Friday")
+	s.Equal(rising(day(2024, time.December, 30)), yearEnd.Open)
+	s.Equal(rising(day(2025, time.January, 3)), yearEnd.Close)
+	s.Equal(rising(day(2024, time.December, 30)), yearEnd.Low)
+	s.Equal(rising(day(2025, time.January, 3)), yearEnd.High)
+	s.Equal(int64(4000000), yearEnd.Volume)
+	s.False(weekly.Data.Bars[0].Partial, "the week before Christmas is complete")
+	s.Equal(4, weekly.Data.Bars[0].TradingDays)
+	s.True(weekly.Data.Bars[2].Partial, "the range ends on the week's Wednesday")
+	s.Equal(3, weekly.Data.Bars[2].TradingDays)
+
+	// The months either side of an empty one keep their own bars
+	var monthly priceSeriesResponse
+	s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/stocks/PRCMO/prices?resolution=monthly&from=2025-01-01&to=2025-03-31", &monthly))
+	s.Require().Len(monthly.Data.Bars, 2)
+	s.Equal(day(2025, time.January, 1), monthly.Data.Bars[0].PeriodStart.UTC())
+	s.Equal(day(2025, time.March, 1), monthly.Data.Bars[1].PeriodStart.UTC())
+	s.Equal(rising(day(2025, time.March, 3)), monthly.Data.Bars[1].Open)
+	s.Equal(rising(day(2025, time.March, 31)), monthly.Data.Bars[1].Close)
+	s.False(monthly.Data.Bars[1].Partial)
+	s.True(s.cacheKeyExists("prices:PRCMO:monthly:2025-01-01:2025-03-31"))
+
+	var february priceSeriesResponse
+	s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/stocks/PRCMO/prices?resolution=monthly&from=2025-02-01&to=2025-02-28", &february))
+	s.Empty(february.Data.Bars)
+}
+
+// TestPriceExportImportRoundTrip exports seeded prices as CSV and JSON,
+// deletes them and imports each export back, checking every stored price
+// comes back as it was
+func (s *E2ESuite) TestPriceExportImportRoundTrip() {
+	defer func() {
+		_, err := s.db.Exec(`DELETE FROM stocks WHERE symbol LIKE 'PXR%'`)
+		s.Require().NoError(err)
+		s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+	}()
+
+	first := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(2024, time.April, 30, 0, 0, 0, 0, time.UTC)
+	none := func(time.Time) bool { return false }
+	s.seedCloses("PXRA", first, last, none, func(d time.Time) float64 { return 100.1234 + float64(d.YearDay()) })
+	s.seedCloses("PXRB", first, last, none, func(d time.Time) float64 { return 20.5 + float64(d.YearDay())/8 })
+	// Adjusted closes that differ from the close must survive the trip too
+	_, err := s.db.Exec(`
+		UPDATE daily_prices SET adjusted_close = ROUND(close_price / 3, 4)
+		WHERE stock_id = (SELECT id FROM stocks WHERE symbol = 'PXRB')`)
+	s.Require().NoError(err)
+
+	stored := func() []string {
+		rows, err := s.db.Query(`
+			SELECT s.symbol, dp.date::text, dp.open_price::text, dp.high_price::text, dp.low_price::text,
+			       dp.close_price::text, dp.adjusted_close::text, dp.volume::text
+			FROM daily_prices dp
+			JOIN stocks s ON s.id = dp.stock_id
+			WHERE s.symbol LIKE 'PXR%'
+			ORDER BY s.symbol, dp.date`)
+		s.Require().NoError(err)
+		defer rows.Close()
+		var all []string
+		for rows.Next() {
+			var symbol, date, open, high, low, closePrice, adjusted, volume string
+			s.Require().NoError(rows.Scan(&symbol, &date, &open, &high, &low, &closePrice, &adjusted, &volume))
+			all = append(all, strings.Join([]string{symbol, date, open, high, low, closePrice, adjusted, volume}, ","))
+		}
+		s.Require().NoError(rows.Err())
+		return all
+	}
+	want := stored()
+	s.Require().Len(want, 2*43)
+
+	ctx := context.Background()
+	for _, format := range services.PriceFormats {
+		s.Run(format, func() {
+			// One stock by symbol, the other within an export of every stock
+			var single, everything bytes.Buffer
+			written, err := services.ExportDailyPrices(ctx, s.db, &single, "PXRA", format)
+			s.Require().NoError(err)
+			s.Equal(43, written)
+			_, err = services.ExportDailyPrices(ctx, s.db, &everything, "", format)
+			s.Require().NoError(err)
+
+			_, err = s.db.Exec(`
+				DELETE FROM daily_prices
+				WHERE stock_id IN (SELECT id FROM stocks WHERE symbol LIKE 'PXR%')`)
+			s.Require().NoError(err)
+
+			stats, err := services.ImportDailyPrices(ctx, s.db, &single, format)
+			s.Require().NoError(err)
+			s.Equal(services.PriceImportStats{Inserted: 43}, stats)
+			// Other stocks' prices are written back over themselves
+			stats, err = services.ImportDailyPrices(ctx, s.db, &everything, format)
+			s.Require().NoError(err)
+			s.Equal(43, stats.Inserted, "only PXRB's prices were missing")
+			s.GreaterOrEqual(stats.Updated, 43, "PXRA's prices are updated in place")
+
+			s.Equal(want, stored())
+		})
+	}
+}
+
+// TestDataPrune seeds prices from 1995 and 2024, and API calls and sync
+// history from over a year ago and today, and checks a dry run counts only
+// the old rows and a prune, three rows at a time, deletes only them
+func (s *E2ESuite) TestDataPrune() {
+	defer func() {
+		_, err := s.db.Exec(`DELETE FROM stocks WHERE symbol LIKE 'PRN%'`)
+		s.Require().NoError(err)
+		_, err = s.db.Exec(`DELETE FROM api_calls WHERE service_name = 'e2e_prune'`)
+		s.Require().NoError(err)
+		_, err = s.db.Exec(`DELETE FROM sync_history WHERE symbol LIKE 'PRN%'`)
+		s.Require().NoError(err)
+		s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+	}()
+
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	none := func(time.Time) bool { return false }
+	flat := func(time.Time) float64 { return 50 }
+	// PRNOLD only has prices older than the retention
+	s.seedCloses("PRNOLD", day(1995, time.March, 1), day(1995, time.March, 14), none, flat)
+	s.seedCloses("PRNMIX", day(1995, time.March, 1), day(1995, time.March, 7), none, flat)
+	s.Require().Len(s.seedCloses("PRNNEW", day(2024, time.March, 1), day(2024, time.March, 7), none, flat), 5)
+	_, err := s.db.Exec(`
+		INSERT INTO daily_prices (stock_id, date, open_price, high_price, low_price, close_price, adjusted_close, volume)
+		SELECT id, DATE '2024-03-01' + n, 50, 50, 50, 50, 50, 1000000 FROM stocks, generate_series(0, 4) n
+		WHERE symbol = 'PRNMIX'`)
+	s.Require().NoError(err)
+	_, err = s.db.Exec(`
+		UPDATE stocks s
+		SET first_price_date = (SELECT MIN(date) FROM daily_prices WHERE stock_id = s.id),
+		    last_price_date = (SELECT MAX(date) FROM daily_prices WHERE stock_id = s.id)
+		WHERE symbol LIKE 'PRN%'`)
+	s.Require().NoError(err)
+
+	for _, age := range []string{"400 days", "1 hour"} {
+		_, err = s.db.Exec(`
+			INSERT INTO api_calls (service_name, endpoint, response_status, created_at)
+			VALUES ('e2e_prune', 'TIME_SERIES_DAILY', 200, CURRENT_TIMESTAMP - $1::interval)`, age)
+		s.Require().NoError(err)
+		_, err = s.db.Exec(`
+			INSERT INTO sync_history (symbol, success, triggered_by, created_at)
+			VALUES ('PRNNEW', true, 'scheduler', CURRENT_TIMESTAMP - $1::interval)`, age)
+		s.Require().NoError(err)
+	}
+
+	// Keeping twenty years leaves everything from 2024 and nothing from 1995
+	policy := services.DefaultRetentionPolicy()
+	policy.PriceYears = 20
+	pruner := services.NewDataPruner(s.db)
+	pruner.SetBatchSize(3)
+	ctx := context.Background()
+
+	rowCounts := func() map[string]int {
+		counts := map[string]int{}
+		for table, query := range map[string]string{
+			"daily_prices": `SELECT COUNT(*) FROM daily_prices dp JOIN stocks s ON s.id = dp.stock_id WHERE s.symbol LIKE 'PRN%'`,
+			"api_calls":    `SELECT COUNT(*) FROM api_calls WHERE service_name = 'e2e_prune'`,
+			"sync_history": `SELECT COUNT(*) FROM sync_history WHERE symbol LIKE 'PRN%'`,
+		} {
+			var n int
+			s.Require().NoError(s.db.QueryRow(query).Scan(&n))
+			counts[table] = n
+		}
+		return counts
+	}
+	before := rowCounts()
+	s.Equal(map[string]int{"daily_prices": 10 + 5 + 5 + 5, "api_calls": 2, "sync_history": 2}, before)
+
+	wantPruned := []services.PruneCount{
+		{Table: "daily_prices", Rows: 15},
+		{Table: "api_calls", Rows: 1},
+		{Table: "sync_history", Rows: 1},
+	}
+	counts, err := pruner.Count(ctx, policy)
+	s.Require().NoError(err)
+	s.Equal(wantPruned, counts)
+	s.Equal(before, rowCounts(), "a dry run deletes nothing")
+
+	counts, err = pruner.Prune(ctx, policy)
+	s.Require().NoError(err)
+	s.Equal(wantPruned, counts)
+	s.Equal(map[string]int{"daily_prices": 10, "api_calls": 1, "sync_history": 1}, rowCounts())
+
+	var oldest time.Time
+	s.Require().NoError(s.db.QueryRow(`
+		SELECT MIN(dp.date) FROM daily_prices dp JOIN stocks s ON s.id = dp.stock_id
+		WHERE s.symbol LIKE 'PRN%'`).Scan(&oldest))
+	s.Equal(day(2024, time.March, 1), oldest.UTC())
+
+	// Coverage moves up to the prices left, and is gone with them
+	coverage := func(symbol string) (first, last *time.Time) {
+		s.Require().NoError(s.db.QueryRow(`SELECT first_price_date, last_price_date FROM stocks WHERE symbol = $1`, symbol).Scan(&first, &last))
+		return first, last
+	}
+	first, last := coverage("PRNMIX")
+	s.Require().NotNil(first)
+	s.Equal(day(2024, time.March, 1), first.UTC())
+	s.Require().NotNil(last)
+	s.Equal(day(2024, time.March, 5), last.UTC())
+	first, last = coverage("PRNOLD")
+	s.Nil(first)
+	s.Nil(last)
+
+	// Nothing is left to prune
+	counts, err = pruner.Count(ctx, policy)
+	s.Require().NoError(err)
+	s.Zero(counts[0].Rows + counts[1].Rows + counts[2].Rows)
+}
@@ -0,0 +1,177 @@
+//go:build e2e
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TestStockListFilters seeds stocks on and either side of the default market
+// cap thresholds, under a sector no other scenario uses, and checks the bands
+// and exchange filters the database applies, alone and combined
+func (s *E2ESuite) TestStockListFilters() {
+	defer func() {
+		_, err := s.db.Exec(`DELETE FROM stocks WHERE symbol LIKE 'CAP%'`)
+		s.Require().NoError(err)
+		s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+	}()
+
+	for _, stock := range []struct {
+		symbol    string
+		marketCap interface{}
+		exchange  string
+	}{
+		{"CAPL", int64(10000000000), "NYSE"},
+		{"CAPM9", int64(9999999999), "NASDAQ"},
+		{"CAPM2", int64(2000000000), "NYSE"},
+		{"CAPS", int64(1999999999), "NASDAQ"},
+		{"CAPNONE", nil, "NASDAQ"},
+	} {
+		_, err := s.db.Exec(`
+			INSERT INTO stocks (symbol, company_name, sector, industry, market_cap, exchange, is_active)
+			VALUES ($1, $1, 'Filters', 'Testing', $2, $3, true)`,
+			stock.symbol, stock.marketCap, stock.exchange)
+		s.Require().NoError(err)
+	}
+	s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+
+	list := func(query string) ([]string, int) {
+		var response struct {
+			Data  []struct{ Symbol string } `json:"data"`
+			Total int                       `json:"total"`
+		}
+		s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/stocks?sector=Filters&"+query, &response), query)
+		symbols := []string{}
+		for _, stock := range response.Data {
+			symbols = append(symbols, stock.Symbol)
+		}
+		return symbols, response.Total
+	}
+
+	// A cap on a threshold belongs to the band above it; a stock without a cap is in none
+	symbols, total := list("market_cap=large")
+	s.Equal([]string{"CAPL"}, symbols)
+	s.Equal(1, total)
+	symbols, _ = list("market_cap=mid")
+	s.Equal([]string{"CAPM9", "CAPM2"}, symbols)
+	symbols, _ = list("market_cap=small")
+	s.Equal([]string{"CAPS"}, symbols)
+
+	symbols, total = list("exchange=nasdaq")
+	s.ElementsMatch([]string{"CAPM9", "CAPS", "CAPNONE"}, symbols)
+	s.Equal(3, total)
+
+	// Combined, the count and the page agree
+	symbols, total = list("market_cap=mid&exchange=NASDAQ")
+	s.Equal([]string{"CAPM9"}, symbols)
+	s.Equal(1, total)
+	symbols, total = list("market_cap=mid&limit=1&offset=1")
+	s.Equal([]string{"CAPM2"}, symbols)
+	s.Equal(2, total)
+}
+
+// TestStockListCursor seeds 120 stocks under a sector no other scenario uses,
+// with tied and missing market caps, and walks them with cursors
+func (s *E2ESuite) TestStockListCursor() {
+	defer func() {
+		_, err := s.db.Exec(`DELETE FROM stocks WHERE symbol LIKE 'CUR%'`)
+		s.Require().NoError(err)
+		s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+	}()
+
+	for i := 0; i < 120; i++ {
+		var marketCap interface{}
+		if i%20 != 0 {
+			marketCap = int64(i/3+1) * 1000000000
+		}
+		_, err := s.db.Exec(`
+			INSERT INTO stocks (symbol, company_name, sector, industry, market_cap, exchange, is_active)
+			VALUES ($1, $1, 'Cursor', 'Testing', $2, 'NYSE', true)`,
+			fmt.Sprintf("CUR%03d", i), marketCap)
+		s.Require().NoError(err)
+	}
+	s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+
+	seen := map[string]bool{}
+	path := "/api/v1/stocks?sector=Cursor&limit=50&cursor="
+	for page, size := range []int{50, 50, 20} {
+		var response struct {
+			Data       []struct{ Symbol string } `json:"data"`
+			NextCursor *string                   `json:"next_cursor"`
+		}
+		s.Require().Equal(http.StatusOK, s.getJSON(path, &response), path)
+		s.Len(response.Data, size, "page %d", page)
+		for _, stock := range response.Data {
+			s.False(seen[stock.Symbol], "%s served twice", stock.Symbol)
+			seen[stock.Symbol] = true
+		}
+		if page < 2 {
+			s.Require().NotNil(response.NextCursor)
+			path = "/api/v1/stocks?sector=Cursor&limit=50&cursor=" + *response.NextCursor
+		} else {
+			s.Nil(response.NextCursor)
+		}
+	}
+	s.Len(seen, 120)
+}
+
+// TestStockDailyChangeAcrossGaps seeds closes around June 2030 with gaps of
+// one and three trading days, and checks the daily change is only measured
+// against a close at most five calendar days before the latest
+func (s *E2ESuite) TestStockDailyChangeAcrossGaps() {
+	defer func() {
+		_, err := s.db.Exec(`DELETE FROM stocks WHERE symbol LIKE 'GAP%'`)
+		s.Require().NoError(err)
+		s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+	}()
+
+	day := func(d int) time.Time { return time.Date(2030, time.June, d, 0, 0, 0, 0, time.UTC) }
+	byDay := func(d time.Time) float64 { return 100 + float64(d.Day()) }
+	missing := func(days ...int) func(time.Time) bool {
+		return func(d time.Time) bool { return slices.Contains(days, d.Day()) }
+	}
+	// Monday 10 June against Friday 7 June
+	s.seedCloses("GAPWKND", day(3), day(10), missing(), byDay)
+	// Thursday 13 June against Tuesday 11 June
+	s.seedCloses("GAPONE", day(3), day(13), missing(12), byDay)
+	// Thursday 13 June, with Friday 7 June six days before it
+	s.seedCloses("GAPTHREE", day(3), day(13), missing(10, 11, 12), byDay)
+	s.Require().NoError(s.cache.InvalidateAll(context.Background()))
+
+	type change struct {
+		Symbol           string  `json:"symbol"`
+		CurrentPrice     float64 `json:"current_price"`
+		DailyChange      float64 `json:"daily_change"`
+		ChangePeriodDays int     `json:"change_period_days"`
+	}
+	expected := map[string]change{
+		"GAPWKND":  {Symbol: "GAPWKND", CurrentPrice: 110, DailyChange: 3, ChangePeriodDays: 3},
+		"GAPONE":   {Symbol: "GAPONE", CurrentPrice: 113, DailyChange: 2, ChangePeriodDays: 2},
+		"GAPTHREE": {Symbol: "GAPTHREE", CurrentPrice: 113, DailyChange: 0, ChangePeriodDays: 0},
+	}
+
+	for symbol, want := range expected {
+		var detail struct {
+			Data change `json:"data"`
+		}
+		s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/stocks/"+symbol, &detail), symbol)
+		s.Equal(want, detail.Data, symbol)
+	}
+
+	var list struct {
+		Data []change `json:"data"`
+	}
+	s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/stocks?sector=Industrials&limit=100", &list))
+	listed := map[string]change{}
+	for _, stock := range list.Data {
+		if strings.HasPrefix(stock.Symbol, "GAP") {
+			listed[stock.Symbol] = stock
+		}
+	}
+	s.Equal(expected, listed)
+}
@@ -0,0 +1,136 @@
+//go:build e2e
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"stock-intelligence-backend/internal/jobs"
+	"stock-intelligence-backend/internal/services"
+)
+
+// TestSyncFlow follows stocks from seeding through a batch sync and a manual
+// sync, checking what the list, cache, WebSocket clients and health endpoints
+// report at each step
+func (s *E2ESuite) TestSyncFlow() {
+	symbols := []string{"AAPL", "MSFT", "IBM"}
+	s.seedStocks(symbols...)
+
+	conn := s.dialWS()
+	defer conn.Close()
+	s.eventually(func() bool { return s.websocketClients() == 1 }, "WebSocket client was not registered")
+
+	// The list is cached before the batch
+	s.Equal("MISS", s.cacheStatus("/api/v1/stocks"))
+	s.Equal("HIT", s.cacheStatus("/api/v1/stocks"))
+
+	// Batch sync fills in history for every pending stock, and each synced
+	// stock is pushed to connected WebSocket clients
+	var batch struct {
+		Data *services.SyncJob `json:"data"`
+	}
+	s.Require().Equal(http.StatusAccepted, s.postJSON("/api/v1/sync/batch?limit=3", nil, &batch))
+	syncJob := s.waitForSyncJob(batch.Data.ID)
+	s.Require().Equal(jobs.StatusSucceeded, syncJob.Status, syncJob.Message)
+	s.Equal(len(symbols), syncJob.Attempted)
+	s.Equal(len(symbols), syncJob.Successful)
+
+	pushed := map[string]bool{}
+	for len(pushed) < len(symbols) {
+		s.waitForMessage(conn, "price_update", func(data json.RawMessage) bool {
+			var update struct {
+				Stocks []struct {
+					Symbol string `json:"symbol"`
+				} `json:"stocks"`
+			}
+			// Each synced stock is pushed on its own
+			if json.Unmarshal(data, &update) != nil || len(update.Stocks) != 1 {
+				return false
+			}
+			pushed[update.Stocks[0].Symbol] = true
+			return true
+		})
+	}
+	for _, symbol := range symbols {
+		s.True(pushed[symbol], "%s was not pushed after the batch", symbol)
+	}
+
+	// The batch invalidated the cached list
+	s.Equal("MISS", s.cacheStatus("/api/v1/stocks"))
+
+	s.Require().NotNil(batch.Data.JobID)
+	job := s.waitForJob(*batch.Data.JobID)
+	s.Require().Equal(jobs.StatusSucceeded, job.Status, job.Error)
+
+	var result services.SyncResult
+	s.Require().NoError(json.Unmarshal(job.Result, &result))
+	s.Equal(len(symbols), result.Successful)
+
+	// The list shows the synced prices and coverage
+	var list struct {
+		Data []struct {
+			Symbol         string  `json:"symbol"`
+			CurrentPrice   float64 `json:"current_price"`
+			FirstPriceDate *string `json:"first_price_date"`
+			LastPriceDate  *string `json:"last_price_date"`
+		} `json:"data"`
+	}
+	s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/stocks", &list))
+	s.Require().Len(list.Data, len(symbols))
+	for _, stock := range list.Data {
+		s.Greater(stock.CurrentPrice, 0.0, stock.Symbol)
+		s.NotNil(stock.FirstPriceDate, stock.Symbol)
+		s.NotNil(stock.LastPriceDate, stock.Symbol)
+	}
+
+	// Reading the overview caches it
+	s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/market/overview", &map[string]interface{}{}))
+	s.True(s.cacheKeyExists("market:overview"))
+
+	// A manual sync is pushed to connected WebSocket clients and clears the cache
+	var manual struct {
+		Job *jobs.Job `json:"job"`
+	}
+	s.Require().Equal(http.StatusAccepted, s.postJSON("/api/v1/system/sync/AAPL", nil, &manual))
+
+	s.waitForMessage(conn, "price_update", func(data json.RawMessage) bool {
+		var update struct {
+			Stocks []struct {
+				Symbol string `json:"symbol"`
+			} `json:"stocks"`
+		}
+		return json.Unmarshal(data, &update) == nil && len(update.Stocks) == 1 && update.Stocks[0].Symbol == "AAPL"
+	})
+	s.Equal(jobs.StatusSucceeded, s.waitForJob(manual.Job.ID).Status)
+	s.False(s.cacheKeyExists("stocks:all"))
+	s.False(s.cacheKeyExists("market:overview"))
+
+	// Health reflects the connected client and every call made to the provider
+	s.Equal(1, s.websocketClients())
+
+	var apiCalls int
+	s.Require().NoError(s.db.QueryRow("SELECT COUNT(*) FROM api_calls WHERE service_name = $1", services.ProviderSandbox).Scan(&apiCalls))
+	s.Equal(len(symbols)+1, apiCalls)
+
+	var systemHealth struct {
+		Status     string `json:"status"`
+		Components struct {
+			API struct {
+				Details struct {
+					DailyRemaining int `json:"daily_remaining"`
+				} `json:"details"`
+			} `json:"api"`
+		} `json:"components"`
+	}
+	s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/system/health", &systemHealth))
+	s.Equal("healthy", systemHealth.Status)
+	s.Equal(10000-apiCalls, systemHealth.Components.API.Details.DailyRemaining)
+
+	var syncStatus struct {
+		Data services.SyncStatus `json:"data"`
+	}
+	s.Require().Equal(http.StatusOK, s.getJSON("/api/v1/sync/status", &syncStatus))
+	s.Equal(len(symbols), syncStatus.Data.StocksWithData)
+	s.Zero(syncStatus.Data.StocksNeedingData)
+}
@@ -0,0 +1,39 @@
+//go:build e2e
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// TestWatchlistCRUD creates a watchlist, adds and removes stocks and checks
+// unknown symbols are refused
+func (s *E2ESuite) TestWatchlistCRUD() {
+	s.seedStocks("AAPL", "MSFT")
+
+	var created struct {
+		Data models.Watchlist `json:"data"`
+	}
+	s.Require().Equal(http.StatusCreated, s.postJSON("/api/v1/watchlists", map[string]string{"name": "Tech"}, &created))
+	path := fmt.Sprintf("/api/v1/watchlists/%d", created.Data.ID)
+
+	var watchlist struct {
+		Data models.Watchlist `json:"data"`
+	}
+	for _, symbol := range []string{"AAPL", "MSFT", "AAPL"} {
+		s.Require().Equal(http.StatusOK, s.postJSON(path+"/symbols", map[string]string{"symbol": symbol}, &watchlist))
+	}
+	s.Len(watchlist.Data.Items, 2)
+
+	s.Equal(http.StatusUnprocessableEntity, s.postJSON(path+"/symbols", map[string]string{"symbol": "NOTREAL"}, nil))
+
+	s.Require().Equal(http.StatusOK, s.request(http.MethodDelete, path+"/symbols/AAPL", nil, &watchlist))
+	s.Require().Equal(http.StatusOK, s.getJSON(path, &watchlist))
+	s.Require().Len(watchlist.Data.Items, 1)
+	s.Equal("MSFT", watchlist.Data.Items[0].Symbol)
+
+	s.Equal(http.StatusNotFound, s.getJSON("/api/v1/watchlists/999999", nil))
+}
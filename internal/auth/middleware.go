@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"stock-intelligence-backend/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader carries the key on protected requests
+const APIKeyHeader = "X-API-Key"
+
+// RequireAPIKey rejects requests without a valid key in the X-API-Key header with 401
+func RequireAPIKey(store *APIKeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(APIKeyHeader)
+		if key == "" {
+			handlers.AbortWithErrorResponse(c, http.StatusUnauthorized, handlers.CodeUnauthorized,
+				errors.New("API key required in the "+APIKeyHeader+" header"))
+			return
+		}
+
+		_, err := store.Authenticate(key)
+		if errors.Is(err, ErrInvalidAPIKey) {
+			handlers.AbortWithErrorResponse(c, http.StatusUnauthorized, handlers.CodeUnauthorized, errors.New("Invalid API key"))
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "API key check failed", "error", err)
+			handlers.AbortWithErrorResponse(c, http.StatusInternalServerError, handlers.CodeInternal,
+				errors.New("Failed to check API key"))
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newProtectedRouter serves a single route behind RequireAPIKey
+func newProtectedRouter(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/protected", RequireAPIKey(NewAPIKeyStore(db)), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return router, mock
+}
+
+func postWithKey(router *gin.Engine, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/protected", nil)
+	if key != "" {
+		req.Header.Set(APIKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func expectAuthenticate(mock sqlmock.Sqlmock, key string) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery(`UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP[\s\S]+RETURNING id, name`).
+		WithArgs(hashKey(key))
+}
+
+func TestRequireAPIKey_ValidKey(t *testing.T) {
+	router, mock := newProtectedRouter(t)
+	expectAuthenticate(mock, "si_valid").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "frontend"))
+
+	w := postWithKey(router, "si_valid")
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequireAPIKey_MissingKey(t *testing.T) {
+	router, mock := newProtectedRouter(t)
+
+	w := postWithKey(router, "")
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), APIKeyHeader)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequireAPIKey_UnknownKey(t *testing.T) {
+	router, mock := newProtectedRouter(t)
+	expectAuthenticate(mock, "si_unknown").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+
+	w := postWithKey(router, "si_unknown")
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid API key")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequireAPIKey_StoreError(t *testing.T) {
+	router, mock := newProtectedRouter(t)
+	expectAuthenticate(mock, "si_valid").WillReturnError(errors.New("connection refused"))
+
+	w := postWithKey(router, "si_valid")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "connection refused")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// capturedArg matches any argument and keeps it for later assertions
+type capturedArg struct{ value driver.Value }
+
+func (a *capturedArg) Match(v driver.Value) bool {
+	a.value = v
+	return true
+}
+
+func TestAPIKeyStore_CreateStoresOnlyTheHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	storedHash, storedPrefix := &capturedArg{}, &capturedArg{}
+	mock.ExpectExec("INSERT INTO api_keys").
+		WithArgs("frontend", storedHash, storedPrefix).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	key, err := NewAPIKeyStore(db).Create("frontend")
+
+	require.NoError(t, err)
+	assert.Regexp(t, `^si_[0-9a-f]{64}$`, key)
+	assert.Equal(t, hashKey(key), storedHash.value)
+	assert.Equal(t, key[:11], storedPrefix.value)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
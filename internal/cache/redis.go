@@ -2,22 +2,48 @@ package cache
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+// DefaultNamespace prefixes every key this application writes so it can share a Redis instance
+const DefaultNamespace = "si:"
+
+// invalidateBatchSize bounds how many keys each SCAN step returns and each DEL removes
+const invalidateBatchSize = 500
+
+// DefaultOperationTimeout bounds each Redis command, so a hung node slows
+// requests down by this much rather than blocking them
+const DefaultOperationTimeout = 200 * time.Millisecond
+
 type RedisCache struct {
-	client *redis.Client
-	ctx    context.Context
+	client    *redis.Client
+	namespace string
+	opTimeout time.Duration
+
+	stats        [keyClassCount]counters
+	statsResetAt atomic.Pointer[time.Time]
 }
 
-func NewRedisCache(redisURL string) (*RedisCache, error) {
+// NewRedisCache connects to Redis; all keys are stored under the given namespace prefix
+func NewRedisCache(redisURL string, namespace string) (*RedisCache, error) {
 	if redisURL == "" {
 		redisURL = "redis://localhost:6379"
 	}
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
 
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
@@ -33,105 +59,571 @@ func NewRedisCache(redisURL string) (*RedisCache, error) {
 		return nil, err
 	}
 
-	log.Printf("✅ Connected to Redis cache")
-	return &RedisCache{
-		client: client,
-		ctx:    ctx,
-	}, nil
+	slog.Info("Connected to Redis cache")
+	return NewRedisCacheWithClient(client, namespace), nil
+}
+
+// NewRedisCacheWithClient wraps an already configured client, such as a
+// mock in tests; all keys are stored under the given namespace prefix
+func NewRedisCacheWithClient(client *redis.Client, namespace string) *RedisCache {
+	r := &RedisCache{
+		client:    client,
+		namespace: namespace,
+		opTimeout: DefaultOperationTimeout,
+	}
+	now := time.Now()
+	r.statsResetAt.Store(&now)
+	return r
+}
+
+// SetOperationTimeout sets how long each Redis command may take; 0 leaves
+// commands bounded by their caller's context alone
+func (r *RedisCache) SetOperationTimeout(timeout time.Duration) {
+	r.opTimeout = timeout
+}
+
+// key returns the namespaced form of a cache key
+func (r *RedisCache) key(name string) string {
+	return r.namespace + name
+}
+
+// withTimeout runs op on key bounded by the operation timeout. A command
+// that runs out of time is logged, and its error returned like any other,
+// so callers carry on without the cache.
+func (r *RedisCache) withTimeout(ctx context.Context, key string, op func(ctx context.Context) error) error {
+	if r.opTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.opTimeout)
+		defer cancel()
+	}
+	err := op(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		slog.WarnContext(ctx, "Redis command timed out", "key", key, "timeout", r.opTimeout, "error", err)
+	}
+	return err
 }
 
 // SetStockData caches stock data with expiration
-func (r *RedisCache) SetStockData(key string, data interface{}, expiration time.Duration) error {
+func (r *RedisCache) SetStockData(ctx context.Context, key string, data interface{}, expiration time.Duration) error {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
-	return r.client.Set(r.ctx, key, jsonData, expiration).Err()
+	err = r.withTimeout(ctx, key, func(ctx context.Context) error {
+		return r.client.Set(ctx, r.key(key), string(jsonData), expiration).Err()
+	})
+	r.recordWrite(key, err)
+	return err
 }
 
 // GetStockData retrieves cached stock data
-func (r *RedisCache) GetStockData(key string, dest interface{}) error {
-	val, err := r.client.Get(r.ctx, key).Result()
+func (r *RedisCache) GetStockData(ctx context.Context, key string, dest interface{}) error {
+	var val string
+	err := r.withTimeout(ctx, key, func(ctx context.Context) (err error) {
+		val, err = r.client.Get(ctx, r.key(key)).Result()
+		return err
+	})
+	if err == redis.Nil {
+		r.recordRead(key, false, nil)
+		return err
+	}
+	r.recordRead(key, err == nil, err)
 	if err != nil {
 		return err
 	}
 	return json.Unmarshal([]byte(val), dest)
 }
 
+// staleKey is where the copy of key kept through its stale grace period lives
+func staleKey(key string) string {
+	return "stale:" + key
+}
+
+// SetStockDataWithGrace caches data under key for expiration, and keeps a copy
+// readable through GetStaleStockData for grace beyond it
+func (r *RedisCache) SetStockDataWithGrace(ctx context.Context, key string, data interface{}, expiration, grace time.Duration) error {
+	if grace <= 0 {
+		return r.SetStockData(ctx, key, data, expiration)
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	err = r.withTimeout(ctx, key, func(ctx context.Context) error {
+		pipe := r.client.TxPipeline()
+		pipe.Set(ctx, r.key(key), string(jsonData), expiration)
+		pipe.Set(ctx, r.key(staleKey(key)), string(jsonData), expiration+grace)
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	r.recordWrite(key, err)
+	return err
+}
+
+// GetStaleStockData retrieves the copy of key written by SetStockDataWithGrace,
+// which outlives key by its grace period
+func (r *RedisCache) GetStaleStockData(ctx context.Context, key string, dest interface{}) error {
+	return r.GetStockData(ctx, staleKey(key), dest)
+}
+
+// StocksListKey is the cache key of the full stocks list
+const StocksListKey = "stocks:all"
+
 // SetStocksList caches the full stocks list
-func (r *RedisCache) SetStocksList(stocks interface{}, expiration time.Duration) error {
-	return r.SetStockData("stocks:all", stocks, expiration)
+func (r *RedisCache) SetStocksList(ctx context.Context, stocks interface{}, expiration time.Duration) error {
+	return r.SetStockData(ctx, StocksListKey, stocks, expiration)
 }
 
 // GetStocksList retrieves cached stocks list
-func (r *RedisCache) GetStocksList(dest interface{}) error {
-	return r.GetStockData("stocks:all", dest)
+func (r *RedisCache) GetStocksList(ctx context.Context, dest interface{}) error {
+	return r.GetStockData(ctx, StocksListKey, dest)
 }
 
+// MarketOverviewKey is the cache key of the market overview
+const MarketOverviewKey = "market:overview"
+
 // SetMarketOverview caches market overview data
-func (r *RedisCache) SetMarketOverview(overview interface{}, expiration time.Duration) error {
-	return r.SetStockData("market:overview", overview, expiration)
+func (r *RedisCache) SetMarketOverview(ctx context.Context, overview interface{}, expiration time.Duration) error {
+	return r.SetStockData(ctx, MarketOverviewKey, overview, expiration)
 }
 
 // GetMarketOverview retrieves cached market overview
-func (r *RedisCache) GetMarketOverview(dest interface{}) error {
-	return r.GetStockData("market:overview", dest)
+func (r *RedisCache) GetMarketOverview(ctx context.Context, dest interface{}) error {
+	return r.GetStockData(ctx, MarketOverviewKey, dest)
+}
+
+// MarketFiltersKey is the cache key of the filter values present in the stocks
+const MarketFiltersKey = "market:filters"
+
+// SetMarketHeatmap caches the stocks grouped by sector for the market heatmap
+func (r *RedisCache) SetMarketHeatmap(ctx context.Context, heatmap interface{}, expiration time.Duration) error {
+	return r.SetStockData(ctx, "market:heatmap", heatmap, expiration)
+}
+
+// GetMarketHeatmap retrieves the cached market heatmap
+func (r *RedisCache) GetMarketHeatmap(ctx context.Context, dest interface{}) error {
+	return r.GetStockData(ctx, "market:heatmap", dest)
 }
 
 // SetPerformanceData caches performance rankings
-func (r *RedisCache) SetPerformanceData(performance interface{}, expiration time.Duration) error {
-	return r.SetStockData("performance:rankings", performance, expiration)
+func (r *RedisCache) SetPerformanceData(ctx context.Context, performance interface{}, expiration time.Duration) error {
+	return r.SetStockData(ctx, "performance:rankings", performance, expiration)
 }
 
 // GetPerformanceData retrieves cached performance rankings
-func (r *RedisCache) GetPerformanceData(dest interface{}) error {
-	return r.GetStockData("performance:rankings", dest)
+func (r *RedisCache) GetPerformanceData(ctx context.Context, dest interface{}) error {
+	return r.GetStockData(ctx, "performance:rankings", dest)
+}
+
+// SetWindowMovers caches the market movers over one lookback window
+func (r *RedisCache) SetWindowMovers(ctx context.Context, window string, movers interface{}, expiration time.Duration) error {
+	return r.SetStockData(ctx, "performance:movers:"+window, movers, expiration)
+}
+
+// GetWindowMovers retrieves the cached market movers over one lookback window
+func (r *RedisCache) GetWindowMovers(ctx context.Context, window string, dest interface{}) error {
+	return r.GetStockData(ctx, "performance:movers:"+window, dest)
+}
+
+// SetDividendLeaders caches the stocks ranked by trailing dividend yield
+func (r *RedisCache) SetDividendLeaders(ctx context.Context, leaders interface{}, expiration time.Duration) error {
+	return r.SetStockData(ctx, "dividends:leaders", leaders, expiration)
+}
+
+// GetDividendLeaders retrieves the cached dividend yield ranking
+func (r *RedisCache) GetDividendLeaders(ctx context.Context, dest interface{}) error {
+	return r.GetStockData(ctx, "dividends:leaders", dest)
+}
+
+// SectorDataKey returns the cache key for all of a sector's stocks
+func SectorDataKey(sector string) string {
+	return "stocks:sector:" + sector
 }
 
 // SetSectorData caches sector-specific stock data
-func (r *RedisCache) SetSectorData(sector string, stocks interface{}, expiration time.Duration) error {
-	key := "stocks:sector:" + sector
-	return r.SetStockData(key, stocks, expiration)
+func (r *RedisCache) SetSectorData(ctx context.Context, sector string, stocks interface{}, expiration time.Duration) error {
+	return r.SetStockData(ctx, SectorDataKey(sector), stocks, expiration)
 }
 
 // GetSectorData retrieves cached sector data
-func (r *RedisCache) GetSectorData(sector string, dest interface{}) error {
-	key := "stocks:sector:" + sector
-	return r.GetStockData(key, dest)
+func (r *RedisCache) GetSectorData(ctx context.Context, sector string, dest interface{}) error {
+	return r.GetStockData(ctx, SectorDataKey(sector), dest)
+}
+
+// GetMultiSectorData retrieves the cached lists of several sectors with a
+// single MGET. It returns the cached JSON of each sector found; sectors
+// missing from the result are not cached.
+func (r *RedisCache) GetMultiSectorData(ctx context.Context, sectors []string) (map[string]json.RawMessage, error) {
+	if len(sectors) == 0 {
+		return map[string]json.RawMessage{}, nil
+	}
+	keys := make([]string, len(sectors))
+	for i, sector := range sectors {
+		keys[i] = r.key(SectorDataKey(sector))
+	}
+
+	var values []interface{}
+	err := r.withTimeout(ctx, SectorDataKey("*"), func(ctx context.Context) (err error) {
+		values, err = r.client.MGet(ctx, keys...).Result()
+		return err
+	})
+	if err != nil {
+		for _, sector := range sectors {
+			r.recordRead(SectorDataKey(sector), false, err)
+		}
+		return nil, err
+	}
+
+	found := make(map[string]json.RawMessage, len(sectors))
+	for i, sector := range sectors {
+		value, ok := values[i].(string)
+		r.recordRead(SectorDataKey(sector), ok, nil)
+		if ok {
+			found[sector] = json.RawMessage(value)
+		}
+	}
+	return found, nil
+}
+
+// SetMultiSectorData caches several sectors' lists in one transaction, a
+// single round trip, each for expiration and with a stale copy for grace
+// beyond it as SetStockDataWithGrace keeps
+func (r *RedisCache) SetMultiSectorData(ctx context.Context, sectors map[string]interface{}, expiration, grace time.Duration) error {
+	if len(sectors) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(sectors))
+	values := make(map[string]string, len(sectors))
+	for sector, stocks := range sectors {
+		jsonData, err := json.Marshal(stocks)
+		if err != nil {
+			return err
+		}
+		names = append(names, sector)
+		values[sector] = string(jsonData)
+	}
+	slices.Sort(names)
+
+	err := r.withTimeout(ctx, SectorDataKey("*"), func(ctx context.Context) error {
+		pipe := r.client.TxPipeline()
+		for _, sector := range names {
+			pipe.Set(ctx, r.key(SectorDataKey(sector)), values[sector], expiration)
+			if grace > 0 {
+				pipe.Set(ctx, r.key(staleKey(SectorDataKey(sector))), values[sector], expiration+grace)
+			}
+		}
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	for _, sector := range names {
+		r.recordWrite(SectorDataKey(sector), err)
+	}
+	return err
+}
+
+// SectorPageKey returns the cache key for one page of a sector's stocks
+func SectorPageKey(sector string, limit, offset int) string {
+	return fmt.Sprintf("stocks:sector:%s:%d:%d", sector, limit, offset)
+}
+
+// SetSectorPage caches a single page of sector results
+func (r *RedisCache) SetSectorPage(ctx context.Context, sector string, limit, offset int, page interface{}, expiration time.Duration) error {
+	return r.SetStockData(ctx, SectorPageKey(sector, limit, offset), page, expiration)
+}
+
+// GetSectorPage retrieves a cached page of sector results
+func (r *RedisCache) GetSectorPage(ctx context.Context, sector string, limit, offset int, dest interface{}) error {
+	return r.GetStockData(ctx, SectorPageKey(sector, limit, offset), dest)
+}
+
+// MissingStockKey returns the cache key recording that symbol is not a
+// stock. It holds no value to decode, so it cannot pass for a cached empty one.
+func MissingStockKey(symbol string) string {
+	return "stock:missing:" + symbol
+}
+
+// MarkStockMissing records that symbol is not a stock for expiration
+func (r *RedisCache) MarkStockMissing(ctx context.Context, symbol string, expiration time.Duration) error {
+	key := MissingStockKey(symbol)
+	return r.withTimeout(ctx, key, func(ctx context.Context) error {
+		return r.client.Set(ctx, r.key(key), "", expiration).Err()
+	})
+}
+
+// IsStockMissing reports whether symbol was recorded as not a stock
+func (r *RedisCache) IsStockMissing(ctx context.Context, symbol string) (bool, error) {
+	key := MissingStockKey(symbol)
+	var count int64
+	err := r.withTimeout(ctx, key, func(ctx context.Context) (err error) {
+		count, err = r.client.Exists(ctx, r.key(key)).Result()
+		return err
+	})
+	return count > 0, err
+}
+
+// QuotesKey returns the cache key for quotes of a set of symbols, given in
+// sorted order; the set is hashed to keep keys short. Per-stock invalidation
+// cannot find these keys, so they rely on a short expiry and InvalidateAll.
+func QuotesKey(sortedSymbols []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(sortedSymbols, ",")))
+	return "quotes:" + hex.EncodeToString(sum[:16])
+}
+
+// SetQuotes caches the quotes of a set of symbols
+func (r *RedisCache) SetQuotes(ctx context.Context, sortedSymbols []string, quotes interface{}, expiration time.Duration) error {
+	return r.SetStockData(ctx, QuotesKey(sortedSymbols), quotes, expiration)
+}
+
+// GetQuotes retrieves the cached quotes of a set of symbols
+func (r *RedisCache) GetQuotes(ctx context.Context, sortedSymbols []string, dest interface{}) error {
+	return r.GetStockData(ctx, QuotesKey(sortedSymbols), dest)
+}
+
+// historicalCacheVersion prefixes historical data keys; bump it when the key
+// scheme or cached payload changes so entries written by older builds are never read
+const historicalCacheVersion = "v2"
+
+// HistoricalDataKey returns the cache key for a symbol's historical data over a number of days
+func HistoricalDataKey(symbol string, days int) string {
+	return fmt.Sprintf("historical:%s:%s:%d", historicalCacheVersion, symbol, days)
 }
 
 // SetHistoricalData caches historical performance data
-func (r *RedisCache) SetHistoricalData(symbol string, days int, data interface{}, expiration time.Duration) error {
-	key := "historical:" + symbol + ":" + string(rune(days))
-	return r.SetStockData(key, data, expiration)
+func (r *RedisCache) SetHistoricalData(ctx context.Context, symbol string, days int, data interface{}, expiration time.Duration) error {
+	return r.SetStockData(ctx, HistoricalDataKey(symbol, days), data, expiration)
 }
 
 // GetHistoricalData retrieves cached historical data
-func (r *RedisCache) GetHistoricalData(symbol string, days int, dest interface{}) error {
-	key := "historical:" + symbol + ":" + string(rune(days))
-	return r.GetStockData(key, dest)
+func (r *RedisCache) GetHistoricalData(ctx context.Context, symbol string, days int, dest interface{}) error {
+	return r.GetStockData(ctx, HistoricalDataKey(symbol, days), dest)
 }
 
-// InvalidateStock removes cached data for a specific stock
-func (r *RedisCache) InvalidateStock(symbol string) error {
-	pattern := "*" + symbol + "*"
-	keys, err := r.client.Keys(r.ctx, pattern).Result()
+// AdjustedHistoricalDataKey returns the cache key for a symbol's split-adjusted historical data
+func AdjustedHistoricalDataKey(symbol string, days int) string {
+	return fmt.Sprintf("historical:%s:%s:adjusted:%d", historicalCacheVersion, symbol, days)
+}
+
+// SetAdjustedHistoricalData caches split-adjusted historical performance data
+func (r *RedisCache) SetAdjustedHistoricalData(ctx context.Context, symbol string, days int, data interface{}, expiration time.Duration) error {
+	return r.SetStockData(ctx, AdjustedHistoricalDataKey(symbol, days), data, expiration)
+}
+
+// GetAdjustedHistoricalData retrieves cached split-adjusted historical data
+func (r *RedisCache) GetAdjustedHistoricalData(ctx context.Context, symbol string, days int, dest interface{}) error {
+	return r.GetStockData(ctx, AdjustedHistoricalDataKey(symbol, days), dest)
+}
+
+// PriceSeriesKey returns the cache key for a symbol's price series at a
+// resolution; from and to are the range's dates, empty when open-ended
+func PriceSeriesKey(symbol, resolution, from, to string) string {
+	return fmt.Sprintf("prices:%s:%s:%s:%s", symbol, resolution, from, to)
+}
+
+// SetPriceSeries caches a symbol's price series over a range
+func (r *RedisCache) SetPriceSeries(ctx context.Context, symbol, resolution, from, to string, series interface{}, expiration time.Duration) error {
+	return r.SetStockData(ctx, PriceSeriesKey(symbol, resolution, from, to), series, expiration)
+}
+
+// GetPriceSeries retrieves a symbol's cached price series over a range
+func (r *RedisCache) GetPriceSeries(ctx context.Context, symbol, resolution, from, to string, dest interface{}) error {
+	return r.GetStockData(ctx, PriceSeriesKey(symbol, resolution, from, to), dest)
+}
+
+// Response cache bookkeeping, kept through InvalidateAll
+const (
+	responseGenerationKey = "response:generation"
+	responseStatsKey      = "response:stats"
+)
+
+// ResponseKey returns the cache key of the response to a request URI as of a
+// generation; the URI is hashed to keep keys short
+func ResponseKey(generation int64, requestURI string) string {
+	sum := sha256.Sum256([]byte(requestURI))
+	return fmt.Sprintf("response:%d:%s", generation, hex.EncodeToString(sum[:16]))
+}
+
+// ResponseGeneration returns the generation of cached responses, which
+// InvalidateAll bumps so responses cached before it are never read again
+func (r *RedisCache) ResponseGeneration(ctx context.Context) (int64, error) {
+	var generation int64
+	err := r.withTimeout(ctx, responseGenerationKey, func(ctx context.Context) (err error) {
+		generation, err = r.client.Get(ctx, r.key(responseGenerationKey)).Int64()
+		return err
+	})
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return generation, err
+}
+
+// SetResponse caches the response to a request URI as of a generation
+func (r *RedisCache) SetResponse(ctx context.Context, generation int64, requestURI string, response interface{}, expiration time.Duration) error {
+	return r.SetStockData(ctx, ResponseKey(generation, requestURI), response, expiration)
+}
+
+// GetResponse retrieves the cached response to a request URI as of a generation
+func (r *RedisCache) GetResponse(ctx context.Context, generation int64, requestURI string, dest interface{}) error {
+	return r.GetStockData(ctx, ResponseKey(generation, requestURI), dest)
+}
+
+// RecordResponseLookup counts a response cache hit or miss
+func (r *RedisCache) RecordResponseLookup(ctx context.Context, hit bool) error {
+	field := "misses"
+	if hit {
+		field = "hits"
+	}
+	return r.withTimeout(ctx, responseStatsKey, func(ctx context.Context) error {
+		return r.client.HIncrBy(ctx, r.key(responseStatsKey), field, 1).Err()
+	})
+}
+
+// ResponseStats returns the response cache hits and misses counted so far
+func (r *RedisCache) ResponseStats(ctx context.Context) (hits, misses int64, err error) {
+	var values []interface{}
+	err = r.withTimeout(ctx, responseStatsKey, func(ctx context.Context) (err error) {
+		values, err = r.client.HMGet(ctx, r.key(responseStatsKey), "hits", "misses").Result()
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	counts := make([]int64, len(values))
+	for i, value := range values {
+		if s, ok := value.(string); ok {
+			if counts[i], err = strconv.ParseInt(s, 10, 64); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	return counts[0], counts[1], nil
+}
+
+// InvalidateStock removes cached data for a specific stock, including the
+// record of it not being a stock
+func (r *RedisCache) InvalidateStock(ctx context.Context, symbol string) error {
+	return r.deleteMatching(ctx, r.key("*"+symbol+"*"))
+}
+
+// InvalidateAll removes all cached stock data, leaving keys outside our
+// namespace untouched. The response generation is bumped first, so a
+// response cached while the keys are deleted is never read.
+func (r *RedisCache) InvalidateAll(ctx context.Context) error {
+	err := r.withTimeout(ctx, responseGenerationKey, func(ctx context.Context) error {
+		return r.client.Incr(ctx, r.key(responseGenerationKey)).Err()
+	})
 	if err != nil {
 		return err
 	}
+	return r.deleteMatching(ctx, r.key("*"), r.key(responseGenerationKey), r.key(responseStatsKey))
+}
+
+// deleteMatching removes keys matching pattern, other than keep and rate limit
+// buckets, using SCAN so Redis is never blocked by a full keyspace walk. The
+// operation timeout bounds each SCAN and DEL step rather than the whole walk.
+func (r *RedisCache) deleteMatching(ctx context.Context, pattern string, keep ...string) error {
+	var cursor uint64
+	for {
+		var keys []string
+		var next uint64
+		err := r.withTimeout(ctx, pattern, func(ctx context.Context) (err error) {
+			keys, next, err = r.client.Scan(ctx, cursor, pattern, invalidateBatchSize).Result()
+			return err
+		})
+		if err != nil {
+			return err
+		}
 
-	if len(keys) > 0 {
-		return r.client.Del(r.ctx, keys...).Err()
+		keys = slices.DeleteFunc(keys, func(key string) bool {
+			return slices.Contains(keep, key) || strings.HasPrefix(key, r.key(rateLimitPrefix))
+		})
+		if len(keys) > 0 {
+			err := r.withTimeout(ctx, pattern, func(ctx context.Context) error {
+				return r.client.Del(ctx, keys...).Err()
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
 	}
-	return nil
 }
 
-// InvalidateAll removes all cached stock data
-func (r *RedisCache) InvalidateAll() error {
-	return r.client.FlushAll(r.ctx).Err()
+// takeTokenScript refills a token bucket for the time since it was last
+// touched, then takes a token if a whole one is left. Times are in
+// milliseconds and passed in, so every server refills by the same clock.
+// Tokens are returned as a string, since Redis truncates Lua numbers.
+var takeTokenScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local per_ms = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens'))
+local updated = tonumber(redis.call('HGET', KEYS[1], 'updated'))
+if tokens == nil or updated == nil then
+	tokens = capacity
+	updated = now
+end
+if now > updated then
+	tokens = math.min(capacity, tokens + (now - updated) * per_ms)
+	updated = now
+end
+local taken = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	taken = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(updated))
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / per_ms))
+return {taken, tostring(tokens)}
+`)
+
+// rateLimitPrefix starts the keys of rate limit buckets, which no invalidation deletes
+const rateLimitPrefix = "ratelimit:"
+
+// RateLimitKey is the cache key of a client's rate limit bucket
+func RateLimitKey(client string) string {
+	return rateLimitPrefix + client
+}
+
+// TakeToken takes a token from the bucket of client, which holds up to
+// capacity tokens and refills perSecond tokens a second, as of now. It
+// reports whether a token was taken and the tokens left. A bucket left alone
+// until it is full again expires. Buckets are not cached data, so no
+// invalidation refills them.
+func (r *RedisCache) TakeToken(ctx context.Context, client string, capacity int, perSecond float64, now time.Time) (bool, float64, error) {
+	var result []interface{}
+	err := r.withTimeout(ctx, RateLimitKey(client), func(ctx context.Context) (err error) {
+		result, err = takeTokenScript.Run(ctx, r.client, []string{r.key(RateLimitKey(client))},
+			capacity, strconv.FormatFloat(perSecond/1000, 'g', -1, 64), now.UnixMilli()).Slice()
+		return err
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	if len(result) != 2 {
+		return false, 0, fmt.Errorf("unexpected rate limit reply %v", result)
+	}
+	taken, _ := result[0].(int64)
+	value, _ := result[1].(string)
+	tokens, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("unexpected rate limit tokens %q: %w", value, err)
+	}
+	return taken == 1, tokens, nil
+}
+
+// PingContext checks that Redis is reachable, giving up when ctx is done or
+// the operation timeout runs out
+func (r *RedisCache) PingContext(ctx context.Context) error {
+	return r.withTimeout(ctx, "", func(ctx context.Context) error {
+		return r.client.Ping(ctx).Err()
+	})
 }
 
 // Close closes the Redis connection
 func (r *RedisCache) Close() error {
 	return r.client.Close()
-}
\ No newline at end of file
+}
@@ -1,12 +1,15 @@
 package cache
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
 	"stock-intelligence-backend/internal/models"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redismock/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -37,7 +40,7 @@ func TestNewRedisCache(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cache, err := NewRedisCache(tt.redisURL)
+			cache, err := NewRedisCache(tt.redisURL, "")
 			if tt.wantErr {
 				assert.Error(t, err)
 				assert.Nil(t, cache)
@@ -58,7 +61,6 @@ func TestRedisCache_SetAndGetStockData(t *testing.T) {
 
 	cache := &RedisCache{
 		client: redis,
-		ctx:    redis.Context(),
 	}
 
 	testData := map[string]interface{}{
@@ -71,18 +73,18 @@ func TestRedisCache_SetAndGetStockData(t *testing.T) {
 
 	// Test SetStockData
 	mock.ExpectSet("test-key", string(jsonData), 5*time.Minute).SetVal("OK")
-	err = cache.SetStockData("test-key", testData, 5*time.Minute)
+	err = cache.SetStockData(context.Background(), "test-key", testData, 5*time.Minute)
 	assert.NoError(t, err)
 
 	// Test GetStockData
 	mock.ExpectGet("test-key").SetVal(string(jsonData))
 	var result map[string]interface{}
-	err = cache.GetStockData("test-key", &result)
+	err = cache.GetStockData(context.Background(), "test-key", &result)
 	assert.NoError(t, err)
 	assert.Equal(t, "AAPL", result["symbol"])
 	assert.Equal(t, 150.0, result["price"])
 
-	err = mock.ExpectationsMet()
+	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
@@ -92,7 +94,6 @@ func TestRedisCache_SetAndGetStocksList(t *testing.T) {
 
 	cache := &RedisCache{
 		client: redis,
-		ctx:    redis.Context(),
 	}
 
 	testStocks := []models.Stock{
@@ -115,19 +116,19 @@ func TestRedisCache_SetAndGetStocksList(t *testing.T) {
 
 	// Test SetStocksList
 	mock.ExpectSet("stocks:all", string(jsonData), time.Hour).SetVal("OK")
-	err = cache.SetStocksList(testStocks, time.Hour)
+	err = cache.SetStocksList(context.Background(), testStocks, time.Hour)
 	assert.NoError(t, err)
 
 	// Test GetStocksList
 	mock.ExpectGet("stocks:all").SetVal(string(jsonData))
 	var result []models.Stock
-	err = cache.GetStocksList(&result)
+	err = cache.GetStocksList(context.Background(), &result)
 	assert.NoError(t, err)
 	assert.Len(t, result, 2)
 	assert.Equal(t, "AAPL", result[0].Symbol)
 	assert.Equal(t, "MSFT", result[1].Symbol)
 
-	err = mock.ExpectationsMet()
+	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
@@ -137,7 +138,6 @@ func TestRedisCache_SetAndGetSectorData(t *testing.T) {
 
 	cache := &RedisCache{
 		client: redis,
-		ctx:    redis.Context(),
 	}
 
 	technologyStocks := []models.Stock{
@@ -155,29 +155,61 @@ func TestRedisCache_SetAndGetSectorData(t *testing.T) {
 
 	// Test SetSectorData
 	mock.ExpectSet("stocks:sector:Technology", string(jsonData), time.Hour).SetVal("OK")
-	err = cache.SetSectorData("Technology", technologyStocks, time.Hour)
+	err = cache.SetSectorData(context.Background(), "Technology", technologyStocks, time.Hour)
 	assert.NoError(t, err)
 
 	// Test GetSectorData
 	mock.ExpectGet("stocks:sector:Technology").SetVal(string(jsonData))
 	var result []models.Stock
-	err = cache.GetSectorData("Technology", &result)
+	err = cache.GetSectorData(context.Background(), "Technology", &result)
 	assert.NoError(t, err)
 	assert.Len(t, result, 1)
 	assert.Equal(t, "AAPL", result[0].Symbol)
 	assert.Equal(t, "Technology", result[0].Sector)
 
-	err = mock.ExpectationsMet()
+	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
+func TestRedisCache_MultiSectorData(t *testing.T) {
+	redis, mock := redismock.NewClientMock()
+	defer redis.Close()
+
+	cache := NewRedisCacheWithClient(redis, "si:")
+
+	technology, err := json.Marshal([]models.Stock{{Symbol: "AAPL", Sector: "Technology"}})
+	require.NoError(t, err)
+	energy, err := json.Marshal([]models.Stock{{Symbol: "XOM", Sector: "Energy"}})
+	require.NoError(t, err)
+
+	// One MGET reads every sector; a missing sector comes back nil
+	mock.ExpectMGet("si:stocks:sector:Technology", "si:stocks:sector:Energy").SetVal([]interface{}{string(technology), nil})
+	found, err := cache.GetMultiSectorData(context.Background(), []string{"Technology", "Energy"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]json.RawMessage{"Technology": technology}, found)
+
+	// The missing ones are written back in one transaction, with their stale copies
+	mock.ExpectTxPipeline()
+	mock.ExpectSet("si:stocks:sector:Energy", string(energy), time.Hour).SetVal("OK")
+	mock.ExpectSet("si:stale:stocks:sector:Energy", string(energy), time.Hour+time.Minute).SetVal("OK")
+	mock.ExpectTxPipelineExec()
+	err = cache.SetMultiSectorData(context.Background(), map[string]interface{}{
+		"Energy": []models.Stock{{Symbol: "XOM", Sector: "Energy"}},
+	}, time.Hour, time.Minute)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, int64(1), cache.stats[classSector].hits.Load())
+	assert.Equal(t, int64(1), cache.stats[classSector].misses.Load())
+	assert.Equal(t, int64(1), cache.stats[classSector].sets.Load())
+}
+
 func TestRedisCache_SetAndGetMarketOverview(t *testing.T) {
 	redis, mock := redismock.NewClientMock()
 	defer redis.Close()
 
 	cache := &RedisCache{
 		client: redis,
-		ctx:    redis.Context(),
 	}
 
 	overview := map[string]interface{}{
@@ -192,18 +224,18 @@ func TestRedisCache_SetAndGetMarketOverview(t *testing.T) {
 
 	// Test SetMarketOverview
 	mock.ExpectSet("market:overview", string(jsonData), 30*time.Minute).SetVal("OK")
-	err = cache.SetMarketOverview(overview, 30*time.Minute)
+	err = cache.SetMarketOverview(context.Background(), overview, 30*time.Minute)
 	assert.NoError(t, err)
 
 	// Test GetMarketOverview
 	mock.ExpectGet("market:overview").SetVal(string(jsonData))
 	var result map[string]interface{}
-	err = cache.GetMarketOverview(&result)
+	err = cache.GetMarketOverview(context.Background(), &result)
 	assert.NoError(t, err)
 	assert.Equal(t, float64(100), result["total_stocks"])
 	assert.Equal(t, float64(55), result["advancing_count"])
 
-	err = mock.ExpectationsMet()
+	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
@@ -213,17 +245,16 @@ func TestRedisCache_InvalidateStock(t *testing.T) {
 
 	cache := &RedisCache{
 		client: redis,
-		ctx:    redis.Context(),
 	}
 
 	// Test InvalidateStock
-	mock.ExpectKeys("*AAPL*").SetVal([]string{"stock:AAPL", "historical:AAPL:30"})
+	mock.ExpectScan(0, "*AAPL*", invalidateBatchSize).SetVal([]string{"stock:AAPL", "historical:AAPL:30"}, 0)
 	mock.ExpectDel("stock:AAPL", "historical:AAPL:30").SetVal(2)
 
-	err := cache.InvalidateStock("AAPL")
+	err := cache.InvalidateStock(context.Background(), "AAPL")
 	assert.NoError(t, err)
 
-	err = mock.ExpectationsMet()
+	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
@@ -232,17 +263,238 @@ func TestRedisCache_InvalidateAll(t *testing.T) {
 	defer redis.Close()
 
 	cache := &RedisCache{
-		client: redis,
-		ctx:    redis.Context(),
+		client:    redis,
+		namespace: "si:",
+	}
+
+	// Test InvalidateAll bumps the response generation, then scans only our
+	// namespace, deleting in batches across cursors and keeping the generation
+	mock.ExpectIncr("si:response:generation").SetVal(3)
+	mock.ExpectScan(0, "si:*", invalidateBatchSize).SetVal([]string{"si:stocks:all", "si:response:generation", "si:market:overview"}, 42)
+	mock.ExpectDel("si:stocks:all", "si:market:overview").SetVal(2)
+	mock.ExpectScan(42, "si:*", invalidateBatchSize).SetVal([]string{"si:performance:rankings"}, 0)
+	mock.ExpectDel("si:performance:rankings").SetVal(1)
+
+	err := cache.InvalidateAll(context.Background())
+	assert.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestRedisCache_InvalidateAll_KeepsForeignKeys(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	cache, err := NewRedisCache("redis://"+server.Addr(), "si:")
+	require.NoError(t, err)
+	defer cache.Close()
+
+	// Keys owned by another application sharing the instance
+	require.NoError(t, server.Set("sessions:abc123", "user-1"))
+	require.NoError(t, server.Set("stocks:all", "not ours"))
+
+	// Enough of our keys to need several SCAN batches
+	for i := 0; i < 1200; i++ {
+		require.NoError(t, cache.SetSectorData(context.Background(), fmt.Sprintf("sector-%d", i), []string{}, time.Hour))
+	}
+	require.NoError(t, cache.SetStocksList(context.Background(), []models.Stock{}, time.Hour))
+	require.NoError(t, cache.SetHistoricalData(context.Background(), "AAPL", 30, map[string]int{"count": 30}, time.Hour))
+
+	require.NoError(t, cache.InvalidateAll(context.Background()))
+
+	// Only the response generation, bumped rather than deleted, is left of ours
+	assert.Equal(t, []string{"sessions:abc123", "si:response:generation", "stocks:all"}, server.Keys())
+
+	var stocks []models.Stock
+	assert.Error(t, cache.GetStocksList(context.Background(), &stocks))
+}
+
+func TestRedisCache_StaleCopyOutlivesKeyByGrace(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	cache, err := NewRedisCache("redis://"+server.Addr(), "si:")
+	require.NoError(t, err)
+	defer cache.Close()
+
+	require.NoError(t, cache.SetStockDataWithGrace(context.Background(), StocksListKey, []string{"AAPL"}, time.Minute, 5*time.Minute))
+	assert.Equal(t, time.Minute, server.TTL("si:stocks:all"))
+	assert.Equal(t, 6*time.Minute, server.TTL("si:stale:stocks:all"))
+
+	// Once the key expires, its copy is still readable until the grace runs out
+	server.FastForward(2 * time.Minute)
+	var symbols []string
+	assert.Error(t, cache.GetStocksList(context.Background(), &symbols))
+	require.NoError(t, cache.GetStaleStockData(context.Background(), StocksListKey, &symbols))
+	assert.Equal(t, []string{"AAPL"}, symbols)
+
+	server.FastForward(5 * time.Minute)
+	assert.Error(t, cache.GetStaleStockData(context.Background(), StocksListKey, &symbols))
+
+	// Without a grace no copy is kept
+	require.NoError(t, cache.SetStockDataWithGrace(context.Background(), MarketOverviewKey, map[string]int{"total": 1}, time.Minute, 0))
+	assert.False(t, server.Exists("si:stale:market:overview"))
+}
+
+func TestRedisCache_OperationTimeout(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	cache, err := NewRedisCache("redis://"+server.Addr(), "si:")
+	require.NoError(t, err)
+	defer cache.Close()
+	assert.Equal(t, DefaultOperationTimeout, cache.opTimeout)
+	require.NoError(t, cache.SetStocksList(context.Background(), []string{"AAPL"}, time.Hour))
+
+	// A caller's context that is already done fails the command too
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	var symbols []string
+	assert.Error(t, cache.GetStocksList(expired, &symbols))
+
+	cache.SetOperationTimeout(time.Nanosecond)
+	assert.Error(t, cache.GetStocksList(context.Background(), &symbols))
+	assert.Error(t, cache.InvalidateAll(context.Background()))
+
+	cache.SetOperationTimeout(0)
+	require.NoError(t, cache.GetStocksList(context.Background(), &symbols))
+	assert.Equal(t, []string{"AAPL"}, symbols)
+}
+
+func TestRedisCache_StatsCountHitsAndMisses(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	cache, err := NewRedisCache("redis://"+server.Addr(), "si:")
+	require.NoError(t, err)
+	defer cache.Close()
+	ctx := context.Background()
+
+	var stocks []string
+	assert.Error(t, cache.GetStocksList(ctx, &stocks))
+	require.NoError(t, cache.SetStocksList(ctx, []string{"AAPL"}, time.Hour))
+	require.NoError(t, cache.GetStocksList(ctx, &stocks))
+	require.NoError(t, cache.GetStocksList(ctx, &stocks))
+	assert.Error(t, cache.GetSectorData(ctx, "Energy", &stocks))
+	require.NoError(t, cache.SetHistoricalData(ctx, "AAPL", 30, map[string]int{"count": 30}, time.Hour))
+	require.NoError(t, server.Set("foreign:key", "not ours"))
+
+	stats, err := cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, OperationCounts{Hits: 2, Misses: 1, Sets: 1, HitRate: 2.0 / 3}, stats.Classes["stocks_list"])
+	assert.Equal(t, OperationCounts{Misses: 1}, stats.Classes["sector"])
+	assert.Equal(t, OperationCounts{Sets: 1}, stats.Classes["historical"])
+	assert.Equal(t, OperationCounts{Hits: 2, Misses: 2, Sets: 2, HitRate: 0.5}, stats.Total)
+	assert.Equal(t, int64(2), stats.Keys, "only keys under our namespace")
+
+	// A Redis that does not answer in time is counted as an error, not a miss
+	cache.SetOperationTimeout(time.Nanosecond)
+	assert.Error(t, cache.GetStocksList(ctx, &stocks))
+	cache.SetOperationTimeout(DefaultOperationTimeout)
+
+	cache.ResetStats()
+	stats, err = cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, OperationCounts{}, stats.Total)
+	assert.WithinDuration(t, time.Now(), stats.ResetAt, time.Second)
+}
+
+func TestUsedMemory(t *testing.T) {
+	used := usedMemory("# Memory\r\nused_memory:1048576\r\nused_memory_human:1.00M\r\n")
+	require.NotNil(t, used)
+	assert.Equal(t, int64(1048576), *used)
+	assert.Nil(t, usedMemory("# Memory\r\n"))
+}
+
+func TestRedisCache_ResponsesAndGeneration(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	cache, err := NewRedisCache("redis://"+server.Addr(), "si:")
+	require.NoError(t, err)
+	defer cache.Close()
+
+	generation, err := cache.ResponseGeneration(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, generation)
+
+	require.NoError(t, cache.SetResponse(context.Background(), generation, "/api/v1/stocks?limit=10", map[string]int{"count": 10}, time.Hour))
+	var response map[string]int
+	require.NoError(t, cache.GetResponse(context.Background(), generation, "/api/v1/stocks?limit=10", &response))
+	assert.Equal(t, 10, response["count"])
+	assert.Error(t, cache.GetResponse(context.Background(), generation, "/api/v1/stocks?limit=20", &response))
+	require.NoError(t, cache.RecordResponseLookup(context.Background(), false))
+	require.NoError(t, cache.RecordResponseLookup(context.Background(), true))
+	require.NoError(t, cache.RecordResponseLookup(context.Background(), true))
+
+	// Invalidation moves on to a new generation and keeps the counts
+	require.NoError(t, cache.InvalidateAll(context.Background()))
+	generation, err = cache.ResponseGeneration(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), generation)
+	assert.Error(t, cache.GetResponse(context.Background(), 0, "/api/v1/stocks?limit=10", &response))
+
+	hits, misses, err := cache.ResponseStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), hits)
+	assert.Equal(t, int64(1), misses)
+}
+
+func TestRedisCache_TakeToken(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	cache, err := NewRedisCache("redis://"+server.Addr(), "si:")
+	require.NoError(t, err)
+	defer cache.Close()
+
+	// A bucket of three refilling a token a second, on a clock the test moves
+	now := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	for want := 2.0; want >= 0; want-- {
+		taken, tokens, err := cache.TakeToken(context.Background(), "203.0.113.7", 3, 1, now)
+		require.NoError(t, err)
+		assert.True(t, taken)
+		assert.Equal(t, want, tokens)
+	}
+	taken, tokens, err := cache.TakeToken(context.Background(), "203.0.113.7", 3, 1, now)
+	require.NoError(t, err)
+	assert.False(t, taken)
+	assert.Zero(t, tokens)
+	assert.True(t, server.Exists("si:ratelimit:203.0.113.7"))
+
+	// Half a second refills half a token, which is kept for the next request
+	taken, tokens, err = cache.TakeToken(context.Background(), "203.0.113.7", 3, 1, now.Add(500*time.Millisecond))
+	require.NoError(t, err)
+	assert.False(t, taken)
+	assert.Equal(t, 0.5, tokens)
+	taken, tokens, err = cache.TakeToken(context.Background(), "203.0.113.7", 3, 1, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.True(t, taken)
+	assert.Zero(t, tokens)
+
+	// The bucket never holds more than its capacity, and expires once it would be full
+	taken, tokens, err = cache.TakeToken(context.Background(), "203.0.113.7", 3, 1, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, taken)
+	assert.Equal(t, 2.0, tokens)
+	server.FastForward(4 * time.Second)
+	assert.False(t, server.Exists("si:ratelimit:203.0.113.7"))
+}
+
+func TestRedisCache_NamespacedKeys(t *testing.T) {
+	redis, mock := redismock.NewClientMock()
+	defer redis.Close()
+
+	cache := &RedisCache{
+		client:    redis,
+		namespace: "si:",
 	}
 
-	// Test InvalidateAll
-	mock.ExpectFlushAll().SetVal("OK")
+	mock.ExpectSet("si:stocks:all", "[]", time.Minute).SetVal("OK")
+	err := cache.SetStocksList(context.Background(), []models.Stock{}, time.Minute)
+	assert.NoError(t, err)
 
-	err := cache.InvalidateAll()
+	mock.ExpectGet("si:market:overview").SetVal("{}")
+	var overview map[string]interface{}
+	err = cache.GetMarketOverview(context.Background(), &overview)
 	assert.NoError(t, err)
 
-	err = mock.ExpectationsMet()
+	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
@@ -252,17 +504,16 @@ func TestRedisCache_GetStockData_NotFound(t *testing.T) {
 
 	cache := &RedisCache{
 		client: redis,
-		ctx:    redis.Context(),
 	}
 
 	// Test cache miss
 	mock.ExpectGet("nonexistent-key").RedisNil()
 
 	var result map[string]interface{}
-	err := cache.GetStockData("nonexistent-key", &result)
+	err := cache.GetStockData(context.Background(), "nonexistent-key", &result)
 	assert.Error(t, err)
 
-	err = mock.ExpectationsMet()
+	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
@@ -272,17 +523,70 @@ func TestRedisCache_SetStockData_MarshalError(t *testing.T) {
 
 	cache := &RedisCache{
 		client: redis,
-		ctx:    redis.Context(),
 	}
 
 	// Test with unmarshalable data (contains channels, which can't be marshaled)
 	invalidData := make(chan int)
 
-	err := cache.SetStockData("test-key", invalidData, time.Minute)
+	err := cache.SetStockData(context.Background(), "test-key", invalidData, time.Minute)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "json: unsupported type")
 }
 
+func TestRedisCache_SetAndGetHistoricalData_KeyFormat(t *testing.T) {
+	tests := []struct {
+		days int
+		key  string
+	}{
+		{days: 7, key: "historical:v2:AAPL:7"},
+		{days: 30, key: "historical:v2:AAPL:30"},
+		{days: 90, key: "historical:v2:AAPL:90"},
+		{days: 286, key: "historical:v2:AAPL:286"},
+		{days: 365, key: "historical:v2:AAPL:365"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			redis, mock := redismock.NewClientMock()
+			defer redis.Close()
+
+			cache := &RedisCache{
+				client: redis,
+			}
+
+			data := map[string]interface{}{"symbol": "AAPL", "count": float64(tt.days)}
+			jsonData, err := json.Marshal(data)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.key, HistoricalDataKey("AAPL", tt.days))
+
+			mock.ExpectSet(tt.key, string(jsonData), 55*time.Minute).SetVal("OK")
+			err = cache.SetHistoricalData(context.Background(), "AAPL", tt.days, data, 55*time.Minute)
+			assert.NoError(t, err)
+
+			mock.ExpectGet(tt.key).SetVal(string(jsonData))
+			var result map[string]interface{}
+			err = cache.GetHistoricalData(context.Background(), "AAPL", tt.days, &result)
+			assert.NoError(t, err)
+			assert.Equal(t, data, result)
+
+			err = mock.ExpectationsWereMet()
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestHistoricalDataKey_NoCollisions(t *testing.T) {
+	seen := make(map[string]int)
+	for days := 1; days <= 400; days++ {
+		key := HistoricalDataKey("AAPL", days)
+		if other, ok := seen[key]; ok {
+			t.Fatalf("days %d and %d share key %q", other, days, key)
+		}
+		seen[key] = days
+	}
+}
+
 // Benchmark test for cache performance
 func BenchmarkRedisCache_SetGetStockData(b *testing.B) {
 	redis, mock := redismock.NewClientMock()
@@ -290,7 +594,6 @@ func BenchmarkRedisCache_SetGetStockData(b *testing.B) {
 
 	cache := &RedisCache{
 		client: redis,
-		ctx:    redis.Context(),
 	}
 
 	testData := map[string]interface{}{
@@ -308,8 +611,33 @@ func BenchmarkRedisCache_SetGetStockData(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		cache.SetStockData("bench-key", testData, time.Minute)
+		cache.SetStockData(context.Background(), "bench-key", testData, time.Minute)
 		var result map[string]interface{}
-		cache.GetStockData("bench-key", &result)
+		cache.GetStockData(context.Background(), "bench-key", &result)
 	}
-}
\ No newline at end of file
+}
+func TestRedisCache_TakeToken_BucketSurvivesInvalidation(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	cache, err := NewRedisCache("redis://"+server.Addr(), "si:")
+	require.NoError(t, err)
+	defer cache.Close()
+
+	now := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		taken, _, err := cache.TakeToken(context.Background(), "203.0.113.7", 3, 1, now)
+		require.NoError(t, err)
+		assert.True(t, taken)
+	}
+	require.NoError(t, cache.SetStocksList(context.Background(), []models.Stock{}, time.Hour))
+
+	// A sync invalidating the cache must not hand an emptied bucket its tokens back
+	require.NoError(t, cache.InvalidateAll(context.Background()))
+	require.NoError(t, cache.InvalidateStock(context.Background(), "203"))
+
+	assert.False(t, server.Exists("si:stocks:all"))
+	taken, tokens, err := cache.TakeToken(context.Background(), "203.0.113.7", 3, 1, now)
+	require.NoError(t, err)
+	assert.False(t, taken)
+	assert.Zero(t, tokens)
+}
@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// keyClass groups cache keys by what they hold, for the statistics
+type keyClass int
+
+const (
+	classStocksList keyClass = iota
+	classSector
+	classOverview
+	classHistorical
+	classOther
+	keyClassCount
+)
+
+// keyClassNames name the key classes in Stats
+var keyClassNames = [keyClassCount]string{
+	classStocksList: "stocks_list",
+	classSector:     "sector",
+	classOverview:   "overview",
+	classHistorical: "historical",
+	classOther:      "other",
+}
+
+// classify returns the class of key; a stale copy counts as its key
+func classify(key string) keyClass {
+	key = strings.TrimPrefix(key, staleKey(""))
+	switch {
+	case key == StocksListKey:
+		return classStocksList
+	case strings.HasPrefix(key, SectorDataKey("")):
+		return classSector
+	case key == MarketOverviewKey:
+		return classOverview
+	case strings.HasPrefix(key, "historical:"):
+		return classHistorical
+	default:
+		return classOther
+	}
+}
+
+// counters are the operations counted for one key class
+type counters struct {
+	hits, misses, sets, errors atomic.Int64
+}
+
+// OperationCounts are the reads and writes of one class of keys since the
+// server started or the counts were last reset
+type OperationCounts struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	Sets    int64   `json:"sets"`
+	Errors  int64   `json:"errors"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// Stats describes what the cache holds and how well it serves reads
+type Stats struct {
+	Classes map[string]OperationCounts `json:"classes"`
+	Total   OperationCounts            `json:"total"`
+	// Keys counts the keys under our namespace
+	Keys int64 `json:"keys"`
+	// MemoryUsedBytes is the memory Redis reports using for every namespace;
+	// nil when Redis does not report it
+	MemoryUsedBytes *int64    `json:"memory_used_bytes"`
+	ResetAt         time.Time `json:"reset_at"`
+}
+
+// recordRead counts a read of key, which found it when hit
+func (r *RedisCache) recordRead(key string, hit bool, err error) {
+	c := &r.stats[classify(key)]
+	switch {
+	case hit:
+		c.hits.Add(1)
+	case err == nil:
+		c.misses.Add(1)
+	default:
+		c.errors.Add(1)
+	}
+}
+
+// recordWrite counts a write of key
+func (r *RedisCache) recordWrite(key string, err error) {
+	c := &r.stats[classify(key)]
+	if err != nil {
+		c.errors.Add(1)
+		return
+	}
+	c.sets.Add(1)
+}
+
+// Stats returns the operations counted per key class, with the number of
+// keys under our namespace and the memory Redis uses
+func (r *RedisCache) Stats(ctx context.Context) (Stats, error) {
+	stats := Stats{Classes: make(map[string]OperationCounts, keyClassCount)}
+	for class, name := range keyClassNames {
+		c := &r.stats[class]
+		counts := OperationCounts{Hits: c.hits.Load(), Misses: c.misses.Load(), Sets: c.sets.Load(), Errors: c.errors.Load()}
+		counts.HitRate = hitRate(counts)
+		stats.Classes[name] = counts
+		stats.Total.Hits += counts.Hits
+		stats.Total.Misses += counts.Misses
+		stats.Total.Sets += counts.Sets
+		stats.Total.Errors += counts.Errors
+	}
+	stats.Total.HitRate = hitRate(stats.Total)
+	if resetAt := r.statsResetAt.Load(); resetAt != nil {
+		stats.ResetAt = *resetAt
+	}
+
+	var cursor uint64
+	for {
+		var keys []string
+		err := r.withTimeout(ctx, "", func(ctx context.Context) (err error) {
+			keys, cursor, err = r.client.Scan(ctx, cursor, r.key("*"), invalidateBatchSize).Result()
+			return err
+		})
+		if err != nil {
+			return Stats{}, err
+		}
+		stats.Keys += int64(len(keys))
+		if cursor == 0 {
+			break
+		}
+	}
+
+	// Not every Redis, nor miniredis, reports its memory
+	var info string
+	err := r.withTimeout(ctx, "", func(ctx context.Context) (err error) {
+		info, err = r.client.Info(ctx, "memory").Result()
+		return err
+	})
+	if err == nil {
+		stats.MemoryUsedBytes = usedMemory(info)
+	}
+	return stats, nil
+}
+
+// ResetStats zeroes the operation counts
+func (r *RedisCache) ResetStats() {
+	for class := range r.stats {
+		c := &r.stats[class]
+		c.hits.Store(0)
+		c.misses.Store(0)
+		c.sets.Store(0)
+		c.errors.Store(0)
+	}
+	now := time.Now()
+	r.statsResetAt.Store(&now)
+}
+
+// hitRate is the share of reads that were hits, 0 before any read
+func hitRate(counts OperationCounts) float64 {
+	reads := counts.Hits + counts.Misses
+	if reads == 0 {
+		return 0
+	}
+	return float64(counts.Hits) / float64(reads)
+}
+
+// usedMemory reads used_memory from an INFO memory reply
+func usedMemory(info string) *int64 {
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "used_memory:"); ok {
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				return &n
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+// Package compression gzips HTTP responses for clients that accept it
+package compression
+
+import (
+	"bufio"
+	"compress/gzip"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultMinSize is the response size, in bytes, below which gzip costs more than it saves
+const DefaultMinSize = 1024
+
+var writers = sync.Pool{
+	New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	},
+}
+
+// Gzip compresses responses of at least minSize bytes for requests that
+// accept gzip. Smaller responses, responses the handler already encoded,
+// event streams and WebSocket upgrades pass through as they are. Every
+// response it may compress is sent with Vary: Accept-Encoding.
+func Gzip(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// A hijacked connection must see the server's own writer
+		if c.GetHeader("Upgrade") != "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		// Added rather than set, so the CORS Vary: Origin is kept
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		writer := &gzipWriter{ResponseWriter: c.Writer, minSize: minSize}
+		c.Writer = writer
+		defer func() {
+			c.Writer = writer.ResponseWriter
+			if recovered := recover(); recovered != nil {
+				// Drop the partial body so the recovery handler can write its own
+				writer.discard()
+				panic(recovered)
+			}
+			writer.close()
+		}()
+
+		c.Next()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.TrimSpace(coding)
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipWriter holds the body back until it reaches minSize bytes, then
+// decides whether to compress it
+type gzipWriter struct {
+	gin.ResponseWriter
+	minSize int
+	buffer  []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buffer = append(w.buffer, data...)
+	if len(w.buffer) >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends what is buffered; a response flushed before reaching minSize
+// is streaming and is sent uncompressed from then on
+func (w *gzipWriter) Flush() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *gzipWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.Hijack()
+}
+
+// decide writes the buffered body, compressed when compress is true and the
+// response is one that should be
+func (w *gzipWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	if compress && header.Get("Content-Encoding") == "" &&
+		!strings.HasPrefix(header.Get("Content-Type"), "text/event-stream") {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.gz = writers.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+
+	buffered := w.buffer
+	w.buffer = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+	if w.gz != nil {
+		_, err := w.gz.Write(buffered)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buffered)
+	return err
+}
+
+// close sends a body that stayed under minSize as it is, or ends the gzip stream
+func (w *gzipWriter) close() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		writers.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+// discard drops anything not yet sent
+func (w *gzipWriter) discard() {
+	w.buffer = nil
+	if w.gz != nil {
+		w.gz.Reset(nil)
+		writers.Put(w.gz)
+		w.gz = nil
+	}
+}
@@ -0,0 +1,129 @@
+package compression
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newGzipRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.Recovery(), Gzip(100))
+	router.GET("/large", func(c *gin.Context) { c.String(http.StatusOK, strings.Repeat("stock ", 100)) })
+	router.GET("/small", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	router.GET("/encoded", func(c *gin.Context) {
+		c.Header("Content-Encoding", "br")
+		c.String(http.StatusOK, strings.Repeat("x", 200))
+	})
+	router.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			c.Writer.WriteString("data: tick\n\n")
+			c.Writer.Flush()
+		}
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		// Still under the minimum, so held back rather than sent
+		c.Writer.WriteString("partial")
+		panic("boom")
+	})
+	return router
+}
+
+func get(router *gin.Engine, path, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func gunzip(t *testing.T, body io.Reader) string {
+	reader, err := gzip.NewReader(body)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestGzip_CompressesLargeResponses(t *testing.T) {
+	router := newGzipRouter()
+
+	plain := get(router, "/large", "")
+	compressed := get(router, "/large", "deflate, gzip;q=0.8")
+
+	assert.Empty(t, plain.Header().Get("Content-Encoding"))
+	assert.Equal(t, "gzip", compressed.Header().Get("Content-Encoding"))
+	assert.Less(t, compressed.Body.Len(), plain.Body.Len())
+	assert.Equal(t, plain.Body.String(), gunzip(t, compressed.Body))
+
+	for _, w := range []*httptest.ResponseRecorder{plain, compressed} {
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	}
+}
+
+func TestGzip_PassesThrough(t *testing.T) {
+	router := newGzipRouter()
+
+	small := get(router, "/small", "gzip")
+	assert.Empty(t, small.Header().Get("Content-Encoding"))
+	assert.Equal(t, "ok", small.Body.String())
+
+	encoded := get(router, "/encoded", "gzip")
+	assert.Equal(t, "br", encoded.Header().Get("Content-Encoding"), "already encoded by the handler")
+	assert.Equal(t, strings.Repeat("x", 200), encoded.Body.String())
+
+	refused := get(router, "/large", "gzip;q=0, identity")
+	assert.Empty(t, refused.Header().Get("Content-Encoding"))
+
+	stream := get(router, "/stream", "gzip")
+	assert.Empty(t, stream.Header().Get("Content-Encoding"), "flushed event streams are sent as written")
+	assert.Equal(t, strings.Repeat("data: tick\n\n", 3), stream.Body.String())
+}
+
+func TestGzip_SkipsUpgradeRequests(t *testing.T) {
+	router := newGzipRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/large", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Empty(t, w.Header().Get("Vary"))
+}
+
+func TestGzip_PanicLeavesResponseToRecovery(t *testing.T) {
+	w := get(newGzipRouter(), "/panic", "gzip")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Empty(t, w.Body.String(), "the held-back partial body is dropped")
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":                       false,
+		"gzip":                   true,
+		"deflate, gzip":          true,
+		"gzip;q=0.5":             true,
+		"gzip;q=0":               false,
+		"*":                      true,
+		"br, identity":           false,
+		"gzip ; q=1.0, identity": true,
+	} {
+		assert.Equal(t, want, acceptsGzip(header), header)
+	}
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-intelligence-backend/internal/compression"
+	"stock-intelligence-backend/internal/ratelimit"
+	"stock-intelligence-backend/internal/responsecache"
+)
+
+// DefaultAllowedOrigins are the local frontend dev servers, used when CORS_ALLOWED_ORIGINS is unset
+var DefaultAllowedOrigins = []string{"http://localhost:3000", "http://localhost:3001"}
+
+// HTTPConfig controls which browsers and proxies the HTTP server trusts
+type HTTPConfig struct {
+	// AllowedOrigins may call the API from a browser (CORS_ALLOWED_ORIGINS); "*" allows any origin
+	AllowedOrigins []string
+	// AllowCredentials lets browsers send cookies and auth headers cross-origin (CORS_ALLOW_CREDENTIALS)
+	AllowCredentials bool
+	// TrustedProxies are the IPs and CIDRs whose X-Forwarded-For is believed (TRUSTED_PROXIES); none when empty
+	TrustedProxies []string
+	// GzipMinSize is the smallest response, in bytes, gzipped for clients that accept it (GZIP_MIN_SIZE)
+	GzipMinSize int
+	// RateLimitPerMinute is the /api/v1 requests each client IP may make a minute (RATE_LIMIT_PER_MINUTE); zero disables the limit
+	RateLimitPerMinute int
+	// ResponseCacheTTL is how long GET responses of the stock and market routes are served from Redis
+	// unless a sync invalidates them first (RESPONSE_CACHE_TTL); zero disables response caching
+	ResponseCacheTTL time.Duration
+}
+
+// LoadHTTPConfig reads the HTTP config from the environment. Unlike the
+// database settings, invalid values are errors rather than defaults, since a
+// typo here silently locks out the frontend or opens the API to any site.
+func LoadHTTPConfig() (*HTTPConfig, error) {
+	config := &HTTPConfig{
+		AllowedOrigins:     DefaultAllowedOrigins,
+		AllowCredentials:   true,
+		GzipMinSize:        compression.DefaultMinSize,
+		RateLimitPerMinute: ratelimit.DefaultPerMinute,
+		ResponseCacheTTL:   responsecache.DefaultTTL,
+	}
+
+	if value := os.Getenv("CORS_ALLOWED_ORIGINS"); value != "" {
+		origins, err := ParseAllowedOrigins(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CORS_ALLOWED_ORIGINS: %w", err)
+		}
+		config.AllowedOrigins = origins
+	}
+
+	if value := os.Getenv("CORS_ALLOW_CREDENTIALS"); value != "" {
+		allow, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CORS_ALLOW_CREDENTIALS %q: must be true or false", value)
+		}
+		config.AllowCredentials = allow
+	}
+
+	if value := os.Getenv("GZIP_MIN_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid GZIP_MIN_SIZE %q: must be a number of bytes", value)
+		}
+		config.GzipMinSize = size
+	}
+
+	if value := os.Getenv("RATE_LIMIT_PER_MINUTE"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_PER_MINUTE %q: must be a number of requests, or 0 for no limit", value)
+		}
+		config.RateLimitPerMinute = limit
+	}
+
+	if value := os.Getenv("RESPONSE_CACHE_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("invalid RESPONSE_CACHE_TTL %q: must be a duration such as 5m, or 0 to disable", value)
+		}
+		config.ResponseCacheTTL = ttl
+	}
+
+	proxies, err := ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	config.TrustedProxies = proxies
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate rejects combinations that are unsafe or that the CORS middleware cannot serve
+func (c *HTTPConfig) Validate() error {
+	if len(c.AllowedOrigins) == 0 {
+		return fmt.Errorf("at least one CORS origin is required")
+	}
+	if c.AllowsAnyOrigin() && c.AllowCredentials {
+		return fmt.Errorf("CORS_ALLOWED_ORIGINS=* cannot be combined with CORS_ALLOW_CREDENTIALS=true: any site could make authenticated requests")
+	}
+	return nil
+}
+
+// AllowsAnyOrigin reports whether the wildcard origin is configured
+func (c *HTTPConfig) AllowsAnyOrigin() bool {
+	return len(c.AllowedOrigins) == 1 && c.AllowedOrigins[0] == "*"
+}
+
+// ParseAllowedOrigins parses a comma-separated list of origins such as
+// "https://app.example.com,http://localhost:3000". Each must be a scheme and
+// host with an optional port and nothing else; "*" must stand alone.
+func ParseAllowedOrigins(value string) ([]string, error) {
+	entries := splitList(value)
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no origins given")
+	}
+
+	origins := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry == "*" {
+			if len(entries) > 1 {
+				return nil, fmt.Errorf("* must be the only origin")
+			}
+			return []string{"*"}, nil
+		}
+
+		origin, err := url.Parse(entry)
+		if err != nil || (origin.Scheme != "http" && origin.Scheme != "https") || origin.Host == "" {
+			return nil, fmt.Errorf("%q is not an origin like https://app.example.com", entry)
+		}
+		if (origin.Path != "" && origin.Path != "/") || origin.RawQuery != "" || origin.Fragment != "" || origin.User != nil {
+			return nil, fmt.Errorf("%q must not have a path, query or credentials", entry)
+		}
+		origins = append(origins, origin.Scheme+"://"+origin.Host)
+	}
+	return origins, nil
+}
+
+// ParseTrustedProxies parses a comma-separated list of IP addresses and CIDR
+// ranges. An empty value trusts no proxies.
+func ParseTrustedProxies(value string) ([]string, error) {
+	var proxies []string
+	for _, entry := range splitList(value) {
+		if strings.Contains(entry, "/") {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return nil, fmt.Errorf("%q is not a valid CIDR range", entry)
+			}
+		} else if net.ParseIP(entry) == nil {
+			return nil, fmt.Errorf("%q is not a valid IP address", entry)
+		}
+		proxies = append(proxies, entry)
+	}
+	return proxies, nil
+}
+
+// splitList splits a comma-separated value, dropping blanks around and between entries
+func splitList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -1,16 +1,24 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/url"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
 )
 
+// ErrInvalidDatabaseURL is returned for a DATABASE_URL that cannot be used to connect
+var ErrInvalidDatabaseURL = errors.New("invalid DATABASE_URL")
+
 type Config struct {
 	Host     string
 	Port     int
@@ -18,9 +26,23 @@ type Config struct {
 	Password string
 	DBName   string
 	SSLMode  string
+	// Params holds any other connection parameters given in DATABASE_URL's query string
+	Params map[string]string
+
+	// ReplicaURL is an optional read replica connection string (DATABASE_REPLICA_URL)
+	ReplicaURL string
+	// ReplicaStickyWindow keeps reads of a just-written symbol on the primary (DATABASE_REPLICA_STICKY_WINDOW)
+	ReplicaStickyWindow time.Duration
+
+	// Pool sizes the primary's and the replica's connection pools and bounds the startup retries
+	Pool PoolConfig
 }
 
-func LoadConfig() *Config {
+// LoadConfig reads the database settings from DATABASE_URL when it is set, and
+// from the discrete DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, DB_NAME and
+// DB_SSLMODE variables otherwise, so every command connects to the same database
+// however it is configured. A malformed DATABASE_URL is an ErrInvalidDatabaseURL.
+func LoadConfig() (*Config, error) {
 	port := 5432
 	if portStr := os.Getenv("DB_PORT"); portStr != "" {
 		if p, err := strconv.Atoi(portStr); err == nil {
@@ -28,14 +50,86 @@ func LoadConfig() *Config {
 		}
 	}
 
-	return &Config{
+	stickyWindow := DefaultReplicaStickyWindow
+	if windowStr := os.Getenv("DATABASE_REPLICA_STICKY_WINDOW"); windowStr != "" {
+		if w, err := time.ParseDuration(windowStr); err == nil && w >= 0 {
+			stickyWindow = w
+		}
+	}
+
+	config := &Config{
 		Host:     getEnvOrDefault("DB_HOST", "localhost"),
 		Port:     port,
 		User:     getEnvOrDefault("DB_USER", "postgres"),
 		Password: getEnvOrDefault("DB_PASSWORD", "postgres"),
 		DBName:   getEnvOrDefault("DB_NAME", "stock_intelligence"),
 		SSLMode:  getEnvOrDefault("DB_SSLMODE", "disable"),
+
+		ReplicaURL:          os.Getenv("DATABASE_REPLICA_URL"),
+		ReplicaStickyWindow: stickyWindow,
+
+		Pool: loadPoolConfig(),
+	}
+
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		if err := config.applyURL(databaseURL); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// applyURL replaces the connection settings with those of a postgres:// or
+// postgresql:// URL. Percent-encoded user names and passwords are decoded; a
+// missing port is 5432, a missing sslmode is disable and a missing database
+// name is stock_intelligence, as with the discrete variables.
+func (c *Config) applyURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		// The parse error quotes the URL, password included
+		return fmt.Errorf("%w: cannot be parsed", ErrInvalidDatabaseURL)
+	}
+	if parsed.Scheme != "postgres" && parsed.Scheme != "postgresql" {
+		return fmt.Errorf("%w: scheme must be postgres or postgresql, got %q", ErrInvalidDatabaseURL, parsed.Scheme)
+	}
+	if parsed.Hostname() == "" {
+		return fmt.Errorf("%w: no host", ErrInvalidDatabaseURL)
+	}
+
+	port := 5432
+	if portStr := parsed.Port(); portStr != "" {
+		port, err = strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("%w: invalid port %q", ErrInvalidDatabaseURL, portStr)
+		}
 	}
+
+	query, err := url.ParseQuery(parsed.RawQuery)
+	if err != nil {
+		return fmt.Errorf("%w: malformed query parameters", ErrInvalidDatabaseURL)
+	}
+
+	c.Host = parsed.Hostname()
+	c.Port = port
+	c.User = parsed.User.Username()
+	c.Password, _ = parsed.User.Password()
+	c.DBName = strings.TrimPrefix(parsed.Path, "/")
+	if c.DBName == "" {
+		c.DBName = "stock_intelligence"
+	}
+	c.SSLMode = "disable"
+	c.Params = nil
+	for key, values := range query {
+		if key == "sslmode" {
+			c.SSLMode = values[0]
+			continue
+		}
+		if c.Params == nil {
+			c.Params = make(map[string]string)
+		}
+		c.Params[key] = values[0]
+	}
+	return nil
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
@@ -45,31 +139,61 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// ConnectionString returns the settings as lib/pq key/value pairs, quoting
+// values such as passwords that contain spaces or quotes
 func (c *Config) ConnectionString() string {
-	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		c.Host, c.Port, c.User, c.Password, c.DBName, c.SSLMode)
+	pairs := []string{
+		"host=" + quoteConnValue(c.Host),
+		"port=" + strconv.Itoa(c.Port),
+	}
+	if c.User != "" {
+		pairs = append(pairs, "user="+quoteConnValue(c.User))
+	}
+	if c.Password != "" {
+		pairs = append(pairs, "password="+quoteConnValue(c.Password))
+	}
+	pairs = append(pairs, "dbname="+quoteConnValue(c.DBName), "sslmode="+quoteConnValue(c.SSLMode))
+
+	keys := make([]string, 0, len(c.Params))
+	for key := range c.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+quoteConnValue(c.Params[key]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// quoteConnValue single-quotes a connection string value when lib/pq would
+// otherwise misread it
+func quoteConnValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n'\\") {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
 }
 
 func Connect() (*sql.DB, error) {
-	config := LoadConfig()
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
 	
 	db, err := sql.Open("postgres", config.ConnectionString())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
 
-	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	config.Pool.Apply(db)
 
-	// Test the connection
-	if err := db.Ping(); err != nil {
+	// The database may still be starting, e.g. alongside this process in a container
+	if err := config.Pool.pingWithRetry(context.Background(), db.PingContext); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Printf("Successfully connected to database: %s", config.DBName)
+	slog.Info("Successfully connected to database", "database", config.DBName)
 	return db, nil
 }
 
@@ -82,10 +206,10 @@ func InitializeDatabase() (*sql.DB, error) {
 	// Run migrations
 	migrator := NewMigrator(db, "./migrations")
 	if err := migrator.Up(); err != nil {
-		log.Printf("Migration failed: %v", err)
+		slog.Error("Migration failed", "error", err)
 		return db, err
 	}
 
-	log.Println("Database initialized successfully")
+	slog.Info("Database initialized successfully")
 	return db, nil
 }
\ No newline at end of file
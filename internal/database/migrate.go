@@ -2,8 +2,9 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
@@ -13,6 +14,9 @@ import (
 	_ "github.com/lib/pq"
 )
 
+// ErrDirtyDatabase is returned when a migration was started but never committed
+var ErrDirtyDatabase = errors.New("database is dirty")
+
 type Migration struct {
 	Version int
 	Name    string
@@ -38,6 +42,7 @@ func (m *Migrator) ensureMigrationsTable() error {
 			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			name VARCHAR(255) NOT NULL
 		);
+		ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS dirty BOOLEAN NOT NULL DEFAULT FALSE;
 	`
 	_, err := m.db.Exec(query)
 	return err
@@ -63,7 +68,70 @@ func (m *Migrator) getAppliedMigrations() (map[int]bool, error) {
 	return applied, rows.Err()
 }
 
+// dirtyMigration returns the migration left dirty by a failed or interrupted run, if any
+func (m *Migrator) dirtyMigration() (version int, name string, dirty bool, err error) {
+	err = m.db.QueryRow("SELECT version, name FROM schema_migrations WHERE dirty ORDER BY version LIMIT 1").Scan(&version, &name)
+	if err == sql.ErrNoRows {
+		return 0, "", false, nil
+	}
+	if err != nil {
+		return 0, "", false, err
+	}
+	return version, name, true, nil
+}
+
+// checkClean refuses to continue while a migration is marked dirty
+func (m *Migrator) checkClean() error {
+	version, name, dirty, err := m.dirtyMigration()
+	if err != nil {
+		return fmt.Errorf("failed to check for dirty migrations: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("%w: migration %d (%s) did not complete; repair the schema by hand, then rerun with -force-clean",
+			ErrDirtyDatabase, version, name)
+	}
+	return nil
+}
+
+// ForceClean clears the dirty flag after a manual repair. The dirty migration
+// stays recorded as applied; delete its schema_migrations row instead to have
+// Up run it again.
+func (m *Migrator) ForceClean() error {
+	if err := m.ensureMigrationsTable(); err != nil {
+		return fmt.Errorf("failed to ensure migrations table: %w", err)
+	}
+
+	result, err := m.db.Exec("UPDATE schema_migrations SET dirty = FALSE WHERE dirty")
+	if err != nil {
+		return fmt.Errorf("failed to clear dirty flag: %w", err)
+	}
+	cleared, _ := result.RowsAffected()
+	slog.Info("Cleared dirty migration flag", "migrations", cleared)
+	return nil
+}
+
+// downSuffix ends the file that reverts a migration: 013_watchlists.down.sql reverts 013_watchlists.sql
+const downSuffix = ".down.sql"
+
 func (m *Migrator) loadMigrations() ([]Migration, error) {
+	return m.loadMigrationFiles(false)
+}
+
+// loadDownMigrations returns the down migrations by version
+func (m *Migrator) loadDownMigrations() (map[int]Migration, error) {
+	migrations, err := m.loadMigrationFiles(true)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+	return byVersion, nil
+}
+
+// loadMigrationFiles reads the up migrations, or with down the down migrations, in version order
+func (m *Migrator) loadMigrationFiles(down bool) ([]Migration, error) {
 	var migrations []Migration
 
 	files, err := filepath.Glob(filepath.Join(m.migrationsDir, "*.sql"))
@@ -74,8 +142,7 @@ func (m *Migrator) loadMigrations() ([]Migration, error) {
 	for _, file := range files {
 		filename := filepath.Base(file)
 		
-		// Skip down migrations for now
-		if strings.Contains(filename, ".down.sql") {
+		if strings.HasSuffix(filename, downSuffix) != down {
 			continue
 		}
 		
@@ -87,11 +154,11 @@ func (m *Migrator) loadMigrations() ([]Migration, error) {
 
 		version, err := strconv.Atoi(parts[0])
 		if err != nil {
-			log.Printf("Skipping migration file %s: invalid version number", filename)
+			slog.Warn("Skipping migration file: invalid version number", "file", filename)
 			continue
 		}
 
-		name := strings.TrimSuffix(strings.Join(parts[1:], "_"), ".sql")
+		name := strings.TrimSuffix(strings.TrimSuffix(strings.Join(parts[1:], "_"), downSuffix), ".sql")
 
 		content, err := os.ReadFile(file)
 		if err != nil {
@@ -113,11 +180,24 @@ func (m *Migrator) loadMigrations() ([]Migration, error) {
 	return migrations, nil
 }
 
+// Up applies every pending migration
 func (m *Migrator) Up() error {
+	return m.UpTo(0)
+}
+
+// UpTo applies pending migrations up to and including target, or all of them
+// when target is 0. Each migration is marked dirty before it runs and clean in
+// the transaction that applies it, so a crash leaves it marked dirty and later
+// runs refuse to continue until ForceClean is called.
+func (m *Migrator) UpTo(target int) error {
 	if err := m.ensureMigrationsTable(); err != nil {
 		return fmt.Errorf("failed to ensure migrations table: %w", err)
 	}
 
+	if err := m.checkClean(); err != nil {
+		return err
+	}
+
 	applied, err := m.getAppliedMigrations()
 	if err != nil {
 		return fmt.Errorf("failed to get applied migrations: %w", err)
@@ -128,35 +208,130 @@ func (m *Migrator) Up() error {
 		return fmt.Errorf("failed to load migrations: %w", err)
 	}
 
+	if target > 0 {
+		found := false
+		for _, migration := range migrations {
+			found = found || migration.Version == target
+		}
+		if !found {
+			return fmt.Errorf("no migration with version %d in %s", target, m.migrationsDir)
+		}
+	}
+
 	for _, migration := range migrations {
+		if target > 0 && migration.Version > target {
+			break
+		}
 		if applied[migration.Version] {
-			log.Printf("Migration %d (%s) already applied, skipping", migration.Version, migration.Name)
+			slog.Debug("Migration already applied, skipping", "version", migration.Version, "name", migration.Name)
 			continue
 		}
 
-		log.Printf("Applying migration %d: %s", migration.Version, migration.Name)
+		slog.Info("Applying migration", "version", migration.Version, "name", migration.Name)
+
+		// Recorded outside the transaction so the mark survives a failure
+		if _, err := m.db.Exec("INSERT INTO schema_migrations (version, name, dirty) VALUES ($1, $2, TRUE)",
+			migration.Version, migration.Name); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+		}
 		
 		tx, err := m.db.Begin()
 		if err != nil {
-			return fmt.Errorf("failed to begin transaction for migration %d: %w", migration.Version, err)
+			return fmt.Errorf("failed to begin transaction for migration %d, now marked dirty: %w", migration.Version, err)
 		}
 
 		if _, err := tx.Exec(migration.SQL); err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to execute migration %d: %w", migration.Version, err)
+			return fmt.Errorf("failed to execute migration %d, now marked dirty: %w", migration.Version, err)
 		}
 
-		if _, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", 
-			migration.Version, migration.Name); err != nil {
+		if _, err := tx.Exec("UPDATE schema_migrations SET dirty = FALSE, applied_at = CURRENT_TIMESTAMP WHERE version = $1",
+			migration.Version); err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+			return fmt.Errorf("failed to record migration %d, now marked dirty: %w", migration.Version, err)
 		}
 
 		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit migration %d: %w", migration.Version, err)
+			return fmt.Errorf("failed to commit migration %d, now marked dirty: %w", migration.Version, err)
 		}
 
-		log.Printf("Successfully applied migration %d: %s", migration.Version, migration.Name)
+		slog.Info("Successfully applied migration", "version", migration.Version, "name", migration.Name)
+	}
+
+	return nil
+}
+
+// Down reverts the steps most recently applied migrations, newest first. Each
+// runs its .down.sql file and removes its schema_migrations row in one
+// transaction. Every down file is checked for before anything is reverted.
+func (m *Migrator) Down(steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("steps must be at least 1, got %d", steps)
+	}
+
+	if err := m.ensureMigrationsTable(); err != nil {
+		return fmt.Errorf("failed to ensure migrations table: %w", err)
+	}
+
+	if err := m.checkClean(); err != nil {
+		return err
+	}
+
+	applied, err := m.getAppliedMigrations()
+	if err != nil {
+		return fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	if steps > len(applied) {
+		return fmt.Errorf("cannot roll back %d migrations: only %d applied", steps, len(applied))
+	}
+
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	versions = versions[:steps]
+
+	downMigrations, err := m.loadDownMigrations()
+	if err != nil {
+		return fmt.Errorf("failed to load down migrations: %w", err)
+	}
+
+	var missing []string
+	for _, version := range versions {
+		if _, ok := downMigrations[version]; !ok {
+			missing = append(missing, fmt.Sprintf("%03d", version))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("no down migration for applied version %s in %s; nothing was rolled back",
+			strings.Join(missing, ", "), m.migrationsDir)
+	}
+
+	for _, version := range versions {
+		migration := downMigrations[version]
+		slog.Info("Rolling back migration", "version", migration.Version, "name", migration.Name)
+
+		tx, err := m.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction for rollback of %d: %w", migration.Version, err)
+		}
+
+		if _, err := tx.Exec(migration.SQL); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to roll back migration %d: %w", migration.Version, err)
+		}
+
+		if _, err := tx.Exec("DELETE FROM schema_migrations WHERE version = $1", migration.Version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to unrecord migration %d: %w", migration.Version, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit rollback of %d: %w", migration.Version, err)
+		}
+
+		slog.Info("Successfully rolled back migration", "version", migration.Version, "name", migration.Name)
 	}
 
 	return nil
@@ -172,20 +347,29 @@ func (m *Migrator) Status() error {
 		return fmt.Errorf("failed to get applied migrations: %w", err)
 	}
 
+	dirtyVersion, _, dirty, err := m.dirtyMigration()
+	if err != nil {
+		return fmt.Errorf("failed to check for dirty migrations: %w", err)
+	}
+
 	migrations, err := m.loadMigrations()
 	if err != nil {
 		return fmt.Errorf("failed to load migrations: %w", err)
 	}
 
-	log.Println("Migration Status:")
-	log.Println("================")
-
 	for _, migration := range migrations {
 		status := "PENDING"
-		if applied[migration.Version] {
+		if dirty && migration.Version == dirtyVersion {
+			status = "DIRTY"
+		} else if applied[migration.Version] {
 			status = "APPLIED"
 		}
-		log.Printf("[%s] %03d: %s", status, migration.Version, migration.Name)
+		slog.Info("Migration status", "status", status, "version", migration.Version, "name", migration.Name)
+	}
+
+	if dirty {
+		slog.Warn("Database is dirty: migrations are blocked until it is repaired and cleaned with -force-clean",
+			"version", dirtyVersion)
 	}
 
 	return nil
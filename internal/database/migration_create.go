@@ -0,0 +1,71 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// migrationNamePattern accepts snake_case names such as add_stock_notes
+var migrationNamePattern = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+// CreateMigration writes up and down templates for name in dir, numbered one
+// past the highest version already there, and returns their paths. It refuses
+// a name that is already taken and never overwrites an existing file.
+func CreateMigration(dir, name string) (upPath, downPath string, err error) {
+	if !migrationNamePattern.MatchString(name) {
+		return "", "", fmt.Errorf("invalid migration name %q: use lowercase snake_case such as add_stock_notes", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	highest := 0
+	for _, entry := range entries {
+		filename := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(filename, ".sql") {
+			continue
+		}
+		prefix, rest, found := strings.Cut(filename, "_")
+		version, err := strconv.Atoi(prefix)
+		if !found || err != nil {
+			continue
+		}
+		highest = max(highest, version)
+
+		if strings.TrimSuffix(strings.TrimSuffix(rest, downSuffix), ".sql") == name {
+			return "", "", fmt.Errorf("migration %q already exists as %s", name, filename)
+		}
+	}
+
+	base := fmt.Sprintf("%03d_%s", highest+1, name)
+	upPath = filepath.Join(dir, base+".sql")
+	downPath = filepath.Join(dir, base+downSuffix)
+
+	if err := writeNewFile(upPath, fmt.Sprintf("-- Migration: %s\n-- Description: \n\n", base)); err != nil {
+		return "", "", err
+	}
+	if err := writeNewFile(downPath, fmt.Sprintf("-- Migration: %s (down)\n-- Description: Revert %s\n\n", base, base)); err != nil {
+		os.Remove(upPath)
+		return "", "", err
+	}
+	return upPath, downPath, nil
+}
+
+// writeNewFile creates path with content, failing if it already exists
+func writeNewFile(path, content string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateMigration_NumbersAfterHighestVersion(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"001_first.sql":       "",
+		"007_second.sql":      "",
+		"007_second.down.sql": "",
+		"README.md":           "",
+	})
+
+	upPath, downPath, err := CreateMigration(dir, "add_stock_notes")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "008_add_stock_notes.sql"), upPath)
+	assert.Equal(t, filepath.Join(dir, "008_add_stock_notes.down.sql"), downPath)
+
+	up, err := os.ReadFile(upPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(up), "-- Migration: 008_add_stock_notes\n")
+
+	down, err := os.ReadFile(downPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(down), "-- Migration: 008_add_stock_notes (down)\n")
+
+	// The new files count towards the next version
+	upPath, _, err = CreateMigration(dir, "add_stock_tags")
+	require.NoError(t, err)
+	assert.Equal(t, "009_add_stock_tags.sql", filepath.Base(upPath))
+}
+
+func TestCreateMigration_ZeroPadsVersion(t *testing.T) {
+	upPath, downPath, err := CreateMigration(t.TempDir(), "initial")
+	require.NoError(t, err)
+	assert.Equal(t, "001_initial.sql", filepath.Base(upPath))
+	assert.Equal(t, "001_initial.down.sql", filepath.Base(downPath))
+
+	dir := writeMigrations(t, map[string]string{"999_last.sql": ""})
+	upPath, _, err = CreateMigration(dir, "next")
+	require.NoError(t, err)
+	assert.Equal(t, "1000_next.sql", filepath.Base(upPath))
+}
+
+func TestCreateMigration_RejectsInvalidNames(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"", "add stock notes", "AddStockNotes", "add-stock-notes", "_notes", "notes_", "1_notes"} {
+		_, _, err := CreateMigration(dir, name)
+		assert.Error(t, err, name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestCreateMigration_RefusesCollisions(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"001_watchlists.sql":      "CREATE TABLE watchlists (id INT);",
+		"001_watchlists.down.sql": "DROP TABLE watchlists;",
+	})
+
+	_, _, err := CreateMigration(dir, "watchlists")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already exists as 001_watchlists")
+
+	// Something already at the next down path is not overwritten, and the up
+	// file created before it is removed again
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "002_notes.down.sql"), 0o755))
+
+	_, _, err = CreateMigration(dir, "notes")
+	require.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "002_notes.sql"))
+
+	up, err := os.ReadFile(filepath.Join(dir, "001_watchlists.sql"))
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE watchlists (id INT);", string(up))
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Connection pool and startup retry defaults, used when the matching variable is unset or invalid
+const (
+	DefaultMaxOpenConns    = 25
+	DefaultMaxIdleConns    = 5
+	DefaultConnMaxLifetime = 5 * time.Minute
+	DefaultConnectRetries  = 5
+	DefaultConnectBackoff  = 500 * time.Millisecond
+)
+
+// maxConnectBackoff caps the wait between two connection attempts
+const maxConnectBackoff = 30 * time.Second
+
+// PoolConfig sizes the connection pool and says how long to wait for the
+// database to come up at startup
+type PoolConfig struct {
+	// MaxOpenConns limits connections in use and idle (DB_MAX_OPEN_CONNS)
+	MaxOpenConns int
+	// MaxIdleConns limits connections kept open between queries (DB_MAX_IDLE_CONNS)
+	MaxIdleConns int
+	// ConnMaxLifetime closes connections older than this; zero keeps them (DB_CONN_MAX_LIFETIME)
+	ConnMaxLifetime time.Duration
+	// ConnectRetries is how many more pings follow a failed first one (DB_CONNECT_RETRIES)
+	ConnectRetries int
+	// ConnectBackoff is the wait after the first failed ping, doubled after each one (DB_CONNECT_BACKOFF)
+	ConnectBackoff time.Duration
+}
+
+// loadPoolConfig reads the pool settings, warning about and ignoring invalid values
+func loadPoolConfig() PoolConfig {
+	pool := PoolConfig{
+		MaxOpenConns:    envInt("DB_MAX_OPEN_CONNS", DefaultMaxOpenConns, 1),
+		MaxIdleConns:    envInt("DB_MAX_IDLE_CONNS", DefaultMaxIdleConns, 0),
+		ConnMaxLifetime: envDuration("DB_CONN_MAX_LIFETIME", DefaultConnMaxLifetime),
+		ConnectRetries:  envInt("DB_CONNECT_RETRIES", DefaultConnectRetries, 0),
+		ConnectBackoff:  envDuration("DB_CONNECT_BACKOFF", DefaultConnectBackoff),
+	}
+	if pool.MaxIdleConns > pool.MaxOpenConns {
+		slog.Warn("DB_MAX_IDLE_CONNS exceeds DB_MAX_OPEN_CONNS, keeping at most that many idle",
+			"max_idle_conns", pool.MaxIdleConns, "max_open_conns", pool.MaxOpenConns)
+		pool.MaxIdleConns = pool.MaxOpenConns
+	}
+	return pool
+}
+
+// envInt reads an integer of at least min from key, or returns def
+func envInt(key string, def, min int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min {
+		slog.Warn("Ignoring invalid database setting", "variable", key, "value", value, "default", def)
+		return def
+	}
+	return n
+}
+
+// envDuration reads a non-negative duration from key, or returns def
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		slog.Warn("Ignoring invalid database setting", "variable", key, "value", value, "default", def.String())
+		return def
+	}
+	return d
+}
+
+// Apply sets the pool limits on db
+func (p PoolConfig) Apply(db *sql.DB) {
+	db.SetMaxOpenConns(p.MaxOpenConns)
+	db.SetMaxIdleConns(p.MaxIdleConns)
+	db.SetConnMaxLifetime(p.ConnMaxLifetime)
+}
+
+// connectDeadline is the most time spent retrying, however many retries are left
+func (p PoolConfig) connectDeadline() time.Duration {
+	var total time.Duration
+	backoff := p.ConnectBackoff
+	for i := 0; i < p.ConnectRetries; i++ {
+		total += backoff
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+	// Leave each attempt time to fail on its own before the deadline cuts it off
+	return total + time.Duration(p.ConnectRetries+1)*5*time.Second
+}
+
+// pingWithRetry pings until the database answers, retrying up to ConnectRetries
+// times with exponential backoff, and gives up early once the cumulative
+// deadline has passed
+func (p PoolConfig) pingWithRetry(ctx context.Context, ping func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, p.connectDeadline())
+	defer cancel()
+
+	backoff := p.ConnectBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = ping(ctx); err == nil {
+			return nil
+		}
+		if attempt > p.ConnectRetries {
+			return fmt.Errorf("database not reachable after %d attempts: %w", attempt, err)
+		}
+
+		slog.Warn("Database not reachable yet, retrying", "attempt", attempt, "retry_in", backoff.String(), "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("database not reachable after %d attempts: %w", attempt, err)
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig_PoolDefaults(t *testing.T) {
+	for _, key := range []string{"DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS", "DB_CONN_MAX_LIFETIME", "DB_CONNECT_RETRIES", "DB_CONNECT_BACKOFF"} {
+		t.Setenv(key, "")
+	}
+
+	config, err := LoadConfig()
+	require.NoError(t, err)
+
+	assert.Equal(t, PoolConfig{
+		MaxOpenConns:    DefaultMaxOpenConns,
+		MaxIdleConns:    DefaultMaxIdleConns,
+		ConnMaxLifetime: DefaultConnMaxLifetime,
+		ConnectRetries:  DefaultConnectRetries,
+		ConnectBackoff:  DefaultConnectBackoff,
+	}, config.Pool)
+}
+
+func TestLoadConfig_Pool(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "50")
+	t.Setenv("DB_MAX_IDLE_CONNS", "10")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "15m")
+	t.Setenv("DB_CONNECT_RETRIES", "0")
+	t.Setenv("DB_CONNECT_BACKOFF", "2s")
+
+	config, err := LoadConfig()
+	require.NoError(t, err)
+
+	assert.Equal(t, PoolConfig{
+		MaxOpenConns:    50,
+		MaxIdleConns:    10,
+		ConnMaxLifetime: 15 * time.Minute,
+		ConnectRetries:  0,
+		ConnectBackoff:  2 * time.Second,
+	}, config.Pool)
+}
+
+func TestLoadConfig_InvalidPoolSettingsFallBack(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "0")
+	t.Setenv("DB_MAX_IDLE_CONNS", "many")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "-1m")
+	t.Setenv("DB_CONNECT_RETRIES", "-3")
+	t.Setenv("DB_CONNECT_BACKOFF", "soon")
+
+	config, err := LoadConfig()
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultMaxOpenConns, config.Pool.MaxOpenConns)
+	assert.Equal(t, DefaultMaxIdleConns, config.Pool.MaxIdleConns)
+	assert.Equal(t, DefaultConnMaxLifetime, config.Pool.ConnMaxLifetime)
+	assert.Equal(t, DefaultConnectRetries, config.Pool.ConnectRetries)
+	assert.Equal(t, DefaultConnectBackoff, config.Pool.ConnectBackoff)
+
+	// More idle than open connections is capped rather than rejected
+	t.Setenv("DB_MAX_OPEN_CONNS", "4")
+	t.Setenv("DB_MAX_IDLE_CONNS", "8")
+	config, err = LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, 4, config.Pool.MaxIdleConns)
+}
+
+func TestPoolConfig_Apply(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	PoolConfig{MaxOpenConns: 3, MaxIdleConns: 1, ConnMaxLifetime: time.Minute}.Apply(db)
+	assert.Equal(t, 3, db.Stats().MaxOpenConnections)
+
+	// Of three connections handed back, only one is kept idle
+	ctx := context.Background()
+	var conns []*sql.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := db.Conn(ctx)
+		require.NoError(t, err)
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		require.NoError(t, conn.Close())
+	}
+	assert.Equal(t, 1, db.Stats().Idle)
+}
+
+func TestPingWithRetry_GivesUpAfterConfiguredAttempts(t *testing.T) {
+	pool := PoolConfig{ConnectRetries: 3, ConnectBackoff: time.Millisecond}
+	refused := errors.New("connection refused")
+
+	attempts := 0
+	err := pool.pingWithRetry(context.Background(), func(context.Context) error {
+		attempts++
+		return refused
+	})
+
+	assert.ErrorIs(t, err, refused)
+	assert.Contains(t, err.Error(), "after 4 attempts")
+	assert.Equal(t, 4, attempts, "the first ping and three retries")
+}
+
+func TestPingWithRetry_SucceedsOnceDatabaseIsUp(t *testing.T) {
+	pool := PoolConfig{ConnectRetries: 5, ConnectBackoff: time.Millisecond}
+
+	attempts := 0
+	err := pool.pingWithRetry(context.Background(), func(context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("the database system is starting up")
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestPoolConfig_ConnectDeadline(t *testing.T) {
+	// 1s + 2s + 4s of backoff, and time for each of the four pings to fail
+	pool := PoolConfig{ConnectRetries: 3, ConnectBackoff: time.Second}
+	assert.Equal(t, 27*time.Second, pool.connectDeadline())
+
+	// Backoff stops doubling at the cap
+	pool = PoolConfig{ConnectRetries: 2, ConnectBackoff: 20 * time.Second}
+	assert.Equal(t, 20*time.Second+maxConnectBackoff+15*time.Second, pool.connectDeadline())
+}
+
+func TestPingWithRetry_StopsAtDeadline(t *testing.T) {
+	pool := PoolConfig{ConnectRetries: 10, ConnectBackoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	start := time.Now()
+	err := pool.pingWithRetry(ctx, func(context.Context) error {
+		attempts++
+		return errors.New("connection refused")
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+	assert.Less(t, time.Since(start), time.Second, "an hour's backoff is cut short")
+}
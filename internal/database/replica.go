@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultReplicaStickyWindow is how long reads for a freshly written symbol stay on the primary
+const DefaultReplicaStickyWindow = 10 * time.Second
+
+// ConnectReplica opens the read replica configured by DATABASE_REPLICA_URL.
+// It returns a nil handle and no error when no replica is configured.
+func ConnectReplica(config *Config) (*sql.DB, error) {
+	if config.ReplicaURL == "" {
+		return nil, nil
+	}
+
+	db, err := sql.Open("postgres", config.ReplicaURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replica connection: %w", err)
+	}
+
+	config.Pool.Apply(db)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping replica: %w", err)
+	}
+
+	slog.Info("Successfully connected to read replica")
+	return db, nil
+}
+
+// ReadRouter hands out the primary for writes and the replica for reads,
+// falling back to the primary when no replica is configured. After a write
+// to a symbol, reads that may include it go to the primary for a short
+// window so callers never see data older than what they just wrote.
+type ReadRouter struct {
+	primary      *sql.DB
+	replica      *sql.DB
+	stickyWindow time.Duration
+	now          func() time.Time
+
+	mu           sync.Mutex
+	recentWrites map[string]time.Time
+}
+
+// NewReadRouter creates a router; replica may be nil
+func NewReadRouter(primary, replica *sql.DB, stickyWindow time.Duration) *ReadRouter {
+	return &ReadRouter{
+		primary:      primary,
+		replica:      replica,
+		stickyWindow: stickyWindow,
+		now:          time.Now,
+		recentWrites: make(map[string]time.Time),
+	}
+}
+
+// Primary returns the write handle
+func (r *ReadRouter) Primary() *sql.DB {
+	return r.primary
+}
+
+// HasReplica reports whether reads can be served by a replica
+func (r *ReadRouter) HasReplica() bool {
+	return r.replica != nil
+}
+
+// Reader returns the handle for reads spanning many symbols. It stays on the
+// primary while any symbol is inside its post-write window.
+func (r *ReadRouter) Reader() *sql.DB {
+	if r.replica == nil {
+		return r.primary
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.expireWrites()
+	if len(r.recentWrites) > 0 {
+		return r.primary
+	}
+	return r.replica
+}
+
+// ReaderFor returns the handle for reads of a single symbol
+func (r *ReadRouter) ReaderFor(symbol string) *sql.DB {
+	if r.replica == nil {
+		return r.primary
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.expireWrites()
+	if _, ok := r.recentWrites[symbol]; ok {
+		return r.primary
+	}
+	return r.replica
+}
+
+// MarkWritten pins reads for symbol to the primary for the sticky window.
+// It is safe to call on a nil router.
+func (r *ReadRouter) MarkWritten(symbol string) {
+	if r == nil || r.replica == nil || r.stickyWindow <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.recentWrites[symbol] = r.now().Add(r.stickyWindow)
+}
+
+// expireWrites drops symbols whose window has passed; callers hold r.mu
+func (r *ReadRouter) expireWrites() {
+	now := r.now()
+	for symbol, until := range r.recentWrites {
+		if !now.Before(until) {
+			delete(r.recentWrites, symbol)
+		}
+	}
+}
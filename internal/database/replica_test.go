@@ -0,0 +1,76 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockHandles(t *testing.T) (*sql.DB, *sql.DB) {
+	primary, _, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { primary.Close() })
+
+	replica, _, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { replica.Close() })
+
+	return primary, replica
+}
+
+func TestReadRouter_FallsBackToPrimaryWithoutReplica(t *testing.T) {
+	primary, _ := newMockHandles(t)
+	router := NewReadRouter(primary, nil, time.Minute)
+
+	router.MarkWritten("AAPL")
+
+	assert.False(t, router.HasReplica())
+	assert.Same(t, primary, router.Primary())
+	assert.Same(t, primary, router.Reader())
+	assert.Same(t, primary, router.ReaderFor("AAPL"))
+}
+
+func TestReadRouter_RoutesReadsToReplica(t *testing.T) {
+	primary, replica := newMockHandles(t)
+	router := NewReadRouter(primary, replica, time.Minute)
+
+	assert.True(t, router.HasReplica())
+	assert.Same(t, primary, router.Primary())
+	assert.Same(t, replica, router.Reader())
+	assert.Same(t, replica, router.ReaderFor("AAPL"))
+}
+
+func TestReadRouter_PinsWrittenSymbolToPrimary(t *testing.T) {
+	primary, replica := newMockHandles(t)
+	router := NewReadRouter(primary, replica, 10*time.Second)
+
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	router.now = func() time.Time { return now }
+
+	router.MarkWritten("AAPL")
+
+	assert.Same(t, primary, router.ReaderFor("AAPL"))
+	assert.Same(t, replica, router.ReaderFor("MSFT"))
+	// Multi-symbol reads could include AAPL, so they stay on the primary too
+	assert.Same(t, primary, router.Reader())
+
+	now = now.Add(10 * time.Second)
+
+	assert.Same(t, replica, router.ReaderFor("AAPL"))
+	assert.Same(t, replica, router.Reader())
+}
+
+func TestReadRouter_NilAndZeroWindowMarkWritten(t *testing.T) {
+	var nilRouter *ReadRouter
+	assert.NotPanics(t, func() { nilRouter.MarkWritten("AAPL") })
+
+	primary, replica := newMockHandles(t)
+	router := NewReadRouter(primary, replica, 0)
+	router.MarkWritten("AAPL")
+
+	assert.Same(t, replica, router.ReaderFor("AAPL"))
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"stock-intelligence-backend/internal/models"
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Column widths of the stocks table
+const (
+	maxCompanyNameLength = 255
+	maxSectorLength      = 100
+	maxIndustryLength    = 150
+	maxExchangeLength    = 10
+	maxRegionLength      = 50
+	maxSearchLength      = 100
+)
+
+// AdminHandler lets operators find listings, start tracking them and keep
+// their metadata right
+type AdminHandler struct {
+	providerName string
+	search       services.SymbolSearchProvider
+	listings     *services.StockListingService
+}
+
+// NewAdminHandler creates an admin handler. Symbol search is only offered
+// when provider implements services.SymbolSearchProvider.
+func NewAdminHandler(provider services.MarketDataProvider, listings *services.StockListingService) *AdminHandler {
+	h := &AdminHandler{providerName: provider.Name(), listings: listings}
+	if search, ok := provider.(services.SymbolSearchProvider); ok {
+		h.search = search
+	}
+	return h
+}
+
+// SearchSymbols looks up listings matching q through the market data provider,
+// spending one call of its budget
+func (h *AdminHandler) SearchSymbols(c *gin.Context) {
+	keywords := strings.TrimSpace(c.Query("q"))
+	if keywords == "" || len(keywords) > maxSearchLength {
+		respondError(c, http.StatusBadRequest, CodeValidation, fmt.Errorf("q must be 1 to %d characters", maxSearchLength))
+		return
+	}
+	if h.search == nil {
+		respondError(c, http.StatusNotImplemented, CodeInternal, withDetails("Symbol search is not supported by the market data provider", gin.H{
+			"provider": h.providerName,
+		}))
+		return
+	}
+
+	matches, err := h.search.SearchSymbols(c.Request.Context(), keywords, services.InitiatorAdmin)
+	if errors.Is(err, services.ErrRateLimited) {
+		respondError(c, http.StatusTooManyRequests, CodeRateLimited, withDetails("API budget exhausted", err.Error()))
+		return
+	}
+	if err != nil {
+		respondServiceError(c, "Symbol search failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    matches,
+		"count":   len(matches),
+	})
+}
+
+// AddStock starts tracking a stock, usually one picked from SearchSymbols. It
+// is active at once and queued for its first sync.
+func (h *AdminHandler) AddStock(c *gin.Context) {
+	var listing services.NewListing
+	if err := c.ShouldBindJSON(&listing); err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Invalid request body"))
+		return
+	}
+
+	symbol, err := models.NormalizeSymbol(listing.Symbol)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock symbol", err.Error()))
+		return
+	}
+	listing.Symbol = symbol
+	listing.CompanyName = strings.TrimSpace(listing.CompanyName)
+	listing.Exchange = strings.ToUpper(strings.TrimSpace(listing.Exchange))
+	listing.Region = strings.TrimSpace(listing.Region)
+
+	invalid := gin.H{}
+	if listing.CompanyName == "" || len(listing.CompanyName) > maxCompanyNameLength {
+		invalid["company_name"] = fmt.Sprintf("is required, at most %d characters", maxCompanyNameLength)
+	}
+	if listing.Exchange == "" || len(listing.Exchange) > maxExchangeLength {
+		invalid["exchange"] = fmt.Sprintf("is required, at most %d characters", maxExchangeLength)
+	}
+	if len(listing.Region) > maxRegionLength {
+		invalid["region"] = fmt.Sprintf("must be at most %d characters", maxRegionLength)
+	}
+	if len(invalid) > 0 {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock listing", invalid))
+		return
+	}
+
+	stock, err := h.listings.AddStock(c.Request.Context(), listing)
+	if errors.Is(err, services.ErrStockExists) {
+		respondError(c, http.StatusConflict, CodeConflict, withDetails("Stock already exists", gin.H{
+			"symbol": symbol,
+		}))
+		return
+	}
+	if err != nil {
+		respondServiceError(c, "Failed to add stock", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    stock,
+	})
+}
+
+// UpdateStock edits a stock's metadata; fields left out of the body keep
+// their values, and an empty sector or industry clears it
+func (h *AdminHandler) UpdateStock(c *gin.Context) {
+	symbol, err := models.NormalizeSymbol(c.Param("symbol"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock symbol", err.Error()))
+		return
+	}
+
+	var update services.StockUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Invalid request body"))
+		return
+	}
+	if update == (services.StockUpdate{}) {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("No fields to update"))
+		return
+	}
+
+	invalid := gin.H{}
+	trim := func(field string, value *string, required bool, maxLength int) {
+		if value == nil {
+			return
+		}
+		*value = strings.TrimSpace(*value)
+		if (required && *value == "") || len(*value) > maxLength {
+			if required {
+				invalid[field] = fmt.Sprintf("must be 1 to %d characters", maxLength)
+			} else {
+				invalid[field] = fmt.Sprintf("must be at most %d characters", maxLength)
+			}
+		}
+	}
+	trim("company_name", update.CompanyName, true, maxCompanyNameLength)
+	trim("sector", update.Sector, false, maxSectorLength)
+	trim("industry", update.Industry, false, maxIndustryLength)
+	trim("exchange", update.Exchange, true, maxExchangeLength)
+	if update.Exchange != nil {
+		*update.Exchange = strings.ToUpper(*update.Exchange)
+	}
+	if update.MarketCap != nil && *update.MarketCap < 0 {
+		invalid["market_cap"] = "must not be negative"
+	}
+	if len(invalid) > 0 {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock update", invalid))
+		return
+	}
+
+	h.respondStockUpdate(c, symbol, func() (*services.StockMetadata, error) {
+		return h.listings.UpdateStock(c.Request.Context(), symbol, update)
+	})
+}
+
+// ActivateStock lists a stock again and returns it to the sync queue
+func (h *AdminHandler) ActivateStock(c *gin.Context) {
+	h.setActive(c, true)
+}
+
+// DeactivateStock stops listing and syncing a stock, such as a delisted
+// ticker, while keeping its stored prices
+func (h *AdminHandler) DeactivateStock(c *gin.Context) {
+	h.setActive(c, false)
+}
+
+func (h *AdminHandler) setActive(c *gin.Context, active bool) {
+	symbol, err := models.NormalizeSymbol(c.Param("symbol"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock symbol", err.Error()))
+		return
+	}
+
+	h.respondStockUpdate(c, symbol, func() (*services.StockMetadata, error) {
+		return h.listings.SetActive(c.Request.Context(), symbol, active)
+	})
+}
+
+// respondStockUpdate writes the stock update returns, or its error
+func (h *AdminHandler) respondStockUpdate(c *gin.Context, symbol string, update func() (*services.StockMetadata, error)) {
+	stock, err := update()
+	if errors.Is(err, services.ErrStockNotFound) {
+		respondError(c, http.StatusNotFound, CodeNotFound, withDetails("Stock not found", gin.H{
+			"symbol": symbol,
+		}))
+		return
+	}
+	if err != nil {
+		respondServiceError(c, "Failed to update stock", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    stock,
+	})
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// searchProvider answers symbol searches with canned matches or err
+type searchProvider struct {
+	services.MarketDataProvider
+	matches  []services.SymbolMatch
+	err      error
+	searches []string
+}
+
+func (p *searchProvider) Name() string { return "test" }
+
+func (p *searchProvider) SearchSymbols(ctx context.Context, keywords string, initiator services.Initiator) ([]services.SymbolMatch, error) {
+	if err := initiator.Validate(); err != nil {
+		return nil, err
+	}
+	p.searches = append(p.searches, keywords)
+	return p.matches, p.err
+}
+
+// plainProvider is a market data provider without symbol search
+type plainProvider struct {
+	services.MarketDataProvider
+}
+
+func (plainProvider) Name() string { return "plain" }
+
+func newAdminRouter(t *testing.T, provider services.MarketDataProvider) (*gin.Engine, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	handler := NewAdminHandler(provider, services.NewStockListingService(db, nil))
+	router := gin.New()
+	router.GET("/admin/symbol-search", handler.SearchSymbols)
+	router.POST("/admin/stocks", handler.AddStock)
+	router.PATCH("/admin/stocks/:symbol", handler.UpdateStock)
+	router.POST("/admin/stocks/:symbol/deactivate", handler.DeactivateStock)
+	router.POST("/admin/stocks/:symbol/activate", handler.ActivateStock)
+	return router, mock
+}
+
+func serveAdminRequest(t *testing.T, router *gin.Engine, method, path, body string) (int, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return w.Code, response
+}
+
+func TestSearchSymbols_ProxiesProvider(t *testing.T) {
+	provider := &searchProvider{matches: []services.SymbolMatch{
+		{Symbol: "TSCO.LON", Name: "Tesco PLC", Type: "Equity", Region: "United Kingdom", Currency: "GBX", MatchScore: 0.7273},
+	}}
+	router, _ := newAdminRouter(t, provider)
+
+	code, response := serveAdminRequest(t, router, http.MethodGet, "/admin/symbol-search?q=+tesco+", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"tesco"}, provider.searches)
+	assert.Equal(t, float64(1), response["count"])
+	match := response["data"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "TSCO.LON", match["symbol"])
+	assert.Equal(t, "United Kingdom", match["region"])
+
+	code, response = serveAdminRequest(t, router, http.MethodGet, "/admin/symbol-search", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, string(CodeValidation), responseError(t, response)["code"])
+	assert.Len(t, provider.searches, 1, "a missing query spends no API call")
+}
+
+func TestSearchSymbols_Failures(t *testing.T) {
+	budget := &services.APIError{Provider: "test", Kind: services.APIErrorRateLimited, Symbol: "tesco", Message: "local API budget exhausted"}
+	router, _ := newAdminRouter(t, &searchProvider{err: budget})
+	code, response := serveAdminRequest(t, router, http.MethodGet, "/admin/symbol-search?q=tesco", "")
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.Equal(t, string(CodeRateLimited), responseError(t, response)["code"])
+
+	router, _ = newAdminRouter(t, &searchProvider{err: fmt.Errorf("connection reset")})
+	code, _ = serveAdminRequest(t, router, http.MethodGet, "/admin/symbol-search?q=tesco", "")
+	assert.Equal(t, http.StatusInternalServerError, code)
+
+	router, _ = newAdminRouter(t, plainProvider{})
+	code, response = serveAdminRequest(t, router, http.MethodGet, "/admin/symbol-search?q=tesco", "")
+	assert.Equal(t, http.StatusNotImplemented, code)
+	assert.Equal(t, "plain", responseError(t, response)["details"].(map[string]interface{})["provider"])
+}
+
+func TestAddStock_InsertsAndRejectsDuplicates(t *testing.T) {
+	router, mock := newAdminRouter(t, plainProvider{})
+	body := `{"symbol": "tsco.lon", "company_name": " Tesco PLC ", "exchange": "lse", "region": "United Kingdom"}`
+
+	mock.ExpectQuery("INSERT INTO stocks").
+		WithArgs("TSCO.LON", "Tesco PLC", "LSE", "United Kingdom").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(42, time.Now()))
+	code, response := serveAdminRequest(t, router, http.MethodPost, "/admin/stocks", body)
+	require.Equal(t, http.StatusCreated, code)
+	stock := response["data"].(map[string]interface{})
+	assert.Equal(t, float64(42), stock["id"])
+	assert.Equal(t, "TSCO.LON", stock["symbol"])
+	assert.Equal(t, true, stock["is_active"])
+
+	mock.ExpectQuery("INSERT INTO stocks").
+		WithArgs("TSCO.LON", "Tesco PLC", "LSE", "United Kingdom").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}))
+	code, response = serveAdminRequest(t, router, http.MethodPost, "/admin/stocks", body)
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, string(CodeConflict), responseError(t, response)["code"])
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddStock_Validation(t *testing.T) {
+	router, mock := newAdminRouter(t, plainProvider{})
+
+	for _, body := range []string{
+		`not json`,
+		`{"symbol": "AA PL", "company_name": "Apple Inc.", "exchange": "NASDAQ"}`,
+		`{"symbol": "AAPL", "exchange": "NASDAQ"}`,
+		`{"symbol": "AAPL", "company_name": "Apple Inc.", "exchange": "NASDAQ GLOBAL SELECT"}`,
+		`{"symbol": "AAPL", "company_name": "Apple Inc.", "exchange": "NASDAQ", "region": "` + strings.Repeat("x", 51) + `"}`,
+	} {
+		code, response := serveAdminRequest(t, router, http.MethodPost, "/admin/stocks", body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+		assert.Equal(t, string(CodeValidation), responseError(t, response)["code"], body)
+	}
+
+	// Nothing reached the database
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func expectStockUpdate(mock sqlmock.Sqlmock, symbol string, active bool, args ...driver.Value) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WithArgs(symbol).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "symbol", "company_name", "sector", "industry", "exchange", "region", "market_cap", "is_active", "delisted_at", "updated_at",
+		}).AddRow(7, symbol, "Apple Inc.", "Technology", "", "NASDAQ", "", nil, active, nil, time.Now()))
+	mock.ExpectQuery("UPDATE stocks").WithArgs(args...).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(time.Now()))
+	mock.ExpectExec("INSERT INTO stock_audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+func TestUpdateStock_AppliesOnlyGivenFields(t *testing.T) {
+	router, mock := newAdminRouter(t, plainProvider{})
+
+	expectStockUpdate(mock, "AAPL", true, 7, "Apple Inc.", "Technology", "", "NYSE", int64(2900000000000), true, nil)
+	code, response := serveAdminRequest(t, router, http.MethodPatch, "/admin/stocks/aapl",
+		`{"exchange": " nyse ", "market_cap": 2900000000000}`)
+	require.Equal(t, http.StatusOK, code)
+	stock := response["data"].(map[string]interface{})
+	assert.Equal(t, "NYSE", stock["exchange"])
+	assert.Equal(t, "Technology", stock["sector"])
+	assert.Equal(t, float64(2900000000000), stock["market_cap"])
+
+	for _, body := range []string{
+		`{}`,
+		`{"company_name": " "}`,
+		`{"exchange": ""}`,
+		`{"sector": "` + strings.Repeat("x", 101) + `"}`,
+		`{"market_cap": -1}`,
+		`{"is_active": "no"}`,
+	} {
+		code, response := serveAdminRequest(t, router, http.MethodPatch, "/admin/stocks/AAPL", body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+		assert.Equal(t, string(CodeValidation), responseError(t, response)["code"], body)
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeactivateAndActivateStock(t *testing.T) {
+	router, mock := newAdminRouter(t, plainProvider{})
+
+	expectStockUpdate(mock, "TWTR", true, 7, "Apple Inc.", "Technology", "", "NASDAQ", nil, false, nil)
+	code, response := serveAdminRequest(t, router, http.MethodPost, "/admin/stocks/TWTR/deactivate", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, false, response["data"].(map[string]interface{})["is_active"])
+
+	expectStockUpdate(mock, "TWTR", false, 7, "Apple Inc.", "Technology", "", "NASDAQ", nil, true, nil)
+	code, response = serveAdminRequest(t, router, http.MethodPost, "/admin/stocks/TWTR/activate", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, response["data"].(map[string]interface{})["is_active"])
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WithArgs("NOTREAL").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+	code, response = serveAdminRequest(t, router, http.MethodPost, "/admin/stocks/NOTREAL/activate", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, string(CodeNotFound), responseError(t, response)["code"])
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
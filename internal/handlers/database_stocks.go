@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"stock-intelligence-backend/internal/cache"
 	"stock-intelligence-backend/internal/models"
 	"stock-intelligence-backend/internal/services"
 
@@ -14,31 +20,46 @@ import (
 
 // DatabaseStockHandler handles stock-related HTTP requests using database
 type DatabaseStockHandler struct {
-	stockService *services.DatabaseStockService
+	stockService services.StockReader
+	prices       services.PriceReader
+	logger       *slog.Logger
 }
 
-// NewDatabaseStockHandler creates a new database stock handler
-func NewDatabaseStockHandler(stockService *services.DatabaseStockService) *DatabaseStockHandler {
+// cacheHolder is implemented by readers whose results the handler may cache
+type cacheHolder interface {
+	GetCache() *cache.RedisCache
+}
+
+// NewDatabaseStockHandler creates a new database stock handler. Price history
+// is cached when prices also implements GetCache, as DatabaseStockService does.
+func NewDatabaseStockHandler(stockService services.StockReader, prices services.PriceReader) *DatabaseStockHandler {
 	return &DatabaseStockHandler{
 		stockService: stockService,
+		prices:       prices,
+		logger:       slog.Default(),
 	}
 }
 
+// SetLogger sets the logger for problems that do not fail the request
+func (h *DatabaseStockHandler) SetLogger(logger *slog.Logger) {
+	h.logger = logger
+}
+
 // GetAllStocks returns all stocks from database with pagination support
 func (h *DatabaseStockHandler) GetAllStocks(c *gin.Context) {
 	// Query parameters for filtering and pagination
 	sector := c.Query("sector")
 	priceRange := c.Query("price_range")
-	limitStr := c.DefaultQuery("limit", "50")
+	limitStr := c.DefaultQuery("limit", strconv.Itoa(defaultPageSize))
 	offsetStr := c.DefaultQuery("offset", "0")
 	
 	// Parse pagination parameters
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit <= 0 {
-		limit = 50 // Default page size
+		limit = defaultPageSize
 	}
-	if limit > 200 {
-		limit = 200 // Maximum page size
+	if limit > maxPageSize {
+		limit = maxPageSize
 	}
 	
 	offset, err := strconv.Atoi(offsetStr)
@@ -46,44 +67,103 @@ func (h *DatabaseStockHandler) GetAllStocks(c *gin.Context) {
 		offset = 0
 	}
 	
-	var stocks []models.Stock
-	var totalCount int
+	// Coverage filter: only stocks whose stored prices span at least this many days
+	minHistoryDays := 0
+	if value := c.Query("min_history_days"); value != "" {
+		minHistoryDays, err = strconv.Atoi(value)
+		if err != nil || minHistoryDays < 0 {
+			respondError(c, http.StatusBadRequest, CodeValidation, errors.New("min_history_days must be a non-negative integer"))
+			return
+		}
+	}
+	
+	// Mobile clients ask for a few fields rather than the full stock
+	fields, err := stockProjection(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, err)
+		return
+	}
 	
-	// Apply filters
+	filter := services.StockFilter{Sector: sector, MinHistoryDays: minHistoryDays}
 	if sector != "" {
-		stocks = h.stockService.GetStocksBySector(sector)
-		totalCount = len(stocks)
-		// Apply pagination to filtered results
-		end := offset + limit
-		if offset >= len(stocks) {
-			stocks = []models.Stock{}
-		} else {
-			if end > len(stocks) {
-				end = len(stocks)
-			}
-			stocks = stocks[offset:end]
+		// A misspelled sector would otherwise list nothing; without the known sectors the filter applies unchecked
+		filters, err := h.stockService.GetMarketFilters(c.Request.Context())
+		if err != nil {
+			h.logger.WarnContext(c.Request.Context(), "Failed to load sectors to validate against", "error", err)
+		} else if !filters.HasSector(sector) {
+			respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Unknown sector", gin.H{
+				"valid_sectors": filters.Sectors,
+			}))
+			return
+		}
+	}
+	if priceRange != "" {
+		if ranges := h.stockService.GetPriceRanges(); !ranges.IsValid(priceRange) {
+			respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid price range", gin.H{
+				"valid_ranges": ranges.Labels(),
+			}))
+			return
+		}
+		filter.PriceRange = priceRange
+	}
+	if value := c.Query("market_cap"); value != "" {
+		if filter.MarketCap, err = services.ParseMarketCapBand(value); err != nil {
+			respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Unsupported market_cap", gin.H{
+				"valid_market_caps": services.MarketCapBands,
+			}))
+			return
+		}
+	}
+	if value := c.Query("exchange"); value != "" {
+		if filter.Exchange, err = services.ParseExchange(value); err != nil {
+			respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Unsupported exchange", gin.H{
+				"valid_exchanges": services.Exchanges,
+			}))
+			return
 		}
-	} else if priceRange != "" {
-		stocks = h.stockService.GetStocksByPriceRange(priceRange)
-		totalCount = len(stocks)
-		// Apply pagination to filtered results
-		end := offset + limit
-		if offset >= len(stocks) {
-			stocks = []models.Stock{}
-		} else {
-			if end > len(stocks) {
-				end = len(stocks)
+	}
+	
+	// Cursor mode pages after the last stock seen instead of by offset; an empty cursor starts at the first page
+	if cursorValue, ok := c.GetQuery("cursor"); ok {
+		if _, ok := c.GetQuery("offset"); ok {
+			respondError(c, http.StatusBadRequest, CodeValidation, errors.New("cursor cannot be combined with offset"))
+			return
+		}
+		var cursor *services.StockCursor
+		if cursorValue != "" {
+			if cursor, err = services.ParseStockCursor(cursorValue); err != nil {
+				respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid cursor", err.Error()))
+				return
 			}
-			stocks = stocks[offset:end]
 		}
+		h.getStocksAfter(c, filter, cursor, limit, fields)
+		return
+	}
+	
+	var stocks []models.Stock
+	var totalCount int
+	
+	// Pages of a whole sector are cached; every other combination of filters is applied in SQL
+	if sector != "" && filter == (services.StockFilter{Sector: sector, MinHistoryDays: minHistoryDays}) {
+		stocks, totalCount, err = h.stockService.GetStocksBySectorPaginated(c.Request.Context(), sector, limit, offset, minHistoryDays)
 	} else {
-		// Use new paginated method
-		stocks, totalCount = h.stockService.GetAllStocksPaginated(limit, offset)
+		stocks, totalCount, err = h.stockService.GetAllStocksPaginated(c.Request.Context(), filter, limit, offset)
+	}
+	if err != nil {
+		respondServiceError(c, "Failed to load stocks", err)
+		return
+	}
+	
+	// Projected after loading, so the cached stocks stay whole
+	data, err := projectStocks(stocks, fields)
+	if err != nil {
+		respondServiceError(c, "Failed to encode stocks", err)
+		return
 	}
 	
 	c.JSON(http.StatusOK, gin.H{
 		"success":     true,
-		"data":        stocks,
+		"data":        data,
 		"count":       len(stocks),
 		"total":       totalCount,
 		"offset":      offset,
@@ -92,30 +172,442 @@ func (h *DatabaseStockHandler) GetAllStocks(c *gin.Context) {
 	})
 }
 
+// getStocksAfter responds with the page of the stock list following cursor.
+// Cursor pages carry no total; next_cursor is null on the last page.
+func (h *DatabaseStockHandler) getStocksAfter(c *gin.Context, filter services.StockFilter, cursor *services.StockCursor, limit int, fields []string) {
+	stocks, next, err := h.stockService.GetStocksAfter(c.Request.Context(), filter, cursor, limit)
+	if err != nil {
+		respondServiceError(c, "Failed to load stocks", err)
+		return
+	}
+	
+	data, err := projectStocks(stocks, fields)
+	if err != nil {
+		respondServiceError(c, "Failed to encode stocks", err)
+		return
+	}
+	
+	var nextCursor *string
+	if next != nil {
+		encoded := next.Encode()
+		nextCursor = &encoded
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"data":        data,
+		"count":       len(stocks),
+		"limit":       limit,
+		"next_cursor": nextCursor,
+	})
+}
+
 // GetStockBySymbol returns a specific stock by symbol
 func (h *DatabaseStockHandler) GetStockBySymbol(c *gin.Context) {
+	symbol, err := models.NormalizeSymbol(c.Param("symbol"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock symbol", err.Error()))
+		return
+	}
+	
+	stock, err := h.stockService.GetStockBySymbol(c.Request.Context(), symbol)
+	if errors.Is(err, services.ErrStockNotFound) {
+		respondError(c, http.StatusNotFound, CodeNotFound, withDetails("Stock not found", err.Error()))
+		return
+	}
+	if err != nil {
+		respondServiceError(c, "Failed to load stock", err)
+		return
+	}
+	
+	dividends, err := h.stockService.GetDividends(c.Request.Context(), symbol)
+	if err != nil {
+		respondServiceError(c, "Failed to load dividends", err)
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": stockDetail{
+			Stock:            stock,
+			DividendYieldTTM: services.TrailingDividendYield(dividends, stock.CurrentPrice, stock.LastUpdated),
+			Delisted:         !stock.IsActive,
+		},
+	})
+}
+
+// stockDetail is a stock with the figures only its detail view computes
+type stockDetail struct {
+	*models.Stock
+	// DividendYieldTTM is null for a stock without a price or a dividend in the past year
+	DividendYieldTTM *float64 `json:"dividend_yield_ttm"`
+	// Delisted is set for a stock that stopped trading; its stored history is still served
+	Delisted bool `json:"delisted"`
+}
+
+// GetStockDividends returns a stock's recorded cash dividends, latest first
+func (h *DatabaseStockHandler) GetStockDividends(c *gin.Context) {
+	symbol, err := models.NormalizeSymbol(c.Param("symbol"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock symbol", err.Error()))
+		return
+	}
+	
+	dividends, err := h.stockService.GetDividends(c.Request.Context(), symbol)
+	if errors.Is(err, services.ErrStockNotFound) {
+		respondError(c, http.StatusNotFound, CodeNotFound, withDetails("Stock not found", err.Error()))
+		return
+	}
+	if err != nil {
+		respondServiceError(c, "Failed to load dividends", err)
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"symbol":    symbol,
+			"dividends": dividends,
+			"count":     len(dividends),
+		},
+	})
+}
+
+// GetStockNews returns a stock's latest stored news articles with their
+// sentiment, and the average sentiment over the articles returned
+func (h *DatabaseStockHandler) GetStockNews(c *gin.Context) {
+	symbol, err := models.NormalizeSymbol(c.Param("symbol"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock symbol", err.Error()))
+		return
+	}
+	
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultNewsLimit)))
+	if err != nil || limit <= 0 {
+		limit = defaultNewsLimit
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	
+	articles, err := h.stockService.GetNews(c.Request.Context(), symbol, limit)
+	if errors.Is(err, services.ErrStockNotFound) {
+		respondError(c, http.StatusNotFound, CodeNotFound, withDetails("Stock not found", err.Error()))
+		return
+	}
+	if err != nil {
+		respondServiceError(c, "Failed to load news", err)
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"symbol":            symbol,
+			"articles":          articles,
+			"count":             len(articles),
+			"average_sentiment": services.AverageSentiment(articles),
+		},
+	})
+}
+
+type quotesRequest struct {
+	Symbols []string `json:"symbols"`
+}
+
+// GetQuotes returns the latest price, change and volume of up to
+// services.MaxQuoteSymbols stocks; unknown symbols are listed in not_found
+func (h *DatabaseStockHandler) GetQuotes(c *gin.Context) {
+	var req quotesRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Symbols) == 0 {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("A non-empty symbols list is required"))
+		return
+	}
+	if len(req.Symbols) > services.MaxQuoteSymbols {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails(fmt.Sprintf("At most %d symbols may be quoted at once", services.MaxQuoteSymbols), gin.H{
+			"count": len(req.Symbols),
+		}))
+		return
+	}
+	
+	symbols := make([]string, len(req.Symbols))
+	for i, raw := range req.Symbols {
+		symbol, err := models.NormalizeSymbol(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock symbol", gin.H{
+				"symbol": raw,
+				"reason": err.Error(),
+			}))
+			return
+		}
+		symbols[i] = symbol
+	}
+	
+	result, err := h.stockService.GetQuotes(c.Request.Context(), symbols)
+	if err != nil {
+		respondServiceError(c, "Failed to load quotes", err)
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"data":      result.Quotes,
+		"not_found": result.NotFound,
+	})
+}
+
+// GetCorrelation returns the correlation matrix of the daily returns of up to
+// services.MaxCorrelationSymbols stocks over their latest days of closes.
+// Adjusted closes are used so a split does not read as a crash.
+func (h *DatabaseStockHandler) GetCorrelation(c *gin.Context) {
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, raw := range strings.Split(c.Query("symbols"), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		symbol, err := models.NormalizeSymbol(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock symbol", gin.H{
+				"symbol": raw,
+				"reason": err.Error(),
+			}))
+			return
+		}
+		if !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) < 2 {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("At least two distinct symbols are required"))
+		return
+	}
+	if len(symbols) > services.MaxCorrelationSymbols {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails(fmt.Sprintf("At most %d symbols may be correlated at once", services.MaxCorrelationSymbols), gin.H{
+			"count": len(symbols),
+		}))
+		return
+	}
+	
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultCorrelationDays)))
+	if err != nil || days < 1 || days > maxPerformanceDays {
+		respondError(c, http.StatusBadRequest, CodeValidation, fmt.Errorf("days must be between 1 and %d", maxPerformanceDays))
+		return
+	}
+	
+	series := make(map[string][]models.PricePoint, len(symbols))
+	for _, symbol := range symbols {
+		history, err := h.prices.GetPriceHistory(c.Request.Context(), symbol, days, true)
+		if err != nil {
+			respondServiceError(c, "Failed to fetch historical data", err)
+			return
+		}
+		series[symbol] = history
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"days":             days,
+			"min_observations": services.MinCorrelationObservations,
+			"correlation":      services.BuildCorrelationMatrix(symbols, series, services.MinCorrelationObservations),
+		},
+	})
+}
+
+// GetStockBeta returns a stock's beta against a benchmark stored in stocks,
+// from both series' latest days of adjusted closes. A benchmark without
+// stored prices is a 409, as it has to be synced before betas mean anything.
+func (h *DatabaseStockHandler) GetStockBeta(c *gin.Context) {
+	symbol, err := models.NormalizeSymbol(c.Param("symbol"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock symbol", err.Error()))
+		return
+	}
+	benchmark, err := models.NormalizeSymbol(c.DefaultQuery("benchmark", defaultBetaBenchmark))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid benchmark symbol", err.Error()))
+		return
+	}
+	
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultBetaDays)))
+	if err != nil || days < 1 || days > maxPerformanceDays {
+		respondError(c, http.StatusBadRequest, CodeValidation, fmt.Errorf("days must be between 1 and %d", maxPerformanceDays))
+		return
+	}
+	
+	benchmarkHistory, err := h.prices.GetPriceHistory(c.Request.Context(), benchmark, days, true)
+	if err != nil {
+		respondServiceError(c, "Failed to fetch historical data", err)
+		return
+	}
+	if len(benchmarkHistory) == 0 {
+		respondError(c, http.StatusConflict, CodeConflict, withDetails(
+			fmt.Sprintf("Benchmark %s has no stored prices; sync it with POST /api/v1/system/sync/%s first", benchmark, benchmark),
+			gin.H{"benchmark": benchmark}))
+		return
+	}
+	
+	history, err := h.prices.GetPriceHistory(c.Request.Context(), symbol, days, true)
+	if err != nil {
+		respondServiceError(c, "Failed to fetch historical data", err)
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"symbol":           symbol,
+			"benchmark":        benchmark,
+			"days":             days,
+			"min_observations": services.MinCorrelationObservations,
+			"estimate":         services.EstimateBeta(history, benchmarkHistory, services.MinCorrelationObservations),
+		},
+	})
+}
+
+// GetStockIntraday returns a stock's intraday bars for one trading day
+func (h *DatabaseStockHandler) GetStockIntraday(c *gin.Context) {
 	symbol := c.Param("symbol")
-	if symbol == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "Symbol parameter is required",
-		})
+	interval := c.DefaultQuery("interval", defaultIntradayInterval)
+	if err := services.ValidateIntradayInterval(interval); err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Unsupported interval", gin.H{
+			"valid_intervals": services.IntradayIntervals,
+		}))
 		return
 	}
 	
-	stock, err := h.stockService.GetStockBySymbol(symbol)
+	// Without a date the most recent day with bars is returned
+	var date time.Time
+	if dateStr := c.Query("date"); dateStr != "" {
+		parsed, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Invalid date, expected YYYY-MM-DD"))
+			return
+		}
+		date = parsed
+	}
+	
+	prices, err := h.prices.GetIntradayPrices(c.Request.Context(), symbol, interval, date)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"success": false,
-			"error":   "Stock not found",
-			"details": err.Error(),
-		})
+		respondServiceError(c, "Failed to fetch intraday data", err)
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"data":     prices,
+		"symbol":   symbol,
+		"interval": interval,
+		"count":    len(prices),
+	})
+}
+
+// GetStockPrices returns a stock's prices between the optional from and to
+// dates as daily bars, or aggregated into weekly or monthly ones. The bar of
+// a period still in progress, or cut by the range, is flagged partial.
+func (h *DatabaseStockHandler) GetStockPrices(c *gin.Context) {
+	symbol, err := models.NormalizeSymbol(c.Param("symbol"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock symbol", err.Error()))
+		return
+	}
+	
+	resolution, err := services.ParsePriceResolution(c.Query("resolution"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Unsupported resolution", gin.H{
+			"valid_resolutions": services.PriceResolutions,
+		}))
+		return
+	}
+	
+	var dates [2]time.Time
+	for i, name := range []string{"from", "to"} {
+		if value := c.Query(name); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, CodeValidation, fmt.Errorf("Invalid %s date, expected YYYY-MM-DD", name))
+				return
+			}
+			dates[i] = parsed
+		}
+	}
+	from, to := dates[0], dates[1]
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("from must not be after to"))
+		return
+	}
+	fromKey, toKey := c.Query("from"), c.Query("to")
+	
+	// Entries are dropped whenever a sync invalidates the cache, so a partial bar is never served stale for long
+	var redisCache *cache.RedisCache
+	if cached, ok := h.prices.(cacheHolder); ok {
+		redisCache = cached.GetCache()
+	}
+	if redisCache != nil {
+		var cached map[string]interface{}
+		if err := redisCache.GetPriceSeries(c.Request.Context(), symbol, string(resolution), fromKey, toKey, &cached); err == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": true,
+				"data":    cached,
+			})
+			return
+		}
+	}
+	
+	bars, err := h.prices.GetPriceSeries(c.Request.Context(), symbol, resolution, from, to)
+	if errors.Is(err, services.ErrStockNotFound) {
+		respondError(c, http.StatusNotFound, CodeNotFound, withDetails("Stock not found", err.Error()))
+		return
+	}
+	if err != nil {
+		respondServiceError(c, "Failed to load prices", err)
 		return
 	}
 	
+	series := gin.H{
+		"symbol":     symbol,
+		"resolution": resolution,
+		"from":       fromKey,
+		"to":         toKey,
+		"bars":       bars,
+		"count":      len(bars),
+	}
+	
+	if redisCache != nil && len(bars) > 0 {
+		if err := redisCache.SetPriceSeries(c.Request.Context(), symbol, string(resolution), fromKey, toKey, series, 55*time.Minute); err != nil {
+			h.logger.Warn("Failed to cache price series", "symbol", symbol, "error", err)
+		}
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data":    stock,
+		"data":    series,
+	})
+}
+
+// GetStockFundamentals returns a stock's company fundamentals. Old data is still
+// served, flagged stale, since fundamentals are refreshed one stock per day.
+func (h *DatabaseStockHandler) GetStockFundamentals(c *gin.Context) {
+	symbol := c.Param("symbol")
+	
+	fundamentals, err := h.stockService.GetCompanyFundamentals(c.Request.Context(), symbol)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, http.StatusNotFound, CodeNotFound, errors.New("No fundamentals available for this stock"))
+		return
+	}
+	if err != nil {
+		respondServiceError(c, "Failed to fetch fundamentals", err)
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"data":       fundamentals,
+		"fetched_at": fundamentals.FetchedAt,
+		"stale":      time.Since(fundamentals.FetchedAt) > services.FundamentalsStaleAfter,
 	})
 }
 
@@ -123,14 +615,19 @@ func (h *DatabaseStockHandler) GetStockBySymbol(c *gin.Context) {
 func (h *DatabaseStockHandler) GetStocksByPriceRange(c *gin.Context) {
 	priceRange := c.Query("range")
 	if priceRange == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "Price range parameter is required",
-		})
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Price range parameter is required"))
 		return
 	}
 	
-	stocks := h.stockService.GetStocksByPriceRange(priceRange)
+	ranges := h.stockService.GetPriceRanges()
+	if !ranges.IsValid(priceRange) {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Unknown price range", gin.H{
+			"valid_ranges": ranges.Labels(),
+		}))
+		return
+	}
+	
+	stocks := h.stockService.GetStocksByPriceRange(c.Request.Context(), priceRange)
 	
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -141,7 +638,7 @@ func (h *DatabaseStockHandler) GetStocksByPriceRange(c *gin.Context) {
 
 // GetSectors returns all unique sectors
 func (h *DatabaseStockHandler) GetSectors(c *gin.Context) {
-	stocks := h.stockService.GetAllStocks()
+	stocks := h.stockService.GetAllStocks(c.Request.Context())
 	sectorMap := make(map[string]int)
 	
 	for _, stock := range stocks {
@@ -170,111 +667,133 @@ func (h *DatabaseStockHandler) GetSectors(c *gin.Context) {
 	})
 }
 
-// GetMarketOverview returns market overview statistics
-func (h *DatabaseStockHandler) GetMarketOverview(c *gin.Context) {
-	stocks := h.stockService.GetAllStocks()
-	
-	totalStocks := len(stocks)
-	advancing := 0
-	declining := 0
-	unchanged := 0
-	totalChange := 0.0
-	
-	for _, stock := range stocks {
-		if stock.ChangePercent > 0.01 {
-			advancing++
-		} else if stock.ChangePercent < -0.01 {
-			declining++
-		} else {
-			unchanged++
-		}
-		totalChange += stock.ChangePercent
+// GetMarketFilters returns the sectors, price ranges and exchanges the active
+// stocks have, the values worth offering as stock list filters
+func (h *DatabaseStockHandler) GetMarketFilters(c *gin.Context) {
+	filters, err := h.stockService.GetMarketFilters(c.Request.Context())
+	if err != nil {
+		respondServiceError(c, "Failed to load market filters", err)
+		return
 	}
 	
-	avgChange := 0.0
-	if totalStocks > 0 {
-		avgChange = totalChange / float64(totalStocks)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    filters,
+	})
+}
+
+// GetMarketOverview returns market overview statistics
+func (h *DatabaseStockHandler) GetMarketOverview(c *gin.Context) {
+	// Implausible moves are left out of the aggregates but reported in diagnostics
+	overview, err := h.stockService.GetMarketOverviewAggregate(c.Request.Context())
+	if err != nil {
+		respondServiceError(c, "Failed to compute market overview", err)
+		return
 	}
 	
-	overview := map[string]interface{}{
-		"total_stocks":    totalStocks,
-		"advancing_count": advancing,
-		"declining_count": declining,
-		"unchanged_count": unchanged,
-		"avg_change":      avgChange,
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    overview,
+	})
+}
+
+// GetMarketHeatmap returns the active stocks grouped by sector, sized by
+// market cap and colored by change, for the dashboard's treemap
+func (h *DatabaseStockHandler) GetMarketHeatmap(c *gin.Context) {
+	heatmap, err := h.stockService.GetMarketHeatmap(c.Request.Context())
+	if err != nil {
+		respondServiceError(c, "Failed to compute market heatmap", err)
+		return
 	}
 	
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data":    overview,
+		"data":    heatmap,
 	})
 }
 
 // GetPerformanceData returns performance categories
 func (h *DatabaseStockHandler) GetPerformanceData(c *gin.Context) {
-	stocks := h.stockService.GetAllStocks()
-	
-	if len(stocks) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"data": gin.H{
-				"top_gainers": []models.Stock{},
-				"top_losers":  []models.Stock{},
-				"most_active": []models.Stock{},
-			},
-		})
+	// Implausible moves would top every list; rank without them and report them instead
+	movers, err := h.stockService.GetTopMovers(c.Request.Context(), services.TopMoversLimit)
+	if err != nil {
+		respondServiceError(c, "Failed to rank stocks", err)
 		return
 	}
 	
-	// Sort for top gainers (highest change percent)
-	topGainers := make([]models.Stock, len(stocks))
-	copy(topGainers, stocks)
-	for i := 0; i < len(topGainers)-1; i++ {
-		for j := i + 1; j < len(topGainers); j++ {
-			if topGainers[j].ChangePercent > topGainers[i].ChangePercent {
-				topGainers[i], topGainers[j] = topGainers[j], topGainers[i]
-			}
-		}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    movers,
+	})
+}
+
+// GetMarketMovers ranks stocks by their return over a lookback window
+func (h *DatabaseStockHandler) GetMarketMovers(c *gin.Context) {
+	window := c.DefaultQuery("window", defaultMoverWindow)
+	movers, err := h.stockService.GetWindowMovers(c.Request.Context(), window)
+	if errors.Is(err, services.ErrUnknownMoverWindow) {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Unsupported window", gin.H{
+			"valid_windows": services.MoverWindows,
+		}))
+		return
 	}
-	if len(topGainers) > 10 {
-		topGainers = topGainers[:10]
+	if err != nil {
+		respondServiceError(c, "Failed to rank market movers", err)
+		return
 	}
 	
-	// Sort for top losers (lowest change percent)
-	topLosers := make([]models.Stock, len(stocks))
-	copy(topLosers, stocks)
-	for i := 0; i < len(topLosers)-1; i++ {
-		for j := i + 1; j < len(topLosers); j++ {
-			if topLosers[j].ChangePercent < topLosers[i].ChangePercent {
-				topLosers[i], topLosers[j] = topLosers[j], topLosers[i]
-			}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    movers,
+	})
+}
+
+// GetVolumeSpikes lists the stocks trading at min_ratio times their average
+// volume or more
+func (h *DatabaseStockHandler) GetVolumeSpikes(c *gin.Context) {
+	minRatio := services.DefaultVolumeSpikeRatio
+	if value := c.Query("min_ratio"); value != "" {
+		var err error
+		if minRatio, err = strconv.ParseFloat(value, 64); err != nil || !(minRatio > 0) || math.IsInf(minRatio, 0) {
+			respondError(c, http.StatusBadRequest, CodeValidation, errors.New("min_ratio must be a positive number"))
+			return
 		}
 	}
-	if len(topLosers) > 10 {
-		topLosers = topLosers[:10]
+	
+	spikes, err := h.stockService.GetVolumeSpikes(c.Request.Context(), minRatio)
+	if err != nil {
+		respondServiceError(c, "Failed to find volume spikes", err)
+		return
 	}
 	
-	// Sort for most active (highest volume)
-	mostActive := make([]models.Stock, len(stocks))
-	copy(mostActive, stocks)
-	for i := 0; i < len(mostActive)-1; i++ {
-		for j := i + 1; j < len(mostActive); j++ {
-			if mostActive[j].Volume > mostActive[i].Volume {
-				mostActive[i], mostActive[j] = mostActive[j], mostActive[i]
-			}
-		}
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"data":      spikes,
+		"count":     len(spikes),
+		"min_ratio": minRatio,
+	})
+}
+
+// GetDividendLeaders ranks stocks that paid a dividend in the past year by
+// their trailing twelve month yield
+func (h *DatabaseStockHandler) GetDividendLeaders(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultDividendLeadersLimit)))
+	if err != nil || limit <= 0 {
+		limit = defaultDividendLeadersLimit
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
 	}
-	if len(mostActive) > 10 {
-		mostActive = mostActive[:10]
+	
+	leaders, err := h.stockService.GetDividendLeaders(c.Request.Context(), limit)
+	if err != nil {
+		respondServiceError(c, "Failed to rank dividend yields", err)
+		return
 	}
 	
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data": gin.H{
-			"top_gainers": topGainers,
-			"top_losers":  topLosers,
-			"most_active": mostActive,
-		},
+		"data":    leaders,
 	})
 }
 
@@ -286,7 +805,7 @@ func (h *DatabaseStockHandler) GetDataSourceInfo(c *gin.Context) {
 			"primary_source":   "Local Database",
 			"fallback_source":  "Generated Data",
 			"last_updated":     "Real-time",
-			"total_stocks":     len(h.stockService.GetAllStocks()),
+			"total_stocks":     len(h.stockService.GetAllStocks(c.Request.Context())),
 			"data_freshness":   "Live",
 			"api_integration": []string{"Alpha Vantage (Historical)", "Local Generation (Real-time)"},
 		},
@@ -295,45 +814,54 @@ func (h *DatabaseStockHandler) GetDataSourceInfo(c *gin.Context) {
 
 // GetStockHistoricalPerformance returns historical performance data for a specific stock
 func (h *DatabaseStockHandler) GetStockHistoricalPerformance(c *gin.Context) {
-	symbol := c.Param("symbol")
-	if symbol == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "Symbol parameter is required",
-		})
+	symbol, err := models.NormalizeSymbol(c.Param("symbol"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock symbol", err.Error()))
 		return
 	}
 	
 	// Get days parameter (default to 30 for mini charts)
-	daysStr := c.DefaultQuery("days", "30")
+	daysStr := c.DefaultQuery("days", strconv.Itoa(defaultPerformanceDays))
 	days, err := strconv.Atoi(daysStr)
 	if err != nil || days <= 0 {
-		days = 30
+		days = defaultPerformanceDays
+	}
+	if days > maxPerformanceDays {
+		days = maxPerformanceDays
 	}
-	if days > 365 {
-		days = 365 // Maximum 1 year
+	
+	// Adjusted prices stay continuous across splits; raw closes show the split as a gap
+	adjusted, err := strconv.ParseBool(c.DefaultQuery("adjusted", "false"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("adjusted must be true or false"))
+		return
 	}
 	
-	// Query recent daily prices from database
-	query := `
-		SELECT dp.date, dp.close_price, dp.volume
-		FROM daily_prices dp
-		JOIN stocks s ON dp.stock_id = s.id
-		WHERE s.symbol = $1
-		ORDER BY dp.date DESC
-		LIMIT $2
-	`
+	// Serve from cache when available; entries are dropped whenever a sync invalidates the cache
+	var redisCache *cache.RedisCache
+	if cached, ok := h.prices.(cacheHolder); ok {
+		redisCache = cached.GetCache()
+	}
+	if redisCache != nil {
+		var cached map[string]interface{}
+		getCached := redisCache.GetHistoricalData
+		if adjusted {
+			getCached = redisCache.GetAdjustedHistoricalData
+		}
+		if err := getCached(c.Request.Context(), symbol, days, &cached); err == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": true,
+				"data":    cached,
+			})
+			return
+		}
+	}
 	
-	rows, err := h.stockService.GetDB().Query(query, symbol, days)
+	history, err := h.prices.GetPriceHistory(c.Request.Context(), symbol, days, adjusted)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "Failed to fetch historical data",
-			"details": err.Error(),
-		})
+		respondServiceError(c, "Failed to fetch historical data", err)
 		return
 	}
-	defer rows.Close()
 	
 	type DataPoint struct {
 		Date   string  `json:"date"`
@@ -342,29 +870,14 @@ func (h *DatabaseStockHandler) GetStockHistoricalPerformance(c *gin.Context) {
 	}
 	
 	var dataPoints []DataPoint
-	for rows.Next() {
-		var date time.Time
-		var price float64
-		var volume int64
-		
-		err := rows.Scan(&date, &price, &volume)
-		if err != nil {
-			continue // Skip invalid rows
-		}
-		
+	for _, point := range history {
 		dataPoints = append(dataPoints, DataPoint{
-			Date:   date.Format("2006-01-02"),
-			Price:  price,
-			Volume: volume,
+			Date:   point.Date.Format("2006-01-02"),
+			Price:  point.Price,
+			Volume: point.Volume,
 		})
 	}
 	
-	// Reverse to get chronological order (oldest first)
-	for i := 0; i < len(dataPoints)/2; i++ {
-		j := len(dataPoints) - 1 - i
-		dataPoints[i], dataPoints[j] = dataPoints[j], dataPoints[i]
-	}
-	
 	// Calculate performance metrics if we have data
 	totalReturn := 0.0
 	if len(dataPoints) > 1 {
@@ -375,17 +888,39 @@ func (h *DatabaseStockHandler) GetStockHistoricalPerformance(c *gin.Context) {
 		}
 	}
 	
+	// Metrics the series is too short for are null
+	metrics := services.ComputePerformanceMetrics(history)
+	
 	performance := map[string]interface{}{
 		"symbol":      symbol,
 		"timeframe":   fmt.Sprintf("%dD", days),
 		"data_points": dataPoints,
 		"count":       len(dataPoints),
+		"adjusted":    adjusted,
 		"performance_metrics": gin.H{
-			"total_return": totalReturn,
-			"data_quality": "real", // Indicate this is real data
+			"total_return":          totalReturn,
+			"annualized_volatility": metrics.AnnualizedVolatility,
+			"max_drawdown":          metrics.MaxDrawdown,
+			"max_drawdown_start":    metrics.MaxDrawdownStart,
+			"max_drawdown_end":      metrics.MaxDrawdownEnd,
+			"best_day":              metrics.BestDay,
+			"worst_day":             metrics.WorstDay,
+			"average_volume":        metrics.AverageVolume,
+			"data_quality":          "real", // Indicate this is real data
 		},
 	}
 	
+	// Cache until the next hourly sync, matching the other stock caches
+	if redisCache != nil && len(dataPoints) > 0 {
+		setCached := redisCache.SetHistoricalData
+		if adjusted {
+			setCached = redisCache.SetAdjustedHistoricalData
+		}
+		if err := setCached(c.Request.Context(), symbol, days, performance, 55*time.Minute); err != nil {
+			h.logger.Warn("Failed to cache historical data", "symbol", symbol, "error", err)
+		}
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    performance,
@@ -0,0 +1,1118 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/cache"
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var stockListColumns = []string{
+	"id", "symbol", "company_name", "sector", "industry", "market_cap",
+	"price_range", "exchange", "is_active", "created_at", "updated_at",
+	"first_price_date", "last_price_date",
+	"current_price", "daily_change", "change_percent", "volume", "last_updated", "change_period_days",
+}
+
+// newOutlierStockHandler serves a market where BRKX, with a corrupt previous
+// close, is beyond the change percent guard and left out of the aggregates
+func newOutlierStockHandler(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/market/overview", handler.GetMarketOverview)
+	router.GET("/market/performance", handler.GetPerformanceData)
+	return router, mock
+}
+
+// expectChangeOutliers expects the query listing stocks beyond the change percent guard
+func expectChangeOutliers(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("WHERE ABS\\(change_percent\\) > \\$1").WithArgs(services.DefaultMaxChangePercent).
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "change_percent", "current_price", "daily_change"}).
+			AddRow("BRKX", 499900.0, 50.0, 49.99))
+}
+
+func TestGetMarketOverview_ExcludesChangeOutliers(t *testing.T) {
+	router, mock := newOutlierStockHandler(t)
+	mock.ExpectQuery("FROM ranked").WithArgs(services.DefaultMaxChangePercent).
+		WillReturnRows(sqlmock.NewRows([]string{"total", "advancing", "declining", "unchanged", "avg"}).
+			AddRow(4, 2, 1, 0, 2.0/3.0))
+	expectChangeOutliers(mock)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/market/overview", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data struct {
+			TotalStocks   int                        `json:"total_stocks"`
+			Advancing     int                        `json:"advancing_count"`
+			Declining     int                        `json:"declining_count"`
+			ExcludedCount int                        `json:"excluded_count"`
+			AvgChange     float64                    `json:"avg_change"`
+			Diagnostics   services.ChangeDiagnostics `json:"diagnostics"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	data := response.Data
+	assert.Equal(t, 4, data.TotalStocks)
+	assert.Equal(t, 2, data.Advancing)
+	assert.Equal(t, 1, data.Declining)
+	assert.Equal(t, 1, data.ExcludedCount)
+	// Reported to two decimal places
+	assert.Equal(t, 0.67, data.AvgChange)
+
+	assert.Equal(t, services.DefaultMaxChangePercent, data.Diagnostics.MaxChangePercent)
+	require.Len(t, data.Diagnostics.ExcludedOutliers, 1)
+	assert.Equal(t, "BRKX", data.Diagnostics.ExcludedOutliers[0].Symbol)
+	assert.Equal(t, 499900.0, data.Diagnostics.ExcludedOutliers[0].ChangePercent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPerformanceData_ExcludesChangeOutliersFromRankings(t *testing.T) {
+	router, mock := newOutlierStockHandler(t)
+	now := time.Now()
+	mock.ExpectQuery("FROM ranked\\s+WHERE change_percent > 0").WithArgs(services.DefaultMaxChangePercent, services.TopMoversLimit).
+		WillReturnRows(sqlmock.NewRows(stockListColumns).
+			AddRow(1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(3000000000000),
+				"$100+", "NASDAQ", true, now, now, nil, nil, 150.0, 3.0, 2.0, int64(50000000), now, 1).
+			AddRow(4, "KO", "Coca-Cola Company", "Consumer Defensive", "Beverages", int64(260000000000),
+				"$50-$100", "NYSE", true, now, now, nil, nil, 60.0, 0.6, 1.0, int64(12000000), now, 1))
+	mock.ExpectQuery("FROM ranked\\s+WHERE change_percent < 0").WithArgs(services.DefaultMaxChangePercent, services.TopMoversLimit).
+		WillReturnRows(sqlmock.NewRows(stockListColumns).
+			AddRow(2, "MSFT", "Microsoft Corporation", "Technology", "Software", int64(2800000000000),
+				"$100+", "NASDAQ", true, now, now, nil, nil, 380.0, -3.8, -1.0, int64(30000000), now, 1))
+	mock.ExpectQuery("FROM volumes\\s+ORDER BY relative_volume DESC").WithArgs(services.DefaultMaxChangePercent, services.TopMoversLimit).
+		WillReturnRows(sqlmock.NewRows(append(stockListColumns, "avg_volume", "relative_volume")))
+	expectChangeOutliers(mock)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/market/performance", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data struct {
+			TopGainers  []struct{ Symbol string }  `json:"top_gainers"`
+			TopLosers   []struct{ Symbol string }  `json:"top_losers"`
+			Diagnostics services.ChangeDiagnostics `json:"diagnostics"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	require.NotEmpty(t, response.Data.TopGainers)
+	assert.Equal(t, "AAPL", response.Data.TopGainers[0].Symbol)
+	for _, stock := range append(response.Data.TopGainers, response.Data.TopLosers...) {
+		assert.NotEqual(t, "BRKX", stock.Symbol)
+	}
+	require.Len(t, response.Data.Diagnostics.ExcludedOutliers, 1)
+	assert.Equal(t, "BRKX", response.Data.Diagnostics.ExcludedOutliers[0].Symbol)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStockIntraday(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks/:symbol/intraday", handler.GetStockIntraday)
+
+	for _, path := range []string{"/stocks/AAPL/intraday?interval=2min", "/stocks/AAPL/intraday?date=05-01-2024"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+
+	open := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM intraday_prices").WithArgs("AAPL", "15min", "2024-05-01").
+		WillReturnRows(sqlmock.NewRows([]string{"timestamp", "open_price", "high_price", "low_price", "close_price", "volume"}).
+			AddRow(open, 169.58, 170.15, 169.11, 169.98, int64(3556202)).
+			AddRow(open.Add(15*time.Minute), 169.98, 170.4, 169.8, 170.22, int64(1802113)))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/AAPL/intraday?interval=15min&date=2024-05-01", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Interval string `json:"interval"`
+		Count    int    `json:"count"`
+		Data     []struct {
+			Timestamp time.Time `json:"timestamp"`
+			Close     float64   `json:"close"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "15min", response.Interval)
+	assert.Equal(t, 2, response.Count)
+	assert.Equal(t, 170.22, response.Data[1].Close)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStockFundamentals(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks/:symbol/fundamentals", handler.GetStockFundamentals)
+
+	columns := []string{"symbol", "pe_ratio", "eps", "dividend_yield", "beta", "shares_outstanding", "description", "fetched_at"}
+	mock.ExpectQuery("FROM company_fundamentals").WithArgs("NEW").
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/NEW/fundamentals", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Fetched long enough ago to be flagged, but still served
+	fetchedAt := time.Now().Add(-services.FundamentalsStaleAfter - time.Hour)
+	mock.ExpectQuery("FROM company_fundamentals").WithArgs("AMZN").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("AMZN", nil, nil, nil, 1.146, nil, "", fetchedAt))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/AMZN/fundamentals", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Stale bool `json:"stale"`
+		Data  struct {
+			PERatio *float64 `json:"pe_ratio"`
+			Beta    *float64 `json:"beta"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Stale)
+	assert.Nil(t, response.Data.PERatio)
+	require.NotNil(t, response.Data.Beta)
+	assert.Equal(t, 1.146, *response.Data.Beta)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllStocks_MinHistoryDays(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks", handler.GetAllStocks)
+
+	for _, path := range []string{"/stocks?min_history_days=year", "/stocks?min_history_days=-1"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+
+	// The filter is applied in SQL to both the count and the page
+	mock.ExpectQuery(`first_price_date >= \$1`).WithArgs(365).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`first_price_date >= \$1`).WithArgs(365, 50, 0).
+		WillReturnRows(sqlmock.NewRows(stockListColumns))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks?min_history_days=365", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllStocks_MarketCapAndExchangeFilters(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks", handler.GetAllStocks)
+
+	for _, path := range []string{"/stocks?market_cap=mega", "/stocks?exchange=LSE", "/stocks?price_range=cheap"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusBadRequest, w.Code, path)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, string(CodeValidation), responseError(t, response)["code"], path)
+	}
+
+	// With a sector, the band and exchange still go into the one paginated query
+	mock.ExpectQuery(`GROUP BY 1, 2, 3`).WillReturnRows(marketFilterRows([]string{"Technology", "NYSE", "$100-150"}))
+	mock.ExpectQuery(`s.sector = \$1 AND s.market_cap >= \$2 AND s.exchange = \$3`).
+		WithArgs("Technology", int64(10000000000), "NYSE").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`s.sector = \$1 AND s.market_cap >= \$2 AND s.exchange = \$3`).
+		WithArgs("Technology", int64(10000000000), "NYSE", 50, 0).
+		WillReturnRows(sqlmock.NewRows(stockListColumns))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks?sector=Technology&market_cap=large&exchange=nyse", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// marketFilterRows is the market filters query's result: rows of sector, exchange and price range
+func marketFilterRows(rows ...[]string) *sqlmock.Rows {
+	result := sqlmock.NewRows([]string{"sector", "exchange", "price_range"})
+	for _, row := range rows {
+		result.AddRow(row[0], row[1], row[2])
+	}
+	return result
+}
+
+func TestGetAllStocks_RejectsUnknownSector(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache("redis://"+server.Addr(), "test:")
+	require.NoError(t, err)
+	defer redisCache.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, redisCache)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks", handler.GetAllStocks)
+
+	mock.ExpectQuery(`GROUP BY 1, 2, 3`).WillReturnRows(marketFilterRows(
+		[]string{"Technology", "NASDAQ", "$100-150"},
+		[]string{"Energy", "NYSE", "$50-100"},
+	))
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks?sector=Tech", nil))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		envelope := responseError(t, response)
+		assert.Equal(t, string(CodeValidation), envelope["code"])
+		assert.Equal(t, "Unknown sector", envelope["message"])
+		assert.Equal(t, map[string]interface{}{"valid_sectors": []interface{}{"Energy", "Technology"}}, envelope["details"])
+	}
+	// The second request was checked against the cached sectors
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Invalidation, as after a sync adds stocks, refreshes them
+	require.NoError(t, redisCache.InvalidateAll(context.Background()))
+	mock.ExpectQuery(`GROUP BY 1, 2, 3`).WillReturnRows(marketFilterRows([]string{"Tech", "NASDAQ", "$100-150"}))
+	mock.ExpectQuery(`WHERE s.is_active = true AND s.sector = \$1`).WithArgs("Tech").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks?sector=Tech", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMarketFilters(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/market/filters", handler.GetMarketFilters)
+
+	mock.ExpectQuery(`GROUP BY 1, 2, 3`).WillReturnRows(marketFilterRows(
+		[]string{"Technology", "NASDAQ", "$150+"},
+		[]string{"Technology", "NASDAQ", "$0-10"},
+		[]string{"Energy", "NYSE", "$50-100"},
+	))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/market/filters", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data services.MarketFilters `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, services.MarketFilters{
+		Sectors:     []string{"Energy", "Technology"},
+		PriceRanges: []string{"$0-10", "$50-100", "$150+"},
+		Exchanges:   []string{"NASDAQ", "NYSE"},
+	}, response.Data)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllStocks_QueryTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	service.SetQueryTimeout(20 * time.Millisecond)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks", handler.GetAllStocks)
+	router.GET("/market/overview", handler.GetMarketOverview)
+
+	mock.ExpectQuery(`SELECT COUNT\(DISTINCT s.id\)`).WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(500))
+	mock.ExpectQuery(`FROM ranked`).WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"total", "advancing", "declining", "unchanged", "avg"}))
+
+	for _, path := range []string{"/stocks", "/market/overview"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusGatewayTimeout, w.Code, path)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		envelope := responseError(t, response)
+		assert.Equal(t, string(CodeTimeout), envelope["code"], path)
+		assert.Equal(t, "Database query timed out", envelope["message"], path)
+	}
+}
+
+func TestGetStockHistoricalPerformance_Adjusted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks/:symbol/performance", handler.GetStockHistoricalPerformance)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/AAPL/performance?adjusted=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Across the 2020-08-31 4:1 split, adjusted closes carry no gap
+	mock.ExpectQuery(`SELECT dp.date, dp.adjusted_close, dp.volume`).WithArgs("AAPL", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"date", "adjusted_close", "volume"}).
+			AddRow(time.Date(2020, 8, 31, 0, 0, 0, 0, time.UTC), 129.04, int64(225702700)).
+			AddRow(time.Date(2020, 8, 28, 0, 0, 0, 0, time.UTC), 124.8075, int64(46907479)))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/AAPL/performance?days=2&adjusted=true", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data struct {
+			Adjusted   bool `json:"adjusted"`
+			DataPoints []struct {
+				Price float64 `json:"price"`
+			} `json:"data_points"`
+			Metrics map[string]interface{} `json:"performance_metrics"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Data.Adjusted)
+	require.Len(t, response.Data.DataPoints, 2)
+	assert.Equal(t, 124.8075, response.Data.DataPoints[0].Price)
+
+	// One return is too few for a volatility, which is null rather than NaN
+	metrics := response.Data.Metrics
+	assert.Contains(t, metrics, "annualized_volatility")
+	assert.Nil(t, metrics["annualized_volatility"])
+	assert.Equal(t, "2020-08-31", metrics["best_day"].(map[string]interface{})["date"])
+	assert.InDelta(t, (225702700+46907479)/2.0, metrics["average_volume"], 0.5)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Raw closes are still the default, and the symbol is looked up uppercased
+	mock.ExpectQuery(`SELECT dp.date, dp.close_price, dp.volume`).WithArgs("AAPL", 30).
+		WillReturnRows(sqlmock.NewRows([]string{"date", "close_price", "volume"}))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/aapl/performance", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectNoDividends expects the dividends lookup of a stock that never paid one
+func expectNoDividends(mock sqlmock.Sqlmock, symbol string) {
+	mock.ExpectQuery("LEFT JOIN dividends").WithArgs(symbol).
+		WillReturnRows(sqlmock.NewRows([]string{"ex_date", "amount"}).AddRow(nil, nil))
+}
+
+func TestGetStockBySymbol_UnsyncedStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks/:symbol", handler.GetStockBySymbol)
+
+	stockColumns := stockListColumns[:13]
+	mock.ExpectQuery("FROM stocks s").WithArgs("TSLA").WillReturnRows(sqlmock.NewRows(stockColumns).
+		AddRow(2, "TSLA", "Tesla, Inc.", "Consumer Cyclical", "Auto Manufacturers", nil,
+			"$200+", "NASDAQ", true, time.Now(), time.Now(), nil, nil))
+	mock.ExpectQuery("FROM daily_prices").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"close_price", "volume", "date", "daily_change", "change_percent", "change_period_days"}))
+	expectNoDividends(mock, "TSLA")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/TSLA", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "TSLA", response.Data["symbol"])
+	assert.Equal(t, false, response.Data["has_price_data"])
+	assert.Equal(t, 0.0, response.Data["current_price"])
+	assert.Contains(t, response.Data, "dividend_yield_ttm")
+	assert.Nil(t, response.Data["dividend_yield_ttm"])
+	assert.Equal(t, false, response.Data["delisted"])
+
+	// Only a symbol missing from the stocks table is a 404
+	mock.ExpectQuery("FROM stocks s").WithArgs("ZZZZ").WillReturnRows(sqlmock.NewRows(stockColumns))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/ZZZZ", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStockBySymbol_DelistedStockKeepsHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks/:symbol", handler.GetStockBySymbol)
+
+	lastClose := time.Date(2022, 10, 27, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`WHERE s.symbol = \$1 AND \(s.is_active = true OR s.delisted_at IS NOT NULL\)`).WithArgs("TWTR").
+		WillReturnRows(sqlmock.NewRows(stockListColumns[:13]).
+			AddRow(3, "TWTR", "Twitter, Inc.", "Communication Services", "Internet Content", nil,
+				"$50-100", "NYSE", false, time.Now(), time.Now(), nil, lastClose))
+	mock.ExpectQuery("FROM daily_prices").WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"close_price", "volume", "date", "daily_change", "change_percent", "change_period_days"}).
+			AddRow(53.70, int64(0), lastClose, 0.0, 0.0, 1))
+	expectNoDividends(mock, "TWTR")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/TWTR", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, true, response.Data["delisted"])
+	assert.Equal(t, false, response.Data["is_active"])
+	assert.Equal(t, 53.70, response.Data["current_price"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStockBySymbol_NormalizesSymbol(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks/:symbol", handler.GetStockBySymbol)
+
+	for path, stored := range map[string]string{"/stocks/aapl": "AAPL", "/stocks/brk-b": "BRK.B"} {
+		mock.ExpectQuery("FROM stocks s").WithArgs(stored).WillReturnRows(sqlmock.NewRows(stockListColumns[:13]).
+			AddRow(1, stored, "Company", "Sector", "Industry", nil, "$100+", "NYSE", true, time.Now(), time.Now(), nil, nil))
+		mock.ExpectQuery("FROM daily_prices").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"close_price", "volume", "date", "daily_change", "change_percent", "change_period_days"}))
+		expectNoDividends(mock, stored)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+
+	// Rejected before any query runs
+	for _, path := range []string{"/stocks/AAPL';DROP%20TABLE%20stocks;--", "/stocks/ABCDEFGHIJK", "/stocks/AAPL%20OR%201=1"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func newQuotesRouter(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.POST("/stocks/quotes", handler.GetQuotes)
+	return router, mock
+}
+
+func postQuotes(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/stocks/quotes", strings.NewReader(body)))
+	return w
+}
+
+func TestGetQuotes_ReportsUnknownSymbols(t *testing.T) {
+	router, mock := newQuotesRouter(t)
+
+	// One query for the whole set, deduplicated and sorted
+	quoteColumns := []string{"symbol", "company_name", "close_price", "daily_change", "change_percent", "volume", "last_updated"}
+	mock.ExpectQuery("WHERE s.symbol = ANY\\(\\$1\\)").WithArgs(pq.Array([]string{"AAPL", "MSFT", "XYZ"})).
+		WillReturnRows(sqlmock.NewRows(quoteColumns).
+			AddRow("AAPL", "Apple Inc.", 190.0, 2.0, 1.0638, int64(52000000), time.Now()).
+			AddRow("MSFT", "Microsoft Corporation", nil, nil, nil, int64(0), time.Now()))
+
+	w := postQuotes(router, `{"symbols": ["msft", "XYZ", "AAPL", "aapl"]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data     []services.StockQuote `json:"data"`
+		NotFound []string              `json:"not_found"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Data, 2)
+	assert.Equal(t, "AAPL", response.Data[0].Symbol)
+	assert.Equal(t, 190.0, response.Data[0].CurrentPrice)
+	assert.Equal(t, 2.0, response.Data[0].Change)
+	assert.Equal(t, int64(52000000), response.Data[0].Volume)
+	assert.False(t, response.Data[1].HasPriceData, "a stock with no prices is still found")
+	assert.Equal(t, []string{"XYZ"}, response.NotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetQuotes_RejectsBadRequests(t *testing.T) {
+	router, mock := newQuotesRouter(t)
+
+	tooMany := make([]string, services.MaxQuoteSymbols+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("S%d", i)
+	}
+	body, err := json.Marshal(map[string][]string{"symbols": tooMany})
+	require.NoError(t, err)
+
+	for name, body := range map[string]string{
+		"empty list":     `{"symbols": []}`,
+		"no list":        `{}`,
+		"not JSON":       `AAPL,MSFT`,
+		"over the cap":   string(body),
+		"invalid symbol": `{"symbols": ["AAPL", "AA PL"]}`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, postQuotes(router, body).Code, name)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "rejected before any query runs")
+}
+
+func TestGetMarketMovers_Window(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/market/movers", handler.GetMarketMovers)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/market/movers?window=2w", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var failure map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &failure))
+	envelope := responseError(t, failure)
+	assert.Equal(t, string(CodeValidation), envelope["code"])
+	assert.Equal(t, []interface{}{"1d", "7d", "30d", "90d"}, envelope["details"].(map[string]interface{})["valid_windows"])
+
+	// Without a window, movers are ranked over the last trading day
+	asOf := time.Date(2026, time.March, 31, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`window_start`).WithArgs(1, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "company_name", "sector", "as_of",
+			"start_date", "start_price", "end_date", "end_price", "volume"}).
+			AddRow("AAPL", "Apple Inc.", "Technology", asOf, asOf.AddDate(0, 0, -1), 100.0, asOf, 102.0, int64(500)).
+			AddRow("NEWCO", "New Co", "", asOf, nil, nil, asOf, 10.0, int64(0)))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/market/movers", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data services.WindowMovers `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "1d", response.Data.Window)
+	assert.Equal(t, 1, response.Data.Excluded)
+	require.Len(t, response.Data.TopGainers, 1)
+	assert.InDelta(t, 2.0, response.Data.TopGainers[0].ChangePercent, 1e-9)
+	assert.Empty(t, response.Data.TopLosers)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetVolumeSpikes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/market/volume-spikes", handler.GetVolumeSpikes)
+
+	for _, ratio := range []string{"abc", "0", "-2", "NaN", "Inf"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/market/volume-spikes?min_ratio="+ratio, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, ratio)
+	}
+
+	now := time.Now()
+	mock.ExpectQuery(`WHERE relative_volume >= \$1`).WithArgs(2.5).
+		WillReturnRows(sqlmock.NewRows(append(stockListColumns, "avg_volume", "relative_volume")).
+			AddRow(1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", nil,
+				"$100+", "NASDAQ", true, now, now, nil, nil, 150.0, 3.0, 2.0, int64(3000000), now, 1, 1000000.0, 3.0))
+
+	code, body := serveSyncRequest(t, router, http.MethodGet, "/market/volume-spikes?min_ratio=2.5")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2.5, body["min_ratio"])
+	assert.Equal(t, float64(1), body["count"])
+	spike := body["data"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "AAPL", spike["symbol"])
+	assert.Equal(t, float64(1000000), spike["avg_volume_30d"])
+	assert.Equal(t, 3.0, spike["relative_volume"])
+	assert.Equal(t, false, spike["ranked_by_volume"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetCorrelation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks/correlation", handler.GetCorrelation)
+
+	// Rejected before any query runs
+	for _, query := range []string{
+		"symbols=AAPL",
+		"symbols=AAPL,aapl",
+		"symbols=A,B,C,D,E,F,G,H,I,J,K",
+		"symbols=AAPL,MSFT&days=0",
+		"symbols=AAPL,MSFT&days=366",
+		"symbols=AAPL,DROP%20TABLE",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/correlation?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	// Adjusted closes, so splits do not read as crashes; KO has no stored prices
+	closes := func(start float64) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"date", "adjusted_close", "volume"})
+		for i := 0; i < 40; i++ {
+			day := time.Date(2026, time.March, 31, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -i)
+			rows.AddRow(day, start+float64(i%3)-float64(i)/10, int64(1000))
+		}
+		return rows
+	}
+	mock.ExpectQuery(`SELECT dp.date, dp.adjusted_close, dp.volume`).WithArgs("AAPL", 60).WillReturnRows(closes(100))
+	mock.ExpectQuery(`SELECT dp.date, dp.adjusted_close, dp.volume`).WithArgs("MSFT", 60).WillReturnRows(closes(200))
+	mock.ExpectQuery(`SELECT dp.date, dp.adjusted_close, dp.volume`).WithArgs("KO", 60).
+		WillReturnRows(sqlmock.NewRows([]string{"date", "adjusted_close", "volume"}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/correlation?symbols=aapl,%20MSFT,KO&days=60", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data struct {
+			Days        int                        `json:"days"`
+			Correlation services.CorrelationMatrix `json:"correlation"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	matrix := response.Data.Correlation
+	assert.Equal(t, 60, response.Data.Days)
+	assert.Equal(t, []string{"AAPL", "MSFT", "KO"}, matrix.Symbols)
+	require.Len(t, matrix.Pairs, 3)
+	require.NotNil(t, matrix.Pairs[0].Correlation)
+	assert.Equal(t, 39, matrix.Pairs[0].Observations)
+	assert.Nil(t, matrix.Pairs[1].Correlation)
+	assert.Equal(t, services.ReasonInsufficientOverlap, matrix.Pairs[1].Reason)
+	assert.Nil(t, matrix.Matrix[2][0])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStockBeta(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks/:symbol/beta", handler.GetStockBeta)
+
+	for _, path := range []string{"/stocks/AAPL/beta?days=0", "/stocks/AAPL/beta?benchmark=S%26P"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+
+	// An unsynced benchmark is the operator's to fix
+	mock.ExpectQuery(`SELECT dp.date, dp.adjusted_close, dp.volume`).WithArgs("SPY", defaultBetaDays).
+		WillReturnRows(sqlmock.NewRows([]string{"date", "adjusted_close", "volume"}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/AAPL/beta", nil))
+	require.Equal(t, http.StatusConflict, w.Code)
+	var failure map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &failure))
+	envelope := responseError(t, failure)
+	assert.Equal(t, string(CodeConflict), envelope["code"])
+	assert.Contains(t, envelope["message"], "sync")
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// A stock measured against itself has a beta of 1
+	closes := func() *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"date", "adjusted_close", "volume"})
+		for i := 0; i < 40; i++ {
+			rows.AddRow(time.Date(2026, time.March, 31, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -i), 100+float64(i%4), int64(1000))
+		}
+		return rows
+	}
+	mock.ExpectQuery(`SELECT dp.date, dp.adjusted_close, dp.volume`).WithArgs("QQQ", 60).WillReturnRows(closes())
+	mock.ExpectQuery(`SELECT dp.date, dp.adjusted_close, dp.volume`).WithArgs("QQQ", 60).WillReturnRows(closes())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/qqq/beta?benchmark=qqq&days=60", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data struct {
+			Symbol    string                `json:"symbol"`
+			Benchmark string                `json:"benchmark"`
+			Estimate  services.BetaEstimate `json:"estimate"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "QQQ", response.Data.Benchmark)
+	assert.Equal(t, 39, response.Data.Estimate.Observations)
+	require.NotNil(t, response.Data.Estimate.Beta)
+	assert.InDelta(t, 1, *response.Data.Estimate.Beta, 1e-9)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStockDividends(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks/:symbol", handler.GetStockBySymbol)
+	router.GET("/stocks/:symbol/dividends", handler.GetStockDividends)
+
+	priceDate := time.Date(2026, time.March, 31, 0, 0, 0, 0, time.UTC)
+	dividends := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"ex_date", "amount"}).
+			AddRow(priceDate.AddDate(0, -1, 0), 0.51).
+			AddRow(priceDate.AddDate(0, -4, 0), 0.51)
+	}
+
+	// The detail view's yield covers the year up to the latest close
+	mock.ExpectQuery("FROM stocks s").WithArgs("KO").WillReturnRows(sqlmock.NewRows(stockListColumns[:13]).
+		AddRow(4, "KO", "Coca-Cola Company", "Consumer Defensive", "Beverages", nil, "$50-$100", "NYSE", true, time.Now(), time.Now(), nil, nil))
+	mock.ExpectQuery("FROM daily_prices").WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"close_price", "volume", "date", "daily_change", "change_percent", "change_period_days"}).
+			AddRow(51.0, int64(12000000), priceDate, 0.5, 1.0, 1))
+	mock.ExpectQuery("LEFT JOIN dividends").WithArgs("KO").WillReturnRows(dividends())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/KO", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var detail struct {
+		Data struct {
+			Symbol           string   `json:"symbol"`
+			DividendYieldTTM *float64 `json:"dividend_yield_ttm"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	assert.Equal(t, "KO", detail.Data.Symbol)
+	require.NotNil(t, detail.Data.DividendYieldTTM)
+	assert.InDelta(t, 2.0, *detail.Data.DividendYieldTTM, 1e-9)
+
+	mock.ExpectQuery("LEFT JOIN dividends").WithArgs("KO").WillReturnRows(dividends())
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/ko/dividends", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Data struct {
+			Dividends []services.Dividend `json:"dividends"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Data.Dividends, 2)
+	assert.Equal(t, 0.51, list.Data.Dividends[0].Amount)
+
+	mock.ExpectQuery("LEFT JOIN dividends").WithArgs("ZZZZ").WillReturnRows(sqlmock.NewRows([]string{"ex_date", "amount"}))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/ZZZZ/dividends", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStockNews(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks/:symbol/news", handler.GetStockNews)
+
+	columns := []string{"title", "url", "source", "published_at", "sentiment_score", "sentiment_label"}
+	published := time.Date(2024, time.January, 4, 21, 30, 0, 0, time.UTC)
+	mock.ExpectQuery("LEFT JOIN LATERAL").WithArgs("AAPL", 2).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("Apple Shares Slip", "https://www.example.com/a", "Benzinga", published, -0.3, "Somewhat-Bearish").
+		AddRow("Tech Stocks To Watch", "https://www.example.com/b", "Motley Fool", published.Add(-time.Hour), 0.2, "Somewhat-Bullish"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/aapl/news?limit=2", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data struct {
+			Articles         []services.NewsArticle `json:"articles"`
+			Count            int                    `json:"count"`
+			AverageSentiment *float64               `json:"average_sentiment"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Data.Count)
+	require.NotNil(t, response.Data.AverageSentiment)
+	assert.InDelta(t, -0.05, *response.Data.AverageSentiment, 1e-9)
+
+	// Without articles there is no average
+	mock.ExpectQuery("LEFT JOIN LATERAL").WithArgs("TSLA", defaultNewsLimit).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(nil, nil, nil, nil, nil, nil))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/TSLA/news", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"average_sentiment":null`)
+
+	mock.ExpectQuery("LEFT JOIN LATERAL").WithArgs("ZZZZ", defaultNewsLimit).WillReturnRows(sqlmock.NewRows(columns))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/ZZZZ/news", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStockPrices(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache("redis://"+server.Addr(), "test:")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, redisCache)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks/:symbol/prices", handler.GetStockPrices)
+
+	for _, query := range []string{"resolution=hourly", "from=2025-1-6", "from=2025-02-01&to=2025-01-01"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/AAPL/prices?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	// Only the first request reaches the database; the second is served from the cache
+	columns := []string{"period_start", "open_price", "high_price", "low_price", "close_price", "volume", "trading_days"}
+	mock.ExpectQuery("LEFT JOIN LATERAL").WithArgs("AAPL", "month", "2025-01-01", "2025-03-15").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC), 243.0, 250.0, 220.0, 236.0, int64(1000), 21).
+			AddRow(time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), 241.0, 244.0, 208.0, 213.5, int64(500), 10))
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/aapl/prices?resolution=monthly&from=2025-01-01&to=2025-03-15", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data struct {
+				Resolution string `json:"resolution"`
+				Bars       []struct {
+					PeriodEnd string  `json:"period_end"`
+					Close     float64 `json:"close"`
+					Partial   bool    `json:"partial"`
+				} `json:"bars"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "monthly", response.Data.Resolution)
+		// February had no trading days in range; March is cut by to
+		require.Len(t, response.Data.Bars, 2)
+		assert.False(t, response.Data.Bars[0].Partial)
+		assert.True(t, response.Data.Bars[1].Partial)
+		assert.Equal(t, "2025-03-31T00:00:00Z", response.Data.Bars[1].PeriodEnd)
+	}
+	assert.True(t, server.Exists("test:"+cache.PriceSeriesKey("AAPL", "monthly", "2025-01-01", "2025-03-15")))
+
+	mock.ExpectQuery("LEFT JOIN LATERAL").WithArgs("ZZZZ", "day", nil, nil).WillReturnRows(sqlmock.NewRows(columns))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stocks/ZZZZ/prices", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllStocks_CursorPagesWalkTheList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks", handler.GetAllStocks)
+
+	// 120 stocks in list order: five without a market cap first, then caps
+	// largest first in tied pairs broken by symbol
+	type fixtureStock struct {
+		symbol    string
+		marketCap *int64
+	}
+	fixture := make([]fixtureStock, 120)
+	for i := range fixture {
+		if i < 5 {
+			fixture[i] = fixtureStock{symbol: fmt.Sprintf("N%03d", i)}
+			continue
+		}
+		marketCap := int64(120-i/2) * 1000000000
+		fixture[i] = fixtureStock{symbol: fmt.Sprintf("S%03d", i), marketCap: &marketCap}
+	}
+	now := time.Now()
+	rows := func(stocks []fixtureStock) *sqlmock.Rows {
+		result := sqlmock.NewRows(stockListColumns)
+		for i, stock := range stocks {
+			var marketCap interface{}
+			if stock.marketCap != nil {
+				marketCap = *stock.marketCap
+			}
+			result.AddRow(i+1, stock.symbol, stock.symbol, "Technology", "Software", marketCap,
+				"$50-$100", "NASDAQ", true, now, now, nil, nil, 75.0, 0.5, 0.7, int64(1000000), now, 1)
+		}
+		return result
+	}
+
+	// Each page asks for one stock more than the limit, after the previous page's last stock
+	mock.ExpectQuery(`ORDER BY s.market_cap DESC NULLS FIRST, s.symbol\s+LIMIT \$1`).WithArgs(51).
+		WillReturnRows(rows(fixture[0:51]))
+	mock.ExpectQuery(`\(s.market_cap < \$1 OR \(s.market_cap = \$2 AND s.symbol > \$3\)\)`).
+		WithArgs(*fixture[49].marketCap, *fixture[49].marketCap, fixture[49].symbol, 51).
+		WillReturnRows(rows(fixture[50:101]))
+	mock.ExpectQuery(`\(s.market_cap < \$1 OR \(s.market_cap = \$2 AND s.symbol > \$3\)\)`).
+		WithArgs(*fixture[99].marketCap, *fixture[99].marketCap, fixture[99].symbol, 51).
+		WillReturnRows(rows(fixture[100:120]))
+
+	var symbols []string
+	path := "/stocks?limit=50&cursor="
+	for page := 0; page < 3; page++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotContains(t, response, "total")
+		assert.NotContains(t, response, "has_more")
+		for _, stock := range response["data"].([]interface{}) {
+			symbols = append(symbols, stock.(map[string]interface{})["symbol"].(string))
+		}
+
+		if page < 2 {
+			require.IsType(t, "", response["next_cursor"], "page %d", page)
+			path = "/stocks?limit=50&cursor=" + response["next_cursor"].(string)
+		} else {
+			assert.Nil(t, response["next_cursor"], "the last page has no next cursor")
+		}
+	}
+
+	// Every stock exactly once, in list order
+	require.Len(t, symbols, len(fixture))
+	for i, stock := range fixture {
+		assert.Equal(t, stock.symbol, symbols[i])
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllStocks_RejectsBadCursors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks", handler.GetAllStocks)
+
+	marketCap := int64(1000000000)
+	valid := services.StockCursor{MarketCap: &marketCap, Symbol: "AAPL"}.Encode()
+	for _, path := range []string{
+		"/stocks?cursor=not-a-cursor!",
+		"/stocks?cursor=" + valid[:len(valid)-2],
+		"/stocks?cursor=" + valid + "&offset=50",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusBadRequest, w.Code, path)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, string(CodeValidation), responseError(t, response)["code"], path)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"stock-intelligence-backend/internal/logging"
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorCode tells a client what kind of failure an error response reports,
+// so it can branch on the code rather than on the status or the message
+type ErrorCode string
+
+const (
+	CodeValidation   ErrorCode = "VALIDATION"
+	CodeUnauthorized ErrorCode = "UNAUTHORIZED"
+	CodeNotFound     ErrorCode = "NOT_FOUND"
+	CodeConflict     ErrorCode = "CONFLICT"
+	CodeRateLimited  ErrorCode = "RATE_LIMITED"
+	CodeTimeout      ErrorCode = "TIMEOUT"
+	CodeInternal     ErrorCode = "INTERNAL"
+)
+
+// ErrorResponse is the error of every failed request, sent as
+// {"success": false, "error": ErrorResponse}. RequestID matches a client's
+// report to the server's log lines for the request.
+type ErrorResponse struct {
+	Code      ErrorCode   `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id"`
+}
+
+// detailedError is an error whose response carries details beyond its message
+type detailedError struct {
+	message string
+	details interface{}
+}
+
+func (e *detailedError) Error() string {
+	return e.message
+}
+
+// withDetails returns an error reported as message, with details in the response
+func withDetails(message string, details interface{}) error {
+	return &detailedError{message: message, details: details}
+}
+
+// respondError writes the error envelope for err, using its message and,
+// for an error from withDetails, its details
+func respondError(c *gin.Context, status int, code ErrorCode, err error) {
+	c.JSON(status, errorBody(c, code, err))
+}
+
+// AbortWithErrorResponse writes the error envelope for err and stops the handler
+// chain, for middleware refusing a request
+func AbortWithErrorResponse(c *gin.Context, status int, code ErrorCode, err error) {
+	c.AbortWithStatusJSON(status, errorBody(c, code, err))
+}
+
+// Recovery turns a panic in a later handler into a 500 with the error envelope
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		AbortWithErrorResponse(c, http.StatusInternalServerError, CodeInternal, errors.New("Internal server error"))
+	})
+}
+
+// NotFound answers requests for routes that do not exist, in place of Gin's plain-text 404
+func NotFound(c *gin.Context) {
+	respondError(c, http.StatusNotFound, CodeNotFound, errors.New("Route not found"))
+}
+
+func errorBody(c *gin.Context, code ErrorCode, err error) gin.H {
+	response := ErrorResponse{Code: code, Message: err.Error(), RequestID: logging.RequestID(c)}
+	var detailed *detailedError
+	if errors.As(err, &detailed) {
+		response.Details = detailed.details
+	}
+	return gin.H{"success": false, "error": response}
+}
+
+// respondServiceError writes a 500 with message for a failed service call, or a
+// 504 when the call's database queries ran out of time
+func respondServiceError(c *gin.Context, message string, err error) {
+	if errors.Is(err, services.ErrQueryTimeout) {
+		respondError(c, http.StatusGatewayTimeout, CodeTimeout, withDetails("Database query timed out", err.Error()))
+		return
+	}
+	respondError(c, http.StatusInternalServerError, CodeInternal, withDetails(message, err.Error()))
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"stock-intelligence-backend/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// responseError returns the error object of a decoded error envelope
+func responseError(t *testing.T, response map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	assert.Equal(t, false, response["success"])
+	envelope, ok := response["error"].(map[string]interface{})
+	require.True(t, ok, "error response without an error object: %v", response)
+	return envelope
+}
+
+func TestRespondError_WritesEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(logging.AssignRequestID())
+	router.GET("/plain", func(c *gin.Context) {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Invalid limit parameter"))
+	})
+	router.GET("/detailed", func(c *gin.Context) {
+		respondError(c, http.StatusConflict, CodeConflict, withDetails("Sync already running", gin.H{"job_id": 4}))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plain", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response struct {
+		Success bool          `json:"success"`
+		Error   ErrorResponse `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response.Success)
+	assert.Equal(t, CodeValidation, response.Error.Code)
+	assert.Equal(t, "Invalid limit parameter", response.Error.Message)
+	assert.Nil(t, response.Error.Details)
+	assert.Equal(t, w.Header().Get(logging.RequestIDHeader), response.Error.RequestID)
+	assert.NotContains(t, w.Body.String(), "details", "details are left out when there are none")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/detailed", nil))
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, CodeConflict, response.Error.Code)
+	assert.Equal(t, "Sync already running", response.Error.Message)
+	assert.Equal(t, map[string]interface{}{"job_id": float64(4)}, response.Error.Details)
+}
+
+func TestRecovery_WritesEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(logging.AssignRequestID(), Recovery())
+	router.NoRoute(NotFound)
+	router.GET("/panic", func(c *gin.Context) {
+		panic("nil map")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	envelope := responseError(t, response)
+	assert.Equal(t, string(CodeInternal), envelope["code"])
+	assert.Equal(t, "Internal server error", envelope["message"])
+	assert.NotContains(t, w.Body.String(), "nil map", "the panic value stays in the log")
+	assert.Equal(t, w.Header().Get(logging.RequestIDHeader), envelope["request_id"])
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, string(CodeNotFound), responseError(t, response)["code"])
+}
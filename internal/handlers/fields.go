@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"stock-intelligence-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stock list views: the full stock, or the fields a price list needs
+const (
+	viewFull    = "full"
+	viewCompact = "compact"
+)
+
+var stockViews = []string{viewFull, viewCompact}
+
+// compactStockFields are the fields of ?view=compact
+var compactStockFields = []string{"symbol", "current_price", "change_percent"}
+
+// stockFields are the JSON names of models.Stock's fields, the names ?fields accepts
+var stockFields = jsonFieldNames(reflect.TypeOf(models.Stock{}))
+
+// jsonFieldNames returns the names encoding/json gives t's fields, in
+// declaration order, including those of embedded structs
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			names = append(names, jsonFieldNames(field.Type)...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// stockProjection returns the fields requested by ?fields or ?view, or nil
+// for the full stock. The error lists the valid values.
+func stockProjection(c *gin.Context) ([]string, error) {
+	requested, view := c.Query("fields"), c.Query("view")
+	if requested != "" && view != "" {
+		return nil, errors.New("fields and view cannot be combined")
+	}
+
+	switch view {
+	case "", viewFull:
+	case viewCompact:
+		return compactStockFields, nil
+	default:
+		return nil, withDetails("Unknown view", gin.H{"valid_views": stockViews})
+	}
+	if requested == "" {
+		return nil, nil
+	}
+
+	valid := make(map[string]bool, len(stockFields))
+	for _, name := range stockFields {
+		valid[name] = true
+	}
+	var fields, unknown []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(requested, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if !valid[name] {
+			unknown = append(unknown, name)
+			continue
+		}
+		fields = append(fields, name)
+	}
+	if len(unknown) > 0 {
+		return nil, withDetails("Unknown fields: "+strings.Join(unknown, ", "), gin.H{
+			"unknown_fields": unknown,
+			"valid_fields":   stockFields,
+		})
+	}
+	if len(fields) == 0 {
+		return nil, withDetails("fields lists no field", gin.H{"valid_fields": stockFields})
+	}
+	return fields, nil
+}
+
+// projectStocks returns stocks with only fields, or stocks unchanged when
+// fields is nil. The stocks themselves, which may be cached, are not modified.
+func projectStocks(stocks []models.Stock, fields []string) (interface{}, error) {
+	if fields == nil {
+		return stocks, nil
+	}
+
+	projected := make([]map[string]json.RawMessage, len(stocks))
+	for i := range stocks {
+		encoded, err := json.Marshal(&stocks[i])
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(encoded, &all); err != nil {
+			return nil, err
+		}
+		projected[i] = make(map[string]json.RawMessage, len(fields))
+		for _, name := range fields {
+			projected[i][name] = all[name]
+		}
+	}
+	return projected, nil
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/cache"
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getStockKeys requests path and returns the sorted keys of each stock in the response
+func getStockKeys(t *testing.T, router *gin.Engine, path string) [][]string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data []map[string]json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	keys := make([][]string, len(response.Data))
+	for i, stock := range response.Data {
+		for key := range stock {
+			keys[i] = append(keys[i], key)
+		}
+		sort.Strings(keys[i])
+	}
+	return keys
+}
+
+func TestGetAllStocks_FieldSelection(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks", handler.GetAllStocks)
+
+	now := time.Now()
+	for _, path := range []string{"/stocks?fields=symbol,current_price", "/stocks?view=compact"} {
+		mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("FROM stocks s").WithArgs(50, 0).WillReturnRows(sqlmock.NewRows(stockListColumns).
+			AddRow(1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(3000000000000),
+				"$100+", "NASDAQ", true, now, now, nil, nil, 150.0, 3.0, 2.0, int64(50000000), now, 1))
+
+		keys := getStockKeys(t, router, path)
+		require.Len(t, keys, 1, path)
+		if path == "/stocks?view=compact" {
+			assert.Equal(t, []string{"change_percent", "current_price", "symbol"}, keys[0], path)
+		} else {
+			assert.Equal(t, []string{"current_price", "symbol"}, keys[0], path)
+		}
+	}
+
+	// Bad selections are refused before any query
+	for query, message := range map[string]string{
+		"fields=symbol,ticker,price":     "Unknown fields: ticker, price",
+		"fields=symbol&view=compact":     "fields and view cannot be combined",
+		"view=tiny":                      "Unknown view",
+		"fields=" + url.QueryEscape(","): "fields lists no field",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stocks?"+query, nil))
+		require.Equal(t, http.StatusBadRequest, w.Code, query)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		envelope := responseError(t, response)
+		assert.Equal(t, string(CodeValidation), envelope["code"], query)
+		assert.Equal(t, message, envelope["message"], query)
+		if query == "fields=symbol,ticker,price" {
+			details := envelope["details"].(map[string]interface{})
+			assert.Equal(t, []interface{}{"ticker", "price"}, details["unknown_fields"])
+			assert.Contains(t, details["valid_fields"], "change_percent")
+		}
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllStocks_ProjectionLeavesCachedStocksWhole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache("redis://"+server.Addr(), "test:")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, redisCache)
+	handler := NewDatabaseStockHandler(service, service)
+	router := gin.New()
+	router.GET("/stocks", handler.GetAllStocks)
+
+	// Only the first request reaches the database; the second is served from the cached sector page
+	now := time.Now()
+	mock.ExpectQuery(`SELECT COUNT\(\*\)`).WithArgs("Technology").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("FROM stocks s").WillReturnRows(sqlmock.NewRows(stockListColumns).
+		AddRow(1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(3000000000000),
+			"$100+", "NASDAQ", true, now, now, nil, nil, 150.0, 3.0, 2.0, int64(50000000), now, 1))
+
+	compact := getStockKeys(t, router, "/stocks?view=compact&sector=Technology")
+	require.Len(t, compact, 1)
+	assert.Equal(t, []string{"change_percent", "current_price", "symbol"}, compact[0])
+
+	full := getStockKeys(t, router, "/stocks?sector=Technology")
+	require.Len(t, full, 1)
+	assert.ElementsMatch(t, stockFields, full[0])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
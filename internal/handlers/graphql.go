@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"stock-intelligence-backend/internal/models"
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// GraphQLHandler serves a read-only GraphQL API over stocks, their prices and
+// the market overview, so a page can load what it needs in one request
+type GraphQLHandler struct {
+	stocks services.StockReader
+	prices services.PriceReader
+	schema graphql.Schema
+}
+
+// NewGraphQLHandler creates a new GraphQL handler
+func NewGraphQLHandler(stocks services.StockReader, prices services.PriceReader) *GraphQLHandler {
+	h := &GraphQLHandler{stocks: stocks, prices: prices}
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: h.queryType()})
+	if err != nil {
+		panic("graphql: " + err.Error()) // The schema is fixed; only an invalid edit to it fails
+	}
+	h.schema = schema
+	return h
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphQLError is a resolver error carrying one of the API's error codes in its extensions
+type graphQLError struct {
+	code    ErrorCode
+	message string
+	details interface{}
+}
+
+func (e graphQLError) Error() string {
+	return e.message
+}
+
+func (e graphQLError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.code}
+	if e.details != nil {
+		extensions["details"] = e.details
+	}
+	return extensions
+}
+
+// graphQLServiceError is the resolver error for a failed service call, as respondServiceError writes it
+func graphQLServiceError(message string, err error) error {
+	if errors.Is(err, services.ErrQueryTimeout) {
+		return graphQLError{code: CodeTimeout, message: "Database query timed out", details: err.Error()}
+	}
+	return graphQLError{code: CodeInternal, message: message, details: err.Error()}
+}
+
+// graphQLLimitError rejects a whole query before it runs
+func graphQLLimitError(format string, args ...interface{}) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{{
+		Message:    fmt.Sprintf(format, args...),
+		Extensions: map[string]interface{}{"code": CodeValidation},
+	}}}
+}
+
+// ServeGraphQL runs a query, answering in the GraphQL response format: errors
+// in the query or its limits, and fields that failed, are listed in errors
+// with a 200, as GraphQL clients expect. Only a body that is not a GraphQL
+// request is a 400.
+func (h *GraphQLHandler) ServeGraphQL(c *gin.Context) {
+	var req graphQLRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Query == "" {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Request body must be a JSON object with a query"))
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		c.JSON(http.StatusOK, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+	if validation := graphql.ValidateDocument(&h.schema, doc, nil); !validation.IsValid {
+		c.JSON(http.StatusOK, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+	depth, complexity := measureQuery(doc, req.Variables)
+	if depth > maxGraphQLDepth {
+		c.JSON(http.StatusOK, graphQLLimitError("Query depth %d exceeds the limit of %d", depth, maxGraphQLDepth))
+		return
+	}
+	if complexity > maxGraphQLComplexity {
+		c.JSON(http.StatusOK, graphQLLimitError("Query complexity %d exceeds the limit of %d", complexity, maxGraphQLComplexity))
+		return
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       context.WithValue(c.Request.Context(), priceLoaderKey{}, newPriceLoader(h.prices)),
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+// priceLoaderKey holds the request's priceLoader in the resolvers' context
+type priceLoaderKey struct{}
+
+// stockPage is the result of the stocks query
+type stockPage struct {
+	Items      []models.Stock
+	TotalCount int
+	Limit      int
+	Offset     int
+	HasMore    bool
+}
+
+func (h *GraphQLHandler) queryType() *graphql.Object {
+	pricePointType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "PricePoint",
+		Description: "One day's closing price and volume",
+		Fields: graphql.Fields{
+			"date": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "Trading day, YYYY-MM-DD",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(models.PricePoint).Date.Format("2006-01-02"), nil
+				},
+			},
+			"price":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"volume": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		},
+	})
+
+	coverage := func(value func(models.PriceCoverage) interface{}) graphql.FieldResolveFn {
+		return func(p graphql.ResolveParams) (interface{}, error) {
+			return value(p.Source.(models.Stock).PriceCoverage), nil
+		}
+	}
+	stockType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Stock",
+		Description: "An active stock with its latest close and daily change",
+		Fields: graphql.Fields{
+			"id":            &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"symbol":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"companyName":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"sector":        &graphql.Field{Type: graphql.String},
+			"industry":      &graphql.Field{Type: graphql.String},
+			"exchange":      &graphql.Field{Type: graphql.String},
+			"marketCap":     &graphql.Field{Type: graphql.Float, Description: "Float, as caps overflow GraphQL's 32-bit Int"},
+			"priceRange":    &graphql.Field{Type: graphql.String},
+			"isActive":      &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"currentPrice":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"dailyChange":   &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"changePercent": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"changePeriodDays": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "Calendar days the daily change spans",
+			},
+			"volume":      &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"lastUpdated": &graphql.Field{Type: graphql.DateTime},
+			"hasPriceData": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.Boolean),
+				Resolve: coverage(func(c models.PriceCoverage) interface{} { return c.HasPriceData }),
+			},
+			"firstPriceDate": &graphql.Field{
+				Type:    graphql.DateTime,
+				Resolve: coverage(func(c models.PriceCoverage) interface{} { return c.FirstPriceDate }),
+			},
+			"lastPriceDate": &graphql.Field{
+				Type:    graphql.DateTime,
+				Resolve: coverage(func(c models.PriceCoverage) interface{} { return c.LastPriceDate }),
+			},
+			"prices": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(pricePointType))),
+				Description: fmt.Sprintf("The latest days of daily closes, oldest first; days is capped at %d", maxPerformanceDays),
+				Args: graphql.FieldConfigArgument{
+					"days":     &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPerformanceDays},
+					"adjusted": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+				},
+				Resolve: h.resolvePrices,
+			},
+		},
+	})
+
+	stockPageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "StockPage",
+		Fields: graphql.Fields{
+			"items":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(stockType)))},
+			"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"limit":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"offset":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"hasMore":    &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		},
+	})
+
+	overview := func(value func(*services.MarketOverviewAggregate) interface{}) graphql.FieldResolveFn {
+		return func(p graphql.ResolveParams) (interface{}, error) {
+			return value(p.Source.(*services.MarketOverviewAggregate)), nil
+		}
+	}
+	marketOverviewType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "MarketOverview",
+		Description: "Advancing, declining and unchanged stocks, leaving out those beyond the change percent guard",
+		Fields: graphql.Fields{
+			"totalStocks": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.Int),
+				Resolve: overview(func(o *services.MarketOverviewAggregate) interface{} { return o.TotalStocks }),
+			},
+			"advancingCount": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.Int),
+				Resolve: overview(func(o *services.MarketOverviewAggregate) interface{} { return o.AdvancingCount }),
+			},
+			"decliningCount": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.Int),
+				Resolve: overview(func(o *services.MarketOverviewAggregate) interface{} { return o.DecliningCount }),
+			},
+			"unchangedCount": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.Int),
+				Resolve: overview(func(o *services.MarketOverviewAggregate) interface{} { return o.UnchangedCount }),
+			},
+			"avgChange": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.Float),
+				Resolve: overview(func(o *services.MarketOverviewAggregate) interface{} { return o.AvgChange }),
+			},
+			"excludedCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"stocks": &graphql.Field{
+				Type:        graphql.NewNonNull(stockPageType),
+				Description: fmt.Sprintf("A page of the active stocks that filter keeps; limit is capped at %d", maxPageSize),
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: stockFilterInput},
+					"sort":   &graphql.ArgumentConfig{Type: stockSortInput},
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: h.resolveStocks,
+			},
+			"stock": &graphql.Field{
+				Type:        stockType,
+				Description: "A stock by symbol, delisted ones included; null for an unknown symbol",
+				Args: graphql.FieldConfigArgument{
+					"symbol": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: h.resolveStock,
+			},
+			"marketOverview": &graphql.Field{
+				Type:    graphql.NewNonNull(marketOverviewType),
+				Resolve: h.resolveMarketOverview,
+			},
+		},
+	})
+}
+
+var stockFilterInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "StockFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"sector":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"priceRange":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"marketCap":      &graphql.InputObjectFieldConfig{Type: enumOf("MarketCapBand", services.MarketCapBands)},
+		"exchange":       &graphql.InputObjectFieldConfig{Type: enumOf("Exchange", services.Exchanges)},
+		"minHistoryDays": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+	},
+})
+
+var stockSortInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "StockSort",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"field": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.NewEnum(graphql.EnumConfig{
+			Name: "StockSortField",
+			Values: graphql.EnumValueConfigMap{
+				"MARKET_CAP":     &graphql.EnumValueConfig{Value: services.StockSortMarketCap},
+				"SYMBOL":         &graphql.EnumValueConfig{Value: services.StockSortSymbol},
+				"PRICE":          &graphql.EnumValueConfig{Value: services.StockSortPrice},
+				"CHANGE_PERCENT": &graphql.EnumValueConfig{Value: services.StockSortChangePercent},
+				"VOLUME":         &graphql.EnumValueConfig{Value: services.StockSortVolume},
+			},
+		}))},
+		"direction": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewEnum(graphql.EnumConfig{
+				Name: "SortDirection",
+				Values: graphql.EnumValueConfigMap{
+					"ASC":  &graphql.EnumValueConfig{Value: true},
+					"DESC": &graphql.EnumValueConfig{Value: false},
+				},
+			}),
+			DefaultValue: false,
+		},
+	},
+})
+
+// enumOf is an enum whose names are values uppercased and which resolves to the values
+func enumOf(name string, values []string) *graphql.Enum {
+	config := graphql.EnumValueConfigMap{}
+	for _, value := range values {
+		config[strings.ToUpper(value)] = &graphql.EnumValueConfig{Value: value}
+	}
+	return graphql.NewEnum(graphql.EnumConfig{Name: name, Values: config})
+}
+
+func (h *GraphQLHandler) resolveStocks(p graphql.ResolveParams) (interface{}, error) {
+	var filter services.StockFilter
+	if args, ok := p.Args["filter"].(map[string]interface{}); ok {
+		filter.Sector, _ = args["sector"].(string)
+		if priceRange, _ := args["priceRange"].(string); priceRange != "" {
+			if ranges := h.stocks.GetPriceRanges(); !ranges.IsValid(priceRange) {
+				return nil, graphQLError{code: CodeValidation, message: "Invalid price range", details: gin.H{
+					"valid_ranges": ranges.Labels(),
+				}}
+			}
+			filter.PriceRange = priceRange
+		}
+		if band, _ := args["marketCap"].(string); band != "" {
+			filter.MarketCap = services.MarketCapBand(band)
+		}
+		filter.Exchange, _ = args["exchange"].(string)
+		filter.MinHistoryDays, _ = args["minHistoryDays"].(int)
+		if filter.MinHistoryDays < 0 {
+			return nil, graphQLError{code: CodeValidation, message: "minHistoryDays must be a non-negative integer"}
+		}
+	}
+	if args, ok := p.Args["sort"].(map[string]interface{}); ok {
+		filter.Sort.Field, _ = args["field"].(services.StockSortField)
+		filter.Sort.Ascending, _ = args["direction"].(bool)
+	}
+
+	// Capped as the REST list caps them
+	limit, _ := p.Args["limit"].(int)
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	limit = min(limit, maxPageSize)
+	offset, _ := p.Args["offset"].(int)
+	offset = max(offset, 0)
+
+	stocks, total, err := h.stocks.GetAllStocksPaginated(p.Context, filter, limit, offset)
+	if err != nil {
+		return nil, graphQLServiceError("Failed to load stocks", err)
+	}
+	if stocks == nil {
+		stocks = []models.Stock{}
+	}
+	return stockPage{Items: stocks, TotalCount: total, Limit: limit, Offset: offset, HasMore: offset+len(stocks) < total}, nil
+}
+
+func (h *GraphQLHandler) resolveStock(p graphql.ResolveParams) (interface{}, error) {
+	stock, err := h.stocks.GetStockBySymbol(p.Context, p.Args["symbol"].(string))
+	switch {
+	case errors.Is(err, services.ErrStockNotFound):
+		return nil, nil
+	case errors.Is(err, models.ErrMalformedSymbol):
+		return nil, graphQLError{code: CodeValidation, message: "Invalid stock symbol", details: err.Error()}
+	case err != nil:
+		return nil, graphQLServiceError("Failed to load stock", err)
+	}
+	return *stock, nil
+}
+
+// resolvePrices defers to the request's price loader, which fetches the
+// prices of every stock in the response together
+func (h *GraphQLHandler) resolvePrices(p graphql.ResolveParams) (interface{}, error) {
+	// Capped as the REST performance endpoint caps it
+	days, _ := p.Args["days"].(int)
+	if days <= 0 {
+		days = defaultPerformanceDays
+	}
+	days = min(days, maxPerformanceDays)
+	adjusted, _ := p.Args["adjusted"].(bool)
+
+	loader, ok := p.Context.Value(priceLoaderKey{}).(*priceLoader)
+	if !ok {
+		loader = newPriceLoader(h.prices)
+	}
+	return loader.load(p.Context, p.Source.(models.Stock).Symbol, days, adjusted), nil
+}
+
+func (h *GraphQLHandler) resolveMarketOverview(p graphql.ResolveParams) (interface{}, error) {
+	overview, err := h.stocks.GetMarketOverviewAggregate(p.Context)
+	if err != nil {
+		return nil, graphQLServiceError("Failed to load market overview", err)
+	}
+	return overview, nil
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// graphQLListSize is how a list field's length is read from its arguments,
+// clamped as its resolver clamps it
+type graphQLListSize struct {
+	argument string
+	fallback int
+	max      int
+}
+
+// graphQLListSizes are the list fields whose selections are multiplied by
+// their length in a query's complexity
+var graphQLListSizes = map[string]graphQLListSize{
+	"stocks": {argument: "limit", fallback: defaultPageSize, max: maxPageSize},
+	"prices": {argument: "days", fallback: defaultPerformanceDays, max: maxPerformanceDays},
+}
+
+// queryMeter measures the depth and complexity of a validated GraphQL document
+type queryMeter struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	// measured caches each fragment's cost, so fragments spreading each other
+	// many times over are measured once
+	measured map[string][2]int
+}
+
+// measureQuery returns the deepest field nesting and the complexity of the
+// costliest operation in doc. Each field costs 1 plus its selection's cost,
+// which list fields multiply by their length. Introspection fields are not
+// counted, so clients can load the schema.
+func measureQuery(doc *ast.Document, variables map[string]interface{}) (depth, complexity int) {
+	m := &queryMeter{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+		measured:  make(map[string][2]int),
+	}
+	for _, definition := range doc.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			m.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	for _, definition := range doc.Definitions {
+		if operation, ok := definition.(*ast.OperationDefinition); ok {
+			d, c := m.selectionSet(operation.SelectionSet)
+			depth, complexity = max(depth, d), max(complexity, c)
+		}
+	}
+	return depth, complexity
+}
+
+func (m *queryMeter) selectionSet(set *ast.SelectionSet) (depth, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, selection := range set.Selections {
+		var d, c int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			d, c = m.selectionSet(selection.SelectionSet)
+			d++
+			c = saturatingAdd(1, saturatingMul(m.listLength(selection), c))
+		case *ast.InlineFragment:
+			d, c = m.selectionSet(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			d, c = m.fragment(selection.Name.Value)
+		}
+		depth = max(depth, d)
+		complexity = saturatingAdd(complexity, c)
+	}
+	return depth, complexity
+}
+
+func (m *queryMeter) fragment(name string) (depth, complexity int) {
+	if cost, ok := m.measured[name]; ok {
+		return cost[0], cost[1]
+	}
+	fragment, ok := m.fragments[name]
+	if !ok {
+		return 0, 0
+	}
+	depth, complexity = m.selectionSet(fragment.SelectionSet)
+	m.measured[name] = [2]int{depth, complexity}
+	return depth, complexity
+}
+
+// listLength is the number of items field returns at most; 1 for fields that are not lists
+func (m *queryMeter) listLength(field *ast.Field) int {
+	size, ok := graphQLListSizes[field.Name.Value]
+	if !ok {
+		return 1
+	}
+	length := size.fallback
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != size.argument {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil {
+				length = n
+			}
+		case *ast.Variable:
+			// Variables arrive as decoded JSON numbers
+			if n, ok := m.variables[value.Name.Value].(float64); ok {
+				length = int(n)
+			}
+		}
+	}
+	if length <= 0 {
+		return size.fallback
+	}
+	return min(length, size.max)
+}
+
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt32-b {
+		return math.MaxInt32
+	}
+	return a + b
+}
+
+func saturatingMul(a, b int) int {
+	if b != 0 && a > math.MaxInt32/b {
+		return math.MaxInt32
+	}
+	return a * b
+}
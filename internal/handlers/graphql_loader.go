@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"stock-intelligence-backend/internal/models"
+	"stock-intelligence-backend/internal/services"
+)
+
+// priceKey is one shape of price history a query asks for
+type priceKey struct {
+	days     int
+	adjusted bool
+}
+
+// priceLoader batches the price histories a GraphQL query asks for. Each
+// prices field registers its symbol and defers its result; the first deferred
+// result read loads every symbol registered so far in one query per shape, so
+// listing stocks with their prices costs one price query, not one per stock.
+// A loader lives for one request.
+type priceLoader struct {
+	prices services.PriceReader
+
+	mu      sync.Mutex
+	pending map[priceKey][]string
+	loaded  map[priceKey]map[string][]models.PricePoint
+	failed  map[priceKey]error
+}
+
+func newPriceLoader(prices services.PriceReader) *priceLoader {
+	return &priceLoader{
+		prices:  prices,
+		pending: make(map[priceKey][]string),
+		loaded:  make(map[priceKey]map[string][]models.PricePoint),
+		failed:  make(map[priceKey]error),
+	}
+}
+
+// load registers symbol and returns a thunk resolving to its history
+func (l *priceLoader) load(ctx context.Context, symbol string, days int, adjusted bool) func() (interface{}, error) {
+	key := priceKey{days: days, adjusted: adjusted}
+	l.mu.Lock()
+	if _, done := l.loaded[key][symbol]; !done && !slices.Contains(l.pending[key], symbol) {
+		l.pending[key] = append(l.pending[key], symbol)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.flush(ctx)
+		if err := l.failed[key]; err != nil {
+			return nil, fmt.Errorf("Failed to load prices: %w", err)
+		}
+		if points := l.loaded[key][symbol]; points != nil {
+			return points, nil
+		}
+		return []models.PricePoint{}, nil
+	}
+}
+
+// flush loads every pending symbol; callers hold l.mu
+func (l *priceLoader) flush(ctx context.Context) {
+	for key, symbols := range l.pending {
+		delete(l.pending, key)
+		histories, err := l.prices.GetPriceHistories(ctx, symbols, key.days, key.adjusted)
+		if err != nil {
+			l.failed[key] = err
+			continue
+		}
+		if l.loaded[key] == nil {
+			l.loaded[key] = make(map[string][]models.PricePoint, len(symbols))
+		}
+		for _, symbol := range symbols {
+			l.loaded[key][symbol] = histories[symbol]
+		}
+	}
+}
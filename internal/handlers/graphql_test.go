@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var priceHistoryColumns = []string{"symbol", "date", "price", "volume"}
+
+func newGraphQLRouter(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	service := services.NewDatabaseStockService(db, nil)
+	router := gin.New()
+	router.POST("/graphql", NewGraphQLHandler(service, service).ServeGraphQL)
+	return router, mock
+}
+
+type graphQLResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func postGraphQL(t *testing.T, router *gin.Engine, query string, variables map[string]interface{}) graphQLResponse {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response graphQLResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestGraphQL_StocksWithPricesInOnePriceQuery(t *testing.T) {
+	router, mock := newGraphQLRouter(t)
+	now := time.Now()
+	day := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT COUNT\(DISTINCT s.id\)[\s\S]+s.sector = \$1`).WithArgs("Technology").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(`s.sector = \$1\s+ORDER BY change_percent, s.symbol\s+LIMIT \$2 OFFSET \$3`).
+		WithArgs("Technology", 2, 0).
+		WillReturnRows(sqlmock.NewRows(stockListColumns).
+			AddRow(1, "MSFT", "Microsoft", "Technology", "Software", int64(3100000000000), "$200+", "NASDAQ", true,
+				now, now, day, day, 410.0, -2.0, -0.49, int64(20000000), day, 1).
+			AddRow(2, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(2900000000000), "$150-200", "NASDAQ", true,
+				now, now, day, day, 170.0, 1.0, 0.59, int64(50000000), day, 1))
+	// One query for both stocks' prices, whichever order they were listed in
+	mock.ExpectQuery(`WHERE s.symbol = ANY\(\$1\)[\s\S]+WHERE n <= \$2`).WithArgs(`{"MSFT","AAPL"}`, 5).
+		WillReturnRows(sqlmock.NewRows(priceHistoryColumns).
+			AddRow("AAPL", day, 170.0, int64(50000000)).
+			AddRow("MSFT", day.AddDate(0, 0, -1), 412.0, int64(18000000)).
+			AddRow("MSFT", day, 410.0, int64(20000000)))
+
+	response := postGraphQL(t, router, `
+		query ($filter: StockFilter) {
+			stocks(filter: $filter, sort: {field: CHANGE_PERCENT, direction: ASC}, limit: 2) {
+				totalCount
+				hasMore
+				items { symbol marketCap changePercent hasPriceData prices(days: 5) { date price } }
+			}
+		}`, map[string]interface{}{"filter": map[string]interface{}{"sector": "Technology"}})
+	require.Empty(t, response.Errors)
+
+	var stocks struct {
+		TotalCount int  `json:"totalCount"`
+		HasMore    bool `json:"hasMore"`
+		Items      []struct {
+			Symbol        string  `json:"symbol"`
+			MarketCap     float64 `json:"marketCap"`
+			ChangePercent float64 `json:"changePercent"`
+			HasPriceData  bool    `json:"hasPriceData"`
+			Prices        []struct {
+				Date  string  `json:"date"`
+				Price float64 `json:"price"`
+			} `json:"prices"`
+		} `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(response.Data["stocks"], &stocks))
+	assert.Equal(t, 3, stocks.TotalCount)
+	assert.True(t, stocks.HasMore)
+	require.Len(t, stocks.Items, 2)
+	assert.Equal(t, "MSFT", stocks.Items[0].Symbol)
+	assert.Equal(t, 3.1e12, stocks.Items[0].MarketCap)
+	assert.True(t, stocks.Items[0].HasPriceData)
+	require.Len(t, stocks.Items[0].Prices, 2)
+	assert.Equal(t, "2024-03-03", stocks.Items[0].Prices[0].Date)
+	assert.Equal(t, 410.0, stocks.Items[0].Prices[1].Price)
+	require.Len(t, stocks.Items[1].Prices, 1)
+	assert.Equal(t, 170.0, stocks.Items[1].Prices[0].Price)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGraphQL_Stock(t *testing.T) {
+	router, mock := newGraphQLRouter(t)
+	stockColumns := []string{
+		"id", "symbol", "company_name", "sector", "industry", "market_cap",
+		"price_range", "exchange", "is_active", "created_at", "updated_at",
+		"first_price_date", "last_price_date",
+	}
+
+	// A stock never synced has no prices rather than failing
+	mock.ExpectQuery("FROM stocks s").WithArgs("BRK.B").WillReturnRows(sqlmock.NewRows(stockColumns).
+		AddRow(7, "BRK.B", "Berkshire Hathaway", "Financial Services", "Insurance", nil,
+			"$200+", "NYSE", true, time.Now(), time.Now(), nil, nil))
+	mock.ExpectQuery("FROM daily_prices").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"close_price", "volume", "date", "daily_change", "change_percent", "change_period_days"}))
+	mock.ExpectQuery(`dp.adjusted_close as price`).WithArgs(`{"BRK.B"}`, defaultPerformanceDays).
+		WillReturnRows(sqlmock.NewRows(priceHistoryColumns))
+
+	response := postGraphQL(t, router, `{ stock(symbol: "brk-b") { symbol marketCap hasPriceData firstPriceDate prices(adjusted: true) { price } } }`, nil)
+	require.Empty(t, response.Errors)
+	assert.JSONEq(t, `{"symbol": "BRK.B", "marketCap": null, "hasPriceData": false, "firstPriceDate": null, "prices": []}`,
+		string(response.Data["stock"]))
+
+	mock.ExpectQuery("FROM stocks s").WithArgs("ZZZZ").WillReturnRows(sqlmock.NewRows(stockColumns))
+	response = postGraphQL(t, router, `{ stock(symbol: "ZZZZ") { symbol } }`, nil)
+	require.Empty(t, response.Errors)
+	assert.Equal(t, "null", string(response.Data["stock"]))
+
+	response = postGraphQL(t, router, `{ stock(symbol: "not a symbol!") { symbol } }`, nil)
+	require.Len(t, response.Errors, 1)
+	assert.Equal(t, "Invalid stock symbol", response.Errors[0].Message)
+	assert.Equal(t, string(CodeValidation), response.Errors[0].Extensions["code"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGraphQL_MarketOverview(t *testing.T) {
+	router, mock := newGraphQLRouter(t)
+	mock.ExpectQuery("FROM ranked").WithArgs(services.DefaultMaxChangePercent).
+		WillReturnRows(sqlmock.NewRows([]string{"total", "advancing", "declining", "unchanged", "avg"}).
+			AddRow(4, 2, 1, 0, 2.0/3.0))
+	expectChangeOutliers(mock)
+
+	response := postGraphQL(t, router, `{ marketOverview { totalStocks advancingCount decliningCount avgChange excludedCount } }`, nil)
+	require.Empty(t, response.Errors)
+	assert.JSONEq(t, `{"totalStocks": 4, "advancingCount": 2, "decliningCount": 1, "avgChange": 0.67, "excludedCount": 1}`,
+		string(response.Data["marketOverview"]))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGraphQL_InvalidFilter(t *testing.T) {
+	router, mock := newGraphQLRouter(t)
+
+	response := postGraphQL(t, router, `{ stocks(filter: {priceRange: "$1-2"}) { totalCount } }`, nil)
+	require.Len(t, response.Errors, 1)
+	assert.Equal(t, "Invalid price range", response.Errors[0].Message)
+	assert.Equal(t, string(CodeValidation), response.Errors[0].Extensions["code"])
+
+	// Enums are checked by the schema
+	response = postGraphQL(t, router, `{ stocks(filter: {exchange: LSE}) { totalCount } }`, nil)
+	require.Len(t, response.Errors, 1)
+	assert.Nil(t, response.Data)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGraphQL_LimitsRefuseQueriesBeforeTheyRun(t *testing.T) {
+	router, mock := newGraphQLRouter(t)
+
+	// 1 + 200 * (1 + 1 + 365) fields, counted before any query runs
+	response := postGraphQL(t, router, `query ($days: Int) { stocks(limit: 200) { items { prices(days: $days) { date } } } }`,
+		map[string]interface{}{"days": 365})
+	require.Len(t, response.Errors, 1)
+	assert.Equal(t, "Query complexity 73401 exceeds the limit of 10000", response.Errors[0].Message)
+	assert.Equal(t, string(CodeValidation), response.Errors[0].Extensions["code"])
+	assert.Nil(t, response.Data)
+
+	// Introspection is not counted
+	response = postGraphQL(t, router, `{ __schema { types { name fields { name type { name ofType { name ofType { name } } } } } } }`, nil)
+	assert.Empty(t, response.Errors)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGraphQL_RequiresAQuery(t *testing.T) {
+	router, _ := newGraphQLRouter(t)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"variables": {}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	response := postGraphQL(t, router, `{ stocks {`, nil)
+	require.Len(t, response.Errors, 1)
+	assert.Contains(t, response.Errors[0].Message, "Syntax Error")
+}
+
+func TestMeasureQuery(t *testing.T) {
+	measure := func(query string, variables map[string]interface{}) (int, int) {
+		doc, err := parser.Parse(parser.ParseParams{Source: query})
+		require.NoError(t, err)
+		return measureQuery(doc, variables)
+	}
+
+	depth, complexity := measure(`{ a { b { c { d { e { f } } } } } }`, nil)
+	assert.Equal(t, 6, depth)
+	assert.Equal(t, 6, complexity)
+
+	// Fragments count where they are spread; limits are clamped as the resolvers clamp them
+	depth, complexity = measure(`
+		query ($n: Int) { stocks(limit: $n) { totalCount items { ...Row } } }
+		fragment Row on Stock { symbol prices(days: 0) { date price } }`, map[string]interface{}{"n": float64(500)})
+	assert.Equal(t, 4, depth)
+	assert.Equal(t, 1+maxPageSize*(1+1+(1+1+defaultPerformanceDays*2)), complexity)
+
+	// Fragments spreading the next twice over are measured once each, and the cost saturates
+	query := "{ ...F0 }"
+	for i := 0; i < 40; i++ {
+		query += fmt.Sprintf(" fragment F%d on Query { ...F%d ...F%d }", i, i+1, i+1)
+	}
+	_, complexity = measure(query+" fragment F40 on Query { x }", nil)
+	assert.Equal(t, math.MaxInt32, complexity)
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"stock-intelligence-backend/internal/services"
 
@@ -24,35 +26,145 @@ func NewHistoricalDataSyncHandler(syncService *services.HistoricalDataSyncServic
 // TriggerBatchSync triggers a batch synchronization of historical data
 func (h *HistoricalDataSyncHandler) TriggerBatchSync(c *gin.Context) {
 	// Get limit from query parameter (default 24)
-	limitStr := c.DefaultQuery("limit", "24")
+	limitStr := c.DefaultQuery("limit", strconv.Itoa(defaultBatchSyncLimit))
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "Invalid limit parameter",
-		})
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Invalid limit parameter"))
 		return
 	}
 
 	// Maximum safety limit
-	if limit > 25 {
-		limit = 25
+	if limit > maxBatchSyncLimit {
+		limit = maxBatchSyncLimit
 	}
 
-	// Trigger the batch sync
-	result, err := h.syncService.SyncBatch(limit)
+	// Queue the batch sync; progress is polled from /sync/jobs/:id
+	job, err := h.syncService.EnqueueBatchSync(c.Request.Context(), limit)
+	var active *services.BatchSyncActiveError
+	if errors.As(err, &active) {
+		respondError(c, http.StatusConflict, CodeConflict, withDetails("A batch sync is already queued or running", gin.H{
+			"active_job_id": active.JobID,
+		}))
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, CodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    job,
+		"message": "Batch sync queued",
+	})
+}
+
+// GetSyncJob returns the progress of a batch sync
+func (h *HistoricalDataSyncHandler) GetSyncJob(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Invalid sync job ID"))
+		return
+	}
+
+	job, err := h.syncService.GetSyncJob(c.Request.Context(), id)
+	if errors.Is(err, services.ErrSyncJobNotFound) {
+		respondError(c, http.StatusNotFound, CodeNotFound, errors.New("Sync job not found"))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, CodeInternal, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data":    result,
-		"message": result.Message,
+		"data":    job,
+	})
+}
+
+// CancelSyncJob stops a batch sync; the stock in progress is finished first
+func (h *HistoricalDataSyncHandler) CancelSyncJob(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Invalid sync job ID"))
+		return
+	}
+
+	job, err := h.syncService.CancelSyncJob(c.Request.Context(), id)
+	switch {
+	case errors.Is(err, services.ErrSyncJobNotFound):
+		respondError(c, http.StatusNotFound, CodeNotFound, errors.New("Sync job not found"))
+		return
+	case errors.Is(err, services.ErrSyncJobFinished), errors.Is(err, services.ErrSyncJobNotCancellable):
+		respondError(c, http.StatusConflict, CodeConflict, err)
+		return
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, CodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    job,
+		"message": "Batch sync cancellation requested",
+	})
+}
+
+// GetSyncHistory returns per-stock sync outcomes, newest first, filtered by
+// symbol, days and status (succeeded or failed) with pagination
+func (h *HistoricalDataSyncHandler) GetSyncHistory(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)))
+	if err != nil || limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	filter := services.SyncHistoryFilter{
+		Symbol: strings.ToUpper(c.Query("symbol")),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	if value := c.Query("days"); value != "" {
+		filter.Days, err = strconv.Atoi(value)
+		if err != nil || filter.Days < 0 {
+			respondError(c, http.StatusBadRequest, CodeValidation, errors.New("days must be a non-negative integer"))
+			return
+		}
+	}
+
+	switch status := c.Query("status"); status {
+	case "":
+	case "succeeded", "failed":
+		success := status == "succeeded"
+		filter.Success = &success
+	default:
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("status must be succeeded or failed"))
+		return
+	}
+
+	entries, total, err := h.syncService.GetSyncHistory(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, CodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"data":     entries,
+		"count":    len(entries),
+		"total":    total,
+		"offset":   offset,
+		"limit":    limit,
+		"has_more": offset+len(entries) < total,
 	})
 }
 
@@ -60,10 +172,7 @@ func (h *HistoricalDataSyncHandler) TriggerBatchSync(c *gin.Context) {
 func (h *HistoricalDataSyncHandler) GetSyncStatus(c *gin.Context) {
 	status, err := h.syncService.GetSyncStatus()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, CodeInternal, err)
 		return
 	}
 
@@ -76,10 +185,10 @@ func (h *HistoricalDataSyncHandler) GetSyncStatus(c *gin.Context) {
 // GetPendingStocks returns stocks that need historical data sync
 func (h *HistoricalDataSyncHandler) GetPendingStocks(c *gin.Context) {
 	// Get limit from query parameter (default 25)
-	limitStr := c.DefaultQuery("limit", "25")
+	limitStr := c.DefaultQuery("limit", strconv.Itoa(defaultPendingStocksLimit))
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit <= 0 {
-		limit = 25
+		limit = defaultPendingStocksLimit
 	}
 
 	// Create SP500 service instance
@@ -87,10 +196,7 @@ func (h *HistoricalDataSyncHandler) GetPendingStocks(c *gin.Context) {
 	
 	pendingStocks, err := sp500Service.GetPendingStocksForSync(limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, CodeInternal, err)
 		return
 	}
 
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/jobs"
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var syncJobColumns = []string{
+	"id", "job_id", "status", "max_stocks", "attempted", "successful", "failed",
+	"message", "created_at", "started_at", "finished_at",
+}
+
+func newSyncRouter(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// The queue is never started, so batches are only queued
+	queue := jobs.NewQueue(jobs.NewPostgresStore(db), 1)
+	service := services.NewHistoricalDataSyncService(db, services.NewAlphaVantageClient("test-key", db), queue)
+
+	gin.SetMode(gin.TestMode)
+	handler := NewHistoricalDataSyncHandler(service)
+	router := gin.New()
+	router.POST("/sync/batch", handler.TriggerBatchSync)
+	router.GET("/sync/jobs/:id", handler.GetSyncJob)
+	router.DELETE("/sync/jobs/:id", handler.CancelSyncJob)
+	router.GET("/sync/history", handler.GetSyncHistory)
+	return router, mock
+}
+
+func serveSyncRequest(t *testing.T, router *gin.Engine, method, path string) (int, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return w.Code, response
+}
+
+func TestTriggerBatchSync_ReturnsJobImmediately(t *testing.T) {
+	router, mock := newSyncRouter(t)
+
+	mock.ExpectQuery("INSERT INTO sync_jobs").WithArgs(3, jobs.StatusQueued).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(5, time.Now()))
+	mock.ExpectQuery("INSERT INTO jobs").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(9, time.Now()))
+	mock.ExpectExec("UPDATE sync_jobs SET job_id").WithArgs(int64(9), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	code, response := serveSyncRequest(t, router, "POST", "/sync/batch?limit=3")
+
+	require.Equal(t, http.StatusAccepted, code)
+	data := response["data"].(map[string]interface{})
+	assert.Equal(t, float64(5), data["id"])
+	assert.Equal(t, float64(9), data["job_id"])
+	assert.Equal(t, jobs.StatusQueued, data["status"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTriggerBatchSync_ConflictWhileActive(t *testing.T) {
+	router, mock := newSyncRouter(t)
+
+	mock.ExpectQuery("INSERT INTO sync_jobs").
+		WillReturnError(&pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"})
+	mock.ExpectQuery("SELECT id FROM sync_jobs").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+
+	code, response := serveSyncRequest(t, router, "POST", "/sync/batch")
+
+	assert.Equal(t, http.StatusConflict, code)
+	envelope := responseError(t, response)
+	assert.Equal(t, string(CodeConflict), envelope["code"])
+	assert.Equal(t, float64(4), envelope["details"].(map[string]interface{})["active_job_id"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSyncJob_ReportsProgress(t *testing.T) {
+	router, mock := newSyncRouter(t)
+	startedAt := time.Now().Add(-time.Minute)
+
+	mock.ExpectQuery("FROM sync_jobs").WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows(syncJobColumns).
+			AddRow(5, 9, jobs.StatusRunning, 3, 2, 1, 1, "", time.Now(), startedAt, nil))
+
+	code, response := serveSyncRequest(t, router, "GET", "/sync/jobs/5")
+
+	require.Equal(t, http.StatusOK, code)
+	data := response["data"].(map[string]interface{})
+	assert.Equal(t, jobs.StatusRunning, data["status"])
+	assert.Equal(t, float64(2), data["attempted"])
+	assert.Equal(t, float64(1), data["successful"])
+	assert.Equal(t, float64(1), data["failed"])
+	assert.NotNil(t, data["started_at"])
+	assert.NotContains(t, data, "finished_at")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSyncJob_NotFound(t *testing.T) {
+	router, mock := newSyncRouter(t)
+
+	mock.ExpectQuery("FROM sync_jobs").WithArgs(int64(6)).
+		WillReturnRows(sqlmock.NewRows(syncJobColumns))
+	code, _ := serveSyncRequest(t, router, "GET", "/sync/jobs/6")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = serveSyncRequest(t, router, "GET", "/sync/jobs/abc")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCancelSyncJob_Responses(t *testing.T) {
+	router, mock := newSyncRouter(t)
+
+	// Finished batches cannot be cancelled
+	mock.ExpectQuery("FROM sync_jobs").WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows(syncJobColumns).
+			AddRow(5, 9, jobs.StatusSucceeded, 3, 3, 3, 0, "", time.Now(), time.Now(), time.Now()))
+	code, _ := serveSyncRequest(t, router, "DELETE", "/sync/jobs/5")
+	assert.Equal(t, http.StatusConflict, code)
+
+	// Nor can one this server's queue does not hold
+	mock.ExpectQuery("FROM sync_jobs").WithArgs(int64(6)).
+		WillReturnRows(sqlmock.NewRows(syncJobColumns).
+			AddRow(6, 10, jobs.StatusRunning, 3, 1, 1, 0, "", time.Now(), time.Now(), nil))
+	code, _ = serveSyncRequest(t, router, "DELETE", "/sync/jobs/6")
+	assert.Equal(t, http.StatusConflict, code)
+
+	mock.ExpectQuery("FROM sync_jobs").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(syncJobColumns))
+	code, _ = serveSyncRequest(t, router, "DELETE", "/sync/jobs/7")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSyncHistory_Filters(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		where string
+		args  []driver.Value
+		limit int
+	}{
+		{name: "no filter", query: "", where: "", limit: 50},
+		{name: "symbol", query: "?symbol=aapl", where: ` WHERE symbol = \$1`, args: []driver.Value{"AAPL"}, limit: 50},
+		{name: "failed in the last week", query: "?days=7&status=failed",
+			where: ` WHERE created_at >= .+ AND success = \$2`, args: []driver.Value{7, false}, limit: 50},
+		{name: "all filters", query: "?symbol=AAPL&days=7&status=succeeded&limit=500",
+			where: ` WHERE symbol = \$1 AND created_at >= .+ AND success = \$3`, args: []driver.Value{"AAPL", 7, true}, limit: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mock := newSyncRouter(t)
+
+			mock.ExpectQuery(`SELECT COUNT\(\*\) FROM sync_history` + tt.where + "$").WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+			mock.ExpectQuery("FROM sync_history").WithArgs(append(tt.args, tt.limit, 2)...).
+				WillReturnRows(sqlmock.NewRows([]string{
+					"id", "symbol", "success", "error_kind", "error_message",
+					"records_added", "duration_ms", "triggered_by", "created_at",
+				}).AddRow(1, "AAPL", false, "rate_limited", "", 0, 120, "scheduler", time.Now()))
+
+			sep := "?"
+			if tt.query != "" {
+				sep = "&"
+			}
+			code, response := serveSyncRequest(t, router, "GET", "/sync/history"+tt.query+sep+"offset=2")
+
+			require.Equal(t, http.StatusOK, code)
+			assert.Equal(t, float64(3), response["total"])
+			assert.Equal(t, float64(1), response["count"])
+			assert.Equal(t, false, response["has_more"])
+			entry := response["data"].([]interface{})[0].(map[string]interface{})
+			assert.Equal(t, "rate_limited", entry["error_kind"])
+			assert.Equal(t, "scheduler", entry["triggered_by"])
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetSyncHistory_RejectsInvalidFilters(t *testing.T) {
+	router, mock := newSyncRouter(t)
+
+	for _, query := range []string{"?days=-1", "?days=week", "?status=pending"} {
+		code, _ := serveSyncRequest(t, router, "GET", "/sync/history"+query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
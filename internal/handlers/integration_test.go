@@ -57,7 +57,7 @@ func (suite *IntegrationTestSuite) SetupSuite() {
 
 	// Setup router with handlers
 	suite.router = gin.New()
-	stockHandler := NewDatabaseStockHandler(stockService)
+	stockHandler := NewDatabaseStockHandler(stockService, stockService)
 
 	api := suite.router.Group("/api/v1")
 	{
@@ -89,7 +89,6 @@ func (suite *IntegrationTestSuite) setupTestData() {
 			Industry:      "Consumer Electronics",
 			Exchange:      "NASDAQ",
 			MarketCap:     &[]int64{3000000000000}[0],
-			PriceRange:    "$150+",
 			IsActive:      true,
 			CurrentPrice:  150.25,
 			DailyChange:   2.50,
@@ -103,7 +102,6 @@ func (suite *IntegrationTestSuite) setupTestData() {
 			Industry:      "Software",
 			Exchange:      "NASDAQ",
 			MarketCap:     &[]int64{2800000000000}[0],
-			PriceRange:    "$300+",
 			IsActive:      true,
 			CurrentPrice:  320.15,
 			DailyChange:   -1.25,
@@ -117,7 +115,6 @@ func (suite *IntegrationTestSuite) setupTestData() {
 			Industry:      "Internet Content & Information",
 			Exchange:      "NASDAQ",
 			MarketCap:     &[]int64{1600000000000}[0],
-			PriceRange:    "$100+",
 			IsActive:      true,
 			CurrentPrice:  125.50,
 			DailyChange:   3.25,
@@ -133,7 +130,7 @@ func (suite *IntegrationTestSuite) setupTestData() {
 			 current_price, daily_change, change_percent, volume, created_at, updated_at, last_updated)
 			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW(), NOW())`,
 			stock.Symbol, stock.CompanyName, stock.Sector, stock.Industry, stock.Exchange,
-			*stock.MarketCap, stock.PriceRange, stock.IsActive, stock.CurrentPrice,
+			*stock.MarketCap, services.DefaultPriceRanges().Bucket(stock.CurrentPrice), stock.IsActive, stock.CurrentPrice,
 			stock.DailyChange, stock.ChangePercent, stock.Volume)
 		
 		if err != nil {
@@ -224,8 +221,9 @@ func (suite *IntegrationTestSuite) TestGetStockBySymbolNotFound() {
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(suite.T(), err)
 
-	assert.False(suite.T(), response["success"].(bool))
-	assert.Contains(suite.T(), response["error"].(string), "not found")
+	envelope := responseError(suite.T(), response)
+	assert.Equal(suite.T(), string(CodeNotFound), envelope["code"])
+	assert.Contains(suite.T(), envelope["message"].(string), "not found")
 }
 
 // TestGetMarketOverview tests the GET /api/v1/market/overview endpoint
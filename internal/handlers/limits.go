@@ -0,0 +1,43 @@
+package handlers
+
+// Query parameter defaults and limits. The handlers apply them and the
+// OpenAPI document publishes them, so the two cannot drift apart.
+const (
+	// defaultPageSize and maxPageSize bound every paginated list; larger limits are capped
+	defaultPageSize = 50
+	maxPageSize     = 200
+
+	// defaultPerformanceDays suits the mini charts; maxPerformanceDays is one year
+	defaultPerformanceDays = 30
+	maxPerformanceDays     = 365
+
+	// defaultCorrelationDays leaves room for services.MinCorrelationObservations overlapping returns
+	defaultCorrelationDays = 90
+
+	// defaultBetaDays is a year of trading days against the S&P 500 ETF
+	defaultBetaDays      = 252
+	defaultBetaBenchmark = "SPY"
+
+	defaultIntradayInterval = "5min"
+	defaultMoverWindow      = "1d"
+
+	// maxBatchSyncLimit is a safety cap on the stocks one batch sync fetches
+	defaultBatchSyncLimit = 24
+	maxBatchSyncLimit     = 25
+
+	defaultPendingStocksLimit   = 25
+	defaultSyncQueueLimit       = 20
+	defaultDividendLeadersLimit = 20
+	defaultNewsLimit            = 20
+
+	defaultAPIHistoryDays = 7
+	maxAPIHistoryDays     = 30
+
+	defaultMinGapDays = 1
+
+	// maxGraphQLDepth and maxGraphQLComplexity bound a GraphQL query before it
+	// runs; the complexity counts fields, multiplying those under stocks by its
+	// limit and those under prices by its days
+	maxGraphQLDepth      = 5
+	maxGraphQLComplexity = 10000
+)
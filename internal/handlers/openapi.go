@@ -0,0 +1,802 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"stock-intelligence-backend/internal/models"
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPIVersion is the OpenAPI release the document is written against
+const OpenAPIVersion = "3.0.3"
+
+// OpenAPIDocument is the subset of an OpenAPI 3 document the API needs
+type OpenAPIDocument struct {
+	OpenAPI    string                              `json:"openapi"`
+	Info       OpenAPIInfo                         `json:"info"`
+	Paths      map[string]map[string]*APIOperation `json:"paths"`
+	Components OpenAPIComponents                   `json:"components"`
+}
+
+// OpenAPIInfo describes the API as a whole
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIComponents holds the schemas and security schemes operations refer to
+type OpenAPIComponents struct {
+	Schemas         map[string]*APISchema         `json:"schemas"`
+	SecuritySchemes map[string]*APISecurityScheme `json:"securitySchemes"`
+}
+
+// APISecurityScheme is an API key passed in a header
+type APISecurityScheme struct {
+	Type string `json:"type"`
+	In   string `json:"in"`
+	Name string `json:"name"`
+}
+
+// APIOperation is one method on one path
+type APIOperation struct {
+	Summary     string                  `json:"summary"`
+	Tags        []string                `json:"tags"`
+	Parameters  []*APIParameter         `json:"parameters,omitempty"`
+	RequestBody *APIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*APIResponse `json:"responses"`
+	Security    []map[string][]string   `json:"security,omitempty"`
+}
+
+// APIParameter is a path or query parameter
+type APIParameter struct {
+	Name        string     `json:"name"`
+	In          string     `json:"in"`
+	Description string     `json:"description,omitempty"`
+	Required    bool       `json:"required"`
+	Style       string     `json:"style,omitempty"`
+	Explode     *bool      `json:"explode,omitempty"`
+	Schema      *APISchema `json:"schema"`
+}
+
+// APIRequestBody is a JSON request body
+type APIRequestBody struct {
+	Required bool                     `json:"required"`
+	Content  map[string]*APIMediaType `json:"content"`
+}
+
+// APIResponse is one status an operation may answer with
+type APIResponse struct {
+	Description string                   `json:"description"`
+	Content     map[string]*APIMediaType `json:"content,omitempty"`
+}
+
+// APIMediaType holds the schema of a body
+type APIMediaType struct {
+	Schema *APISchema `json:"schema"`
+}
+
+// APISchema is the subset of JSON Schema the document uses
+type APISchema struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Type        string                `json:"type,omitempty"`
+	Format      string                `json:"format,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Enum        []interface{}         `json:"enum,omitempty"`
+	Default     interface{}           `json:"default,omitempty"`
+	Minimum     *int                  `json:"minimum,omitempty"`
+	Maximum     *int                  `json:"maximum,omitempty"`
+	MinItems    *int                  `json:"minItems,omitempty"`
+	MaxItems    *int                  `json:"maxItems,omitempty"`
+	MaxLength   *int                  `json:"maxLength,omitempty"`
+	Pattern     string                `json:"pattern,omitempty"`
+	Items       *APISchema            `json:"items,omitempty"`
+	Properties  map[string]*APISchema `json:"properties,omitempty"`
+	Required    []string              `json:"required,omitempty"`
+}
+
+// OpenAPIHandler serves the API's OpenAPI document and a Swagger UI page for it
+type OpenAPIHandler struct {
+	spec []byte
+}
+
+// NewOpenAPIHandler builds the document once; priceRanges supplies the
+// price range labels the stock endpoints accept and apiKeyHeader the header
+// protected endpoints read the API key from
+func NewOpenAPIHandler(priceRanges *services.PriceRanges, apiKeyHeader string) *OpenAPIHandler {
+	spec, err := json.Marshal(BuildOpenAPIDocument(priceRanges, apiKeyHeader))
+	if err != nil {
+		panic("openapi: " + err.Error()) // Only plain structs are marshalled
+	}
+	return &OpenAPIHandler{spec: spec}
+}
+
+// GetSpec serves the OpenAPI document
+func (h *OpenAPIHandler) GetSpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", h.spec)
+}
+
+// GetDocs serves a Swagger UI page that loads the document
+func (h *OpenAPIHandler) GetDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Stock Intelligence API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/api/v1/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// BuildOpenAPIDocument describes every route newRouter registers. Limits and
+// enums come from the constants the handlers validate against.
+func BuildOpenAPIDocument(priceRanges *services.PriceRanges, apiKeyHeader string) *OpenAPIDocument {
+	if priceRanges == nil {
+		priceRanges = services.DefaultPriceRanges()
+	}
+	rangeLabels := priceRanges.Labels()
+
+	doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info: OpenAPIInfo{
+			Title:   "Stock Intelligence API",
+			Version: "1.0.0",
+			Description: "Stock data, market analytics and data sync management. Limits above their maximum are capped rather than rejected. " +
+				"Each client IP may make a limited number of /api/v1 requests a minute; every response carries X-RateLimit-Limit and X-RateLimit-Remaining. " +
+				"GET responses of the /stocks and /market routes may be served from a cache that every data sync clears, as X-Cache: HIT with their Age; requests with an API key bypass it.",
+		},
+		Paths: map[string]map[string]*APIOperation{},
+		Components: OpenAPIComponents{
+			Schemas: map[string]*APISchema{
+				"Error": {
+					Type:     "object",
+					Required: []string{"success", "error"},
+					Properties: map[string]*APISchema{
+						"success": {Type: "boolean", Enum: []interface{}{false}},
+						"error": {
+							Type:     "object",
+							Required: []string{"code", "message", "request_id"},
+							Properties: map[string]*APISchema{
+								"code": {Type: "string", Enum: []interface{}{
+									CodeValidation, CodeUnauthorized, CodeNotFound, CodeConflict,
+									CodeRateLimited, CodeTimeout, CodeInternal,
+								}},
+								"message":    {Type: "string"},
+								"details":    {Description: "More about the failure, when there is any"},
+								"request_id": {Type: "string"},
+							},
+						},
+					},
+				},
+			},
+			SecuritySchemes: map[string]*APISecurityScheme{
+				"apiKey": {Type: "apiKey", In: "header", Name: apiKeyHeader},
+			},
+		},
+	}
+
+	add := func(method, path, tag, summary string, op *APIOperation) {
+		if op == nil {
+			op = &APIOperation{}
+		}
+		op.Summary = summary
+		op.Tags = []string{tag}
+		if op.Responses == nil {
+			op.Responses = map[string]*APIResponse{}
+		}
+		if len(op.Responses) == 0 {
+			op.Responses["200"] = jsonResponse("Success")
+		}
+		if len(op.Parameters) > 0 || op.RequestBody != nil {
+			op.Responses["400"] = errorResponse("Invalid parameters")
+		}
+		if op.Security != nil {
+			op.Responses["401"] = errorResponse("Missing or invalid API key")
+		}
+		if strings.HasPrefix(path, "/api/v1/") && op.Responses["429"] == nil {
+			op.Responses["429"] = errorResponse("Rate limit exceeded; retry after the Retry-After seconds")
+		}
+		op.Responses["500"] = errorResponse("Server error")
+
+		openAPIPath := toOpenAPIPath(path)
+		if doc.Paths[openAPIPath] == nil {
+			doc.Paths[openAPIPath] = map[string]*APIOperation{}
+		}
+		doc.Paths[openAPIPath][strings.ToLower(method)] = op
+	}
+
+	symbol := pathParameter("symbol", "Ticker symbol; class shares may be written BRK-B or BRK.B", symbolSchema())
+	id := pathParameter("id", "ID", &APISchema{Type: "integer", Format: "int64", Minimum: intPtr(1)})
+	protected := []map[string][]string{{"apiKey": {}}}
+
+	add(http.MethodGet, "/health", "health", "Liveness check, with the running build", nil)
+	add(http.MethodGet, "/ws", "websocket", "WebSocket for price updates and market data", &APIOperation{
+		Responses: map[string]*APIResponse{"101": {Description: "Switching to the WebSocket protocol"}, "429": errorResponse("Too many connections")},
+	})
+	add(http.MethodGet, "/api/v1/stream/prices", "websocket", "Server-Sent Events stream of the WebSocket's price updates", &APIOperation{
+		Parameters: []*APIParameter{{
+			Name: "Last-Event-ID", In: "header", Description: "Set when reconnecting; the stream restarts with every stock as a price_update",
+			Schema: &APISchema{Type: "string"},
+		}},
+		Responses: map[string]*APIResponse{
+			"200": {Description: "An event stream of initial and price_update events, with keepalive comments"},
+			"429": errorResponse("Too many connections"),
+		},
+	})
+	add(http.MethodPost, "/api/v1/graphql", "graphql", "GraphQL query over stocks, their prices and the market overview", &APIOperation{
+		RequestBody: jsonBody(&APISchema{
+			Type:     "object",
+			Required: []string{"query"},
+			Properties: map[string]*APISchema{
+				"query":         {Type: "string", Description: "Fields are stocks(filter, sort, limit, offset), stock(symbol) and marketOverview; stocks have prices(days, adjusted)"},
+				"operationName": {Type: "string"},
+				"variables":     {Type: "object"},
+			},
+		}),
+		Responses: map[string]*APIResponse{
+			"200": {
+				Description: fmt.Sprintf("A GraphQL result of data and errors. Queries nested deeper than %d fields or of complexity above %d are refused in errors.",
+					maxGraphQLDepth, maxGraphQLComplexity),
+				Content: map[string]*APIMediaType{"application/json": {Schema: &APISchema{
+					Type: "object",
+					Properties: map[string]*APISchema{
+						"data":   {Type: "object"},
+						"errors": {Type: "array", Items: &APISchema{Type: "object"}},
+					},
+				}}},
+			},
+		},
+	})
+	add(http.MethodGet, "/api/v1/openapi.json", "docs", "This OpenAPI document", nil)
+	add(http.MethodGet, "/api/v1/docs", "docs", "Swagger UI for this document", &APIOperation{
+		Responses: map[string]*APIResponse{"200": {Description: "HTML page"}},
+	})
+
+	// Stocks
+	add(http.MethodGet, "/api/v1/stocks", "stocks", "List active stocks with their latest prices", &APIOperation{
+		Parameters: []*APIParameter{
+			queryParameter("sector", "Only stocks in this sector, one of the sectors /market/filters lists", &APISchema{Type: "string"}),
+			queryParameter("price_range", "Only stocks in this price bucket", enumSchema(rangeLabels, nil)),
+			queryParameter("market_cap", "Only stocks in this market cap band; by default large is $10B and up, mid $2B up to $10B and small below $2B",
+				enumSchema(services.MarketCapBands, nil)),
+			queryParameter("exchange", "Only stocks listed on this exchange", enumSchema(services.Exchanges, nil)),
+			limitParameter(defaultPageSize, maxPageSize),
+			offsetParameter(),
+			queryParameter("cursor", "Page after this next_cursor instead of by offset; empty for the first page. Cursor pages carry next_cursor, null on the last page, instead of total and has_more",
+				&APISchema{Type: "string"}),
+			queryParameter("min_history_days", "Only stocks whose stored prices span at least this many days",
+				&APISchema{Type: "integer", Minimum: intPtr(0), Default: 0}),
+			commaSeparatedParameter("fields", "Only these fields of each stock", enumSchema(stockFields, nil)),
+			queryParameter("view", "compact is "+strings.Join(compactStockFields, ", ")+"; cannot be combined with fields",
+				enumSchema(stockViews, viewFull)),
+		},
+		Responses: map[string]*APIResponse{
+			"200": jsonResponse("A page of stocks"),
+			"400": errorResponse("Invalid filter; an unknown sector lists the valid_sectors"),
+			"504": errorResponse("Database query timed out"),
+		},
+	})
+	add(http.MethodGet, "/api/v1/stocks/:symbol", "stocks", "One stock with its latest price; delisted stocks included", &APIOperation{
+		Parameters: []*APIParameter{symbol},
+		Responses: map[string]*APIResponse{
+			"200": jsonResponse("The stock, with dividend_yield_ttm null without a price or a dividend in the past year"),
+			"404": errorResponse("Unknown symbol"),
+		},
+	})
+	add(http.MethodGet, "/api/v1/stocks/:symbol/dividends", "stocks", "Cash dividends by ex-date, latest first", &APIOperation{
+		Parameters: []*APIParameter{symbol},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("The dividends"), "404": errorResponse("Unknown symbol")},
+	})
+	add(http.MethodGet, "/api/v1/stocks/:symbol/news", "stocks", "Latest news articles with their sentiment, and the average sentiment over them", &APIOperation{
+		Parameters: []*APIParameter{symbol, limitParameter(defaultNewsLimit, maxPageSize)},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("The articles, latest first"), "404": errorResponse("Unknown symbol")},
+	})
+	add(http.MethodGet, "/api/v1/stocks/:symbol/performance", "stocks", "Daily closes for the stock's chart", &APIOperation{
+		Parameters: []*APIParameter{
+			symbol,
+			queryParameter("days", "Days of history", &APISchema{
+				Type: "integer", Minimum: intPtr(1), Maximum: intPtr(maxPerformanceDays), Default: defaultPerformanceDays,
+			}),
+			queryParameter("adjusted", "Split- and dividend-adjusted closes", &APISchema{Type: "boolean", Default: false}),
+		},
+	})
+	add(http.MethodGet, "/api/v1/stocks/:symbol/prices", "stocks", "Daily prices, or weekly or monthly bars aggregated from them", &APIOperation{
+		Parameters: []*APIParameter{
+			symbol,
+			queryParameter("resolution", "Period of each bar; weeks are ISO weeks starting on Monday",
+				enumSchema(services.PriceResolutions, string(services.PriceResolutionDaily))),
+			queryParameter("from", "First day of the range; open-ended when omitted", &APISchema{Type: "string", Format: "date"}),
+			queryParameter("to", "Last day of the range; open-ended when omitted", &APISchema{Type: "string", Format: "date"}),
+		},
+		Responses: map[string]*APIResponse{
+			"200": jsonResponse("Bars oldest first; a period still in progress or cut by the range is flagged partial"),
+			"404": errorResponse("Unknown symbol"),
+		},
+	})
+	benchmark := symbolSchema()
+	benchmark.Default = defaultBetaBenchmark
+	add(http.MethodGet, "/api/v1/stocks/:symbol/beta", "stocks", "Beta and R² of the stock's daily returns against a benchmark", &APIOperation{
+		Parameters: []*APIParameter{
+			symbol,
+			queryParameter("benchmark", "A stored stock or ETF to measure against", benchmark),
+			queryParameter("days", "Latest trading days of closes to regress over", &APISchema{
+				Type: "integer", Minimum: intPtr(1), Maximum: intPtr(maxPerformanceDays), Default: defaultBetaDays,
+			}),
+		},
+		Responses: map[string]*APIResponse{
+			"200": jsonResponse(fmt.Sprintf("With fewer than %d overlapping returns beta is null with a reason", services.MinCorrelationObservations)),
+			"409": errorResponse("The benchmark has no stored prices and must be synced first"),
+		},
+	})
+	add(http.MethodGet, "/api/v1/stocks/:symbol/intraday", "stocks", "Intraday bars for one trading day", &APIOperation{
+		Parameters: []*APIParameter{
+			symbol,
+			queryParameter("interval", "Bar size", enumSchema(services.IntradayIntervals, defaultIntradayInterval)),
+			queryParameter("date", "Trading day; the latest day with bars when omitted", &APISchema{Type: "string", Format: "date"}),
+		},
+	})
+	add(http.MethodGet, "/api/v1/stocks/:symbol/fundamentals", "stocks", "Company fundamentals, flagged stale when old", &APIOperation{
+		Parameters: []*APIParameter{symbol},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("The fundamentals"), "404": errorResponse("No fundamentals stored")},
+	})
+	add(http.MethodGet, "/api/v1/stocks/price-range", "stocks", "Stocks in one price bucket", &APIOperation{
+		Parameters: []*APIParameter{requiredQueryParameter("range", "Price bucket", enumSchema(rangeLabels, nil))},
+	})
+	add(http.MethodPost, "/api/v1/stocks/quotes", "stocks", "Latest quotes for a list of symbols", &APIOperation{
+		RequestBody: jsonBody(&APISchema{
+			Type:     "object",
+			Required: []string{"symbols"},
+			Properties: map[string]*APISchema{
+				"symbols": {Type: "array", MinItems: intPtr(1), MaxItems: intPtr(services.MaxQuoteSymbols), Items: symbolSchema()},
+			},
+		}),
+		Responses: map[string]*APIResponse{"200": jsonResponse("Quotes for known symbols; unknown ones are listed in not_found")},
+	})
+	correlationSymbols := commaSeparatedParameter("symbols", "The stocks to correlate", symbolSchema())
+	correlationSymbols.Required = true
+	correlationSymbols.Schema.MinItems = intPtr(2)
+	correlationSymbols.Schema.MaxItems = intPtr(services.MaxCorrelationSymbols)
+	add(http.MethodGet, "/api/v1/stocks/correlation", "stocks", "Correlation matrix of daily returns", &APIOperation{
+		Parameters: []*APIParameter{
+			correlationSymbols,
+			queryParameter("days", "Latest trading days of closes to correlate over", &APISchema{
+				Type: "integer", Minimum: intPtr(1), Maximum: intPtr(maxPerformanceDays), Default: defaultCorrelationDays,
+			}),
+		},
+		Responses: map[string]*APIResponse{
+			"200": jsonResponse(fmt.Sprintf("Pairs with fewer than %d overlapping returns are null with a reason", services.MinCorrelationObservations)),
+		},
+	})
+
+	// Market
+	add(http.MethodGet, "/api/v1/market/performance", "market", "Top gainers and losers, and the stocks trading furthest above their average volume", &APIOperation{
+		Responses: map[string]*APIResponse{
+			"200": jsonResponse("The rankings; most_active carries each stock's avg_volume_30d and relative_volume, and flags with ranked_by_volume the stocks without the history for an average, listed after the others by volume"),
+			"504": errorResponse("Database query timed out"),
+		},
+	})
+	add(http.MethodGet, "/api/v1/market/overview", "market", "Advancing and declining counts and the average change", &APIOperation{
+		Responses: map[string]*APIResponse{"200": jsonResponse("The overview"), "504": errorResponse("Database query timed out")},
+	})
+	add(http.MethodGet, "/api/v1/market/heatmap", "market", "Active stocks grouped by sector with their market cap and change", &APIOperation{
+		Responses: map[string]*APIResponse{
+			"200": jsonResponse("An object keyed by sector, largest total market cap first; stocks without a market cap or sector are under " + services.UnknownSector),
+			"504": errorResponse("Database query timed out"),
+		},
+	})
+	add(http.MethodGet, "/api/v1/market/movers", "market", "Top gainers, losers and most traded stocks over a lookback window", &APIOperation{
+		Parameters: []*APIParameter{queryParameter("window", "Lookback window", enumSchema(services.MoverWindows, defaultMoverWindow))},
+		Responses: map[string]*APIResponse{
+			"200": jsonResponse("The rankings, with the count of stocks lacking the history for the window"),
+			"504": errorResponse("Database query timed out"),
+		},
+	})
+	add(http.MethodGet, "/api/v1/market/volume-spikes", "market", "Stocks trading at a multiple of their average volume", &APIOperation{
+		Parameters: []*APIParameter{
+			queryParameter("min_ratio", "The least latest volume over the average of the 30 trading days before it",
+				&APISchema{Type: "number", Default: services.DefaultVolumeSpikeRatio}),
+		},
+		Responses: map[string]*APIResponse{
+			"200": jsonResponse("The stocks, highest relative volume first; stocks without 30 earlier trading days are left out"),
+			"400": errorResponse("min_ratio is not a positive number"),
+			"504": errorResponse("Database query timed out"),
+		},
+	})
+	add(http.MethodGet, "/api/v1/market/dividend-leaders", "market", "Highest trailing twelve month dividend yields", &APIOperation{
+		Parameters: []*APIParameter{limitParameter(defaultDividendLeadersLimit, maxPageSize)},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("Stocks that paid a dividend in the past year, by yield")},
+	})
+	add(http.MethodGet, "/api/v1/market/sectors", "market", "Sectors with stock counts", nil)
+	add(http.MethodGet, "/api/v1/market/filters", "market", "Sectors, price ranges and exchanges the active stocks have", &APIOperation{
+		Responses: map[string]*APIResponse{
+			"200": jsonResponse("The sector, price_range and exchange values of /stocks that match some stock; an unknown sector is rejected with these as valid_sectors"),
+			"504": errorResponse("Database query timed out"),
+		},
+	})
+	add(http.MethodGet, "/api/v1/market/data-source", "market", "Where the stock data comes from", nil)
+
+	// System
+	add(http.MethodGet, "/api/v1/system/health", "system", "Health of the database, cache, data freshness, provider and scheduler", &APIOperation{
+		Security: protected,
+		Responses: map[string]*APIResponse{
+			"200": jsonResponse("Healthy or degraded: the worst component status, with each component's latency"),
+			"503": jsonResponse("Unhealthy, with the same body"),
+		},
+	})
+	add(http.MethodGet, "/api/v1/system/version", "system", "Version, commit and build time of the running server, with its uptime", &APIOperation{Security: protected})
+	add(http.MethodGet, "/api/v1/system/summary", "system", "One-call status summary for dashboards", &APIOperation{Security: protected})
+	add(http.MethodGet, "/api/v1/system/api-status", "system", "Provider rate limits and call statistics", &APIOperation{Security: protected})
+	add(http.MethodGet, "/api/v1/system/sync-status", "system", "Freshness of the stored data", &APIOperation{Security: protected})
+	add(http.MethodGet, "/api/v1/system/api-history", "system", "Provider calls per day", &APIOperation{
+		Security: protected,
+		Parameters: []*APIParameter{queryParameter("days", "Days of history; out of range values use the default", &APISchema{
+			Type: "integer", Minimum: intPtr(1), Maximum: intPtr(maxAPIHistoryDays), Default: defaultAPIHistoryDays,
+		})},
+	})
+	add(http.MethodGet, "/api/v1/system/api-calls", "system", "Logged provider calls, newest first", &APIOperation{
+		Security: protected,
+		Parameters: []*APIParameter{
+			queryParameter("status", "Only successful or failed calls", enumSchema(services.APICallStatuses, nil)),
+			queryParameter("endpoint", "Only calls to this provider endpoint, e.g. TIME_SERIES_DAILY", &APISchema{Type: "string"}),
+			queryParameter("page", "Page number", &APISchema{Type: "integer", Minimum: intPtr(1), Default: 1}),
+			queryParameter("per_page", "Page size; larger values are capped", &APISchema{
+				Type: "integer", Minimum: intPtr(1), Maximum: intPtr(maxPageSize), Default: defaultPageSize,
+			}),
+		},
+		Responses: map[string]*APIResponse{
+			"200": jsonResponse("Calls with their stored response bodies, cut to API_CALL_BODY_MAX_BYTES"),
+			"400": errorResponse("Invalid page or status"),
+		},
+	})
+	add(http.MethodGet, "/api/v1/system/scheduler/runs", "system", "Recent scheduled job runs, newest first", &APIOperation{
+		Security: protected,
+		Parameters: []*APIParameter{
+			queryParameter("job", "Only runs of this job", enumSchema(services.SchedulerJobs, nil)),
+			limitParameter(defaultPageSize, maxPageSize),
+		},
+	})
+	add(http.MethodGet, "/api/v1/system/sync-queue", "system", "The stocks the scheduled sync picks next, with their scores", &APIOperation{
+		Security:   protected,
+		Parameters: []*APIParameter{limitParameter(defaultSyncQueueLimit, maxPageSize)},
+	})
+	add(http.MethodGet, "/api/v1/system/data-gaps", "system", "Stocks missing trading days in their stored prices", &APIOperation{
+		Security: protected,
+		Parameters: []*APIParameter{queryParameter("min_gap_days", "Only gaps of at least this many trading days", &APISchema{
+			Type: "integer", Minimum: intPtr(1), Default: defaultMinGapDays,
+		})},
+	})
+	add(http.MethodGet, "/api/v1/system/cache", "system", "Cache hits, misses, sets and errors per key class, with the keys and memory held", &APIOperation{Security: protected})
+	add(http.MethodPost, "/api/v1/system/cache/reset", "system", "Reset the cache statistics, keeping the cached data", &APIOperation{Security: protected})
+	add(http.MethodPost, "/api/v1/system/scheduler/pause", "system", "Pause the scheduled jobs", &APIOperation{Security: protected})
+	add(http.MethodPost, "/api/v1/system/scheduler/resume", "system", "Resume the scheduled jobs", &APIOperation{Security: protected})
+	add(http.MethodPost, "/api/v1/system/sync/:symbol", "system", "Queue a manual sync of one stock", &APIOperation{
+		Security: protected,
+		Parameters: []*APIParameter{symbol, queryParameter("mode",
+			"full fetches the missing daily history; quote updates only the latest trading day from one quote",
+			enumSchema(services.SyncModes, string(services.SyncModeFull)))},
+		Responses: map[string]*APIResponse{
+			"202": jsonResponse("The queued sync job"),
+			"409": errorResponse("A sync of this symbol is already queued or running"),
+		},
+	})
+	add(http.MethodGet, "/api/v1/system/sync/:symbol/status", "system", "The symbol's latest manual sync", &APIOperation{
+		Security:   protected,
+		Parameters: []*APIParameter{symbol},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("The sync's state"), "404": errorResponse("No manual sync since the server started")},
+	})
+	add(http.MethodGet, "/api/v1/system/jobs/:id", "system", "A background job", &APIOperation{
+		Security:   protected,
+		Parameters: []*APIParameter{id},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("The job"), "404": errorResponse("Unknown job")},
+	})
+
+	// Sync
+	add(http.MethodPost, "/api/v1/sync/batch", "sync", "Start a background batch sync", &APIOperation{
+		Security: protected,
+		Parameters: []*APIParameter{queryParameter("limit", "Stocks to sync; larger values are capped", &APISchema{
+			Type: "integer", Minimum: intPtr(1), Maximum: intPtr(maxBatchSyncLimit), Default: defaultBatchSyncLimit,
+		})},
+		Responses: map[string]*APIResponse{
+			"202": jsonResponse("The queued sync job"),
+			"409": errorResponse("A batch sync is already queued or running"),
+		},
+	})
+	add(http.MethodGet, "/api/v1/sync/status", "sync", "Historical data coverage", nil)
+	add(http.MethodGet, "/api/v1/sync/jobs/:id", "sync", "A batch sync's progress", &APIOperation{
+		Parameters: []*APIParameter{id},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("The sync job"), "404": errorResponse("Unknown sync job")},
+	})
+	add(http.MethodDelete, "/api/v1/sync/jobs/:id", "sync", "Cancel a batch sync", &APIOperation{
+		Security:   protected,
+		Parameters: []*APIParameter{id},
+		Responses: map[string]*APIResponse{
+			"202": jsonResponse("Cancellation requested"),
+			"404": errorResponse("Unknown sync job"),
+			"409": errorResponse("The sync job already finished"),
+		},
+	})
+	add(http.MethodGet, "/api/v1/sync/history", "sync", "Per-stock sync outcomes, newest first", &APIOperation{
+		Parameters: []*APIParameter{
+			queryParameter("symbol", "Only outcomes for this stock", symbolSchema()),
+			queryParameter("days", "Only outcomes from the last this many days", &APISchema{Type: "integer", Minimum: intPtr(0)}),
+			queryParameter("status", "Only successful or failed outcomes", enumSchema([]string{"succeeded", "failed"}, nil)),
+			limitParameter(defaultPageSize, maxPageSize),
+			offsetParameter(),
+		},
+	})
+	add(http.MethodGet, "/api/v1/sync/pending", "sync", "Stocks waiting for historical data", &APIOperation{
+		Parameters: []*APIParameter{queryParameter("limit", "Page size", &APISchema{
+			Type: "integer", Minimum: intPtr(1), Default: defaultPendingStocksLimit,
+		})},
+	})
+
+	// Watchlists
+	add(http.MethodPost, "/api/v1/watchlists", "watchlists", "Create a watchlist", &APIOperation{
+		Security: protected,
+		RequestBody: jsonBody(&APISchema{
+			Type:       "object",
+			Required:   []string{"name"},
+			Properties: map[string]*APISchema{"name": {Type: "string"}},
+		}),
+		Responses: map[string]*APIResponse{"201": jsonResponse("The new watchlist")},
+	})
+	add(http.MethodGet, "/api/v1/watchlists/:id", "watchlists", "A watchlist with each stock's latest price", &APIOperation{
+		Parameters: []*APIParameter{id},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("The watchlist"), "404": errorResponse("Unknown watchlist")},
+	})
+	add(http.MethodPost, "/api/v1/watchlists/:id/symbols", "watchlists", "Add a stock to a watchlist", &APIOperation{
+		Security:   protected,
+		Parameters: []*APIParameter{id},
+		RequestBody: jsonBody(&APISchema{
+			Type:       "object",
+			Required:   []string{"symbol"},
+			Properties: map[string]*APISchema{"symbol": symbolSchema()},
+		}),
+		Responses: map[string]*APIResponse{
+			"200": jsonResponse("The watchlist"),
+			"404": errorResponse("Unknown watchlist"),
+			"422": errorResponse("Unknown stock symbol"),
+		},
+	})
+	add(http.MethodDelete, "/api/v1/watchlists/:id/symbols/:symbol", "watchlists", "Remove a stock from a watchlist", &APIOperation{
+		Security:   protected,
+		Parameters: []*APIParameter{id, symbol},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("The watchlist"), "404": errorResponse("Unknown watchlist")},
+	})
+
+	// Portfolios
+	add(http.MethodPost, "/api/v1/portfolios", "portfolios", "Create a portfolio", &APIOperation{
+		Security: protected,
+		RequestBody: jsonBody(&APISchema{
+			Type:       "object",
+			Required:   []string{"name"},
+			Properties: map[string]*APISchema{"name": {Type: "string"}},
+		}),
+		Responses: map[string]*APIResponse{"201": jsonResponse("The new portfolio")},
+	})
+	add(http.MethodPost, "/api/v1/portfolios/:id/transactions", "portfolios", "Record a buy or sell", &APIOperation{
+		Security:   protected,
+		Parameters: []*APIParameter{id},
+		RequestBody: jsonBody(&APISchema{
+			Type:     "object",
+			Required: []string{"symbol", "side", "quantity", "price"},
+			Properties: map[string]*APISchema{
+				"symbol":      symbolSchema(),
+				"side":        {Type: "string", Enum: []interface{}{"buy", "sell"}},
+				"quantity":    {Type: "number", Description: "Shares, up to 6 decimal places; greater than 0"},
+				"price":       {Type: "number", Description: "Price per share; greater than 0"},
+				"executed_at": {Type: "string", Format: "date-time", Description: "When the trade executed; now when omitted, and never in the future"},
+			},
+		}),
+		Responses: map[string]*APIResponse{
+			"201": jsonResponse("The recorded transaction"),
+			"404": errorResponse("Unknown portfolio"),
+			"422": errorResponse("Unknown stock symbol, or a sell of more than the portfolio holds"),
+		},
+	})
+	add(http.MethodGet, "/api/v1/portfolios/:id/summary", "portfolios", "Positions valued at the latest close, with P&L", &APIOperation{
+		Parameters: []*APIParameter{id},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("The portfolio summary"), "404": errorResponse("Unknown portfolio")},
+	})
+
+	// Stock administration
+	add(http.MethodGet, "/api/v1/admin/symbol-search", "admin", "Search the market data provider for listings to add; spends one API call", &APIOperation{
+		Security: protected,
+		Parameters: []*APIParameter{
+			requiredQueryParameter("q", "Ticker or company name keywords", &APISchema{Type: "string", MaxLength: intPtr(maxSearchLength)}),
+		},
+		Responses: map[string]*APIResponse{
+			"200": jsonResponse("Matching listings, best first"),
+			"429": errorResponse("API budget exhausted"),
+			"501": errorResponse("The market data provider cannot search symbols"),
+		},
+	})
+	add(http.MethodPost, "/api/v1/admin/stocks", "admin", "Start tracking a stock; it is queued for its first sync at once", &APIOperation{
+		Security: protected,
+		RequestBody: jsonBody(&APISchema{
+			Type:     "object",
+			Required: []string{"symbol", "company_name", "exchange"},
+			Properties: map[string]*APISchema{
+				"symbol":       symbolSchema(),
+				"company_name": {Type: "string", MaxLength: intPtr(maxCompanyNameLength)},
+				"exchange":     {Type: "string", MaxLength: intPtr(maxExchangeLength)},
+				"region":       {Type: "string", MaxLength: intPtr(maxRegionLength)},
+			},
+		}),
+		Responses: map[string]*APIResponse{
+			"201": jsonResponse("The new stock"),
+			"409": errorResponse("The symbol is already listed"),
+		},
+	})
+	add(http.MethodPatch, "/api/v1/admin/stocks/:symbol", "admin", "Edit a stock's metadata; fields left out keep their values", &APIOperation{
+		Security:   protected,
+		Parameters: []*APIParameter{symbol},
+		RequestBody: jsonBody(&APISchema{
+			Type: "object",
+			Properties: map[string]*APISchema{
+				"company_name": {Type: "string", MaxLength: intPtr(maxCompanyNameLength)},
+				"sector":       {Type: "string", MaxLength: intPtr(maxSectorLength), Description: "Empty clears the sector"},
+				"industry":     {Type: "string", MaxLength: intPtr(maxIndustryLength), Description: "Empty clears the industry"},
+				"exchange":     {Type: "string", MaxLength: intPtr(maxExchangeLength)},
+				"is_active":    {Type: "boolean"},
+				"market_cap":   {Type: "integer", Format: "int64", Minimum: intPtr(0)},
+			},
+		}),
+		Responses: map[string]*APIResponse{"200": jsonResponse("The updated stock"), "404": errorResponse("Unknown stock")},
+	})
+	add(http.MethodPost, "/api/v1/admin/stocks/:symbol/deactivate", "admin", "Stop listing and syncing a stock, keeping its prices", &APIOperation{
+		Security:   protected,
+		Parameters: []*APIParameter{symbol},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("The updated stock"), "404": errorResponse("Unknown stock")},
+	})
+	add(http.MethodPost, "/api/v1/admin/stocks/:symbol/activate", "admin", "List and sync a deactivated stock again, clearing a delisting", &APIOperation{
+		Security:   protected,
+		Parameters: []*APIParameter{symbol},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("The updated stock"), "404": errorResponse("Unknown stock")},
+	})
+
+	// Webhooks
+	webhookEvents := make([]interface{}, len(services.WebhookEvents))
+	for i, event := range services.WebhookEvents {
+		webhookEvents[i] = event
+	}
+	webhookProperties := map[string]*APISchema{
+		"url":    {Type: "string", Format: "uri", Description: "Absolute http or https URL the events are POSTed to"},
+		"secret": {Type: "string", MaxLength: intPtr(maxWebhookSecretLength), Description: fmt.Sprintf("At least %d characters; signs each body as %s: sha256=<hex HMAC-SHA256>", minWebhookSecretLength, services.WebhookSignatureHeader)},
+		"events": {Type: "array", MinItems: intPtr(1), Items: &APISchema{Type: "string", Enum: webhookEvents}},
+		"active": {Type: "boolean"},
+	}
+	add(http.MethodGet, "/api/v1/admin/webhooks", "admin", "List webhooks, without their secrets", &APIOperation{
+		Security:  protected,
+		Responses: map[string]*APIResponse{"200": jsonResponse("Every webhook")},
+	})
+	add(http.MethodPost, "/api/v1/admin/webhooks", "admin", "Add a webhook; its secret is generated when left out and only shown in this response", &APIOperation{
+		Security: protected,
+		RequestBody: jsonBody(&APISchema{
+			Type:     "object",
+			Required: []string{"url", "events"},
+			Properties: map[string]*APISchema{
+				"url":    webhookProperties["url"],
+				"secret": webhookProperties["secret"],
+				"events": webhookProperties["events"],
+			},
+		}),
+		Responses: map[string]*APIResponse{"201": jsonResponse("The new webhook, with its secret")},
+	})
+	add(http.MethodGet, "/api/v1/admin/webhooks/:id", "admin", "Get a webhook, without its secret", &APIOperation{
+		Security:   protected,
+		Parameters: []*APIParameter{id},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("The webhook"), "404": errorResponse("Unknown webhook")},
+	})
+	add(http.MethodPatch, "/api/v1/admin/webhooks/:id", "admin", "Edit a webhook; fields left out keep their values", &APIOperation{
+		Security:    protected,
+		Parameters:  []*APIParameter{id},
+		RequestBody: jsonBody(&APISchema{Type: "object", Properties: webhookProperties}),
+		Responses:   map[string]*APIResponse{"200": jsonResponse("The updated webhook"), "404": errorResponse("Unknown webhook")},
+	})
+	add(http.MethodDelete, "/api/v1/admin/webhooks/:id", "admin", "Delete a webhook", &APIOperation{
+		Security:   protected,
+		Parameters: []*APIParameter{id},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("Deleted"), "404": errorResponse("Unknown webhook")},
+	})
+	add(http.MethodPost, "/api/v1/admin/webhooks/:id/test", "admin", "Send a webhook.test event once, without retries", &APIOperation{
+		Security:   protected,
+		Parameters: []*APIParameter{id},
+		Responses:  map[string]*APIResponse{"200": jsonResponse("The delivery: delivered, attempts, status_code and error"), "404": errorResponse("Unknown webhook")},
+	})
+
+	return doc
+}
+
+// toOpenAPIPath turns Gin's :param segments into OpenAPI's {param}
+func toOpenAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func symbolSchema() *APISchema {
+	return &APISchema{Type: "string", MaxLength: intPtr(models.MaxSymbolLength), Pattern: "^[A-Za-z0-9.-]+$"}
+}
+
+func enumSchema(values []string, defaultValue interface{}) *APISchema {
+	enum := make([]interface{}, len(values))
+	for i, value := range values {
+		enum[i] = value
+	}
+	return &APISchema{Type: "string", Enum: enum, Default: defaultValue}
+}
+
+func pathParameter(name, description string, schema *APISchema) *APIParameter {
+	return &APIParameter{Name: name, In: "path", Description: description, Required: true, Schema: schema}
+}
+
+func queryParameter(name, description string, schema *APISchema) *APIParameter {
+	return &APIParameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+// commaSeparatedParameter is a query parameter listing items as a,b,c
+func commaSeparatedParameter(name, description string, items *APISchema) *APIParameter {
+	explode := false
+	parameter := queryParameter(name, description, &APISchema{Type: "array", Items: items})
+	parameter.Style = "form"
+	parameter.Explode = &explode
+	return parameter
+}
+
+func requiredQueryParameter(name, description string, schema *APISchema) *APIParameter {
+	parameter := queryParameter(name, description, schema)
+	parameter.Required = true
+	return parameter
+}
+
+func limitParameter(defaultLimit, maxLimit int) *APIParameter {
+	return queryParameter("limit", "Page size; larger values are capped", &APISchema{
+		Type: "integer", Minimum: intPtr(1), Maximum: intPtr(maxLimit), Default: defaultLimit,
+	})
+}
+
+func offsetParameter() *APIParameter {
+	return queryParameter("offset", "Items to skip", &APISchema{Type: "integer", Minimum: intPtr(0), Default: 0})
+}
+
+func jsonBody(schema *APISchema) *APIRequestBody {
+	return &APIRequestBody{Required: true, Content: map[string]*APIMediaType{"application/json": {Schema: schema}}}
+}
+
+func jsonResponse(description string) *APIResponse {
+	return &APIResponse{
+		Description: description,
+		Content:     map[string]*APIMediaType{"application/json": {Schema: &APISchema{Type: "object"}}},
+	}
+}
+
+func errorResponse(description string) *APIResponse {
+	return &APIResponse{
+		Description: description,
+		Content:     map[string]*APIMediaType{"application/json": {Schema: &APISchema{Ref: "#/components/schemas/Error"}}},
+	}
+}
+
+func intPtr(value int) *int {
+	return &value
+}
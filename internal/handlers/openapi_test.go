@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	openAPIVersionPattern = regexp.MustCompile(`^3\.0\.\d+$`)
+	pathTemplatePattern   = regexp.MustCompile(`\{([^}]+)\}`)
+	schemaTypes           = map[string]bool{"": true, "string": true, "integer": true, "number": true, "boolean": true, "array": true, "object": true}
+	parameterLocations    = map[string]bool{"query": true, "path": true, "header": true, "cookie": true}
+	operationMethods      = map[string]bool{"get": true, "put": true, "post": true, "delete": true, "options": true, "head": true, "patch": true, "trace": true}
+)
+
+// validateOpenAPIDocument checks the rules of the OpenAPI 3.0 schema the
+// document's types can break, returning one message per violation
+func validateOpenAPIDocument(doc map[string]interface{}) []string {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if version, _ := doc["openapi"].(string); !openAPIVersionPattern.MatchString(version) {
+		fail("openapi %q is not a 3.0 version", version)
+	}
+	info, _ := doc["info"].(map[string]interface{})
+	if title, _ := info["title"].(string); title == "" {
+		fail("info.title is required")
+	}
+	if version, _ := info["version"].(string); version == "" {
+		fail("info.version is required")
+	}
+
+	components, _ := doc["components"].(map[string]interface{})
+	schemas, _ := components["schemas"].(map[string]interface{})
+	securitySchemes, _ := components["securitySchemes"].(map[string]interface{})
+	for name, raw := range securitySchemes {
+		scheme, _ := raw.(map[string]interface{})
+		if scheme["type"] != "apiKey" || scheme["name"] == "" || !parameterLocations[fmt.Sprint(scheme["in"])] {
+			fail("security scheme %s is not a valid apiKey scheme", name)
+		}
+	}
+
+	var validateSchema func(where string, raw interface{})
+	validateSchema = func(where string, raw interface{}) {
+		schema, ok := raw.(map[string]interface{})
+		if !ok {
+			fail("%s: schema is not an object", where)
+			return
+		}
+		if ref, ok := schema["$ref"].(string); ok {
+			name, found := strings.CutPrefix(ref, "#/components/schemas/")
+			if _, defined := schemas[name]; !found || !defined {
+				fail("%s: $ref %s does not resolve", where, ref)
+			}
+			return
+		}
+		schemaType, _ := schema["type"].(string)
+		if !schemaTypes[schemaType] {
+			fail("%s: unknown type %q", where, schemaType)
+		}
+		if schemaType == "array" {
+			if _, ok := schema["items"]; !ok {
+				fail("%s: array without items", where)
+			}
+		}
+		if items, ok := schema["items"]; ok {
+			validateSchema(where+".items", items)
+		}
+		if properties, ok := schema["properties"].(map[string]interface{}); ok {
+			for name, property := range properties {
+				validateSchema(where+"."+name, property)
+			}
+		}
+		if required, ok := schema["required"].([]interface{}); ok {
+			properties, _ := schema["properties"].(map[string]interface{})
+			for _, name := range required {
+				if _, ok := properties[name.(string)]; !ok {
+					fail("%s: required property %v is not defined", where, name)
+				}
+			}
+		}
+		if enum, ok := schema["enum"].([]interface{}); ok && len(enum) == 0 {
+			fail("%s: enum must not be empty", where)
+		}
+		if minimum, ok := schema["minimum"].(float64); ok {
+			if maximum, ok := schema["maximum"].(float64); ok && maximum < minimum {
+				fail("%s: maximum %v is below minimum %v", where, maximum, minimum)
+			}
+		}
+	}
+	for name, schema := range schemas {
+		validateSchema("components.schemas."+name, schema)
+	}
+
+	paths, _ := doc["paths"].(map[string]interface{})
+	if len(paths) == 0 {
+		fail("paths is empty")
+	}
+	for path, rawItem := range paths {
+		if !strings.HasPrefix(path, "/") {
+			fail("path %s does not start with /", path)
+		}
+		templated := map[string]bool{}
+		for _, match := range pathTemplatePattern.FindAllStringSubmatch(path, -1) {
+			templated[match[1]] = true
+		}
+
+		item, _ := rawItem.(map[string]interface{})
+		for method, rawOperation := range item {
+			where := strings.ToUpper(method) + " " + path
+			if !operationMethods[method] {
+				fail("%s: unknown method", where)
+				continue
+			}
+			operation, _ := rawOperation.(map[string]interface{})
+
+			declared := map[string]bool{}
+			parameters, _ := operation["parameters"].([]interface{})
+			for _, rawParameter := range parameters {
+				parameter, _ := rawParameter.(map[string]interface{})
+				name, _ := parameter["name"].(string)
+				in, _ := parameter["in"].(string)
+				if name == "" || !parameterLocations[in] {
+					fail("%s: parameter %q in %q is invalid", where, name, in)
+				}
+				if declared[in+":"+name] {
+					fail("%s: parameter %s is declared twice", where, name)
+				}
+				declared[in+":"+name] = true
+				if in == "path" {
+					if !templated[name] {
+						fail("%s: path parameter %s is not in the path", where, name)
+					}
+					if parameter["required"] != true {
+						fail("%s: path parameter %s must be required", where, name)
+					}
+				}
+				validateSchema(where+" parameter "+name, parameter["schema"])
+			}
+			for name := range templated {
+				if !declared["path:"+name] {
+					fail("%s: path parameter %s is not declared", where, name)
+				}
+			}
+
+			if body, ok := operation["requestBody"].(map[string]interface{}); ok {
+				content, _ := body["content"].(map[string]interface{})
+				if len(content) == 0 {
+					fail("%s: request body without content", where)
+				}
+				for mediaType, rawMedia := range content {
+					media, _ := rawMedia.(map[string]interface{})
+					validateSchema(where+" body "+mediaType, media["schema"])
+				}
+			}
+
+			responses, _ := operation["responses"].(map[string]interface{})
+			if len(responses) == 0 {
+				fail("%s: no responses", where)
+			}
+			for status, rawResponse := range responses {
+				if code, err := strconv.Atoi(status); status != "default" && (err != nil || code < 100 || code > 599) {
+					fail("%s: response key %q is not a status code", where, status)
+				}
+				response, _ := rawResponse.(map[string]interface{})
+				if description, _ := response["description"].(string); description == "" {
+					fail("%s: response %s has no description", where, status)
+				}
+				content, _ := response["content"].(map[string]interface{})
+				for mediaType, rawMedia := range content {
+					media, _ := rawMedia.(map[string]interface{})
+					validateSchema(where+" response "+status+" "+mediaType, media["schema"])
+				}
+			}
+
+			security, _ := operation["security"].([]interface{})
+			for _, rawRequirement := range security {
+				requirement, _ := rawRequirement.(map[string]interface{})
+				for name := range requirement {
+					if _, ok := securitySchemes[name]; !ok {
+						fail("%s: security scheme %s is not defined", where, name)
+					}
+				}
+			}
+		}
+	}
+	return problems
+}
+
+func TestOpenAPIHandler_ServesValidDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ranges, err := services.NewPriceRanges([]float64{25, 75})
+	require.NoError(t, err)
+	handler := NewOpenAPIHandler(ranges, "X-API-Key")
+
+	router := gin.New()
+	router.GET("/api/v1/openapi.json", handler.GetSpec)
+	router.GET("/api/v1/docs", handler.GetDocs)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Empty(t, validateOpenAPIDocument(doc))
+
+	// Parameters carry the handlers' own limits and enums
+	var typed OpenAPIDocument
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &typed))
+	parameter := func(path, name string) *APIParameter {
+		for _, p := range typed.Paths[path]["get"].Parameters {
+			if p.Name == name {
+				return p
+			}
+		}
+		t.Fatalf("GET %s has no %s parameter", path, name)
+		return nil
+	}
+	limit := parameter("/api/v1/stocks", "limit").Schema
+	assert.Equal(t, maxPageSize, *limit.Maximum)
+	assert.Equal(t, float64(defaultPageSize), limit.Default)
+	assert.Equal(t, maxPerformanceDays, *parameter("/api/v1/stocks/{symbol}/performance", "days").Schema.Maximum)
+	assert.Equal(t, []interface{}{"$0-25", "$25-75", "$75+"}, parameter("/api/v1/stocks/price-range", "range").Schema.Enum)
+	assert.Len(t, parameter("/api/v1/stocks/{symbol}/intraday", "interval").Schema.Enum, len(services.IntradayIntervals))
+	assert.Len(t, parameter("/api/v1/system/scheduler/runs", "job").Schema.Enum, len(services.SchedulerJobs))
+	quotes := typed.Paths["/api/v1/stocks/quotes"]["post"].RequestBody.Content["application/json"].Schema
+	assert.Equal(t, services.MaxQuoteSymbols, *quotes.Properties["symbols"].MaxItems)
+	assert.Equal(t, "X-API-Key", typed.Components.SecuritySchemes["apiKey"].Name)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/docs", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `url: "/api/v1/openapi.json"`)
+}
+
+func TestValidateOpenAPIDocument_ReportsViolations(t *testing.T) {
+	doc := map[string]interface{}{
+		"openapi": "2.0",
+		"info":    map[string]interface{}{"title": "API"},
+		"paths": map[string]interface{}{
+			"/stocks/{symbol}": map[string]interface{}{
+				"get": map[string]interface{}{
+					"parameters": []interface{}{
+						map[string]interface{}{"name": "limit", "in": "body", "schema": map[string]interface{}{"type": "int"}},
+					},
+					"responses": map[string]interface{}{
+						"OK": map[string]interface{}{"description": "Success"},
+					},
+				},
+			},
+		},
+	}
+
+	problems := strings.Join(validateOpenAPIDocument(doc), "\n")
+	for _, expected := range []string{
+		`openapi "2.0" is not a 3.0 version`,
+		"info.version is required",
+		`parameter "limit" in "body" is invalid`,
+		`unknown type "int"`,
+		"path parameter symbol is not declared",
+		`response key "OK" is not a status code`,
+	} {
+		assert.Contains(t, problems, expected)
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PortfolioHandler handles portfolio HTTP requests
+type PortfolioHandler struct {
+	portfolioService *services.PortfolioService
+	now              func() time.Time
+}
+
+// NewPortfolioHandler creates a new portfolio handler
+func NewPortfolioHandler(portfolioService *services.PortfolioService) *PortfolioHandler {
+	return &PortfolioHandler{
+		portfolioService: portfolioService,
+		now:              time.Now,
+	}
+}
+
+type createPortfolioRequest struct {
+	Name string `json:"name"`
+}
+
+type recordTransactionRequest struct {
+	Symbol   string  `json:"symbol"`
+	Side     string  `json:"side"`
+	Quantity float64 `json:"quantity"`
+	Price    float64 `json:"price"`
+	// ExecutedAt defaults to now
+	ExecutedAt *time.Time `json:"executed_at"`
+}
+
+// CreatePortfolio creates an empty portfolio
+func (h *PortfolioHandler) CreatePortfolio(c *gin.Context) {
+	var req createPortfolioRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Portfolio name is required"))
+		return
+	}
+
+	portfolio, err := h.portfolioService.CreatePortfolio(c.Request.Context(), strings.TrimSpace(req.Name))
+	if err != nil {
+		respondServiceError(c, "Failed to create portfolio", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    portfolio,
+	})
+}
+
+// RecordTransaction records a buy or sell; selling more than the portfolio holds is a 422
+func (h *PortfolioHandler) RecordTransaction(c *gin.Context) {
+	id, ok := portfolioID(c)
+	if !ok {
+		return
+	}
+
+	var req recordTransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Invalid transaction body"))
+		return
+	}
+	symbol, err := models.NormalizeSymbol(req.Symbol)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Stock symbol is required"))
+		return
+	}
+	side := strings.ToLower(strings.TrimSpace(req.Side))
+	if side != models.SideBuy && side != models.SideSell {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Side must be buy or sell", gin.H{
+			"side": req.Side,
+		}))
+		return
+	}
+	if req.Quantity <= 0 || req.Price <= 0 {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Quantity and price must be greater than 0"))
+		return
+	}
+	executedAt := h.now()
+	if req.ExecutedAt != nil {
+		if req.ExecutedAt.After(executedAt) {
+			respondError(c, http.StatusBadRequest, CodeValidation, errors.New("executed_at cannot be in the future"))
+			return
+		}
+		executedAt = *req.ExecutedAt
+	}
+
+	transaction, err := h.portfolioService.RecordTransaction(c.Request.Context(), id, models.Transaction{
+		Symbol:     symbol,
+		Side:       side,
+		Quantity:   req.Quantity,
+		Price:      req.Price,
+		ExecutedAt: executedAt,
+	})
+	var insufficient *services.InsufficientQuantityError
+	switch {
+	case errors.Is(err, services.ErrUnknownSymbol):
+		respondError(c, http.StatusUnprocessableEntity, CodeValidation, withDetails("Unknown stock symbol", gin.H{
+			"symbol": symbol,
+		}))
+		return
+	case errors.As(err, &insufficient):
+		respondError(c, http.StatusUnprocessableEntity, CodeValidation, withDetails("Cannot sell more than the portfolio holds", gin.H{
+			"symbol":    insufficient.Symbol,
+			"available": insufficient.Available,
+			"requested": insufficient.Requested,
+		}))
+		return
+	}
+	if !handlePortfolioError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    transaction,
+	})
+}
+
+// GetSummary returns a portfolio's positions valued at the latest stored close, with totals
+func (h *PortfolioHandler) GetSummary(c *gin.Context) {
+	id, ok := portfolioID(c)
+	if !ok {
+		return
+	}
+
+	summary, err := h.portfolioService.Summary(c.Request.Context(), id)
+	if !handlePortfolioError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    summary,
+	})
+}
+
+// handlePortfolioError writes the error response for err and reports whether the request may continue
+func handlePortfolioError(c *gin.Context, err error) bool {
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, services.ErrPortfolioNotFound) {
+		respondError(c, http.StatusNotFound, CodeNotFound, errors.New("Portfolio not found"))
+		return false
+	}
+	respondServiceError(c, "Portfolio request failed", err)
+	return false
+}
+
+// portfolioID parses the :id parameter, writing a 400 when it is invalid
+func portfolioID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Invalid portfolio ID"))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPortfolioRouter(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	handler := NewPortfolioHandler(services.NewPortfolioService(db))
+	router := gin.New()
+	router.POST("/portfolios", handler.CreatePortfolio)
+	router.POST("/portfolios/:id/transactions", handler.RecordTransaction)
+	router.GET("/portfolios/:id/summary", handler.GetSummary)
+	return router, mock
+}
+
+type portfolioResponse struct {
+	Data  json.RawMessage `json:"data"`
+	Error struct {
+		Code    string                 `json:"code"`
+		Details map[string]interface{} `json:"details"`
+	} `json:"error"`
+}
+
+func servePortfolioRequest(t *testing.T, router *gin.Engine, method, path, body string) (int, portfolioResponse) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	var response portfolioResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return w.Code, response
+}
+
+// expectSellable expects a sell of AAPL from portfolio 1, which bought qty shares a week ago
+func expectSellable(mock sqlmock.Sqlmock, qty float64) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM portfolios").WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery("SELECT side, quantity, executed_at").WithArgs(int64(1), 7).
+		WillReturnRows(sqlmock.NewRows([]string{"side", "quantity", "executed_at"}).
+			AddRow("buy", qty, time.Now().AddDate(0, 0, -7)))
+}
+
+func TestPortfolios_RecordTransaction(t *testing.T) {
+	router, mock := newPortfolioRouter(t)
+
+	expectSellable(mock, 10)
+	mock.ExpectQuery("INSERT INTO transactions").
+		WithArgs(int64(1), 7, "sell", 4.0, 150.25, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectCommit()
+
+	code, response := servePortfolioRequest(t, router, "POST", "/portfolios/1/transactions",
+		`{"symbol": "aapl", "side": "SELL", "quantity": 4, "price": 150.25}`)
+	require.Equal(t, http.StatusCreated, code)
+	assert.Contains(t, string(response.Data), `"symbol":"AAPL"`)
+	assert.Contains(t, string(response.Data), `"side":"sell"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPortfolios_OversellIsUnprocessable(t *testing.T) {
+	router, mock := newPortfolioRouter(t)
+
+	expectSellable(mock, 3)
+	mock.ExpectRollback()
+
+	code, response := servePortfolioRequest(t, router, "POST", "/portfolios/1/transactions",
+		`{"symbol": "AAPL", "side": "sell", "quantity": 5, "price": 150}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, string(CodeValidation), response.Error.Code)
+	assert.Equal(t, 3.0, response.Error.Details["available"])
+	assert.Equal(t, 5.0, response.Error.Details["requested"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPortfolios_ValidatesTransactions(t *testing.T) {
+	router, mock := newPortfolioRouter(t)
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	for _, body := range []string{
+		`{"symbol": "AAPL", "side": "hold", "quantity": 1, "price": 1}`,
+		`{"symbol": "AAPL", "side": "buy", "quantity": 0, "price": 1}`,
+		`{"symbol": "AAPL", "side": "buy", "quantity": 1, "price": -1}`,
+		`{"symbol": "", "side": "buy", "quantity": 1, "price": 1}`,
+		`{"symbol": "AAPL", "side": "buy", "quantity": 1, "price": 1, "executed_at": "` + future + `"}`,
+	} {
+		code, response := servePortfolioRequest(t, router, "POST", "/portfolios/1/transactions", body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+		assert.Equal(t, string(CodeValidation), response.Error.Code, body)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPortfolios_NotFound(t *testing.T) {
+	router, mock := newPortfolioRouter(t)
+
+	mock.ExpectQuery("SELECT name, created_at, updated_at FROM portfolios").WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "created_at", "updated_at"}))
+	code, _ := servePortfolioRequest(t, router, "GET", "/portfolios/2/summary", "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM portfolios").WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+	code, _ = servePortfolioRequest(t, router, "POST", "/portfolios/2/transactions",
+		`{"symbol": "AAPL", "side": "buy", "quantity": 1, "price": 1}`)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = servePortfolioRequest(t, router, "GET", "/portfolios/abc/summary", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamKeepaliveInterval is how often an idle SSE stream gets a comment, so
+// proxies that close quiet connections leave it open
+const streamKeepaliveInterval = 15 * time.Second
+
+// streamBufferSize is how many events a stream may fall behind by before it
+// is dropped, as a WebSocket client whose write fails is
+const streamBufferSize = 16
+
+// priceStream is one SSE client's queue of framed events
+type priceStream struct {
+	events chan []byte
+}
+
+// HandlePriceStream streams the WebSocket broadcasts as Server-Sent Events,
+// for clients behind proxies that block WebSocket upgrades. A new stream
+// starts with the initial snapshot; one resumed with Last-Event-ID gets every
+// stock as a price_update instead, since missed events are not kept.
+func (wsh *WebSocketHandler) HandlePriceStream(c *gin.Context) {
+	stream, currentConnections, eventID := wsh.registerStream()
+	if stream == nil {
+		wsh.rejectConnection(c, "SSE", currentConnections)
+		return
+	}
+	defer wsh.unregisterStream(stream)
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// Stops nginx buffering the stream
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	snapshot := wsh.initialMessage()
+	if c.GetHeader("Last-Event-ID") != "" {
+		snapshot = map[string]interface{}{
+			"type": "price_update",
+			"data": map[string]interface{}{
+				"stocks":    roundedStocks(wsh.stockService.GetAllStocks()),
+				"timestamp": time.Now().Unix(),
+			},
+		}
+	}
+	frame, err := streamFrame(eventID, snapshot)
+	if err != nil {
+		wsh.logger.Warn("Error encoding SSE snapshot", "error", err)
+		return
+	}
+	if _, err := c.Writer.Write(frame); err != nil {
+		return
+	}
+	c.Writer.Flush()
+
+	keepalive := time.NewTicker(wsh.keepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case frame, ok := <-stream.events:
+			if !ok {
+				wsh.logger.Warn("SSE client fell behind, closing stream")
+				return
+			}
+			if _, err := c.Writer.Write(frame); err != nil {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := c.Writer.WriteString(": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// registerStream adds a stream unless the connection limit is reached, and
+// returns the connection count and the ID of the latest broadcast
+func (wsh *WebSocketHandler) registerStream() (*priceStream, int, uint64) {
+	wsh.clientsMutex.Lock()
+	defer wsh.clientsMutex.Unlock()
+
+	currentConnections := len(wsh.clients) + len(wsh.streams)
+	if currentConnections >= maxConnections {
+		return nil, currentConnections, wsh.lastEventID
+	}
+	stream := &priceStream{events: make(chan []byte, streamBufferSize)}
+	wsh.streams[stream] = true
+	wsh.logger.Info("SSE client connected", "clients", currentConnections+1, "max_clients", maxConnections)
+	return stream, currentConnections, wsh.lastEventID
+}
+
+func (wsh *WebSocketHandler) unregisterStream(stream *priceStream) {
+	wsh.clientsMutex.Lock()
+	delete(wsh.streams, stream)
+	clientCount := len(wsh.clients) + len(wsh.streams)
+	wsh.clientsMutex.Unlock()
+
+	wsh.logger.Info("SSE client disconnected", "clients", clientCount, "max_clients", maxConnections)
+}
+
+// sendToStreams queues the message, framed with the latest event ID, on every
+// stream; a stream whose queue is full is closed. The caller holds clientsMutex.
+func (wsh *WebSocketHandler) sendToStreams(message map[string]interface{}) {
+	if len(wsh.streams) == 0 {
+		return
+	}
+	frame, err := streamFrame(wsh.lastEventID, message)
+	if err != nil {
+		wsh.logger.Warn("Error encoding SSE event", "error", err)
+		return
+	}
+	for stream := range wsh.streams {
+		select {
+		case stream.events <- frame:
+		default:
+			close(stream.events)
+			delete(wsh.streams, stream)
+		}
+	}
+}
+
+// streamFrame frames a message as an SSE event named by its type, with the
+// whole message, as the WebSocket sends it, for data
+func streamFrame(id uint64, message map[string]interface{}) ([]byte, error) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("id: %d\nevent: %v\ndata: %s\n\n", id, message["type"], data)), nil
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamEvent is one SSE frame, or a comment when comment is set
+type streamEvent struct {
+	id, event, data string
+	comment         string
+}
+
+// openPriceStream connects to the handler's stream and returns its frames as they arrive
+func openPriceStream(t *testing.T, handler *WebSocketHandler, lastEventID string) (*http.Response, <-chan streamEvent) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stream/prices", handler.HandlePriceStream)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/stream/prices", nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	events := make(chan streamEvent, 16)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(nil, 1<<20)
+		var event streamEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				events <- event
+				event = streamEvent{}
+			case strings.HasPrefix(line, ":"):
+				event.comment = strings.TrimSpace(strings.TrimPrefix(line, ":"))
+			case strings.HasPrefix(line, "id: "):
+				event.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return resp, events
+}
+
+func nextStreamEvent(t *testing.T, events <-chan streamEvent) streamEvent {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "stream closed")
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event within 2s")
+		return streamEvent{}
+	}
+}
+
+func newStreamTestHandler() *WebSocketHandler {
+	mockService := &MockHybridStockService{}
+	mockService.On("GetAllStocks").Return([]models.Stock{{ID: 1, Symbol: "AAPL", CurrentPrice: 150.24999999}})
+	mockService.On("GetPerformanceData").Return(models.StockPerformance{})
+	mockService.On("GetMarketOverview").Return(models.MarketOverview{TotalStocks: 1})
+	return NewWebSocketHandler(mockService, nil, nil)
+}
+
+func TestPriceStream_DeliversBroadcasts(t *testing.T) {
+	handler := newStreamTestHandler()
+	resp, events := openPriceStream(t, handler, "")
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	initial := nextStreamEvent(t, events)
+	assert.Equal(t, "initial", initial.event)
+	assert.Equal(t, "0", initial.id)
+	assert.Equal(t, 1, handler.GetStreamClients())
+
+	handler.broadcastToClients(map[string]interface{}{
+		"type": "price_update",
+		"data": map[string]interface{}{"stocks": roundedStocks([]models.Stock{{Symbol: "AAPL", CurrentPrice: 151.5}})},
+	})
+
+	update := nextStreamEvent(t, events)
+	assert.Equal(t, "price_update", update.event)
+	assert.Equal(t, "1", update.id)
+	var message struct {
+		Type string `json:"type"`
+		Data struct {
+			Stocks []models.Stock `json:"stocks"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(update.data), &message))
+	assert.Equal(t, "price_update", message.Type)
+	require.Len(t, message.Data.Stocks, 1)
+	assert.Equal(t, 151.5, message.Data.Stocks[0].CurrentPrice)
+
+	resp.Body.Close()
+	require.Eventually(t, func() bool { return handler.GetStreamClients() == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestPriceStream_LastEventIDResendsSnapshot(t *testing.T) {
+	handler := newStreamTestHandler()
+	handler.lastEventID = 41
+
+	_, events := openPriceStream(t, handler, "37")
+
+	snapshot := nextStreamEvent(t, events)
+	assert.Equal(t, "price_update", snapshot.event)
+	assert.Equal(t, "41", snapshot.id)
+	assert.Contains(t, snapshot.data, `"symbol":"AAPL"`)
+	assert.Contains(t, snapshot.data, `"current_price":150.25`)
+}
+
+func TestPriceStream_SendsKeepalives(t *testing.T) {
+	handler := newStreamTestHandler()
+	handler.keepaliveInterval = 20 * time.Millisecond
+
+	_, events := openPriceStream(t, handler, "")
+	nextStreamEvent(t, events)
+
+	assert.Equal(t, "keepalive", nextStreamEvent(t, events).comment)
+}
+
+func TestPriceStream_SharesConnectionLimit(t *testing.T) {
+	handler := newStreamTestHandler()
+	for i := 0; i < maxConnections; i++ {
+		stream, _, _ := handler.registerStream()
+		require.NotNil(t, stream)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stream/prices", handler.HandlePriceStream)
+	router.GET("/ws", handler.HandleWebSocket)
+
+	for _, path := range []string{"/stream/prices", "/ws"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, path)
+	}
+}
+
+func TestPriceStream_DropsStreamThatFallsBehind(t *testing.T) {
+	handler := newStreamTestHandler()
+	stream, _, _ := handler.registerStream()
+	require.NotNil(t, stream)
+
+	for i := 0; i <= streamBufferSize; i++ {
+		handler.broadcastToClients(map[string]interface{}{"type": "price_update", "data": i})
+	}
+
+	assert.Equal(t, 0, handler.GetStreamClients())
+	for range stream.events {
+	}
+}
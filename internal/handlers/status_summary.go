@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"stock-intelligence-backend/internal/cache"
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultStatusSummaryTTL is how long a computed summary is served before it is rebuilt
+const DefaultStatusSummaryTTL = 30 * time.Second
+
+// statusSummaryErrors is how many of the newest scheduler errors the summary includes
+const statusSummaryErrors = 3
+
+// StatusSummary is the payload polled by the external status page. Its field
+// names are a public contract; add fields rather than renaming them.
+type StatusSummary struct {
+	Status           string                 `json:"status"`
+	LastSync         StatusSummarySync      `json:"last_sync"`
+	Stocks           StatusSummaryStocks    `json:"stocks"`
+	APIBudget        StatusSummaryAPIBudget `json:"api_budget"`
+	Cache            StatusSummaryCache     `json:"cache"`
+	WebSocketClients int                    `json:"websocket_clients"`
+	RecentErrors     []string               `json:"recent_errors"`
+	GeneratedAt      time.Time              `json:"generated_at"`
+}
+
+// StatusSummarySync is the last successful sync; At is nil until one has run
+type StatusSummarySync struct {
+	At     *time.Time `json:"at"`
+	Symbol string     `json:"symbol"`
+}
+
+// StatusSummaryStocks counts active stocks with enough price history
+type StatusSummaryStocks struct {
+	WithData int `json:"with_data"`
+	Total    int `json:"total"`
+}
+
+// StatusSummaryAPIBudget is the remaining market data provider allowance
+type StatusSummaryAPIBudget struct {
+	Status            string     `json:"status"`
+	DailyRemaining    int        `json:"daily_remaining"`
+	HourlyRemaining   *int       `json:"hourly_remaining"`
+	NextCallAllowedAt *time.Time `json:"next_call_allowed_at"`
+}
+
+// StatusSummaryCache reports whether Redis is serving requests
+type StatusSummaryCache struct {
+	Status string `json:"status"`
+}
+
+// StatusSummaryHandler serves a single cheap, cached status payload built from
+// the scheduler's in-memory state, the API budget and the cache connection
+type StatusSummaryHandler struct {
+	provider         services.MarketDataProvider
+	schedulerService *services.SchedulerService
+	syncService      *services.HistoricalDataSyncService
+	redisCache       *cache.RedisCache
+	connectedClients func() int
+	ttl              time.Duration
+	now              func() time.Time
+
+	mu      sync.Mutex
+	summary *StatusSummary
+}
+
+// NewStatusSummaryHandler creates the handler; redisCache may be nil when caching is disabled
+func NewStatusSummaryHandler(provider services.MarketDataProvider, schedulerService *services.SchedulerService,
+	syncService *services.HistoricalDataSyncService, redisCache *cache.RedisCache, connectedClients func() int) *StatusSummaryHandler {
+	return &StatusSummaryHandler{
+		provider:         provider,
+		schedulerService: schedulerService,
+		syncService:      syncService,
+		redisCache:       redisCache,
+		connectedClients: connectedClients,
+		ttl:              DefaultStatusSummaryTTL,
+		now:              time.Now,
+	}
+}
+
+// GetSummary returns the status page summary, rebuilding it at most once per TTL
+func (h *StatusSummaryHandler) GetSummary(c *gin.Context) {
+	h.mu.Lock()
+	if h.summary == nil || h.now().Sub(h.summary.GeneratedAt) >= h.ttl {
+		h.summary = h.buildSummary(c.Request.Context())
+	}
+	summary := *h.summary
+	h.mu.Unlock()
+
+	c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(h.ttl/time.Second)))
+	c.JSON(http.StatusOK, summary)
+}
+
+// buildSummary gathers each section; a failing source degrades the status instead of failing the request
+func (h *StatusSummaryHandler) buildSummary(ctx context.Context) *StatusSummary {
+	activity := h.schedulerService.GetRecentActivity(statusSummaryErrors)
+
+	summary := &StatusSummary{
+		Status:       "healthy",
+		RecentErrors: activity.RecentErrors,
+		GeneratedAt:  h.now(),
+	}
+
+	if !activity.LastSync.IsZero() {
+		lastSync := activity.LastSync
+		summary.LastSync = StatusSummarySync{At: &lastSync, Symbol: activity.LastSyncSymbol}
+	}
+
+	// Stocks and budget both need the database; losing it makes the service unhealthy
+	withData, total, coverageErr := h.syncService.GetDataCoverage()
+	summary.Stocks = StatusSummaryStocks{WithData: withData, Total: total}
+
+	budget, budgetErr := h.provider.GetAPIBudget()
+	if budgetErr != nil {
+		summary.APIBudget = StatusSummaryAPIBudget{Status: "unknown"}
+	} else {
+		nextCall := budget.NextCallAllowedAt
+		summary.APIBudget = StatusSummaryAPIBudget{
+			Status:            map[bool]string{true: "available", false: "rate_limited"}[budget.CanMakeRequest],
+			DailyRemaining:    budget.DailyRemaining,
+			HourlyRemaining:   budget.HourlyRemaining,
+			NextCallAllowedAt: &nextCall,
+		}
+	}
+
+	summary.Cache = StatusSummaryCache{Status: "disabled"}
+	if h.redisCache != nil {
+		summary.Cache.Status = "connected"
+		if err := h.redisCache.PingContext(ctx); err != nil {
+			summary.Cache.Status = "unavailable"
+		}
+	}
+
+	if h.connectedClients != nil {
+		summary.WebSocketClients = h.connectedClients()
+	}
+
+	switch {
+	case coverageErr != nil || budgetErr != nil:
+		summary.Status = "unhealthy"
+	case !activity.IsRunning || summary.Cache.Status == "unavailable":
+		summary.Status = "degraded"
+	}
+
+	return summary
+}
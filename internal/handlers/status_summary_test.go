@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectSummaryQueries expects the database reads made each time the summary is rebuilt
+func expectSummaryQueries(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("FILTER \\(WHERE has_sufficient_data\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"with_data", "total"}).AddRow(412, 503))
+	// Counters written this hour, in the Eastern time they roll over in
+	eastern, _ := time.LoadLocation("America/New_York")
+	now := time.Now().In(eastern)
+	mock.ExpectQuery("FROM api_rate_limits").WillReturnRows(sqlmock.NewRows([]string{
+		"id", "service_name", "daily_limit", "hourly_limit", "current_daily_count",
+		"current_hourly_count", "last_reset_date", "last_reset_hour", "created_at", "updated_at",
+	}).AddRow(1, "alphavantage", 25, 5, 7, 1, now, now.Hour(), now, now))
+	mock.ExpectQuery("SELECT MAX\\(created_at\\) FROM api_calls").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+}
+
+func newSummaryRouter(t *testing.T) (*gin.Engine, *StatusSummaryHandler, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	client := services.NewAlphaVantageClient("test-key", db)
+	scheduler := services.NewSchedulerService(db, client, nil, nil, nil)
+	syncService := services.NewHistoricalDataSyncService(db, client, nil)
+
+	handler := NewStatusSummaryHandler(client, scheduler, syncService, nil, func() int { return 3 })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/system/summary", handler.GetSummary)
+	return router, handler, mock
+}
+
+func getSummary(t *testing.T, router *gin.Engine) (map[string]interface{}, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/system/summary", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body, w
+}
+
+func keysOf(t *testing.T, value interface{}) []string {
+	object, ok := value.(map[string]interface{})
+	require.True(t, ok, "expected an object, got %T", value)
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TestStatusSummary_Contract pins the field names the external status page depends on
+func TestStatusSummary_Contract(t *testing.T) {
+	router, _, mock := newSummaryRouter(t)
+	expectSummaryQueries(mock)
+
+	body, _ := getSummary(t, router)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, []string{
+		"api_budget", "cache", "generated_at", "last_sync", "recent_errors",
+		"status", "stocks", "websocket_clients",
+	}, keysOf(t, body))
+	assert.Equal(t, []string{"at", "symbol"}, keysOf(t, body["last_sync"]))
+	assert.Equal(t, []string{"total", "with_data"}, keysOf(t, body["stocks"]))
+	assert.Equal(t, []string{
+		"daily_remaining", "hourly_remaining", "next_call_allowed_at", "status",
+	}, keysOf(t, body["api_budget"]))
+	assert.Equal(t, []string{"status"}, keysOf(t, body["cache"]))
+
+	// The scheduler was never started, so the service is degraded but reachable
+	assert.Equal(t, "degraded", body["status"])
+	assert.Nil(t, body["last_sync"].(map[string]interface{})["at"])
+	assert.Equal(t, float64(412), body["stocks"].(map[string]interface{})["with_data"])
+	assert.Equal(t, float64(503), body["stocks"].(map[string]interface{})["total"])
+	assert.Equal(t, float64(18), body["api_budget"].(map[string]interface{})["daily_remaining"])
+	assert.Equal(t, "available", body["api_budget"].(map[string]interface{})["status"])
+	assert.Equal(t, "disabled", body["cache"].(map[string]interface{})["status"])
+	assert.Equal(t, float64(3), body["websocket_clients"])
+	assert.Equal(t, []interface{}{}, body["recent_errors"])
+}
+
+func TestStatusSummary_ServedFromCacheWithinTTL(t *testing.T) {
+	router, handler, mock := newSummaryRouter(t)
+
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	handler.now = func() time.Time { return now }
+
+	expectSummaryQueries(mock)
+	first, w := getSummary(t, router)
+	assert.Equal(t, "public, max-age=30", w.Header().Get("Cache-Control"))
+
+	// Polling inside the TTL makes no database queries
+	now = now.Add(15 * time.Second)
+	second, _ := getSummary(t, router)
+	assert.Equal(t, first["generated_at"], second["generated_at"])
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// Once the TTL passes the summary is rebuilt
+	now = now.Add(15 * time.Second)
+	expectSummaryQueries(mock)
+	third, _ := getSummary(t, router)
+	assert.NotEqual(t, first["generated_at"], third["generated_at"])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatusSummary_UnhealthyWithoutDatabase(t *testing.T) {
+	router, _, mock := newSummaryRouter(t)
+	mock.ExpectQuery("FILTER \\(WHERE has_sufficient_data\\)").WillReturnError(errors.New("connection refused"))
+	mock.ExpectQuery("FROM api_rate_limits").WillReturnError(errors.New("connection refused"))
+
+	body, _ := getSummary(t, router)
+
+	assert.Equal(t, "unhealthy", body["status"])
+	assert.Equal(t, "unknown", body["api_budget"].(map[string]interface{})["status"])
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -59,11 +60,9 @@ func (h *StockHandler) GetStockBySymbol(c *gin.Context) {
 	
 	stock := h.stockService.GetStockBySymbol(symbol)
 	if stock == nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"success": false,
-			"error":   "Stock not found",
-			"symbol":  symbol,
-		})
+		respondError(c, http.StatusNotFound, CodeNotFound, withDetails("Stock not found", gin.H{
+			"symbol": symbol,
+		}))
 		return
 	}
 	
@@ -97,10 +96,7 @@ func (h *StockHandler) GetMarketOverview(c *gin.Context) {
 func (h *StockHandler) GetStocksByPriceRange(c *gin.Context) {
 	priceRange := c.Query("range")
 	if priceRange == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "price range parameter is required",
-		})
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("price range parameter is required"))
 		return
 	}
 	
@@ -153,7 +149,7 @@ func (h *StockHandler) GetStockHistoricalPerformance(c *gin.Context) {
 	daysParam := c.Query("days")
 	
 	// Parse days parameter, default to 30 if not provided or invalid
-	days := 30
+	days := defaultPerformanceDays
 	if daysParam != "" {
 		if parsedDays, err := strconv.Atoi(daysParam); err == nil && parsedDays > 0 {
 			days = parsedDays
@@ -162,11 +158,9 @@ func (h *StockHandler) GetStockHistoricalPerformance(c *gin.Context) {
 	
 	performance := h.stockService.GetHistoricalPerformance(symbol, days)
 	if performance == nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"success": false,
-			"error":   "Historical performance data not found",
-			"symbol":  symbol,
-		})
+		respondError(c, http.StatusNotFound, CodeNotFound, withDetails("Historical performance data not found", gin.H{
+			"symbol": symbol,
+		}))
 		return
 	}
 	
@@ -1,63 +1,100 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"stock-intelligence-backend/internal/cache"
+	"stock-intelligence-backend/internal/jobs"
+	"stock-intelligence-backend/internal/models"
 	"stock-intelligence-backend/internal/services"
+	"stock-intelligence-backend/internal/version"
 
 	"github.com/gin-gonic/gin"
 )
 
 type SystemHandler struct {
-	alphaVantageClient *services.AlphaVantageClient
-	schedulerService   *services.SchedulerService
+	provider           services.MarketDataProvider
+	schedulerService   services.SyncScheduler
+	jobQueue           *jobs.Queue
+	health             *services.HealthChecker
+	cache              *cache.RedisCache
 }
 
-func NewSystemHandler(alphaVantageClient *services.AlphaVantageClient, schedulerService *services.SchedulerService) *SystemHandler {
+func NewSystemHandler(provider services.MarketDataProvider, schedulerService services.SyncScheduler, jobQueue *jobs.Queue) *SystemHandler {
 	return &SystemHandler{
-		alphaVantageClient: alphaVantageClient,
+		provider:           provider,
 		schedulerService:   schedulerService,
+		jobQueue:           jobQueue,
 	}
 }
 
-// GetAPIStatus returns the current Alpha Vantage API status and rate limits
+// SetHealthChecker sets the checker whose database, cache and data freshness
+// checks the system health includes
+func (h *SystemHandler) SetHealthChecker(checker *services.HealthChecker) {
+	h.health = checker
+}
+
+// SetCache sets the cache whose statistics /system/cache reports; nil reports it disabled
+func (h *SystemHandler) SetCache(redisCache *cache.RedisCache) {
+	h.cache = redisCache
+}
+
+// GetAPIStatus returns the market data provider's API status and rate limits
 func (h *SystemHandler) GetAPIStatus(c *gin.Context) {
-	rateLimit, err := h.alphaVantageClient.GetRateLimit()
+	rateLimit, err := h.provider.GetRateLimit()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get API rate limit status",
-			"details": err.Error(),
-		})
+		respondServiceError(c, "Failed to get API rate limit status", err)
+		return
+	}
+
+	budget, err := h.provider.GetAPIBudget()
+	if err != nil {
+		respondServiceError(c, "Failed to get API rate limit status", err)
 		return
 	}
 
 	// Get API call stats for last 7 days
-	stats, err := h.alphaVantageClient.GetAPICallStats(7)
+	stats, err := h.provider.GetAPICallStats(7)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get API call statistics",
-			"details": err.Error(),
-		})
+		respondServiceError(c, "Failed to get API call statistics", err)
+		return
+	}
+
+	// Today's usage broken down by the component that spent it
+	usageByInitiator, err := h.provider.GetAPICallStatsByInitiator(0)
+	if err != nil {
+		respondServiceError(c, "Failed to get API usage by initiator", err)
 		return
 	}
 
 	response := gin.H{
-		"service": "alphavantage",
+		"service": h.provider.Name(),
 		"status":  "active",
 		"rate_limit": gin.H{
-			"daily_limit":          rateLimit.DailyLimit,
-			"daily_used":           rateLimit.CurrentDailyCount,
-			"daily_remaining":      rateLimit.RemainingDaily(),
-			"hourly_limit":         rateLimit.HourlyLimit,
-			"hourly_used":          rateLimit.CurrentHourlyCount,
-			"hourly_remaining":     rateLimit.RemainingHourly(),
-			"can_make_request":     rateLimit.CanMakeRequest(),
+			"daily_limit":          budget.DailyLimit,
+			"daily_used":           budget.DailyUsed,
+			"daily_remaining":      budget.DailyRemaining,
+			"hourly_limit":         budget.HourlyLimit,
+			"hourly_used":          budget.HourlyUsed,
+			"hourly_remaining":     budget.HourlyRemaining,
+			"can_make_request":     budget.CanMakeRequest,
 			"last_reset_date":      rateLimit.LastResetDate.Format("2006-01-02"),
 			"last_reset_hour":      rateLimit.LastResetHour,
 		},
+		"pacing": gin.H{
+			"calls_per_minute":     budget.CallsPerMinute,
+			"last_call_at":         budget.LastCallAt,
+			"next_call_allowed_at": budget.NextCallAllowedAt,
+			"limited_by":           budget.LimitedBy,
+		},
 		"statistics": stats,
+		"usage_by_initiator": usageByInitiator,
+		"manual_sync_dedup": h.schedulerService.GetManualSyncDedupStats(),
 		"updated_at": time.Now(),
 	}
 
@@ -74,101 +111,206 @@ func (h *SystemHandler) GetDataSyncStatus(c *gin.Context) {
 	})
 }
 
-// TriggerManualSync triggers a manual sync for a specific stock
+// TriggerManualSync queues a manual sync for a specific stock and returns at once
+// with the job; progress is reported by GetManualSyncStatus. mode=quote
+// refreshes only the latest trading day from one quote; mode=full, the
+// default, fetches the missing history.
 func (h *SystemHandler) TriggerManualSync(c *gin.Context) {
-	symbol := c.Param("symbol")
-	if symbol == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Stock symbol is required",
-		})
+	symbol, err := models.NormalizeSymbol(c.Param("symbol"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock symbol", err.Error()))
+		return
+	}
+	mode, err := services.ParseSyncMode(c.Query("mode"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, err)
 		return
 	}
 
-	err := h.schedulerService.TriggerManualSync(symbol)
+	job, deduplicated, err := h.schedulerService.TriggerManualSync(c.Request.Context(), symbol, mode)
+	if errors.Is(err, services.ErrSyncInProgress) {
+		respondError(c, http.StatusConflict, CodeConflict, withDetails("Sync already in progress for this symbol", gin.H{
+			"symbol": symbol,
+		}))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to trigger manual sync",
-			"details": err.Error(),
+		respondServiceError(c, "Failed to trigger manual sync", err)
+		return
+	}
+
+	// A second request while the first is queued or running spends no API call
+	if deduplicated && !job.IsFinished() {
+		respondError(c, http.StatusConflict, CodeConflict, withDetails("Manual sync already queued or running for this symbol", gin.H{
+			"symbol": symbol,
+			"job":    job,
+		}))
+		return
+	}
+
+	// A sync that just completed is reused rather than spending another API call
+	if deduplicated {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Manual sync recently completed",
+			"symbol":  symbol,
+			"mode":    mode,
+			"job":     job,
+			"deduplicated": true,
+			"timestamp": time.Now(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Manual sync triggered successfully",
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Manual sync queued",
 		"symbol":  symbol,
+		"mode":    mode,
+		"job":     job,
+		"deduplicated": false,
 		"timestamp": time.Now(),
 	})
 }
 
-// GetSystemHealth returns overall system health status
-func (h *SystemHandler) GetSystemHealth(c *gin.Context) {
-	// Get data sync status
-	syncStatus := h.schedulerService.GetStatus()
-	
-	// Get API rate limit status
-	rateLimit, err := h.alphaVantageClient.GetRateLimit()
+// GetManualSyncStatus reports whether a symbol's latest manual sync is queued,
+// running, done or failed, with its result or error
+func (h *SystemHandler) GetManualSyncStatus(c *gin.Context) {
+	symbol, err := models.NormalizeSymbol(c.Param("symbol"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get system health",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid stock symbol", err.Error()))
 		return
 	}
 
-	// Determine overall health
-	health := "healthy"
-	if !syncStatus.IsRunning {
-		health = "degraded"
+	status, err := h.schedulerService.GetManualSyncStatus(symbol)
+	if errors.Is(err, services.ErrNoManualSync) {
+		respondError(c, http.StatusNotFound, CodeNotFound, withDetails("No manual sync for this symbol since the server started", gin.H{
+			"symbol": symbol,
+		}))
+		return
 	}
-	if !rateLimit.CanMakeRequest() && syncStatus.ProcessedToday == 0 {
-		health = "unhealthy"
+	if err != nil {
+		respondServiceError(c, "Failed to get manual sync status", err)
+		return
 	}
 
-	response := gin.H{
-		"status": health,
-		"components": gin.H{
-			"database": gin.H{
-				"status": "healthy", // We assume DB is healthy if we can query it
-			},
-			"scheduler": gin.H{
-				"status": map[bool]string{true: "healthy", false: "unhealthy"}[syncStatus.IsRunning],
-				"details": gin.H{
-					"last_sync":        syncStatus.LastSync,
-					"next_sync":        syncStatus.NextSync,
-					"processed_today":  syncStatus.ProcessedToday,
-					"total_stocks":     syncStatus.TotalStocks,
-					"recent_errors":    len(syncStatus.Errors),
-				},
-			},
-			"api": gin.H{
-				"status": map[bool]string{true: "healthy", false: "rate_limited"}[rateLimit.CanMakeRequest()],
-				"details": gin.H{
-					"daily_remaining":  rateLimit.RemainingDaily(),
-					"hourly_remaining": rateLimit.RemainingHourly(),
-				},
-			},
+	c.JSON(http.StatusOK, gin.H{
+		"sync":       status,
+		"updated_at": time.Now(),
+	})
+}
+
+// GetJob returns the status of a background job
+func (h *SystemHandler) GetJob(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Invalid job ID"))
+		return
+	}
+
+	job, err := h.jobQueue.Get(id)
+	if err != nil {
+		if errors.Is(err, jobs.ErrJobNotFound) {
+			respondError(c, http.StatusNotFound, CodeNotFound, withDetails("Job not found", gin.H{
+				"id": id,
+			}))
+			return
+		}
+		respondServiceError(c, "Failed to get job", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job":        job,
+		"updated_at": time.Now(),
+	})
+}
+
+// GetSystemHealth checks each component and reports the worst of their
+// statuses overall, with 503 when that is unhealthy so load balancers can
+// take the instance out of rotation
+func (h *SystemHandler) GetSystemHealth(c *gin.Context) {
+	ctx := c.Request.Context()
+	components := gin.H{}
+	var statuses []string
+	report := func(name string, health services.ComponentHealth) {
+		components[name] = health
+		statuses = append(statuses, health.Status)
+	}
+	
+	if h.health != nil {
+		report("database", h.health.CheckDatabase(ctx))
+		report("cache", h.health.CheckCache(ctx))
+		report("data_freshness", h.health.CheckDataFreshness(ctx))
+	}
+	
+	// A stopped scheduler leaves the data to go stale but serving continues
+	syncStatus := h.schedulerService.GetStatus()
+	scheduler := services.ComponentHealth{
+		Status: services.HealthHealthy,
+		Details: map[string]interface{}{
+			"paused":             syncStatus.Paused,
+			"last_sync":          syncStatus.LastSync,
+			"next_sync":          syncStatus.NextSync,
+			"processed_today":    syncStatus.ProcessedToday,
+			"last_run_processed": syncStatus.LastRunProcessed,
+			"total_stocks":       syncStatus.TotalStocks,
+			"recent_errors":      len(syncStatus.Errors),
 		},
-		"timestamp": time.Now(),
 	}
+	if !syncStatus.IsRunning {
+		scheduler.Status = services.HealthDegraded
+	}
+	report("scheduler", scheduler)
+	
+	// Rate limited with nothing synced today means no fresh data is coming
+	api := services.ComponentHealth{Status: services.HealthHealthy}
+	if budget, err := h.provider.GetAPIBudget(); err != nil {
+		api = services.ComponentHealth{Status: services.HealthUnhealthy, Error: err.Error()}
+	} else {
+		api.Details = map[string]interface{}{
+			"rate_limited":         !budget.CanMakeRequest,
+			"daily_remaining":      budget.DailyRemaining,
+			"hourly_remaining":     budget.HourlyRemaining,
+			"next_call_allowed_at": budget.NextCallAllowedAt,
+		}
+		if !budget.CanMakeRequest {
+			api.Status = services.HealthDegraded
+			if syncStatus.ProcessedToday == 0 {
+				api.Status = services.HealthUnhealthy
+			}
+		}
+	}
+	report("api", api)
+	
+	overall := services.WorstHealth(statuses...)
+	status := http.StatusOK
+	if overall == services.HealthUnhealthy {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, gin.H{
+		"status":     overall,
+		"components": components,
+		"timestamp":  time.Now(),
+	})
+}
 
-	c.JSON(http.StatusOK, response)
+// GetVersion returns the running build's version, commit and build time,
+// with the process's start time and uptime
+func (h *SystemHandler) GetVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, version.Get())
 }
 
 // GetAPICallHistory returns detailed API call history
 func (h *SystemHandler) GetAPICallHistory(c *gin.Context) {
 	// Get days parameter from query string, default to 7
-	daysStr := c.DefaultQuery("days", "7")
+	daysStr := c.DefaultQuery("days", strconv.Itoa(defaultAPIHistoryDays))
 	days, err := strconv.Atoi(daysStr)
-	if err != nil || days < 1 || days > 30 {
-		days = 7
+	if err != nil || days < 1 || days > maxAPIHistoryDays {
+		days = defaultAPIHistoryDays
 	}
 
-	stats, err := h.alphaVantageClient.GetAPICallStats(days)
+	stats, err := h.provider.GetAPICallStats(days)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get API call history",
-			"details": err.Error(),
-		})
+		respondServiceError(c, "Failed to get API call history", err)
 		return
 	}
 
@@ -177,4 +319,202 @@ func (h *SystemHandler) GetAPICallHistory(c *gin.Context) {
 		"period_days": days,
 		"updated_at": time.Now(),
 	})
-}
\ No newline at end of file
+}
+
+// GetAPICalls returns logged calls to the market data provider, newest
+// first, filtered by status (success or failed) and endpoint, a page at a time
+func (h *SystemHandler) GetAPICalls(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("page must be a positive whole number"))
+		return
+	}
+
+	perPage, err := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(defaultPageSize)))
+	if err != nil || perPage <= 0 {
+		perPage = defaultPageSize
+	}
+	if perPage > maxPageSize {
+		perPage = maxPageSize
+	}
+
+	status := c.Query("status")
+	if status != "" && !slices.Contains(services.APICallStatuses, status) {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("status must be one of " + strings.Join(services.APICallStatuses, ", ")))
+		return
+	}
+
+	filter := services.APICallFilter{
+		Status:   status,
+		Endpoint: c.Query("endpoint"),
+		Limit:    perPage,
+		Offset:   (page - 1) * perPage,
+	}
+	calls, total, err := h.provider.ListAPICalls(c.Request.Context(), filter)
+	if err != nil {
+		respondServiceError(c, "Failed to get API calls", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"data":     calls,
+		"count":    len(calls),
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+		"has_more": filter.Offset+len(calls) < total,
+	})
+}
+
+// GetSchedulerRuns returns recent scheduled job runs, newest first, for one job
+// (sync, cleanup or rate_limit_reset) or for all of them
+func (h *SystemHandler) GetSchedulerRuns(c *gin.Context) {
+	job := c.Query("job")
+	if job != "" && !slices.Contains(services.SchedulerJobs, job) {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("job must be one of " + strings.Join(services.SchedulerJobs, ", ")))
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)))
+	if err != nil || limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	runs, err := h.schedulerService.ListRuns(c.Request.Context(), job, limit)
+	if err != nil {
+		respondServiceError(c, "Failed to get scheduler runs", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs":       runs,
+		"count":      len(runs),
+		"limit":      limit,
+		"updated_at": time.Now(),
+	})
+}
+
+// GetSyncQueue returns the stocks the scheduled sync will pick next, in order,
+// with the score that placed each one
+func (h *SystemHandler) GetSyncQueue(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSyncQueueLimit)))
+	if err != nil || limit <= 0 {
+		limit = defaultSyncQueueLimit
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	queue, err := h.schedulerService.GetSyncQueue(c.Request.Context(), limit)
+	if err != nil {
+		respondServiceError(c, "Failed to get sync queue", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"queue":      queue,
+		"count":      len(queue),
+		"limit":      limit,
+		"updated_at": time.Now(),
+	})
+}
+
+// GetDataGaps lists the active stocks missing trading days inside their
+// stored price history, with the missing ranges
+func (h *SystemHandler) GetDataGaps(c *gin.Context) {
+	minGapDays, err := strconv.Atoi(c.DefaultQuery("min_gap_days", strconv.Itoa(defaultMinGapDays)))
+	if err != nil || minGapDays <= 0 {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("min_gap_days must be a positive whole number"))
+		return
+	}
+
+	report, err := h.schedulerService.GetDataGaps(c.Request.Context(), minGapDays)
+	if err != nil {
+		respondServiceError(c, "Failed to find data gaps", err)
+		return
+	}
+
+	missing := 0
+	for _, stock := range report {
+		missing += stock.MissingDays
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stocks":       report,
+		"count":        len(report),
+		"missing_days": missing,
+		"min_gap_days": minGapDays,
+		"updated_at":   time.Now(),
+	})
+}
+
+// PauseScheduler stops scheduled jobs from doing any work, and so from
+// spending API budget, until the scheduler is resumed
+func (h *SystemHandler) PauseScheduler(c *gin.Context) {
+	h.setSchedulerPaused(c, true)
+}
+
+// ResumeScheduler lets scheduled jobs run again
+func (h *SystemHandler) ResumeScheduler(c *gin.Context) {
+	h.setSchedulerPaused(c, false)
+}
+
+func (h *SystemHandler) setSchedulerPaused(c *gin.Context, paused bool) {
+	change, message := h.schedulerService.Resume, "Scheduler resumed"
+	if paused {
+		change, message = h.schedulerService.Pause, "Scheduler paused"
+	}
+
+	// The change takes effect even when it could not be saved
+	if err := change(c.Request.Context()); err != nil {
+		respondError(c, http.StatusInternalServerError, CodeInternal, withDetails(message + " until restart; failed to save the state", gin.H{
+			"paused": paused,
+			"reason": err.Error(),
+		}))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    message,
+		"paused":     paused,
+		"updated_at": time.Now(),
+	})
+}
+
+// GetCacheStats reports the cache reads and writes counted per key class,
+// with the keys and memory the cache holds
+func (h *SystemHandler) GetCacheStats(c *gin.Context) {
+	if h.cache == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+	
+	stats, err := h.cache.Stats(c.Request.Context())
+	if err != nil {
+		respondServiceError(c, "Failed to read cache statistics", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": true,
+		"stats":   stats,
+	})
+}
+
+// ResetCacheStats zeroes the cache's read and write counts; the cached data is kept
+func (h *SystemHandler) ResetCacheStats(c *gin.Context) {
+	if h.cache == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+	
+	h.cache.ResetStats()
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":  true,
+		"message":  "Cache statistics reset",
+		"reset_at": time.Now(),
+	})
+}
@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/cache"
+	"stock-intelligence-backend/internal/jobs"
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var schedulerRunColumns = []string{"id", "job_name", "started_at", "finished_at", "status", "detail", "symbol"}
+
+func newSystemRouter(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// The queue is never started, so manual syncs are only queued
+	queue := jobs.NewQueue(jobs.NewPostgresStore(db), 1)
+	scheduler := services.NewSchedulerService(db, nil, nil, queue, nil)
+
+	gin.SetMode(gin.TestMode)
+	handler := NewSystemHandler(nil, scheduler, queue)
+	router := gin.New()
+	router.GET("/system/scheduler/runs", handler.GetSchedulerRuns)
+	router.POST("/system/sync/:symbol", handler.TriggerManualSync)
+	router.GET("/system/sync/:symbol/status", handler.GetManualSyncStatus)
+	router.GET("/system/sync-queue", handler.GetSyncQueue)
+	router.GET("/system/data-gaps", handler.GetDataGaps)
+	return router, mock
+}
+
+func TestGetSchedulerRuns_FiltersByJob(t *testing.T) {
+	router, mock := newSystemRouter(t)
+
+	startedAt := time.Date(2026, time.March, 9, 21, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM scheduler_runs").WithArgs(services.SchedulerJobSync, 20).
+		WillReturnRows(sqlmock.NewRows(schedulerRunColumns).
+			AddRow(7, services.SchedulerJobSync, startedAt, startedAt.Add(time.Minute), services.RunStatusFailed,
+				"Failed to fetch data for AAPL: timeout", ""))
+
+	code, response := serveSyncRequest(t, router, http.MethodGet, "/system/scheduler/runs?job=sync&limit=20")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(1), response["count"])
+	assert.Equal(t, float64(20), response["limit"])
+
+	runs := response["runs"].([]interface{})
+	run := runs[0].(map[string]interface{})
+	assert.Equal(t, "sync", run["job_name"])
+	assert.Equal(t, "failed", run["status"])
+	assert.Equal(t, "Failed to fetch data for AAPL: timeout", run["detail"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSchedulerRuns_AllJobsByDefault(t *testing.T) {
+	router, mock := newSystemRouter(t)
+
+	mock.ExpectQuery("FROM scheduler_runs").WithArgs("", 50).
+		WillReturnRows(sqlmock.NewRows(schedulerRunColumns))
+
+	code, response := serveSyncRequest(t, router, http.MethodGet, "/system/scheduler/runs")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []interface{}{}, response["runs"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSchedulerRuns_RejectsUnknownJob(t *testing.T) {
+	router, mock := newSystemRouter(t)
+
+	code, response := serveSyncRequest(t, router, http.MethodGet, "/system/scheduler/runs?job=backup")
+	assert.Equal(t, http.StatusBadRequest, code)
+	envelope := responseError(t, response)
+	assert.Equal(t, string(CodeValidation), envelope["code"])
+	assert.Contains(t, envelope["message"], "sync, cleanup, rate_limit_reset")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSyncQueue(t *testing.T) {
+	router, mock := newSystemRouter(t)
+
+	// No constituents imported, so the built-in S&P 500 list ranks the stocks
+	mock.ExpectQuery("FROM index_constituents").WillReturnRows(sqlmock.NewRows([]string{"symbol", "company_name", "priority", "market_cap"}))
+	mock.ExpectQuery("LAG\\(dp.date\\)").WillReturnRows(sqlmock.NewRows([]string{"symbol", "prev_date", "date"}))
+	mock.ExpectQuery("SELECT s.symbol").WillReturnRows(sqlmock.NewRows([]string{"symbol", "company_name", "last_price_date", "market_cap", "updated_at", "quote_price_date"}).
+		AddRow("TINY", "Tiny Holdings", nil, int64(50000000), nil, nil).
+		AddRow("AAPL", "Apple Inc.", time.Now().AddDate(0, 0, -7), int64(3000000000000), time.Now().AddDate(0, 0, -7), nil))
+
+	code, response := serveSyncRequest(t, router, http.MethodGet, "/system/sync-queue?limit=1")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(1), response["count"])
+
+	queue := response["queue"].([]interface{})
+	next := queue[0].(map[string]interface{})
+	assert.Equal(t, "AAPL", next["symbol"], "a week-stale S&P 500 leader goes before an obscure stock with no data")
+	assert.Equal(t, float64(1), next["sp500_priority"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDataGaps(t *testing.T) {
+	router, mock := newSystemRouter(t)
+
+	// MSFT is missing one day, AAPL the week of March 9
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+	mock.ExpectQuery("LAG\\(dp.date\\)").WithArgs(sqlmock.AnyArg(), "").
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "prev_date", "date"}).
+			AddRow("AAPL", day(time.March, 6), day(time.March, 16)).
+			AddRow("MSFT", day(time.March, 2), day(time.March, 4)))
+
+	code, response := serveSyncRequest(t, router, http.MethodGet, "/system/data-gaps?min_gap_days=3")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(1), response["count"])
+	assert.Equal(t, float64(5), response["missing_days"])
+
+	stock := response["stocks"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "AAPL", stock["symbol"])
+	gap := stock["gaps"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "2026-03-09T00:00:00Z", gap["from"])
+	assert.Equal(t, "2026-03-13T00:00:00Z", gap["to"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	code, _ = serveSyncRequest(t, router, http.MethodGet, "/system/data-gaps?min_gap_days=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+var jobColumns = []string{"id", "job_type", "payload", "status", "attempts", "max_attempts",
+	"error_message", "result", "created_at", "started_at", "finished_at"}
+
+func TestTriggerManualSync_ConcurrentRequestsQueueOneSync(t *testing.T) {
+	router, mock := newSystemRouter(t)
+
+	// One request queues the sync; the other finds it queued
+	mock.ExpectQuery("INSERT INTO jobs").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, time.Now()))
+	mock.ExpectQuery("FROM jobs WHERE id = \\$1").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(jobColumns).
+			AddRow(7, services.JobTypeManualSync, `{"symbol":"AAPL"}`, jobs.StatusQueued, 0, 1, "", nil, time.Now(), nil, nil))
+
+	var wg sync.WaitGroup
+	recorders := make([]*httptest.ResponseRecorder, 2)
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(w *httptest.ResponseRecorder) {
+			defer wg.Done()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/system/sync/aapl", nil))
+		}(recorders[i])
+	}
+	wg.Wait()
+
+	codes := []int{recorders[0].Code, recorders[1].Code}
+	assert.ElementsMatch(t, []int{http.StatusAccepted, http.StatusConflict}, codes)
+	for _, w := range recorders {
+		var response struct {
+			Job   *jobs.Job `json:"job"`
+			Error struct {
+				Code    ErrorCode `json:"code"`
+				Details struct {
+					Job *jobs.Job `json:"job"`
+				} `json:"details"`
+			} `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		job := response.Job
+		if w.Code == http.StatusConflict {
+			assert.Equal(t, CodeConflict, response.Error.Code)
+			job = response.Error.Details.Job
+		}
+		require.NotNil(t, job)
+		assert.Equal(t, int64(7), job.ID, "both requests point at the one queued sync")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTriggerManualSync_Mode(t *testing.T) {
+	router, mock := newSystemRouter(t)
+
+	code, response := serveSyncRequest(t, router, http.MethodPost, "/system/sync/AAPL?mode=intraday")
+	require.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, string(CodeValidation), responseError(t, response)["code"])
+
+	mock.ExpectQuery("INSERT INTO jobs").
+		WithArgs(services.JobTypeManualSync, []byte(`{"symbol":"AAPL","mode":"quote"}`), jobs.StatusQueued, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, time.Now()))
+
+	code, response = serveSyncRequest(t, router, http.MethodPost, "/system/sync/AAPL?mode=quote")
+	require.Equal(t, http.StatusAccepted, code)
+	assert.Equal(t, "quote", response["mode"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetManualSyncStatus(t *testing.T) {
+	router, mock := newSystemRouter(t)
+
+	code, _ := serveSyncRequest(t, router, http.MethodGet, "/system/sync/AAPL/status")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	queuedAt := time.Date(2026, time.March, 10, 22, 0, 0, 0, time.UTC)
+	finishedAt := queuedAt.Add(5 * time.Second)
+	mock.ExpectQuery("INSERT INTO jobs").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, queuedAt))
+	mock.ExpectQuery("FROM jobs WHERE id = \\$1").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(jobColumns).
+			AddRow(7, services.JobTypeManualSync, `{"symbol":"AAPL"}`, jobs.StatusSucceeded, 1, 1, "",
+				`{"symbol":"AAPL","synced_at":"2026-03-10T22:00:05Z"}`, queuedAt, queuedAt, finishedAt))
+
+	code, _ = serveSyncRequest(t, router, http.MethodPost, "/system/sync/AAPL")
+	require.Equal(t, http.StatusAccepted, code)
+
+	code, response := serveSyncRequest(t, router, http.MethodGet, "/system/sync/aapl/status")
+	require.Equal(t, http.StatusOK, code)
+	status := response["sync"].(map[string]interface{})
+	assert.Equal(t, services.ManualSyncDone, status["state"])
+	assert.Equal(t, float64(7), status["job_id"])
+	assert.Equal(t, "2026-03-10T22:00:05Z", status["result"].(map[string]interface{})["synced_at"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPauseAndResumeScheduler(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	handler := NewSystemHandler(nil, services.NewSchedulerService(db, nil, nil, nil, nil), nil)
+	router := gin.New()
+	router.POST("/system/scheduler/pause", handler.PauseScheduler)
+	router.POST("/system/scheduler/resume", handler.ResumeScheduler)
+
+	mock.ExpectExec("INSERT INTO system_settings").WithArgs(services.SettingSchedulerPaused, "true").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	code, response := serveSyncRequest(t, router, http.MethodPost, "/system/scheduler/pause")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, response["paused"])
+
+	mock.ExpectExec("INSERT INTO system_settings").WithArgs(services.SettingSchedulerPaused, "false").
+		WillReturnError(errors.New("connection refused"))
+	code, response = serveSyncRequest(t, router, http.MethodPost, "/system/scheduler/resume")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	envelope := responseError(t, response)
+	assert.Contains(t, envelope["message"], "until restart")
+	assert.Equal(t, false, envelope["details"].(map[string]interface{})["paused"])
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// budgetProvider is a market data provider reporting a fixed API budget
+type budgetProvider struct {
+	services.MarketDataProvider
+	budget services.APIBudget
+}
+
+func (p budgetProvider) GetAPIBudget() (*services.APIBudget, error) { return &p.budget, nil }
+
+func TestGetSystemHealth(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	handler := NewSystemHandler(budgetProvider{budget: services.APIBudget{CanMakeRequest: true}},
+		services.NewSchedulerService(db, nil, nil, nil, nil), nil)
+	handler.SetHealthChecker(services.NewHealthChecker(db, nil))
+	router := gin.New()
+	router.GET("/system/health", handler.GetSystemHealth)
+
+	getHealth := func() (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/system/health", nil))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+	component := func(body map[string]interface{}, name string) map[string]interface{} {
+		return body["components"].(map[string]interface{})[name].(map[string]interface{})
+	}
+
+	// The scheduler was never started, which only degrades the service
+	mock.ExpectPing()
+	mock.ExpectQuery(`SELECT MAX\(date\) FROM daily_prices`).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(time.Now()))
+	code, body := getHealth()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "degraded", body["status"])
+	assert.Equal(t, "healthy", component(body, "database")["status"])
+	assert.Contains(t, component(body, "database"), "latency_ms")
+	assert.Equal(t, "disabled", component(body, "cache")["status"])
+	assert.Equal(t, "healthy", component(body, "data_freshness")["status"])
+	assert.Equal(t, "degraded", component(body, "scheduler")["status"])
+
+	// A failing ping makes the whole service unhealthy, which load balancers see as 503
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectQuery(`SELECT MAX\(date\) FROM daily_prices`).WillReturnError(errors.New("connection refused"))
+	code, body = getHealth()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unhealthy", body["status"])
+	assert.Equal(t, "unhealthy", component(body, "database")["status"])
+	assert.Equal(t, "connection refused", component(body, "database")["error"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// apiCallsProvider is a market data provider that lists a fixed page of
+// calls and keeps the filter it was asked for
+type apiCallsProvider struct {
+	services.MarketDataProvider
+	calls  []services.APICallRecord
+	total  int
+	filter *services.APICallFilter
+}
+
+func (p apiCallsProvider) ListAPICalls(_ context.Context, filter services.APICallFilter) ([]services.APICallRecord, int, error) {
+	*p.filter = filter
+	return p.calls, p.total, nil
+}
+
+func TestGetAPICalls(t *testing.T) {
+	var filter services.APICallFilter
+	provider := apiCallsProvider{
+		calls:  []services.APICallRecord{{ID: 9, Endpoint: "TIME_SERIES_DAILY", ResponseStatus: 503}},
+		total:  51,
+		filter: &filter,
+	}
+
+	gin.SetMode(gin.TestMode)
+	handler := NewSystemHandler(provider, nil, nil)
+	router := gin.New()
+	router.GET("/system/api-calls", handler.GetAPICalls)
+
+	code, response := serveSyncRequest(t, router, http.MethodGet, "/system/api-calls?status=failed&endpoint=TIME_SERIES_DAILY&page=2&per_page=50")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, services.APICallFilter{Status: "failed", Endpoint: "TIME_SERIES_DAILY", Limit: 50, Offset: 50}, filter)
+	assert.Equal(t, float64(1), response["count"])
+	assert.Equal(t, float64(51), response["total"])
+	assert.Equal(t, float64(2), response["page"])
+	assert.Equal(t, false, response["has_more"])
+	call := response["data"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, float64(503), call["response_status"])
+
+	// Defaults are the first page of every call, and page sizes are capped
+	code, response = serveSyncRequest(t, router, http.MethodGet, "/system/api-calls?per_page=100000")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, services.APICallFilter{Limit: maxPageSize}, filter)
+	assert.Equal(t, float64(1), response["page"])
+	assert.Equal(t, true, response["has_more"])
+
+	for _, query := range []string{"status=pending", "page=0", "page=two"} {
+		code, response = serveSyncRequest(t, router, http.MethodGet, "/system/api-calls?"+query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+		assert.Equal(t, string(CodeValidation), responseError(t, response)["code"], query)
+	}
+}
+
+func TestGetVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/system/version", NewSystemHandler(nil, nil, nil).GetVersion)
+
+	code, first := serveSyncRequest(t, router, http.MethodGet, "/system/version")
+	require.Equal(t, http.StatusOK, code)
+	for _, field := range []string{"version", "commit", "build_time", "go_version", "started_at", "uptime_seconds"} {
+		assert.Contains(t, first, field)
+	}
+	assert.Equal(t, "dev", first["version"])
+	assert.Equal(t, runtime.Version(), first["go_version"])
+
+	time.Sleep(5 * time.Millisecond)
+	_, second := serveSyncRequest(t, router, http.MethodGet, "/system/version")
+	assert.Equal(t, first["started_at"], second["started_at"])
+	assert.Greater(t, second["uptime_seconds"], first["uptime_seconds"])
+}
+
+func TestGetCacheStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewSystemHandler(nil, nil, nil)
+	router := gin.New()
+	router.GET("/system/cache", handler.GetCacheStats)
+	router.POST("/system/cache/reset", handler.ResetCacheStats)
+
+	code, body := serveSyncRequest(t, router, http.MethodGet, "/system/cache")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, false, body["enabled"])
+
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache("redis://"+server.Addr(), "test:")
+	require.NoError(t, err)
+	defer redisCache.Close()
+	handler.SetCache(redisCache)
+
+	ctx := context.Background()
+	var stocks []string
+	require.Error(t, redisCache.GetStocksList(ctx, &stocks))
+	require.NoError(t, redisCache.SetStocksList(ctx, []string{"AAPL"}, time.Hour))
+	require.NoError(t, redisCache.GetStocksList(ctx, &stocks))
+
+	code, body = serveSyncRequest(t, router, http.MethodGet, "/system/cache")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, body["enabled"])
+	stats := body["stats"].(map[string]interface{})
+	assert.Equal(t, float64(1), stats["keys"])
+	assert.Equal(t, 0.5, stats["classes"].(map[string]interface{})["stocks_list"].(map[string]interface{})["hit_rate"])
+	assert.Equal(t, float64(1), stats["total"].(map[string]interface{})["sets"])
+
+	code, body = serveSyncRequest(t, router, http.MethodPost, "/system/cache/reset")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "reset_at")
+
+	_, body = serveSyncRequest(t, router, http.MethodGet, "/system/cache")
+	total := body["stats"].(map[string]interface{})["total"].(map[string]interface{})
+	assert.Equal(t, float64(0), total["hits"])
+	assert.Equal(t, float64(0), total["sets"])
+}
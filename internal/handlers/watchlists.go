@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WatchlistHandler handles watchlist HTTP requests
+type WatchlistHandler struct {
+	watchlistService *services.WatchlistService
+}
+
+// NewWatchlistHandler creates a new watchlist handler
+func NewWatchlistHandler(watchlistService *services.WatchlistService) *WatchlistHandler {
+	return &WatchlistHandler{
+		watchlistService: watchlistService,
+	}
+}
+
+type createWatchlistRequest struct {
+	Name string `json:"name"`
+}
+
+type addWatchlistSymbolRequest struct {
+	Symbol string `json:"symbol"`
+}
+
+// CreateWatchlist creates an empty watchlist
+func (h *WatchlistHandler) CreateWatchlist(c *gin.Context) {
+	var req createWatchlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Watchlist name is required"))
+		return
+	}
+
+	watchlist, err := h.watchlistService.CreateWatchlist(strings.TrimSpace(req.Name))
+	if err != nil {
+		respondServiceError(c, "Failed to create watchlist", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    watchlist,
+	})
+}
+
+// GetWatchlist returns a watchlist with the latest price of each stock on it
+func (h *WatchlistHandler) GetWatchlist(c *gin.Context) {
+	id, ok := watchlistID(c)
+	if !ok {
+		return
+	}
+	h.respondWithWatchlist(c, id)
+}
+
+// AddSymbol adds a stock to a watchlist; adding one already on it succeeds without change
+func (h *WatchlistHandler) AddSymbol(c *gin.Context) {
+	id, ok := watchlistID(c)
+	if !ok {
+		return
+	}
+
+	var req addWatchlistSymbolRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Symbol) == "" {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Stock symbol is required"))
+		return
+	}
+	symbol := strings.ToUpper(strings.TrimSpace(req.Symbol))
+
+	err := h.watchlistService.AddSymbol(id, symbol)
+	if errors.Is(err, services.ErrUnknownSymbol) {
+		respondError(c, http.StatusUnprocessableEntity, CodeValidation, withDetails("Unknown stock symbol", gin.H{
+			"symbol": symbol,
+		}))
+		return
+	}
+	if !h.handleWatchlistError(c, err) {
+		return
+	}
+	h.respondWithWatchlist(c, id)
+}
+
+// RemoveSymbol removes a stock from a watchlist
+func (h *WatchlistHandler) RemoveSymbol(c *gin.Context) {
+	id, ok := watchlistID(c)
+	if !ok {
+		return
+	}
+
+	err := h.watchlistService.RemoveSymbol(id, strings.ToUpper(c.Param("symbol")))
+	if !h.handleWatchlistError(c, err) {
+		return
+	}
+	h.respondWithWatchlist(c, id)
+}
+
+func (h *WatchlistHandler) respondWithWatchlist(c *gin.Context, id int64) {
+	watchlist, err := h.watchlistService.GetWatchlist(id)
+	if !h.handleWatchlistError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    watchlist,
+	})
+}
+
+// handleWatchlistError writes the error response for err and reports whether the request may continue
+func (h *WatchlistHandler) handleWatchlistError(c *gin.Context, err error) bool {
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, services.ErrWatchlistNotFound) {
+		respondError(c, http.StatusNotFound, CodeNotFound, errors.New("Watchlist not found"))
+		return false
+	}
+	respondServiceError(c, "Watchlist request failed", err)
+	return false
+}
+
+// watchlistID parses the :id parameter, writing a 400 when it is invalid
+func watchlistID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Invalid watchlist ID"))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var watchlistItemColumns = []string{"symbol", "company_name", "current_price", "change_percent", "added_at"}
+
+func newWatchlistRouter(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	handler := NewWatchlistHandler(services.NewWatchlistService(db))
+	router := gin.New()
+	router.POST("/watchlists", handler.CreateWatchlist)
+	router.GET("/watchlists/:id", handler.GetWatchlist)
+	router.POST("/watchlists/:id/symbols", handler.AddSymbol)
+	router.DELETE("/watchlists/:id/symbols/:symbol", handler.RemoveSymbol)
+	return router, mock
+}
+
+func expectWatchlistExists(mock sqlmock.Sqlmock, id int64, exists bool) {
+	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM watchlists`).WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(exists))
+}
+
+// expectWatchlist expects the watchlist to be read back with the given items
+func expectWatchlist(mock sqlmock.Sqlmock, id int64, items *sqlmock.Rows) {
+	now := time.Now()
+	mock.ExpectQuery("SELECT name, created_at, updated_at FROM watchlists").WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "created_at", "updated_at"}).AddRow("Tech", now, now))
+	mock.ExpectQuery(`FROM watchlist_items wi[\s\S]+LEFT JOIN LATERAL[\s\S]+latest ON true`).WithArgs(id).
+		WillReturnRows(items)
+}
+
+func serveWatchlistRequest(t *testing.T, router *gin.Engine, method, path, body string) (int, models.Watchlist) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Data models.Watchlist `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return w.Code, response.Data
+}
+
+func TestWatchlists_CRUDCycle(t *testing.T) {
+	router, mock := newWatchlistRouter(t)
+	now := time.Now()
+	withAAPL := func() *sqlmock.Rows {
+		return sqlmock.NewRows(watchlistItemColumns).AddRow("AAPL", "Apple Inc.", 150.25, 1.69, now)
+	}
+
+	// Create
+	mock.ExpectQuery("INSERT INTO watchlists").WithArgs("Tech").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(1, now, now))
+
+	code, watchlist := serveWatchlistRequest(t, router, "POST", "/watchlists", `{"name": "Tech"}`)
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, int64(1), watchlist.ID)
+	assert.Empty(t, watchlist.Items)
+
+	// Add a symbol; the response carries its latest price
+	expectWatchlistExists(mock, 1, true)
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(`INSERT INTO watchlist_items[\s\S]+ON CONFLICT \(watchlist_id, stock_id\) DO NOTHING`).WithArgs(int64(1), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectWatchlist(mock, 1, withAAPL())
+
+	code, watchlist = serveWatchlistRequest(t, router, "POST", "/watchlists/1/symbols", `{"symbol": "aapl"}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, watchlist.Items, 1)
+	assert.Equal(t, "AAPL", watchlist.Items[0].Symbol)
+	assert.Equal(t, 150.25, watchlist.Items[0].CurrentPrice)
+	assert.Equal(t, 1.69, watchlist.Items[0].ChangePercent)
+
+	// Adding it again changes nothing
+	expectWatchlistExists(mock, 1, true)
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec("INSERT INTO watchlist_items").WithArgs(int64(1), 7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectWatchlist(mock, 1, withAAPL())
+
+	code, watchlist = serveWatchlistRequest(t, router, "POST", "/watchlists/1/symbols", `{"symbol": "AAPL"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, watchlist.Items, 1)
+
+	// Read
+	expectWatchlist(mock, 1, withAAPL())
+
+	code, watchlist = serveWatchlistRequest(t, router, "GET", "/watchlists/1", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Tech", watchlist.Name)
+	assert.Len(t, watchlist.Items, 1)
+
+	// Remove
+	expectWatchlistExists(mock, 1, true)
+	mock.ExpectExec("DELETE FROM watchlist_items").WithArgs(int64(1), "AAPL").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectWatchlist(mock, 1, sqlmock.NewRows(watchlistItemColumns))
+
+	code, watchlist = serveWatchlistRequest(t, router, "DELETE", "/watchlists/1/symbols/AAPL", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Empty(t, watchlist.Items)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWatchlists_RoundsPricesAndChanges(t *testing.T) {
+	router, mock := newWatchlistRouter(t)
+
+	// The change percent is computed in the query to full precision
+	expectWatchlist(mock, 1, sqlmock.NewRows(watchlistItemColumns).
+		AddRow("AAPL", "Apple Inc.", 150.24999999, 1.6949152542, time.Now()))
+
+	code, watchlist := serveWatchlistRequest(t, router, "GET", "/watchlists/1", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, watchlist.Items, 1)
+	assert.Equal(t, 150.25, watchlist.Items[0].CurrentPrice)
+	assert.Equal(t, 1.69, watchlist.Items[0].ChangePercent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWatchlists_AddUnknownSymbol(t *testing.T) {
+	router, mock := newWatchlistRouter(t)
+
+	expectWatchlistExists(mock, 1, true)
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("NOTREAL").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	code, _ := serveWatchlistRequest(t, router, "POST", "/watchlists/1/symbols", `{"symbol": "NOTREAL"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWatchlists_NotFound(t *testing.T) {
+	router, mock := newWatchlistRouter(t)
+
+	mock.ExpectQuery("SELECT name, created_at, updated_at FROM watchlists").WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "created_at", "updated_at"}))
+	code, _ := serveWatchlistRequest(t, router, "GET", "/watchlists/2", "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	expectWatchlistExists(mock, 2, false)
+	code, _ = serveWatchlistRequest(t, router, "POST", "/watchlists/2/symbols", `{"symbol": "AAPL"}`)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = serveWatchlistRequest(t, router, "GET", "/watchlists/abc", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = serveWatchlistRequest(t, router, "POST", "/watchlists", `{"name": " "}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
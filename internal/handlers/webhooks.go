@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bounds of a caller-chosen webhook secret; the column holds 128 characters
+const (
+	minWebhookSecretLength = 16
+	maxWebhookSecretLength = 128
+)
+
+// WebhookHandler manages webhooks and test-fires them
+type WebhookHandler struct {
+	webhooks   *services.WebhookService
+	dispatcher *services.WebhookDispatcher
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(webhooks *services.WebhookService, dispatcher *services.WebhookDispatcher) *WebhookHandler {
+	return &WebhookHandler{webhooks: webhooks, dispatcher: dispatcher}
+}
+
+type webhookRequest struct {
+	URL    *string  `json:"url"`
+	Secret *string  `json:"secret"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+// ListWebhooks returns every webhook, without secrets
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	webhooks, err := h.webhooks.List(c.Request.Context())
+	if err != nil {
+		respondServiceError(c, "Failed to list webhooks", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    webhooks,
+		"count":   len(webhooks),
+	})
+}
+
+// CreateWebhook adds an active webhook; a secret is generated unless one is
+// given, and is only shown in this response
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Invalid request body"))
+		return
+	}
+	if req.URL == nil {
+		req.URL = new(string)
+	}
+	if req.Events == nil {
+		req.Events = []string{}
+	}
+	if !validateWebhookRequest(c, &req) {
+		return
+	}
+	secret := ""
+	if req.Secret != nil {
+		secret = *req.Secret
+	}
+
+	webhook, err := h.webhooks.Create(c.Request.Context(), *req.URL, secret, req.Events)
+	if err != nil {
+		respondServiceError(c, "Failed to create webhook", err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    webhook,
+	})
+}
+
+// GetWebhook returns a webhook, without its secret
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	webhook, err := h.webhooks.Get(c.Request.Context(), id)
+	if !handleWebhookError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    webhook,
+	})
+}
+
+// UpdateWebhook changes any of url, secret, events and active; fields left
+// out keep their values
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Invalid request body"))
+		return
+	}
+	if !validateWebhookRequest(c, &req) {
+		return
+	}
+
+	webhook, err := h.webhooks.Update(c.Request.Context(), id, services.WebhookUpdate{
+		URL:    req.URL,
+		Secret: req.Secret,
+		Events: req.Events,
+		Active: req.Active,
+	})
+	if !handleWebhookError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    webhook,
+	})
+}
+
+// DeleteWebhook removes a webhook
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	if !handleWebhookError(c, h.webhooks.Delete(c.Request.Context(), id)) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Webhook deleted",
+	})
+}
+
+// TestWebhook sends a webhook.test event to a webhook once, without retries,
+// and reports whether it answered 2xx
+func (h *WebhookHandler) TestWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	delivery, err := h.dispatcher.Test(c.Request.Context(), id)
+	if !handleWebhookError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    delivery,
+	})
+}
+
+// validateWebhookRequest checks the fields that are set, writing a 400 naming
+// the invalid ones
+func validateWebhookRequest(c *gin.Context, req *webhookRequest) bool {
+	invalid := gin.H{}
+	if req.URL != nil {
+		*req.URL = strings.TrimSpace(*req.URL)
+		if u, err := url.Parse(*req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid["url"] = "must be an absolute http or https URL"
+		}
+	}
+	if req.Secret != nil && (len(*req.Secret) < minWebhookSecretLength || len(*req.Secret) > maxWebhookSecretLength) {
+		invalid["secret"] = fmt.Sprintf("must be %d to %d characters", minWebhookSecretLength, maxWebhookSecretLength)
+	}
+	if req.Events != nil {
+		events := make([]string, 0, len(req.Events))
+		for _, event := range req.Events {
+			if !services.IsWebhookEvent(event) {
+				invalid["events"] = fmt.Sprintf("must be one or more of %s", strings.Join(services.WebhookEvents, ", "))
+				break
+			}
+			if !slices.Contains(events, event) {
+				events = append(events, event)
+			}
+		}
+		if len(events) == 0 {
+			invalid["events"] = fmt.Sprintf("must be one or more of %s", strings.Join(services.WebhookEvents, ", "))
+		}
+		req.Events = events
+	}
+	if len(invalid) > 0 {
+		respondError(c, http.StatusBadRequest, CodeValidation, withDetails("Invalid webhook", invalid))
+		return false
+	}
+	return true
+}
+
+// handleWebhookError writes the error response for err and reports whether the request may continue
+func handleWebhookError(c *gin.Context, err error) bool {
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		respondError(c, http.StatusNotFound, CodeNotFound, errors.New("Webhook not found"))
+		return false
+	}
+	respondServiceError(c, "Webhook request failed", err)
+	return false
+}
+
+// webhookID parses the :id parameter, writing a 400 when it is invalid
+func webhookID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, CodeValidation, errors.New("Invalid webhook ID"))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var webhookColumns = []string{"id", "url", "secret", "events", "active", "created_at", "updated_at"}
+
+func newWebhookRouter(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	webhooks := services.NewWebhookService(db)
+	handler := NewWebhookHandler(webhooks, services.NewWebhookDispatcher(webhooks))
+	router := gin.New()
+	router.GET("/webhooks", handler.ListWebhooks)
+	router.POST("/webhooks", handler.CreateWebhook)
+	router.PATCH("/webhooks/:id", handler.UpdateWebhook)
+	router.DELETE("/webhooks/:id", handler.DeleteWebhook)
+	router.POST("/webhooks/:id/test", handler.TestWebhook)
+	return router, mock
+}
+
+func serveWebhookRequest(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestWebhooks_CreateGeneratesSecretShownOnce(t *testing.T) {
+	router, mock := newWebhookRouter(t)
+	now := time.Now()
+
+	mock.ExpectQuery("INSERT INTO webhooks").
+		WithArgs("https://hooks.example.com/sync", sqlmock.AnyArg(), pq.Array([]string{services.WebhookEventBatchSyncCompleted})).
+		WillReturnRows(sqlmock.NewRows(webhookColumns).
+			AddRow(1, "https://hooks.example.com/sync", "generated-secret", "{sync.batch_completed}", true, now, now))
+
+	w := serveWebhookRequest(router, "POST", "/webhooks",
+		`{"url": " https://hooks.example.com/sync ", "events": ["sync.batch_completed", "sync.batch_completed"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data services.Webhook `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "generated-secret", created.Data.Secret)
+	assert.Equal(t, []string{services.WebhookEventBatchSyncCompleted}, created.Data.Events)
+
+	mock.ExpectQuery("SELECT (.+) FROM webhooks ORDER BY id").WillReturnRows(sqlmock.NewRows(webhookColumns).
+		AddRow(1, "https://hooks.example.com/sync", "generated-secret", "{sync.batch_completed}", true, now, now))
+	w = serveWebhookRequest(router, "GET", "/webhooks", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "generated-secret")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhooks_ValidatesFields(t *testing.T) {
+	router, mock := newWebhookRouter(t)
+
+	for body, field := range map[string]string{
+		`{"url": "ftp://hooks.example.com", "events": ["sync.batch_completed"]}`:                      "url",
+		`{"url": "/relative", "events": ["sync.batch_completed"]}`:                                    "url",
+		`{"url": "https://hooks.example.com", "events": []}`:                                          "events",
+		`{"url": "https://hooks.example.com"}`:                                                        "events",
+		`{"url": "https://hooks.example.com", "events": ["stock.updated"]}`:                           "events",
+		`{"url": "https://hooks.example.com", "events": ["sync.batch_completed"], "secret": "short"}`: "secret",
+	} {
+		w := serveWebhookRequest(router, "POST", "/webhooks", body)
+		require.Equal(t, http.StatusBadRequest, w.Code, body)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		details := responseError(t, response)["details"].(map[string]interface{})
+		assert.Contains(t, details, field, body)
+	}
+
+	// An update only checks the fields it sets
+	w := serveWebhookRequest(router, "PATCH", "/webhooks/1", `{"events": []}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhooks_UpdateKeepsFieldsLeftOut(t *testing.T) {
+	router, mock := newWebhookRouter(t)
+	now := time.Now()
+
+	mock.ExpectQuery("UPDATE webhooks").WithArgs(int64(1), nil, nil, nil, false).
+		WillReturnRows(sqlmock.NewRows(webhookColumns).
+			AddRow(1, "https://hooks.example.com/sync", "kept-secret", "{sync.batch_completed}", false, now, now))
+
+	w := serveWebhookRequest(router, "PATCH", "/webhooks/1", `{"active": false}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "kept-secret")
+	assert.Contains(t, w.Body.String(), `"active":false`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhooks_TestFire(t *testing.T) {
+	router, mock := newWebhookRouter(t)
+	var signature string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(services.WebhookSignatureHeader)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	mock.ExpectQuery("FROM webhooks WHERE id").WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows(webhookColumns).
+		AddRow(1, receiver.URL, "0123456789abcdef", "{sync.batch_completed}", true, time.Now(), time.Now()))
+
+	w := serveWebhookRequest(router, "POST", "/webhooks/1/test", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data services.WebhookDelivery `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Data.Delivered)
+	assert.Equal(t, http.StatusNoContent, response.Data.StatusCode)
+	assert.Equal(t, services.WebhookEventTest, response.Data.Event)
+	assert.True(t, strings.HasPrefix(signature, "sha256="))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhooks_NotFound(t *testing.T) {
+	router, mock := newWebhookRouter(t)
+
+	mock.ExpectQuery("FROM webhooks WHERE id").WithArgs(int64(2)).WillReturnRows(sqlmock.NewRows(webhookColumns))
+	assert.Equal(t, http.StatusNotFound, serveWebhookRequest(router, "POST", "/webhooks/2/test", "").Code)
+
+	mock.ExpectExec("DELETE FROM webhooks").WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.Equal(t, http.StatusNotFound, serveWebhookRequest(router, "DELETE", "/webhooks/2", "").Code)
+
+	assert.Equal(t, http.StatusBadRequest, serveWebhookRequest(router, "DELETE", "/webhooks/abc", "").Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,8 +1,9 @@
 package handlers
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
@@ -25,54 +26,71 @@ var upgrader = websocket.Upgrader{
 	WriteBufferSize:  1024,
 }
 
-// WebSocketHandler handles WebSocket connections for real-time data
+// WebSocketHandler handles WebSocket connections for real-time data, and
+// the Server-Sent Events stream that carries the same broadcasts
 type WebSocketHandler struct {
-	stockService *services.HybridStockService
+	stockService services.StockFeed
 	clients      map[*websocket.Conn]bool
 	clientsMutex sync.RWMutex
 	broadcast    chan []byte
+	logger       *slog.Logger
+	// streams and lastEventID are guarded by clientsMutex
+	streams           map[*priceStream]bool
+	lastEventID       uint64
+	keepaliveInterval time.Duration
 }
 
-// NewWebSocketHandler creates a new WebSocket handler
-func NewWebSocketHandler(stockService *services.HybridStockService) *WebSocketHandler {
+// NewWebSocketHandler creates a new WebSocket handler that pushes stock
+// updates to clients whenever a sync publishes them on the event bus. A nil
+// logger uses the default.
+func NewWebSocketHandler(stockService services.StockFeed, events *services.StockEventBus, logger *slog.Logger) *WebSocketHandler {
+	if logger == nil {
+		logger = slog.Default()
+	}
 	handler := &WebSocketHandler{
-		stockService: stockService,
-		clients:      make(map[*websocket.Conn]bool),
-		broadcast:    make(chan []byte),
+		stockService:      stockService,
+		clients:           make(map[*websocket.Conn]bool),
+		broadcast:         make(chan []byte),
+		logger:            logger,
+		streams:           make(map[*priceStream]bool),
+		keepaliveInterval: streamKeepaliveInterval,
 	}
 
 	// Start the broadcast goroutine
 	go handler.handleBroadcast()
 	
-	// Start the price update goroutine
-	go handler.broadcastPriceUpdates()
+	// Rebroadcast real data as syncs complete
+	if events != nil {
+		updates, _ := events.Subscribe()
+		go handler.broadcastStockUpdates(updates)
+	}
+	
+	// Simulated prices are only for demos; they never reflect the database
+	if os.Getenv("DEMO_MODE") == "true" {
+		logger.Warn("DEMO_MODE enabled: broadcasting simulated price updates")
+		go handler.broadcastPriceUpdates()
+	}
 
 	return handler
 }
 
-const maxConnections = 3 // Reasonable limit for a single user session
+const maxConnections = 3 // Reasonable limit for a single user session; WebSocket and SSE clients count alike
 
 // HandleWebSocket handles WebSocket upgrade and connection
 func (wsh *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 	// Check connection limit first
 	wsh.clientsMutex.RLock()
-	currentConnections := len(wsh.clients)
+	currentConnections := len(wsh.clients) + len(wsh.streams)
 	wsh.clientsMutex.RUnlock()
 	
 	if currentConnections >= maxConnections {
-		log.Printf("WebSocket connection limit reached (%d/%d). Rejecting new connection from %s", 
-			currentConnections, maxConnections, c.ClientIP())
-		c.JSON(http.StatusTooManyRequests, gin.H{
-			"error": "Too many connections",
-			"limit": maxConnections,
-			"current": currentConnections,
-		})
+		wsh.rejectConnection(c, "WebSocket", currentConnections)
 		return
 	}
 
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade failed: %v", err)
+		wsh.logger.Warn("WebSocket upgrade failed", "error", err)
 		return
 	}
 	defer conn.Close()
@@ -83,7 +101,7 @@ func (wsh *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 	clientCount := len(wsh.clients)
 	wsh.clientsMutex.Unlock()
 
-	log.Printf("WebSocket client connected. Total clients: %d/%d", clientCount, maxConnections)
+	wsh.logger.Info("WebSocket client connected", "clients", clientCount, "max_clients", maxConnections)
 
 	// Set connection timeouts
 	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -109,7 +127,7 @@ func (wsh *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 			case <-pingTicker.C:
 				conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					log.Printf("WebSocket ping error: %v", err)
+					wsh.logger.Debug("WebSocket ping error", "error", err)
 					return
 				}
 			}
@@ -120,9 +138,9 @@ func (wsh *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		_, _, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.CloseNormalClosure) {
-				log.Printf("WebSocket unexpected close error: %v", err)
+				wsh.logger.Warn("WebSocket unexpected close error", "error", err)
 			} else {
-				log.Printf("WebSocket connection closed: %v", err)
+				wsh.logger.Debug("WebSocket connection closed", "error", err)
 			}
 			break
 		}
@@ -136,29 +154,39 @@ func (wsh *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 	clientCount = len(wsh.clients)
 	wsh.clientsMutex.Unlock()
 
-	log.Printf("WebSocket client disconnected. Total clients: %d/%d", clientCount, maxConnections)
+	wsh.logger.Info("WebSocket client disconnected", "clients", clientCount, "max_clients", maxConnections)
+}
+
+// rejectConnection answers 429 to a client over the connection limit
+func (wsh *WebSocketHandler) rejectConnection(c *gin.Context, transport string, currentConnections int) {
+	wsh.logger.Warn(transport+" connection limit reached, rejecting new connection",
+		"clients", currentConnections, "max_clients", maxConnections, "client_ip", c.ClientIP())
+	respondError(c, http.StatusTooManyRequests, CodeRateLimited, withDetails("Too many connections", gin.H{
+		"limit":   maxConnections,
+		"current": currentConnections,
+	}))
 }
 
 // sendInitialData sends initial stock data to a newly connected client
 func (wsh *WebSocketHandler) sendInitialData(conn *websocket.Conn) {
-	stocks := wsh.stockService.GetAllStocks()
-	performance := wsh.stockService.GetPerformanceData()
-	overview := wsh.stockService.GetMarketOverview()
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := conn.WriteJSON(wsh.initialMessage()); err != nil {
+		wsh.logger.Warn("Error sending initial data", "error", err)
+	}
+}
 
-	initialData := map[string]interface{}{
+// initialMessage is the snapshot a new client starts from: every stock, the
+// performance rankings and the market overview
+func (wsh *WebSocketHandler) initialMessage() map[string]interface{} {
+	return map[string]interface{}{
 		"type": "initial",
 		"data": map[string]interface{}{
-			"stocks":      stocks,
-			"performance": performance,
-			"overview":    overview,
+			"stocks":      roundedStocks(wsh.stockService.GetAllStocks()),
+			"performance": wsh.stockService.GetPerformanceData(),
+			"overview":    wsh.stockService.GetMarketOverview(),
 			"timestamp":   time.Now().Unix(),
 		},
 	}
-
-	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-	if err := conn.WriteJSON(initialData); err != nil {
-		log.Printf("Error sending initial data: %v", err)
-	}
 }
 
 // handleBroadcast handles broadcasting messages to all clients
@@ -171,7 +199,7 @@ func (wsh *WebSocketHandler) handleBroadcast() {
 		for client := range wsh.clients {
 			client.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if err := client.WriteMessage(websocket.TextMessage, message); err != nil {
-				log.Printf("WebSocket write error: %v", err)
+				wsh.logger.Warn("WebSocket write error", "error", err)
 				client.Close()
 				clientsToRemove = append(clientsToRemove, client)
 			}
@@ -185,7 +213,29 @@ func (wsh *WebSocketHandler) handleBroadcast() {
 	}
 }
 
-// broadcastPriceUpdates simulates real-time price updates
+// broadcastStockUpdates reloads each updated stock from the database and
+// sends it to all clients as a price update
+func (wsh *WebSocketHandler) broadcastStockUpdates(updates <-chan services.StockUpdatedEvent) {
+	for event := range updates {
+		stock := wsh.stockService.GetStockBySymbol(event.Symbol)
+		if stock == nil {
+			wsh.logger.Warn("Skipping WebSocket update: stock could not be loaded", "symbol", event.Symbol)
+			continue
+		}
+
+		updateMessage := map[string]interface{}{
+			"type": "price_update",
+			"data": map[string]interface{}{
+				"stocks":    roundedStocks([]models.Stock{*stock}),
+				"timestamp": time.Now().Unix(),
+			},
+		}
+
+		wsh.broadcastToClients(updateMessage)
+	}
+}
+
+// broadcastPriceUpdates simulates real-time price updates when DEMO_MODE is enabled
 func (wsh *WebSocketHandler) broadcastPriceUpdates() {
 	ticker := time.NewTicker(5 * time.Second) // Update every 5 seconds
 	defer ticker.Stop()
@@ -204,6 +254,7 @@ func (wsh *WebSocketHandler) broadcastPriceUpdates() {
 				"type": "price_update",
 				"data": map[string]interface{}{
 					"stocks":    updatedStocks,
+					"simulated": true,
 					"timestamp": time.Now().Unix(),
 				},
 			}
@@ -233,14 +284,29 @@ func (wsh *WebSocketHandler) simulatepriceChanges(stocks []models.Stock) []model
 		updatedStocks[i].LastUpdated = time.Now()
 	}
 	
-	return updatedStocks
+	return roundedStocks(updatedStocks)
 }
 
-// broadcastToClients sends a message to all connected WebSocket clients
-func (wsh *WebSocketHandler) broadcastToClients(message interface{}) {
+// roundedStocks copies stocks with their prices rounded for a payload, so no
+// float noise reaches clients
+func roundedStocks(stocks []models.Stock) []models.Stock {
+	rounded := make([]models.Stock, len(stocks))
+	copy(rounded, stocks)
+	for i := range rounded {
+		services.RoundStockPrices(&rounded[i])
+	}
+	return rounded
+}
+
+// broadcastToClients sends a message to all connected WebSocket clients and,
+// as an event named by its type, to every SSE stream
+func (wsh *WebSocketHandler) broadcastToClients(message map[string]interface{}) {
 	wsh.clientsMutex.Lock()
 	defer wsh.clientsMutex.Unlock()
 	
+	wsh.lastEventID++
+	wsh.sendToStreams(message)
+
 	if len(wsh.clients) == 0 {
 		return // No clients to broadcast to
 	}
@@ -249,7 +315,7 @@ func (wsh *WebSocketHandler) broadcastToClients(message interface{}) {
 	for client := range wsh.clients {
 		client.SetWriteDeadline(time.Now().Add(10 * time.Second))
 		if err := client.WriteJSON(message); err != nil {
-			log.Printf("WebSocket broadcast error: %v", err)
+			wsh.logger.Warn("WebSocket broadcast error", "error", err)
 			client.Close()
 			clientsToRemove = append(clientsToRemove, client)
 		}
@@ -266,4 +332,11 @@ func (wsh *WebSocketHandler) GetConnectedClients() int {
 	wsh.clientsMutex.RLock()
 	defer wsh.clientsMutex.RUnlock()
 	return len(wsh.clients)
+}
+
+// GetStreamClients returns the number of connected SSE clients
+func (wsh *WebSocketHandler) GetStreamClients() int {
+	wsh.clientsMutex.RLock()
+	defer wsh.clientsMutex.RUnlock()
+	return len(wsh.streams)
 }
\ No newline at end of file
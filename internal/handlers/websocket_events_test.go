@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connectRawClient registers a WebSocket client with the handler without
+// sending the initial snapshot, so only broadcasts reach it
+func connectRawClient(t *testing.T, handler *WebSocketHandler) (*websocket.Conn, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		handler.clientsMutex.Lock()
+		handler.clients[conn] = true
+		handler.clientsMutex.Unlock()
+	}))
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return handler.GetConnectedClients() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	return conn, server
+}
+
+func TestWebSocketHandler_BroadcastsRealDataOnStockUpdate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("FROM stocks s").WithArgs("AAPL").WillReturnRows(sqlmock.NewRows([]string{
+		"id", "symbol", "company_name", "sector", "industry", "market_cap",
+		"price_range", "exchange", "is_active", "created_at", "updated_at",
+		"first_price_date", "last_price_date",
+	}).AddRow(1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(3000000000000),
+		"$100+", "NASDAQ", true, time.Now(), time.Now(), nil, nil))
+	mock.ExpectQuery("FROM daily_prices").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{
+		"close_price", "volume", "date", "daily_change", "change_percent", "change_period_days",
+	}).AddRow(185.64, int64(82488700), time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), -1.51, -0.81, 1))
+
+	events := services.NewStockEventBus()
+	stockService := services.NewHybridStockService(services.NewDatabaseStockService(db, nil))
+	handler := NewWebSocketHandler(stockService, events, nil)
+
+	conn, server := connectRawClient(t, handler)
+	defer server.Close()
+	defer conn.Close()
+
+	// Published by SchedulerService once a manual sync has saved the data
+	events.PublishStockUpdated("AAPL")
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var message map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&message))
+
+	assert.Equal(t, "price_update", message["type"])
+	data := message["data"].(map[string]interface{})
+	assert.Nil(t, data["simulated"])
+	stocks := data["stocks"].([]interface{})
+	require.Len(t, stocks, 1)
+	stock := stocks[0].(map[string]interface{})
+	assert.Equal(t, "AAPL", stock["symbol"])
+	assert.Equal(t, 185.64, stock["current_price"])
+	assert.Equal(t, true, stock["has_price_data"])
+
+	// No further broadcasts: nothing is simulated outside DEMO_MODE
+	conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	err = conn.ReadJSON(&message)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebSocketHandler_SkipsUnknownStockUpdate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("FROM stocks s").WithArgs("ZZZZ").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	events := services.NewStockEventBus()
+	stockService := services.NewHybridStockService(services.NewDatabaseStockService(db, nil))
+	handler := NewWebSocketHandler(stockService, events, nil)
+
+	conn, server := connectRawClient(t, handler)
+	defer server.Close()
+	defer conn.Close()
+
+	events.PublishStockUpdated("ZZZZ")
+
+	conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	var message map[string]interface{}
+	assert.Error(t, conn.ReadJSON(&message))
+}
+
+func TestWebSocketHandler_InitialDataFlagsUnsyncedStocks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("ORDER BY s.symbol").WillReturnRows(sqlmock.NewRows(stockListColumns).
+		AddRow(1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(3000000000000),
+			"$100+", "NASDAQ", true, now, now, nil, nil, 185.64, -1.51, -0.81, int64(82488700), now, 1).
+		AddRow(2, "TSLA", "Tesla, Inc.", "Consumer Cyclical", "Auto Manufacturers", nil,
+			"$200+", "NASDAQ", true, now, now, nil, nil, nil, nil, nil, nil, now, 0))
+	// Performance rankings and the overview are computed in the database
+	for i := 0; i < 3; i++ {
+		mock.ExpectQuery("LIMIT").WillReturnRows(sqlmock.NewRows(stockListColumns))
+	}
+	expectChangeOutliers(mock)
+	mock.ExpectQuery("FROM ranked").WillReturnRows(sqlmock.NewRows([]string{"total", "advancing", "declining", "unchanged", "avg"}).
+		AddRow(2, 0, 1, 1, -0.405))
+	expectChangeOutliers(mock)
+
+	stockService := services.NewHybridStockService(services.NewDatabaseStockService(db, nil))
+	handler := NewWebSocketHandler(stockService, services.NewStockEventBus(), nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", handler.HandleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var message struct {
+		Type string `json:"type"`
+		Data struct {
+			Stocks []struct {
+				Symbol       string  `json:"symbol"`
+				CurrentPrice float64 `json:"current_price"`
+				HasPriceData bool    `json:"has_price_data"`
+			} `json:"stocks"`
+		} `json:"data"`
+	}
+	require.NoError(t, conn.ReadJSON(&message))
+
+	assert.Equal(t, "initial", message.Type)
+	require.Len(t, message.Data.Stocks, 2)
+	assert.True(t, message.Data.Stocks[0].HasPriceData)
+	assert.Equal(t, "TSLA", message.Data.Stocks[1].Symbol)
+	assert.False(t, message.Data.Stocks[1].HasPriceData)
+	assert.Zero(t, message.Data.Stocks[1].CurrentPrice)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -17,7 +17,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-// Mock HybridStockService for testing
+// MockHybridStockService is a services.StockFeed for testing
 type MockHybridStockService struct {
 	mock.Mock
 }
@@ -27,25 +27,26 @@ func (m *MockHybridStockService) GetAllStocks() []models.Stock {
 	return args.Get(0).([]models.Stock)
 }
 
-func (m *MockHybridStockService) GetPerformanceData() interface{} {
+func (m *MockHybridStockService) GetPerformanceData() models.StockPerformance {
 	args := m.Called()
-	return args.Get(0)
+	return args.Get(0).(models.StockPerformance)
 }
 
-func (m *MockHybridStockService) GetMarketOverview() interface{} {
+func (m *MockHybridStockService) GetMarketOverview() models.MarketOverview {
 	args := m.Called()
-	return args.Get(0)
+	return args.Get(0).(models.MarketOverview)
 }
 
-func (m *MockHybridStockService) GetStockBySymbol(symbol string) (*models.Stock, error) {
+func (m *MockHybridStockService) GetStockBySymbol(symbol string) *models.Stock {
 	args := m.Called(symbol)
-	return args.Get(0).(*models.Stock), args.Error(1)
+	stock, _ := args.Get(0).(*models.Stock)
+	return stock
 }
 
 func TestNewWebSocketHandler(t *testing.T) {
 	mockService := &MockHybridStockService{}
 	
-	handler := NewWebSocketHandler(mockService)
+	handler := NewWebSocketHandler(mockService, nil, nil)
 	
 	assert.NotNil(t, handler)
 	assert.NotNil(t, handler.clients)
@@ -58,7 +59,7 @@ func TestNewWebSocketHandler(t *testing.T) {
 
 func TestWebSocketHandler_GetConnectedClients(t *testing.T) {
 	mockService := &MockHybridStockService{}
-	handler := NewWebSocketHandler(mockService)
+	handler := NewWebSocketHandler(mockService, nil, nil)
 	
 	// Initially no clients
 	count := handler.GetConnectedClients()
@@ -67,7 +68,7 @@ func TestWebSocketHandler_GetConnectedClients(t *testing.T) {
 
 func TestWebSocketHandler_HandleWebSocket_ConnectionLimit(t *testing.T) {
 	mockService := &MockHybridStockService{}
-	handler := NewWebSocketHandler(mockService)
+	handler := NewWebSocketHandler(mockService, nil, nil)
 	
 	// Fill up to the connection limit
 	for i := 0; i < maxConnections; i++ {
@@ -98,13 +99,15 @@ func TestWebSocketHandler_HandleWebSocket_ConnectionLimit(t *testing.T) {
 	var response map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	require.NoError(t, err)
-	assert.Equal(t, "Too many connections", response["error"])
-	assert.Equal(t, float64(maxConnections), response["limit"])
+	envelope := responseError(t, response)
+	assert.Equal(t, string(CodeRateLimited), envelope["code"])
+	assert.Equal(t, "Too many connections", envelope["message"])
+	assert.Equal(t, float64(maxConnections), envelope["details"].(map[string]interface{})["limit"])
 }
 
 func TestWebSocketHandler_SimulatePriceChanges(t *testing.T) {
 	mockService := &MockHybridStockService{}
-	handler := NewWebSocketHandler(mockService)
+	handler := NewWebSocketHandler(mockService, nil, nil)
 	
 	originalStocks := []models.Stock{
 		{
@@ -135,9 +138,22 @@ func TestWebSocketHandler_SimulatePriceChanges(t *testing.T) {
 	assert.NotEqual(t, originalStocks[1].CurrentPrice, updatedStocks[1].CurrentPrice)
 }
 
+func TestRoundedStocks_PayloadPrecision(t *testing.T) {
+	stocks := []models.Stock{{Symbol: "AAPL", CurrentPrice: 150.24999999, DailyChange: 0.30000000000000004, ChangePercent: 0.19968051118210864}}
+
+	body, err := json.Marshal(map[string]interface{}{"stocks": roundedStocks(stocks)})
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"current_price":150.25`)
+	assert.Contains(t, string(body), `"daily_change":0.3`)
+	assert.Contains(t, string(body), `"change_percent":0.2`)
+
+	// The caller's stocks are left as they were
+	assert.Equal(t, 150.24999999, stocks[0].CurrentPrice)
+}
+
 func TestWebSocketHandler_BroadcastToClients_NoClients(t *testing.T) {
 	mockService := &MockHybridStockService{}
-	handler := NewWebSocketHandler(mockService)
+	handler := NewWebSocketHandler(mockService, nil, nil)
 	
 	message := map[string]interface{}{
 		"type": "test",
@@ -202,12 +218,12 @@ func createTestWebSocketConnection(t *testing.T, handler *WebSocketHandler) (*we
 			CurrentPrice: 150.0,
 		},
 	})
-	mockService.On("GetPerformanceData").Return(map[string]interface{}{
-		"top_gainers": []interface{}{},
-		"top_losers":  []interface{}{},
+	mockService.On("GetPerformanceData").Return(models.StockPerformance{
+		TopGainers: []models.Stock{},
+		TopLosers:  []models.Stock{},
 	})
-	mockService.On("GetMarketOverview").Return(map[string]interface{}{
-		"total_stocks": 1,
+	mockService.On("GetMarketOverview").Return(models.MarketOverview{
+		TotalStocks: 1,
 	})
 	
 	handler.stockService = mockService
@@ -230,7 +246,7 @@ func createTestWebSocketConnection(t *testing.T, handler *WebSocketHandler) (*we
 }
 
 func TestWebSocketHandler_ConnectionLifecycle(t *testing.T) {
-	handler := NewWebSocketHandler(&MockHybridStockService{})
+	handler := NewWebSocketHandler(&MockHybridStockService{}, nil, nil)
 	
 	// Initial state
 	assert.Equal(t, 0, handler.GetConnectedClients())
@@ -254,7 +270,7 @@ func TestWebSocketHandler_ConnectionLifecycle(t *testing.T) {
 }
 
 func TestWebSocketHandler_MessageHandling(t *testing.T) {
-	handler := NewWebSocketHandler(&MockHybridStockService{})
+	handler := NewWebSocketHandler(&MockHybridStockService{}, nil, nil)
 	conn, server := createTestWebSocketConnection(t, handler)
 	defer server.Close()
 	defer conn.Close()
@@ -270,18 +286,16 @@ func TestWebSocketHandler_MessageHandling(t *testing.T) {
 	assert.Equal(t, "initial", initialMessage["type"])
 	assert.NotNil(t, initialMessage["data"])
 	
-	// Should receive price updates
+	// Without DEMO_MODE no simulated price updates follow
+	conn.SetReadDeadline(time.Now().Add(6 * time.Second))
 	var updateMessage map[string]interface{}
 	err = conn.ReadJSON(&updateMessage)
-	require.NoError(t, err)
-	
-	assert.Equal(t, "price_update", updateMessage["type"])
-	assert.NotNil(t, updateMessage["data"])
+	assert.Error(t, err)
 }
 
 // Benchmark tests for WebSocket performance
 func BenchmarkWebSocketHandler_SimulatePriceChanges(b *testing.B) {
-	handler := NewWebSocketHandler(&MockHybridStockService{})
+	handler := NewWebSocketHandler(&MockHybridStockService{}, nil, nil)
 	
 	// Create test stocks
 	stocks := make([]models.Stock, 100)
@@ -302,7 +316,7 @@ func BenchmarkWebSocketHandler_SimulatePriceChanges(b *testing.B) {
 }
 
 func BenchmarkWebSocketHandler_BroadcastToClients(b *testing.B) {
-	handler := NewWebSocketHandler(&MockHybridStockService{})
+	handler := NewWebSocketHandler(&MockHybridStockService{}, nil, nil)
 	
 	message := map[string]interface{}{
 		"type": "benchmark",
@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job statuses as stored in the jobs table
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// Job is a unit of background work persisted in the jobs table
+type Job struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	Error       string          `json:"error,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+// IsFinished reports whether the job has reached a terminal status
+func (j *Job) IsFinished() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCancelled
+}
+
+// DecodePayload unmarshals the job payload into dest
+func (j *Job) DecodePayload(dest interface{}) error {
+	if len(j.Payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(j.Payload, dest)
+}
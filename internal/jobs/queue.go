@@ -0,0 +1,329 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+var (
+	// ErrJobCancelled is the cause of a running job's context when Cancel stops it
+	ErrJobCancelled = errors.New("job cancelled")
+	// ErrJobNotActive is returned when cancelling a job this queue is not running or holding
+	ErrJobNotActive = errors.New("job is not queued or running in this process")
+)
+
+// Handler executes a job and returns a JSON-serializable result
+type Handler func(ctx context.Context, job *Job) (interface{}, error)
+
+// Options configures how jobs of a given type are executed
+type Options struct {
+	// Concurrency is the maximum number of jobs of this type running at once
+	Concurrency int
+	// MaxAttempts is how many times a failing job is tried before giving up
+	MaxAttempts int
+}
+
+type jobType struct {
+	handler Handler
+	options Options
+	running int
+}
+
+// Queue runs persisted jobs on a bounded worker pool
+type Queue struct {
+	store   Store
+	workers int
+
+	mu      sync.Mutex
+	types   map[string]*jobType
+	pending []*Job
+	running int
+	started bool
+	cancels map[int64]context.CancelCauseFunc
+
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewQueue creates a queue with the given number of workers
+func NewQueue(store Store, workers int) *Queue {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Queue{
+		store:   store,
+		workers: workers,
+		types:   make(map[string]*jobType),
+		cancels: make(map[int64]context.CancelCauseFunc),
+		wake:    make(chan struct{}, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Register associates a handler with a job type
+func (q *Queue) Register(name string, handler Handler, options Options) {
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 1
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.types[name] = &jobType{handler: handler, options: options}
+}
+
+// Start resumes unfinished jobs from the store and begins dispatching
+func (q *Queue) Start() error {
+	q.mu.Lock()
+	if q.started {
+		q.mu.Unlock()
+		return nil
+	}
+	q.started = true
+	q.mu.Unlock()
+
+	unfinished, err := q.store.ListUnfinished()
+	if err != nil {
+		return fmt.Errorf("failed to load unfinished jobs: %w", err)
+	}
+
+	resumed := 0
+	q.mu.Lock()
+	queued := make(map[int64]bool, len(q.pending))
+	for _, job := range q.pending {
+		queued[job.ID] = true
+	}
+	for _, job := range unfinished {
+		if queued[job.ID] {
+			continue
+		}
+		if _, ok := q.types[job.Type]; !ok {
+			slog.Warn("Skipping job: no handler registered for its type", "job_id", job.ID, "job_type", job.Type)
+			continue
+		}
+		q.pending = append(q.pending, job)
+		resumed++
+	}
+	q.mu.Unlock()
+
+	if resumed > 0 {
+		slog.Info("Resumed unfinished background jobs", "count", resumed)
+	}
+
+	q.wg.Add(1)
+	go q.dispatchLoop()
+	q.signal()
+
+	return nil
+}
+
+// Stop cancels running jobs and waits for workers to exit
+func (q *Queue) Stop() {
+	q.cancel()
+	q.wg.Wait()
+}
+
+// Enqueue persists a new job and schedules it for execution
+func (q *Queue) Enqueue(name string, payload interface{}) (*Job, error) {
+	q.mu.Lock()
+	jt, ok := q.types[name]
+	q.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown job type: %s", name)
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %w", err)
+	}
+
+	job := &Job{
+		Type:        name,
+		Payload:     payloadJSON,
+		MaxAttempts: jt.options.MaxAttempts,
+	}
+	if err := q.store.Create(job); err != nil {
+		return nil, err
+	}
+
+	// Hand the caller a copy; the queued job is mutated by its worker
+	snapshot := *job
+
+	q.mu.Lock()
+	q.pending = append(q.pending, job)
+	q.mu.Unlock()
+	q.signal()
+
+	return &snapshot, nil
+}
+
+// Cancel stops a job. A queued job is dropped and marked cancelled at once. A
+// running job's context is cancelled with ErrJobCancelled as its cause and the
+// job is marked cancelled, with whatever result it returns, once its handler
+// winds down; Cancel reports true in that case. Only jobs held by this queue
+// can be cancelled; others return ErrJobNotActive.
+func (q *Queue) Cancel(id int64) (bool, error) {
+	q.mu.Lock()
+	if cancel, ok := q.cancels[id]; ok {
+		q.mu.Unlock()
+		cancel(ErrJobCancelled)
+		return true, nil
+	}
+
+	for i, job := range q.pending {
+		if job.ID != id {
+			continue
+		}
+		q.pending = append(q.pending[:i], q.pending[i+1:]...)
+		q.mu.Unlock()
+
+		q.finish(job, StatusCancelled, nil, ErrJobCancelled.Error())
+		return false, nil
+	}
+	q.mu.Unlock()
+
+	return false, ErrJobNotActive
+}
+
+// Get returns the current state of a job
+func (q *Queue) Get(id int64) (*Job, error) {
+	return q.store.Get(id)
+}
+
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatchLoop starts pending jobs whenever a worker and a type slot are free
+func (q *Queue) dispatchLoop() {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-q.wake:
+		}
+
+		q.mu.Lock()
+		remaining := q.pending[:0]
+		for _, job := range q.pending {
+			jt := q.types[job.Type]
+			if q.running >= q.workers || jt.running >= jt.options.Concurrency {
+				remaining = append(remaining, job)
+				continue
+			}
+
+			// Registered before the worker starts so Cancel always finds a dispatched job
+			ctx, cancel := context.WithCancelCause(q.ctx)
+			q.cancels[job.ID] = cancel
+
+			q.running++
+			jt.running++
+			q.wg.Add(1)
+			go q.run(ctx, job, jt)
+		}
+		q.pending = remaining
+		q.mu.Unlock()
+	}
+}
+
+// run executes a single job attempt and records the outcome
+func (q *Queue) run(ctx context.Context, job *Job, jt *jobType) {
+	defer q.wg.Done()
+	defer func() {
+		q.mu.Lock()
+		q.running--
+		jt.running--
+		cancel := q.cancels[job.ID]
+		delete(q.cancels, job.ID)
+		q.mu.Unlock()
+		cancel(nil)
+		q.signal()
+	}()
+
+	if err := q.store.MarkRunning(job.ID); err != nil {
+		slog.Error("Failed to mark job as running", "job_id", job.ID, "error", err)
+	}
+	job.Attempts++
+	job.Status = StatusRunning
+
+	result, err := q.execute(ctx, job, jt.handler)
+
+	if q.ctx.Err() != nil {
+		// Shutting down: leave the job as running so it is resumed on next start
+		slog.Info("Job interrupted by shutdown", "job_id", job.ID, "job_type", job.Type)
+		return
+	}
+
+	if errors.Is(context.Cause(ctx), ErrJobCancelled) {
+		slog.Info("Job cancelled", "job_id", job.ID, "job_type", job.Type)
+		q.finish(job, StatusCancelled, result, ErrJobCancelled.Error())
+		return
+	}
+
+	if err != nil {
+		if job.Attempts < jt.options.MaxAttempts {
+			slog.Warn("Job attempt failed, retrying", "job_id", job.ID, "job_type", job.Type,
+				"attempt", job.Attempts, "max_attempts", jt.options.MaxAttempts, "error", err)
+			if storeErr := q.store.Requeue(job.ID, err.Error()); storeErr != nil {
+				slog.Error("Failed to requeue job", "job_id", job.ID, "error", storeErr)
+			}
+			job.Status = StatusQueued
+			q.mu.Lock()
+			q.pending = append(q.pending, job)
+			q.mu.Unlock()
+			return
+		}
+
+		slog.Error("Job failed", "job_id", job.ID, "job_type", job.Type, "error", err)
+		q.finish(job, StatusFailed, result, err.Error())
+		return
+	}
+
+	q.finish(job, StatusSucceeded, result, "")
+}
+
+// execute calls the handler, converting panics into errors
+func (q *Queue) execute(ctx context.Context, job *Job, handler Handler) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+func (q *Queue) finish(job *Job, status string, result interface{}, errMsg string) {
+	var resultJSON []byte
+	if result != nil {
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			slog.Error("Failed to encode job result", "job_id", job.ID, "error", err)
+		} else {
+			resultJSON = encoded
+		}
+	}
+
+	if err := q.store.MarkFinished(job.ID, status, resultJSON, errMsg); err != nil {
+		slog.Error("Failed to record job completion", "job_id", job.ID, "error", err)
+	}
+	job.Status = status
+}
@@ -0,0 +1,275 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore is an in-memory Store used to exercise the queue without a database
+type memStore struct {
+	mu     sync.Mutex
+	nextID int64
+	jobs   map[int64]*Job
+}
+
+func newMemStore() *memStore {
+	return &memStore{jobs: make(map[int64]*Job)}
+}
+
+func (s *memStore) Create(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	job.ID = s.nextID
+	job.Status = StatusQueued
+	job.CreatedAt = time.Now()
+	stored := *job
+	s.jobs[job.ID] = &stored
+	return nil
+}
+
+func (s *memStore) Get(id int64) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (s *memStore) MarkRunning(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.jobs[id].Status = StatusRunning
+	s.jobs[id].Attempts++
+	s.jobs[id].StartedAt = &now
+	return nil
+}
+
+func (s *memStore) MarkFinished(id int64, status string, result []byte, errMsg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.jobs[id].Status = status
+	s.jobs[id].Result = result
+	s.jobs[id].Error = errMsg
+	s.jobs[id].FinishedAt = &now
+	return nil
+}
+
+func (s *memStore) Requeue(id int64, errMsg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[id].Status = StatusQueued
+	s.jobs[id].Error = errMsg
+	return nil
+}
+
+func (s *memStore) ListUnfinished() ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var unfinished []*Job
+	for id := int64(1); id <= s.nextID; id++ {
+		if job, ok := s.jobs[id]; ok && !job.IsFinished() {
+			copied := *job
+			unfinished = append(unfinished, &copied)
+		}
+	}
+	return unfinished, nil
+}
+
+func waitForStatus(t *testing.T, q *Queue, id int64, status string) *Job {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := q.Get(id)
+		require.NoError(t, err)
+		if job.Status == status {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %d did not reach status %s", id, status)
+	return nil
+}
+
+func TestQueue_RunsJobAndStoresResult(t *testing.T) {
+	q := NewQueue(newMemStore(), 2)
+	q.Register("echo", func(ctx context.Context, job *Job) (interface{}, error) {
+		var payload map[string]string
+		if err := job.DecodePayload(&payload); err != nil {
+			return nil, err
+		}
+		return map[string]string{"echo": payload["symbol"]}, nil
+	}, Options{})
+	require.NoError(t, q.Start())
+	defer q.Stop()
+
+	job, err := q.Enqueue("echo", map[string]string{"symbol": "AAPL"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusQueued, job.Status)
+
+	done := waitForStatus(t, q, job.ID, StatusSucceeded)
+	assert.Equal(t, 1, done.Attempts)
+	assert.NotNil(t, done.FinishedAt)
+
+	var result map[string]string
+	require.NoError(t, json.Unmarshal(done.Result, &result))
+	assert.Equal(t, "AAPL", result["echo"])
+}
+
+func TestQueue_UnknownType(t *testing.T) {
+	q := NewQueue(newMemStore(), 1)
+
+	_, err := q.Enqueue("missing", nil)
+	assert.Error(t, err)
+}
+
+func TestQueue_RetriesUntilMaxAttempts(t *testing.T) {
+	q := NewQueue(newMemStore(), 1)
+	q.Register("flaky", func(ctx context.Context, job *Job) (interface{}, error) {
+		return nil, errors.New("upstream unavailable")
+	}, Options{MaxAttempts: 3})
+	require.NoError(t, q.Start())
+	defer q.Stop()
+
+	job, err := q.Enqueue("flaky", nil)
+	require.NoError(t, err)
+
+	done := waitForStatus(t, q, job.ID, StatusFailed)
+	assert.Equal(t, 3, done.Attempts)
+	assert.Equal(t, "upstream unavailable", done.Error)
+}
+
+func TestQueue_RecoversFromPanic(t *testing.T) {
+	q := NewQueue(newMemStore(), 1)
+	q.Register("explode", func(ctx context.Context, job *Job) (interface{}, error) {
+		panic("boom")
+	}, Options{})
+	require.NoError(t, q.Start())
+	defer q.Stop()
+
+	job, err := q.Enqueue("explode", nil)
+	require.NoError(t, err)
+
+	done := waitForStatus(t, q, job.ID, StatusFailed)
+	assert.Contains(t, done.Error, "boom")
+}
+
+func TestQueue_RespectsTypeConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	current, peak := 0, 0
+
+	q := NewQueue(newMemStore(), 4)
+	q.Register("serial", func(ctx context.Context, job *Job) (interface{}, error) {
+		mu.Lock()
+		current++
+		if current > peak {
+			peak = current
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		current--
+		mu.Unlock()
+		return nil, nil
+	}, Options{Concurrency: 1})
+	require.NoError(t, q.Start())
+	defer q.Stop()
+
+	var ids []int64
+	for i := 0; i < 4; i++ {
+		job, err := q.Enqueue("serial", nil)
+		require.NoError(t, err)
+		ids = append(ids, job.ID)
+	}
+	for _, id := range ids {
+		waitForStatus(t, q, id, StatusSucceeded)
+	}
+
+	assert.Equal(t, 1, peak)
+}
+
+func TestQueue_ResumesUnfinishedJobsOnStart(t *testing.T) {
+	store := newMemStore()
+	interrupted := &Job{Type: "resume", Payload: json.RawMessage(`{}`), MaxAttempts: 1}
+	require.NoError(t, store.Create(interrupted))
+	require.NoError(t, store.MarkRunning(interrupted.ID))
+
+	q := NewQueue(store, 1)
+	q.Register("resume", func(ctx context.Context, job *Job) (interface{}, error) {
+		return "done", nil
+	}, Options{})
+	require.NoError(t, q.Start())
+	defer q.Stop()
+
+	waitForStatus(t, q, interrupted.ID, StatusSucceeded)
+}
+
+func TestQueue_CancelRunningJob(t *testing.T) {
+	started := make(chan struct{})
+	q := NewQueue(newMemStore(), 1)
+	q.Register("long", func(ctx context.Context, job *Job) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return map[string]int{"done": 1}, ctx.Err()
+	}, Options{MaxAttempts: 3})
+	require.NoError(t, q.Start())
+	defer q.Stop()
+
+	job, err := q.Enqueue("long", nil)
+	require.NoError(t, err)
+	<-started
+
+	running, err := q.Cancel(job.ID)
+	require.NoError(t, err)
+	assert.True(t, running)
+
+	// Cancelled jobs are not retried and keep the result of the work they did
+	done := waitForStatus(t, q, job.ID, StatusCancelled)
+	assert.Equal(t, 1, done.Attempts)
+	assert.JSONEq(t, `{"done": 1}`, string(done.Result))
+}
+
+func TestQueue_CancelQueuedJob(t *testing.T) {
+	q := NewQueue(newMemStore(), 1)
+	q.Register("never", func(ctx context.Context, job *Job) (interface{}, error) {
+		t.Error("cancelled job must not run")
+		return nil, nil
+	}, Options{})
+
+	job, err := q.Enqueue("never", nil)
+	require.NoError(t, err)
+
+	running, err := q.Cancel(job.ID)
+	require.NoError(t, err)
+	assert.False(t, running)
+
+	require.NoError(t, q.Start())
+	defer q.Stop()
+	stored, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCancelled, stored.Status)
+
+	_, err = q.Cancel(job.ID)
+	assert.ErrorIs(t, err, ErrJobNotActive)
+}
@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ErrJobNotFound is returned when a job ID does not exist
+var ErrJobNotFound = fmt.Errorf("job not found")
+
+// Store persists job records
+type Store interface {
+	Create(job *Job) error
+	Get(id int64) (*Job, error)
+	MarkRunning(id int64) error
+	MarkFinished(id int64, status string, result []byte, errMsg string) error
+	Requeue(id int64, errMsg string) error
+	ListUnfinished() ([]*Job, error)
+}
+
+// PostgresStore stores jobs in the jobs table
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a new Postgres-backed job store
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+const jobColumns = `id, job_type, payload, status, attempts, max_attempts,
+	       COALESCE(error_message, ''), result, created_at, started_at, finished_at`
+
+// Create inserts a new queued job and fills in its ID and creation time
+func (s *PostgresStore) Create(job *Job) error {
+	query := `
+		INSERT INTO jobs (job_type, payload, status, max_attempts)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	payload := []byte(job.Payload)
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+
+	err := s.db.QueryRow(query, job.Type, payload, StatusQueued, job.MaxAttempts).
+		Scan(&job.ID, &job.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+
+	job.Status = StatusQueued
+	return nil
+}
+
+// Get loads a job by ID
+func (s *PostgresStore) Get(id int64) (*Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE id = $1`
+
+	job, err := scanJob(s.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job %d: %w", id, err)
+	}
+	return job, nil
+}
+
+// MarkRunning flags a job as running and counts the attempt
+func (s *PostgresStore) MarkRunning(id int64) error {
+	query := `
+		UPDATE jobs
+		SET status = $1, attempts = attempts + 1, started_at = CURRENT_TIMESTAMP,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+	_, err := s.db.Exec(query, StatusRunning, id)
+	return err
+}
+
+// MarkFinished records the terminal status, result and error of a job
+func (s *PostgresStore) MarkFinished(id int64, status string, result []byte, errMsg string) error {
+	query := `
+		UPDATE jobs
+		SET status = $1, result = $2, error_message = NULLIF($3, ''),
+		    finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+	`
+
+	var resultArg interface{}
+	if len(result) > 0 {
+		resultArg = result
+	}
+
+	_, err := s.db.Exec(query, status, resultArg, errMsg, id)
+	return err
+}
+
+// Requeue puts a failed attempt back in the queue for another try
+func (s *PostgresStore) Requeue(id int64, errMsg string) error {
+	query := `
+		UPDATE jobs
+		SET status = $1, error_message = NULLIF($2, ''), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+	`
+	_, err := s.db.Exec(query, StatusQueued, errMsg, id)
+	return err
+}
+
+// ListUnfinished returns queued and interrupted jobs in creation order
+func (s *PostgresStore) ListUnfinished() ([]*Job, error) {
+	query := `SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE status IN ('queued', 'running')
+		ORDER BY id`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unfinished jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row rowScanner) (*Job, error) {
+	var job Job
+	var payload, result []byte
+	var startedAt, finishedAt sql.NullTime
+
+	err := row.Scan(&job.ID, &job.Type, &payload, &job.Status, &job.Attempts,
+		&job.MaxAttempts, &job.Error, &result, &job.CreatedAt, &startedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Payload = payload
+	job.Result = result
+	if startedAt.Valid {
+		t := startedAt.Time
+		job.StartedAt = &t
+	}
+	if finishedAt.Valid {
+		t := finishedAt.Time
+		job.FinishedAt = &t
+	}
+
+	return &job, nil
+}
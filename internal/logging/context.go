@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDContextKey is the gin context key holding the request ID
+const requestIDContextKey = "request_id"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying a request ID. Records logged
+// with it through a logger from New include the ID as request_id.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or "" when there is none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// RequestID returns the ID AssignRequestID gave to the request, or ""
+// when the middleware is not installed
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDContextKey)
+}
+
+// contextHandler adds the request ID from a record's context to the record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
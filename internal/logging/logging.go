@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// New returns a logger writing to w. format is "json" or "text" (the default
+// when empty); level is debug, info (the default when empty), warn or error.
+// Records logged with a context from WithRequestID carry its request ID.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var slogLevel slog.Level
+	if level != "" {
+		if err := slogLevel.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q: must be debug, info, warn or error", level)
+		}
+	}
+
+	options := &slog.HandlerOptions{Level: slogLevel}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(contextHandler{slog.NewTextHandler(w, options)}), nil
+	case "json":
+		return slog.New(contextHandler{slog.NewJSONHandler(w, options)}), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q: must be json or text", format)
+	}
+}
+
+// FromEnv builds the process logger from LOG_FORMAT and LOG_LEVEL and makes it
+// the default, so the standard log package and slog's top-level functions
+// write through it too. Invalid settings fall back to text at info, with a warning.
+func FromEnv() *slog.Logger {
+	logger, err := New(os.Stderr, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		logger, _ = New(os.Stderr, "text", "info")
+		logger.Warn("Ignoring invalid logging configuration", "error", err)
+	}
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Fatal logs msg and its attributes at error level and exits with status 1,
+// for commands that cannot carry on; deferred calls do not run
+func Fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newLoggedRouter serves /ok, /fail and /logs through AssignRequestID and
+// RequestLogger writing JSON to the returned buffer. /logs logs a record of its own.
+func newLoggedRouter(t *testing.T) (*gin.Engine, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "debug")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(AssignRequestID(), RequestLogger(logger))
+	router.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/fail", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+	router.GET("/logs", func(c *gin.Context) {
+		logger.InfoContext(c.Request.Context(), "Handling request", "symbol", "AAPL")
+		c.Status(http.StatusOK)
+	})
+	return router, &buf
+}
+
+// records decodes each JSON line in buf
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		out = append(out, record)
+	}
+	return out
+}
+
+func TestRequestLogger_OneRecordPerRequest(t *testing.T) {
+	router, buf := newLoggedRouter(t)
+
+	req := httptest.NewRequest("GET", "/ok?verbose=1", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	logged := records(t, buf)
+	require.Len(t, logged, 1)
+	record := logged[0]
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "HTTP request", record["msg"])
+	assert.Equal(t, "GET", record["method"])
+	assert.Equal(t, "/ok", record["path"])
+	assert.Equal(t, float64(http.StatusOK), record["status"])
+	assert.Equal(t, "req-123", record["request_id"])
+	assert.Contains(t, record, "duration")
+}
+
+func TestRequestLogger_GeneratesRequestIDsAndLevelsByStatus(t *testing.T) {
+	router, buf := newLoggedRouter(t)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+
+	logged := records(t, buf)
+	require.Len(t, logged, 2)
+	assert.Equal(t, "ERROR", logged[0]["level"])
+	assert.Equal(t, "WARN", logged[1]["level"])
+	assert.NotEmpty(t, logged[0]["request_id"])
+	assert.NotEqual(t, logged[0]["request_id"], logged[1]["request_id"])
+}
+
+func TestAssignRequestID_EchoesIDInHeaderAndLogs(t *testing.T) {
+	router, buf := newLoggedRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/logs", nil))
+
+	requestID := w.Header().Get(RequestIDHeader)
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, requestID)
+
+	// The handler's own record and the request record both carry it
+	logged := records(t, buf)
+	require.Len(t, logged, 2)
+	assert.Equal(t, "Handling request", logged[0]["msg"])
+	assert.Equal(t, requestID, logged[0]["request_id"])
+	assert.Equal(t, "HTTP request", logged[1]["msg"])
+	assert.Equal(t, requestID, logged[1]["request_id"])
+}
+
+func TestAssignRequestID_ReplacesUnusableIDs(t *testing.T) {
+	router, _ := newLoggedRouter(t)
+
+	for _, incoming := range []string{"has space", `quote"d`, strings.Repeat("a", maxRequestIDLength+1)} {
+		req := httptest.NewRequest("GET", "/ok", nil)
+		req.Header.Set(RequestIDHeader, incoming)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.NotEqual(t, incoming, w.Header().Get(RequestIDHeader))
+		assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "", "warn")
+	require.NoError(t, err)
+
+	logger.Info("hidden")
+	logger.Warn("shown", "symbol", "AAPL")
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "level=WARN msg=shown symbol=AAPL")
+
+	_, err = New(&buf, "xml", "")
+	assert.Error(t, err)
+	_, err = New(&buf, "json", "loud")
+	assert.Error(t, err)
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// APICallInitiatorStats summarizes external API usage attributed to one initiator on one day
+type APICallInitiatorStats struct {
+	ServiceName     string    `json:"service_name"`
+	InitiatedBy     string    `json:"initiated_by"`
+	TotalCalls      int       `json:"total_calls"`
+	SuccessfulCalls int       `json:"successful_calls"`
+	FailedCalls     int       `json:"failed_calls"`
+	LastCallAt      time.Time `json:"last_call_at"`
+	CallDate        time.Time `json:"call_date"`
+}
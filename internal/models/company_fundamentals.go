@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// CompanyFundamentals is a stock's latest company overview. Metrics the source
+// reports as "None" or leaves empty are nil rather than zero.
+type CompanyFundamentals struct {
+	Symbol            string    `json:"symbol"`
+	PERatio           *float64  `json:"pe_ratio"`
+	EPS               *float64  `json:"eps"`
+	DividendYield     *float64  `json:"dividend_yield"`
+	Beta              *float64  `json:"beta"`
+	SharesOutstanding *int64    `json:"shares_outstanding"`
+	Description       string    `json:"description"`
+	FetchedAt         time.Time `json:"fetched_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// IntradayPrice is one intraday bar; Timestamp is the bar start in exchange local time
+type IntradayPrice struct {
+	Timestamp time.Time `json:"timestamp"`
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Close     float64   `json:"close"`
+	Volume    int64     `json:"volume"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// Transaction sides
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+)
+
+// Portfolio is a named set of stock transactions
+type Portfolio struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Transaction is one buy or sell of a stock in a portfolio
+type Transaction struct {
+	ID         int64     `json:"id"`
+	Symbol     string    `json:"symbol"`
+	Side       string    `json:"side"`
+	Quantity   float64   `json:"quantity"`
+	Price      float64   `json:"price"`
+	ExecutedAt time.Time `json:"executed_at"`
+}
+
+// Position is the quantity of a stock a portfolio holds, valued at the latest
+// stored close. The valuation fields are null while the stock has no price.
+type Position struct {
+	Symbol   string  `json:"symbol"`
+	Quantity float64 `json:"quantity"`
+	// AverageCost is the cost per share of the lots still held, bought first in, first out
+	AverageCost          float64    `json:"average_cost"`
+	CostBasis            float64    `json:"cost_basis"`
+	LatestPrice          *float64   `json:"latest_price"`
+	PriceDate            *time.Time `json:"price_date"`
+	MarketValue          *float64   `json:"market_value"`
+	UnrealizedPnL        *float64   `json:"unrealized_pnl"`
+	UnrealizedPnLPercent *float64   `json:"unrealized_pnl_percent"`
+	// RealizedPnL is the gain or loss on the shares of this stock already sold
+	RealizedPnL float64 `json:"realized_pnl"`
+	// Stale marks a position without a price, or whose latest close is the
+	// stale threshold or more completed trading days old
+	Stale bool `json:"stale"`
+}
+
+// PortfolioTotals sums the positions. Market value and unrealized P&L only
+// cover positions with a price; realized P&L includes stocks sold out of.
+type PortfolioTotals struct {
+	CostBasis      float64 `json:"cost_basis"`
+	MarketValue    float64 `json:"market_value"`
+	UnrealizedPnL  float64 `json:"unrealized_pnl"`
+	RealizedPnL    float64 `json:"realized_pnl"`
+	StalePositions int     `json:"stale_positions"`
+}
+
+// PortfolioSummary is a portfolio's open positions, by symbol, and their totals
+type PortfolioSummary struct {
+	Portfolio
+	Positions []Position      `json:"positions"`
+	Totals    PortfolioTotals `json:"totals"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// PriceCoverage is the span of daily prices stored for a stock. Both dates are
+// nil and HasPriceData is false until the stock's first price is saved.
+type PriceCoverage struct {
+	FirstPriceDate *time.Time `json:"first_price_date"`
+	LastPriceDate  *time.Time `json:"last_price_date"`
+	HasPriceData   bool       `json:"has_price_data"`
+}
+
+// HistoryDays is the number of calendar days between the oldest and newest stored price
+func (c PriceCoverage) HistoryDays() int {
+	if c.FirstPriceDate == nil || c.LastPriceDate == nil {
+		return 0
+	}
+	return int(c.LastPriceDate.Sub(*c.FirstPriceDate).Hours() / 24)
+}
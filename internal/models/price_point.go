@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// PricePoint is one day's closing price, raw or split-adjusted, and volume
+type PricePoint struct {
+	Date   time.Time `json:"date"`
+	Price  float64   `json:"price"`
+	Volume int64     `json:"volume"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxSymbolLength is the longest ticker symbol accepted
+const MaxSymbolLength = 10
+
+// ErrMalformedSymbol is returned for a symbol that cannot be a ticker
+var ErrMalformedSymbol = errors.New("invalid stock symbol")
+
+// NormalizeSymbol returns the stored form of a ticker symbol: trimmed and
+// uppercased, with class shares written with a dot (BRK-B becomes BRK.B).
+// Symbols that are empty, longer than MaxSymbolLength or contain anything but
+// letters, digits and dots are an ErrMalformedSymbol.
+func NormalizeSymbol(raw string) (string, error) {
+	symbol := strings.ReplaceAll(strings.ToUpper(strings.TrimSpace(raw)), "-", ".")
+	if symbol == "" {
+		return "", fmt.Errorf("%w: symbol is empty", ErrMalformedSymbol)
+	}
+	if len(symbol) > MaxSymbolLength {
+		return "", fmt.Errorf("%w: %q is longer than %d characters", ErrMalformedSymbol, raw, MaxSymbolLength)
+	}
+	for _, r := range symbol {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '.' {
+			return "", fmt.Errorf("%w: %q contains %q", ErrMalformedSymbol, raw, r)
+		}
+	}
+	return symbol, nil
+}
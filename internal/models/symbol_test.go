@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeSymbol(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{raw: "AAPL", want: "AAPL"},
+		{raw: "aapl", want: "AAPL"},
+		{raw: "  msft ", want: "MSFT"},
+		{raw: "BRK-B", want: "BRK.B"},
+		{raw: "brk.b", want: "BRK.B"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeSymbol(tt.raw)
+		require.NoError(t, err, tt.raw)
+		assert.Equal(t, tt.want, got, tt.raw)
+	}
+}
+
+func TestNormalizeSymbol_RejectsMalformed(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"   ",
+		"ABCDEFGHIJK",
+		"AAPL'; DROP TABLE stocks;--",
+		"AAPL OR 1=1",
+		"../etc",
+		"AAPL%00",
+	} {
+		_, err := NormalizeSymbol(raw)
+		assert.ErrorIs(t, err, ErrMalformedSymbol, raw)
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Watchlist is a named list of stocks with their latest prices
+type Watchlist struct {
+	ID        int64           `json:"id"`
+	Name      string          `json:"name"`
+	Items     []WatchlistItem `json:"items"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// WatchlistItem is a stock on a watchlist; prices are zero until the stock has daily prices
+type WatchlistItem struct {
+	Symbol        string    `json:"symbol"`
+	CompanyName   string    `json:"company_name"`
+	CurrentPrice  float64   `json:"current_price"`
+	ChangePercent float64   `json:"change_percent"`
+	AddedAt       time.Time `json:"added_at"`
+}
@@ -0,0 +1,147 @@
+// Package ratelimit limits how often each client may call the API
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"stock-intelligence-backend/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultPerMinute is the requests a client may make each minute
+const DefaultPerMinute = 60
+
+const (
+	// LimitHeader is the requests a client may make each minute
+	LimitHeader = "X-RateLimit-Limit"
+	// RemainingHeader is the requests a client may still make right away
+	RemainingHeader = "X-RateLimit-Remaining"
+)
+
+// Store holds the token buckets of clients. TakeToken refills the bucket of
+// client for the time up to now, then takes a token if a whole one is left;
+// it reports whether it did and the tokens left.
+type Store interface {
+	TakeToken(ctx context.Context, client string, capacity int, perSecond float64, now time.Time) (bool, float64, error)
+}
+
+// Limiter gives each client IP a bucket of perMinute tokens that refills
+// steadily over a minute, so a client may burst up to the limit and then
+// continues at the limit's rate
+type Limiter struct {
+	store     Store
+	fallback  *MemoryStore
+	perMinute int
+	logger    *slog.Logger
+	now       func() time.Time
+}
+
+// NewLimiter creates a limiter keeping its buckets in store, such as the
+// Redis cache so every server shares them. Should the store fail, buckets
+// are kept in memory until it is back.
+func NewLimiter(store Store, perMinute int, logger *slog.Logger) *Limiter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Limiter{
+		store:     store,
+		fallback:  NewMemoryStore(),
+		perMinute: perMinute,
+		logger:    logger,
+		now:       time.Now,
+	}
+}
+
+// perSecond is the rate buckets refill at
+func (l *Limiter) perSecond() float64 {
+	return float64(l.perMinute) / 60
+}
+
+// Middleware refuses requests beyond the limit with 429 and a Retry-After
+// header. Every response carries the limit and the requests remaining.
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := l.now()
+		ctx := c.Request.Context()
+		taken, tokens, err := l.store.TakeToken(ctx, c.ClientIP(), l.perMinute, l.perSecond(), now)
+		if err != nil {
+			l.logger.WarnContext(ctx, "Rate limit store failed, limiting in memory", "error", err)
+			taken, tokens, _ = l.fallback.TakeToken(ctx, c.ClientIP(), l.perMinute, l.perSecond(), now)
+		}
+
+		c.Header(LimitHeader, strconv.Itoa(l.perMinute))
+		c.Header(RemainingHeader, strconv.Itoa(int(math.Floor(tokens))))
+		if !taken {
+			// Whole seconds until the bucket holds a token again, rounded to the
+			// millisecond first so float error does not add a second
+			wait := time.Duration((1 - tokens) / l.perSecond() * float64(time.Second)).Round(time.Millisecond)
+			c.Header("Retry-After", strconv.Itoa(int(math.Max(math.Ceil(wait.Seconds()), 1))))
+			handlers.AbortWithErrorResponse(c, http.StatusTooManyRequests, handlers.CodeRateLimited,
+				errors.New("Rate limit exceeded"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// MemoryStore keeps token buckets in this process
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// pruneThreshold is the bucket count above which full buckets are dropped
+const pruneThreshold = 10000
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+// TakeToken implements Store
+func (m *MemoryStore) TakeToken(_ context.Context, client string, capacity int, perSecond float64, now time.Time) (bool, float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[client]
+	if !ok {
+		if len(m.buckets) >= pruneThreshold {
+			m.prune(float64(capacity), perSecond, now)
+		}
+		b = &bucket{tokens: float64(capacity), updated: now}
+		m.buckets[client] = b
+	}
+	if now.After(b.updated) {
+		b.tokens = math.Min(float64(capacity), b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+		b.updated = now
+	}
+	if b.tokens < 1 {
+		return false, b.tokens, nil
+	}
+	b.tokens--
+	return true, b.tokens, nil
+}
+
+// prune drops the buckets that have refilled by now, which a new bucket
+// would recreate as they are
+func (m *MemoryStore) prune(capacity, perSecond float64, now time.Time) {
+	for client, b := range m.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*perSecond >= capacity {
+			delete(m.buckets, client)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a time the test moves by hand
+type fakeClock struct{ now time.Time }
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)}
+}
+
+func (f *fakeClock) Now() time.Time          { return f.now }
+func (f *fakeClock) Advance(d time.Duration) { f.now = f.now.Add(d) }
+
+// requestFrom is a request from a client at ip
+func requestFrom(ip string) *http.Request {
+	r := httptest.NewRequest("GET", "/stocks", nil)
+	r.RemoteAddr = ip + ":1234"
+	return r
+}
+
+func serve(router *gin.Engine, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+// newLimitedRouter serves /stocks behind a limiter on clock
+func newLimitedRouter(store Store, perMinute int, clock *fakeClock) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	limiter := NewLimiter(store, perMinute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	limiter.now = clock.Now
+	router := gin.New()
+	router.Use(limiter.Middleware())
+	router.GET("/stocks", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"success": true}) })
+	return router
+}
+
+func TestMiddleware_RefusesBeyondTheLimitAndRefills(t *testing.T) {
+	clock := newFakeClock()
+	router := newLimitedRouter(NewMemoryStore(), 60, clock)
+
+	// A burst up to the limit is served, counting down the remaining quota
+	for i := 1; i <= 60; i++ {
+		w := serve(router, requestFrom("203.0.113.7"))
+		require.Equal(t, http.StatusOK, w.Code, "request %d", i)
+		assert.Equal(t, "60", w.Header().Get(LimitHeader))
+		assert.Equal(t, strconv.Itoa(60-i), w.Header().Get(RemainingHeader))
+	}
+
+	w := serve(router, requestFrom("203.0.113.7"))
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"), "a token a second at 60 a minute")
+	assert.Equal(t, "0", w.Header().Get(RemainingHeader))
+	var response struct {
+		Error struct{ Code string } `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "RATE_LIMITED", response.Error.Code)
+
+	// Other clients have their own bucket
+	assert.Equal(t, http.StatusOK, serve(router, requestFrom("198.51.100.2")).Code)
+
+	// Half a second refills half a token, which is not enough
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, http.StatusTooManyRequests, serve(router, requestFrom("203.0.113.7")).Code)
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, serve(router, requestFrom("203.0.113.7")).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(router, requestFrom("203.0.113.7")).Code)
+
+	// A minute later the whole quota is back, and no more
+	clock.Advance(time.Hour)
+	w = serve(router, requestFrom("203.0.113.7"))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "59", w.Header().Get(RemainingHeader))
+}
+
+func TestMiddleware_RetryAfterAtLowLimits(t *testing.T) {
+	clock := newFakeClock()
+	router := newLimitedRouter(NewMemoryStore(), 2, clock)
+
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusOK, serve(router, requestFrom("203.0.113.7")).Code)
+	}
+	w := serve(router, requestFrom("203.0.113.7"))
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"), "a token every 30 seconds at 2 a minute")
+
+	clock.Advance(10 * time.Second)
+	w = serve(router, requestFrom("203.0.113.7"))
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "20", w.Header().Get("Retry-After"))
+
+	clock.Advance(20 * time.Second)
+	assert.Equal(t, http.StatusOK, serve(router, requestFrom("203.0.113.7")).Code)
+}
+
+func TestMiddleware_CountsRequestsCarryingUpgrade(t *testing.T) {
+	router := newLimitedRouter(NewMemoryStore(), 1, newFakeClock())
+
+	// WebSocket clients connect to /ws, outside the limit, so the header
+	// buys a limited route nothing
+	require.Equal(t, http.StatusOK, serve(router, requestFrom("203.0.113.7")).Code)
+	r := requestFrom("203.0.113.7")
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	assert.Equal(t, http.StatusTooManyRequests, serve(router, r).Code)
+}
+
+// failingStore stands in for Redis being down
+type failingStore struct{}
+
+func (failingStore) TakeToken(context.Context, string, int, float64, time.Time) (bool, float64, error) {
+	return false, 0, errors.New("connection refused")
+}
+
+func TestMiddleware_LimitsInMemoryWhenTheStoreFails(t *testing.T) {
+	router := newLimitedRouter(failingStore{}, 2, newFakeClock())
+
+	assert.Equal(t, http.StatusOK, serve(router, requestFrom("203.0.113.7")).Code)
+	assert.Equal(t, http.StatusOK, serve(router, requestFrom("203.0.113.7")).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(router, requestFrom("203.0.113.7")).Code)
+}
+
+func TestMemoryStore_PrunesFullBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	now := newFakeClock().now
+	for i := 0; i < pruneThreshold; i++ {
+		_, _, err := store.TakeToken(context.Background(), strconv.Itoa(i), 60, 1, now)
+		require.NoError(t, err)
+	}
+
+	// A minute on every bucket is full again and only the new one is kept
+	taken, tokens, err := store.TakeToken(context.Background(), "new", 60, 1, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, taken)
+	assert.Equal(t, 59.0, tokens)
+	assert.Len(t, store.buckets, 1)
+}
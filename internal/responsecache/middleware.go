@@ -0,0 +1,151 @@
+// Package responsecache serves repeated GET requests from whole responses
+// cached in Redis until the next data sync
+package responsecache
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stock-intelligence-backend/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultTTL is how long a response is served, unless a sync invalidates it first
+const DefaultTTL = 5 * time.Minute
+
+// StatusHeader tells whether a response was served from the cache: HIT, MISS or BYPASS
+const StatusHeader = "X-Cache"
+
+// Store holds cached responses by generation. Invalidating the cache bumps
+// the generation, leaving every response cached before it unread.
+type Store interface {
+	ResponseGeneration(ctx context.Context) (int64, error)
+	GetResponse(ctx context.Context, generation int64, requestURI string, dest interface{}) error
+	SetResponse(ctx context.Context, generation int64, requestURI string, response interface{}, expiration time.Duration) error
+	RecordResponseLookup(ctx context.Context, hit bool) error
+}
+
+// cachedResponse is a successful response as it was written
+type cachedResponse struct {
+	ContentType string    `json:"content_type"`
+	Body        []byte    `json:"body"`
+	StoredAt    time.Time `json:"stored_at"`
+}
+
+// Cache serves GET requests from responses stored by request URI
+type Cache struct {
+	store  Store
+	ttl    time.Duration
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+// New creates a response cache whose responses are served for up to ttl
+func New(store Store, ttl time.Duration, logger *slog.Logger) *Cache {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Cache{store: store, ttl: ttl, logger: logger, now: time.Now}
+}
+
+// Middleware answers a GET request from the cache when an identical one was
+// answered since the last sync, and otherwise caches a 200 response for the
+// next. Responses carry Cache-Control with the time left until the cached
+// copy expires, and Age with how old it is. Requests with an API key and
+// error responses are never cached.
+func (rc *Cache) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet || c.GetHeader(auth.APIKeyHeader) != "" {
+			c.Header(StatusHeader, "BYPASS")
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		generation, err := rc.store.ResponseGeneration(ctx)
+		if err != nil {
+			rc.logger.WarnContext(ctx, "Response cache unavailable", "error", err)
+			c.Header(StatusHeader, "BYPASS")
+			c.Next()
+			return
+		}
+
+		requestURI := c.Request.URL.RequestURI()
+		var cached cachedResponse
+		if err := rc.store.GetResponse(ctx, generation, requestURI, &cached); err == nil {
+			rc.record(c, true)
+			age := rc.now().Sub(cached.StoredAt)
+			if age < 0 {
+				age = 0
+			}
+			rc.setFreshness(c, age)
+			c.Header(StatusHeader, "HIT")
+			c.Data(http.StatusOK, cached.ContentType, cached.Body)
+			c.Abort()
+			return
+		}
+		rc.record(c, false)
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		rc.setFreshness(c, 0)
+		c.Header(StatusHeader, "MISS")
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.Status() != http.StatusOK {
+			return
+		}
+		response := cachedResponse{
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+			StoredAt:    rc.now(),
+		}
+		if err := rc.store.SetResponse(ctx, generation, requestURI, response, rc.ttl); err != nil {
+			rc.logger.WarnContext(ctx, "Failed to cache response", "path", c.Request.URL.Path, "error", err)
+		}
+	}
+}
+
+// setFreshness sets Cache-Control and Age for a response of the given age
+func (rc *Cache) setFreshness(c *gin.Context, age time.Duration) {
+	c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(rc.ttl.Seconds())))
+	c.Header("Age", strconv.Itoa(int(age.Seconds())))
+}
+
+// record counts a lookup; a failure only loses the count
+func (rc *Cache) record(c *gin.Context, hit bool) {
+	if err := rc.store.RecordResponseLookup(c.Request.Context(), hit); err != nil {
+		rc.logger.DebugContext(c.Request.Context(), "Failed to count response cache lookup", "error", err)
+	}
+}
+
+// recordingWriter keeps a copy of the body it writes. A response other than
+// 200 is marked uncacheable before its headers go out.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(code int) {
+	if code != http.StatusOK {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Del("Age")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
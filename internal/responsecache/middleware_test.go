@@ -0,0 +1,120 @@
+package responsecache
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/auth"
+	"stock-intelligence-backend/internal/cache"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCachedRouter serves /stocks through the response cache, counting the
+// requests that reach the handler, and /missing as a 404
+func newCachedRouter(t *testing.T) (*gin.Engine, *cache.RedisCache, *Cache, *int) {
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache("redis://"+server.Addr(), "si:")
+	require.NoError(t, err)
+	t.Cleanup(func() { redisCache.Close() })
+
+	gin.SetMode(gin.TestMode)
+	responseCache := New(redisCache, time.Minute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	calls := 0
+	router := gin.New()
+	router.Use(responseCache.Middleware())
+	router.GET("/stocks", func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusOK, gin.H{"success": true, "calls": calls})
+	})
+	router.GET("/missing", func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusNotFound, gin.H{"success": false})
+	})
+	return router, redisCache, responseCache, &calls
+}
+
+func get(router *gin.Engine, path string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", path, nil)
+	for name, values := range header {
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddleware_ServesRepeatsFromCacheUntilInvalidated(t *testing.T) {
+	router, redisCache, responseCache, calls := newCachedRouter(t)
+	now := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	responseCache.now = func() time.Time { return now }
+
+	first := get(router, "/stocks?limit=10", nil)
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "MISS", first.Header().Get(StatusHeader))
+	assert.Equal(t, "public, max-age=60", first.Header().Get("Cache-Control"))
+	assert.Equal(t, "0", first.Header().Get("Age"))
+
+	// The same request is answered without reaching the handler, body and all
+	now = now.Add(15 * time.Second)
+	second := get(router, "/stocks?limit=10", nil)
+	require.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, 1, *calls)
+	assert.Equal(t, "HIT", second.Header().Get(StatusHeader))
+	assert.Equal(t, "15", second.Header().Get("Age"))
+	assert.Equal(t, first.Header().Get("Content-Type"), second.Header().Get("Content-Type"))
+	assert.JSONEq(t, first.Body.String(), second.Body.String())
+
+	// Another query string is another response
+	get(router, "/stocks?limit=20", nil)
+	assert.Equal(t, 2, *calls)
+
+	// A sync invalidates the cache, so the next request reaches the handler again
+	require.NoError(t, redisCache.InvalidateAll(context.Background()))
+	third := get(router, "/stocks?limit=10", nil)
+	assert.Equal(t, "MISS", third.Header().Get(StatusHeader))
+	assert.Equal(t, 3, *calls)
+	assert.JSONEq(t, `{"success": true, "calls": 3}`, third.Body.String())
+
+	hits, misses, err := redisCache.ResponseStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), hits)
+	assert.Equal(t, int64(3), misses)
+}
+
+func TestMiddleware_NeverCachesErrors(t *testing.T) {
+	router, _, _, calls := newCachedRouter(t)
+
+	for i := 1; i <= 2; i++ {
+		w := get(router, "/missing", nil)
+		require.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.Empty(t, w.Header().Get("Age"))
+		assert.Equal(t, i, *calls)
+	}
+}
+
+func TestMiddleware_BypassesRequestsWithAPIKey(t *testing.T) {
+	router, _, _, calls := newCachedRouter(t)
+	withKey := http.Header{auth.APIKeyHeader: []string{"si_admin"}}
+
+	// Neither served from nor stored in the cache
+	for i := 1; i <= 2; i++ {
+		w := get(router, "/stocks", withKey)
+		assert.Equal(t, "BYPASS", w.Header().Get(StatusHeader))
+		assert.Empty(t, w.Header().Get("Cache-Control"))
+		assert.Equal(t, i, *calls)
+	}
+	assert.Equal(t, "MISS", get(router, "/stocks", nil).Header().Get(StatusHeader))
+	assert.Equal(t, 3, *calls)
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// AdjustedDataProvider is implemented by market data providers that serve
+// split- and dividend-adjusted daily history. The bars it returns carry
+// AdjustedClose and SplitCoefficient, which SaveHistoricalData stores.
+type AdjustedDataProvider interface {
+	FetchDailyAdjustedData(symbol string, initiator Initiator) (*DailySeries, error)
+}
+
+// AlphaVantageAdjustedResponse is the TIME_SERIES_DAILY_ADJUSTED payload
+type AlphaVantageAdjustedResponse struct {
+	MetaData   MetaData                           `json:"Meta Data"`
+	TimeSeries map[string]AdjustedTimeSeriesEntry `json:"Time Series (Daily)"`
+}
+
+// AdjustedTimeSeriesEntry is one day of TIME_SERIES_DAILY_ADJUSTED. Note the
+// volume moves to "6." to make room for the adjusted close.
+type AdjustedTimeSeriesEntry struct {
+	Open             string `json:"1. open"`
+	High             string `json:"2. high"`
+	Low              string `json:"3. low"`
+	Close            string `json:"4. close"`
+	AdjustedClose    string `json:"5. adjusted close"`
+	Volume           string `json:"6. volume"`
+	DividendAmount   string `json:"7. dividend amount"`
+	SplitCoefficient string `json:"8. split coefficient"`
+}
+
+// FetchDailyAdjustedData fetches the full daily history for a stock with
+// adjusted closes, dividends and split coefficients
+func (a *AlphaVantageClient) FetchDailyAdjustedData(symbol string, initiator Initiator) (*DailySeries, error) {
+	if err := initiator.Validate(); err != nil {
+		return nil, err
+	}
+
+	canMake, err := a.CanMakeRequest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	if !canMake {
+		return nil, &APIError{Provider: a.serviceName, Kind: APIErrorRateLimited, Symbol: symbol, Message: "local API budget exhausted"}
+	}
+
+	params := map[string]string{
+		"function":   "TIME_SERIES_DAILY_ADJUSTED",
+		"symbol":     symbol,
+		"outputsize": "full",
+		"apikey":     a.apiKey,
+	}
+
+	response, err := a.makeRequest(context.Background(), initiator, "TIME_SERIES_DAILY_ADJUSTED", a.baseURL, params)
+	if err != nil {
+		a.logger.Error("Alpha Vantage API error", "symbol", symbol, "error", err)
+		return nil, err
+	}
+
+	series, err := parseDailyAdjustedResponse(symbol, response)
+	if err != nil {
+		return nil, err
+	}
+
+	a.logger.Info("Successfully fetched adjusted daily data", "symbol", symbol, "days", len(series.Bars))
+	return series, nil
+}
+
+// parseDailyAdjustedResponse converts a TIME_SERIES_DAILY_ADJUSTED body into
+// bars, classifying the error bodies Alpha Vantage sends with a 200 status
+func parseDailyAdjustedResponse(symbol string, body []byte) (*DailySeries, error) {
+	var avResponse AlphaVantageAdjustedResponse
+	if err := json.Unmarshal(body, &avResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse Alpha Vantage response: %w", err)
+	}
+
+	if len(avResponse.TimeSeries) == 0 {
+		if err := classifyErrorBody(symbol, body); err != nil {
+			return nil, err
+		}
+		return nil, &APIError{Provider: ProviderAlphaVantage, Kind: APIErrorNoData, Symbol: symbol, Message: "no time series data returned"}
+	}
+
+	series := &DailySeries{Symbol: symbol, Bars: make([]DailyBar, 0, len(avResponse.TimeSeries))}
+	for dateStr, entry := range avResponse.TimeSeries {
+		bar, err := entry.ParseBar(dateStr)
+		if err != nil {
+			series.reject(err)
+			continue
+		}
+		series.Bars = append(series.Bars, bar)
+	}
+
+	return series, nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"math"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// largestDailyMove returns the biggest day-over-day change, as a fraction, of
+// the closes picked from bars sorted oldest first
+func largestDailyMove(bars []DailyBar, close func(DailyBar) float64) float64 {
+	largest := 0.0
+	for i := 1; i < len(bars); i++ {
+		move := math.Abs(close(bars[i])-close(bars[i-1])) / close(bars[i-1])
+		largest = math.Max(largest, move)
+	}
+	return largest
+}
+
+func TestFetchDailyAdjustedData_SplitKeepsAdjustedSeriesContinuous(t *testing.T) {
+	var requests []*http.Request
+	client, mock := newRetryTestClient(t, serveFixture(t, "alphavantage_daily_adjusted_aapl_split.json", &requests))
+
+	expectRateLimitQuery(mock)
+	mock.ExpectExec("INSERT INTO api_calls").
+		WithArgs(ProviderAlphaVantage, "TIME_SERIES_DAILY_ADJUSTED", sqlmock.AnyArg(), 200, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), string(InitiatorTasksCLI), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRateLimitIncrement(mock)
+
+	series, err := client.FetchDailyAdjustedData("AAPL", InitiatorTasksCLI)
+
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	assert.Equal(t, "TIME_SERIES_DAILY_ADJUSTED", requests[0].URL.Query().Get("function"))
+	require.Len(t, series.Bars, 6)
+
+	bars := series.Bars
+	sort.Slice(bars, func(i, j int) bool { return bars[i].Date.Before(bars[j].Date) })
+
+	// The 4:1 split on 2020-08-31 cuts the raw close by three quarters overnight
+	assert.Greater(t, largestDailyMove(bars, func(b DailyBar) float64 { return b.Close }), 0.7)
+	// while the adjusted series moves no more than an ordinary trading day
+	assert.Less(t, largestDailyMove(bars, func(b DailyBar) float64 { return b.AdjustedClose }), 0.05)
+
+	var splits []DailyBar
+	for _, bar := range bars {
+		if bar.isSplit() {
+			splits = append(splits, bar)
+		}
+	}
+	require.Len(t, splits, 1)
+	assert.Equal(t, "2020-08-31", splits[0].Date.Format("2006-01-02"))
+	assert.Equal(t, 4.0, splits[0].SplitCoefficient)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParseDailyAdjustedResponse_Errors(t *testing.T) {
+	_, err := parseDailyAdjustedResponse("NOTREAL", []byte(`{"Error Message": "Invalid API call."}`))
+	assert.ErrorIs(t, err, ErrInvalidSymbol)
+
+	_, err = parseDailyAdjustedResponse("AAPL", []byte(`{"Meta Data": {}}`))
+	assert.ErrorIs(t, err, ErrNoData)
+}
+
+func TestSaveHistoricalData_StoresAdjustedCloseAndRecordsSplits(t *testing.T) {
+	series, err := parseDailyAdjustedResponse("AAPL", readFixture(t, "alphavantage_daily_adjusted_aapl_split.json"))
+	require.NoError(t, err)
+	sort.Slice(series.Bars, func(i, j int) bool { return series.Bars[i].Date.Before(series.Bars[j].Date) })
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expectAdvisoryLock(mock, 1, true)
+	// Adjusted bars rewrite stored history, so none are skipped as already stored
+	expectSaveBegins(mock, 1, series.Bars[len(series.Bars)-1].Date)
+	args := []driver.Value{1}
+	updated := sqlmock.NewRows([]string{"inserted"})
+	for _, bar := range series.Bars {
+		args = append(args, bar.Date, bar.Open, bar.High, bar.Low, bar.Close, bar.AdjustedClose, bar.Volume)
+		updated.AddRow(false)
+	}
+	mock.ExpectQuery(`INSERT INTO daily_prices[\s\S]+adjusted_close = EXCLUDED.adjusted_close`).
+		WithArgs(args...).WillReturnRows(updated)
+	mock.ExpectExec("INSERT INTO stock_splits").WithArgs(1, series.Bars[3].Date, 4.0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectPriceCoverage(mock, 1)
+	mock.ExpectCommit()
+	expectAdvisoryUnlock(mock, 1)
+
+	client := NewAlphaVantageClient("test-key", db)
+	stats, err := client.SaveHistoricalData(context.Background(), "AAPL", series, SyncLockWait)
+	require.NoError(t, err)
+	assert.Equal(t, SaveStats{Updated: len(series.Bars)}, stats)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveHistoricalData_UnadjustedSeriesKeepsStoredAdjustment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expectAdvisoryLock(mock, 1, true)
+	expectSaveBegins(mock, 1, nil)
+	// A new day takes the close as its adjusted close; a stored day keeps its adjustment
+	bar := singleBarSeries("AAPL").Bars[0]
+	mock.ExpectQuery(`INSERT INTO daily_prices[\s\S]+adjusted_close = daily_prices.adjusted_close`).
+		WithArgs(1, bar.Date, bar.Open, bar.High, bar.Low, bar.Close, bar.Close, bar.Volume).
+		WillReturnRows(insertedRow())
+	expectPriceCoverage(mock, 1)
+	mock.ExpectCommit()
+	expectAdvisoryUnlock(mock, 1)
+
+	client := NewAlphaVantageClient("test-key", db)
+	_, err = client.SaveHistoricalData(context.Background(), "AAPL", singleBarSeries("AAPL"), SyncLockWait)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveHistoricalData_RecordsNonZeroDividends(t *testing.T) {
+	exDate := time.Date(2024, 2, 9, 0, 0, 0, 0, time.UTC)
+	series := &DailySeries{Symbol: "AAPL", Bars: []DailyBar{
+		{Date: time.Date(2024, 2, 8, 0, 0, 0, 0, time.UTC), Open: 189.39, High: 189.54, Low: 187.35, Close: 188.32, AdjustedClose: 187.33, Volume: 40962000},
+		{Date: exDate, Open: 188.65, High: 189.99, Low: 188, Close: 188.85, AdjustedClose: 188.10, Volume: 45155200, Dividend: 0.23},
+		// Fetched again in the same series; the later amount is the one kept
+		{Date: exDate, Open: 188.65, High: 189.99, Low: 188, Close: 188.85, AdjustedClose: 188.10, Volume: 45155200, Dividend: 0.24},
+	}}
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expectAdvisoryLock(mock, 1, true)
+	expectSaveBegins(mock, 1, nil)
+	mock.ExpectQuery("INSERT INTO daily_prices").WillReturnRows(insertedRows(2))
+	// Only the day with a dividend is recorded, and a stored ex-date is overwritten
+	mock.ExpectExec(`INSERT INTO dividends[\s\S]+ON CONFLICT \(stock_id, ex_date\)\s+DO UPDATE SET amount = EXCLUDED.amount`).
+		WithArgs(1, exDate, 0.24).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectPriceCoverage(mock, 1)
+	mock.ExpectCommit()
+	expectAdvisoryUnlock(mock, 1)
+
+	client := NewAlphaVantageClient("test-key", db)
+	stats, err := client.SaveHistoricalData(context.Background(), "AAPL", series, SyncLockWait)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Inserted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
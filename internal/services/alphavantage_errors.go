@@ -0,0 +1,155 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// APIErrorKind classifies why a market data fetch failed
+type APIErrorKind string
+
+const (
+	// APIErrorRateLimited means the daily, hourly or per-minute allowance is spent
+	APIErrorRateLimited APIErrorKind = "rate_limited"
+	// APIErrorInvalidSymbol means the provider does not recognise the symbol
+	APIErrorInvalidSymbol APIErrorKind = "invalid_symbol"
+	// APIErrorNoData means the response parsed but held no time series
+	APIErrorNoData APIErrorKind = "no_data"
+	// APIErrorRequestFailed covers network errors and unexpected HTTP statuses
+	APIErrorRequestFailed APIErrorKind = "request_failed"
+	// APIErrorSaveFailed means the data was fetched but could not be stored
+	APIErrorSaveFailed APIErrorKind = "save_failed"
+)
+
+// Sentinel errors matched by APIError through errors.Is
+var (
+	ErrRateLimited   = errors.New("market data provider rate limit reached")
+	ErrInvalidSymbol = errors.New("market data provider does not recognise the symbol")
+	ErrNoData        = errors.New("market data provider returned no time series data")
+)
+
+// APIError is a failed market data fetch with its cause classified. Provider
+// is the service name of the source; errors without one came from Alpha Vantage.
+type APIError struct {
+	Provider string
+	Kind     APIErrorKind
+	Symbol   string
+	Message  string
+}
+
+func (e *APIError) Error() string {
+	provider := "Alpha Vantage"
+	if e.Provider != "" && e.Provider != ProviderAlphaVantage {
+		provider = e.Provider
+	}
+	return fmt.Sprintf("%s %s for %s: %s", provider, e.Kind, e.Symbol, e.Message)
+}
+
+// Is lets callers use errors.Is(err, ErrRateLimited) and friends
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.Kind == APIErrorRateLimited
+	case ErrInvalidSymbol:
+		return e.Kind == APIErrorInvalidSymbol
+	case ErrNoData:
+		return e.Kind == APIErrorNoData
+	}
+	return false
+}
+
+// Retryable reports whether the same fetch may succeed later
+func (k APIErrorKind) Retryable() bool {
+	switch k {
+	case APIErrorInvalidSymbol, APIErrorNoData:
+		return false
+	}
+	return true
+}
+
+// ErrorKindOf classifies err, treating unclassified errors as failed requests
+func ErrorKindOf(err error) APIErrorKind {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind
+	}
+	return APIErrorRequestFailed
+}
+
+// parseDailyResponse decodes a TIME_SERIES_DAILY body, turning the error
+// shapes Alpha Vantage returns with a 200 status into typed errors
+func parseDailyResponse(symbol string, body []byte) (*AlphaVantageResponse, error) {
+	var avResponse AlphaVantageResponse
+	if err := json.Unmarshal(body, &avResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse Alpha Vantage response: %w", err)
+	}
+
+	if len(avResponse.TimeSeries) > 0 {
+		return &avResponse, nil
+	}
+
+	if err := classifyErrorBody(symbol, body); err != nil {
+		return nil, err
+	}
+	return nil, &APIError{Provider: ProviderAlphaVantage, Kind: APIErrorNoData, Symbol: symbol, Message: "no time series data returned"}
+}
+
+// classifyErrorBody returns the typed error for a body carrying one of the
+// error messages Alpha Vantage sends with a 200 status, or nil if it has none
+func classifyErrorBody(symbol string, body []byte) error {
+	var errorResponse map[string]interface{}
+	if err := json.Unmarshal(body, &errorResponse); err != nil {
+		return nil
+	}
+
+	if msg, exists := errorResponse["Error Message"]; exists {
+		return &APIError{Provider: ProviderAlphaVantage, Kind: APIErrorInvalidSymbol, Symbol: symbol, Message: fmt.Sprint(msg)}
+	}
+	if note, exists := errorResponse["Note"]; exists {
+		return &APIError{Provider: ProviderAlphaVantage, Kind: APIErrorRateLimited, Symbol: symbol, Message: fmt.Sprint(note)}
+	}
+	// Newer responses report an exhausted allowance under "Information"
+	if info, exists := errorResponse["Information"]; exists && strings.Contains(strings.ToLower(fmt.Sprint(info)), "rate limit") {
+		return &APIError{Provider: ProviderAlphaVantage, Kind: APIErrorRateLimited, Symbol: symbol, Message: fmt.Sprint(info)}
+	}
+	return nil
+}
+
+// parseQuoteResponse decodes a GLOBAL_QUOTE body. Unknown symbols come back as
+// an empty "Global Quote" object rather than an error message.
+func parseQuoteResponse(symbol string, body []byte) (*Quote, error) {
+	var quoteResponse GlobalQuoteResponse
+	if err := json.Unmarshal(body, &quoteResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse Alpha Vantage response: %w", err)
+	}
+
+	entry := quoteResponse.GlobalQuote
+	if entry.Symbol == "" {
+		if err := classifyErrorBody(symbol, body); err != nil {
+			return nil, err
+		}
+		return nil, &APIError{Provider: ProviderAlphaVantage, Kind: APIErrorInvalidSymbol, Symbol: symbol, Message: "no quote returned"}
+	}
+
+	date, err := parseMarketDate(entry.LatestTradingDay)
+	if err != nil {
+		return nil, &APIError{Provider: ProviderAlphaVantage, Kind: APIErrorNoData, Symbol: symbol, Message: fmt.Sprintf("invalid trading day %q", entry.LatestTradingDay)}
+	}
+
+	quote := &Quote{Symbol: symbol, DailyBar: DailyBar{Date: date}}
+	quote.Open, _ = strconv.ParseFloat(entry.Open, 64)
+	quote.High, _ = strconv.ParseFloat(entry.High, 64)
+	quote.Low, _ = strconv.ParseFloat(entry.Low, 64)
+	quote.Close, err = strconv.ParseFloat(entry.Price, 64)
+	if err != nil || quote.Close <= 0 {
+		return nil, &APIError{Provider: ProviderAlphaVantage, Kind: APIErrorNoData, Symbol: symbol, Message: fmt.Sprintf("invalid price %q", entry.Price)}
+	}
+	quote.Volume, _ = strconv.ParseInt(entry.Volume, 10, 64)
+	quote.PreviousClose, _ = strconv.ParseFloat(entry.PreviousClose, 64)
+	quote.Change, _ = strconv.ParseFloat(entry.Change, 64)
+	quote.ChangePercent, _ = strconv.ParseFloat(strings.TrimSuffix(entry.ChangePercent, "%"), 64)
+	return quote, nil
+}
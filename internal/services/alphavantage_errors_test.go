@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDailyResponse_ErrorShapes(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		kind      APIErrorKind
+		sentinel  error
+		retryable bool
+	}{
+		{
+			name:     "invalid symbol",
+			body:     `{"Error Message": "Invalid API call. Please retry or visit the documentation (https://www.alphavantage.co/documentation/) for TIME_SERIES_DAILY."}`,
+			kind:     APIErrorInvalidSymbol,
+			sentinel: ErrInvalidSymbol,
+		},
+		{
+			name:      "rate limit note",
+			body:      `{"Note": "Thank you for using Alpha Vantage! Our standard API call frequency is 5 calls per minute and 500 calls per day."}`,
+			kind:      APIErrorRateLimited,
+			sentinel:  ErrRateLimited,
+			retryable: true,
+		},
+		{
+			name:      "rate limit information",
+			body:      `{"Information": "Thank you for using Alpha Vantage! Our standard API rate limit is 25 requests per day."}`,
+			kind:      APIErrorRateLimited,
+			sentinel:  ErrRateLimited,
+			retryable: true,
+		},
+		{
+			name:     "empty time series",
+			body:     `{"Meta Data": {"2. Symbol": "AAPL"}, "Time Series (Daily)": {}}`,
+			kind:     APIErrorNoData,
+			sentinel: ErrNoData,
+		},
+		{
+			name:     "unrelated information",
+			body:     `{"Information": "This is a premium endpoint."}`,
+			kind:     APIErrorNoData,
+			sentinel: ErrNoData,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := parseDailyResponse("AAPL", []byte(tt.body))
+
+			assert.Nil(t, data)
+			require.ErrorIs(t, err, tt.sentinel)
+			assert.Equal(t, tt.kind, ErrorKindOf(err))
+			assert.Equal(t, tt.retryable, ErrorKindOf(err).Retryable())
+
+			var apiErr *APIError
+			require.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, "AAPL", apiErr.Symbol)
+		})
+	}
+}
+
+func TestParseDailyResponse_Success(t *testing.T) {
+	data, err := parseDailyResponse("AAPL", []byte(dailySeriesFixture))
+
+	require.NoError(t, err)
+	assert.Len(t, data.TimeSeries, 1)
+}
+
+func TestFetchDailyData_ReturnsTypedErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Error Message": "Invalid API call."}`)
+	}))
+	defer server.Close()
+
+	client, mock := newRetryTestClient(t, server)
+	expectRateLimitQuery(mock)
+	expectAttemptLogged(mock, http.StatusOK)
+	expectRateLimitIncrement(mock)
+
+	_, err := client.FetchDailyData(context.Background(), "NOTREAL", InitiatorManualSync)
+
+	assert.ErrorIs(t, err, ErrInvalidSymbol)
+	assert.False(t, errors.Is(err, ErrRateLimited))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestErrorKindOf_UnclassifiedErrors(t *testing.T) {
+	assert.Equal(t, APIErrorRequestFailed, ErrorKindOf(fmt.Errorf("request failed: connection refused")))
+	assert.True(t, APIErrorRequestFailed.Retryable())
+	assert.True(t, APIErrorSaveFailed.Retryable())
+}
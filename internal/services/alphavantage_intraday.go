@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IntradayIntervals are the bar sizes TIME_SERIES_INTRADAY supports
+var IntradayIntervals = []string{"1min", "5min", "15min", "30min", "60min"}
+
+// DefaultIntradayRetention is how long intraday bars are kept before the daily cleanup removes them
+const DefaultIntradayRetention = 30 * 24 * time.Hour
+
+// intradayTimestampLayout is how Alpha Vantage formats intraday bar times
+const intradayTimestampLayout = "2006-01-02 15:04:05"
+
+// IntradayProvider is implemented by market data providers that serve intraday bars
+type IntradayProvider interface {
+	FetchIntradayData(symbol, interval string, initiator Initiator) (*IntradaySeries, error)
+	SaveIntradayData(ctx context.Context, symbol string, data *IntradaySeries) error
+}
+
+// IntradayBar is one interval of prices. Timestamp is the bar's start in
+// exchange local time, stored without a zone like Alpha Vantage reports it.
+type IntradayBar struct {
+	Timestamp time.Time
+	Open      float64
+	High      float64
+	Low       float64
+	Close     float64
+	Volume    int64
+}
+
+// IntradaySeries is a symbol's intraday bars at one interval, in no particular order
+type IntradaySeries struct {
+	Symbol   string
+	Interval string
+	Bars     []IntradayBar
+}
+
+// ValidateIntradayInterval returns an error unless interval is one of IntradayIntervals
+func ValidateIntradayInterval(interval string) error {
+	for _, valid := range IntradayIntervals {
+		if interval == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported intraday interval %q (expected one of %s)", interval, strings.Join(IntradayIntervals, ", "))
+}
+
+// FetchIntradayData fetches intraday bars for a stock at the given interval,
+// spending budget through the same rate limit checks as daily fetches
+func (a *AlphaVantageClient) FetchIntradayData(symbol, interval string, initiator Initiator) (*IntradaySeries, error) {
+	if err := ValidateIntradayInterval(interval); err != nil {
+		return nil, err
+	}
+	if err := initiator.Validate(); err != nil {
+		return nil, err
+	}
+
+	canMake, err := a.CanMakeRequest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	if !canMake {
+		return nil, &APIError{Provider: a.serviceName, Kind: APIErrorRateLimited, Symbol: symbol, Message: "local API budget exhausted"}
+	}
+
+	params := map[string]string{
+		"function":   "TIME_SERIES_INTRADAY",
+		"symbol":     symbol,
+		"interval":   interval,
+		"outputsize": "full",
+		"apikey":     a.apiKey,
+	}
+
+	response, err := a.makeRequest(context.Background(), initiator, "TIME_SERIES_INTRADAY", a.baseURL, params)
+	if err != nil {
+		a.logger.Error("Alpha Vantage API error", "symbol", symbol, "error", err)
+		return nil, err
+	}
+
+	series, err := parseIntradayResponse(symbol, interval, response)
+	if err != nil {
+		return nil, err
+	}
+
+	a.logger.Info("Successfully fetched intraday data", "symbol", symbol, "interval", interval, "bars", len(series.Bars))
+	return series, nil
+}
+
+// parseIntradayResponse decodes a TIME_SERIES_INTRADAY body, whose series key
+// names the interval, e.g. "Time Series (5min)"
+func parseIntradayResponse(symbol, interval string, body []byte) (*IntradaySeries, error) {
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse Alpha Vantage response: %w", err)
+	}
+
+	var entries map[string]TimeSeriesEntry
+	if raw, exists := response["Time Series ("+interval+")"]; exists {
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse Alpha Vantage response: %w", err)
+		}
+	}
+
+	if len(entries) == 0 {
+		if err := classifyErrorBody(symbol, body); err != nil {
+			return nil, err
+		}
+		return nil, &APIError{Provider: ProviderAlphaVantage, Kind: APIErrorNoData, Symbol: symbol, Message: "no intraday data returned"}
+	}
+
+	series := &IntradaySeries{Symbol: symbol, Interval: interval, Bars: make([]IntradayBar, 0, len(entries))}
+	for timestampStr, entry := range entries {
+		timestamp, err := time.Parse(intradayTimestampLayout, timestampStr)
+		if err != nil {
+			slog.Warn("Failed to parse timestamp", "timestamp", timestampStr, "error", err)
+			continue
+		}
+
+		open, _ := strconv.ParseFloat(entry.Open, 64)
+		high, _ := strconv.ParseFloat(entry.High, 64)
+		low, _ := strconv.ParseFloat(entry.Low, 64)
+		close, _ := strconv.ParseFloat(entry.Close, 64)
+		volume, _ := strconv.ParseInt(entry.Volume, 10, 64)
+
+		series.Bars = append(series.Bars, IntradayBar{Timestamp: timestamp, Open: open, High: high, Low: low, Close: close, Volume: volume})
+	}
+
+	return series, nil
+}
+
+// SaveIntradayData upserts intraday bars, so re-fetching a day replaces its bars
+func (a *AlphaVantageClient) SaveIntradayData(ctx context.Context, symbol string, data *IntradaySeries) error {
+	var stockID int
+	err := a.db.QueryRowContext(ctx, "SELECT id FROM stocks WHERE symbol = $1", symbol).Scan(&stockID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("stock with symbol %s not found", symbol)
+		}
+		return fmt.Errorf("failed to get stock ID: %w", err)
+	}
+
+	insertQuery := `
+		INSERT INTO intraday_prices (stock_id, bar_interval, "timestamp", open_price, high_price,
+		                            low_price, close_price, volume)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (stock_id, bar_interval, "timestamp")
+		DO UPDATE SET
+			open_price = EXCLUDED.open_price,
+			high_price = EXCLUDED.high_price,
+			low_price = EXCLUDED.low_price,
+			close_price = EXCLUDED.close_price,
+			volume = EXCLUDED.volume,
+			created_at = CURRENT_TIMESTAMP
+	`
+
+	stmt, err := a.db.PrepareContext(ctx, insertQuery)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert statement: %w", err)
+	}
+	defer stmt.Close()
+
+	saved := 0
+	for _, bar := range data.Bars {
+		_, err := stmt.ExecContext(ctx, stockID, data.Interval, bar.Timestamp, bar.Open, bar.High, bar.Low, bar.Close, bar.Volume)
+		if err != nil {
+			a.logger.Error("Failed to insert intraday bar", "symbol", symbol, "interval", data.Interval, "timestamp", bar.Timestamp.Format(intradayTimestampLayout), "error", err)
+			continue
+		}
+		saved++
+	}
+
+	a.logger.Info("Saved intraday bars", "symbol", symbol, "interval", data.Interval, "bars", saved)
+	return nil
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateIntradayInterval(t *testing.T) {
+	for _, interval := range []string{"1min", "5min", "15min", "30min", "60min"} {
+		assert.NoError(t, ValidateIntradayInterval(interval), interval)
+	}
+	for _, interval := range []string{"", "2min", "1h", "daily", "5MIN"} {
+		assert.Error(t, ValidateIntradayInterval(interval), interval)
+	}
+}
+
+func TestFetchIntradayData_RejectsIntervalBeforeSpendingBudget(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	client := NewAlphaVantageClient("test-key", db)
+	_, err = client.FetchIntradayData("AAPL", "2min", InitiatorTasksCLI)
+	assert.Error(t, err)
+
+	// No rate limit check or API call was made
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFetchIntradayData_ParsesRecordedJSON(t *testing.T) {
+	var requests []*http.Request
+	client, mock := newRetryTestClient(t, serveFixture(t, "alphavantage_intraday_aapl_5min.json", &requests))
+	expectRateLimitQuery(mock)
+	mock.ExpectExec("INSERT INTO api_calls").
+		WithArgs(ProviderAlphaVantage, "TIME_SERIES_INTRADAY", sqlmock.AnyArg(), http.StatusOK, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), string(InitiatorManualSync), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRateLimitIncrement(mock)
+
+	data, err := client.FetchIntradayData("AAPL", "5min", InitiatorManualSync)
+
+	require.NoError(t, err)
+	assert.Equal(t, "5min", data.Interval)
+	require.Len(t, data.Bars, 2)
+	require.Len(t, requests, 1)
+	assert.Equal(t, "5min", requests[0].URL.Query().Get("interval"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParseIntradayResponse_OtherIntervalIsNoData(t *testing.T) {
+	body := []byte(`{"Time Series (1min)": {"2024-05-01 09:30:00": {"4. close": "169.60"}}}`)
+
+	_, err := parseIntradayResponse("AAPL", "5min", body)
+
+	assert.ErrorIs(t, err, ErrNoData)
+}
+
+func TestSaveIntradayData_UpsertsOnRefetch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	bar := IntradayBar{Timestamp: time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC), Open: 169.58, High: 169.9, Low: 169.11, Close: 169.6, Volume: 2301877}
+	client := NewAlphaVantageClient("test-key", db)
+
+	// The second fetch revised the bar's close; the same row is updated in place
+	for _, close := range []float64{169.6, 169.75} {
+		bar.Close = close
+		mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectPrepare(`ON CONFLICT \(stock_id, bar_interval, "timestamp"\)\s+DO UPDATE`).
+			ExpectExec().
+			WithArgs(1, "5min", bar.Timestamp, bar.Open, bar.High, bar.Low, close, bar.Volume).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := client.SaveIntradayData(context.Background(), "AAPL", &IntradaySeries{Symbol: "AAPL", Interval: "5min", Bars: []IntradayBar{bar}})
+		require.NoError(t, err)
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCleanupOldDataJob_PrunesIntradayByRetention(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	scheduler := NewSchedulerService(db, NewAlphaVantageClient("test-key", db), nil, nil, nil)
+	scheduler.SetIntradayRetention(7 * 24 * time.Hour)
+
+	mock.ExpectExec("DELETE FROM api_calls").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM intraday_prices").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 120))
+	mock.ExpectExec("DELETE FROM sync_history").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM scheduler_runs").WillReturnResult(sqlmock.NewResult(0, 0))
+	expectSchedulerRun(mock, SchedulerJobCleanup, RunStatusSucceeded)
+
+	scheduler.cleanupOldDataJob()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, scheduler.GetRecentActivity(1).RecentErrors)
+}
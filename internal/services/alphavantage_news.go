@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewsFetchLimit is how many of the latest articles one news fetch asks for
+const NewsFetchLimit = 50
+
+// NewsProvider is implemented by market data providers that serve news
+// articles with their sentiment
+type NewsProvider interface {
+	FetchNewsSentiment(ctx context.Context, symbol string, initiator Initiator) ([]NewsArticle, error)
+}
+
+// NewsArticle is a news article about a stock. SentimentScore runs from -1
+// (bearish) to 1 (bullish) and is the sentiment towards the stock when the
+// source scores it, otherwise the article's overall sentiment.
+type NewsArticle struct {
+	Title          string    `json:"title"`
+	URL            string    `json:"url"`
+	Source         string    `json:"source"`
+	PublishedAt    time.Time `json:"published_at"`
+	SentimentScore float64   `json:"sentiment_score"`
+	SentimentLabel string    `json:"sentiment_label"`
+}
+
+// AlphaVantageNewsResponse is the subset of the NEWS_SENTIMENT payload we
+// store. Overall scores are numbers; per-ticker scores arrive as strings.
+type AlphaVantageNewsResponse struct {
+	Feed []struct {
+		Title                 string  `json:"title"`
+		URL                   string  `json:"url"`
+		TimePublished         string  `json:"time_published"`
+		Source                string  `json:"source"`
+		OverallSentimentScore float64 `json:"overall_sentiment_score"`
+		OverallSentimentLabel string  `json:"overall_sentiment_label"`
+		TickerSentiment       []struct {
+			Ticker         string `json:"ticker"`
+			SentimentScore string `json:"ticker_sentiment_score"`
+			SentimentLabel string `json:"ticker_sentiment_label"`
+		} `json:"ticker_sentiment"`
+	} `json:"feed"`
+}
+
+// FetchNewsSentiment fetches the latest NewsFetchLimit articles mentioning a
+// stock, spending one call of the API budget
+func (a *AlphaVantageClient) FetchNewsSentiment(ctx context.Context, symbol string, initiator Initiator) ([]NewsArticle, error) {
+	if err := initiator.Validate(); err != nil {
+		return nil, err
+	}
+
+	canMake, err := a.CanMakeRequest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	if !canMake {
+		return nil, &APIError{Provider: a.serviceName, Kind: APIErrorRateLimited, Symbol: symbol, Message: "local API budget exhausted"}
+	}
+
+	params := map[string]string{
+		"function": "NEWS_SENTIMENT",
+		"tickers":  symbol,
+		"sort":     "LATEST",
+		"limit":    strconv.Itoa(NewsFetchLimit),
+		"apikey":   a.apiKey,
+	}
+
+	response, err := a.makeRequest(ctx, initiator, "NEWS_SENTIMENT", a.baseURL, params)
+	if err != nil {
+		a.logger.Error("Alpha Vantage API error", "symbol", symbol, "error", err)
+		return nil, err
+	}
+
+	return parseNewsResponse(symbol, response)
+}
+
+// parseNewsResponse maps a NEWS_SENTIMENT body onto articles. A stock without
+// recent news has an empty feed, which is an empty list rather than an error;
+// a body without a feed at all is an error.
+func parseNewsResponse(symbol string, body []byte) ([]NewsArticle, error) {
+	var newsResponse AlphaVantageNewsResponse
+	if err := json.Unmarshal(body, &newsResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse Alpha Vantage response: %w", err)
+	}
+
+	if newsResponse.Feed == nil {
+		if err := classifyErrorBody(symbol, body); err != nil {
+			return nil, err
+		}
+		return nil, &APIError{Provider: ProviderAlphaVantage, Kind: APIErrorNoData, Symbol: symbol, Message: "no news feed returned"}
+	}
+
+	articles := make([]NewsArticle, 0, len(newsResponse.Feed))
+	for _, item := range newsResponse.Feed {
+		publishedAt, err := time.Parse("20060102T150405", item.TimePublished)
+		if err != nil || strings.TrimSpace(item.URL) == "" {
+			continue
+		}
+
+		article := NewsArticle{
+			Title:          strings.TrimSpace(item.Title),
+			URL:            strings.TrimSpace(item.URL),
+			Source:         strings.TrimSpace(item.Source),
+			PublishedAt:    publishedAt,
+			SentimentScore: item.OverallSentimentScore,
+			SentimentLabel: item.OverallSentimentLabel,
+		}
+		for _, ticker := range item.TickerSentiment {
+			if !strings.EqualFold(ticker.Ticker, symbol) {
+				continue
+			}
+			if score, err := strconv.ParseFloat(strings.TrimSpace(ticker.SentimentScore), 64); err == nil {
+				article.SentimentScore = score
+				article.SentimentLabel = ticker.SentimentLabel
+			}
+			break
+		}
+		articles = append(articles, article)
+	}
+	return articles, nil
+}
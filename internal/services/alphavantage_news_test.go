@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchNewsSentiment_ParsesRecordedJSON(t *testing.T) {
+	var requests []*http.Request
+	client, mock := newRetryTestClient(t, serveFixture(t, "alphavantage_news_sentiment_aapl.json", &requests))
+	expectRateLimitQuery(mock)
+	mock.ExpectExec("INSERT INTO api_calls").
+		WithArgs(ProviderAlphaVantage, "NEWS_SENTIMENT", sqlmock.AnyArg(), http.StatusOK, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), string(InitiatorScheduler), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRateLimitIncrement(mock)
+
+	articles, err := client.FetchNewsSentiment(context.Background(), "AAPL", InitiatorScheduler)
+
+	require.NoError(t, err)
+	assert.Equal(t, []NewsArticle{
+		{
+			Title:          "Apple Shares Slip As iPhone Demand Worries Mount",
+			URL:            "https://www.example.com/news/apple-shares-slip",
+			Source:         "Benzinga",
+			PublishedAt:    time.Date(2024, time.January, 4, 21, 30, 0, 0, time.UTC),
+			SentimentScore: -0.312,
+			SentimentLabel: "Somewhat-Bearish",
+		},
+		{
+			// Not scored for AAPL, so the article's overall sentiment
+			Title:          "Tech Stocks To Watch This Week",
+			URL:            "https://www.example.com/news/tech-stocks-to-watch",
+			Source:         "Motley Fool",
+			PublishedAt:    time.Date(2024, time.January, 3, 14, 5, 0, 0, time.UTC),
+			SentimentScore: 0.215,
+			SentimentLabel: "Somewhat-Bullish",
+		},
+	}, articles)
+	require.Len(t, requests, 1)
+	assert.Equal(t, "NEWS_SENTIMENT", requests[0].URL.Query().Get("function"))
+	assert.Equal(t, "AAPL", requests[0].URL.Query().Get("tickers"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParseNewsResponse(t *testing.T) {
+	articles, err := parseNewsResponse("AAPL", []byte(`{"items": "0", "feed": []}`))
+	require.NoError(t, err)
+	assert.Empty(t, articles)
+	assert.NotNil(t, articles, "no news is an empty list")
+
+	_, err = parseNewsResponse("AAPL", []byte(`{"Information": "We have detected your API key as demo and our standard API rate limit is 25 requests per day."}`))
+	assert.ErrorIs(t, err, ErrRateLimited)
+
+	_, err = parseNewsResponse("AAPL", []byte(`{}`))
+	assert.ErrorIs(t, err, ErrNoData)
+}
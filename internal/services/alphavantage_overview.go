@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// FundamentalsStaleAfter is the age past which stored fundamentals are flagged as stale
+const FundamentalsStaleAfter = 90 * 24 * time.Hour
+
+// FundamentalsProvider is implemented by market data providers that serve company overviews
+type FundamentalsProvider interface {
+	FetchCompanyOverview(symbol string, initiator Initiator) (*models.CompanyFundamentals, error)
+	SaveCompanyFundamentals(ctx context.Context, fundamentals *models.CompanyFundamentals) error
+}
+
+// AlphaVantageOverview is the subset of the OVERVIEW payload we store. Every
+// value arrives as a string, with "None" or "-" for metrics that don't apply.
+type AlphaVantageOverview struct {
+	Symbol            string `json:"Symbol"`
+	Description       string `json:"Description"`
+	PERatio           string `json:"PERatio"`
+	EPS               string `json:"EPS"`
+	DividendYield     string `json:"DividendYield"`
+	Beta              string `json:"Beta"`
+	SharesOutstanding string `json:"SharesOutstanding"`
+}
+
+// FetchCompanyOverview fetches a stock's company fundamentals
+func (a *AlphaVantageClient) FetchCompanyOverview(symbol string, initiator Initiator) (*models.CompanyFundamentals, error) {
+	if err := initiator.Validate(); err != nil {
+		return nil, err
+	}
+
+	canMake, err := a.CanMakeRequest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	if !canMake {
+		return nil, &APIError{Provider: a.serviceName, Kind: APIErrorRateLimited, Symbol: symbol, Message: "local API budget exhausted"}
+	}
+
+	params := map[string]string{
+		"function": "OVERVIEW",
+		"symbol":   symbol,
+		"apikey":   a.apiKey,
+	}
+
+	response, err := a.makeRequest(context.Background(), initiator, "OVERVIEW", a.baseURL, params)
+	if err != nil {
+		a.logger.Error("Alpha Vantage API error", "symbol", symbol, "error", err)
+		return nil, err
+	}
+
+	return parseOverviewResponse(symbol, response, time.Now())
+}
+
+// parseOverviewResponse maps an OVERVIEW body onto CompanyFundamentals.
+// Unknown symbols come back as an empty object.
+func parseOverviewResponse(symbol string, body []byte, fetchedAt time.Time) (*models.CompanyFundamentals, error) {
+	var overview AlphaVantageOverview
+	if err := json.Unmarshal(body, &overview); err != nil {
+		return nil, fmt.Errorf("failed to parse Alpha Vantage response: %w", err)
+	}
+
+	if overview.Symbol == "" {
+		if err := classifyErrorBody(symbol, body); err != nil {
+			return nil, err
+		}
+		return nil, &APIError{Provider: ProviderAlphaVantage, Kind: APIErrorInvalidSymbol, Symbol: symbol, Message: "no company overview returned"}
+	}
+
+	fundamentals := &models.CompanyFundamentals{
+		Symbol:        symbol,
+		PERatio:       parseOverviewFloat(overview.PERatio),
+		EPS:           parseOverviewFloat(overview.EPS),
+		DividendYield: parseOverviewFloat(overview.DividendYield),
+		Beta:          parseOverviewFloat(overview.Beta),
+		Description:   overviewValue(overview.Description),
+		FetchedAt:     fetchedAt,
+	}
+	if value := overviewValue(overview.SharesOutstanding); value != "" {
+		if shares, err := strconv.ParseInt(value, 10, 64); err == nil {
+			fundamentals.SharesOutstanding = &shares
+		}
+	}
+
+	return fundamentals, nil
+}
+
+// overviewValue trims an OVERVIEW field, mapping its placeholders for missing data to ""
+func overviewValue(value string) string {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case "none", "-", "n/a":
+		return ""
+	}
+	return value
+}
+
+func parseOverviewFloat(value string) *float64 {
+	value = overviewValue(value)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	return &parsed
+}
+
+// SaveCompanyFundamentals replaces a stock's stored fundamentals
+func (a *AlphaVantageClient) SaveCompanyFundamentals(ctx context.Context, fundamentals *models.CompanyFundamentals) error {
+	var stockID int
+	err := a.db.QueryRowContext(ctx, "SELECT id FROM stocks WHERE symbol = $1", fundamentals.Symbol).Scan(&stockID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("stock with symbol %s not found", fundamentals.Symbol)
+		}
+		return fmt.Errorf("failed to get stock ID: %w", err)
+	}
+
+	query := `
+		INSERT INTO company_fundamentals (stock_id, pe_ratio, eps, dividend_yield, beta,
+		                                  shares_outstanding, description, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (stock_id)
+		DO UPDATE SET
+			pe_ratio = EXCLUDED.pe_ratio,
+			eps = EXCLUDED.eps,
+			dividend_yield = EXCLUDED.dividend_yield,
+			beta = EXCLUDED.beta,
+			shares_outstanding = EXCLUDED.shares_outstanding,
+			description = EXCLUDED.description,
+			fetched_at = EXCLUDED.fetched_at
+	`
+
+	_, err = a.db.ExecContext(ctx, query, stockID, fundamentals.PERatio, fundamentals.EPS, fundamentals.DividendYield,
+		fundamentals.Beta, fundamentals.SharesOutstanding, fundamentals.Description, fundamentals.FetchedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save fundamentals for %s: %w", fundamentals.Symbol, err)
+	}
+
+	a.logger.Info("Saved fundamentals", "symbol", fundamentals.Symbol)
+	return nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return body
+}
+
+func TestParseOverviewResponse_MapsStringValues(t *testing.T) {
+	fetchedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	fundamentals, err := parseOverviewResponse("IBM", readFixture(t, "alphavantage_overview_ibm.json"), fetchedAt)
+
+	require.NoError(t, err)
+	assert.Equal(t, "IBM", fundamentals.Symbol)
+	require.NotNil(t, fundamentals.PERatio)
+	assert.Equal(t, 20.6, *fundamentals.PERatio)
+	require.NotNil(t, fundamentals.EPS)
+	assert.Equal(t, 8.97, *fundamentals.EPS)
+	require.NotNil(t, fundamentals.DividendYield)
+	assert.Equal(t, 0.0359, *fundamentals.DividendYield)
+	require.NotNil(t, fundamentals.Beta)
+	assert.Equal(t, 0.707, *fundamentals.Beta)
+	require.NotNil(t, fundamentals.SharesOutstanding)
+	assert.Equal(t, int64(918741000), *fundamentals.SharesOutstanding)
+	assert.Contains(t, fundamentals.Description, "Armonk")
+	assert.Equal(t, fetchedAt, fundamentals.FetchedAt)
+}
+
+func TestParseOverviewResponse_MissingValuesAreNil(t *testing.T) {
+	fundamentals, err := parseOverviewResponse("AMZN", readFixture(t, "alphavantage_overview_no_dividend.json"), time.Now())
+
+	require.NoError(t, err)
+	assert.Nil(t, fundamentals.PERatio, `"-"`)
+	assert.Nil(t, fundamentals.EPS, "empty string")
+	assert.Nil(t, fundamentals.DividendYield, `"None"`)
+	assert.Nil(t, fundamentals.SharesOutstanding, `"None"`)
+	assert.Empty(t, fundamentals.Description)
+	require.NotNil(t, fundamentals.Beta)
+	assert.Equal(t, 1.146, *fundamentals.Beta)
+}
+
+func TestParseOverviewResponse_Errors(t *testing.T) {
+	_, err := parseOverviewResponse("NOTREAL", []byte(`{}`), time.Now())
+	assert.ErrorIs(t, err, ErrInvalidSymbol)
+
+	_, err = parseOverviewResponse("IBM", []byte(`{"Note": "Thank you for using Alpha Vantage! Our standard API call frequency is 5 calls per minute."}`), time.Now())
+	assert.ErrorIs(t, err, ErrRateLimited)
+}
+
+// expectBudget expects the queries GetAPIBudget makes, with dailyUsed of 25 calls spent
+func expectBudget(mock sqlmock.Sqlmock, dailyUsed int) {
+	mock.ExpectQuery("FROM api_rate_limits").WillReturnRows(sqlmock.NewRows([]string{
+		"id", "service_name", "daily_limit", "hourly_limit", "current_daily_count",
+		"current_hourly_count", "last_reset_date", "last_reset_hour", "created_at", "updated_at",
+	}).AddRow(1, "alphavantage", 25, nil, dailyUsed, 0, marketDate(time.Now()), marketHour(time.Now()), time.Now(), time.Now()))
+	mock.ExpectQuery("SELECT MAX\\(created_at\\) FROM api_calls").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+}
+
+func TestRefreshFundamentalsIfDue_OncePerDay(t *testing.T) {
+	client, mock := newRetryTestClient(t, serveFixture(t, "alphavantage_overview_ibm.json", nil))
+	scheduler := NewSchedulerService(client.db, client, nil, nil, nil)
+
+	expectBudget(mock, 0)
+	mock.ExpectQuery("LEFT JOIN company_fundamentals").
+		WillReturnRows(sqlmock.NewRows([]string{"symbol"}).AddRow("IBM"))
+	expectRateLimitQuery(mock)
+	mock.ExpectExec("INSERT INTO api_calls").
+		WithArgs(ProviderAlphaVantage, "OVERVIEW", sqlmock.AnyArg(), 200, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), string(InitiatorScheduler), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRateLimitIncrement(mock)
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("IBM").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec("INSERT INTO company_fundamentals").
+		WithArgs(3, 20.6, 8.97, 0.0359, 0.707, int64(918741000), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	scheduler.refreshFundamentalsIfDue()
+	// Already refreshed today: no further queries
+	scheduler.refreshFundamentalsIfDue()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, scheduler.GetRecentActivity(1).RecentErrors)
+}
+
+func TestRefreshFundamentalsIfDue_LeavesBudgetForPrices(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	scheduler := NewSchedulerService(db, NewAlphaVantageClient("test-key", db), nil, nil, nil)
+
+	// Every call left today is needed for the hourly price syncs
+	remaining := 23 - time.Now().Hour()
+	expectBudget(mock, 25-remaining)
+
+	scheduler.refreshFundamentalsIfDue()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshFundamentalsIfDue_SkipsProvidersWithoutFundamentals(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	scheduler := NewSchedulerService(db, NewStooqClient(db), nil, nil, nil)
+	scheduler.refreshFundamentalsIfDue()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
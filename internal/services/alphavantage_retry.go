@@ -0,0 +1,49 @@
+package services
+
+import (
+	"bytes"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// DefaultMaxAttempts is how many times a single Alpha Vantage request is tried
+const DefaultMaxAttempts = 3
+
+// Default backoff between attempts; the delay doubles each retry up to the max
+const (
+	DefaultRetryBaseDelay = 1 * time.Second
+	DefaultRetryMaxDelay  = 10 * time.Second
+)
+
+// shouldRetry reports whether a failed attempt is worth repeating. Network errors,
+// 429 and 5xx are transient; other 4xx responses and the rate-limit "Note" body
+// will fail the same way again and only burn more of the budget.
+func shouldRetry(status int, body []byte, err error) bool {
+	if bytes.Contains(body, []byte(`"Note"`)) {
+		return false
+	}
+	if status == 0 {
+		return err != nil
+	}
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryDelay returns the backoff before retrying after the given attempt (1-based).
+// The exponential delay is capped at maxDelay, and the wait is drawn from its upper
+// half so concurrent syncs spread out without ever retrying immediately.
+func retryDelay(attempt int, baseDelay, maxDelay time.Duration) time.Duration {
+	delay := baseDelay
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRetryTestClient points a client with millisecond backoff at server
+func newRetryTestClient(t *testing.T, server *httptest.Server) (*AlphaVantageClient, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	client := NewAlphaVantageClient("test-key", db)
+	client.baseURL = server.URL
+	client.SetRetryPolicy(3, 10*time.Millisecond, 40*time.Millisecond)
+	return client, mock
+}
+
+// expectAttemptLogged expects an api_calls row for an attempt with the given status
+func expectAttemptLogged(mock sqlmock.Sqlmock, status int) {
+	mock.ExpectExec("INSERT INTO api_calls").
+		WithArgs(ProviderAlphaVantage, "TIME_SERIES_DAILY", sqlmock.AnyArg(), status, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), string(InitiatorManualSync), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func expectRateLimitIncrement(mock sqlmock.Sqlmock) {
+	expectRequestRecorded(mock, ProviderAlphaVantage)
+}
+
+func TestFetchDailyData_RetriesTransientFailures(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, dailySeriesFixture)
+	}))
+	defer server.Close()
+
+	client, mock := newRetryTestClient(t, server)
+	expectRateLimitQuery(mock)
+	expectAttemptLogged(mock, http.StatusServiceUnavailable)
+	expectAttemptLogged(mock, http.StatusServiceUnavailable)
+	expectAttemptLogged(mock, http.StatusOK)
+	// The rate limit counter moves once for the whole fetch
+	expectRateLimitIncrement(mock)
+
+	start := time.Now()
+	data, err := client.FetchDailyData(context.Background(), "AAPL", InitiatorManualSync)
+	elapsed := time.Since(start)
+
+	require.NoError(t, err)
+	assert.Len(t, data.Bars, 1)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Backoff waits at least half of 10ms then 20ms, and never more than 10ms + 20ms
+	assert.GreaterOrEqual(t, elapsed, 15*time.Millisecond)
+	assert.Less(t, elapsed, 500*time.Millisecond)
+}
+
+func TestFetchDailyData_RetriesTooManyRequests(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, dailySeriesFixture)
+	}))
+	defer server.Close()
+
+	client, mock := newRetryTestClient(t, server)
+	expectRateLimitQuery(mock)
+	expectAttemptLogged(mock, http.StatusTooManyRequests)
+	expectAttemptLogged(mock, http.StatusOK)
+	expectRateLimitIncrement(mock)
+
+	_, err := client.FetchDailyData(context.Background(), "AAPL", InitiatorManualSync)
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFetchDailyData_DoesNotRetryClientErrors(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client, mock := newRetryTestClient(t, server)
+	expectRateLimitQuery(mock)
+	expectAttemptLogged(mock, http.StatusBadRequest)
+	expectRateLimitIncrement(mock)
+
+	_, err := client.FetchDailyData(context.Background(), "AAPL", InitiatorManualSync)
+
+	require.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFetchDailyData_DoesNotRetryRateLimitNote(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `{"Note": "Thank you for using Alpha Vantage! Our standard API call frequency is 5 calls per minute."}`)
+	}))
+	defer server.Close()
+
+	client, mock := newRetryTestClient(t, server)
+	expectRateLimitQuery(mock)
+	expectAttemptLogged(mock, http.StatusOK)
+	expectRateLimitIncrement(mock)
+
+	_, err := client.FetchDailyData(context.Background(), "AAPL", InitiatorManualSync)
+
+	require.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFetchDailyData_GivesUpAfterMaxAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// A closed server makes every attempt a network error
+	server.Close()
+
+	client, mock := newRetryTestClient(t, server)
+	expectRateLimitQuery(mock)
+	expectAttemptLogged(mock, 0)
+	expectAttemptLogged(mock, 0)
+	expectAttemptLogged(mock, 0)
+	// Nothing reached the provider, so nothing is counted
+
+	_, err := client.FetchDailyData(context.Background(), "AAPL", InitiatorManualSync)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "request failed")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetryDelay(t *testing.T) {
+	base := 100 * time.Millisecond
+	maxDelay := 350 * time.Millisecond
+
+	for i := 0; i < 50; i++ {
+		first := retryDelay(1, base, maxDelay)
+		assert.GreaterOrEqual(t, first, 50*time.Millisecond)
+		assert.LessOrEqual(t, first, 100*time.Millisecond)
+
+		second := retryDelay(2, base, maxDelay)
+		assert.GreaterOrEqual(t, second, 100*time.Millisecond)
+		assert.LessOrEqual(t, second, 200*time.Millisecond)
+
+		// 400ms is capped to the max delay
+		capped := retryDelay(3, base, maxDelay)
+		assert.GreaterOrEqual(t, capped, 175*time.Millisecond)
+		assert.LessOrEqual(t, capped, maxDelay)
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	assert.True(t, shouldRetry(0, nil, fmt.Errorf("request failed")))
+	assert.True(t, shouldRetry(http.StatusTooManyRequests, nil, fmt.Errorf("status 429")))
+	assert.True(t, shouldRetry(http.StatusBadGateway, nil, fmt.Errorf("status 502")))
+	assert.False(t, shouldRetry(http.StatusNotFound, nil, fmt.Errorf("status 404")))
+	assert.False(t, shouldRetry(http.StatusServiceUnavailable, []byte(`{"Note": "call frequency"}`), fmt.Errorf("status 503")))
+}
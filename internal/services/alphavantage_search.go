@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SymbolSearchProvider is implemented by market data providers that can look
+// up listings by ticker or company name
+type SymbolSearchProvider interface {
+	SearchSymbols(ctx context.Context, keywords string, initiator Initiator) ([]SymbolMatch, error)
+}
+
+// SymbolMatch is one listing a symbol search found, best matches first
+type SymbolMatch struct {
+	Symbol     string  `json:"symbol"`
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Region     string  `json:"region"`
+	Currency   string  `json:"currency"`
+	MatchScore float64 `json:"match_score"`
+}
+
+// AlphaVantageSymbolSearchResponse is the SYMBOL_SEARCH payload
+type AlphaVantageSymbolSearchResponse struct {
+	BestMatches []struct {
+		Symbol     string `json:"1. symbol"`
+		Name       string `json:"2. name"`
+		Type       string `json:"3. type"`
+		Region     string `json:"4. region"`
+		Currency   string `json:"8. currency"`
+		MatchScore string `json:"9. matchScore"`
+	} `json:"bestMatches"`
+}
+
+// SearchSymbols looks up listings matching keywords. Each search spends one
+// call of the API budget, like any other fetch.
+func (a *AlphaVantageClient) SearchSymbols(ctx context.Context, keywords string, initiator Initiator) ([]SymbolMatch, error) {
+	if err := initiator.Validate(); err != nil {
+		return nil, err
+	}
+
+	canMake, err := a.CanMakeRequest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	if !canMake {
+		return nil, &APIError{Provider: a.serviceName, Kind: APIErrorRateLimited, Symbol: keywords, Message: "local API budget exhausted"}
+	}
+
+	params := map[string]string{
+		"function": "SYMBOL_SEARCH",
+		"keywords": keywords,
+		"apikey":   a.apiKey,
+	}
+
+	response, err := a.makeRequest(ctx, initiator, "SYMBOL_SEARCH", a.baseURL, params)
+	if err != nil {
+		a.logger.Error("Alpha Vantage API error", "keywords", keywords, "error", err)
+		return nil, err
+	}
+
+	return parseSymbolSearchResponse(keywords, response)
+}
+
+// parseSymbolSearchResponse maps a SYMBOL_SEARCH body onto matches. A search
+// without matches is an empty list, not an error.
+func parseSymbolSearchResponse(keywords string, body []byte) ([]SymbolMatch, error) {
+	var searchResponse AlphaVantageSymbolSearchResponse
+	if err := json.Unmarshal(body, &searchResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse Alpha Vantage response: %w", err)
+	}
+
+	if len(searchResponse.BestMatches) == 0 {
+		if err := classifyErrorBody(keywords, body); err != nil {
+			return nil, err
+		}
+	}
+
+	matches := make([]SymbolMatch, 0, len(searchResponse.BestMatches))
+	for _, entry := range searchResponse.BestMatches {
+		score, _ := strconv.ParseFloat(strings.TrimSpace(entry.MatchScore), 64)
+		matches = append(matches, SymbolMatch{
+			Symbol:     strings.TrimSpace(entry.Symbol),
+			Name:       strings.TrimSpace(entry.Name),
+			Type:       strings.TrimSpace(entry.Type),
+			Region:     strings.TrimSpace(entry.Region),
+			Currency:   strings.TrimSpace(entry.Currency),
+			MatchScore: score,
+		})
+	}
+	return matches, nil
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchSymbols_ParsesRecordedJSON(t *testing.T) {
+	var requests []*http.Request
+	client, mock := newRetryTestClient(t, serveFixture(t, "alphavantage_symbol_search_tesco.json", &requests))
+	expectRateLimitQuery(mock)
+	mock.ExpectExec("INSERT INTO api_calls").
+		WithArgs(ProviderAlphaVantage, "SYMBOL_SEARCH", sqlmock.AnyArg(), http.StatusOK, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), string(InitiatorAdmin), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRateLimitIncrement(mock)
+
+	matches, err := client.SearchSymbols(context.Background(), "tesco", InitiatorAdmin)
+
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	assert.Equal(t, SymbolMatch{
+		Symbol: "TSCO.LON", Name: "Tesco PLC", Type: "Equity", Region: "United Kingdom", Currency: "GBX", MatchScore: 0.7273,
+	}, matches[0])
+	require.Len(t, requests, 1)
+	assert.Equal(t, "SYMBOL_SEARCH", requests[0].URL.Query().Get("function"))
+	assert.Equal(t, "tesco", requests[0].URL.Query().Get("keywords"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParseSymbolSearchResponse(t *testing.T) {
+	matches, err := parseSymbolSearchResponse("zzzz", []byte(`{"bestMatches": []}`))
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+	assert.NotNil(t, matches, "no matches is an empty list")
+
+	_, err = parseSymbolSearchResponse("tesco", []byte(`{"Note": "Thank you for using Alpha Vantage! Our standard API call frequency is 5 calls per minute."}`))
+	assert.ErrorIs(t, err, ErrRateLimited)
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// MaxCorrelationSymbols caps how many symbols one correlation matrix may cover;
+// the pairs, and the response, grow with its square
+const MaxCorrelationSymbols = 10
+
+// MinCorrelationObservations is how many overlapping daily returns a pair needs
+// before its correlation, or a beta, means anything
+const MinCorrelationObservations = 30
+
+// Reasons a pair's correlation, or a beta, is null
+const (
+	ReasonInsufficientOverlap = "insufficient_overlap"
+	ReasonConstantReturns     = "constant_returns"
+)
+
+// CorrelationPair is the Pearson correlation of two stocks' daily returns over
+// the dates both have closes for. Correlation is nil, with a Reason, when the
+// pair has fewer than MinCorrelationObservations returns or one never moves.
+type CorrelationPair struct {
+	Symbols      [2]string `json:"symbols"`
+	Correlation  *float64  `json:"correlation"`
+	Observations int       `json:"observations"`
+	Reason       string    `json:"reason,omitempty"`
+}
+
+// CorrelationMatrix holds the correlation of every pair of Symbols. Matrix is
+// indexed in Symbols order; Pairs lists each pair above the diagonal once.
+type CorrelationMatrix struct {
+	Symbols []string          `json:"symbols"`
+	Matrix  [][]*float64      `json:"matrix"`
+	Pairs   []CorrelationPair `json:"pairs"`
+}
+
+// AlignedReturns returns the daily returns of a and b, oldest first, between
+// consecutive dates on which both have a positive close. A day missing from
+// either series folds into the next return of both, so each pair of returns
+// covers the same period.
+func AlignedReturns(a, b []models.PricePoint) (aReturns, bReturns []float64) {
+	bCloses := make(map[time.Time]float64, len(b))
+	for _, point := range b {
+		if point.Price > 0 {
+			bCloses[point.Date] = point.Price
+		}
+	}
+
+	type closes struct {
+		date time.Time
+		a, b float64
+	}
+	var common []closes
+	for _, point := range a {
+		if bClose, ok := bCloses[point.Date]; ok && point.Price > 0 {
+			common = append(common, closes{point.Date, point.Price, bClose})
+		}
+	}
+	sort.Slice(common, func(i, j int) bool { return common[i].date.Before(common[j].date) })
+
+	for i := 1; i < len(common); i++ {
+		if common[i].date.Equal(common[i-1].date) {
+			continue
+		}
+		aReturns = append(aReturns, common[i].a/common[i-1].a-1)
+		bReturns = append(bReturns, common[i].b/common[i-1].b-1)
+	}
+	return aReturns, bReturns
+}
+
+// comoments returns the sums of products of deviations from the mean that
+// covariance and variance are built from; x and y must be equally long
+func comoments(x, y []float64) (covariance, varianceX, varianceY float64) {
+	var meanX, meanY float64
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= float64(len(x))
+	meanY /= float64(len(y))
+
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		covariance += dx * dy
+		varianceX += dx * dx
+		varianceY += dy * dy
+	}
+	return covariance, varianceX, varianceY
+}
+
+// PearsonCorrelation returns the correlation of two equally long series, and
+// false when it is undefined: fewer than two values, or a series without variance
+func PearsonCorrelation(x, y []float64) (float64, bool) {
+	if len(x) != len(y) || len(x) < 2 {
+		return 0, false
+	}
+
+	covariance, varianceX, varianceY := comoments(x, y)
+	if varianceX == 0 || varianceY == 0 {
+		return 0, false
+	}
+
+	// Rounding can carry a perfect correlation just past ±1
+	return math.Max(-1, math.Min(1, covariance/math.Sqrt(varianceX*varianceY))), true
+}
+
+// CorrelatePair correlates the daily returns of a and b, needing at least
+// minObservations aligned returns
+func CorrelatePair(a, b []models.PricePoint, minObservations int) (correlation *float64, observations int, reason string) {
+	aReturns, bReturns := AlignedReturns(a, b)
+	if len(aReturns) < minObservations {
+		return nil, len(aReturns), ReasonInsufficientOverlap
+	}
+	value, ok := PearsonCorrelation(aReturns, bReturns)
+	if !ok {
+		return nil, len(aReturns), ReasonConstantReturns
+	}
+	return &value, len(aReturns), ""
+}
+
+// BuildCorrelationMatrix correlates every pair of symbols from their closes in
+// series. A symbol's correlation with itself is 1 when it has enough returns
+// to be correlated with anything, and null otherwise.
+func BuildCorrelationMatrix(symbols []string, series map[string][]models.PricePoint, minObservations int) *CorrelationMatrix {
+	matrix := &CorrelationMatrix{
+		Symbols: symbols,
+		Matrix:  make([][]*float64, len(symbols)),
+		Pairs:   []CorrelationPair{},
+	}
+	for i := range symbols {
+		matrix.Matrix[i] = make([]*float64, len(symbols))
+	}
+
+	for i, first := range symbols {
+		self, _, _ := CorrelatePair(series[first], series[first], minObservations)
+		matrix.Matrix[i][i] = self
+
+		for j := i + 1; j < len(symbols); j++ {
+			second := symbols[j]
+			correlation, observations, reason := CorrelatePair(series[first], series[second], minObservations)
+			matrix.Matrix[i][j], matrix.Matrix[j][i] = correlation, correlation
+			matrix.Pairs = append(matrix.Pairs, CorrelationPair{
+				Symbols:      [2]string{first, second},
+				Correlation:  correlation,
+				Observations: observations,
+				Reason:       reason,
+			})
+		}
+	}
+	return matrix
+}
+
+// BetaEstimate is a stock's beta against a benchmark, the covariance of their
+// daily returns over the benchmark's variance, and the R² of that fit. Both are
+// nil, with a Reason, when there are fewer than MinCorrelationObservations
+// overlapping returns or either series never moves.
+type BetaEstimate struct {
+	Beta         *float64 `json:"beta"`
+	RSquared     *float64 `json:"r_squared"`
+	Observations int      `json:"observations"`
+	Reason       string   `json:"reason,omitempty"`
+}
+
+// EstimateBeta regresses the daily returns of stock on those of benchmark,
+// aligned as AlignedReturns does, needing at least minObservations returns
+func EstimateBeta(stock, benchmark []models.PricePoint, minObservations int) BetaEstimate {
+	stockReturns, benchmarkReturns := AlignedReturns(stock, benchmark)
+	estimate := BetaEstimate{Observations: len(stockReturns)}
+	if len(stockReturns) < minObservations || len(stockReturns) < 2 {
+		estimate.Reason = ReasonInsufficientOverlap
+		return estimate
+	}
+
+	covariance, stockVariance, benchmarkVariance := comoments(stockReturns, benchmarkReturns)
+	if stockVariance == 0 || benchmarkVariance == 0 {
+		estimate.Reason = ReasonConstantReturns
+		return estimate
+	}
+
+	beta := covariance / benchmarkVariance
+	rSquared := math.Min(1, covariance*covariance/(stockVariance*benchmarkVariance))
+	estimate.Beta, estimate.RSquared = &beta, &rSquared
+	return estimate
+}
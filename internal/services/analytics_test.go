@@ -0,0 +1,218 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dailySeries closes price(i) on the i-th day of 2026 for each i in [0, n)
+// that skip does not reject
+func dailySeries(n int, price func(i int) float64, skip ...int) []models.PricePoint {
+	skipped := make(map[int]bool)
+	for _, i := range skip {
+		skipped[i] = true
+	}
+	var points []models.PricePoint
+	for i := 0; i < n; i++ {
+		if !skipped[i] {
+			points = append(points, models.PricePoint{Date: time.Date(2026, time.January, 1+i, 0, 0, 0, 0, time.UTC), Price: price(i)})
+		}
+	}
+	return points
+}
+
+// The reference correlations below were computed offline with Python's
+// statistics.correlation on the same series
+func sineSeries(i int) float64   { return 100 + 10*math.Sin(float64(i)) + float64(i) }
+func cosineSeries(i int) float64 { return 50 + 5*math.Cos(0.7*float64(i)) + 0.3*float64(i) }
+
+func TestAlignedReturns_SpansDaysMissingFromEitherSeries(t *testing.T) {
+	a := []models.PricePoint{
+		{Date: date2026(time.March, 2), Price: 100},
+		{Date: date2026(time.March, 3), Price: 110},
+		{Date: date2026(time.March, 4), Price: 121},
+		{Date: date2026(time.March, 5), Price: 0}, // corrupt close
+		{Date: date2026(time.March, 6), Price: 133.1},
+	}
+	// Unordered, and without March 3
+	b := []models.PricePoint{
+		{Date: date2026(time.March, 6), Price: 45},
+		{Date: date2026(time.March, 2), Price: 50},
+		{Date: date2026(time.March, 4), Price: 40},
+		{Date: date2026(time.March, 5), Price: 42},
+	}
+
+	aReturns, bReturns := AlignedReturns(a, b)
+	require.Len(t, aReturns, 2)
+	require.Len(t, bReturns, 2)
+	assert.InDelta(t, 0.21, aReturns[0], 1e-12, "March 2 to 4")
+	assert.InDelta(t, -0.2, bReturns[0], 1e-12, "March 2 to 4")
+	assert.InDelta(t, 0.1, aReturns[1], 1e-12, "March 4 to 6")
+	assert.InDelta(t, 0.125, bReturns[1], 1e-12, "March 4 to 6")
+}
+
+func TestPearsonCorrelation(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y []float64
+		want float64
+		ok   bool
+	}{
+		{
+			name: "reference returns",
+			x:    []float64{0.012, -0.004, 0.021, -0.015, 0.003, 0.008},
+			y:    []float64{0.010, -0.001, 0.015, -0.020, 0.001, 0.012},
+			want: 0.9486275867111013,
+			ok:   true,
+		},
+		{name: "perfectly correlated", x: []float64{1, 2, 3}, y: []float64{2, 4, 6}, want: 1, ok: true},
+		{name: "perfectly anticorrelated", x: []float64{1, 2, 3}, y: []float64{3, 2, 1}, want: -1, ok: true},
+		{name: "constant series", x: []float64{1, 2, 3}, y: []float64{5, 5, 5}},
+		{name: "single value", x: []float64{1}, y: []float64{2}},
+		{name: "unequal lengths", x: []float64{1, 2, 3}, y: []float64{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := PearsonCorrelation(tt.x, tt.y)
+			assert.Equal(t, tt.ok, ok)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
+
+func TestBuildCorrelationMatrix(t *testing.T) {
+	series := map[string][]models.PricePoint{
+		// Day 10 is missing from MSFT, so the pair overlaps on 38 returns
+		"AAPL": dailySeries(40, sineSeries),
+		"MSFT": dailySeries(40, cosineSeries, 10),
+		// Listed on day 20
+		"NEWCO": dailySeries(40, sineSeries)[20:],
+		"FLAT":  dailySeries(40, func(int) float64 { return 10 }),
+	}
+	symbols := []string{"AAPL", "MSFT", "NEWCO", "FLAT", "NODATA"}
+
+	matrix := BuildCorrelationMatrix(symbols, series, MinCorrelationObservations)
+	require.Len(t, matrix.Matrix, len(symbols))
+	require.Len(t, matrix.Pairs, len(symbols)*(len(symbols)-1)/2)
+
+	pair := matrix.Pairs[0]
+	assert.Equal(t, [2]string{"AAPL", "MSFT"}, pair.Symbols)
+	assert.Equal(t, 38, pair.Observations)
+	require.NotNil(t, pair.Correlation)
+	assert.InDelta(t, 0.08132268077117799, *pair.Correlation, 1e-9)
+	assert.Empty(t, pair.Reason)
+	assert.Same(t, matrix.Matrix[0][1], matrix.Matrix[1][0])
+
+	// The matrix mirrors the pairs, with 1 on the diagonal for stocks with enough history
+	require.NotNil(t, matrix.Matrix[0][0])
+	assert.InDelta(t, 1, *matrix.Matrix[0][0], 1e-12)
+	assert.Nil(t, matrix.Matrix[2][2])
+	assert.Nil(t, matrix.Matrix[4][4])
+
+	reasons := make(map[[2]string]CorrelationPair)
+	for _, pair := range matrix.Pairs {
+		reasons[pair.Symbols] = pair
+	}
+	assert.Equal(t, CorrelationPair{Symbols: [2]string{"AAPL", "NEWCO"}, Observations: 19, Reason: ReasonInsufficientOverlap},
+		reasons[[2]string{"AAPL", "NEWCO"}])
+	assert.Equal(t, CorrelationPair{Symbols: [2]string{"AAPL", "FLAT"}, Observations: 39, Reason: ReasonConstantReturns},
+		reasons[[2]string{"AAPL", "FLAT"}])
+	assert.Equal(t, CorrelationPair{Symbols: [2]string{"FLAT", "NODATA"}, Reason: ReasonInsufficientOverlap},
+		reasons[[2]string{"FLAT", "NODATA"}])
+	assert.Nil(t, matrix.Matrix[1][3])
+}
+
+// leveraged returns a series whose every daily return is factor times that of points
+func leveraged(points []models.PricePoint, factor float64) []models.PricePoint {
+	series := make([]models.PricePoint, len(points))
+	for i, point := range points {
+		series[i] = models.PricePoint{Date: point.Date, Price: 100}
+		if i > 0 {
+			series[i].Price = series[i-1].Price * (1 + factor*(point.Price/points[i-1].Price-1))
+		}
+	}
+	return series
+}
+
+func TestEstimateBeta(t *testing.T) {
+	benchmark := dailySeries(40, sineSeries)
+
+	tests := []struct {
+		name         string
+		stock        []models.PricePoint
+		benchmark    []models.PricePoint
+		beta         *float64
+		rSquared     *float64
+		observations int
+		reason       string
+	}{
+		{
+			name:         "stock is the benchmark",
+			stock:        benchmark,
+			benchmark:    benchmark,
+			beta:         floatPtr(1),
+			rSquared:     floatPtr(1),
+			observations: 39,
+		},
+		{
+			name:         "twice the benchmark's moves",
+			stock:        leveraged(benchmark, 2),
+			benchmark:    benchmark,
+			beta:         floatPtr(2),
+			rSquared:     floatPtr(1),
+			observations: 39,
+		},
+		{
+			// Reference values from Python's statistics module; MSFT is missing day 10
+			name:         "reference series",
+			stock:        benchmark,
+			benchmark:    dailySeries(40, cosineSeries, 10),
+			beta:         floatPtr(0.10796010357177006),
+			rSquared:     floatPtr(0.006613378407810923),
+			observations: 38,
+		},
+		{
+			name:         "too little overlap",
+			stock:        benchmark[25:],
+			benchmark:    benchmark,
+			observations: 14,
+			reason:       ReasonInsufficientOverlap,
+		},
+		{
+			name:   "no benchmark prices",
+			stock:  benchmark,
+			reason: ReasonInsufficientOverlap,
+		},
+		{
+			name:         "flat benchmark",
+			stock:        benchmark,
+			benchmark:    dailySeries(40, func(int) float64 { return 10 }),
+			observations: 39,
+			reason:       ReasonConstantReturns,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate := EstimateBeta(tt.stock, tt.benchmark, MinCorrelationObservations)
+			assert.Equal(t, tt.observations, estimate.Observations)
+			assert.Equal(t, tt.reason, estimate.Reason)
+			if tt.beta == nil {
+				assert.Nil(t, estimate.Beta)
+				assert.Nil(t, estimate.RSquared)
+				return
+			}
+			require.NotNil(t, estimate.Beta)
+			require.NotNil(t, estimate.RSquared)
+			assert.InDelta(t, *tt.beta, *estimate.Beta, 1e-9)
+			assert.InDelta(t, *tt.rSquared, *estimate.RSquared, 1e-9)
+		})
+	}
+}
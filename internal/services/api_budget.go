@@ -0,0 +1,99 @@
+package services
+
+import (
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// DefaultCallsPerMinute matches the Alpha Vantage free tier burst limit
+const DefaultCallsPerMinute = 5
+
+// APIBudget is the remaining API allowance with counters rolled over to the current
+// hour and day, plus when the next call may be made under the pacing configuration
+type APIBudget struct {
+	DailyLimit        int        `json:"daily_limit"`
+	DailyUsed         int        `json:"daily_used"`
+	DailyRemaining    int        `json:"daily_remaining"`
+	HourlyLimit       *int       `json:"hourly_limit"`
+	HourlyUsed        int        `json:"hourly_used"`
+	HourlyRemaining   *int       `json:"hourly_remaining"`
+	CallsPerMinute    int        `json:"calls_per_minute"`
+	LastCallAt        *time.Time `json:"last_call_at"`
+	NextCallAllowedAt time.Time  `json:"next_call_allowed_at"`
+	CanMakeRequest    bool       `json:"can_make_request"`
+	LimitedBy         string     `json:"limited_by,omitempty"`
+}
+
+// ComputeAPIBudget derives the budget at now from stored rate limit counters.
+// The database only resets counters when the row is next written, so counters from a
+// previous hour or day are treated as zero here rather than as still being spent.
+func ComputeAPIBudget(rateLimit *models.APIRateLimit, lastCallAt *time.Time, callsPerMinute int, now time.Time) APIBudget {
+	if callsPerMinute <= 0 {
+		callsPerMinute = DefaultCallsPerMinute
+	}
+
+	dailyUsed := rateLimit.CurrentDailyCount
+	hourlyUsed := rateLimit.CurrentHourlyCount
+
+	// Counters roll over on the Eastern-time day and hour, as they are recorded
+	local := now.In(marketLocation)
+	year, month, day := local.Date()
+	resetYear, resetMonth, resetDay := rateLimit.LastResetDate.Date()
+	sameDay := resetYear == year && resetMonth == month && resetDay == day
+	if !sameDay {
+		dailyUsed = 0
+		hourlyUsed = 0
+	} else if rateLimit.LastResetHour < local.Hour() {
+		hourlyUsed = 0
+	}
+
+	budget := APIBudget{
+		DailyLimit:        rateLimit.DailyLimit,
+		DailyUsed:         dailyUsed,
+		DailyRemaining:    max(rateLimit.DailyLimit-dailyUsed, 0),
+		HourlyLimit:       rateLimit.HourlyLimit,
+		HourlyUsed:        hourlyUsed,
+		CallsPerMinute:    callsPerMinute,
+		LastCallAt:        lastCallAt,
+		NextCallAllowedAt: now,
+		CanMakeRequest:    true,
+	}
+
+	if rateLimit.HourlyLimit != nil {
+		remaining := max(*rateLimit.HourlyLimit-hourlyUsed, 0)
+		budget.HourlyRemaining = &remaining
+	}
+
+	// Pacing: space calls evenly across the minute
+	if lastCallAt != nil {
+		paced := lastCallAt.Add(time.Minute / time.Duration(callsPerMinute))
+		if paced.After(budget.NextCallAllowedAt) {
+			budget.NextCallAllowedAt = paced
+			budget.LimitedBy = "pacing"
+		}
+	}
+
+	if budget.HourlyRemaining != nil && *budget.HourlyRemaining == 0 {
+		budget.CanMakeRequest = false
+		budget.NextCallAllowedAt = time.Date(year, month, day, local.Hour()+1, 0, 0, 0, marketLocation).In(now.Location())
+		budget.LimitedBy = "hourly"
+	}
+
+	if budget.DailyRemaining == 0 {
+		budget.CanMakeRequest = false
+		budget.NextCallAllowedAt = time.Date(year, month, day+1, 0, 0, 0, 0, marketLocation).In(now.Location())
+		budget.LimitedBy = "daily"
+	}
+
+	return budget
+}
+
+// CallsAvailable returns how many calls can be made before hitting the daily or hourly limit
+func (b APIBudget) CallsAvailable() int {
+	available := b.DailyRemaining
+	if b.HourlyRemaining != nil && *b.HourlyRemaining < available {
+		available = *b.HourlyRemaining
+	}
+	return available
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestComputeAPIBudget_HourRollover(t *testing.T) {
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	rateLimit := &models.APIRateLimit{
+		DailyLimit:         25,
+		HourlyLimit:        intPtr(5),
+		CurrentDailyCount:  12,
+		CurrentHourlyCount: 5,
+		LastResetDate:      day,
+		LastResetHour:      13,
+	}
+
+	// Last second of the hour: hourly budget spent, wait for the top of the hour
+	justBefore := time.Date(2026, 3, 10, 13, 59, 59, 0, marketLocation)
+	budget := ComputeAPIBudget(rateLimit, nil, 5, justBefore)
+	assert.False(t, budget.CanMakeRequest)
+	assert.Equal(t, 5, budget.HourlyUsed)
+	assert.Equal(t, 0, *budget.HourlyRemaining)
+	assert.Equal(t, 13, budget.DailyRemaining)
+	assert.Equal(t, "hourly", budget.LimitedBy)
+	assert.Equal(t, time.Date(2026, 3, 10, 14, 0, 0, 0, marketLocation), budget.NextCallAllowedAt)
+	assert.Equal(t, 0, budget.CallsAvailable())
+
+	// Top of the next hour: stored counters are stale and no longer count
+	onTheHour := time.Date(2026, 3, 10, 14, 0, 0, 0, marketLocation)
+	budget = ComputeAPIBudget(rateLimit, nil, 5, onTheHour)
+	assert.True(t, budget.CanMakeRequest)
+	assert.Equal(t, 0, budget.HourlyUsed)
+	assert.Equal(t, 5, *budget.HourlyRemaining)
+	assert.Equal(t, 12, budget.DailyUsed)
+	assert.Equal(t, onTheHour, budget.NextCallAllowedAt)
+	assert.Equal(t, 5, budget.CallsAvailable())
+}
+
+func TestComputeAPIBudget_DayRollover(t *testing.T) {
+	rateLimit := &models.APIRateLimit{
+		DailyLimit:         25,
+		CurrentDailyCount:  25,
+		CurrentHourlyCount: 3,
+		LastResetDate:      time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC),
+		LastResetHour:      23,
+	}
+
+	lateEvening := time.Date(2026, 3, 10, 23, 30, 0, 0, marketLocation)
+	budget := ComputeAPIBudget(rateLimit, nil, 5, lateEvening)
+	assert.False(t, budget.CanMakeRequest)
+	assert.Equal(t, "daily", budget.LimitedBy)
+	assert.Nil(t, budget.HourlyRemaining)
+	assert.Equal(t, time.Date(2026, 3, 11, 0, 0, 0, 0, marketLocation), budget.NextCallAllowedAt)
+
+	// After midnight the last reset hour (23) is greater than the current hour (0),
+	// but the date change still clears both counters
+	afterMidnight := time.Date(2026, 3, 11, 0, 0, 1, 0, marketLocation)
+	budget = ComputeAPIBudget(rateLimit, nil, 5, afterMidnight)
+	assert.True(t, budget.CanMakeRequest)
+	assert.Equal(t, 0, budget.DailyUsed)
+	assert.Equal(t, 0, budget.HourlyUsed)
+	assert.Equal(t, 25, budget.DailyRemaining)
+}
+
+func TestComputeAPIBudget_EasternDayAcrossUTCMidnight(t *testing.T) {
+	// Counters last written at 22:00 Eastern on March 10, which is 02:00 UTC on March 11
+	rateLimit := &models.APIRateLimit{
+		DailyLimit:         25,
+		CurrentDailyCount:  25,
+		CurrentHourlyCount: 4,
+		LastResetDate:      time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC),
+		LastResetHour:      22,
+	}
+
+	// Past midnight UTC it is still March 10 in New York, so the day's calls stay spent
+	afterUTCMidnight := time.Date(2026, 3, 11, 2, 30, 0, 0, time.UTC)
+	budget := ComputeAPIBudget(rateLimit, nil, 5, afterUTCMidnight)
+	assert.False(t, budget.CanMakeRequest)
+	assert.Equal(t, 25, budget.DailyUsed)
+	assert.Equal(t, 4, budget.HourlyUsed)
+	assert.Equal(t, "daily", budget.LimitedBy)
+	// Midnight Eastern (EDT), given in the caller's zone
+	assert.Equal(t, time.Date(2026, 3, 11, 4, 0, 0, 0, time.UTC), budget.NextCallAllowedAt)
+
+	// Midnight Eastern starts a new day
+	budget = ComputeAPIBudget(rateLimit, nil, 5, time.Date(2026, 3, 11, 4, 0, 1, 0, time.UTC))
+	assert.True(t, budget.CanMakeRequest)
+	assert.Equal(t, 0, budget.DailyUsed)
+	assert.Equal(t, 0, budget.HourlyUsed)
+}
+
+func TestComputeAPIBudget_Pacing(t *testing.T) {
+	now := time.Date(2026, 3, 10, 9, 30, 0, 0, time.UTC)
+	rateLimit := &models.APIRateLimit{
+		DailyLimit:    25,
+		LastResetDate: now,
+		LastResetHour: 9,
+	}
+
+	// 5 calls per minute means one call every 12 seconds
+	lastCall := now.Add(-5 * time.Second)
+	budget := ComputeAPIBudget(rateLimit, &lastCall, 5, now)
+	assert.True(t, budget.CanMakeRequest)
+	assert.Equal(t, "pacing", budget.LimitedBy)
+	assert.Equal(t, now.Add(7*time.Second), budget.NextCallAllowedAt)
+
+	// Long enough ago: allowed immediately
+	lastCall = now.Add(-time.Minute)
+	budget = ComputeAPIBudget(rateLimit, &lastCall, 5, now)
+	assert.Equal(t, now, budget.NextCallAllowedAt)
+	assert.Empty(t, budget.LimitedBy)
+
+	// Unset pacing falls back to the default
+	budget = ComputeAPIBudget(rateLimit, nil, 0, now)
+	assert.Equal(t, DefaultCallsPerMinute, budget.CallsPerMinute)
+}
+
+func TestCanMakeRequest_RespectsHourlyLimit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now().In(marketLocation)
+	mock.ExpectQuery("FROM api_rate_limits").WillReturnRows(sqlmock.NewRows([]string{
+		"id", "service_name", "daily_limit", "hourly_limit", "current_daily_count",
+		"current_hourly_count", "last_reset_date", "last_reset_hour",
+	}).AddRow(1, "alphavantage", 25, 5, 5, 5, marketDate(now), now.Hour()))
+
+	client := NewAlphaVantageClient("test-key", db)
+	canMake, err := client.CanMakeRequest()
+	require.NoError(t, err)
+	assert.False(t, canMake)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
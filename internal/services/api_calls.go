@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultMaxStoredBodyBytes is how much of a provider's response body an
+// api_calls row keeps. A full daily series runs to hundreds of KB, and the
+// start of the body is enough to see what went wrong.
+const DefaultMaxStoredBodyBytes = 2048
+
+// API call statuses a listing can be narrowed to, as counted by the API call stats
+const (
+	// APICallStatusSuccess is a call the provider answered with 200
+	APICallStatusSuccess = "success"
+	// APICallStatusFailed is a call answered with 400 or above, or never answered
+	APICallStatusFailed = "failed"
+)
+
+// APICallStatuses lists the statuses accepted by APICallFilter
+var APICallStatuses = []string{APICallStatusSuccess, APICallStatusFailed}
+
+// apiCallStatusConditions are the SQL conditions of each status
+var apiCallStatusConditions = map[string]string{
+	APICallStatusSuccess: "response_status = 200",
+	APICallStatusFailed:  "(response_status >= 400 OR response_status = 0)",
+}
+
+// APICallRecord is one logged call to the market data provider
+type APICallRecord struct {
+	ID       int64  `json:"id"`
+	Endpoint string `json:"endpoint"`
+	// RequestParams are the query parameters sent, without the API key
+	RequestParams  map[string]string `json:"request_params,omitempty"`
+	ResponseStatus int               `json:"response_status"`
+	// ResponseBody is the start of the body, cut to the configured maximum
+	ResponseBody string `json:"response_body,omitempty"`
+	// ResponseBodyLength is the size in bytes of the whole body; nil for
+	// calls logged before it was recorded
+	ResponseBodyLength *int      `json:"response_body_length,omitempty"`
+	Truncated          bool      `json:"truncated"`
+	ErrorMessage       string    `json:"error_message,omitempty"`
+	ProcessingTimeMs   int       `json:"processing_time_ms"`
+	InitiatedBy        Initiator `json:"initiated_by"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// APICallFilter narrows an API call listing; zero values do not filter
+type APICallFilter struct {
+	// Status is one of APICallStatuses
+	Status   string
+	Endpoint string
+	Limit    int
+	Offset   int
+}
+
+// redactedParams are request parameters never returned by a listing
+var redactedParams = []string{"apikey"}
+
+// truncateBody cuts body to at most max bytes, backing off to the start of
+// a character so a multi-byte one is never split
+func truncateBody(body string, max int) string {
+	if len(body) <= max {
+		return body
+	}
+	cut := max
+	for cut > 0 && max-cut < utf8.UTFMax && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return body[:cut]
+}
+
+// ListAPICalls returns a page of the provider's logged calls, newest first,
+// with the total matching the filter
+func (u *providerUsage) ListAPICalls(ctx context.Context, filter APICallFilter) ([]APICallRecord, int, error) {
+	args := []interface{}{u.serviceName}
+	conditions := []string{"service_name = $1"}
+	if filter.Endpoint != "" {
+		args = append(args, filter.Endpoint)
+		conditions = append(conditions, fmt.Sprintf("endpoint = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		condition, ok := apiCallStatusConditions[filter.Status]
+		if !ok {
+			return nil, 0, fmt.Errorf("unknown API call status %q", filter.Status)
+		}
+		conditions = append(conditions, condition)
+	}
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := u.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM api_calls`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count API calls: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, endpoint, request_params, response_status, COALESCE(response_body, ''),
+		       response_body_length, COALESCE(error_message, ''), processing_time_ms,
+		       initiated_by, created_at
+		FROM api_calls%s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+	rows, err := u.db.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list API calls: %w", err)
+	}
+	defer rows.Close()
+
+	calls := []APICallRecord{}
+	for rows.Next() {
+		var call APICallRecord
+		var params []byte
+		var bodyLength *int
+		var initiatedBy string
+		if err := rows.Scan(&call.ID, &call.Endpoint, &params, &call.ResponseStatus, &call.ResponseBody,
+			&bodyLength, &call.ErrorMessage, &call.ProcessingTimeMs, &initiatedBy, &call.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan API call: %w", err)
+		}
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &call.RequestParams); err != nil {
+				return nil, 0, fmt.Errorf("failed to decode parameters of API call %d: %w", call.ID, err)
+			}
+			for _, name := range redactedParams {
+				delete(call.RequestParams, name)
+			}
+		}
+		call.ResponseBodyLength = bodyLength
+		call.Truncated = bodyLength != nil && *bodyLength > len(call.ResponseBody)
+		call.InitiatedBy = Initiator(initiatedBy)
+		calls = append(calls, call)
+	}
+	return calls, total, rows.Err()
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateBody_KeepsValidUTF8(t *testing.T) {
+	assert.Equal(t, "short", truncateBody("short", 10))
+	assert.Equal(t, "exact", truncateBody("exact", 5))
+	assert.Equal(t, "abc", truncateBody("abcdef", 3))
+
+	// "é" is two bytes and "€" three; a cut inside either backs off before it
+	assert.Equal(t, "ab", truncateBody("abé", 3))
+	assert.Equal(t, "abé", truncateBody("abé€", 5))
+	assert.Equal(t, "abé", truncateBody("abé€", 6))
+	assert.Equal(t, "abé€", truncateBody("abé€x", 7))
+
+	body := strings.Repeat("日本語", 1000)
+	for max := 1; max <= 12; max++ {
+		truncated := truncateBody(body, max)
+		assert.True(t, utf8.ValidString(truncated), "max %d", max)
+		assert.LessOrEqual(t, len(truncated), max)
+		assert.Greater(t, len(truncated), max-utf8.UTFMax)
+	}
+}
+
+func TestLogAPICall_TruncatesStoredBody(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	usage := newProviderUsage(ProviderAlphaVantage, db, 5)
+	usage.SetMaxStoredBodyBytes(8)
+
+	// The eighth byte is inside the first "€", so only the six before it are kept
+	body := `{"q":"€€€"}`
+	mock.ExpectExec("INSERT INTO api_calls").
+		WithArgs(ProviderAlphaVantage, "TIME_SERIES_DAILY", sqlmock.AnyArg(), http.StatusOK,
+			`{"q":"`, "", 0, string(InitiatorScheduler), len(body)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRequestRecorded(mock, ProviderAlphaVantage)
+
+	require.NoError(t, usage.LogAPICall(InitiatorScheduler, "TIME_SERIES_DAILY", nil, http.StatusOK, body, "", 0))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+var apiCallColumns = []string{"id", "endpoint", "request_params", "response_status", "response_body",
+	"response_body_length", "error_message", "processing_time_ms", "initiated_by", "created_at"}
+
+func TestListAPICalls_Filters(t *testing.T) {
+	loggedAt := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		filter     APICallFilter
+		conditions string
+		args       []driver.Value
+	}{
+		{
+			name:       "no filter",
+			filter:     APICallFilter{Limit: 50},
+			conditions: `WHERE service_name = \$1`,
+			args:       nil,
+		},
+		{
+			name:       "failed calls to one endpoint",
+			filter:     APICallFilter{Status: APICallStatusFailed, Endpoint: "TIME_SERIES_DAILY", Limit: 50, Offset: 50},
+			conditions: `WHERE service_name = \$1 AND endpoint = \$2 AND \(response_status >= 400 OR response_status = 0\)`,
+			args:       []driver.Value{"TIME_SERIES_DAILY"},
+		},
+		{
+			name:       "successful calls",
+			filter:     APICallFilter{Status: APICallStatusSuccess, Limit: 50},
+			conditions: `WHERE service_name = \$1 AND response_status = 200`,
+			args:       nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			args := append([]driver.Value{ProviderAlphaVantage}, tt.args...)
+			mock.ExpectQuery(`SELECT COUNT\(\*\) FROM api_calls ` + tt.conditions + `$`).
+				WithArgs(args...).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(51))
+			mock.ExpectQuery(`FROM api_calls ` + tt.conditions + `\s+ORDER BY created_at DESC, id DESC`).
+				WithArgs(append(args, tt.filter.Limit, tt.filter.Offset)...).
+				WillReturnRows(sqlmock.NewRows(apiCallColumns).
+					AddRow(7, "TIME_SERIES_DAILY", []byte(`{"symbol":"AAPL","apikey":"secret"}`), 503,
+						"Service Unavailable", 19, "provider returned 503", 120, "scheduler", loggedAt))
+
+			calls, total, err := NewAlphaVantageClient("test-key", db).ListAPICalls(context.Background(), tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, 51, total)
+			require.Len(t, calls, 1)
+			assert.Equal(t, int64(7), calls[0].ID)
+			assert.Equal(t, map[string]string{"symbol": "AAPL"}, calls[0].RequestParams, "the API key is never listed")
+			assert.Equal(t, InitiatorScheduler, calls[0].InitiatedBy)
+			assert.False(t, calls[0].Truncated)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListAPICalls_MarksTruncatedBodies(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM api_calls`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`FROM api_calls`).
+		WillReturnRows(sqlmock.NewRows(apiCallColumns).
+			AddRow(2, "TIME_SERIES_DAILY", nil, 200, `{"Meta`, 250000, "", 900, "scheduler", time.Now()).
+			AddRow(1, "TIME_SERIES_DAILY", nil, 200, `{"Meta Data": {}}`, nil, "", 800, "scheduler", time.Now()))
+
+	calls, _, err := NewAlphaVantageClient("test-key", db).ListAPICalls(context.Background(), APICallFilter{Limit: 50})
+	require.NoError(t, err)
+	require.Len(t, calls, 2)
+	assert.True(t, calls[0].Truncated)
+	assert.Equal(t, 250000, *calls[0].ResponseBodyLength)
+	assert.False(t, calls[1].Truncated, "calls logged before lengths were recorded are whole")
+	assert.Nil(t, calls[1].ResponseBodyLength)
+}
+
+func TestListAPICalls_RejectsUnknownStatus(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, _, err = NewAlphaVantageClient("test-key", db).ListAPICalls(context.Background(), APICallFilter{Status: "pending"})
+	assert.Error(t, err)
+}
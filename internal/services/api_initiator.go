@@ -0,0 +1,29 @@
+package services
+
+import "fmt"
+
+// Initiator identifies which part of the system spent an external API call
+type Initiator string
+
+const (
+	InitiatorScheduler   Initiator = "scheduler"
+	InitiatorManualSync  Initiator = "manual_sync"
+	InitiatorBatchSync   Initiator = "batch_sync"
+	InitiatorTasksCLI    Initiator = "tasks_cli"
+	InitiatorDataFetcher Initiator = "data_fetcher"
+	InitiatorSeed        Initiator = "seed"
+	InitiatorAdmin       Initiator = "admin"
+)
+
+// Validate returns an error unless the initiator is one of the known callers
+func (i Initiator) Validate() error {
+	switch i {
+	case InitiatorScheduler, InitiatorManualSync, InitiatorBatchSync,
+		InitiatorTasksCLI, InitiatorDataFetcher, InitiatorSeed, InitiatorAdmin:
+		return nil
+	case "":
+		return fmt.Errorf("API call initiator is required")
+	default:
+		return fmt.Errorf("unknown API call initiator: %s", string(i))
+	}
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// initiatorArg maps API-spending methods to the position of their initiator argument
+var initiatorArg = map[string]int{
+	"FetchDailyData":         2,
+	"FetchQuote":             1,
+	"FetchIntradayData":      2,
+	"FetchCompanyOverview":   1,
+	"FetchDailyAdjustedData": 1,
+	"SearchSymbols":          2,
+	"LogAPICall":             0,
+	"insertAPICall":          0,
+	"makeRequest":            1,
+}
+
+// repoGoFiles returns every non-test Go file in the module
+func repoGoFiles(t *testing.T) []string {
+	t.Helper()
+
+	root, err := filepath.Abs("../..")
+	require.NoError(t, err)
+
+	var files []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && (info.Name() == ".git" || info.Name() == "vendor") {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return files
+}
+
+// TestAPICallSitesPassInitiator fails when a call site spends API budget
+// without naming one of the Initiator constants.
+func TestAPICallSitesPassInitiator(t *testing.T) {
+	fset := token.NewFileSet()
+	checked := 0
+
+	for _, path := range repoGoFiles(t) {
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		require.NoError(t, err, path)
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			pos, ok := initiatorArg[sel.Sel.Name]
+			if !ok {
+				return true
+			}
+
+			checked++
+			where := fset.Position(call.Pos()).String()
+			if !assert.Greater(t, len(call.Args), pos, "%s: %s is missing its initiator", where, sel.Sel.Name) {
+				return true
+			}
+			if !isInitiatorConstant(call.Args[pos]) {
+				t.Errorf("%s: %s must be passed an Initiator constant", where, sel.Sel.Name)
+			}
+			return true
+		})
+	}
+
+	assert.NotZero(t, checked, "expected to find API call sites")
+}
+
+// isInitiatorConstant accepts InitiatorX or services.InitiatorX, and forwarded initiator parameters
+func isInitiatorConstant(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return strings.HasPrefix(e.Name, "Initiator") || e.Name == "initiator"
+	case *ast.SelectorExpr:
+		return strings.HasPrefix(e.Sel.Name, "Initiator")
+	}
+	return false
+}
+
+// TestRawAPICallInsertsSetInitiator covers binaries that write api_calls directly
+func TestRawAPICallInsertsSetInitiator(t *testing.T) {
+	insert := regexp.MustCompile(`(?s)INSERT INTO api_calls.*?VALUES`)
+
+	for _, path := range repoGoFiles(t) {
+		source, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		for _, stmt := range insert.FindAllString(string(source), -1) {
+			assert.Contains(t, stmt, "initiated_by", "%s: INSERT INTO api_calls must set initiated_by", path)
+		}
+	}
+}
+
+func TestInitiator_Validate(t *testing.T) {
+	assert.NoError(t, InitiatorScheduler.Validate())
+	assert.NoError(t, InitiatorSeed.Validate())
+	assert.Error(t, Initiator("").Validate())
+	assert.Error(t, Initiator("someone").Validate())
+}
+
+func TestFetchDailyData_RequiresInitiator(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	client := NewAlphaVantageClient("test-key", db)
+	_, err = client.FetchDailyData(context.Background(), "AAPL", "")
+	assert.Error(t, err)
+
+	// No rate limit check or API call was made
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAPICallStatsByInitiator(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	today := time.Now().Truncate(24 * time.Hour)
+	mock.ExpectQuery("GROUP BY service_name, initiated_by").WithArgs(ProviderAlphaVantage, 0).WillReturnRows(sqlmock.NewRows([]string{
+		"service_name", "initiated_by", "total_calls", "successful_calls", "failed_calls", "last_call_at", "call_date",
+	}).
+		AddRow("alphavantage", "batch_sync", 18, 17, 1, time.Now(), today).
+		AddRow("alphavantage", "scheduler", 5, 5, 0, time.Now(), today))
+
+	client := NewAlphaVantageClient("test-key", db)
+	stats, err := client.GetAPICallStatsByInitiator(0)
+	require.NoError(t, err)
+
+	require.Len(t, stats, 2)
+	assert.Equal(t, "batch_sync", stats[0].InitiatedBy)
+	assert.Equal(t, 18, stats[0].TotalCalls)
+	assert.Equal(t, 1, stats[0].FailedCalls)
+	assert.Equal(t, "scheduler", stats[1].InitiatedBy)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// WarmCache loads the stock list, every sector's list, the market overview
+// and the performance rankings into the cache, so the first requests after a
+// deploy do not pay for the cold queries. Whatever is already cached is kept.
+// It warms everything it can and returns the errors of what it could not.
+func (d *DatabaseStockService) WarmCache(ctx context.Context) error {
+	if d.cache == nil {
+		return errors.New("no cache to warm")
+	}
+	start := time.Now()
+
+	var errs []error
+	stocks := d.GetAllStocks(ctx)
+	if len(stocks) == 0 {
+		errs = append(errs, errors.New("no stocks loaded"))
+	}
+
+	sectors := make(map[string]bool)
+	for _, stock := range stocks {
+		if stock.Sector != "" && !sectors[stock.Sector] {
+			sectors[stock.Sector] = true
+			d.GetStocksBySector(ctx, stock.Sector)
+		}
+	}
+
+	if _, err := d.GetMarketOverviewAggregate(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("market overview: %w", err))
+	}
+	if _, err := d.GetTopMovers(ctx, TopMoversLimit); err != nil {
+		errs = append(errs, fmt.Errorf("performance rankings: %w", err))
+	}
+
+	slog.InfoContext(ctx, "Cache warmed", "stocks", len(stocks), "sectors", len(sectors),
+		"failures", len(errs), "duration", time.Since(start))
+	return errors.Join(errs...)
+}
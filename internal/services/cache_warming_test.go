@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/cache"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmCache_CachesListsOverviewAndRankings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache("redis://"+server.Addr(), "test:")
+	require.NoError(t, err)
+	defer redisCache.Close()
+	service := NewDatabaseStockService(db, redisCache)
+
+	now := time.Now()
+	mock.ExpectQuery(`FROM stocks s`).WillReturnRows(sqlmock.NewRows(rankedStockColumns).
+		AddRow(1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", nil,
+			"$100+", "NASDAQ", true, now, now, nil, nil, 150.0, 2.5, 1.69, int64(1000), now, 1).
+		AddRow(2, "XOM", "Exxon Mobil", "Energy", "Oil & Gas", nil,
+			"$100+", "NYSE", true, now, now, nil, nil, 110.0, -1.0, -0.9, int64(1000), now, 1).
+		AddRow(3, "MSFT", "Microsoft", "Technology", "Software", nil,
+			"$100+", "NASDAQ", true, now, now, nil, nil, 380.0, 1.0, 0.26, int64(1000), now, 1))
+	// Sector lists are filtered from the cached stock list without a query
+	mock.ExpectQuery(`FILTER \(WHERE change_percent > 0.01\)`).WithArgs(DefaultMaxChangePercent).
+		WillReturnRows(sqlmock.NewRows([]string{"total", "advancing", "declining", "unchanged", "avg"}).
+			AddRow(3, 2, 1, 0, 0.35))
+	mock.ExpectQuery(`WHERE ABS\(change_percent\) > \$1`).WithArgs(DefaultMaxChangePercent).
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "change_percent", "current_price", "daily_change"}))
+	mock.ExpectQuery(`WHERE change_percent > 0\s+ORDER BY`).WillReturnRows(rankedStocks(1.69, "AAPL"))
+	mock.ExpectQuery(`WHERE change_percent < 0\s+ORDER BY`).WillReturnRows(rankedStocks(-0.9, "XOM"))
+	mock.ExpectQuery(`ORDER BY relative_volume DESC`).WillReturnRows(activeStocks([]interface{}{"AAPL", int64(1000), nil, nil}))
+	mock.ExpectQuery(`WHERE ABS\(change_percent\) > \$1`).WithArgs(DefaultMaxChangePercent).
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "change_percent", "current_price", "daily_change"}))
+
+	require.NoError(t, service.WarmCache(context.Background()))
+
+	assert.ElementsMatch(t, []string{
+		"test:stocks:all",
+		"test:stocks:sector:Technology",
+		"test:stocks:sector:Energy",
+		"test:market:overview",
+		"test:performance:rankings",
+	}, server.Keys())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Warming again finds everything cached
+	require.NoError(t, service.WarmCache(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWarmCache_ReportsWhatFailed(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+
+	mock.ExpectQuery(`FROM stocks s`).WillReturnRows(sqlmock.NewRows(rankedStockColumns))
+	mock.ExpectQuery(`FILTER \(WHERE change_percent > 0.01\)`).WillReturnError(assert.AnError)
+	mock.ExpectQuery(`WHERE change_percent > 0\s+ORDER BY`).WillReturnError(assert.AnError)
+
+	err := service.WarmCache(context.Background())
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "no stocks loaded")
+	assert.ErrorContains(t, err, "market overview")
+	assert.ErrorContains(t, err, "performance rankings")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWarmCache_NeedsCache(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	assert.Error(t, NewDatabaseStockService(db, nil).WarmCache(context.Background()))
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"math"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// DefaultMaxChangePercent is the largest absolute daily change treated as real data.
+// Anything beyond it is almost always a corrupt previous close rather than a market move.
+const DefaultMaxChangePercent = 50.0
+
+const outlierReason = "change percent exceeds sanity limit; previous close is likely corrupt"
+
+// ChangeOutlier describes a stock left out of market aggregates and rankings
+type ChangeOutlier struct {
+	Symbol        string  `json:"symbol"`
+	ChangePercent float64 `json:"change_percent"`
+	CurrentPrice  float64 `json:"current_price"`
+	DailyChange   float64 `json:"daily_change"`
+	Reason        string  `json:"reason"`
+}
+
+// ChangeDiagnostics reports which stocks were excluded by the change percent guard
+type ChangeDiagnostics struct {
+	MaxChangePercent float64         `json:"max_change_percent"`
+	ExcludedOutliers []ChangeOutlier `json:"excluded_outliers"`
+}
+
+// SetMaxChangePercent sets the absolute change percent above which stocks are excluded
+// from aggregates and rankings; zero or less disables the guard
+func (d *DatabaseStockService) SetMaxChangePercent(maxChangePercent float64) {
+	d.maxChangePercent = maxChangePercent
+}
+
+// ExcludeChangeOutliers splits stocks into those safe to aggregate and rank, and
+// diagnostics listing the ones whose change percent exceeds the configured limit.
+// There is no corporate action data yet, so every outlier is excluded.
+func (d *DatabaseStockService) ExcludeChangeOutliers(stocks []models.Stock) ([]models.Stock, ChangeDiagnostics) {
+	diagnostics := ChangeDiagnostics{
+		MaxChangePercent: d.maxChangePercent,
+		ExcludedOutliers: []ChangeOutlier{},
+	}
+	if d.maxChangePercent <= 0 {
+		return stocks, diagnostics
+	}
+
+	kept := make([]models.Stock, 0, len(stocks))
+	for _, stock := range stocks {
+		if math.Abs(stock.ChangePercent) > d.maxChangePercent {
+			diagnostics.ExcludedOutliers = append(diagnostics.ExcludedOutliers, ChangeOutlier{
+				Symbol:        stock.Symbol,
+				ChangePercent: stock.ChangePercent,
+				CurrentPrice:  stock.CurrentPrice,
+				DailyChange:   stock.DailyChange,
+				Reason:        outlierReason,
+			})
+			continue
+		}
+		kept = append(kept, stock)
+	}
+
+	return kept, diagnostics
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrUnknownSymbols is returned when a run is restricted to symbols that are
+// not active stocks
+var ErrUnknownSymbols = errors.New("not active stocks")
+
+// DataFetchOptions tune one run of the data fetcher
+type DataFetchOptions struct {
+	// MaxCalls caps the API calls the run spends; zero leaves it to the rate limit
+	MaxCalls int
+	// Symbols restricts the run to these stocks; empty fetches every active stock
+	Symbols []string
+	// Compact fetches only the latest CompactOutputTradingDays trading days of
+	// each stock, rather than whatever it is missing
+	Compact bool
+	// NoResume plans afresh rather than resuming today's unfinished run
+	NoResume bool
+	// Delay is an extra pause between stocks, on top of the provider's per-minute pacing
+	Delay time.Duration
+	// Logger receives the run's progress; nil logs to slog.Default()
+	Logger *slog.Logger
+}
+
+// DataFetchResult summarises one run of the data fetcher
+type DataFetchResult struct {
+	// Available is how many calls the rate limit allowed when the run started
+	Available int `json:"available"`
+	// Candidates is how many active stocks were in line to be fetched
+	Candidates int `json:"candidates"`
+	Successful int `json:"successful"`
+	Failed     int `json:"failed"`
+	// RateLimited is set when the provider refused a call and the run stopped early
+	RateLimited bool `json:"rate_limited"`
+}
+
+// DataFetchPlan is what a run of the data fetcher would fetch, worked out
+// without calling the provider
+type DataFetchPlan struct {
+	Budget APIBudget `json:"budget"`
+	// Available is how many calls the rate limit allows now
+	Available int `json:"available"`
+	// Limit is how many calls the run may spend: Available, capped by MaxCalls
+	Limit int `json:"limit"`
+	// Candidates is how many stocks are in line to be fetched; for a resumed
+	// run, those it has not fetched yet
+	Candidates int `json:"candidates"`
+	// Symbols are the stocks the run fetches, in order
+	Symbols []string `json:"symbols"`
+	// EstimatedDuration is how long the run takes when paced to the
+	// per-minute limit and the options' delay
+	EstimatedDuration time.Duration `json:"estimated_duration"`
+	// ResumedRunID is today's unfinished run the plan resumes; zero for a fresh plan
+	ResumedRunID int64 `json:"resumed_run_id,omitempty"`
+	// Skipped is how many of the resumed run's stocks were already fetched
+	Skipped int `json:"skipped,omitempty"`
+
+	candidates []fetchStock
+	stocks     []fetchStock
+}
+
+// PlanDataFetch works out which stocks a run with opts would fetch and how
+// long it would take. Only the rate limit counters, the fetch runs and the
+// stock list are read; nothing is fetched or written. When no calls are
+// available the plan is empty and nothing else is read.
+//
+// A run that is not restricted to symbols resumes today's unfinished run from
+// its first pending stock, rather than planning afresh, unless opts.NoResume.
+func PlanDataFetch(ctx context.Context, db *sql.DB, client MarketDataProvider, opts DataFetchOptions) (DataFetchPlan, error) {
+	var plan DataFetchPlan
+	if _, ok := client.(RecentDailyProvider); opts.Compact && !ok {
+		return plan, errors.New("provider cannot fetch compact output")
+	}
+
+	budget, err := client.GetAPIBudget()
+	if err != nil {
+		return plan, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+	plan.Budget = *budget
+	plan.Available = budget.CallsAvailable()
+	if plan.Available <= 0 {
+		return plan, nil
+	}
+	plan.Limit = plan.Available
+	if opts.MaxCalls > 0 && opts.MaxCalls < plan.Limit {
+		plan.Limit = opts.MaxCalls
+	}
+
+	if len(opts.Symbols) == 0 && !opts.NoResume {
+		run, err := incompleteFetchRun(ctx, db, time.Now())
+		if err != nil {
+			return plan, err
+		}
+		var pending []string
+		if run != nil {
+			pending = run.pending()
+		}
+		if len(pending) > 0 {
+			plan.ResumedRunID = run.id
+			plan.Skipped = len(run.symbols) - len(pending)
+			for _, symbol := range pending {
+				plan.candidates = append(plan.candidates, fetchStock{symbol: symbol})
+			}
+			plan.selectStocks(opts.Delay)
+			return plan, nil
+		}
+	}
+
+	stocks, err := prioritizedFetchStocks(ctx, db)
+	if err != nil {
+		return plan, err
+	}
+	if len(opts.Symbols) > 0 {
+		stocks = slices.DeleteFunc(stocks, func(stock fetchStock) bool {
+			return !slices.Contains(opts.Symbols, stock.symbol)
+		})
+		var unknown []string
+		for _, symbol := range opts.Symbols {
+			if !slices.ContainsFunc(stocks, func(stock fetchStock) bool { return stock.symbol == symbol }) {
+				unknown = append(unknown, symbol)
+			}
+		}
+		if len(unknown) > 0 {
+			return plan, fmt.Errorf("%w: %s", ErrUnknownSymbols, strings.Join(unknown, ", "))
+		}
+	}
+	plan.candidates = stocks
+	plan.selectStocks(opts.Delay)
+	return plan, nil
+}
+
+// selectStocks picks as many of the candidates as the limit allows
+func (p *DataFetchPlan) selectStocks(delay time.Duration) {
+	p.Candidates = len(p.candidates)
+	p.stocks = p.candidates[:min(p.Limit, len(p.candidates))]
+	p.Symbols = make([]string, len(p.stocks))
+	for i, stock := range p.stocks {
+		p.Symbols[i] = stock.symbol
+	}
+	p.EstimatedDuration = estimateFetchDuration(p.Budget, len(p.stocks), delay, time.Now())
+}
+
+// estimateFetchDuration is how long calls takes from now: waiting for the
+// next call the budget allows, then pacing each further call to the
+// per-minute limit plus delay
+func estimateFetchDuration(budget APIBudget, calls int, delay time.Duration, now time.Time) time.Duration {
+	if calls == 0 {
+		return 0
+	}
+	var wait time.Duration
+	if budget.NextCallAllowedAt.After(now) {
+		wait = budget.NextCallAllowedAt.Sub(now)
+	}
+	interval := delay
+	if budget.CallsPerMinute > 0 {
+		interval += time.Minute / time.Duration(budget.CallsPerMinute)
+	}
+	return wait + time.Duration(calls-1)*interval
+}
+
+// DataFetchRun fetches and saves daily prices for as many active stocks as
+// the rate limit and opts allow, stocks without any stored prices first and
+// then by market cap. A stock that fails is counted and the run moves on; a
+// refused call ends the run, since every later call would be refused too.
+//
+// Unless restricted to symbols, the run's plan is stored in fetch_runs with
+// each stock's outcome as it is fetched, so the next run today picks up
+// where an interrupted or rate limited one stopped. A refused stock stays
+// pending.
+func DataFetchRun(ctx context.Context, db *sql.DB, client MarketDataProvider, opts DataFetchOptions) (DataFetchResult, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	var result DataFetchResult
+
+	plan, err := PlanDataFetch(ctx, db, client, opts)
+	if err != nil {
+		return result, err
+	}
+	budget := plan.Budget
+	result.Available = plan.Available
+	logger.InfoContext(ctx, "Rate limit status", "used_today", budget.DailyUsed, "daily_limit", budget.DailyLimit,
+		"available", result.Available)
+	if result.Available <= 0 {
+		logger.InfoContext(ctx, "No API calls available, nothing fetched",
+			"next_call_allowed_at", budget.NextCallAllowedAt.Format(time.RFC3339))
+		return result, nil
+	}
+
+	stocks := plan.stocks
+	result.Candidates = plan.Candidates
+	if len(stocks) == 0 {
+		logger.InfoContext(ctx, "No active stocks to fetch")
+		return result, nil
+	}
+
+	runID := plan.ResumedRunID
+	if runID != 0 {
+		logger.InfoContext(ctx, "Resuming today's unfinished run", "run_id", runID, "already_fetched", plan.Skipped)
+	} else if len(opts.Symbols) == 0 {
+		symbols := make([]string, len(plan.candidates))
+		for i, stock := range plan.candidates {
+			symbols[i] = stock.symbol
+		}
+		// Without a checkpoint the run still fetches, it just cannot be resumed
+		if runID, err = startFetchRun(ctx, db, symbols, time.Now()); err != nil {
+			logger.WarnContext(ctx, "Failed to store the run's plan, it will not be resumable", "error", err)
+		}
+	}
+	checkpointed := 0
+	checkpoint := func(symbol, status string) {
+		if runID == 0 {
+			return
+		}
+		// Recorded even as the run is cancelled, so the next run does not fetch it again
+		if err := checkpointFetchRun(context.WithoutCancel(ctx), db, runID, symbol, status); err != nil {
+			logger.WarnContext(ctx, "Failed to checkpoint fetch run", "symbol", symbol, "error", err)
+			return
+		}
+		checkpointed++
+	}
+	logger.InfoContext(ctx, "Fetching stocks", "candidates", plan.Candidates, "max_calls", plan.Limit,
+		"compact", opts.Compact, "estimated_duration", plan.EstimatedDuration)
+
+	for i, stock := range stocks {
+		if i > 0 && opts.Delay > 0 {
+			select {
+			case <-ctx.Done():
+				return result, ctx.Err()
+			case <-time.After(opts.Delay):
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		logger.InfoContext(ctx, "Fetching data", "symbol", stock.symbol, "company", stock.companyName,
+			"index", i+1, "total", len(stocks))
+
+		var data *DailySeries
+		if opts.Compact {
+			data, err = client.(RecentDailyProvider).FetchRecentDailyData(ctx, stock.symbol, InitiatorDataFetcher)
+		} else {
+			data, err = fetchMissingDailyData(ctx, db, client, stock.symbol, InitiatorDataFetcher)
+		}
+		if errors.Is(err, ErrRateLimited) {
+			logger.WarnContext(ctx, "Rate limited, stopping the run", "symbol", stock.symbol, "error", err)
+			result.Failed++
+			result.RateLimited = true
+			break
+		}
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to fetch stock data", "symbol", stock.symbol, "error", err)
+			result.Failed++
+			checkpoint(stock.symbol, RunStatusFailed)
+			continue
+		}
+
+		stats, err := client.SaveHistoricalData(ctx, stock.symbol, data, SyncLockWait)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to save stock data", "symbol", stock.symbol, "error", err)
+			result.Failed++
+			checkpoint(stock.symbol, RunStatusFailed)
+			continue
+		}
+		if rejected := len(data.Rejected) + len(stats.Rejected); rejected > 0 {
+			logger.WarnContext(ctx, "Skipped malformed price rows", "symbol", stock.symbol, "rows_skipped", rejected)
+		}
+		logger.InfoContext(ctx, "Successfully fetched stock data", "symbol", stock.symbol,
+			"inserted", stats.Inserted, "updated", stats.Updated)
+		result.Successful++
+		checkpoint(stock.symbol, RunStatusSucceeded)
+	}
+	if !result.RateLimited && plan.Candidates > len(stocks) {
+		logger.InfoContext(ctx, "Reached call limit for this run", "processed", len(stocks), "total", plan.Candidates)
+	}
+	if runID != 0 && checkpointed == plan.Candidates {
+		if err := completeFetchRun(ctx, db, runID); err != nil {
+			logger.WarnContext(ctx, "Failed to mark fetch run completed", "run_id", runID, "error", err)
+		}
+	}
+
+	logger.InfoContext(ctx, "Fetch summary", "successful", result.Successful, "failed", result.Failed,
+		"rate_limited", result.RateLimited)
+	return result, nil
+}
+
+type fetchStock struct {
+	symbol      string
+	companyName string
+}
+
+// prioritizedFetchStocks returns the active stocks, those with no price data first
+func prioritizedFetchStocks(ctx context.Context, db *sql.DB) ([]fetchStock, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.symbol, s.company_name
+		FROM stocks s
+		LEFT JOIN daily_prices dp ON s.id = dp.stock_id
+		WHERE s.is_active = true
+		GROUP BY s.id, s.symbol, s.company_name
+		ORDER BY
+			CASE WHEN COUNT(dp.id) = 0 THEN 1 ELSE 2 END,  -- Prioritize stocks with no price data
+			s.market_cap DESC NULLS LAST,                   -- Then by market cap
+			s.symbol                                        -- Finally alphabetically
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prioritized stocks: %w", err)
+	}
+	defer rows.Close()
+
+	var stocks []fetchStock
+	for rows.Next() {
+		var stock fetchStock
+		if err := rows.Scan(&stock.symbol, &stock.companyName); err != nil {
+			return nil, fmt.Errorf("failed to scan stock: %w", err)
+		}
+		stocks = append(stocks, stock)
+	}
+	return stocks, rows.Err()
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDataFetchClient returns an Alpha Vantage client whose server answers each
+// symbol with its entry in responses
+func newDataFetchClient(t *testing.T, responses map[string]string) (*AlphaVantageClient, sqlmock.Sqlmock) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, responses[r.URL.Query().Get("symbol")])
+	}))
+	t.Cleanup(server.Close)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	client := NewAlphaVantageClient("test-key", db)
+	client.baseURL = server.URL
+	return client, mock
+}
+
+// expectFetchBudget expects the budget lookup a run starts with, dailyUsed of 25 calls spent
+func expectFetchBudget(mock sqlmock.Sqlmock, dailyUsed int) {
+	mock.ExpectQuery("FROM api_rate_limits").WillReturnRows(sqlmock.NewRows([]string{
+		"id", "service_name", "daily_limit", "hourly_limit", "current_daily_count",
+		"current_hourly_count", "last_reset_date", "last_reset_hour", "created_at", "updated_at",
+	}).AddRow(1, "alphavantage", 25, nil, dailyUsed, 0, marketDate(time.Now()), marketHour(time.Now()), time.Now(), time.Now()))
+	mock.ExpectQuery("SELECT MAX\\(created_at\\) FROM api_calls").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+}
+
+// expectNoRunToResume expects the lookup of today's unfinished run to find none
+func expectNoRunToResume(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("FROM fetch_runs").WithArgs(fetchRunRunning, marketDate(time.Now())).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "symbols", "statuses"}))
+}
+
+// expectRunStarted expects a fresh plan of symbols to be stored as run id
+func expectRunStarted(mock sqlmock.Sqlmock, id int64, symbols ...string) {
+	mock.ExpectQuery("INSERT INTO fetch_runs").
+		WithArgs(fetchRunAbandoned, fetchRunRunning, marketDate(time.Now()), pq.Array(symbols)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+}
+
+func expectCheckpoint(mock sqlmock.Sqlmock, id int64, symbol, status string) {
+	mock.ExpectExec(`UPDATE fetch_runs\s+SET statuses`).WithArgs(id, symbol, status).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func expectFetchCandidates(mock sqlmock.Sqlmock, symbols ...string) {
+	rows := sqlmock.NewRows([]string{"symbol", "company_name"})
+	for _, symbol := range symbols {
+		rows.AddRow(symbol, symbol+" Inc.")
+	}
+	mock.ExpectQuery("CASE WHEN COUNT\\(dp.id\\) = 0").WillReturnRows(rows)
+}
+
+// expectFetchCall expects one daily series request for a stock with no stored prices
+func expectFetchCall(mock sqlmock.Sqlmock) {
+	expectLatestPriceDate(mock, nil)
+	expectRateLimitQuery(mock)
+	mock.ExpectExec("INSERT INTO api_calls").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRequestRecorded(mock, ProviderAlphaVantage)
+}
+
+func TestDataFetchRun_StopsAtMaxCalls(t *testing.T) {
+	client, mock := newDataFetchClient(t, map[string]string{"AAPL": dailySeriesFixture})
+
+	expectFetchBudget(mock, 0)
+	expectNoRunToResume(mock)
+	expectFetchCandidates(mock, "AAPL", "MSFT")
+	expectRunStarted(mock, 1, "AAPL", "MSFT")
+	expectFetchCall(mock)
+	expectSave(mock, "AAPL", 1, 0)
+	expectCheckpoint(mock, 1, "AAPL", RunStatusSucceeded)
+
+	result, err := DataFetchRun(context.Background(), client.db, client, DataFetchOptions{MaxCalls: 1})
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet(), "MSFT must not be fetched")
+	assert.Equal(t, DataFetchResult{Available: 25, Candidates: 2, Successful: 1}, result)
+}
+
+func TestDataFetchRun_ContinuesPastFailureAndStopsOnRateLimit(t *testing.T) {
+	client, mock := newDataFetchClient(t, map[string]string{
+		"BADSYM": `{"Error Message": "Invalid API call."}`,
+		"AAPL":   dailySeriesFixture,
+		"NEXT":   `{"Note": "Our standard API call frequency is 5 calls per minute."}`,
+	})
+
+	expectFetchBudget(mock, 20)
+	expectNoRunToResume(mock)
+	expectFetchCandidates(mock, "BADSYM", "AAPL", "NEXT", "LATER")
+	expectRunStarted(mock, 1, "BADSYM", "AAPL", "NEXT", "LATER")
+	expectFetchCall(mock) // BADSYM: fails, keep going
+	expectCheckpoint(mock, 1, "BADSYM", RunStatusFailed)
+	expectFetchCall(mock) // AAPL
+	expectSave(mock, "AAPL", 1, 0)
+	expectCheckpoint(mock, 1, "AAPL", RunStatusSucceeded)
+	expectFetchCall(mock) // NEXT: rate limited, stop and leave it pending
+
+	result, err := DataFetchRun(context.Background(), client.db, client, DataFetchOptions{Delay: time.Millisecond})
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet(), "LATER must not be fetched")
+	assert.Equal(t, DataFetchResult{Available: 5, Candidates: 4, Successful: 1, Failed: 2, RateLimited: true}, result)
+}
+
+func TestDataFetchRun_NoCallsAvailable(t *testing.T) {
+	client, mock := newDataFetchClient(t, nil)
+
+	expectFetchBudget(mock, 25)
+
+	result, err := DataFetchRun(context.Background(), client.db, client, DataFetchOptions{})
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Zero(t, result.Available)
+	assert.Zero(t, result.Candidates)
+}
+
+// stubFetchProvider reports a fixed budget and records the symbols it is asked for
+type stubFetchProvider struct {
+	MarketDataProvider
+	budget  APIBudget
+	full    []string
+	compact []string
+	// afterSave runs after each stock is saved
+	afterSave func(symbol string)
+}
+
+func (p *stubFetchProvider) GetAPIBudget() (*APIBudget, error) {
+	budget := p.budget
+	return &budget, nil
+}
+
+func (p *stubFetchProvider) FetchDailyData(ctx context.Context, symbol string, initiator Initiator) (*DailySeries, error) {
+	p.full = append(p.full, symbol)
+	return &DailySeries{Symbol: symbol, Bars: []DailyBar{{}}}, nil
+}
+
+func (p *stubFetchProvider) FetchRecentDailyData(ctx context.Context, symbol string, initiator Initiator) (*DailySeries, error) {
+	p.compact = append(p.compact, symbol)
+	return &DailySeries{Symbol: symbol, Bars: []DailyBar{{}}}, nil
+}
+
+func (p *stubFetchProvider) SaveHistoricalData(ctx context.Context, symbol string, data *DailySeries, mode SyncLockMode) (SaveStats, error) {
+	if p.afterSave != nil {
+		p.afterSave(symbol)
+	}
+	return SaveStats{Inserted: len(data.Bars)}, nil
+}
+
+func TestPlanDataFetch_RestrictsToSymbolsAndLimit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	client := &stubFetchProvider{budget: APIBudget{DailyLimit: 25, DailyUsed: 15, DailyRemaining: 10, CallsPerMinute: 5}}
+
+	expectFetchCandidates(mock, "AAPL", "MSFT", "GOOGL", "TSLA")
+
+	plan, err := PlanDataFetch(context.Background(), db, client, DataFetchOptions{
+		Symbols: []string{"TSLA", "AAPL", "MSFT"}, MaxCalls: 2,
+	})
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 10, plan.Available)
+	assert.Equal(t, 2, plan.Limit)
+	assert.Equal(t, 3, plan.Candidates)
+	assert.Equal(t, []string{"AAPL", "MSFT"}, plan.Symbols, "the run's priority order is kept")
+	assert.Equal(t, 12*time.Second, plan.EstimatedDuration)
+	assert.Empty(t, client.full, "planning makes no API calls")
+}
+
+func TestPlanDataFetch_UnknownSymbols(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	client := &stubFetchProvider{budget: APIBudget{DailyRemaining: 10, CallsPerMinute: 5}}
+
+	expectFetchCandidates(mock, "AAPL", "MSFT")
+
+	_, err = PlanDataFetch(context.Background(), db, client, DataFetchOptions{Symbols: []string{"AAPL", "NOPE"}})
+
+	assert.ErrorIs(t, err, ErrUnknownSymbols)
+	assert.ErrorContains(t, err, "NOPE")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPlanDataFetch_CompactNeedsProviderSupport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = PlanDataFetch(context.Background(), db, &stubSyncProvider{}, DataFetchOptions{Compact: true})
+
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEstimateFetchDuration(t *testing.T) {
+	now := time.Now()
+	budget := APIBudget{CallsPerMinute: 5, NextCallAllowedAt: now.Add(30 * time.Second)}
+
+	assert.Zero(t, estimateFetchDuration(budget, 0, time.Second, now))
+	assert.Equal(t, 30*time.Second, estimateFetchDuration(budget, 1, time.Second, now))
+	assert.Equal(t, 56*time.Second, estimateFetchDuration(budget, 3, time.Second, now),
+		"the wait for the next call, then 12s pacing plus the delay between calls")
+}
+
+func TestDataFetchRun_WithStubClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		compact bool
+		full    []string
+		recent  []string
+	}{
+		{name: "missing prices", full: []string{"MSFT"}},
+		{name: "compact", compact: true, recent: []string{"MSFT"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			client := &stubFetchProvider{budget: APIBudget{DailyRemaining: 10, CallsPerMinute: 5}}
+
+			expectFetchCandidates(mock, "AAPL", "MSFT")
+			if !tt.compact {
+				expectLatestPriceDate(mock, nil)
+			}
+
+			result, err := DataFetchRun(context.Background(), db, client, DataFetchOptions{
+				Symbols: []string{"MSFT"}, Compact: tt.compact,
+			})
+
+			require.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, DataFetchResult{Available: 10, Candidates: 1, Successful: 1}, result)
+			assert.Equal(t, tt.full, client.full)
+			assert.Equal(t, tt.recent, client.compact)
+		})
+	}
+}
+
+func TestDataFetchRun_ResumesAfterInterrupt(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// The first run is interrupted once two stocks are saved
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	saved := 0
+	client := &stubFetchProvider{
+		budget: APIBudget{DailyRemaining: 10, CallsPerMinute: 5},
+		afterSave: func(string) {
+			if saved++; saved == 2 {
+				cancel()
+			}
+		},
+	}
+
+	expectNoRunToResume(mock)
+	expectFetchCandidates(mock, "AAPL", "MSFT", "GOOGL", "TSLA")
+	expectRunStarted(mock, 7, "AAPL", "MSFT", "GOOGL", "TSLA")
+	for _, symbol := range []string{"AAPL", "MSFT"} {
+		expectLatestPriceDate(mock, nil)
+		expectCheckpoint(mock, 7, symbol, RunStatusSucceeded)
+	}
+
+	result, err := DataFetchRun(ctx, db, client, DataFetchOptions{})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 2, result.Successful)
+	require.NoError(t, mock.ExpectationsWereMet(), "both stocks must be checkpointed despite the interrupt")
+
+	// The next run resumes from the first pending stock, without re-planning
+	client.full, client.afterSave = nil, nil
+	mock.ExpectQuery("FROM fetch_runs").WillReturnRows(sqlmock.NewRows([]string{"id", "symbols", "statuses"}).
+		AddRow(7, `{AAPL,MSFT,GOOGL,TSLA}`, `{"AAPL": "succeeded", "MSFT": "succeeded"}`))
+	for _, symbol := range []string{"GOOGL", "TSLA"} {
+		expectLatestPriceDate(mock, nil)
+		expectCheckpoint(mock, 7, symbol, RunStatusSucceeded)
+	}
+	mock.ExpectExec("UPDATE fetch_runs SET status").WithArgs(int64(7), fetchRunCompleted).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	result, err = DataFetchRun(context.Background(), db, client, DataFetchOptions{})
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []string{"GOOGL", "TSLA"}, client.full, "AAPL and MSFT must not be fetched again")
+	assert.Equal(t, DataFetchResult{Available: 10, Candidates: 2, Successful: 2}, result)
+}
+
+func TestPlanDataFetch_NoResumePlansAfresh(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	client := &stubFetchProvider{budget: APIBudget{DailyRemaining: 10, CallsPerMinute: 5}}
+
+	expectFetchCandidates(mock, "AAPL", "MSFT")
+
+	plan, err := PlanDataFetch(context.Background(), db, client, DataFetchOptions{NoResume: true})
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet(), "today's unfinished run must not be looked up")
+	assert.Zero(t, plan.ResumedRunID)
+	assert.Equal(t, []string{"AAPL", "MSFT"}, plan.Symbols)
+}
+
+func TestFetchRun_Pending(t *testing.T) {
+	run := fetchRun{
+		symbols:  []string{"AAPL", "MSFT", "GOOGL", "TSLA"},
+		statuses: map[string]string{"AAPL": RunStatusSucceeded, "GOOGL": RunStatusFailed},
+	}
+
+	assert.Equal(t, []string{"MSFT", "TSLA"}, run.pending())
+}
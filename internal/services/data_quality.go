@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DataGap is a run of trading days with no stored price between two days that have one
+type DataGap struct {
+	// From and To are the first and last missing trading days
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	TradingDays int       `json:"trading_days"`
+}
+
+// SymbolGaps lists the gaps in one stock's stored prices
+type SymbolGaps struct {
+	Symbol      string    `json:"symbol"`
+	Gaps        []DataGap `json:"gaps"`
+	MissingDays int       `json:"missing_days"`
+}
+
+// DataQualityService checks stored daily prices against the trading days the
+// market calendar expects
+type DataQualityService struct {
+	db       *sql.DB
+	calendar *MarketCalendar
+}
+
+// NewDataQualityService returns a service checking against calendar, or the
+// built-in NYSE calendar when calendar is nil
+func NewDataQualityService(db *sql.DB, calendar *MarketCalendar) *DataQualityService {
+	if calendar == nil {
+		calendar = NewMarketCalendar()
+	}
+	return &DataQualityService{db: db, calendar: calendar}
+}
+
+// FindGaps returns the gaps between symbol's oldest and newest stored prices,
+// oldest first. Days before the calendar's first holiday year are not checked.
+func (s *DataQualityService) FindGaps(symbol string) ([]DataGap, error) {
+	report, err := s.gaps(context.Background(), symbol)
+	if err != nil {
+		return nil, err
+	}
+	if len(report) == 0 {
+		return []DataGap{}, nil
+	}
+	return report[0].Gaps, nil
+}
+
+// GapReport returns the active stocks with gaps of at least minGapDays trading
+// days, most missing days first
+func (s *DataQualityService) GapReport(ctx context.Context, minGapDays int) ([]SymbolGaps, error) {
+	all, err := s.gaps(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	report := []SymbolGaps{}
+	for _, symbol := range all {
+		filtered := SymbolGaps{Symbol: symbol.Symbol}
+		for _, gap := range symbol.Gaps {
+			if gap.TradingDays >= minGapDays {
+				filtered.Gaps = append(filtered.Gaps, gap)
+				filtered.MissingDays += gap.TradingDays
+			}
+		}
+		if len(filtered.Gaps) > 0 {
+			report = append(report, filtered)
+		}
+	}
+
+	sort.SliceStable(report, func(i, j int) bool {
+		if report[i].MissingDays != report[j].MissingDays {
+			return report[i].MissingDays > report[j].MissingDays
+		}
+		return report[i].Symbol < report[j].Symbol
+	})
+	return report, nil
+}
+
+// missingDays returns the trading days missing from each active stock's stored history
+func (s *DataQualityService) missingDays(ctx context.Context) (map[string]int, error) {
+	report, err := s.gaps(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	missing := make(map[string]int, len(report))
+	for _, symbol := range report {
+		missing[symbol.Symbol] = symbol.MissingDays
+	}
+	return missing, nil
+}
+
+// gaps finds the gaps of symbol, or of every active stock when symbol is empty,
+// in symbol order. SQL narrows the search to consecutive stored dates more than
+// a day apart, other than a plain weekend; the calendar decides which of the
+// days between them the market traded.
+func (s *DataQualityService) gaps(ctx context.Context, symbol string) ([]SymbolGaps, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT symbol, prev_date, date
+		FROM (
+			SELECT s.symbol, dp.date, LAG(dp.date) OVER (PARTITION BY dp.stock_id ORDER BY dp.date) AS prev_date
+			FROM daily_prices dp
+			JOIN stocks s ON s.id = dp.stock_id
+			WHERE dp.date >= $1 AND (s.symbol = $2 OR ($2 = '' AND s.is_active = true))
+		) stored
+		WHERE date - prev_date > 1
+		  AND NOT (EXTRACT(ISODOW FROM date) = 1 AND date - prev_date = 3)
+		ORDER BY symbol, date
+	`, s.calendar.holidaysKnownFrom(), symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price gaps: %w", err)
+	}
+	defer rows.Close()
+
+	var report []SymbolGaps
+	for rows.Next() {
+		var stockSymbol string
+		var before, after time.Time
+		if err := rows.Scan(&stockSymbol, &before, &after); err != nil {
+			return nil, fmt.Errorf("failed to scan price gap: %w", err)
+		}
+
+		gap, ok := s.gapBetween(before, after)
+		if !ok {
+			continue // Only closures between the two dates
+		}
+		if len(report) == 0 || report[len(report)-1].Symbol != stockSymbol {
+			report = append(report, SymbolGaps{Symbol: stockSymbol})
+		}
+		last := &report[len(report)-1]
+		last.Gaps = append(last.Gaps, gap)
+		last.MissingDays += gap.TradingDays
+	}
+	return report, rows.Err()
+}
+
+// gapBetween returns the trading days strictly between two stored dates, if any
+func (s *DataQualityService) gapBetween(before, after time.Time) (DataGap, bool) {
+	before = time.Date(before.Year(), before.Month(), before.Day(), 0, 0, 0, 0, time.UTC)
+	after = time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, time.UTC)
+
+	var gap DataGap
+	for day := before.AddDate(0, 0, 1); day.Before(after); day = day.AddDate(0, 0, 1) {
+		if !s.calendar.isTradingDate(day) {
+			continue
+		}
+		if gap.TradingDays == 0 {
+			gap.From = day
+		}
+		gap.To = day
+		gap.TradingDays++
+	}
+	return gap, gap.TradingDays > 0
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storedGap is a pair of consecutive stored price dates with days between them
+type storedGap struct {
+	symbol        string
+	before, after time.Time
+}
+
+// expectGaps expects the price gap search, finding the given stored date pairs
+func expectGaps(mock sqlmock.Sqlmock, gaps ...storedGap) {
+	rows := sqlmock.NewRows([]string{"symbol", "prev_date", "date"})
+	for _, gap := range gaps {
+		rows.AddRow(gap.symbol, gap.before, gap.after)
+	}
+	mock.ExpectQuery("LAG\\(dp.date\\)").WillReturnRows(rows)
+}
+
+func date2026(month time.Month, d int) time.Time {
+	return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+// seededGaps is what the search finds in a series missing the week of March 9
+// and, around Good Friday, only the closure itself
+var seededGaps = []storedGap{
+	{"AAPL", date2026(time.March, 6), date2026(time.March, 16)},
+	{"AAPL", date2026(time.April, 2), date2026(time.April, 6)},
+}
+
+func TestFindGaps_MissingWeek(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("LAG\\(dp.date\\)").WithArgs(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), "AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "prev_date", "date"}).
+			AddRow("AAPL", seededGaps[0].before, seededGaps[0].after).
+			AddRow("AAPL", seededGaps[1].before, seededGaps[1].after))
+
+	gaps, err := NewDataQualityService(db, nil).FindGaps("AAPL")
+	require.NoError(t, err)
+	require.Len(t, gaps, 1, "Good Friday is a closure, not a gap")
+	assert.Equal(t, DataGap{From: date2026(time.March, 9), To: date2026(time.March, 13), TradingDays: 5}, gaps[0])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindGaps_None(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectGaps(mock)
+
+	gaps, err := NewDataQualityService(db, nil).FindGaps("AAPL")
+	require.NoError(t, err)
+	assert.Empty(t, gaps)
+	assert.NotNil(t, gaps)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindGaps_SpansAHoliday(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Thursday to the Tuesday after Good Friday: only the Monday is missing
+	expectGaps(mock, storedGap{"AAPL", date2026(time.April, 2), date2026(time.April, 7)})
+
+	gaps, err := NewDataQualityService(db, nil).FindGaps("AAPL")
+	require.NoError(t, err)
+	require.Len(t, gaps, 1)
+	assert.Equal(t, DataGap{From: date2026(time.April, 6), To: date2026(time.April, 6), TradingDays: 1}, gaps[0])
+}
+
+func TestGapReport_MinGapDays(t *testing.T) {
+	tests := []struct {
+		minGapDays int
+		want       []string
+	}{
+		{minGapDays: 1, want: []string{"AAPL", "MSFT"}},
+		{minGapDays: 3, want: []string{"AAPL"}},
+		{minGapDays: 6, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+
+		expectGaps(mock, append(seededGaps,
+			storedGap{"MSFT", date2026(time.March, 2), date2026(time.March, 4)},
+			storedGap{"MSFT", date2026(time.March, 20), date2026(time.March, 24)})...)
+
+		report, err := NewDataQualityService(db, nil).GapReport(context.Background(), tt.minGapDays)
+		require.NoError(t, err)
+
+		symbols := []string{}
+		for _, symbol := range report {
+			symbols = append(symbols, symbol.Symbol)
+		}
+		assert.Equal(t, tt.want, symbols, "min_gap_days=%d", tt.minGapDays)
+		db.Close()
+	}
+}
+
+func TestGapReport_CountsMissingDays(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectGaps(mock,
+		storedGap{"MSFT", date2026(time.March, 2), date2026(time.March, 4)},
+		storedGap{"MSFT", date2026(time.March, 20), date2026(time.March, 24)})
+
+	report, err := NewDataQualityService(db, nil).GapReport(context.Background(), 1)
+	require.NoError(t, err)
+	require.Len(t, report, 1)
+	assert.Equal(t, 2, report[0].MissingDays, "March 3 and 23")
+	assert.Len(t, report[0].Gaps, 2)
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultAPICallRetention is how long API call logs are kept by the daily cleanup
+const DefaultAPICallRetention = 30 * 24 * time.Hour
+
+// DefaultPriceRetentionYears is how many years of daily prices a prune keeps
+const DefaultPriceRetentionYears = 5
+
+// DefaultPruneBatchSize is the most daily prices one transaction of a prune
+// deletes, so no transaction holds its row locks for long
+const DefaultPruneBatchSize = 10000
+
+// RetentionDaysFromEnv reads a retention in whole days from the environment
+// variable name, returning fallback when it is unset
+func RetentionDaysFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback, nil
+	}
+	days, err := strconv.Atoi(v)
+	if err != nil || days <= 0 {
+		return fallback, fmt.Errorf("%s must be a positive number of days, not %q", name, v)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// RetentionPolicy is how long a prune keeps each kind of old data
+type RetentionPolicy struct {
+	// PriceYears is how many years of daily prices are kept, counting back from today
+	PriceYears  int
+	APICalls    time.Duration
+	SyncHistory time.Duration
+}
+
+// DefaultRetentionPolicy keeps what the daily cleanup keeps, and
+// DefaultPriceRetentionYears of daily prices
+func DefaultRetentionPolicy() RetentionPolicy {
+	return RetentionPolicy{
+		PriceYears:  DefaultPriceRetentionYears,
+		APICalls:    DefaultAPICallRetention,
+		SyncHistory: SyncHistoryRetention,
+	}
+}
+
+// PriceCutoff is the oldest date of daily prices the policy keeps at now
+func (p RetentionPolicy) PriceCutoff(now time.Time) time.Time {
+	return marketDate(now).AddDate(-p.PriceYears, 0, 0)
+}
+
+// PruneCount is how many of a table's rows a prune deleted, or would delete
+type PruneCount struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+}
+
+// DataPruner deletes the daily prices, API call logs and sync history older
+// than a RetentionPolicy keeps them
+type DataPruner struct {
+	db        *sql.DB
+	history   *SyncHistoryService
+	batchSize int
+	now       func() time.Time
+}
+
+// NewDataPruner creates a pruner deleting DefaultPruneBatchSize daily prices per transaction
+func NewDataPruner(db *sql.DB) *DataPruner {
+	return &DataPruner{
+		db:        db,
+		history:   NewSyncHistoryService(db),
+		batchSize: DefaultPruneBatchSize,
+		now:       time.Now,
+	}
+}
+
+// SetBatchSize sets the most daily prices deleted per transaction
+func (p *DataPruner) SetBatchSize(size int) {
+	if size > 0 {
+		p.batchSize = size
+	}
+}
+
+// Count reports how many rows of each table a prune under policy would
+// delete now, deleting nothing
+func (p *DataPruner) Count(ctx context.Context, policy RetentionPolicy) ([]PruneCount, error) {
+	counts := []PruneCount{{Table: "daily_prices"}, {Table: "api_calls"}, {Table: "sync_history"}}
+	queries := []struct {
+		query string
+		arg   interface{}
+	}{
+		{`SELECT COUNT(*) FROM daily_prices WHERE date < $1`, policy.PriceCutoff(p.now())},
+		{`SELECT COUNT(*) FROM api_calls WHERE created_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 day'`, retentionDays(policy.APICalls)},
+		{`SELECT COUNT(*) FROM sync_history WHERE created_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 day'`, retentionDays(policy.SyncHistory)},
+	}
+	for i, q := range queries {
+		if err := p.db.QueryRowContext(ctx, q.query, q.arg).Scan(&counts[i].Rows); err != nil {
+			return nil, fmt.Errorf("failed to count old %s rows: %w", counts[i].Table, err)
+		}
+	}
+	return counts, nil
+}
+
+// Prune deletes the rows of each table older than policy keeps them and
+// returns how many went. Daily prices go oldest first, at most the batch
+// size per transaction. On error the counts cover what was deleted before it.
+func (p *DataPruner) Prune(ctx context.Context, policy RetentionPolicy) ([]PruneCount, error) {
+	counts := []PruneCount{{Table: "daily_prices"}, {Table: "api_calls"}, {Table: "sync_history"}}
+
+	var err error
+	if counts[0].Rows, err = p.pruneDailyPrices(ctx, policy.PriceCutoff(p.now())); err != nil {
+		return counts, err
+	}
+	if counts[1].Rows, err = pruneAPICalls(ctx, p.db, policy.APICalls); err != nil {
+		return counts, fmt.Errorf("failed to prune old API calls: %w", err)
+	}
+	if counts[2].Rows, err = p.history.Prune(ctx, policy.SyncHistory); err != nil {
+		return counts, fmt.Errorf("failed to prune old sync history: %w", err)
+	}
+	return counts, nil
+}
+
+// pruneDailyPrices deletes the daily prices dated before cutoff in batches,
+// each batch the oldest remaining rows, until a batch comes up short
+func (p *DataPruner) pruneDailyPrices(ctx context.Context, cutoff time.Time) (int64, error) {
+	var deleted int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+		rows, err := p.pruneDailyPriceBatch(ctx, cutoff)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to prune daily prices before %s after %d rows: %w",
+				cutoff.Format("2006-01-02"), deleted, err)
+		}
+		deleted += rows
+		if rows < int64(p.batchSize) {
+			return deleted, nil
+		}
+	}
+}
+
+// pruneDailyPriceBatch deletes one batch of daily prices dated before cutoff
+// and, in the same transaction, moves the price coverage of the stocks still
+// holding older prices up to what they have left
+func (p *DataPruner) pruneDailyPriceBatch(ctx context.Context, cutoff time.Time) (int64, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM daily_prices
+		WHERE id IN (
+		    SELECT id FROM daily_prices
+		    WHERE date < $1
+		    ORDER BY date, id
+		    LIMIT $2
+		)
+	`, cutoff, p.batchSize)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	// A stock whose prices all went has no coverage left
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE stocks s
+		SET first_price_date = (SELECT MIN(dp.date) FROM daily_prices dp WHERE dp.stock_id = s.id),
+		    last_price_date = (SELECT MAX(dp.date) FROM daily_prices dp WHERE dp.stock_id = s.id)
+		WHERE s.first_price_date < $1
+	`, cutoff); err != nil {
+		return 0, err
+	}
+	return rows, tx.Commit()
+}
+
+// pruneAPICalls deletes the API call logs older than retention
+func pruneAPICalls(ctx context.Context, db *sql.DB, retention time.Duration) (int64, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM api_calls WHERE created_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 day'`, retentionDays(retention))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// retentionDays is a retention in the whole days the cleanup queries take
+func retentionDays(retention time.Duration) int {
+	return int(retention / (24 * time.Hour))
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPruner(t *testing.T, batchSize int) (*DataPruner, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	pruner := NewDataPruner(db)
+	pruner.SetBatchSize(batchSize)
+	pruner.now = func() time.Time { return time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC) }
+	return pruner, mock
+}
+
+func TestDataPruner_PrunesInBatches(t *testing.T) {
+	pruner, mock := newTestPruner(t, 2)
+	cutoff := time.Date(2021, 10, 18, 0, 0, 0, 0, time.UTC)
+
+	// Five old rows go two at a time, the short third batch ending the prune
+	for _, rows := range []int64{2, 2, 1} {
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM daily_prices").WithArgs(cutoff, 2).WillReturnResult(sqlmock.NewResult(0, rows))
+		mock.ExpectExec(`UPDATE stocks s\s+SET first_price_date`).WithArgs(cutoff).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+	mock.ExpectExec("DELETE FROM api_calls").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 40))
+	mock.ExpectExec("DELETE FROM sync_history").WithArgs(14).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	counts, err := pruner.Prune(context.Background(), RetentionPolicy{
+		PriceYears: 5, APICalls: 7 * 24 * time.Hour, SyncHistory: 14 * 24 * time.Hour,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []PruneCount{
+		{Table: "daily_prices", Rows: 5},
+		{Table: "api_calls", Rows: 40},
+		{Table: "sync_history", Rows: 3},
+	}, counts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDataPruner_FailedBatchKeepsEarlierOnes(t *testing.T) {
+	pruner, mock := newTestPruner(t, 2)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM daily_prices").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`UPDATE stocks s\s+SET first_price_date`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM daily_prices").WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	counts, err := pruner.Prune(context.Background(), DefaultRetentionPolicy())
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.ErrorContains(t, err, "before 2021-10-18 after 2 rows")
+	assert.Equal(t, int64(2), counts[0].Rows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDataPruner_CountDeletesNothing(t *testing.T) {
+	pruner, mock := newTestPruner(t, DefaultPruneBatchSize)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM daily_prices WHERE date < \$1`).
+		WithArgs(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12345))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM api_calls`).WithArgs(30).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(678))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM sync_history`).WithArgs(90).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(9))
+
+	policy := DefaultRetentionPolicy()
+	policy.PriceYears = 3
+	counts, err := pruner.Count(context.Background(), policy)
+
+	require.NoError(t, err)
+	assert.Equal(t, []PruneCount{
+		{Table: "daily_prices", Rows: 12345},
+		{Table: "api_calls", Rows: 678},
+		{Table: "sync_history", Rows: 9},
+	}, counts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetentionDaysFromEnv(t *testing.T) {
+	t.Setenv("TEST_RETENTION_DAYS", "")
+	retention, err := RetentionDaysFromEnv("TEST_RETENTION_DAYS", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, retention)
+
+	t.Setenv("TEST_RETENTION_DAYS", "45")
+	retention, err = RetentionDaysFromEnv("TEST_RETENTION_DAYS", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 45*24*time.Hour, retention)
+
+	for _, invalid := range []string{"0", "-3", "two"} {
+		t.Setenv("TEST_RETENTION_DAYS", invalid)
+		retention, err = RetentionDaysFromEnv("TEST_RETENTION_DAYS", time.Hour)
+		assert.ErrorContains(t, err, "TEST_RETENTION_DAYS", invalid)
+		assert.Equal(t, time.Hour, retention, invalid)
+	}
+}
+
+func TestCleanupOldDataJob_UsesConfiguredRetention(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	scheduler := NewSchedulerService(db, NewAlphaVantageClient("test-key", db), nil, nil, nil)
+	scheduler.SetAPICallRetention(7 * 24 * time.Hour)
+	scheduler.SetSyncHistoryRetention(180 * 24 * time.Hour)
+
+	mock.ExpectExec("DELETE FROM api_calls").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM intraday_prices").WithArgs(30).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM sync_history").WithArgs(180).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM scheduler_runs").WillReturnResult(sqlmock.NewResult(0, 0))
+	expectSchedulerRun(mock, SchedulerJobCleanup, RunStatusSucceeded)
+
+	scheduler.cleanupOldDataJob()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, scheduler.GetRecentActivity(1).RecentErrors)
+}
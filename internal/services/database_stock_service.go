@@ -1,58 +1,144 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
 	"time"
 
 	"stock-intelligence-backend/internal/cache"
+	"stock-intelligence-backend/internal/database"
 	"stock-intelligence-backend/internal/models"
+
+	"github.com/lib/pq"
+	"golang.org/x/sync/singleflight"
 )
 
+// ErrStockNotFound is returned for a symbol that is not an active stock
+var ErrStockNotFound = errors.New("stock not found")
+
+// MissingStockTTL is how long a symbol found not to be a stock is answered
+// from the cache, so probing unknown symbols does not query the database
+const MissingStockTTL = time.Minute
+
 type DatabaseStockService struct {
-	db    *sql.DB
-	cache *cache.RedisCache
+	db                  *sql.DB
+	cache               *cache.RedisCache
+	router              *database.ReadRouter
+	priceRanges         *PriceRanges
+	maxChangePercent    float64
+	queryTimeout        time.Duration
+	marketCapThresholds MarketCapThresholds
+	// flight shares one load of an expired cache key among the requests missing it
+	flight     singleflight.Group
+	staleGrace time.Duration
 }
 
 func NewDatabaseStockService(db *sql.DB, redisCache *cache.RedisCache) *DatabaseStockService {
 	return &DatabaseStockService{
-		db:    db,
-		cache: redisCache,
+		db:                  db,
+		cache:               redisCache,
+		router:              database.NewReadRouter(db, nil, 0),
+		priceRanges:         DefaultPriceRanges(),
+		maxChangePercent:    DefaultMaxChangePercent,
+		queryTimeout:        DefaultQueryTimeout,
+		marketCapThresholds: DefaultMarketCapThresholds,
 	}
 }
 
-// GetAllStocks returns all stocks from the database with caching
-func (d *DatabaseStockService) GetAllStocks() []models.Stock {
-	// Try to get from cache first
-	if d.cache != nil {
-		var cachedStocks []models.Stock
-		err := d.cache.GetStocksList(&cachedStocks)
-		if err == nil && len(cachedStocks) > 0 {
-			log.Printf("Loaded %d stocks from cache", len(cachedStocks))
-			return cachedStocks
-		}
-	}
+// SetReadRouter routes the service's SELECT queries through router, typically to a read replica
+func (d *DatabaseStockService) SetReadRouter(router *database.ReadRouter) {
+	d.router = router
+}
+
+// SetPriceRanges sets the buckets used to compute each stock's price_range
+func (d *DatabaseStockService) SetPriceRanges(ranges *PriceRanges) {
+	d.priceRanges = ranges
+}
 
-	// Cache miss - fetch from database
-	stocks := d.fetchAllStocksFromDatabase()
+// SetMarketCapThresholds sets where the market cap bands of stock list filters start
+func (d *DatabaseStockService) SetMarketCapThresholds(thresholds MarketCapThresholds) {
+	d.marketCapThresholds = thresholds
+}
 
-	// Cache the results for 55 minutes (until next hourly update + safety margin)
-	if d.cache != nil && len(stocks) > 0 {
-		err := d.cache.SetStocksList(stocks, 55*time.Minute)
-		if err != nil {
-			log.Printf("Warning: Failed to cache stocks list: %v", err)
-		}
-	}
+// SetStaleWhileRefresh keeps the cached stock list, sector lists and pages and
+// market overview servable for grace after they expire, refreshing them in the
+// background meanwhile. Zero, the default, waits for the refresh instead.
+func (d *DatabaseStockService) SetStaleWhileRefresh(grace time.Duration) {
+	d.staleGrace = grace
+}
+
+// GetPriceRanges returns the price buckets in effect
+func (d *DatabaseStockService) GetPriceRanges() *PriceRanges {
+	return d.priceRanges
+}
 
+// rebucket returns a copy of stocks loaded from cache with their price buckets
+// recomputed, as they may have been bucketed under different boundaries. The
+// copy leaves a slice shared with other requests' loads untouched.
+func (d *DatabaseStockService) rebucket(stocks []models.Stock) []models.Stock {
+	stocks = slices.Clone(stocks)
+	for i := range stocks {
+		stocks[i].PriceRange = d.priceRanges.Resolve(stocks[i].CurrentPrice, stocks[i].PriceRange)
+	}
 	return stocks
 }
 
+// DailyChangeWindowDays is how many calendar days before the latest close
+// the close a daily change is measured from may be. A gap in the stored
+// prices beyond it leaves the stock without a daily change rather than
+// showing a multi-day move as one day's.
+const DailyChangeWindowDays = 5
+
+// latestPriceJoins joins each stock s to its latest daily price and the one
+// before it within DailyChangeWindowDays, as latest and previous, for current
+// price and daily change
+const latestPriceJoins = `
+		LEFT JOIN LATERAL (
+		    SELECT close_price, volume, date 
+		    FROM daily_prices 
+		    WHERE stock_id = s.id 
+		    ORDER BY date DESC 
+		    LIMIT 1
+		) latest ON true
+		LEFT JOIN LATERAL (
+		    SELECT close_price, date
+		    FROM daily_prices 
+		    WHERE stock_id = s.id AND date < latest.date AND date >= latest.date - 5
+		    ORDER BY date DESC 
+		    LIMIT 1
+		) previous ON true`
+
+// GetAllStocks returns all stocks from the database with caching
+func (d *DatabaseStockService) GetAllStocks(ctx context.Context) []models.Stock {
+	// Cache for 55 minutes (until next hourly update + safety margin); an empty list is not cached
+	stocks, _ := loadShared(ctx, d, cache.StocksListKey, 55*time.Minute, func(ctx context.Context) ([]models.Stock, bool, error) {
+		stocks := d.fetchAllStocksFromDatabase(ctx)
+		return stocks, len(stocks) > 0, nil
+	})
+
+	return d.rebucket(stocks)
+}
+
 // fetchAllStocksFromDatabase performs the actual database query
-func (d *DatabaseStockService) fetchAllStocksFromDatabase() []models.Stock {
+func (d *DatabaseStockService) fetchAllStocksFromDatabase(ctx context.Context) []models.Stock {
+	return d.fetchStocksFromDatabase(ctx, "")
+}
+
+// fetchStocksFromDatabase queries the active stocks that also meet filter, an
+// SQL condition on s taking args
+func (d *DatabaseStockService) fetchStocksFromDatabase(ctx context.Context, filter string, args ...interface{}) []models.Stock {
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	
 	query := `
 		SELECT s.id, s.symbol, s.company_name, s.sector, s.industry, s.market_cap, 
 		       s.price_range, s.exchange, s.is_active, s.created_at, s.updated_at,
+		       s.first_price_date, s.last_price_date,
 		       COALESCE(latest.close_price, 0) as current_price,
 		       COALESCE(latest.close_price - previous.close_price, 0) as daily_change,
 		       COALESCE(
@@ -61,29 +147,16 @@ func (d *DatabaseStockService) fetchAllStocksFromDatabase() []models.Stock {
 		           ELSE 0 END, 0
 		       ) as change_percent,
 		       COALESCE(latest.volume, 0) as volume,
-		       COALESCE(latest.date, s.updated_at) as last_updated
-		FROM stocks s
-		LEFT JOIN LATERAL (
-		    SELECT close_price, volume, date 
-		    FROM daily_prices 
-		    WHERE stock_id = s.id 
-		    ORDER BY date DESC 
-		    LIMIT 1
-		) latest ON true
-		LEFT JOIN LATERAL (
-		    SELECT close_price 
-		    FROM daily_prices 
-		    WHERE stock_id = s.id AND date < latest.date
-		    ORDER BY date DESC 
-		    LIMIT 1
-		) previous ON true
-		WHERE s.is_active = true
+		       COALESCE(latest.date, s.updated_at) as last_updated,
+		       COALESCE(latest.date - previous.date, 0) as change_period_days
+		FROM stocks s` + latestPriceJoins + `
+		WHERE s.is_active = true` + filter + `
 		ORDER BY s.symbol
 	`
 	
-	rows, err := d.db.Query(query)
+	rows, err := d.router.Reader().QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Printf("Error fetching stocks: %v", err)
+		slog.ErrorContext(ctx, "Error fetching stocks", "error", queryError(ctx, err))
 		return []models.Stock{}
 	}
 	defer rows.Close()
@@ -102,22 +175,18 @@ func (d *DatabaseStockService) fetchAllStocksFromDatabase() []models.Stock {
 			&stock.ID, &stock.Symbol, &stock.CompanyName, &stock.Sector, 
 			&stock.Industry, &stock.MarketCap, &priceRange, &stock.Exchange,
 			&stock.IsActive, &stock.CreatedAt, &stock.UpdatedAt,
+			&stock.FirstPriceDate, &stock.LastPriceDate,
 			&currentPrice, &dailyChange, &changePercent, &volume, &lastUpdated,
+			&stock.ChangePeriodDays,
 		)
 		if err != nil {
-			log.Printf("Error scanning stock: %v", err)
+			slog.ErrorContext(ctx, "Error scanning stock", "error", err)
 			continue
 		}
 		
-		// Set price range from database or use fallback
-		if priceRange.Valid {
-			stock.PriceRange = priceRange.String
-		} else {
-			stock.PriceRange = ""
-		}
-		
 		// Set computed fields from database data only
 		if currentPrice.Valid && currentPrice.Float64 > 0 {
+			stock.HasPriceData = true
 			stock.CurrentPrice = currentPrice.Float64
 			// Only set change values if they are valid (not null from database)
 			if dailyChange.Valid {
@@ -135,54 +204,52 @@ func (d *DatabaseStockService) fetchAllStocksFromDatabase() []models.Stock {
 			stock.Volume = 0
 		}
 		
+		RoundStockPrices(&stock)
 		stock.LastUpdated = lastUpdated
 		
-		// Ensure price range is set
-		if stock.PriceRange == "" {
-			stock.PriceRange = stock.GetPriceRange()
-		}
+		// Bucket by the latest close; the stored column only covers stocks without prices
+		stock.PriceRange = d.priceRanges.Resolve(stock.CurrentPrice, priceRange.String)
 		
 		stocks = append(stocks, stock)
 	}
+	// A cut-short read, e.g. a cancelled request, must not be returned and cached as the full list
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "Error reading stocks", "error", queryError(ctx, err))
+		return []models.Stock{}
+	}
 	
-	log.Printf("Loaded %d stocks from database", len(stocks))
+	slog.DebugContext(ctx, "Loaded stocks from database", "count", len(stocks))
 	return stocks
 }
 
-// GetAllStocksPaginated returns stocks with pagination support
-func (d *DatabaseStockService) GetAllStocksPaginated(limit, offset int) ([]models.Stock, int) {
+// GetAllStocksPaginated returns one page of the active stocks that filter
+// keeps, with their total count. Every filter is applied in SQL, so the count
+// and the pages agree.
+func (d *DatabaseStockService) GetAllStocksPaginated(ctx context.Context, filter StockFilter, limit, offset int) ([]models.Stock, int, error) {
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	
+	conditions := d.filterSQL(filter)
+	args := conditions.args
+	
 	// First get total count
 	var totalCount int
 	countQuery := `
 		SELECT COUNT(DISTINCT s.id)
-		FROM stocks s
-		LEFT JOIN LATERAL (
-		    SELECT close_price, volume, date 
-		    FROM daily_prices 
-		    WHERE stock_id = s.id 
-		    ORDER BY date DESC 
-		    LIMIT 1
-		) latest ON true
-		LEFT JOIN LATERAL (
-		    SELECT close_price 
-		    FROM daily_prices 
-		    WHERE stock_id = s.id AND date < latest.date
-		    ORDER BY date DESC 
-		    LIMIT 1
-		) previous ON true
-		WHERE s.is_active = true
+		FROM stocks s` + latestPriceJoins + `
+		WHERE s.is_active = true` + conditions.where() + `
 	`
 	
-	err := d.db.QueryRow(countQuery).Scan(&totalCount)
+	err := d.router.Reader().QueryRowContext(ctx, countQuery, args...).Scan(&totalCount)
 	if err != nil {
-		log.Printf("Error getting stock count: %v", err)
-		return []models.Stock{}, 0
+		return nil, 0, fmt.Errorf("failed to count stocks: %w", queryError(ctx, err))
 	}
 	
 	// Now get paginated results using the same query structure as GetAllStocks
 	query := `
 		SELECT s.id, s.symbol, s.company_name, s.sector, s.industry, s.market_cap, 
 		       s.price_range, s.exchange, s.is_active, s.created_at, s.updated_at,
+		       s.first_price_date, s.last_price_date,
 		       COALESCE(latest.close_price, 0) as current_price,
 		       COALESCE(latest.close_price - previous.close_price, 0) as daily_change,
 		       COALESCE(
@@ -191,200 +258,354 @@ func (d *DatabaseStockService) GetAllStocksPaginated(limit, offset int) ([]model
 		           ELSE 0 END, 0
 		       ) as change_percent,
 		       COALESCE(latest.volume, 0) as volume,
-		       COALESCE(latest.date, s.updated_at) as last_updated
-		FROM stocks s
-		LEFT JOIN LATERAL (
-		    SELECT close_price, volume, date 
-		    FROM daily_prices 
-		    WHERE stock_id = s.id 
-		    ORDER BY date DESC 
-		    LIMIT 1
-		) latest ON true
-		LEFT JOIN LATERAL (
-		    SELECT close_price 
-		    FROM daily_prices 
-		    WHERE stock_id = s.id AND date < latest.date
-		    ORDER BY date DESC 
-		    LIMIT 1
-		) previous ON true
-		WHERE s.is_active = true
-		ORDER BY s.market_cap DESC, s.symbol
-		LIMIT $1 OFFSET $2
-	`
+		       COALESCE(latest.date, s.updated_at) as last_updated,
+		       COALESCE(latest.date - previous.date, 0) as change_period_days
+		FROM stocks s` + latestPriceJoins + `
+		WHERE s.is_active = true` + conditions.where() + fmt.Sprintf(`
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+	`, filter.Sort.orderBy(), len(args)+1, len(args)+2)
 	
-	rows, err := d.db.Query(query, limit, offset)
+	rows, err := d.router.Reader().QueryContext(ctx, query, append(args, limit, offset)...)
 	if err != nil {
-		log.Printf("Error fetching paginated stocks: %v", err)
-		return []models.Stock{}, totalCount
+		return nil, 0, fmt.Errorf("failed to fetch stocks: %w", queryError(ctx, err))
 	}
 	defer rows.Close()
 	
+	stocks := d.scanStockRows(ctx, rows)
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read stocks: %w", queryError(ctx, err))
+	}
+	
+	slog.DebugContext(ctx, "Loaded stocks from database", "count", len(stocks), "page", offset/limit+1, "limit", limit)
+	return stocks, totalCount, nil
+}
+
+
+// scanStockRows reads stock rows produced by the latest/previous price lateral-join query
+func (d *DatabaseStockService) scanStockRows(ctx context.Context, rows *sql.Rows) []models.Stock {
 	var stocks []models.Stock
 	for rows.Next() {
-		var stock models.Stock
-		var currentPrice sql.NullFloat64
-		var dailyChange sql.NullFloat64
-		var changePercent sql.NullFloat64
-		var volume sql.NullInt64
-		var lastUpdated time.Time
-		var priceRange sql.NullString
-		
-		err := rows.Scan(
-			&stock.ID, &stock.Symbol, &stock.CompanyName, &stock.Sector, 
-			&stock.Industry, &stock.MarketCap, &priceRange, &stock.Exchange,
-			&stock.IsActive, &stock.CreatedAt, &stock.UpdatedAt,
-			&currentPrice, &dailyChange, &changePercent, &volume, &lastUpdated,
-		)
+		stock, err := d.scanStockRow(rows)
 		if err != nil {
-			log.Printf("Error scanning stock: %v", err)
+			slog.ErrorContext(ctx, "Error scanning stock", "error", err)
 			continue
 		}
-		
-		// Set price range from database or use fallback
-		if priceRange.Valid {
-			stock.PriceRange = priceRange.String
-		} else {
-			stock.PriceRange = ""
-		}
-		
-		// Set computed fields from database data only
-		if currentPrice.Valid && currentPrice.Float64 > 0 {
-			stock.CurrentPrice = currentPrice.Float64
-			// Only set change values if they are valid (not null from database)
-			if dailyChange.Valid {
-				stock.DailyChange = dailyChange.Float64
-			}
-			if changePercent.Valid {
-				stock.ChangePercent = changePercent.Float64
-			}
-			stock.Volume = volume.Int64
-		} else {
-			// Set default values for stocks without price data
-			stock.CurrentPrice = 0.0
-			stock.DailyChange = 0.0
-			stock.ChangePercent = 0.0
-			stock.Volume = 0
-		}
-		
-		stock.LastUpdated = lastUpdated
-		
-		// Ensure price range is set
-		if stock.PriceRange == "" {
-			stock.PriceRange = stock.GetPriceRange()
-		}
-		
 		stocks = append(stocks, stock)
 	}
 	
-	log.Printf("Loaded %d stocks from database (page %d, limit %d)", len(stocks), offset/limit+1, limit)
-	return stocks, totalCount
+	return stocks
 }
 
+// scanStockRow reads the current row as scanStockRows does, scanning any
+// columns following the stock's into extra
+func (d *DatabaseStockService) scanStockRow(rows *sql.Rows, extra ...interface{}) (models.Stock, error) {
+	var stock models.Stock
+	var currentPrice sql.NullFloat64
+	var dailyChange sql.NullFloat64
+	var changePercent sql.NullFloat64
+	var volume sql.NullInt64
+	var lastUpdated time.Time
+	var priceRange sql.NullString
+	
+	err := rows.Scan(append([]interface{}{
+		&stock.ID, &stock.Symbol, &stock.CompanyName, &stock.Sector, 
+		&stock.Industry, &stock.MarketCap, &priceRange, &stock.Exchange,
+		&stock.IsActive, &stock.CreatedAt, &stock.UpdatedAt,
+		&stock.FirstPriceDate, &stock.LastPriceDate,
+		&currentPrice, &dailyChange, &changePercent, &volume, &lastUpdated,
+		&stock.ChangePeriodDays,
+	}, extra...)...)
+	if err != nil {
+		return models.Stock{}, err
+	}
+	
+	// Set computed fields from database data only
+	if currentPrice.Valid && currentPrice.Float64 > 0 {
+		stock.HasPriceData = true
+		stock.CurrentPrice = currentPrice.Float64
+		// Only set change values if they are valid (not null from database)
+		if dailyChange.Valid {
+			stock.DailyChange = dailyChange.Float64
+		}
+		if changePercent.Valid {
+			stock.ChangePercent = changePercent.Float64
+		}
+		stock.Volume = volume.Int64
+	} else {
+		// Set default values for stocks without price data
+		stock.CurrentPrice = 0.0
+		stock.DailyChange = 0.0
+		stock.ChangePercent = 0.0
+		stock.Volume = 0
+	}
+	
+	RoundStockPrices(&stock)
+	stock.LastUpdated = lastUpdated
+	
+	// Bucket by the latest close; the stored column only covers stocks without prices
+	stock.PriceRange = d.priceRanges.Resolve(stock.CurrentPrice, priceRange.String)
+	
+	return stock, nil
+}
 
-// GetStockBySymbol returns a specific stock by symbol
-func (d *DatabaseStockService) GetStockBySymbol(symbol string) (*models.Stock, error) {
+// GetStockBySymbol returns a specific stock by symbol, in any case and with
+// either class share separator. A stock that has not been synced yet is
+// returned with zero prices and HasPriceData false, and a delisted one with
+// IsActive false. An unknown or deactivated symbol is an ErrStockNotFound,
+// remembered for MissingStockTTL.
+func (d *DatabaseStockService) GetStockBySymbol(ctx context.Context, symbol string) (*models.Stock, error) {
+	symbol, err := models.NormalizeSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if d.cache != nil {
+		if missing, err := d.cache.IsStockMissing(ctx, symbol); err == nil && missing {
+			return nil, fmt.Errorf("%w: %s", ErrStockNotFound, symbol)
+		}
+	}
+	
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	
 	query := `
 		SELECT s.id, s.symbol, s.company_name, s.sector, s.industry, s.market_cap,
-		       s.price_range, s.exchange, s.is_active, s.created_at, s.updated_at
+		       s.price_range, s.exchange, s.is_active, s.created_at, s.updated_at,
+		       s.first_price_date, s.last_price_date
 		FROM stocks s
-		WHERE s.symbol = $1 AND s.is_active = true
+		WHERE s.symbol = $1 AND (s.is_active = true OR s.delisted_at IS NOT NULL)
 	`
 	
 	var stock models.Stock
 	var priceRange sql.NullString
-	err := d.db.QueryRow(query, symbol).Scan(
+	err = d.router.ReaderFor(symbol).QueryRowContext(ctx, query, symbol).Scan(
 		&stock.ID, &stock.Symbol, &stock.CompanyName, &stock.Sector,
 		&stock.Industry, &stock.MarketCap, &priceRange, &stock.Exchange,
 		&stock.IsActive, &stock.CreatedAt, &stock.UpdatedAt,
+		&stock.FirstPriceDate, &stock.LastPriceDate,
 	)
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("stock not found: %s", symbol)
+			if d.cache != nil {
+				if err := d.cache.MarkStockMissing(ctx, symbol, MissingStockTTL); err != nil {
+					slog.WarnContext(ctx, "Failed to cache missing stock", "symbol", symbol, "error", err)
+				}
+			}
+			return nil, fmt.Errorf("%w: %s", ErrStockNotFound, symbol)
 		}
-		return nil, fmt.Errorf("database error: %w", err)
-	}
-	
-	// Set price range from database or use fallback
-	if priceRange.Valid {
-		stock.PriceRange = priceRange.String
-	} else {
-		stock.PriceRange = ""
+		return nil, fmt.Errorf("database error: %w", queryError(ctx, err))
 	}
 	
-	// Get latest price data
+	// Get latest price data, with the daily change measured as in the lists
 	priceQuery := `
-		SELECT close_price, volume, date,
-		       close_price - LAG(close_price) OVER (ORDER BY date) as daily_change,
-		       ((close_price - LAG(close_price) OVER (ORDER BY date)) / 
-		        LAG(close_price) OVER (ORDER BY date) * 100) as change_percent
-		FROM daily_prices 
-		WHERE stock_id = $1 
-		ORDER BY date DESC 
-		LIMIT 1
+		SELECT latest.close_price, latest.volume, latest.date,
+		       latest.close_price - previous.close_price as daily_change,
+		       CASE WHEN previous.close_price > 0 THEN
+		           ((latest.close_price - previous.close_price) / previous.close_price * 100)
+		       END as change_percent,
+		       COALESCE(latest.date - previous.date, 0) as change_period_days
+		FROM stocks s` + latestPriceJoins + `
+		WHERE s.id = $1 AND latest.date IS NOT NULL
 	`
 	
 	var currentPrice, dailyChange, changePercent sql.NullFloat64
 	var volume sql.NullInt64
 	var lastUpdated time.Time
+	var changePeriodDays int
 	
-	err = d.db.QueryRow(priceQuery, stock.ID).Scan(
-		&currentPrice, &volume, &lastUpdated, &dailyChange, &changePercent,
+	err = d.router.ReaderFor(symbol).QueryRowContext(ctx, priceQuery, stock.ID).Scan(
+		&currentPrice, &volume, &lastUpdated, &dailyChange, &changePercent, &changePeriodDays,
 	)
 	
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("database error: %w", queryError(ctx, err))
+	}
+	
 	if err == nil && currentPrice.Valid {
+		stock.HasPriceData = true
 		stock.CurrentPrice = currentPrice.Float64
 		stock.DailyChange = dailyChange.Float64
 		stock.ChangePercent = changePercent.Float64
+		stock.ChangePeriodDays = changePeriodDays
+		RoundStockPrices(&stock)
 		stock.Volume = volume.Int64
 		stock.LastUpdated = lastUpdated
-	} else {
-		// Return error if no price data available - database-only mode
-		return nil, fmt.Errorf("no price data available for stock: %s", symbol)
 	}
+	stock.PriceRange = d.priceRanges.Resolve(stock.CurrentPrice, priceRange.String)
 	
 	return &stock, nil
 }
 
 // GetStocksBySector returns stocks filtered by sector with caching
-func (d *DatabaseStockService) GetStocksBySector(sector string) []models.Stock {
-	// Try to get from cache first
+func (d *DatabaseStockService) GetStocksBySector(ctx context.Context, sector string) []models.Stock {
+	// Cache for 55 minutes (until next hourly update + safety margin); an empty sector is not cached
+	filtered, _ := loadShared(ctx, d, cache.SectorDataKey(sector), 55*time.Minute, func(ctx context.Context) ([]models.Stock, bool, error) {
+		var filtered []models.Stock
+		for _, stock := range d.GetAllStocks(ctx) {
+			if stock.Sector == sector {
+				filtered = append(filtered, stock)
+			}
+		}
+		return filtered, len(filtered) > 0, nil
+	})
+	
+	return d.rebucket(filtered)
+}
+
+// GetStocksBySectors returns the stocks of each of several sectors, keyed by
+// sector. The cached sectors are read in one round trip, the rest loaded in
+// one query and cached together, so listing every sector costs at most two
+// Redis round trips and one query. Sectors without stocks map to nothing and,
+// as in GetStocksBySector, are not cached.
+func (d *DatabaseStockService) GetStocksBySectors(ctx context.Context, sectors []string) map[string][]models.Stock {
+	result := make(map[string][]models.Stock, len(sectors))
+	missing := slices.Clone(sectors)
 	if d.cache != nil {
-		var cachedStocks []models.Stock
-		err := d.cache.GetSectorData(sector, &cachedStocks)
-		if err == nil && len(cachedStocks) > 0 {
-			log.Printf("Loaded %d stocks for sector '%s' from cache", len(cachedStocks), sector)
-			return cachedStocks
+		if cached, err := d.cache.GetMultiSectorData(ctx, sectors); err == nil {
+			missing = missing[:0]
+			for _, sector := range sectors {
+				var stocks []models.Stock
+				if data, ok := cached[sector]; ok && json.Unmarshal(data, &stocks) == nil {
+					result[sector] = stocks
+					continue
+				}
+				missing = append(missing, sector)
+			}
 		}
 	}
-
-	// Cache miss - filter from all stocks
-	allStocks := d.GetAllStocks()
-	var filtered []models.Stock
 	
-	for _, stock := range allStocks {
-		if stock.Sector == sector {
-			filtered = append(filtered, stock)
+	if len(missing) > 0 {
+		loaded := make(map[string]interface{})
+		for _, stock := range d.fetchStocksFromDatabase(ctx, " AND s.sector = ANY($1)", pq.Array(missing)) {
+			result[stock.Sector] = append(result[stock.Sector], stock)
+		}
+		for _, sector := range missing {
+			if stocks, ok := result[sector]; ok {
+				loaded[sector] = stocks
+			}
+		}
+		if d.cache != nil {
+			// Cache for 55 minutes (until next hourly update + safety margin), as GetStocksBySector does
+			if err := d.cache.SetMultiSectorData(context.WithoutCancel(ctx), loaded, 55*time.Minute, d.staleGrace); err != nil {
+				slog.WarnContext(ctx, "Failed to cache sectors", "sectors", len(loaded), "error", err)
+			}
 		}
 	}
+	
+	for sector, stocks := range result {
+		result[sector] = d.rebucket(stocks)
+	}
+	return result
+}
 
-	// Cache the sector results for 55 minutes (until next hourly update + safety margin)
-	if d.cache != nil && len(filtered) > 0 {
-		err := d.cache.SetSectorData(sector, filtered, 55*time.Minute)
+// GetStocksBySectorPaginated returns one page of a sector's stocks along with the
+// sector's total count, filtering and paginating in SQL rather than in memory.
+// A positive minHistoryDays filters as in GetAllStocksPaginated; those pages are not cached.
+func (d *DatabaseStockService) GetStocksBySectorPaginated(ctx context.Context, sector string, limit, offset, minHistoryDays int) ([]models.Stock, int, error) {
+	if minHistoryDays > 0 {
+		page, err := d.fetchSectorPage(ctx, sector, limit, offset, minHistoryDays)
 		if err != nil {
-			log.Printf("Warning: Failed to cache sector data for '%s': %v", sector, err)
+			return nil, 0, err
 		}
+		return page.Stocks, page.Total, nil
+	}
+
+	// Cache the page for 55 minutes (until next hourly update + safety margin)
+	page, err := loadShared(ctx, d, cache.SectorPageKey(sector, limit, offset), 55*time.Minute, func(ctx context.Context) (sectorPage, bool, error) {
+		page, err := d.fetchSectorPage(ctx, sector, limit, offset, 0)
+		return page, err == nil, err
+	})
+	if err != nil {
+		return nil, 0, err
 	}
 	
-	return filtered
+	return d.rebucket(page.Stocks), page.Total, nil
+}
+
+// fetchSectorPage queries one page of a sector's stocks and the sector's total count
+func (d *DatabaseStockService) fetchSectorPage(ctx context.Context, sector string, limit, offset, minHistoryDays int) (sectorPage, error) {
+	args := []interface{}{sector}
+	coverageFilter := ""
+	if minHistoryDays > 0 {
+		args = append(args, minHistoryDays)
+		coverageFilter = minHistoryFilter(len(args))
+	}
+	
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+
+	var totalCount int
+	countQuery := `
+		SELECT COUNT(*)
+		FROM stocks s
+		WHERE s.is_active = true AND s.sector = $1` + coverageFilter + `
+	`
+	
+	err := d.router.Reader().QueryRowContext(ctx, countQuery, args...).Scan(&totalCount)
+	if err != nil {
+		return sectorPage{}, fmt.Errorf("failed to count stocks in sector %s: %w", sector, queryError(ctx, err))
+	}
+	
+	stocks := []models.Stock{}
+	if offset < totalCount {
+		query := `
+			SELECT s.id, s.symbol, s.company_name, s.sector, s.industry, s.market_cap, 
+			       s.price_range, s.exchange, s.is_active, s.created_at, s.updated_at,
+			       s.first_price_date, s.last_price_date,
+			       COALESCE(latest.close_price, 0) as current_price,
+			       COALESCE(latest.close_price - previous.close_price, 0) as daily_change,
+			       COALESCE(
+			           CASE WHEN previous.close_price > 0 THEN
+			               ((latest.close_price - previous.close_price) / previous.close_price * 100)
+			           ELSE 0 END, 0
+			       ) as change_percent,
+			       COALESCE(latest.volume, 0) as volume,
+			       COALESCE(latest.date, s.updated_at) as last_updated,
+			       COALESCE(latest.date - previous.date, 0) as change_period_days
+			FROM stocks s` + latestPriceJoins + `
+			WHERE s.is_active = true AND s.sector = $1` + coverageFilter + fmt.Sprintf(`
+			ORDER BY s.market_cap DESC, s.symbol
+			LIMIT $%d OFFSET $%d
+		`, len(args)+1, len(args)+2)
+		
+		rows, err := d.router.Reader().QueryContext(ctx, query, append(args, limit, offset)...)
+		if err != nil {
+			return sectorPage{}, fmt.Errorf("failed to fetch stocks in sector %s: %w", sector, queryError(ctx, err))
+		}
+		defer rows.Close()
+		
+		if page := d.scanStockRows(ctx, rows); page != nil {
+			stocks = page
+		}
+		// A cut-short page must not be cached
+		if err := rows.Err(); err != nil {
+			return sectorPage{}, fmt.Errorf("failed to read stocks in sector %s: %w", sector, queryError(ctx, err))
+		}
+	}
+	
+	return sectorPage{Stocks: stocks, Total: totalCount}, nil
+}
+
+// minHistoryFilter restricts a stock query to stocks whose stored prices span at
+// least the number of days bound to placeholder $argPos
+func minHistoryFilter(argPos int) string {
+	return fmt.Sprintf(" AND s.last_price_date - s.first_price_date >= $%d", argPos)
+}
+
+// sectorPage is the cached form of a single page of sector results
+type sectorPage struct {
+	Stocks []models.Stock `json:"stocks"`
+	Total  int            `json:"total"`
 }
 
 // GetStocksByPriceRange returns stocks filtered by price range
-func (d *DatabaseStockService) GetStocksByPriceRange(priceRange string) []models.Stock {
-	allStocks := d.GetAllStocks()
+func (d *DatabaseStockService) GetStocksByPriceRange(ctx context.Context, priceRange string) []models.Stock {
+	allStocks := d.GetAllStocks(ctx)
 	var filtered []models.Stock
 	
 	for _, stock := range allStocks {
+		// GetAllStocks has already bucketed each stock by its latest close
 		if stock.PriceRange == priceRange {
 			filtered = append(filtered, stock)
 		}
@@ -393,7 +614,205 @@ func (d *DatabaseStockService) GetStocksByPriceRange(priceRange string) []models
 	return filtered
 }
 
+// GetPriceHistory returns a stock's latest days of daily closes, oldest first.
+// Adjusted closes stay continuous across splits; raw closes show a split as a gap.
+func (d *DatabaseStockService) GetPriceHistory(ctx context.Context, symbol string, days int, adjusted bool) ([]models.PricePoint, error) {
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	
+	priceColumn := "dp.close_price"
+	if adjusted {
+		priceColumn = "dp.adjusted_close"
+	}
+	
+	query := `
+		SELECT dp.date, ` + priceColumn + `, dp.volume
+		FROM daily_prices dp
+		JOIN stocks s ON dp.stock_id = s.id
+		WHERE s.symbol = $1
+		ORDER BY dp.date DESC
+		LIMIT $2
+	`
+	
+	rows, err := d.router.ReaderFor(symbol).QueryContext(ctx, query, symbol, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price history: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+	
+	var points []models.PricePoint
+	for rows.Next() {
+		var point models.PricePoint
+		if err := rows.Scan(&point.Date, &point.Price, &point.Volume); err != nil {
+			continue // Skip invalid rows
+		}
+		points = append(points, point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read price history: %w", queryError(ctx, err))
+	}
+	
+	// Newest first from the query; callers want chronological order
+	for i := 0; i < len(points)/2; i++ {
+		j := len(points) - 1 - i
+		points[i], points[j] = points[j], points[i]
+	}
+	
+	return points, nil
+}
+
+// GetPriceHistories returns the latest days of daily closes of each of
+// symbols, oldest first, in one query. Symbols without stored prices are
+// left out of the map.
+func (d *DatabaseStockService) GetPriceHistories(ctx context.Context, symbols []string, days int, adjusted bool) (map[string][]models.PricePoint, error) {
+	histories := make(map[string][]models.PricePoint, len(symbols))
+	if len(symbols) == 0 {
+		return histories, nil
+	}
+	
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	
+	priceColumn := "dp.close_price"
+	if adjusted {
+		priceColumn = "dp.adjusted_close"
+	}
+	
+	query := `
+		SELECT symbol, date, price, volume
+		FROM (
+		    SELECT s.symbol, dp.date, ` + priceColumn + ` as price, dp.volume,
+		           ROW_NUMBER() OVER (PARTITION BY dp.stock_id ORDER BY dp.date DESC) as n
+		    FROM daily_prices dp
+		    JOIN stocks s ON dp.stock_id = s.id
+		    WHERE s.symbol = ANY($1)
+		) recent
+		WHERE n <= $2
+		ORDER BY symbol, date
+	`
+	
+	rows, err := d.router.Reader().QueryContext(ctx, query, pq.Array(symbols), days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price histories: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+	
+	for rows.Next() {
+		var symbol string
+		var point models.PricePoint
+		if err := rows.Scan(&symbol, &point.Date, &point.Price, &point.Volume); err != nil {
+			continue // Skip invalid rows
+		}
+		histories[symbol] = append(histories[symbol], point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read price histories: %w", queryError(ctx, err))
+	}
+	
+	return histories, nil
+}
+
+// GetIntradayPrices returns a stock's intraday bars at interval for one trading
+// day, oldest first. A zero date selects the most recent day with bars.
+func (d *DatabaseStockService) GetIntradayPrices(ctx context.Context, symbol, interval string, date time.Time) ([]models.IntradayPrice, error) {
+	if err := ValidateIntradayInterval(interval); err != nil {
+		return nil, err
+	}
+	
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	
+	var day interface{}
+	if !date.IsZero() {
+		day = date.Format("2006-01-02")
+	}
+	
+	query := `
+		SELECT ip."timestamp", ip.open_price, ip.high_price, ip.low_price, ip.close_price, ip.volume
+		FROM intraday_prices ip
+		JOIN stocks s ON ip.stock_id = s.id
+		WHERE s.symbol = $1
+		  AND ip.bar_interval = $2
+		  AND ip."timestamp"::date = COALESCE($3::date, (
+		      SELECT MAX(latest."timestamp")::date
+		      FROM intraday_prices latest
+		      WHERE latest.stock_id = s.id AND latest.bar_interval = $2
+		  ))
+		ORDER BY ip."timestamp"
+	`
+	
+	rows, err := d.router.ReaderFor(symbol).QueryContext(ctx, query, symbol, interval, day)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query intraday prices: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+	
+	prices := make([]models.IntradayPrice, 0)
+	for rows.Next() {
+		var price models.IntradayPrice
+		if err := rows.Scan(&price.Timestamp, &price.Open, &price.High, &price.Low, &price.Close, &price.Volume); err != nil {
+			return nil, fmt.Errorf("failed to scan intraday price: %w", err)
+		}
+		prices = append(prices, price)
+	}
+	
+	return prices, queryError(ctx, rows.Err())
+}
+
+// GetCompanyFundamentals returns a stock's stored fundamentals however old they
+// are; callers judge freshness from FetchedAt. sql.ErrNoRows means none are stored.
+func (d *DatabaseStockService) GetCompanyFundamentals(ctx context.Context, symbol string) (*models.CompanyFundamentals, error) {
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+	
+	query := `
+		SELECT s.symbol, cf.pe_ratio, cf.eps, cf.dividend_yield, cf.beta,
+		       cf.shares_outstanding, COALESCE(cf.description, ''), cf.fetched_at
+		FROM company_fundamentals cf
+		JOIN stocks s ON cf.stock_id = s.id
+		WHERE s.symbol = $1
+	`
+	
+	var fundamentals models.CompanyFundamentals
+	var peRatio, eps, dividendYield, beta sql.NullFloat64
+	var sharesOutstanding sql.NullInt64
+	err := d.router.ReaderFor(symbol).QueryRowContext(ctx, query, symbol).Scan(
+		&fundamentals.Symbol, &peRatio, &eps, &dividendYield, &beta,
+		&sharesOutstanding, &fundamentals.Description, &fundamentals.FetchedAt,
+	)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	
+	fundamentals.PERatio = nullFloatPtr(peRatio)
+	fundamentals.EPS = nullFloatPtr(eps)
+	fundamentals.DividendYield = nullFloatPtr(dividendYield)
+	fundamentals.Beta = nullFloatPtr(beta)
+	if sharesOutstanding.Valid {
+		fundamentals.SharesOutstanding = &sharesOutstanding.Int64
+	}
+	
+	return &fundamentals, nil
+}
+
+func nullFloatPtr(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
+
 // GetDB returns the database connection for direct queries
 func (d *DatabaseStockService) GetDB() *sql.DB {
 	return d.db
+}
+
+// GetReadDB returns the handle to use for read-only queries about symbol
+func (d *DatabaseStockService) GetReadDB(symbol string) *sql.DB {
+	return d.router.ReaderFor(symbol)
+}
+
+// GetCache returns the Redis cache, or nil when caching is disabled
+func (d *DatabaseStockService) GetCache() *cache.RedisCache {
+	return d.cache
 }
\ No newline at end of file
@@ -1,13 +1,21 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"sync"
 	"testing"
 	"time"
 
 	"stock-intelligence-backend/internal/cache"
+	"stock-intelligence-backend/internal/database"
+	"stock-intelligence-backend/internal/models"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redismock/v8"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -34,22 +42,25 @@ func TestGetAllStocks_DatabaseQuery(t *testing.T) {
 	rows := sqlmock.NewRows([]string{
 		"id", "symbol", "company_name", "sector", "industry", "market_cap",
 		"price_range", "exchange", "is_active", "created_at", "updated_at",
-		"current_price", "daily_change", "change_percent", "volume", "last_updated",
+		"first_price_date", "last_price_date",
+		"current_price", "daily_change", "change_percent", "volume", "last_updated", "change_period_days",
 	}).AddRow(
 		1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(3000000000000),
 		"$100+", "NASDAQ", true, time.Now(), time.Now(),
-		150.0, 2.5, 1.69, int64(50000000), time.Now(),
+		nil, nil,
+		150.0, 2.5, 1.69, int64(50000000), time.Now(), 1,
 	).AddRow(
 		2, "MSFT", "Microsoft Corporation", "Technology", "Software", int64(2800000000000),
 		"$100+", "NASDAQ", true, time.Now(), time.Now(),
-		380.0, -1.2, -0.31, int64(30000000), time.Now(),
+		nil, nil,
+		380.0, -1.2, -0.31, int64(30000000), time.Now(), 1,
 	)
 
 	// Expect any query starting with SELECT
 	mock.ExpectQuery("SELECT").WillReturnRows(rows)
 
 	service := NewDatabaseStockService(db, nil) // No cache for this test
-	stocks := service.GetAllStocks()
+	stocks := service.GetAllStocks(context.Background())
 
 	assert.Len(t, stocks, 2)
 	assert.Equal(t, "AAPL", stocks[0].Symbol)
@@ -58,6 +69,138 @@ func TestGetAllStocks_DatabaseQuery(t *testing.T) {
 	assert.Equal(t, 380.0, stocks[1].CurrentPrice)
 }
 
+func TestGetAllStocks_ConcurrentMissesShareOneQuery(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+
+	// Slow enough for every caller to miss the cache while it runs
+	mock.ExpectQuery(`FROM stocks s`).WillDelayFor(100 * time.Millisecond).WillReturnRows(rankedStocks(1.5, "AAPL"))
+
+	results := make([][]models.Stock, 50)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = service.GetAllStocks(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	for _, stocks := range results {
+		require.Len(t, stocks, 1)
+		assert.Equal(t, "AAPL", stocks[0].Symbol)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllStocks_ServesStaleWhileRefreshing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache("redis://"+server.Addr(), "test:")
+	require.NoError(t, err)
+	defer redisCache.Close()
+
+	service := NewDatabaseStockService(db, redisCache)
+	service.SetStaleWhileRefresh(10 * time.Minute)
+
+	mock.ExpectQuery(`FROM stocks s`).WillReturnRows(rankedStocks(1.5, "OLD"))
+	assert.Equal(t, "OLD", service.GetAllStocks(context.Background())[0].Symbol)
+
+	// Past its expiry the list is served stale while one refresh runs
+	server.FastForward(56 * time.Minute)
+	mock.ExpectQuery(`FROM stocks s`).WillDelayFor(50 * time.Millisecond).WillReturnRows(rankedStocks(1.5, "NEW"))
+	assert.Equal(t, "OLD", service.GetAllStocks(context.Background())[0].Symbol)
+	assert.Equal(t, "OLD", service.GetAllStocks(context.Background())[0].Symbol)
+
+	// Joining the refresh waits for it
+	service.flight.Do(cache.StocksListKey, func() (interface{}, error) { return nil, nil })
+	assert.Equal(t, "NEW", service.GetAllStocks(context.Background())[0].Symbol)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllStocks_FallsBackToDatabaseWhenRedisTimesOut(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache("redis://"+server.Addr(), "test:")
+	require.NoError(t, err)
+	defer redisCache.Close()
+	require.NoError(t, redisCache.SetStocksList(context.Background(), []models.Stock{{Symbol: "CACHED"}}, time.Hour))
+
+	// Every command's deadline has passed before it is sent
+	redisCache.SetOperationTimeout(time.Nanosecond)
+	mock.ExpectQuery(`FROM stocks s`).WillReturnRows(rankedStocks(1.5, "AAPL"))
+
+	stocks := NewDatabaseStockService(db, redisCache).GetAllStocks(context.Background())
+	require.Len(t, stocks, 1)
+	assert.Equal(t, "AAPL", stocks[0].Symbol)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllStocks_DailyChangeOnlyWithinWindow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Monday's close against Friday's, and a stock whose prices stop three
+	// trading days before its latest close, which has no daily change
+	rows := sqlmock.NewRows([]string{
+		"id", "symbol", "company_name", "sector", "industry", "market_cap",
+		"price_range", "exchange", "is_active", "created_at", "updated_at",
+		"first_price_date", "last_price_date",
+		"current_price", "daily_change", "change_percent", "volume", "last_updated", "change_period_days",
+	}).AddRow(
+		1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(3000000000000),
+		"$100+", "NASDAQ", true, time.Now(), time.Now(),
+		nil, nil,
+		150.0, 2.5, 1.69, int64(50000000), time.Now(), 3,
+	).AddRow(
+		2, "GAP", "Gap Inc.", "Consumer Cyclical", "Apparel Retail", int64(8000000000),
+		"$10-50", "NYSE", true, time.Now(), time.Now(),
+		nil, nil,
+		21.0, 0.0, 0.0, int64(4000000), time.Now(), 0,
+	)
+	mock.ExpectQuery(`date < latest.date AND date >= latest.date - 5`).WillReturnRows(rows)
+
+	stocks := NewDatabaseStockService(db, nil).GetAllStocks(context.Background())
+
+	require.Len(t, stocks, 2)
+	assert.Equal(t, 3, stocks[0].ChangePeriodDays)
+	assert.Equal(t, 2.5, stocks[0].DailyChange)
+	assert.Equal(t, 0, stocks[1].ChangePeriodDays)
+	assert.Zero(t, stocks[1].DailyChange)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllStocks_RoundsPrices(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`FROM stocks s`).WillReturnRows(sqlmock.NewRows([]string{
+		"id", "symbol", "company_name", "sector", "industry", "market_cap",
+		"price_range", "exchange", "is_active", "created_at", "updated_at",
+		"first_price_date", "last_price_date",
+		"current_price", "daily_change", "change_percent", "volume", "last_updated", "change_period_days",
+	}).AddRow(
+		1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(3000000000000),
+		"$100+", "NASDAQ", true, time.Now(), time.Now(),
+		nil, nil,
+		150.24999999, 2.5100000000000193, 1.6988416988416988, int64(50000000), time.Now(), 1,
+	))
+
+	stocks := NewDatabaseStockService(db, nil).GetAllStocks(context.Background())
+
+	require.Len(t, stocks, 1)
+	assert.Equal(t, 150.25, stocks[0].CurrentPrice)
+	assert.Equal(t, 2.51, stocks[0].DailyChange)
+	assert.Equal(t, 1.7, stocks[0].ChangePercent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetStockBySymbol_NotFound(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -68,11 +211,71 @@ func TestGetStockBySymbol_NotFound(t *testing.T) {
 		WillReturnError(sql.ErrNoRows)
 
 	service := NewDatabaseStockService(db, nil)
-	stock, err := service.GetStockBySymbol("INVALID")
+	stock, err := service.GetStockBySymbol(context.Background(), "INVALID")
 
 	assert.Error(t, err)
 	assert.Nil(t, stock)
 	assert.Contains(t, err.Error(), "stock not found")
+	assert.ErrorIs(t, err, ErrStockNotFound)
+}
+
+func TestGetStockBySymbol_UnknownSymbolCachedAsMissing(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+
+	mock.ExpectQuery(`FROM stocks s\s+WHERE s.symbol = \$1`).WithArgs("NOPE").WillReturnError(sql.ErrNoRows)
+	for range 2 {
+		_, err := service.GetStockBySymbol(context.Background(), "nope")
+		assert.ErrorIs(t, err, ErrStockNotFound)
+	}
+	// The second lookup was answered from the cache
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Invalidating the stock looks it up again
+	require.NoError(t, service.cache.InvalidateStock(context.Background(), "NOPE"))
+	mock.ExpectQuery(`FROM stocks s\s+WHERE s.symbol = \$1`).WithArgs("NOPE").WillReturnError(sql.ErrNoRows)
+	_, err := service.GetStockBySymbol(context.Background(), "NOPE")
+	assert.ErrorIs(t, err, ErrStockNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStockBySymbol_PriceData(t *testing.T) {
+	stockColumns := []string{
+		"id", "symbol", "company_name", "sector", "industry", "market_cap",
+		"price_range", "exchange", "is_active", "created_at", "updated_at",
+		"first_price_date", "last_price_date",
+	}
+	priceColumns := []string{"close_price", "volume", "date", "daily_change", "change_percent", "change_period_days"}
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	service := NewDatabaseStockService(db, nil)
+
+	mock.ExpectQuery("FROM stocks s").WithArgs("AAPL").WillReturnRows(sqlmock.NewRows(stockColumns).
+		AddRow(1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(3000000000000),
+			"$100+", "NASDAQ", true, time.Now(), time.Now(), nil, nil))
+	mock.ExpectQuery("FROM daily_prices").WithArgs(1).WillReturnRows(sqlmock.NewRows(priceColumns).
+		AddRow(185.64, int64(82488700), time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), -1.51, -0.81, 1))
+
+	stock, err := service.GetStockBySymbol(context.Background(), "AAPL")
+	require.NoError(t, err)
+	assert.True(t, stock.HasPriceData)
+	assert.Equal(t, 185.64, stock.CurrentPrice)
+	assert.Equal(t, 1, stock.ChangePeriodDays)
+
+	// Listed but never synced
+	mock.ExpectQuery("FROM stocks s").WithArgs("TSLA").WillReturnRows(sqlmock.NewRows(stockColumns).
+		AddRow(2, "TSLA", "Tesla, Inc.", "Consumer Cyclical", "Auto Manufacturers", nil,
+			"$200+", "NASDAQ", true, time.Now(), time.Now(), nil, nil))
+	mock.ExpectQuery("FROM daily_prices").WithArgs(2).WillReturnRows(sqlmock.NewRows(priceColumns))
+
+	stock, err = service.GetStockBySymbol(context.Background(), "TSLA")
+	require.NoError(t, err)
+	assert.Equal(t, "TSLA", stock.Symbol)
+	assert.False(t, stock.HasPriceData)
+	assert.Zero(t, stock.CurrentPrice)
+	assert.Equal(t, "$200+", stock.PriceRange)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestGetStocksBySector(t *testing.T) {
@@ -84,25 +287,29 @@ func TestGetStocksBySector(t *testing.T) {
 	rows := sqlmock.NewRows([]string{
 		"id", "symbol", "company_name", "sector", "industry", "market_cap",
 		"price_range", "exchange", "is_active", "created_at", "updated_at",
-		"current_price", "daily_change", "change_percent", "volume", "last_updated",
+		"first_price_date", "last_price_date",
+		"current_price", "daily_change", "change_percent", "volume", "last_updated", "change_period_days",
 	}).AddRow(
 		1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(3000000000000),
 		"$100+", "NASDAQ", true, time.Now(), time.Now(),
-		150.0, 2.5, 1.69, int64(50000000), time.Now(),
+		nil, nil,
+		150.0, 2.5, 1.69, int64(50000000), time.Now(), 1,
 	).AddRow(
 		2, "MSFT", "Microsoft Corporation", "Technology", "Software", int64(2800000000000),
 		"$100+", "NASDAQ", true, time.Now(), time.Now(),
-		380.0, -1.2, -0.31, int64(30000000), time.Now(),
+		nil, nil,
+		380.0, -1.2, -0.31, int64(30000000), time.Now(), 1,
 	).AddRow(
 		3, "WMT", "Walmart Inc.", "Consumer Staples", "Retail", int64(520000000000),
 		"$50-100", "NYSE", true, time.Now(), time.Now(),
-		97.0, 0.87, 0.90, int64(15000000), time.Now(),
+		nil, nil,
+		97.0, 0.87, 0.90, int64(15000000), time.Now(), 1,
 	)
 
 	mock.ExpectQuery("SELECT").WillReturnRows(rows)
 
 	service := NewDatabaseStockService(db, nil)
-	technologyStocks := service.GetStocksBySector("Technology")
+	technologyStocks := service.GetStocksBySector(context.Background(), "Technology")
 
 	assert.Len(t, technologyStocks, 2)
 	assert.Equal(t, "AAPL", technologyStocks[0].Symbol)
@@ -111,6 +318,247 @@ func TestGetStocksBySector(t *testing.T) {
 	assert.Equal(t, "Technology", technologyStocks[1].Sector)
 }
 
+func TestGetStocksBySector_RebucketsCachedStocks(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+	mock.ExpectQuery(`FROM stocks s`).WillReturnRows(rankedStocks(1.5, "AAPL"))
+
+	loaded := service.GetStocksBySector(context.Background(), "Technology")
+	require.Len(t, loaded, 1)
+
+	// Served from the cache, but under the boundaries now in effect
+	ranges, err := NewPriceRanges([]float64{90, 200})
+	require.NoError(t, err)
+	service.SetPriceRanges(ranges)
+	cached := service.GetStocksBySector(context.Background(), "Technology")
+	require.Len(t, cached, 1)
+	assert.NotEqual(t, loaded[0].PriceRange, cached[0].PriceRange)
+	assert.Equal(t, "$90-200", cached[0].PriceRange)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStocksBySectors_LoadsMissingSectorsInOneQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	redis, redisMock := redismock.NewClientMock()
+	defer redis.Close()
+	service := NewDatabaseStockService(db, cache.NewRedisCacheWithClient(redis, "test:"))
+
+	technology, err := json.Marshal([]models.Stock{{Symbol: "AAPL", Sector: "Technology", PriceRange: "$100+"}})
+	require.NoError(t, err)
+	redisMock.ExpectMGet("test:stocks:sector:Technology", "test:stocks:sector:Energy", "test:stocks:sector:Utilities").
+		SetVal([]interface{}{string(technology), nil, nil})
+
+	now := time.Now()
+	mock.ExpectQuery(`WHERE s.is_active = true AND s.sector = ANY\(\$1\)`).
+		WithArgs(pq.Array([]string{"Energy", "Utilities"})).
+		WillReturnRows(sqlmock.NewRows(rankedStockColumns).
+			AddRow(2, "CVX", "Chevron", "Energy", "Oil & Gas", nil,
+				"$100+", "NYSE", true, now, now, nil, nil, 150.0, 1.0, 0.67, int64(1000), now, 1).
+			AddRow(3, "XOM", "Exxon Mobil", "Energy", "Oil & Gas", nil,
+				"$100+", "NYSE", true, now, now, nil, nil, 110.0, -1.0, -0.9, int64(1000), now, 1))
+
+	// Only the sector found in the database is cached, in one transaction
+	redisMock.ExpectTxPipeline()
+	redisMock.Regexp().ExpectSet("test:stocks:sector:Energy", `"symbol":"CVX".*"symbol":"XOM"`, 55*time.Minute).SetVal("OK")
+	redisMock.ExpectTxPipelineExec()
+
+	sectors := service.GetStocksBySectors(context.Background(), []string{"Technology", "Energy", "Utilities"})
+
+	require.Len(t, sectors, 2)
+	require.Len(t, sectors["Technology"], 1)
+	assert.Equal(t, "AAPL", sectors["Technology"][0].Symbol)
+	require.Len(t, sectors["Energy"], 2)
+	assert.Equal(t, "CVX", sectors["Energy"][0].Symbol)
+	assert.Equal(t, "XOM", sectors["Energy"][1].Symbol)
+	assert.NotContains(t, sectors, "Utilities")
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, redisMock.ExpectationsWereMet())
+}
+
+func TestGetStocksBySectors_WithoutCache(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	service := NewDatabaseStockService(db, nil)
+
+	now := time.Now()
+	mock.ExpectQuery(`s.sector = ANY\(\$1\)`).WithArgs(pq.Array([]string{"Energy"})).
+		WillReturnRows(sqlmock.NewRows(rankedStockColumns).
+			AddRow(3, "XOM", "Exxon Mobil", "Energy", "Oil & Gas", nil,
+				"$100+", "NYSE", true, now, now, nil, nil, 110.0, -1.0, -0.9, int64(1000), now, 1))
+
+	sectors := service.GetStocksBySectors(context.Background(), []string{"Energy"})
+	require.Len(t, sectors["Energy"], 1)
+	assert.Equal(t, "XOM", sectors["Energy"][0].Symbol)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStocksBySectorPaginated(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\)\s+FROM stocks s\s+WHERE s.is_active = true AND s.sector = \$1`).
+		WithArgs("Technology").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	rows := sqlmock.NewRows([]string{
+		"id", "symbol", "company_name", "sector", "industry", "market_cap",
+		"price_range", "exchange", "is_active", "created_at", "updated_at",
+		"first_price_date", "last_price_date",
+		"current_price", "daily_change", "change_percent", "volume", "last_updated", "change_period_days",
+	}).AddRow(
+		1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(3000000000000),
+		"$100+", "NASDAQ", true, time.Now(), time.Now(),
+		nil, nil,
+		150.0, 2.5, 1.69, int64(50000000), time.Now(), 1,
+	).AddRow(
+		2, "MSFT", "Microsoft Corporation", "Technology", "Software", int64(2800000000000),
+		"$100+", "NASDAQ", true, time.Now(), time.Now(),
+		nil, nil,
+		380.0, -1.2, -0.31, int64(30000000), time.Now(), 1,
+	)
+	mock.ExpectQuery(`WHERE s.is_active = true AND s.sector = \$1\s+ORDER BY s.market_cap DESC, s.symbol\s+LIMIT \$2 OFFSET \$3`).
+		WithArgs("Technology", 2, 0).
+		WillReturnRows(rows)
+
+	service := NewDatabaseStockService(db, nil)
+	stocks, total, err := service.GetStocksBySectorPaginated(context.Background(), "Technology", 2, 0, 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, total)
+	require.Len(t, stocks, 2)
+	assert.Equal(t, "AAPL", stocks[0].Symbol)
+	assert.Equal(t, "MSFT", stocks[1].Symbol)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStocksBySectorPaginated_OffsetPastEnd(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\)`).
+		WithArgs("Technology").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	service := NewDatabaseStockService(db, nil)
+	stocks, total, err := service.GetStocksBySectorPaginated(context.Background(), "Technology", 50, 100, 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, total)
+	assert.NotNil(t, stocks)
+	assert.Empty(t, stocks)
+	// No page query is issued once the offset is past the sector's total
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabaseStockService_ReadRouting(t *testing.T) {
+	primary, primaryMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer primary.Close()
+
+	replica, replicaMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer replica.Close()
+
+	service := NewDatabaseStockService(primary, nil)
+	router := database.NewReadRouter(primary, replica, time.Minute)
+	service.SetReadRouter(router)
+
+	expectSector := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(`SELECT COUNT\(\*\)`).
+			WithArgs("Technology").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	}
+
+	// Reads go to the replica while nothing has been written
+	expectSector(replicaMock)
+	_, _, err = service.GetStocksBySectorPaginated(context.Background(), "Technology", 50, 0, 0)
+	require.NoError(t, err)
+	assert.Same(t, replica, service.GetReadDB("AAPL"))
+
+	// After a sync writes AAPL, reads that may include it go to the primary
+	router.MarkWritten("AAPL")
+	expectSector(primaryMock)
+	_, _, err = service.GetStocksBySectorPaginated(context.Background(), "Technology", 50, 0, 0)
+	require.NoError(t, err)
+	assert.Same(t, primary, service.GetReadDB("AAPL"))
+	assert.Same(t, replica, service.GetReadDB("MSFT"))
+
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+}
+
+func TestGetAllStocks_ComputesPriceRangeFromLatestClose(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{
+		"id", "symbol", "company_name", "sector", "industry", "market_cap",
+		"price_range", "exchange", "is_active", "created_at", "updated_at",
+		"first_price_date", "last_price_date",
+		"current_price", "daily_change", "change_percent", "volume", "last_updated", "change_period_days",
+	}).AddRow(
+		// Stored bucket went stale as the price moved
+		1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(3000000000000),
+		"$50-100", "NASDAQ", true, time.Now(), time.Now(),
+		nil, nil,
+		150.0, 2.5, 1.69, int64(50000000), time.Now(), 1,
+	).AddRow(
+		// No price data yet, so the stored column is the only bucket available
+		2, "NEWCO", "New Listing Inc.", "Technology", "Software", int64(1000000000),
+		"$10-50", "NYSE", true, time.Now(), time.Now(),
+		nil, nil,
+		nil, nil, nil, nil, time.Now(), 0,
+	)
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+	service := NewDatabaseStockService(db, nil)
+	stocks := service.GetAllStocks(context.Background())
+
+	require.Len(t, stocks, 2)
+	assert.Equal(t, "$150+", stocks[0].PriceRange)
+	assert.Equal(t, "$10-50", stocks[1].PriceRange)
+}
+
+func TestGetStocksByPriceRange_UsesConfiguredBoundaries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{
+		"id", "symbol", "company_name", "sector", "industry", "market_cap",
+		"price_range", "exchange", "is_active", "created_at", "updated_at",
+		"first_price_date", "last_price_date",
+		"current_price", "daily_change", "change_percent", "volume", "last_updated", "change_period_days",
+	}).AddRow(
+		1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(3000000000000),
+		"$100+", "NASDAQ", true, time.Now(), time.Now(),
+		nil, nil,
+		150.0, 2.5, 1.69, int64(50000000), time.Now(), 1,
+	).AddRow(
+		2, "WMT", "Walmart Inc.", "Consumer Staples", "Retail", int64(520000000000),
+		"$50-100", "NYSE", true, time.Now(), time.Now(),
+		nil, nil,
+		97.0, 0.87, 0.90, int64(15000000), time.Now(), 1,
+	)
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+	ranges, err := NewPriceRanges([]float64{90, 200})
+	require.NoError(t, err)
+
+	service := NewDatabaseStockService(db, nil)
+	service.SetPriceRanges(ranges)
+	stocks := service.GetStocksByPriceRange(context.Background(), "$90-200")
+
+	require.Len(t, stocks, 2)
+	assert.Equal(t, "AAPL", stocks[0].Symbol)
+	assert.Equal(t, "WMT", stocks[1].Symbol)
+}
+
 func TestGetDB(t *testing.T) {
 	db, _, err := sqlmock.New()
 	require.NoError(t, err)
@@ -132,7 +580,8 @@ func BenchmarkGetAllStocks(b *testing.B) {
 	rows := sqlmock.NewRows([]string{
 		"id", "symbol", "company_name", "sector", "industry", "market_cap",
 		"price_range", "exchange", "is_active", "created_at", "updated_at",
-		"current_price", "daily_change", "change_percent", "volume", "last_updated",
+		"first_price_date", "last_price_date",
+		"current_price", "daily_change", "change_percent", "volume", "last_updated", "change_period_days",
 	})
 
 	for i := 1; i <= 100; i++ {
@@ -152,6 +601,93 @@ func BenchmarkGetAllStocks(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		service.GetAllStocks()
+		service.GetAllStocks(context.Background())
 	}
-}
\ No newline at end of file
+}
+func TestExcludeChangeOutliers(t *testing.T) {
+	stocks := []models.Stock{
+		{Symbol: "AAPL", ChangePercent: 2.5},
+		{Symbol: "BAD", ChangePercent: 499900.0},
+		{Symbol: "DROP", ChangePercent: -75.0},
+		{Symbol: "MSFT", ChangePercent: -0.3},
+	}
+
+	service := NewDatabaseStockService(nil, nil)
+	kept, diagnostics := service.ExcludeChangeOutliers(stocks)
+
+	require.Len(t, kept, 2)
+	assert.Equal(t, "AAPL", kept[0].Symbol)
+	assert.Equal(t, "MSFT", kept[1].Symbol)
+	require.Len(t, diagnostics.ExcludedOutliers, 2)
+	assert.Equal(t, "BAD", diagnostics.ExcludedOutliers[0].Symbol)
+	assert.Equal(t, "DROP", diagnostics.ExcludedOutliers[1].Symbol)
+
+	// A zero limit disables the guard
+	service.SetMaxChangePercent(0)
+	kept, diagnostics = service.ExcludeChangeOutliers(stocks)
+	assert.Len(t, kept, 4)
+	assert.Empty(t, diagnostics.ExcludedOutliers)
+}
+
+func TestGetAllStocksPaginated_MinHistoryDays(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	first := time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)
+	last := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT COUNT\(DISTINCT s.id\)[\s\S]+AND s.last_price_date - s.first_price_date >= \$1`).
+		WithArgs(365).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`AND s.last_price_date - s.first_price_date >= \$1\s+ORDER BY s.market_cap DESC, s.symbol\s+LIMIT \$2 OFFSET \$3`).
+		WithArgs(365, 50, 0).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "symbol", "company_name", "sector", "industry", "market_cap",
+			"price_range", "exchange", "is_active", "created_at", "updated_at",
+			"first_price_date", "last_price_date",
+			"current_price", "daily_change", "change_percent", "volume", "last_updated", "change_period_days",
+		}).AddRow(
+			1, "AAPL", "Apple Inc.", "Technology", "Consumer Electronics", int64(3000000000000),
+			"$100+", "NASDAQ", true, time.Now(), time.Now(),
+			first, last,
+			150.0, 2.5, 1.69, int64(50000000), last, 1,
+		))
+
+	service := NewDatabaseStockService(db, nil)
+	stocks, total, err := service.GetAllStocksPaginated(context.Background(), StockFilter{MinHistoryDays: 365}, 50, 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, total)
+	require.Len(t, stocks, 1)
+	require.NotNil(t, stocks[0].FirstPriceDate)
+	assert.Equal(t, first, *stocks[0].FirstPriceDate)
+	assert.Equal(t, last, *stocks[0].LastPriceDate)
+	assert.Equal(t, 484, stocks[0].HistoryDays())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPriceHistories_OneQueryForEverySymbol(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	day := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`dp.adjusted_close as price[\s\S]+WHERE s.symbol = ANY\(\$1\)[\s\S]+WHERE n <= \$2`).
+		WithArgs(`{"AAPL","MSFT","NEWCO"}`, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "date", "price", "volume"}).
+			AddRow("AAPL", day, 170.5, int64(100)).
+			AddRow("AAPL", day.AddDate(0, 0, 1), 171.25, int64(120)).
+			AddRow("MSFT", day.AddDate(0, 0, 1), 410.0, int64(80)))
+
+	service := NewDatabaseStockService(db, nil)
+	histories, err := service.GetPriceHistories(context.Background(), []string{"AAPL", "MSFT", "NEWCO"}, 2, true)
+	require.NoError(t, err)
+
+	require.Len(t, histories["AAPL"], 2)
+	assert.Equal(t, day, histories["AAPL"][0].Date)
+	assert.Equal(t, 171.25, histories["AAPL"][1].Price)
+	assert.Equal(t, []models.PricePoint{{Date: day.AddDate(0, 0, 1), Price: 410.0, Volume: 80}}, histories["MSFT"])
+	assert.NotContains(t, histories, "NEWCO")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// Dividend is a cash dividend per share, paid to holders before ExDate
+type Dividend struct {
+	ExDate time.Time `json:"ex_date"`
+	Amount float64   `json:"amount"`
+}
+
+// TrailingDividends sums the dividends that went ex in the year up to and
+// including asOf, and counts them. Nothing is extrapolated, so a stock paying
+// twice a year, or that began paying this year, reports only what it paid.
+func TrailingDividends(dividends []Dividend, asOf time.Time) (total float64, payouts int) {
+	yearAgo := asOf.AddDate(-1, 0, 0)
+	for _, dividend := range dividends {
+		if dividend.ExDate.After(yearAgo) && !dividend.ExDate.After(asOf) {
+			total += dividend.Amount
+			payouts++
+		}
+	}
+	return total, payouts
+}
+
+// TrailingDividendYield is the trailing twelve month dividends over price, in
+// percent, as of the price's date. It is nil without a price or without a
+// dividend in that year, rather than a yield of zero the data cannot support.
+func TrailingDividendYield(dividends []Dividend, price float64, priceDate time.Time) *float64 {
+	total, payouts := TrailingDividends(dividends, priceDate)
+	return dividendYield(total, payouts, price)
+}
+
+func dividendYield(total float64, payouts int, price float64) *float64 {
+	if price <= 0 || payouts == 0 {
+		return nil
+	}
+	yield := total / price * 100
+	return &yield
+}
+
+// GetDividends returns a stock's recorded dividends, latest first, or
+// ErrStockNotFound when symbol is neither an active nor a delisted stock
+func (d *DatabaseStockService) GetDividends(ctx context.Context, symbol string) ([]Dividend, error) {
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+
+	// One row with a NULL dividend tells a stock that never paid from an unknown symbol
+	rows, err := d.router.ReaderFor(symbol).QueryContext(ctx, `
+		SELECT d.ex_date, d.amount
+		FROM stocks s
+		LEFT JOIN dividends d ON d.stock_id = s.id
+		WHERE s.symbol = $1 AND (s.is_active = true OR s.delisted_at IS NOT NULL)
+		ORDER BY d.ex_date DESC
+	`, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dividends: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+
+	found := false
+	dividends := []Dividend{}
+	for rows.Next() {
+		found = true
+		var exDate sql.NullTime
+		var amount sql.NullFloat64
+		if err := rows.Scan(&exDate, &amount); err != nil {
+			return nil, fmt.Errorf("failed to scan dividend: %w", err)
+		}
+		if exDate.Valid {
+			dividends = append(dividends, Dividend{ExDate: exDate.Time, Amount: amount.Float64})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dividends: %w", queryError(ctx, err))
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrStockNotFound, symbol)
+	}
+	return dividends, nil
+}
+
+// DividendLeader is a stock's trailing twelve month dividend yield as of its latest close
+type DividendLeader struct {
+	Symbol            string    `json:"symbol"`
+	CompanyName       string    `json:"company_name"`
+	Sector            string    `json:"sector"`
+	CurrentPrice      float64   `json:"current_price"`
+	PriceDate         time.Time `json:"price_date"`
+	TrailingDividends float64   `json:"trailing_dividends"`
+	Payouts           int       `json:"payouts"`
+	DividendYield     float64   `json:"dividend_yield"`
+}
+
+// dividendLeadersQuery sums each active stock's dividends in the year up to its
+// latest close. Stocks without a dividend in that year are left out entirely.
+const dividendLeadersQuery = `
+		SELECT s.symbol, s.company_name, COALESCE(s.sector, ''), dp.close_price, dp.date,
+		       SUM(d.amount), COUNT(d.ex_date)
+		FROM stocks s
+		JOIN daily_prices dp ON dp.stock_id = s.id AND dp.date = s.last_price_date
+		JOIN dividends d ON d.stock_id = s.id
+		     AND d.ex_date > dp.date - INTERVAL '1 year' AND d.ex_date <= dp.date
+		WHERE s.is_active = true
+		GROUP BY s.symbol, s.company_name, s.sector, dp.close_price, dp.date`
+
+// GetDividendLeaders returns up to limit stocks with the highest trailing
+// dividend yields, among those that paid a dividend in the past year
+func (d *DatabaseStockService) GetDividendLeaders(ctx context.Context, limit int) ([]DividendLeader, error) {
+	var leaders []DividendLeader
+	if d.cache == nil || d.cache.GetDividendLeaders(ctx, &leaders) != nil {
+		var err error
+		if leaders, err = d.rankDividendYields(ctx); err != nil {
+			return nil, err
+		}
+
+		if d.cache != nil {
+			if err := d.cache.SetDividendLeaders(ctx, leaders, MarketAggregateTTL); err != nil {
+				slog.WarnContext(ctx, "Failed to cache dividend leaders", "error", err)
+			}
+		}
+	}
+
+	if len(leaders) > limit {
+		leaders = leaders[:limit]
+	}
+	return leaders, nil
+}
+
+// rankDividendYields returns every stock with a trailing dividend yield, highest first
+func (d *DatabaseStockService) rankDividendYields(ctx context.Context) ([]DividendLeader, error) {
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.router.Reader().QueryContext(ctx, dividendLeadersQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dividend yields: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+
+	leaders := []DividendLeader{}
+	for rows.Next() {
+		var leader DividendLeader
+		if err := rows.Scan(&leader.Symbol, &leader.CompanyName, &leader.Sector, &leader.CurrentPrice,
+			&leader.PriceDate, &leader.TrailingDividends, &leader.Payouts); err != nil {
+			return nil, fmt.Errorf("failed to scan dividend yield: %w", err)
+		}
+		yield := dividendYield(leader.TrailingDividends, leader.Payouts, leader.CurrentPrice)
+		if yield == nil {
+			continue
+		}
+		leader.DividendYield = *yield
+		leaders = append(leaders, leader)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dividend yields: %w", queryError(ctx, err))
+	}
+
+	sort.SliceStable(leaders, func(i, j int) bool {
+		if leaders[i].DividendYield != leaders[j].DividendYield {
+			return leaders[i].DividendYield > leaders[j].DividendYield
+		}
+		return leaders[i].Symbol < leaders[j].Symbol
+	})
+	return leaders, nil
+}
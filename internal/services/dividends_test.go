@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrailingDividendYield(t *testing.T) {
+	priceDate := date2026(time.March, 31)
+	quarterly := []Dividend{
+		{ExDate: date2026(time.February, 9), Amount: 0.25},
+		{ExDate: time.Date(2025, time.November, 10, 0, 0, 0, 0, time.UTC), Amount: 0.25},
+		{ExDate: time.Date(2025, time.August, 11, 0, 0, 0, 0, time.UTC), Amount: 0.25},
+		{ExDate: time.Date(2025, time.May, 12, 0, 0, 0, 0, time.UTC), Amount: 0.25},
+		// More than a year before the price
+		{ExDate: time.Date(2025, time.February, 10, 0, 0, 0, 0, time.UTC), Amount: 0.24},
+	}
+
+	tests := []struct {
+		name      string
+		dividends []Dividend
+		price     float64
+		want      *float64
+		payouts   int
+	}{
+		{name: "four quarterly payouts", dividends: quarterly, price: 50, want: floatPtr(2), payouts: 4},
+		{
+			// Neither annualized nor padded: a semiannual payer reports what it paid
+			name: "two semiannual payouts",
+			dividends: []Dividend{
+				{ExDate: date2026(time.March, 2), Amount: 0.60},
+				{ExDate: time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC), Amount: 0.60},
+			},
+			price:   40,
+			want:    floatPtr(3),
+			payouts: 2,
+		},
+		{
+			name:      "first payout ever",
+			dividends: []Dividend{{ExDate: date2026(time.March, 31), Amount: 0.10}},
+			price:     20,
+			want:      floatPtr(0.5),
+			payouts:   1,
+		},
+		{name: "no price", dividends: quarterly, price: 0, payouts: 4},
+		{name: "no dividend in the past year", dividends: quarterly[4:], price: 50},
+		{name: "never paid", price: 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, payouts := TrailingDividends(tt.dividends, priceDate)
+			assert.Equal(t, tt.payouts, payouts)
+
+			got := TrailingDividendYield(tt.dividends, tt.price, priceDate)
+			if tt.want == nil {
+				assert.Nil(t, got)
+				return
+			}
+			require.NotNil(t, got)
+			assert.InDelta(t, *tt.want, *got, 1e-9)
+		})
+	}
+}
+
+func TestGetDividends(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+	columns := []string{"ex_date", "amount"}
+
+	mock.ExpectQuery("LEFT JOIN dividends").WithArgs("KO").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow(date2026(time.March, 13), 0.51).
+		AddRow(time.Date(2025, time.November, 28, 0, 0, 0, 0, time.UTC), 0.51))
+	dividends, err := service.GetDividends(context.Background(), "KO")
+	require.NoError(t, err)
+	assert.Equal(t, []Dividend{
+		{ExDate: date2026(time.March, 13), Amount: 0.51},
+		{ExDate: time.Date(2025, time.November, 28, 0, 0, 0, 0, time.UTC), Amount: 0.51},
+	}, dividends)
+
+	// A stock that never paid has no dividends; an unknown one is not found
+	mock.ExpectQuery("LEFT JOIN dividends").WithArgs("TSLA").WillReturnRows(sqlmock.NewRows(columns).AddRow(nil, nil))
+	dividends, err = service.GetDividends(context.Background(), "TSLA")
+	require.NoError(t, err)
+	assert.Empty(t, dividends)
+	assert.NotNil(t, dividends)
+
+	mock.ExpectQuery("LEFT JOIN dividends").WithArgs("ZZZZ").WillReturnRows(sqlmock.NewRows(columns))
+	_, err = service.GetDividends(context.Background(), "ZZZZ")
+	assert.ErrorIs(t, err, ErrStockNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDividendLeaders_RanksByYieldAndCaches(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+
+	priceDate := date2026(time.March, 31)
+	mock.ExpectQuery(`JOIN dividends d ON d.stock_id = s.id`).WillReturnRows(sqlmock.NewRows([]string{
+		"symbol", "company_name", "sector", "close_price", "date", "trailing", "payouts",
+	}).
+		AddRow("AAPL", "Apple Inc.", "Technology", 200.0, priceDate, 1.0, 4).
+		AddRow("KO", "Coca-Cola Company", "Consumer Defensive", 60.0, priceDate, 2.04, 4).
+		AddRow("T", "AT&T Inc.", "Communication Services", 20.0, priceDate, 1.11, 4).
+		AddRow("NEWCO", "New Co", "", 10.0, priceDate, 0.05, 1))
+
+	leaders, err := service.GetDividendLeaders(context.Background(), 3)
+	require.NoError(t, err)
+	require.Len(t, leaders, 3)
+	assert.Equal(t, "T", leaders[0].Symbol)
+	assert.InDelta(t, 5.55, leaders[0].DividendYield, 1e-9)
+	assert.Equal(t, "KO", leaders[1].Symbol)
+	assert.InDelta(t, 3.4, leaders[1].DividendYield, 1e-9)
+	assert.Equal(t, "AAPL", leaders[2].Symbol)
+
+	// The whole ranking is cached, so a larger limit needs no query
+	leaders, err = service.GetDividendLeaders(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, leaders, 4)
+	assert.Equal(t, "NEWCO", leaders[3].Symbol)
+	assert.Equal(t, 1, leaders[3].Payouts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
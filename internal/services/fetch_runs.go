@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Statuses of a fetch run, as stored in fetch_runs.status. Its symbols'
+// outcomes are RunStatusSucceeded or RunStatusFailed.
+const (
+	fetchRunRunning   = "running"
+	fetchRunCompleted = "completed"
+	// fetchRunAbandoned is an unfinished run that a fresh plan replaced
+	fetchRunAbandoned = "abandoned"
+)
+
+// fetchRun is a data fetcher run's planned symbols, in priority order, with
+// the outcome of each symbol fetched so far
+type fetchRun struct {
+	id       int64
+	symbols  []string
+	statuses map[string]string
+}
+
+// pending returns the symbols without an outcome, in planned order
+func (r *fetchRun) pending() []string {
+	var pending []string
+	for _, symbol := range r.symbols {
+		if _, done := r.statuses[symbol]; !done {
+			pending = append(pending, symbol)
+		}
+	}
+	return pending
+}
+
+// incompleteFetchRun returns the latest run planned today that has not
+// finished, nil when there is none
+func incompleteFetchRun(ctx context.Context, db *sql.DB, now time.Time) (*fetchRun, error) {
+	run := fetchRun{}
+	var statuses []byte
+	err := db.QueryRowContext(ctx, `
+		SELECT id, symbols, statuses
+		FROM fetch_runs
+		WHERE status = $1 AND run_date = $2
+		ORDER BY id DESC
+		LIMIT 1
+	`, fetchRunRunning, marketDate(now)).Scan(&run.id, pq.Array(&run.symbols), &statuses)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up an unfinished fetch run: %w", err)
+	}
+	if err := json.Unmarshal(statuses, &run.statuses); err != nil {
+		return nil, fmt.Errorf("failed to read fetch run %d: %w", run.id, err)
+	}
+	return &run, nil
+}
+
+// startFetchRun stores a fresh plan of symbols, abandoning any run left unfinished
+func startFetchRun(ctx context.Context, db *sql.DB, symbols []string, now time.Time) (int64, error) {
+	var id int64
+	err := db.QueryRowContext(ctx, `
+		WITH abandoned AS (
+			UPDATE fetch_runs SET status = $1, finished_at = CURRENT_TIMESTAMP
+			WHERE status = $2
+		)
+		INSERT INTO fetch_runs (run_date, symbols, status)
+		VALUES ($3, $4, $2)
+		RETURNING id
+	`, fetchRunAbandoned, fetchRunRunning, marketDate(now), pq.Array(symbols)).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start fetch run: %w", err)
+	}
+	return id, nil
+}
+
+// checkpointFetchRun records the outcome of one of a run's symbols
+func checkpointFetchRun(ctx context.Context, db *sql.DB, runID int64, symbol, status string) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE fetch_runs
+		SET statuses = statuses || jsonb_build_object($2::text, $3::text), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, runID, symbol, status)
+	if err != nil {
+		return fmt.Errorf("failed to checkpoint %s in fetch run %d: %w", symbol, runID, err)
+	}
+	return nil
+}
+
+// completeFetchRun marks a run whose every symbol has an outcome as finished
+func completeFetchRun(ctx context.Context, db *sql.DB, runID int64) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE fetch_runs SET status = $2, finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, runID, fetchRunCompleted)
+	if err != nil {
+		return fmt.Errorf("failed to complete fetch run %d: %w", runID, err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"stock-intelligence-backend/internal/cache"
+)
+
+// Component and overall health, from best to worst
+const (
+	HealthHealthy   = "healthy"
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+	// HealthDisabled is a component that is not configured; it leaves the overall status as it is
+	HealthDisabled = "disabled"
+)
+
+// DefaultHealthCheckTimeout bounds each health check
+const DefaultHealthCheckTimeout = 2 * time.Second
+
+// DefaultStaleTradingDays is how many completed trading days without a new
+// daily price make the stored data stale
+const DefaultStaleTradingDays = 3
+
+// healthRank orders statuses by severity
+var healthRank = map[string]int{HealthDisabled: 0, HealthHealthy: 0, HealthDegraded: 1, HealthUnhealthy: 2}
+
+// WorstHealth returns the most severe of statuses, healthy when there are none
+func WorstHealth(statuses ...string) string {
+	worst := HealthHealthy
+	for _, status := range statuses {
+		if healthRank[status] > healthRank[worst] {
+			worst = status
+		}
+	}
+	return worst
+}
+
+// ComponentHealth is the outcome of one health check
+type ComponentHealth struct {
+	Status string `json:"status"`
+	// LatencyMS is how long the check took, in milliseconds
+	LatencyMS float64                `json:"latency_ms"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// HealthChecker probes the database, the cache and the freshness of the
+// stored prices, each within a timeout
+type HealthChecker struct {
+	db               *sql.DB
+	cache            *cache.RedisCache
+	calendar         *MarketCalendar
+	staleTradingDays int
+	timeout          time.Duration
+	now              func() time.Time
+}
+
+// NewHealthChecker creates a checker; redisCache is nil when caching is disabled
+func NewHealthChecker(db *sql.DB, redisCache *cache.RedisCache) *HealthChecker {
+	return &HealthChecker{
+		db:               db,
+		cache:            redisCache,
+		calendar:         NewMarketCalendar(),
+		staleTradingDays: DefaultStaleTradingDays,
+		timeout:          DefaultHealthCheckTimeout,
+		now:              time.Now,
+	}
+}
+
+// SetMarketCalendar sets the calendar whose trading days the freshness check counts
+func (h *HealthChecker) SetMarketCalendar(calendar *MarketCalendar) {
+	if calendar != nil {
+		h.calendar = calendar
+	}
+}
+
+// SetStaleTradingDays sets how many completed trading days without a new price degrade the data freshness
+func (h *HealthChecker) SetStaleTradingDays(days int) {
+	if days > 0 {
+		h.staleTradingDays = days
+	}
+}
+
+// timed runs check within the timeout and records how long it took
+func (h *HealthChecker) timed(ctx context.Context, check func(ctx context.Context) ComponentHealth) ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	health := check(ctx)
+	health.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	return health
+}
+
+// CheckDatabase pings the database
+func (h *HealthChecker) CheckDatabase(ctx context.Context) ComponentHealth {
+	return h.timed(ctx, func(ctx context.Context) ComponentHealth {
+		if err := h.db.PingContext(ctx); err != nil {
+			return ComponentHealth{Status: HealthUnhealthy, Error: err.Error()}
+		}
+		return ComponentHealth{Status: HealthHealthy}
+	})
+}
+
+// CheckCache pings Redis. The API serves from the database without it, so
+// an unreachable cache degrades the service rather than breaking it.
+func (h *HealthChecker) CheckCache(ctx context.Context) ComponentHealth {
+	if h.cache == nil {
+		return ComponentHealth{Status: HealthDisabled}
+	}
+	return h.timed(ctx, func(ctx context.Context) ComponentHealth {
+		if err := h.cache.PingContext(ctx); err != nil {
+			return ComponentHealth{Status: HealthDegraded, Error: err.Error()}
+		}
+		return ComponentHealth{Status: HealthHealthy}
+	})
+}
+
+// CheckDataFreshness degrades when the newest stored daily price is the
+// stale threshold or more completed trading days old, or when there is none
+func (h *HealthChecker) CheckDataFreshness(ctx context.Context) ComponentHealth {
+	return h.timed(ctx, func(ctx context.Context) ComponentHealth {
+		var newest sql.NullTime
+		if err := h.db.QueryRowContext(ctx, `SELECT MAX(date) FROM daily_prices`).Scan(&newest); err != nil {
+			return ComponentHealth{Status: HealthUnhealthy, Error: err.Error()}
+		}
+
+		details := map[string]interface{}{"stale_after_trading_days": h.staleTradingDays}
+		if !newest.Valid {
+			return ComponentHealth{Status: HealthDegraded, Error: "no daily prices stored", Details: details}
+		}
+
+		behind := h.calendar.TradingDaysSince(newest.Time, h.now())
+		details["newest_price_date"] = newest.Time.Format("2006-01-02")
+		details["trading_days_behind"] = behind
+		status := HealthHealthy
+		if behind >= h.staleTradingDays {
+			status = HealthDegraded
+		}
+		return ComponentHealth{Status: status, Details: details}
+	})
+}
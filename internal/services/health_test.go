@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/cache"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorstHealth(t *testing.T) {
+	assert.Equal(t, HealthHealthy, WorstHealth())
+	assert.Equal(t, HealthHealthy, WorstHealth(HealthHealthy, HealthDisabled))
+	assert.Equal(t, HealthDegraded, WorstHealth(HealthDegraded, HealthHealthy, HealthDisabled))
+	assert.Equal(t, HealthUnhealthy, WorstHealth(HealthDegraded, HealthUnhealthy, HealthHealthy))
+}
+
+func TestHealthChecker_DatabasePing(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer db.Close()
+	checker := NewHealthChecker(db, nil)
+
+	mock.ExpectPing()
+	health := checker.CheckDatabase(context.Background())
+	assert.Equal(t, HealthHealthy, health.Status)
+	assert.Empty(t, health.Error)
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	health = checker.CheckDatabase(context.Background())
+	assert.Equal(t, HealthUnhealthy, health.Status)
+	assert.Equal(t, "connection refused", health.Error)
+
+	// A ping that hangs is cut off by the timeout
+	checker.timeout = 20 * time.Millisecond
+	mock.ExpectPing().WillDelayFor(time.Second)
+	health = checker.CheckDatabase(context.Background())
+	assert.Equal(t, HealthUnhealthy, health.Status)
+	assert.Less(t, health.LatencyMS, 500.0)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHealthChecker_Cache(t *testing.T) {
+	assert.Equal(t, HealthDisabled, NewHealthChecker(nil, nil).CheckCache(context.Background()).Status)
+
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache("redis://"+server.Addr(), "")
+	require.NoError(t, err)
+	defer redisCache.Close()
+	checker := NewHealthChecker(nil, redisCache)
+	assert.Equal(t, HealthHealthy, checker.CheckCache(context.Background()).Status)
+
+	// The API still serves from the database without Redis
+	server.Close()
+	health := checker.CheckCache(context.Background())
+	assert.Equal(t, HealthDegraded, health.Status)
+	assert.NotEmpty(t, health.Error)
+}
+
+func TestHealthChecker_DataFreshness(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	checker := NewHealthChecker(db, nil)
+	location, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	// Friday morning, April 10 2026
+	checker.now = func() time.Time { return time.Date(2026, time.April, 10, 10, 0, 0, 0, location) }
+
+	newest := func(date interface{}) {
+		mock.ExpectQuery(`SELECT MAX\(date\) FROM daily_prices`).
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(date))
+	}
+
+	// Wednesday's prices are one completed session behind
+	newest(time.Date(2026, time.April, 8, 0, 0, 0, 0, time.UTC))
+	health := checker.CheckDataFreshness(context.Background())
+	assert.Equal(t, HealthHealthy, health.Status)
+	assert.Equal(t, 1, health.Details["trading_days_behind"])
+	assert.Equal(t, "2026-04-08", health.Details["newest_price_date"])
+
+	// Thursday before Good Friday is four sessions behind, past the default three
+	newest(time.Date(2026, time.April, 2, 0, 0, 0, 0, time.UTC))
+	health = checker.CheckDataFreshness(context.Background())
+	assert.Equal(t, HealthDegraded, health.Status)
+	assert.Equal(t, 4, health.Details["trading_days_behind"])
+
+	checker.SetStaleTradingDays(5)
+	newest(time.Date(2026, time.April, 2, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, HealthHealthy, checker.CheckDataFreshness(context.Background()).Status)
+
+	newest(nil)
+	health = checker.CheckDataFreshness(context.Background())
+	assert.Equal(t, HealthDegraded, health.Status)
+	assert.Equal(t, "no daily prices stored", health.Error)
+
+	mock.ExpectQuery(`SELECT MAX\(date\) FROM daily_prices`).WillReturnError(errors.New("relation does not exist"))
+	assert.Equal(t, HealthUnhealthy, checker.CheckDataFreshness(context.Background()).Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHealthChecker_DataFreshnessAcrossUTCMidnight(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	checker := NewHealthChecker(db, nil)
+	checker.SetStaleTradingDays(1)
+	// 02:30 UTC on Thursday April 9 is still Wednesday evening in New York,
+	// so Wednesday's session is the one in progress rather than a missed one
+	checker.now = func() time.Time { return time.Date(2026, time.April, 9, 2, 30, 0, 0, time.UTC) }
+
+	mock.ExpectQuery(`SELECT MAX\(date\) FROM daily_prices`).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(time.Date(2026, time.April, 7, 0, 0, 0, 0, time.UTC)))
+	health := checker.CheckDataFreshness(context.Background())
+	assert.Equal(t, HealthHealthy, health.Status)
+	assert.Equal(t, 0, health.Details["trading_days_behind"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,34 +1,201 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
+
+	"stock-intelligence-backend/internal/cache"
+	"stock-intelligence-backend/internal/database"
+	"stock-intelligence-backend/internal/jobs"
+	"stock-intelligence-backend/internal/logging"
 )
 
+// JobTypeBatchSync is the job queue type for batch historical data syncs
+const JobTypeBatchSync = "batch_sync"
+
+// batchSyncPayload is the job payload for a batch sync
+type batchSyncPayload struct {
+	MaxStocks int `json:"max_stocks"`
+	// SyncJobID is the sync_jobs row the batch reports its progress to
+	SyncJobID int64 `json:"sync_job_id,omitempty"`
+	// RequestID is the ID of the HTTP request that queued the sync, for its log records
+	RequestID string `json:"request_id,omitempty"`
+}
+
 // HistoricalDataSyncService manages bulk historical data synchronization
 type HistoricalDataSyncService struct {
 	db                    *sql.DB
-	alphaVantageClient    *AlphaVantageClient
+	provider              MarketDataProvider
 	sp500PriorityService  *SP500PriorityService
+	history               *SyncHistoryService
+	jobQueue              *jobs.Queue
+	readRouter            *database.ReadRouter
+	priceRanges           *PriceRanges
+	webhooks              *WebhookDispatcher
+	cache                 *cache.RedisCache
+	events                *StockEventBus
 }
 
 // NewHistoricalDataSyncService creates a new historical data sync service
-func NewHistoricalDataSyncService(db *sql.DB, alphaVantageClient *AlphaVantageClient) *HistoricalDataSyncService {
-	return &HistoricalDataSyncService{
+func NewHistoricalDataSyncService(db *sql.DB, provider MarketDataProvider, jobQueue *jobs.Queue) *HistoricalDataSyncService {
+	service := &HistoricalDataSyncService{
 		db:                   db,
-		alphaVantageClient:   alphaVantageClient,
+		provider:             provider,
 		sp500PriorityService: NewSP500PriorityService(db),
+		history:              NewSyncHistoryService(db),
+		jobQueue:             jobQueue,
+		priceRanges:          DefaultPriceRanges(),
 	}
+	
+	if jobQueue != nil {
+		// Only one batch may run at a time since each one drains the daily budget
+		jobQueue.Register(JobTypeBatchSync, service.runBatchSyncJob, jobs.Options{
+			Concurrency: 1,
+			MaxAttempts: 1,
+		})
+	}
+	
+	return service
+}
+
+// SetReadRouter lets synced symbols pin their reads to the primary
+func (h *HistoricalDataSyncService) SetReadRouter(router *database.ReadRouter) {
+	h.readRouter = router
+}
+
+// SetPriceRanges sets the buckets used to refresh the stored price_range after a sync
+func (h *HistoricalDataSyncService) SetPriceRanges(ranges *PriceRanges) {
+	h.priceRanges = ranges
+}
+
+// SetCache sets the response cache a batch invalidates once it has saved a stock
+func (h *HistoricalDataSyncService) SetCache(redisCache *cache.RedisCache) {
+	h.cache = redisCache
+}
+
+// SetStockEvents sets the bus a batch publishes each synced stock to, for live clients
+func (h *HistoricalDataSyncService) SetStockEvents(events *StockEventBus) {
+	h.events = events
+}
+
+// SetWebhookDispatcher sends sync.batch_completed to webhooks as each batch sync ends
+func (h *HistoricalDataSyncService) SetWebhookDispatcher(webhooks *WebhookDispatcher) {
+	h.webhooks = webhooks
+}
+
+// EnqueueBatchSync queues a batch sync on the background job queue and
+// returns the sync job its progress is reported to. Only one batch may be
+// queued or running at a time; otherwise a BatchSyncActiveError names it. The
+// request ID in ctx is kept with the job so the sync's log records carry it.
+func (h *HistoricalDataSyncService) EnqueueBatchSync(ctx context.Context, maxStocks int) (*SyncJob, error) {
+	if h.jobQueue == nil {
+		return nil, fmt.Errorf("job queue is not configured")
+	}
+	
+	syncJob, err := h.createSyncJob(ctx, maxStocks)
+	if err != nil {
+		return nil, err
+	}
+	
+	job, err := h.jobQueue.Enqueue(JobTypeBatchSync, batchSyncPayload{
+		MaxStocks: maxStocks,
+		SyncJobID: syncJob.ID,
+		RequestID: logging.RequestIDFromContext(ctx),
+	})
+	if err != nil {
+		// Free the slot, or no batch could start again
+		if finishErr := h.finishSyncJob(syncJob.ID, jobs.StatusFailed, nil, err.Error()); finishErr != nil {
+			slog.ErrorContext(ctx, "Failed to fail sync job", "sync_job_id", syncJob.ID, "error", finishErr)
+		}
+		return nil, err
+	}
+	
+	if err := h.setSyncJobQueueJob(syncJob.ID, job.ID); err != nil {
+		slog.WarnContext(ctx, "Failed to link sync job to its queue job", "sync_job_id", syncJob.ID, "job_id", job.ID, "error", err)
+	} else {
+		syncJob.JobID = &job.ID
+	}
+	return syncJob, nil
+}
+
+// runBatchSyncJob executes a queued batch sync, reporting progress to its sync job after every stock
+func (h *HistoricalDataSyncService) runBatchSyncJob(ctx context.Context, job *jobs.Job) (interface{}, error) {
+	var payload batchSyncPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return nil, fmt.Errorf("invalid batch sync payload: %w", err)
+	}
+	ctx = logging.WithRequestID(ctx, payload.RequestID)
+	
+	var progress func(*SyncResult)
+	if payload.SyncJobID != 0 {
+		if err := h.markSyncJobRunning(payload.SyncJobID); err != nil {
+			slog.WarnContext(ctx, "Failed to mark sync job running", "sync_job_id", payload.SyncJobID, "error", err)
+		}
+		progress = func(result *SyncResult) {
+			if err := h.updateSyncJobProgress(payload.SyncJobID, result); err != nil {
+				slog.WarnContext(ctx, "Failed to update sync job progress", "sync_job_id", payload.SyncJobID, "error", err)
+			}
+		}
+	}
+	
+	var result *SyncResult
+	var err error
+	if payload.MaxStocks <= 0 {
+		err = fmt.Errorf("batch sync job has invalid max_stocks: %d", payload.MaxStocks)
+	} else {
+		result, err = h.syncBatch(ctx, payload.MaxStocks, progress)
+	}
+	
+	// On shutdown the sync job stays running, to be resumed with its queue job
+	cancelled := errors.Is(context.Cause(ctx), jobs.ErrJobCancelled)
+	if payload.SyncJobID != 0 && (ctx.Err() == nil || cancelled) {
+		status, message := jobs.StatusSucceeded, ""
+		if err != nil {
+			status, message = jobs.StatusFailed, err.Error()
+		} else {
+			message = result.Message
+		}
+		if cancelled {
+			status = jobs.StatusCancelled
+		}
+		if finishErr := h.finishSyncJob(payload.SyncJobID, status, result, message); finishErr != nil {
+			slog.ErrorContext(ctx, "Failed to finish sync job", "sync_job_id", payload.SyncJobID, "error", finishErr)
+		} else if h.webhooks != nil {
+			h.notifyBatchCompleted(context.WithoutCancel(ctx), payload.SyncJobID)
+		}
+	}
+	
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// notifyBatchCompleted sends a finished sync job to webhooks
+func (h *HistoricalDataSyncService) notifyBatchCompleted(ctx context.Context, syncJobID int64) {
+	job, err := h.GetSyncJob(ctx, syncJobID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to load finished sync job for webhooks", "sync_job_id", syncJobID, "error", err)
+		return
+	}
+	h.webhooks.Dispatch(WebhookEventBatchSyncCompleted, job)
 }
 
 // SyncBatch synchronizes historical data for multiple stocks in batch
-func (h *HistoricalDataSyncService) SyncBatch(maxStocks int) (*SyncResult, error) {
-	log.Printf("Starting batch sync for up to %d stocks", maxStocks)
+func (h *HistoricalDataSyncService) SyncBatch(ctx context.Context, maxStocks int) (*SyncResult, error) {
+	return h.syncBatch(ctx, maxStocks, nil)
+}
+
+// syncBatch runs a batch, calling progress, when set, after each stock
+func (h *HistoricalDataSyncService) syncBatch(ctx context.Context, maxStocks int, progress func(*SyncResult)) (*SyncResult, error) {
+	slog.InfoContext(ctx, "Starting batch sync", "max_stocks", maxStocks)
 	
 	// Check remaining API calls
-	canMake, err := h.alphaVantageClient.CanMakeRequest()
+	canMake, err := h.provider.CanMakeRequest()
 	if err != nil {
 		return nil, fmt.Errorf("failed to check API availability: %w", err)
 	}
@@ -36,22 +203,22 @@ func (h *HistoricalDataSyncService) SyncBatch(maxStocks int) (*SyncResult, error
 		return nil, fmt.Errorf("no API calls remaining for today")
 	}
 	
-	// Get current rate limit info
-	rateLimit, err := h.alphaVantageClient.GetRateLimit()
+	// Get current budget; the batch may not exceed what is left this hour or today
+	budget, err := h.provider.GetAPIBudget()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get rate limit info: %w", err)
 	}
 	
-	remainingCalls := rateLimit.DailyLimit - rateLimit.CurrentDailyCount
+	remainingCalls := budget.CallsAvailable()
 	if remainingCalls <= 0 {
-		return nil, fmt.Errorf("no API calls remaining today (%d/%d used)", 
-			rateLimit.CurrentDailyCount, rateLimit.DailyLimit)
+		return nil, fmt.Errorf("no API calls remaining (%d/%d used today, %d used this hour); next call allowed at %s", 
+			budget.DailyUsed, budget.DailyLimit, budget.HourlyUsed, budget.NextCallAllowedAt.Format(time.RFC3339))
 	}
 	
 	// Limit to available calls
 	if maxStocks > remainingCalls {
 		maxStocks = remainingCalls
-		log.Printf("Limiting sync to %d stocks due to API rate limits", maxStocks)
+		slog.InfoContext(ctx, "Limiting sync due to API rate limits", "max_stocks", maxStocks)
 	}
 	
 	// Get pending stocks ordered by priority
@@ -60,7 +227,7 @@ func (h *HistoricalDataSyncService) SyncBatch(maxStocks int) (*SyncResult, error
 		return nil, fmt.Errorf("failed to get pending stocks: %w", err)
 	}
 	
-	log.Printf("Found %d pending stocks for sync", len(pendingStocks))
+	slog.InfoContext(ctx, "Found pending stocks for sync", "count", len(pendingStocks))
 	
 	if len(pendingStocks) == 0 {
 		return &SyncResult{
@@ -78,9 +245,21 @@ func (h *HistoricalDataSyncService) SyncBatch(maxStocks int) (*SyncResult, error
 	}
 	
 	for i, stock := range pendingStocks {
-		log.Printf("Syncing stock %d/%d: %s (priority %d)", i+1, len(pendingStocks), stock.Symbol, stock.Priority)
+		// A cancelled batch attempts no further stocks
+		if ctx.Err() != nil {
+			result.Cancelled = true
+			result.Skipped = len(pendingStocks) - i
+			slog.InfoContext(ctx, "Batch sync cancelled, skipping the remaining stocks", "skipped", result.Skipped)
+			break
+		}
+		
+		slog.InfoContext(ctx, "Syncing stock", "symbol", stock.Symbol, "position", i+1, "total", len(pendingStocks), "priority", stock.Priority)
 		
-		stockResult := h.syncSingleStock(stock)
+		// The stock in progress is fetched and saved in full even if the batch is cancelled meanwhile
+		stockResult := h.syncSingleStock(context.WithoutCancel(ctx), stock)
+		if err := h.history.Record(context.WithoutCancel(ctx), InitiatorBatchSync, stockResult); err != nil {
+			slog.WarnContext(ctx, "Failed to record sync history", "symbol", stock.Symbol, "error", err)
+		}
 		result.Stocks = append(result.Stocks, stockResult)
 		result.TotalAttempted++
 		
@@ -89,26 +268,67 @@ func (h *HistoricalDataSyncService) SyncBatch(maxStocks int) (*SyncResult, error
 		} else {
 			result.Failed++
 		}
+		if progress != nil {
+			progress(result)
+		}
 		
-		// Add small delay between API calls to be respectful
-		if i < len(pendingStocks)-1 {
-			time.Sleep(1 * time.Second)
+		// Every remaining call would be refused too, so stop rather than burn the batch;
+		// a bad symbol only affects itself and the batch carries on
+		if stockResult.ErrorKind == APIErrorRateLimited {
+			result.Aborted = true
+			result.Skipped = len(pendingStocks) - (i + 1)
+			slog.WarnContext(ctx, "Rate limited, skipping the remaining stocks", "symbol", stock.Symbol, "skipped", result.Skipped)
+			break
 		}
 	}
 	
+	h.announceSynced(ctx, result.Stocks)
+	
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(result.StartTime)
-	result.Message = fmt.Sprintf("Batch sync completed: %d successful, %d failed out of %d attempted", 
-		result.Successful, result.Failed, result.TotalAttempted)
+	if result.Aborted {
+		result.Message = fmt.Sprintf("Batch sync stopped by rate limit: %d successful, %d failed out of %d attempted, %d skipped", 
+			result.Successful, result.Failed, result.TotalAttempted, result.Skipped)
+	} else if result.Cancelled {
+		result.Message = fmt.Sprintf("Batch sync cancelled: %d successful, %d failed out of %d attempted, %d skipped", 
+			result.Successful, result.Failed, result.TotalAttempted, result.Skipped)
+	} else {
+		result.Message = fmt.Sprintf("Batch sync completed: %d successful, %d failed out of %d attempted", 
+			result.Successful, result.Failed, result.TotalAttempted)
+	}
 	
-	log.Printf("Batch sync completed in %v: %d successful, %d failed", 
-		result.Duration, result.Successful, result.Failed)
+	slog.InfoContext(ctx, "Batch sync completed", "duration", result.Duration, "successful", result.Successful, "failed", result.Failed)
 	
 	return result, nil
 }
 
+// announceSynced invalidates the response cache once a batch has saved any
+// stock, so no response from before the batch is served, and then tells live
+// clients about each stock saved, as a manual sync does
+func (h *HistoricalDataSyncService) announceSynced(ctx context.Context, stocks []StockSyncResult) {
+	var synced []string
+	for _, stock := range stocks {
+		if stock.Success {
+			synced = append(synced, stock.Symbol)
+		}
+	}
+	if len(synced) == 0 {
+		return
+	}
+	
+	// Even a cancelled batch saved these stocks
+	if h.cache != nil {
+		if err := h.cache.InvalidateAll(context.WithoutCancel(ctx)); err != nil {
+			slog.WarnContext(ctx, "Failed to invalidate cache after batch sync", "synced", len(synced), "error", err)
+		}
+	}
+	for _, symbol := range synced {
+		h.events.PublishStockUpdated(symbol)
+	}
+}
+
 // syncSingleStock synchronizes historical data for a single stock
-func (h *HistoricalDataSyncService) syncSingleStock(stock SP500Stock) StockSyncResult {
+func (h *HistoricalDataSyncService) syncSingleStock(ctx context.Context, stock SP500Stock) StockSyncResult {
 	start := time.Now()
 	
 	result := StockSyncResult{
@@ -117,44 +337,51 @@ func (h *HistoricalDataSyncService) syncSingleStock(stock SP500Stock) StockSyncR
 		StartTime: start,
 	}
 	
-	// Fetch historical data from Alpha Vantage
-	data, err := h.alphaVantageClient.FetchDailyData(stock.Symbol)
+	// Fetch the missing days from the market data provider
+	data, err := fetchMissingDailyData(ctx, h.db, h.provider, stock.Symbol, InitiatorBatchSync)
 	if err != nil {
 		result.Success = false
 		result.ErrorMessage = err.Error()
+		result.ErrorKind = ErrorKindOf(err)
+		result.Retryable = result.ErrorKind.Retryable()
 		result.EndTime = time.Now()
-		log.Printf("Failed to fetch data for %s: %v", stock.Symbol, err)
+		slog.ErrorContext(ctx, "Failed to fetch data", "symbol", stock.Symbol, "error", err)
 		return result
 	}
 	
-	// Save to database
-	err = h.alphaVantageClient.SaveHistoricalData(stock.Symbol, data)
+	// Save to database, queueing behind any other writer of this symbol
+	result.RowsSkipped = data.Rejected
+	stats, err := h.provider.SaveHistoricalData(ctx, stock.Symbol, data, SyncLockWait)
+	result.RowsSkipped = append(result.RowsSkipped, stats.Rejected...)
 	if err != nil {
 		result.Success = false
 		result.ErrorMessage = fmt.Sprintf("Failed to save data: %v", err)
+		result.ErrorKind = APIErrorSaveFailed
+		result.Retryable = true
 		result.EndTime = time.Now()
-		log.Printf("Failed to save data for %s: %v", stock.Symbol, err)
+		slog.ErrorContext(ctx, "Failed to save data", "symbol", stock.Symbol, "error", err)
 		return result
 	}
+	h.readRouter.MarkWritten(stock.Symbol)
 	
 	// Update stock metadata with S&P 500 info
 	err = h.sp500PriorityService.UpdateStockWithPriority(stock.Symbol)
 	if err != nil {
-		log.Printf("Warning: Failed to update priority for %s: %v", stock.Symbol, err)
+		slog.WarnContext(ctx, "Failed to update priority", "symbol", stock.Symbol, "error", err)
 	}
 	
 	// Update data completeness status
 	err = h.updateStockDataStatus(stock.Symbol)
 	if err != nil {
-		log.Printf("Warning: Failed to update data status for %s: %v", stock.Symbol, err)
+		slog.WarnContext(ctx, "Failed to update data status", "symbol", stock.Symbol, "error", err)
 	}
 	
 	result.Success = true
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(start)
-	result.RecordsAdded = len(data.TimeSeries)
+	result.RecordsAdded = stats.Inserted
 	
-	log.Printf("Successfully synced %s: %d records in %v", stock.Symbol, result.RecordsAdded, result.Duration)
+	slog.InfoContext(ctx, "Successfully synced stock", "symbol", stock.Symbol, "records", result.RecordsAdded, "skipped", stats.Skipped, "rows_rejected", len(result.RowsSkipped), "duration", result.Duration)
 	
 	return result
 }
@@ -174,7 +401,8 @@ func (h *HistoricalDataSyncService) updateStockDataStatus(symbol string) error {
 			WHERE dp.stock_id = stocks.id
 		),
 		last_data_sync = CURRENT_TIMESTAMP,
-		updated_at = CURRENT_TIMESTAMP
+		updated_at = CURRENT_TIMESTAMP,
+		` + h.priceRanges.storedColumnAssignment() + `
 		WHERE symbol = $1
 	`
 	
@@ -195,31 +423,19 @@ func (h *HistoricalDataSyncService) GetSyncStatus() (*SyncStatus, error) {
 		LastSyncTime:         time.Time{},
 	}
 	
-	// Check each stock's data status
+	// One pass over every active stock's coverage, rather than a query per symbol
+	coverage, err := h.stockCoverage()
+	if err != nil {
+		return nil, err
+	}
+	
+	// Walk the S&P 500 list so pending stocks stay in priority order; a stock
+	// missing from the table has no data yet
 	for _, stock := range sp500Stocks {
-		query := `
-			SELECT 
-				COALESCE(s.has_sufficient_data, false) as has_data,
-				COUNT(dp.date) as price_count,
-				MAX(dp.date) as latest_date,
-				s.last_data_sync
-			FROM stocks s
-			LEFT JOIN daily_prices dp ON s.id = dp.stock_id
-			WHERE s.symbol = $1 AND s.is_active = true
-			GROUP BY s.id, s.has_sufficient_data, s.last_data_sync
-		`
+		row := coverage[stock.Symbol]
+		historyDays, lastSync := row.historyDays, row.lastSync
 		
-		var hasData bool
-		var priceCount int
-		var latestDate sql.NullTime
-		var lastSync sql.NullTime
-		
-		err := h.db.QueryRow(query, stock.Symbol).Scan(&hasData, &priceCount, &latestDate, &lastSync)
-		if err != nil && err != sql.ErrNoRows {
-			continue
-		}
-		
-		if hasData && priceCount >= 30 {
+		if historyDays >= SufficientHistoryDays {
 			status.StocksWithData++
 		} else {
 			status.StocksNeedingData++
@@ -236,11 +452,12 @@ func (h *HistoricalDataSyncService) GetSyncStatus() (*SyncStatus, error) {
 	}
 	
 	// Get API rate limit info
-	rateLimit, err := h.alphaVantageClient.GetRateLimit()
+	budget, err := h.provider.GetAPIBudget()
 	if err == nil {
-		status.APICallsUsed = rateLimit.CurrentDailyCount
-		status.APICallsRemaining = rateLimit.DailyLimit - rateLimit.CurrentDailyCount
-		status.DailyAPILimit = rateLimit.DailyLimit
+		status.APICallsUsed = budget.DailyUsed
+		status.APICallsRemaining = budget.CallsAvailable()
+		status.DailyAPILimit = budget.DailyLimit
+		status.NextCallAllowedAt = budget.NextCallAllowedAt
 	}
 	
 	status.PercentComplete = float64(status.StocksWithData) / float64(status.TotalSP500Stocks) * 100
@@ -248,6 +465,60 @@ func (h *HistoricalDataSyncService) GetSyncStatus() (*SyncStatus, error) {
 	return status, nil
 }
 
+// stockSyncCoverage is an active stock's stored price history and last sync
+type stockSyncCoverage struct {
+	historyDays int
+	lastSync    sql.NullTime
+}
+
+// stockCoverage returns the price coverage of every active stock, keyed by symbol
+func (h *HistoricalDataSyncService) stockCoverage() (map[string]stockSyncCoverage, error) {
+	query := `
+		SELECT s.symbol,
+		       COALESCE(s.last_price_date - s.first_price_date, 0) as history_days,
+		       s.last_data_sync
+		FROM stocks s
+		WHERE s.is_active = true
+	`
+	
+	rows, err := h.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock coverage: %w", err)
+	}
+	defer rows.Close()
+	
+	coverage := make(map[string]stockSyncCoverage)
+	for rows.Next() {
+		var symbol string
+		var row stockSyncCoverage
+		if err := rows.Scan(&symbol, &row.historyDays, &row.lastSync); err != nil {
+			return nil, fmt.Errorf("failed to scan stock coverage: %w", err)
+		}
+		coverage[symbol] = row
+	}
+	return coverage, rows.Err()
+}
+
+// GetDataCoverage returns how many active stocks have enough price history, out of all active stocks
+func (h *HistoricalDataSyncService) GetDataCoverage() (withData int, total int, err error) {
+	query := `
+		SELECT COUNT(*) FILTER (WHERE has_sufficient_data), COUNT(*)
+		FROM stocks
+		WHERE is_active = true
+	`
+	
+	err = h.db.QueryRow(query).Scan(&withData, &total)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get data coverage: %w", err)
+	}
+	return withData, total, nil
+}
+
+// GetSyncHistory returns a page of per-stock sync outcomes and the total matching filter
+func (h *HistoricalDataSyncService) GetSyncHistory(ctx context.Context, filter SyncHistoryFilter) ([]SyncHistoryEntry, int, error) {
+	return h.history.List(ctx, filter)
+}
+
 // GetDB returns the database connection for use in handlers
 func (h *HistoricalDataSyncService) GetDB() *sql.DB {
 	return h.db
@@ -262,6 +533,9 @@ type SyncResult struct {
 	EndTime        time.Time           `json:"end_time"`
 	Duration       time.Duration       `json:"duration"`
 	Message        string              `json:"message"`
+	Aborted        bool                `json:"aborted"`
+	Cancelled      bool                `json:"cancelled"`
+	Skipped        int                 `json:"skipped"`
 	Stocks         []StockSyncResult   `json:"stocks"`
 }
 
@@ -271,7 +545,11 @@ type StockSyncResult struct {
 	Priority     int           `json:"priority"`
 	Success      bool          `json:"success"`
 	ErrorMessage string        `json:"error_message,omitempty"`
+	ErrorKind    APIErrorKind  `json:"error_kind,omitempty"`
+	Retryable    bool          `json:"retryable,omitempty"`
 	RecordsAdded int           `json:"records_added"`
+	// RowsSkipped are the provider's rows left out as malformed or impossible
+	RowsSkipped  []RejectedRow `json:"rows_skipped,omitempty"`
 	StartTime    time.Time     `json:"start_time"`
 	EndTime      time.Time     `json:"end_time"`
 	Duration     time.Duration `json:"duration"`
@@ -287,5 +565,6 @@ type SyncStatus struct {
 	APICallsUsed         int       `json:"api_calls_used"`
 	APICallsRemaining    int       `json:"api_calls_remaining"`
 	DailyAPILimit        int       `json:"daily_api_limit"`
+	NextCallAllowedAt    time.Time `json:"next_call_allowed_at"`
 	LastSyncTime         time.Time `json:"last_sync_time"`
 }
\ No newline at end of file
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/cache"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectBatchBudget expects the availability checks SyncBatch makes before syncing
+func expectBatchBudget(mock sqlmock.Sqlmock) {
+	expectRateLimitQuery(mock)
+	mock.ExpectQuery("FROM api_rate_limits").WillReturnRows(sqlmock.NewRows([]string{
+		"id", "service_name", "daily_limit", "hourly_limit", "current_daily_count",
+		"current_hourly_count", "last_reset_date", "last_reset_hour", "created_at", "updated_at",
+	}).AddRow(1, "alphavantage", 25, nil, 0, 0, marketDate(time.Now()), marketHour(time.Now()), time.Now(), time.Now()))
+	mock.ExpectQuery("SELECT MAX\\(created_at\\) FROM api_calls").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+}
+
+func expectPendingStocks(mock sqlmock.Sqlmock, symbols ...string) {
+	expectConstituents(mock)
+	rows := sqlmock.NewRows([]string{"symbol", "company_name", "market_cap", "history_days"})
+	for i, symbol := range symbols {
+		rows.AddRow(symbol, symbol+" Inc.", int64(1000000000-i), 0)
+	}
+	mock.ExpectQuery("last_price_date - s.first_price_date, 0\\) < \\$2").
+		WithArgs(sqlmock.AnyArg(), SufficientHistoryDays, IndexSP500).WillReturnRows(rows)
+}
+
+// expectFailedFetch expects the calls a batch makes to fetch a stock with no
+// stored prices when the request fails
+func expectFailedFetch(mock sqlmock.Sqlmock) {
+	expectLatestPriceDate(mock, nil)
+	expectRateLimitQuery(mock)
+	mock.ExpectExec("INSERT INTO api_calls").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRateLimitIncrement(mock)
+}
+
+func newBatchSyncService(t *testing.T, responses map[string]string) (*HistoricalDataSyncService, sqlmock.Sqlmock) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, responses[r.URL.Query().Get("symbol")])
+	}))
+	t.Cleanup(server.Close)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	client := NewAlphaVantageClient("test-key", db)
+	client.baseURL = server.URL
+
+	service := NewHistoricalDataSyncService(db, client, nil)
+	return service, mock
+}
+
+func TestSyncBatch_ContinuesPastInvalidSymbolAndStopsOnRateLimit(t *testing.T) {
+	service, mock := newBatchSyncService(t, map[string]string{
+		"BADSYM": `{"Error Message": "Invalid API call."}`,
+		"NEXT":   `{"Note": "Our standard API call frequency is 5 calls per minute."}`,
+	})
+
+	expectBatchBudget(mock)
+	expectPendingStocks(mock, "BADSYM", "NEXT", "LATER")
+	expectFailedFetch(mock) // BADSYM: permanent, keep going
+	expectSyncHistory(mock, "BADSYM", false, APIErrorInvalidSymbol, InitiatorBatchSync)
+	expectFailedFetch(mock) // NEXT: rate limited, stop
+	expectSyncHistory(mock, "NEXT", false, APIErrorRateLimited, InitiatorBatchSync)
+
+	result, err := service.SyncBatch(context.Background(), 3)
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet(), "LATER must not be fetched")
+
+	assert.True(t, result.Aborted)
+	assert.Equal(t, 2, result.TotalAttempted)
+	assert.Equal(t, 2, result.Failed)
+	assert.Equal(t, 1, result.Skipped)
+	assert.True(t, strings.HasPrefix(result.Message, "Batch sync stopped by rate limit"))
+
+	require.Len(t, result.Stocks, 2)
+	assert.Equal(t, APIErrorInvalidSymbol, result.Stocks[0].ErrorKind)
+	assert.False(t, result.Stocks[0].Retryable)
+	assert.Equal(t, APIErrorRateLimited, result.Stocks[1].ErrorKind)
+	assert.True(t, result.Stocks[1].Retryable)
+}
+
+func TestSyncBatch_ContinuesOnPermanentFailures(t *testing.T) {
+	service, mock := newBatchSyncService(t, map[string]string{
+		"BADSYM": `{"Error Message": "Invalid API call."}`,
+		"EMPTY":  `{"Meta Data": {}, "Time Series (Daily)": {}}`,
+	})
+
+	expectBatchBudget(mock)
+	expectPendingStocks(mock, "BADSYM", "EMPTY")
+	expectFailedFetch(mock)
+	expectSyncHistory(mock, "BADSYM", false, APIErrorInvalidSymbol, InitiatorBatchSync)
+	expectFailedFetch(mock)
+	expectSyncHistory(mock, "EMPTY", false, APIErrorNoData, InitiatorBatchSync)
+
+	result, err := service.SyncBatch(context.Background(), 2)
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.False(t, result.Aborted)
+	assert.Equal(t, 2, result.Failed)
+	assert.Zero(t, result.Skipped)
+	assert.Equal(t, APIErrorNoData, result.Stocks[1].ErrorKind)
+}
+
+func TestSyncBatch_AnnouncesSyncedStocks(t *testing.T) {
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache("redis://"+server.Addr(), "test:")
+	require.NoError(t, err)
+	defer redisCache.Close()
+	events := NewStockEventBus()
+	updates, unsubscribe := events.Subscribe()
+	defer unsubscribe()
+
+	service := NewHistoricalDataSyncService(nil, nil, nil)
+	service.SetCache(redisCache)
+	service.SetStockEvents(events)
+	ctx := context.Background()
+
+	// A batch that saved nothing leaves the cache alone
+	require.NoError(t, redisCache.SetStocksList(ctx, []string{}, time.Hour))
+	service.announceSynced(ctx, []StockSyncResult{{Symbol: "BADSYM"}})
+	assert.True(t, server.Exists("test:stocks:all"))
+	assert.Empty(t, updates)
+
+	service.announceSynced(ctx, []StockSyncResult{
+		{Symbol: "AAPL", Success: true},
+		{Symbol: "BADSYM"},
+		{Symbol: "MSFT", Success: true},
+	})
+
+	assert.False(t, server.Exists("test:stocks:all"))
+	generation, err := redisCache.ResponseGeneration(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), generation, "responses cached before the batch must not be served")
+	require.Len(t, updates, 2)
+	assert.Equal(t, "AAPL", (<-updates).Symbol)
+	assert.Equal(t, "MSFT", (<-updates).Symbol)
+}
+
+// stockCoverageRows is the coverage query's result: every listed S&P 500 stock
+// with a full history except MSFT, which has a month
+func stockCoverageRows(lastSync time.Time) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"symbol", "history_days", "last_data_sync"})
+	for _, stock := range defaultSP500Stocks() {
+		switch stock.Symbol {
+		case "MSFT":
+			rows.AddRow(stock.Symbol, 30, lastSync.Add(-time.Hour))
+		case "GOOGL", "NVDA":
+			// Not in the stocks table yet
+		default:
+			rows.AddRow(stock.Symbol, SufficientHistoryDays, lastSync)
+		}
+	}
+	return rows
+}
+
+func TestGetSyncStatus_SingleCoverageQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	lastSync := time.Date(2024, 1, 2, 22, 0, 0, 0, time.UTC)
+	expectConstituents(mock)
+	mock.ExpectQuery("FROM stocks s").WillReturnRows(stockCoverageRows(lastSync))
+	expectBudget(mock, 5)
+
+	service := NewHistoricalDataSyncService(db, NewAlphaVantageClient("test-key", db), nil)
+	status, err := service.GetSyncStatus()
+
+	require.NoError(t, err)
+	total := len(defaultSP500Stocks())
+	assert.Equal(t, total, status.TotalSP500Stocks)
+	assert.Equal(t, total-3, status.StocksWithData)
+	assert.Equal(t, 3, status.StocksNeedingData)
+	// Pending stocks keep the S&P 500 priority order
+	assert.Equal(t, []string{"MSFT", "GOOGL", "NVDA"}, status.TopPriorityPending)
+	assert.Equal(t, lastSync, status.LastSyncTime)
+	assert.Equal(t, 5, status.APICallsUsed)
+	assert.NoError(t, mock.ExpectationsWereMet(), "one coverage query, then the API budget")
+}
+
+// BenchmarkGetSyncStatus loads the sync dashboard with each statement taking a
+// simulated 100µs database round trip. The coverage is one query where a query
+// per S&P 500 symbol made one round trip per stock.
+func BenchmarkGetSyncStatus(b *testing.B) {
+	roundTrip := 100 * time.Microsecond
+	lastSync := time.Now()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		db, mock, err := sqlmock.New()
+		require.NoError(b, err)
+		expectConstituents(mock)
+		mock.ExpectQuery("FROM stocks s").WillDelayFor(roundTrip).WillReturnRows(stockCoverageRows(lastSync))
+		expectBudget(mock, 5)
+		service := NewHistoricalDataSyncService(db, NewAlphaVantageClient("test-key", db), nil)
+		b.StartTimer()
+
+		if _, err := service.GetSyncStatus(); err != nil {
+			b.Fatal(err)
+		}
+
+		b.StopTimer()
+		db.Close()
+		b.StartTimer()
+	}
+}
@@ -1,7 +1,8 @@
 package services
 
 import (
-	"log"
+	"context"
+	"log/slog"
 
 	"stock-intelligence-backend/internal/models"
 )
@@ -18,13 +19,14 @@ func NewHybridStockService(databaseService *DatabaseStockService) *HybridStockSe
 		databaseService: databaseService,
 	}
 
-	log.Println("Stock service initialized with database backend")
+	slog.Info("Stock service initialized with database backend")
 	return service
 }
 
-// GetAllStocks returns all stocks from database
+// GetAllStocks returns all stocks from database. The WebSocket feed reads
+// outside any HTTP request, so these lookups run without a request context.
 func (h *HybridStockService) GetAllStocks() []models.Stock {
-	return h.databaseService.GetAllStocks()
+	return h.databaseService.GetAllStocks(context.Background())
 }
 
 // refreshCache is no longer needed as we use database service directly
@@ -33,9 +35,10 @@ func (h *HybridStockService) refreshCache() {
 	return
 }
 
-// GetStockBySymbol returns a specific stock by symbol
+// GetStockBySymbol returns a specific stock by symbol, or nil when the symbol
+// is unknown. Stocks not yet synced are returned with HasPriceData false.
 func (h *HybridStockService) GetStockBySymbol(symbol string) *models.Stock {
-	stock, err := h.databaseService.GetStockBySymbol(symbol)
+	stock, err := h.databaseService.GetStockBySymbol(context.Background(), symbol)
 	if err != nil {
 		return nil
 	}
@@ -44,105 +47,37 @@ func (h *HybridStockService) GetStockBySymbol(symbol string) *models.Stock {
 
 // GetStocksByPriceRange filters stocks by price range
 func (h *HybridStockService) GetStocksByPriceRange(priceRange string) []models.Stock {
-	return h.databaseService.GetStocksByPriceRange(priceRange)
+	return h.databaseService.GetStocksByPriceRange(context.Background(), priceRange)
 }
 
 // GetStocksBySector filters stocks by sector
 func (h *HybridStockService) GetStocksBySector(sector string) []models.Stock {
-	return h.databaseService.GetStocksBySector(sector)
+	return h.databaseService.GetStocksBySector(context.Background(), sector)
 }
 
 // GetPerformanceData returns categorized performance data
 func (h *HybridStockService) GetPerformanceData() models.StockPerformance {
-	stocks := h.GetAllStocks()
-
-	var gainers, losers, mostActive []models.Stock
-
-	// Separate stocks by performance
-	for _, stock := range stocks {
-		if stock.ChangePercent > 0 {
-			gainers = append(gainers, stock)
-		} else if stock.ChangePercent < 0 {
-			losers = append(losers, stock)
-		}
-		mostActive = append(mostActive, stock)
-	}
-
-	// Sort gainers by change percent (descending)
-	for i := 0; i < len(gainers)-1; i++ {
-		for j := i + 1; j < len(gainers); j++ {
-			if gainers[i].ChangePercent < gainers[j].ChangePercent {
-				gainers[i], gainers[j] = gainers[j], gainers[i]
-			}
-		}
-	}
-
-	// Sort losers by change percent (ascending)
-	for i := 0; i < len(losers)-1; i++ {
-		for j := i + 1; j < len(losers); j++ {
-			if losers[i].ChangePercent > losers[j].ChangePercent {
-				losers[i], losers[j] = losers[j], losers[i]
-			}
-		}
-	}
-
-	// Sort by volume for most active
-	for i := 0; i < len(mostActive)-1; i++ {
-		for j := i + 1; j < len(mostActive); j++ {
-			if mostActive[i].Volume < mostActive[j].Volume {
-				mostActive[i], mostActive[j] = mostActive[j], mostActive[i]
-			}
-		}
-	}
-
-	// Limit to top 10 each
-	if len(gainers) > 10 {
-		gainers = gainers[:10]
-	}
-	if len(losers) > 10 {
-		losers = losers[:10]
-	}
-	if len(mostActive) > 10 {
-		mostActive = mostActive[:10]
+	movers, err := h.databaseService.GetTopMovers(context.Background(), TopMoversLimit)
+	if err != nil {
+		slog.Error("Failed to rank stocks", "error", err)
+		return models.StockPerformance{}
 	}
-
-	return models.StockPerformance{
-		TopGainers: gainers,
-		TopLosers:  losers,
-		MostActive: mostActive,
+	performance := movers.StockPerformance
+	performance.MostActive = make([]models.Stock, len(movers.MostActive))
+	for i, active := range movers.MostActive {
+		performance.MostActive[i] = active.Stock
 	}
+	return performance
 }
 
 // GetMarketOverview returns overall market statistics
 func (h *HybridStockService) GetMarketOverview() models.MarketOverview {
-	allStocks := h.GetAllStocks()
-
-	var advancing, declining, unchanged int
-	var totalChange float64
-
-	for _, stock := range allStocks {
-		if stock.ChangePercent > 0 {
-			advancing++
-		} else if stock.ChangePercent < 0 {
-			declining++
-		} else {
-			unchanged++
-		}
-		totalChange += stock.ChangePercent
-	}
-
-	avgChange := 0.0
-	if len(allStocks) > 0 {
-		avgChange = totalChange / float64(len(allStocks))
-	}
-
-	return models.MarketOverview{
-		TotalStocks:    len(allStocks),
-		AdvancingCount: advancing,
-		DecliningCount: declining,
-		UnchangedCount: unchanged,
-		AvgChange:      avgChange,
+	overview, err := h.databaseService.GetMarketOverviewAggregate(context.Background())
+	if err != nil {
+		slog.Error("Failed to compute market overview", "error", err)
+		return models.MarketOverview{}
 	}
+	return overview.MarketOverview
 }
 
 // GetDataSource returns information about current data source
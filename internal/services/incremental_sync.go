@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// CompactOutputTradingDays is how many of the latest trading days a compact fetch returns
+const CompactOutputTradingDays = 100
+
+// RecentDailyProvider is implemented by market data providers that can serve
+// just the latest CompactOutputTradingDays trading days, which is far cheaper
+// than the full history for a stock that is nearly up to date.
+type RecentDailyProvider interface {
+	FetchRecentDailyData(ctx context.Context, symbol string, initiator Initiator) (*DailySeries, error)
+}
+
+// fetchMissingDailyData fetches the prices a stock is missing. When its stored
+// history ends within the last CompactOutputTradingDays trading days, has no
+// gap further back than that, and the provider can, only the recent days are
+// fetched; otherwise the full history.
+func fetchMissingDailyData(ctx context.Context, db *sql.DB, provider MarketDataProvider, symbol string, initiator Initiator) (*DailySeries, error) {
+	recent, ok := provider.(RecentDailyProvider)
+	if !ok {
+		return provider.FetchDailyData(ctx, symbol, initiator)
+	}
+
+	latest, err := latestStoredPriceDate(ctx, db, symbol)
+	if err != nil {
+		// The full history is always correct, just larger
+		slog.WarnContext(ctx, "Failed to get latest stored date, fetching full history", "symbol", symbol, "error", err)
+		return provider.FetchDailyData(ctx, symbol, initiator)
+	}
+	if latest.IsZero() || tradingDaysSince(latest, time.Now()) >= CompactOutputTradingDays {
+		return provider.FetchDailyData(ctx, symbol, initiator)
+	}
+
+	// Only the full history can backfill a gap older than the recent days
+	report, err := NewDataQualityService(db, nil).gaps(ctx, symbol)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to check for gaps, fetching full history", "symbol", symbol, "error", err)
+		return provider.FetchDailyData(ctx, symbol, initiator)
+	}
+	if len(report) > 0 && tradingDaysSince(report[0].Gaps[0].From, time.Now()) >= CompactOutputTradingDays {
+		return provider.FetchDailyData(ctx, symbol, initiator)
+	}
+	return recent.FetchRecentDailyData(ctx, symbol, initiator)
+}
+
+// latestStoredPriceDate returns the date of a stock's newest stored price, zero when it has none
+func latestStoredPriceDate(ctx context.Context, db *sql.DB, symbol string) (time.Time, error) {
+	var latest sql.NullTime
+	err := db.QueryRowContext(ctx, `
+		SELECT MAX(dp.date)
+		FROM daily_prices dp
+		JOIN stocks s ON s.id = dp.stock_id
+		WHERE s.symbol = $1
+	`, symbol).Scan(&latest)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return latest.Time, nil
+}
+
+// tradingDaysSince counts the weekdays after since, up to and including now.
+// Holidays are counted too, so a stock is never judged fresher than it is.
+func tradingDaysSince(since, now time.Time) int {
+	since = time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, time.UTC)
+	now = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	days := 0
+	for day := since.AddDate(0, 0, 1); !day.After(now); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			days++
+		}
+	}
+	return days
+}
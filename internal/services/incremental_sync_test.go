@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectLatestPriceDate expects a sync to look up a stock's newest stored price, nil for none
+func expectLatestPriceDate(mock sqlmock.Sqlmock, latest interface{}) {
+	mock.ExpectQuery(`SELECT MAX\(dp.date\)`).WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(latest))
+}
+
+func TestTradingDaysSince(t *testing.T) {
+	friday := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 0, tradingDaysSince(friday, friday))
+	assert.Equal(t, 0, tradingDaysSince(friday, friday.AddDate(0, 0, 2)), "weekends are not trading days")
+	assert.Equal(t, 1, tradingDaysSince(friday, friday.AddDate(0, 0, 3)))
+	assert.Equal(t, 10, tradingDaysSince(friday, friday.AddDate(0, 0, 14)))
+}
+
+func TestFetchMissingDailyData_ChoosesOutputSize(t *testing.T) {
+	recent := storedGap{"AAPL", time.Now().AddDate(0, 0, -21), time.Now().AddDate(0, 0, -7)}
+	old := storedGap{"AAPL", time.Now().AddDate(0, -8, -14), time.Now().AddDate(0, -8, 0)}
+	tests := []struct {
+		name       string
+		latest     interface{}
+		gaps       []storedGap
+		outputSize string
+	}{
+		{name: "empty stock", latest: nil, outputSize: "full"},
+		{name: "one day stale", latest: time.Now().AddDate(0, 0, -1), gaps: []storedGap{}, outputSize: "compact"},
+		{name: "six months stale", latest: time.Now().AddDate(0, -6, 0), outputSize: "full"},
+		{name: "recent gap", latest: time.Now().AddDate(0, 0, -1), gaps: []storedGap{recent}, outputSize: "compact"},
+		{name: "gap older than the compact window", latest: time.Now().AddDate(0, 0, -1), gaps: []storedGap{old, recent}, outputSize: "full"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var outputSize string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				outputSize = r.URL.Query().Get("outputsize")
+				fmt.Fprint(w, dailySeriesFixture)
+			}))
+			defer server.Close()
+
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			expectLatestPriceDate(mock, tt.latest)
+			if tt.gaps != nil {
+				expectGaps(mock, tt.gaps...)
+			}
+			expectRateLimitQuery(mock)
+			mock.ExpectExec("INSERT INTO api_calls").WillReturnResult(sqlmock.NewResult(1, 1))
+			expectRequestRecorded(mock, ProviderAlphaVantage)
+
+			client := NewAlphaVantageClient("test-key", db)
+			client.baseURL = server.URL
+
+			series, err := fetchMissingDailyData(context.Background(), db, client, "AAPL", InitiatorScheduler)
+
+			require.NoError(t, err)
+			assert.Len(t, series.Bars, 1)
+			assert.Equal(t, tt.outputSize, outputSize)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSaveHistoricalData_SkipsBarsOlderThanStored(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	series := &DailySeries{Symbol: "AAPL", Bars: []DailyBar{
+		{Date: day(2), Close: 185.64},
+		{Date: day(3), Close: 184.25},
+		{Date: day(4), Close: 181.91},
+	}}
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expectAdvisoryLock(mock, 1, true)
+	expectSaveBegins(mock, 1, day(3))
+	// The stored latest day is written again, as it may have been partial
+	mock.ExpectQuery("INSERT INTO daily_prices").
+		WithArgs(1, day(3), 0.0, 0.0, 0.0, 184.25, 184.25, int64(0), day(4), 0.0, 0.0, 0.0, 181.91, 181.91, int64(0)).
+		WillReturnRows(sqlmock.NewRows([]string{"inserted"}).AddRow(false).AddRow(true))
+	mock.ExpectExec(`UPDATE stocks\s+SET first_price_date`).WithArgs(1, day(3), day(4), false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectAdvisoryUnlock(mock, 1)
+
+	client := NewAlphaVantageClient("test-key", db)
+	stats, err := client.SaveHistoricalData(context.Background(), "AAPL", series, SyncLockWait)
+
+	require.NoError(t, err)
+	assert.Equal(t, SaveStats{Inserted: 1, Updated: 1, Skipped: 1}, stats)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
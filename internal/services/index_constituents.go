@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// IndexSP500 is the index_name of the S&P 500 in index_constituents
+const IndexSP500 = "sp500"
+
+// SupportedIndexes are the indexes whose constituents can be imported
+var SupportedIndexes = []string{IndexSP500}
+
+// IndexConstituent is one stock's membership of an index
+type IndexConstituent struct {
+	IndexName string
+	Symbol    string
+	// Priority orders syncs, 1 first; usually the rank by index weight
+	Priority int
+	// Weight is the stock's share of the index, when the list gives one
+	Weight   *float64
+	AsOfDate time.Time
+}
+
+// ParseConstituentsCSV reads an index's constituents from CSV with a header
+// row. Only the symbol column is required. Priority defaults to the row's
+// position, weight to none, and as_of_date to asOf; columns may come in any order.
+func ParseConstituentsCSV(r io.Reader, indexName string, asOf time.Time) ([]IndexConstituent, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("constituents CSV is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read constituents header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["symbol"]; !ok {
+		return nil, errors.New("constituents CSV has no symbol column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var constituents []IndexConstituent
+	seen := make(map[string]int)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read constituents: %w", err)
+		}
+
+		symbol, err := models.NormalizeSymbol(field(record, "symbol"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if first, ok := seen[symbol]; ok {
+			return nil, fmt.Errorf("line %d: %s is already listed on line %d", line, symbol, first)
+		}
+		seen[symbol] = line
+
+		constituent := IndexConstituent{
+			IndexName: indexName,
+			Symbol:    symbol,
+			Priority:  len(constituents) + 1,
+			AsOfDate:  asOf,
+		}
+		if value := field(record, "priority"); value != "" {
+			priority, err := strconv.Atoi(value)
+			if err != nil || priority <= 0 {
+				return nil, fmt.Errorf("line %d: priority %q is not a positive whole number", line, value)
+			}
+			constituent.Priority = priority
+		}
+		if value := field(record, "weight"); value != "" {
+			weight, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			if err != nil || weight < 0 {
+				return nil, fmt.Errorf("line %d: weight %q is not a non-negative number", line, value)
+			}
+			constituent.Weight = &weight
+		}
+		if value := field(record, "as_of_date"); value != "" {
+			date, err := parseMarketDate(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: as_of_date %q is not YYYY-MM-DD", line, value)
+			}
+			constituent.AsOfDate = date
+		}
+		constituents = append(constituents, constituent)
+	}
+
+	if len(constituents) == 0 {
+		return nil, errors.New("constituents CSV has no rows")
+	}
+	return constituents, nil
+}
+
+// ImportConstituents replaces indexName's constituents with the given list in
+// one transaction: listed stocks are upserted and stocks no longer listed are
+// removed. It returns how many rows were removed.
+func (s *SP500PriorityService) ImportConstituents(ctx context.Context, indexName string, constituents []IndexConstituent) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin constituents import: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, constituent := range constituents {
+		// CURRENT_TIMESTAMP is the transaction's start, the same for every row
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO index_constituents (index_name, symbol, priority, weight, as_of_date, updated_at)
+			VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+			ON CONFLICT (index_name, symbol) DO UPDATE SET
+				priority = EXCLUDED.priority,
+				weight = EXCLUDED.weight,
+				as_of_date = EXCLUDED.as_of_date,
+				updated_at = EXCLUDED.updated_at
+		`, indexName, constituent.Symbol, constituent.Priority, constituent.Weight, constituent.AsOfDate)
+		if err != nil {
+			return 0, fmt.Errorf("failed to import %s: %w", constituent.Symbol, err)
+		}
+	}
+
+	// Rows this import did not touch belong to stocks that have left the index
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM index_constituents WHERE index_name = $1 AND updated_at < CURRENT_TIMESTAMP
+	`, indexName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove former constituents: %w", err)
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit constituents import: %w", err)
+	}
+	return removed, nil
+}
+
+// constituents returns indexName's constituents in priority order, with the
+// company name and market cap of those in the stocks table
+func (s *SP500PriorityService) constituents(ctx context.Context, indexName string) ([]SP500Stock, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT ic.symbol, COALESCE(s.company_name, ''), ic.priority, COALESCE(s.market_cap, 0)
+		FROM index_constituents ic
+		LEFT JOIN stocks s ON s.symbol = ic.symbol
+		WHERE ic.index_name = $1
+		ORDER BY ic.priority, ic.symbol
+	`, indexName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s constituents: %w", indexName, err)
+	}
+	defer rows.Close()
+
+	var stocks []SP500Stock
+	for rows.Next() {
+		var stock SP500Stock
+		if err := rows.Scan(&stock.Symbol, &stock.CompanyName, &stock.Priority, &stock.MarketCap); err != nil {
+			return nil, fmt.Errorf("failed to scan %s constituent: %w", indexName, err)
+		}
+		stocks = append(stocks, stock)
+	}
+	return stocks, rows.Err()
+}
+
+// indexPriority returns symbol's priority in indexName, whether it is listed,
+// and whether the index has any constituents imported at all
+func (s *SP500PriorityService) indexPriority(indexName, symbol string) (priority int, listed, imported bool, err error) {
+	var stored sql.NullInt64
+	err = s.db.QueryRow(`
+		SELECT (SELECT priority FROM index_constituents WHERE index_name = $1 AND symbol = $2),
+		       EXISTS (SELECT 1 FROM index_constituents WHERE index_name = $1)
+	`, indexName, symbol).Scan(&stored, &imported)
+	if err != nil {
+		return 0, false, false, fmt.Errorf("failed to look up %s priority of %s: %w", indexName, symbol, err)
+	}
+	return int(stored.Int64), stored.Valid, imported, nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectConstituents expects the S&P 500 constituents to be loaded, listing
+// symbols in priority order; with none, callers use the built-in list
+func expectConstituents(mock sqlmock.Sqlmock, symbols ...string) {
+	rows := sqlmock.NewRows([]string{"symbol", "company_name", "priority", "market_cap"})
+	for i, symbol := range symbols {
+		rows.AddRow(symbol, symbol+" Inc.", i+1, int64(0))
+	}
+	mock.ExpectQuery("FROM index_constituents ic").WithArgs(IndexSP500).WillReturnRows(rows)
+}
+
+func TestParseConstituentsCSV(t *testing.T) {
+	asOf := time.Date(2026, time.March, 20, 0, 0, 0, 0, time.UTC)
+
+	constituents, err := ParseConstituentsCSV(strings.NewReader(
+		"Symbol, Weight, Priority, as_of_date\n"+
+			"msft, 6.9%, 2, 2026-03-21\n"+
+			"AAPL, 7.1, 1,\n"+
+			"brk-b, , 3,\n"), IndexSP500, asOf)
+	require.NoError(t, err)
+	require.Len(t, constituents, 3)
+
+	assert.Equal(t, "MSFT", constituents[0].Symbol)
+	assert.Equal(t, 2, constituents[0].Priority)
+	assert.Equal(t, 6.9, *constituents[0].Weight)
+	assert.Equal(t, time.Date(2026, time.March, 21, 0, 0, 0, 0, time.UTC), constituents[0].AsOfDate)
+
+	assert.Equal(t, asOf, constituents[1].AsOfDate, "an empty as_of_date takes the import date")
+	assert.Equal(t, "BRK.B", constituents[2].Symbol)
+	assert.Nil(t, constituents[2].Weight)
+	assert.Equal(t, IndexSP500, constituents[2].IndexName)
+}
+
+func TestParseConstituentsCSV_PriorityDefaultsToRowOrder(t *testing.T) {
+	constituents, err := ParseConstituentsCSV(strings.NewReader("symbol\nNVDA\nAAPL\n"), IndexSP500, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, constituents[0].Priority)
+	assert.Equal(t, 2, constituents[1].Priority)
+}
+
+func TestParseConstituentsCSV_Rejects(t *testing.T) {
+	tests := map[string]string{
+		"empty":            "",
+		"no symbol column": "ticker,weight\nAAPL,7\n",
+		"no rows":          "symbol,priority\n",
+		"bad symbol":       "symbol\nAA PL\n",
+		"duplicate":        "symbol\nAAPL\nMSFT\naapl\n",
+		"bad priority":     "symbol,priority\nAAPL,0\n",
+		"bad weight":       "symbol,weight\nAAPL,lots\n",
+		"bad date":         "symbol,as_of_date\nAAPL,20/03/2026\n",
+	}
+	for name, csv := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseConstituentsCSV(strings.NewReader(csv), IndexSP500, time.Now())
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestImportConstituents_UpsertsAndRemovesFormerMembers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	asOf := time.Date(2026, time.March, 20, 0, 0, 0, 0, time.UTC)
+	weight := 7.1
+	constituents := []IndexConstituent{
+		{IndexName: IndexSP500, Symbol: "AAPL", Priority: 1, Weight: &weight, AsOfDate: asOf},
+		{IndexName: IndexSP500, Symbol: "MSFT", Priority: 2, AsOfDate: asOf},
+	}
+
+	// Re-importing updates the rows already there; the one stock that left the index goes
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO index_constituents").WithArgs(IndexSP500, "AAPL", 1, &weight, asOf).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO index_constituents").WithArgs(IndexSP500, "MSFT", 2, nil, asOf).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM index_constituents WHERE index_name = \\$1 AND updated_at < CURRENT_TIMESTAMP").
+		WithArgs(IndexSP500).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	removed, err := NewSP500PriorityService(db).ImportConstituents(context.Background(), IndexSP500, constituents)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportConstituents_RollsBackOnFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO index_constituents").WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	_, err = NewSP500PriorityService(db).ImportConstituents(context.Background(), IndexSP500,
+		[]IndexConstituent{{IndexName: IndexSP500, Symbol: "AAPL", Priority: 1, AsOfDate: time.Now()}})
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStockPriority(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectPriority := func(symbol string, priority interface{}, imported bool) {
+		mock.ExpectQuery("SELECT \\(SELECT priority FROM index_constituents").WithArgs(IndexSP500, symbol).
+			WillReturnRows(sqlmock.NewRows([]string{"priority", "exists"}).AddRow(priority, imported))
+	}
+	expectPriority("PLTR", 87, true)
+	expectPriority("AAPL", nil, true)
+	expectPriority("MSFT", nil, false)
+
+	service := NewSP500PriorityService(db)
+	assert.Equal(t, 87, service.GetStockPriority("PLTR"))
+	assert.Equal(t, 999, service.GetStockPriority("AAPL"), "not in the imported index, whatever the built-in list says")
+	assert.Equal(t, 2, service.GetStockPriority("MSFT"), "the built-in list until an index is imported")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTop500SP500Stocks_FromTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectConstituents(mock, "NVDA", "MSFT")
+	expectConstituents(mock)
+
+	service := NewSP500PriorityService(db)
+	stocks := service.GetTop500SP500Stocks()
+	require.Len(t, stocks, 2)
+	assert.Equal(t, "NVDA", stocks[0].Symbol)
+	assert.Equal(t, 1, stocks[0].Priority)
+
+	assert.Equal(t, defaultSP500Stocks(), service.GetTop500SP500Stocks(), "an empty table falls back to the built-in list")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"os"
 	"testing"
@@ -87,17 +88,16 @@ func (suite *ServiceIntegrationTestSuite) setupTestData() {
 		industry      string
 		exchange      string
 		marketCap     int64
-		priceRange    string
 		currentPrice  float64
 		dailyChange   float64
 		changePercent float64
 		volume        int64
 	}{
-		{"AAPL", "Apple Inc.", "Technology", "Consumer Electronics", "NASDAQ", 3000000000000, "$150+", 150.25, 2.50, 1.69, 50000000},
-		{"MSFT", "Microsoft Corporation", "Technology", "Software", "NASDAQ", 2800000000000, "$300+", 320.15, -1.25, -0.39, 25000000},
-		{"GOOGL", "Alphabet Inc.", "Technology", "Internet Content", "NASDAQ", 1600000000000, "$100+", 125.50, 3.25, 2.66, 30000000},
-		{"TSLA", "Tesla Inc.", "Consumer Discretionary", "Auto Manufacturers", "NASDAQ", 800000000000, "$200+", 225.75, -5.50, -2.38, 75000000},
-		{"JPM", "JPMorgan Chase & Co.", "Financial Services", "Banks", "NYSE", 450000000000, "$100+", 145.80, 1.20, 0.83, 15000000},
+		{"AAPL", "Apple Inc.", "Technology", "Consumer Electronics", "NASDAQ", 3000000000000, 150.25, 2.50, 1.69, 50000000},
+		{"MSFT", "Microsoft Corporation", "Technology", "Software", "NASDAQ", 2800000000000, 320.15, -1.25, -0.39, 25000000},
+		{"GOOGL", "Alphabet Inc.", "Technology", "Internet Content", "NASDAQ", 1600000000000, 125.50, 3.25, 2.66, 30000000},
+		{"TSLA", "Tesla Inc.", "Consumer Discretionary", "Auto Manufacturers", "NASDAQ", 800000000000, 225.75, -5.50, -2.38, 75000000},
+		{"JPM", "JPMorgan Chase & Co.", "Financial Services", "Banks", "NYSE", 450000000000, 145.80, 1.20, 0.83, 15000000},
 	}
 
 	for _, stock := range testStocks {
@@ -107,7 +107,7 @@ func (suite *ServiceIntegrationTestSuite) setupTestData() {
 			 current_price, daily_change, change_percent, volume, created_at, updated_at, last_updated)
 			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
 			stock.symbol, stock.companyName, stock.sector, stock.industry, stock.exchange,
-			stock.marketCap, stock.priceRange, true, stock.currentPrice, stock.dailyChange,
+			stock.marketCap, DefaultPriceRanges().Bucket(stock.currentPrice), true, stock.currentPrice, stock.dailyChange,
 			stock.changePercent, stock.volume, time.Now(), time.Now(), time.Now())
 		
 		if err != nil {
@@ -130,7 +130,7 @@ func (suite *ServiceIntegrationTestSuite) cleanupTestData() {
 
 // TestGetAllStocks tests retrieving all stocks from database
 func (suite *ServiceIntegrationTestSuite) TestGetAllStocks() {
-	stocks := suite.stockService.GetAllStocks()
+	stocks := suite.stockService.GetAllStocks(context.Background())
 	
 	assert.GreaterOrEqual(suite.T(), len(stocks), 5, "Should return at least 5 test stocks")
 	
@@ -156,7 +156,7 @@ func (suite *ServiceIntegrationTestSuite) TestGetAllStocks() {
 // TestGetStockBySymbol tests retrieving individual stocks
 func (suite *ServiceIntegrationTestSuite) TestGetStockBySymbol() {
 	// Test existing stock
-	stock, err := suite.stockService.GetStockBySymbol("AAPL")
+	stock, err := suite.stockService.GetStockBySymbol(context.Background(), "AAPL")
 	assert.NoError(suite.T(), err)
 	assert.NotNil(suite.T(), stock)
 	assert.Equal(suite.T(), "AAPL", stock.Symbol)
@@ -165,7 +165,7 @@ func (suite *ServiceIntegrationTestSuite) TestGetStockBySymbol() {
 	assert.Equal(suite.T(), 150.25, stock.CurrentPrice)
 	
 	// Test non-existent stock
-	stock, err = suite.stockService.GetStockBySymbol("NONEXISTENT")
+	stock, err = suite.stockService.GetStockBySymbol(context.Background(), "NONEXISTENT")
 	assert.Error(suite.T(), err)
 	assert.Nil(suite.T(), stock)
 }
@@ -173,7 +173,7 @@ func (suite *ServiceIntegrationTestSuite) TestGetStockBySymbol() {
 // TestGetStocksBysector tests sector-based filtering
 func (suite *ServiceIntegrationTestSuite) TestGetStocksBySector() {
 	// Test Technology sector
-	techStocks := suite.stockService.GetStocksBySector("Technology")
+	techStocks := suite.stockService.GetStocksBySector(context.Background(), "Technology")
 	assert.GreaterOrEqual(suite.T(), len(techStocks), 3, "Should have at least 3 technology stocks")
 	
 	for _, stock := range techStocks {
@@ -181,7 +181,7 @@ func (suite *ServiceIntegrationTestSuite) TestGetStocksBySector() {
 	}
 	
 	// Test Financial Services sector
-	financialStocks := suite.stockService.GetStocksBySector("Financial Services")
+	financialStocks := suite.stockService.GetStocksBySector(context.Background(), "Financial Services")
 	assert.GreaterOrEqual(suite.T(), len(financialStocks), 1, "Should have at least 1 financial stock")
 	
 	for _, stock := range financialStocks {
@@ -189,26 +189,21 @@ func (suite *ServiceIntegrationTestSuite) TestGetStocksBySector() {
 	}
 	
 	// Test non-existent sector
-	nonExistentStocks := suite.stockService.GetStocksBySector("NonExistentSector")
+	nonExistentStocks := suite.stockService.GetStocksBySector(context.Background(), "NonExistentSector")
 	assert.Equal(suite.T(), 0, len(nonExistentStocks))
 }
 
 // TestGetStocksByPriceRangeMethod tests price range filtering
 func (suite *ServiceIntegrationTestSuite) TestGetStocksByPriceRangeMethod() {
-	// Test $150+ price range
-	expensiveStocks := suite.stockService.GetStocksByPriceRange("$150+")
-	assert.GreaterOrEqual(suite.T(), len(expensiveStocks), 0)
+	ranges := DefaultPriceRanges()
 	
-	for _, stock := range expensiveStocks {
-		assert.Equal(suite.T(), "$150+", stock.PriceRange)
-	}
-	
-	// Test $100+ price range
-	midRangeStocks := suite.stockService.GetStocksByPriceRange("$100+")
-	assert.GreaterOrEqual(suite.T(), len(midRangeStocks), 0)
-	
-	for _, stock := range midRangeStocks {
-		assert.Equal(suite.T(), "$100+", stock.PriceRange)
+	for _, label := range ranges.Labels() {
+		stocks := suite.stockService.GetStocksByPriceRange(context.Background(), label)
+		
+		for _, stock := range stocks {
+			assert.Equal(suite.T(), label, stock.PriceRange)
+			assert.Equal(suite.T(), label, ranges.Bucket(stock.CurrentPrice))
+		}
 	}
 }
 
@@ -234,14 +229,14 @@ func (suite *ServiceIntegrationTestSuite) TestConcurrentAccess() {
 			defer func() { done <- true }()
 			
 			// Perform various operations concurrently
-			stocks := suite.stockService.GetAllStocks()
+			stocks := suite.stockService.GetAllStocks(context.Background())
 			assert.GreaterOrEqual(suite.T(), len(stocks), 5)
 			
-			stock, err := suite.stockService.GetStockBySymbol("AAPL")
+			stock, err := suite.stockService.GetStockBySymbol(context.Background(), "AAPL")
 			assert.NoError(suite.T(), err)
 			assert.NotNil(suite.T(), stock)
 			
-			stocks = suite.stockService.GetAllStocks()
+			stocks = suite.stockService.GetAllStocks(context.Background())
 			assert.GreaterOrEqual(suite.T(), len(stocks), 5)
 		}()
 	}
@@ -255,12 +250,12 @@ func (suite *ServiceIntegrationTestSuite) TestConcurrentAccess() {
 // TestDataValidation tests that service validates data properly
 func (suite *ServiceIntegrationTestSuite) TestDataValidation() {
 	// Test with empty symbol
-	stock, err := suite.stockService.GetStockBySymbol("")
+	stock, err := suite.stockService.GetStockBySymbol(context.Background(), "")
 	assert.Error(suite.T(), err)
 	assert.Nil(suite.T(), stock)
 	
 	// Test with whitespace symbol
-	stock, err = suite.stockService.GetStockBySymbol("   ")
+	stock, err = suite.stockService.GetStockBySymbol(context.Background(), "   ")
 	assert.Error(suite.T(), err)
 	assert.Nil(suite.T(), stock)
 }
@@ -269,7 +264,7 @@ func (suite *ServiceIntegrationTestSuite) TestDataValidation() {
 func (suite *ServiceIntegrationTestSuite) TestPerformance() {
 	// Measure time for GetAllStocks
 	start := time.Now()
-	stocks := suite.stockService.GetAllStocks()
+	stocks := suite.stockService.GetAllStocks(context.Background())
 	duration := time.Since(start)
 	
 	assert.GreaterOrEqual(suite.T(), len(stocks), 5)
@@ -277,7 +272,7 @@ func (suite *ServiceIntegrationTestSuite) TestPerformance() {
 	
 	// Measure time for GetStockBySymbol
 	start = time.Now()
-	stock, err := suite.stockService.GetStockBySymbol("AAPL")
+	stock, err := suite.stockService.GetStockBySymbol(context.Background(), "AAPL")
 	duration = time.Since(start)
 	
 	assert.NoError(suite.T(), err)
@@ -286,7 +281,7 @@ func (suite *ServiceIntegrationTestSuite) TestPerformance() {
 	
 	// Measure time for GetStocksBySector
 	start = time.Now()
-	techStocks := suite.stockService.GetStocksBySector("Technology")
+	techStocks := suite.stockService.GetStocksBySector(context.Background(), "Technology")
 	duration = time.Since(start)
 	
 	assert.GreaterOrEqual(suite.T(), len(techStocks), 3)
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	// Embedded so America/New_York resolves on hosts without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// Default sync window: from shortly after the 16:00 ET close until 23:00 ET,
+// on trading days only
+const (
+	DefaultSyncWindowStart = 16*time.Hour + 30*time.Minute
+	DefaultSyncWindowEnd   = 23 * time.Hour
+)
+
+// Regular NYSE session, in Eastern time
+const (
+	marketOpen  = 9*time.Hour + 30*time.Minute
+	marketClose = 16 * time.Hour
+)
+
+// marketLocation is US Eastern time, in which the NYSE trades and providers
+// give trading dates
+var marketLocation = func() *time.Location {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		// Unreachable with the embedded zoneinfo
+		panic(fmt.Sprintf("load America/New_York: %v", err))
+	}
+	return location
+}()
+
+// marketDate returns the Eastern-time date at now as midnight UTC, the form a
+// DATE column reads back as. Between midnight UTC and midnight Eastern it is
+// still the previous day.
+func marketDate(now time.Time) time.Time {
+	local := now.In(marketLocation)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// marketHour returns the hour of the day at now in Eastern time
+func marketHour(now time.Time) int {
+	return now.In(marketLocation).Hour()
+}
+
+// parseMarketDate parses a YYYY-MM-DD trading date as midnight UTC, like
+// marketDate, so it is stored as the same DATE whatever the server's zone
+func parseMarketDate(value string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", value, time.UTC)
+}
+
+// nyseHolidays are the full-day NYSE closures known in advance. Closures
+// announced later, or years past the list, go in the market_holidays table.
+var nyseHolidays = []string{
+	"2024-01-01", "2024-01-15", "2024-02-19", "2024-03-29", "2024-05-27", "2024-06-19",
+	"2024-07-04", "2024-09-02", "2024-11-28", "2024-12-25",
+	"2025-01-01", "2025-01-09", "2025-01-20", "2025-02-17", "2025-04-18", "2025-05-26",
+	"2025-06-19", "2025-07-04", "2025-09-01", "2025-11-27", "2025-12-25",
+	"2026-01-01", "2026-01-19", "2026-02-16", "2026-04-03", "2026-05-25", "2026-06-19",
+	"2026-07-03", "2026-09-07", "2026-11-26", "2026-12-25",
+	"2027-01-01", "2027-01-18", "2027-02-15", "2027-03-26", "2027-05-31", "2027-06-18",
+	"2027-07-05", "2027-09-06", "2027-11-25", "2027-12-24",
+}
+
+// MarketCalendar knows US market hours in Eastern time, which days the NYSE
+// trades, and the part of each trading day the scheduler syncs in
+type MarketCalendar struct {
+	location  *time.Location
+	holidays  map[string]bool
+	syncStart time.Duration
+	syncEnd   time.Duration
+}
+
+// NewMarketCalendar returns a calendar with the built-in NYSE holidays and the default sync window
+func NewMarketCalendar() *MarketCalendar {
+	holidays := make(map[string]bool, len(nyseHolidays))
+	for _, day := range nyseHolidays {
+		holidays[day] = true
+	}
+
+	return &MarketCalendar{
+		location:  marketLocation,
+		holidays:  holidays,
+		syncStart: DefaultSyncWindowStart,
+		syncEnd:   DefaultSyncWindowEnd,
+	}
+}
+
+// LoadHolidays adds the closures listed in the market_holidays table
+func (c *MarketCalendar) LoadHolidays(ctx context.Context, db *sql.DB) (int, error) {
+	rows, err := db.QueryContext(ctx, "SELECT date FROM market_holidays")
+	if err != nil {
+		return 0, fmt.Errorf("failed to load market holidays: %w", err)
+	}
+	defer rows.Close()
+
+	loaded := 0
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			return loaded, fmt.Errorf("failed to scan market holiday: %w", err)
+		}
+		c.holidays[date.Format("2006-01-02")] = true
+		loaded++
+	}
+	return loaded, rows.Err()
+}
+
+// SetSyncWindow sets the span of each trading day, as offsets from midnight
+// Eastern time, in which scheduled syncs run. Both ends are inclusive.
+func (c *MarketCalendar) SetSyncWindow(start, end time.Duration) error {
+	if start < 0 || end > 24*time.Hour || start >= end {
+		return fmt.Errorf("invalid sync window %s-%s", formatClock(start), formatClock(end))
+	}
+	c.syncStart = start
+	c.syncEnd = end
+	return nil
+}
+
+// ParseSyncWindow parses an Eastern-time window such as "16:30-23:00"
+func ParseSyncWindow(value string) (start, end time.Duration, err error) {
+	from, to, found := strings.Cut(value, "-")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid sync window %q: want HH:MM-HH:MM", value)
+	}
+	if start, err = parseClock(strings.TrimSpace(from)); err != nil {
+		return 0, 0, fmt.Errorf("invalid sync window %q: %w", value, err)
+	}
+	if end, err = parseClock(strings.TrimSpace(to)); err != nil {
+		return 0, 0, fmt.Errorf("invalid sync window %q: %w", value, err)
+	}
+	return start, end, nil
+}
+
+// parseClock parses HH:MM into an offset from midnight; 24:00 is allowed as the end of the day
+func parseClock(value string) (time.Duration, error) {
+	if value == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func formatClock(offset time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(offset.Hours()), int(offset.Minutes())%60)
+}
+
+// IsTradingDay reports whether the NYSE trades on t's date in Eastern time
+func (c *MarketCalendar) IsTradingDay(t time.Time) bool {
+	local := t.In(c.location)
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		return false
+	}
+	return !c.holidays[local.Format("2006-01-02")]
+}
+
+// isTradingDate reports whether the NYSE trades on the calendar date of day,
+// taken as is rather than converted to Eastern time, as for stored price dates
+func (c *MarketCalendar) isTradingDate(day time.Time) bool {
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+	return !c.holidays[day.Format("2006-01-02")]
+}
+
+// TradingDaysSince counts the trading dates after the calendar date of day
+// and before now's Eastern-time date, that is the completed sessions since day
+func (c *MarketCalendar) TradingDaysSince(day, now time.Time) int {
+	today := marketDate(now)
+	count := 0
+	for d := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1); d.Before(today); d = d.AddDate(0, 0, 1) {
+		if c.isTradingDate(d) {
+			count++
+		}
+	}
+	return count
+}
+
+// holidaysKnownFrom is January 1 of the first year the calendar lists holidays
+// for; before it a missing weekday cannot be told apart from a closure
+func (c *MarketCalendar) holidaysKnownFrom() time.Time {
+	earliest := ""
+	for day := range c.holidays {
+		if earliest == "" || day < earliest {
+			earliest = day
+		}
+	}
+	from, err := parseMarketDate(earliest)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Date(from.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// IsMarketOpen reports whether t falls in the regular 9:30-16:00 ET session
+func (c *MarketCalendar) IsMarketOpen(t time.Time) bool {
+	offset := c.sinceMidnight(t)
+	return c.IsTradingDay(t) && offset >= marketOpen && offset < marketClose
+}
+
+// InSyncWindow reports whether a scheduled sync may run at t
+func (c *MarketCalendar) InSyncWindow(t time.Time) bool {
+	offset := c.sinceMidnight(t)
+	return c.IsTradingDay(t) && offset >= c.syncStart && offset <= c.syncEnd
+}
+
+// NextSyncRun returns the first hourly run after t that falls in the sync
+// window, or the zero time if none does in the next two weeks
+func (c *MarketCalendar) NextSyncRun(t time.Time) time.Time {
+	// Eastern time is a whole number of hours from UTC, so hours line up
+	run := t.Truncate(time.Hour).Add(time.Hour)
+	for i := 0; i < 14*24; i++ {
+		if c.InSyncWindow(run) {
+			return run
+		}
+		run = run.Add(time.Hour)
+	}
+	return time.Time{}
+}
+
+// sinceMidnight is how far into its Eastern-time day t falls, by the wall clock
+func (c *MarketCalendar) sinceMidnight(t time.Time) time.Duration {
+	local := t.In(c.location)
+	return time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second
+}
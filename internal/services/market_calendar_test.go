@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eastern returns a wall-clock time in New York
+func eastern(t *testing.T, year int, month time.Month, day, hour, minute int) time.Time {
+	location, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	return time.Date(year, month, day, hour, minute, 0, 0, location)
+}
+
+func TestMarketCalendar_Saturday(t *testing.T) {
+	calendar := NewMarketCalendar()
+	saturday := eastern(t, 2026, time.March, 14, 18, 0)
+
+	assert.False(t, calendar.IsTradingDay(saturday))
+	assert.False(t, calendar.IsMarketOpen(eastern(t, 2026, time.March, 14, 11, 0)))
+	assert.False(t, calendar.InSyncWindow(saturday))
+
+	// Nothing runs until Monday's first hour in the window
+	assert.True(t, eastern(t, 2026, time.March, 16, 17, 0).Equal(calendar.NextSyncRun(saturday)))
+}
+
+func TestMarketCalendar_Holiday(t *testing.T) {
+	calendar := NewMarketCalendar()
+
+	// Good Friday
+	goodFriday := eastern(t, 2026, time.April, 3, 18, 0)
+	assert.False(t, calendar.IsTradingDay(goodFriday))
+	assert.False(t, calendar.InSyncWindow(goodFriday))
+	assert.True(t, eastern(t, 2026, time.April, 6, 17, 0).Equal(calendar.NextSyncRun(goodFriday)))
+
+	// The day before is a normal trading day
+	thursday := eastern(t, 2026, time.April, 2, 18, 0)
+	assert.True(t, calendar.IsTradingDay(thursday))
+	assert.True(t, calendar.InSyncWindow(thursday))
+}
+
+func TestMarketCalendar_TradingDaysSince(t *testing.T) {
+	calendar := NewMarketCalendar()
+	thursday := time.Date(2026, time.April, 2, 0, 0, 0, 0, time.UTC)
+
+	// Good Friday and the weekend are skipped, and today's session is not complete
+	assert.Equal(t, 0, calendar.TradingDaysSince(thursday, eastern(t, 2026, time.April, 6, 23, 0)))
+	assert.Equal(t, 1, calendar.TradingDaysSince(thursday, eastern(t, 2026, time.April, 7, 10, 0)))
+	assert.Equal(t, 4, calendar.TradingDaysSince(thursday, eastern(t, 2026, time.April, 10, 10, 0)))
+	assert.Equal(t, 0, calendar.TradingDaysSince(thursday, eastern(t, 2026, time.April, 2, 18, 0)))
+}
+
+func TestMarketDate_AroundMidnight(t *testing.T) {
+	march10 := time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC)
+	march11 := time.Date(2026, time.March, 11, 0, 0, 0, 0, time.UTC)
+
+	// Midnight UTC is 20:00 in New York (EDT); the market date holds until midnight there
+	assert.Equal(t, march10, marketDate(time.Date(2026, time.March, 10, 23, 59, 0, 0, time.UTC)))
+	assert.Equal(t, march10, marketDate(time.Date(2026, time.March, 11, 0, 30, 0, 0, time.UTC)))
+	assert.Equal(t, march10, marketDate(time.Date(2026, time.March, 11, 3, 59, 59, 0, time.UTC)))
+	assert.Equal(t, march11, marketDate(time.Date(2026, time.March, 11, 4, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 23, marketHour(time.Date(2026, time.March, 11, 3, 59, 59, 0, time.UTC)))
+
+	// The server's zone makes no difference
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	assert.Equal(t, march10, marketDate(time.Date(2026, time.March, 11, 9, 0, 0, 0, tokyo)))
+}
+
+func TestParseMarketDate(t *testing.T) {
+	date, err := parseMarketDate("2026-03-10")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC), date)
+	assert.Equal(t, date, marketDate(eastern(t, 2026, time.March, 10, 23, 0)))
+
+	_, err = parseMarketDate("03/10/2026")
+	assert.Error(t, err)
+}
+
+func TestMarketCalendar_LoadHolidays(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT date FROM market_holidays").
+		WillReturnRows(sqlmock.NewRows([]string{"date"}).AddRow(time.Date(2028, time.January, 17, 0, 0, 0, 0, time.UTC)))
+
+	calendar := NewMarketCalendar()
+	monday := eastern(t, 2028, time.January, 17, 18, 0)
+	assert.True(t, calendar.IsTradingDay(monday))
+
+	loaded, err := calendar.LoadHolidays(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, 1, loaded)
+	assert.False(t, calendar.IsTradingDay(monday))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarketCalendar_DSTTransition(t *testing.T) {
+	calendar := NewMarketCalendar()
+
+	// Clocks go forward on Sunday 8 March 2026: 21:15 UTC is 16:15 EST on
+	// the Friday before, outside the window, but 17:15 EDT on the Monday after
+	assert.False(t, calendar.InSyncWindow(time.Date(2026, time.March, 6, 21, 15, 0, 0, time.UTC)))
+	assert.True(t, calendar.InSyncWindow(time.Date(2026, time.March, 9, 21, 15, 0, 0, time.UTC)))
+
+	// The window follows the wall clock across the change: the first run on
+	// Friday is 22:00 UTC, on Monday 21:00 UTC
+	assert.Equal(t, time.Date(2026, time.March, 6, 22, 0, 0, 0, time.UTC),
+		calendar.NextSyncRun(time.Date(2026, time.March, 6, 12, 0, 0, 0, time.UTC)).UTC())
+	assert.Equal(t, time.Date(2026, time.March, 9, 21, 0, 0, 0, time.UTC),
+		calendar.NextSyncRun(time.Date(2026, time.March, 8, 12, 0, 0, 0, time.UTC)).UTC())
+
+	// The market opens at 9:30 local time on both sides of the change
+	assert.True(t, calendar.IsMarketOpen(time.Date(2026, time.March, 6, 14, 30, 0, 0, time.UTC)))
+	assert.False(t, calendar.IsMarketOpen(time.Date(2026, time.March, 9, 13, 15, 0, 0, time.UTC)))
+	assert.True(t, calendar.IsMarketOpen(time.Date(2026, time.March, 9, 13, 30, 0, 0, time.UTC)))
+}
+
+func TestMarketCalendar_SyncWindow(t *testing.T) {
+	calendar := NewMarketCalendar()
+	tuesday := func(hour, minute int) time.Time { return eastern(t, 2026, time.March, 10, hour, minute) }
+
+	assert.False(t, calendar.InSyncWindow(tuesday(16, 0)))
+	assert.True(t, calendar.InSyncWindow(tuesday(16, 30)))
+	assert.True(t, calendar.InSyncWindow(tuesday(23, 0)))
+	assert.False(t, calendar.InSyncWindow(tuesday(23, 1)))
+
+	start, end, err := ParseSyncWindow("06:00-24:00")
+	require.NoError(t, err)
+	require.NoError(t, calendar.SetSyncWindow(start, end))
+	assert.True(t, calendar.InSyncWindow(tuesday(7, 0)))
+	assert.False(t, calendar.InSyncWindow(tuesday(5, 0)))
+
+	for _, value := range []string{"", "16:30", "4pm-11pm", "25:00-26:00"} {
+		_, _, err := ParseSyncWindow(value)
+		assert.Error(t, err, value)
+	}
+	assert.Error(t, calendar.SetSyncWindow(23*time.Hour, 16*time.Hour))
+}
+
+func TestSyncStockDataJob_SkipsOutsideSyncWindow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	provider := &budgetedSyncProvider{allowed: 25}
+	scheduler := NewSchedulerService(db, provider, nil, nil, nil)
+	scheduler.now = func() time.Time { return eastern(t, 2026, time.March, 14, 18, 0) }
+	scheduler.syncStockDataJob()
+
+	// No stock was picked and no call spent
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Zero(t, provider.fetches)
+}
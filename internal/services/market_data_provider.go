@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// Market data provider names accepted by MARKET_DATA_PROVIDER. Each is also the
+// service_name of the provider's api_rate_limits and api_calls rows.
+const (
+	ProviderAlphaVantage = "alphavantage"
+	ProviderStooq        = "stooq"
+	// ProviderSandbox serves synthetic prices offline, for development and end-to-end tests
+	ProviderSandbox = "sandbox"
+)
+
+// MarketDataProvider fetches prices from an external source and tracks the
+// budget spent doing so. Syncs depend on this rather than a concrete client so
+// the source can be switched without touching them.
+type MarketDataProvider interface {
+	// Name is the provider's service name, e.g. "alphavantage"
+	Name() string
+
+	// FetchDailyData returns the full daily history for symbol. The initiator
+	// is recorded with the API call so budget usage can be attributed; ctx
+	// cancels the request and carries the request ID into log records.
+	FetchDailyData(ctx context.Context, symbol string, initiator Initiator) (*DailySeries, error)
+	// FetchQuote returns the latest trading day's prices for symbol
+	FetchQuote(symbol string, initiator Initiator) (*Quote, error)
+	// SaveHistoricalData upserts a fetched series into daily_prices, one writer
+	// per symbol at a time; mode decides whether to wait or return ErrSyncInProgress
+	SaveHistoricalData(ctx context.Context, symbol string, data *DailySeries, mode SyncLockMode) (SaveStats, error)
+
+	CanMakeRequest() (bool, error)
+	GetRateLimit() (*models.APIRateLimit, error)
+	GetAPIBudget() (*APIBudget, error)
+	GetAPICallStats(days int) ([]models.APICallStats, error)
+	GetAPICallStatsByInitiator(days int) ([]models.APICallInitiatorStats, error)
+	// ListAPICalls returns a page of logged calls, newest first, with the total matching filter
+	ListAPICalls(ctx context.Context, filter APICallFilter) ([]APICallRecord, int, error)
+
+	SetCallsPerMinute(callsPerMinute int)
+	SetRetryPolicy(maxAttempts int, baseDelay, maxDelay time.Duration)
+	SetSyncLockTimeout(timeout time.Duration)
+	SetMaxStoredBodyBytes(maxBytes int)
+	SetLogger(logger *slog.Logger)
+}
+
+// DailyBar is one trading day of prices
+type DailyBar struct {
+	Date   time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume int64
+
+	// AdjustedClose is the split- and dividend-adjusted close, zero when the
+	// series is unadjusted
+	AdjustedClose float64
+	// SplitCoefficient is the day's split ratio, e.g. 4 for a 4:1 split; zero
+	// or one when no split took effect
+	SplitCoefficient float64
+	// Dividend is the cash dividend paid per share on the day
+	Dividend float64
+}
+
+// DailySeries is a symbol's daily price history, in no particular order
+type DailySeries struct {
+	Symbol string
+	Bars   []DailyBar
+	// Rejected are the provider's rows that could not be parsed into bars
+	Rejected []RejectedRow
+	// Provisional marks a series built from an intra-day quote, whose day a
+	// later full sync must store again once the session has closed
+	Provisional bool
+}
+
+// reject records a row left out of the series; err is a *RejectedRow
+func (s *DailySeries) reject(err error) {
+	var rejected *RejectedRow
+	if !errors.As(err, &rejected) {
+		rejected = &RejectedRow{Reason: err.Error()}
+	}
+	slog.Warn("Rejected price row", "symbol", s.Symbol, "date", rejected.Date, "field", rejected.Field, "value", rejected.Value, "reason", rejected.Reason)
+	s.Rejected = append(s.Rejected, *rejected)
+}
+
+// Quote is the latest trading day's prices for a symbol. During the session
+// Close is the latest price rather than the final one.
+type Quote struct {
+	Symbol string
+	DailyBar
+	// PreviousClose, Change and ChangePercent are zero for providers that do
+	// not report them
+	PreviousClose float64
+	Change        float64
+	ChangePercent float64
+}
+
+// NewMarketDataProvider returns the provider called name, defaulting to Alpha
+// Vantage when name is empty. apiKey is ignored by providers that need none.
+func NewMarketDataProvider(name, apiKey string, db *sql.DB) (MarketDataProvider, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", ProviderAlphaVantage:
+		return NewAlphaVantageClient(apiKey, db), nil
+	case ProviderStooq:
+		return NewStooqClient(db), nil
+	case ProviderSandbox:
+		return NewSandboxClient(db), nil
+	}
+	return nil, fmt.Errorf("unknown market data provider %q (expected %s, %s or %s)", name, ProviderAlphaVantage, ProviderStooq, ProviderSandbox)
+}
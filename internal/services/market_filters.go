@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"stock-intelligence-backend/internal/cache"
+)
+
+// MarketFilters are the values the stock list can be filtered by that the
+// active stocks actually have
+type MarketFilters struct {
+	Sectors     []string `json:"sectors"`
+	PriceRanges []string `json:"price_ranges"`
+	Exchanges   []string `json:"exchanges"`
+}
+
+// HasSector reports whether some active stock is in sector
+func (f *MarketFilters) HasSector(sector string) bool {
+	return slices.Contains(f.Sectors, sector)
+}
+
+// GetMarketFilters returns the distinct sectors, price ranges and exchanges
+// of the active stocks, found with one GROUP BY query. Price ranges are
+// bucketed by the latest close as in Resolve and listed cheapest first.
+// The result is cached until the next invalidation, so stocks added or
+// moved between sectors are picked up when their sync clears the cache.
+func (d *DatabaseStockService) GetMarketFilters(ctx context.Context) (*MarketFilters, error) {
+	filters, err := loadShared(ctx, d, cache.MarketFiltersKey, 55*time.Minute, func(ctx context.Context) (MarketFilters, bool, error) {
+		filters, err := d.fetchMarketFilters(ctx)
+		return filters, err == nil && len(filters.Sectors) > 0, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &filters, nil
+}
+
+// fetchMarketFilters runs GetMarketFilters' query
+func (d *DatabaseStockService) fetchMarketFilters(ctx context.Context) (MarketFilters, error) {
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT s.sector, s.exchange,
+		       CASE WHEN latest.close_price > 0 THEN ` + d.priceRanges.SQLCase("latest.close_price") + ` ELSE s.price_range END
+		FROM stocks s
+		LEFT JOIN LATERAL (
+		    SELECT close_price
+		    FROM daily_prices
+		    WHERE stock_id = s.id
+		    ORDER BY date DESC
+		    LIMIT 1
+		) latest ON true
+		WHERE s.is_active = true
+		GROUP BY 1, 2, 3
+	`
+	rows, err := d.router.Reader().QueryContext(ctx, query)
+	if err != nil {
+		return MarketFilters{}, fmt.Errorf("failed to load market filters: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+
+	sectors, priceRanges, exchanges := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for rows.Next() {
+		var sector, exchange, priceRange sql.NullString
+		if err := rows.Scan(&sector, &exchange, &priceRange); err != nil {
+			return MarketFilters{}, fmt.Errorf("failed to scan market filters: %w", err)
+		}
+		if sector.String != "" {
+			sectors[sector.String] = true
+		}
+		if exchange.String != "" {
+			exchanges[exchange.String] = true
+		}
+		if priceRange.String != "" {
+			priceRanges[priceRange.String] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return MarketFilters{}, fmt.Errorf("failed to read market filters: %w", queryError(ctx, err))
+	}
+
+	filters := MarketFilters{
+		Sectors:     slices.Sorted(maps.Keys(sectors)),
+		PriceRanges: slices.Sorted(maps.Keys(priceRanges)),
+		Exchanges:   slices.Sorted(maps.Keys(exchanges)),
+	}
+	// Buckets read best cheapest first; legacy labels no bucket has go last
+	order := d.priceRanges.Labels()
+	slices.SortStableFunc(filters.PriceRanges, func(a, b string) int {
+		i, j := slices.Index(order, a), slices.Index(order, b)
+		if i < 0 {
+			i = len(order)
+		}
+		if j < 0 {
+			j = len(order)
+		}
+		return i - j
+	})
+	return filters, nil
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// UnknownSector is the heatmap bucket of stocks without a market cap or a sector
+const UnknownSector = "Unknown"
+
+// HeatmapTile is one stock of the market heatmap
+type HeatmapTile struct {
+	Symbol        string  `json:"symbol"`
+	MarketCap     *int64  `json:"market_cap"`
+	ChangePercent float64 `json:"change_percent"`
+}
+
+// HeatmapSector is one sector of the market heatmap: its stocks, largest
+// first, with their total market cap and average change
+type HeatmapSector struct {
+	Sector         string        `json:"-"`
+	TotalMarketCap int64         `json:"total_market_cap"`
+	AvgChange      float64       `json:"avg_change"`
+	Stocks         []HeatmapTile `json:"stocks"`
+}
+
+// MarketHeatmap is the active stocks grouped by sector, largest sector first.
+// It is a JSON object keyed by sector whose keys keep that order.
+type MarketHeatmap []HeatmapSector
+
+// MarshalJSON writes the heatmap as an object keyed by sector, in order
+func (m MarketHeatmap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, sector := range m {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(sector.Sector)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(sector)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// UnmarshalJSON reads a heatmap written by MarshalJSON, keeping its sector order
+func (m *MarketHeatmap) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return fmt.Errorf("market heatmap must be a JSON object")
+	}
+	heatmap := MarketHeatmap{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		sector := HeatmapSector{Sector: token.(string)}
+		if err := decoder.Decode(&sector); err != nil {
+			return err
+		}
+		heatmap = append(heatmap, sector)
+	}
+	*m = heatmap
+	return nil
+}
+
+// GetMarketHeatmap groups the active stocks by sector with their market cap
+// and change, in one query. Stocks without a market cap, which a treemap
+// cannot size, and stocks without a sector are put in the UnknownSector
+// bucket. Stocks beyond the change percent guard are left out, as they are
+// from the market overview.
+func (d *DatabaseStockService) GetMarketHeatmap(ctx context.Context) (MarketHeatmap, error) {
+	if d.cache != nil {
+		var cached MarketHeatmap
+		if err := d.cache.GetMarketHeatmap(ctx, &cached); err == nil {
+			return cached, nil
+		}
+	}
+
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+
+	with, args := d.rankableChanges()
+	query := with + `,
+		tiles AS (
+			SELECT CASE WHEN market_cap IS NULL OR COALESCE(sector, '') = '' THEN '` + UnknownSector + `' ELSE sector END AS bucket,
+			       symbol, market_cap, change_percent
+			FROM ranked
+		)
+		SELECT bucket, COALESCE(SUM(market_cap) OVER bucket, 0)::bigint AS total_market_cap,
+		       AVG(change_percent) OVER bucket AS avg_change,
+		       symbol, market_cap, change_percent
+		FROM tiles
+		WINDOW bucket AS (PARTITION BY bucket)
+		ORDER BY total_market_cap DESC, bucket, market_cap DESC NULLS LAST, symbol
+	`
+	rows, err := d.router.Reader().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute market heatmap: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+
+	heatmap := MarketHeatmap{}
+	for rows.Next() {
+		var sector HeatmapSector
+		var tile HeatmapTile
+		var marketCap sql.NullInt64
+		if err := rows.Scan(&sector.Sector, &sector.TotalMarketCap, &sector.AvgChange, &tile.Symbol, &marketCap, &tile.ChangePercent); err != nil {
+			return nil, fmt.Errorf("failed to scan heatmap tile: %w", err)
+		}
+		if marketCap.Valid {
+			tile.MarketCap = &marketCap.Int64
+		}
+		sector.AvgChange = RoundPercent(sector.AvgChange)
+		tile.ChangePercent = RoundPercent(tile.ChangePercent)
+		// Rows arrive grouped by sector
+		if n := len(heatmap); n == 0 || heatmap[n-1].Sector != sector.Sector {
+			sector.Stocks = []HeatmapTile{}
+			heatmap = append(heatmap, sector)
+		}
+		last := &heatmap[len(heatmap)-1]
+		last.Stocks = append(last.Stocks, tile)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read market heatmap: %w", queryError(ctx, err))
+	}
+
+	if d.cache != nil {
+		if err := d.cache.SetMarketHeatmap(ctx, heatmap, MarketAggregateTTL); err != nil {
+			slog.WarnContext(ctx, "Failed to cache market heatmap", "error", err)
+		}
+	}
+
+	return heatmap, nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var heatmapColumns = []string{"bucket", "total_market_cap", "avg_change", "symbol", "market_cap", "change_percent"}
+
+// heatmapRows is the heatmap query's result: two sectors, largest first, and
+// a stock without a market cap in the Unknown bucket
+func heatmapRows() *sqlmock.Rows {
+	return sqlmock.NewRows(heatmapColumns).
+		AddRow("Technology", int64(5000000000000), 1.5, "AAPL", int64(3000000000000), 2.0).
+		AddRow("Technology", int64(5000000000000), 1.5, "MSFT", int64(2000000000000), 1.0).
+		AddRow("Energy", int64(400000000000), -0.5, "XOM", int64(400000000000), -0.5).
+		AddRow(UnknownSector, int64(0), 3.0, "NEWCO", nil, 3.0)
+}
+
+func TestGetMarketHeatmap_GroupsSectorsAndCaches(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+
+	mock.ExpectQuery(`CASE WHEN market_cap IS NULL OR COALESCE\(sector, ''\) = '' THEN 'Unknown' ELSE sector END`).
+		WithArgs(DefaultMaxChangePercent).WillReturnRows(heatmapRows())
+
+	heatmap, err := service.GetMarketHeatmap(context.Background())
+	require.NoError(t, err)
+	require.Len(t, heatmap, 3)
+	assert.Equal(t, "Technology", heatmap[0].Sector)
+	assert.Equal(t, int64(5000000000000), heatmap[0].TotalMarketCap)
+	require.Len(t, heatmap[0].Stocks, 2)
+	assert.Equal(t, "MSFT", heatmap[0].Stocks[1].Symbol)
+	assert.Equal(t, UnknownSector, heatmap[2].Sector)
+	assert.Nil(t, heatmap[2].Stocks[0].MarketCap, "kept rather than dropped")
+
+	// Served from market:heatmap without touching the database, in the same order
+	cached, err := service.GetMarketHeatmap(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, heatmap, cached)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMarketHeatmap_RoundsChanges(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	service := NewDatabaseStockService(db, nil)
+
+	// Averaged and computed in the database to full precision
+	mock.ExpectQuery(`FROM tiles`).WithArgs(DefaultMaxChangePercent).
+		WillReturnRows(sqlmock.NewRows(heatmapColumns).
+			AddRow("Technology", int64(5000000000000), 1.3333333333, "AAPL", int64(3000000000000), 2.0049999).
+			AddRow("Technology", int64(5000000000000), 1.3333333333, "MSFT", int64(2000000000000), 0.6616666))
+
+	heatmap, err := service.GetMarketHeatmap(context.Background())
+	require.NoError(t, err)
+	require.Len(t, heatmap, 1)
+	assert.Equal(t, 1.33, heatmap[0].AvgChange)
+	assert.Equal(t, 2.0, heatmap[0].Stocks[0].ChangePercent)
+	assert.Equal(t, 0.66, heatmap[0].Stocks[1].ChangePercent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarketHeatmap_JSONKeepsSectorOrder(t *testing.T) {
+	heatmap := MarketHeatmap{
+		{Sector: "Technology", TotalMarketCap: 5, Stocks: []HeatmapTile{{Symbol: "AAPL"}}},
+		{Sector: "Energy", TotalMarketCap: 4, Stocks: []HeatmapTile{{Symbol: "XOM"}}},
+	}
+
+	data, err := json.Marshal(heatmap)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"Technology": {"total_market_cap": 5, "avg_change": 0, "stocks": [{"symbol": "AAPL", "market_cap": null, "change_percent": 0}]},
+		"Energy": {"total_market_cap": 4, "avg_change": 0, "stocks": [{"symbol": "XOM", "market_cap": null, "change_percent": 0}]}
+	}`, string(data))
+	assert.True(t, strings.HasPrefix(string(data), `{"Technology":`), "the largest sector is written first")
+
+	var decoded MarketHeatmap
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, heatmap, decoded)
+}
+
+func TestGetMarketHeatmap_InvalidatedBySync(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+	mock.ExpectQuery("FROM tiles").WithArgs(DefaultMaxChangePercent).WillReturnRows(heatmapRows())
+	_, err := service.GetMarketHeatmap(context.Background())
+	require.NoError(t, err)
+
+	// A sync with new prices drops every cached aggregate, the heatmap included
+	mock.ExpectExec("UPDATE stocks SET updated_at").WithArgs("AAPL").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	provider := &stubSyncProvider{data: &DailySeries{Symbol: "AAPL", Bars: []DailyBar{{}}}}
+	scheduler := NewSchedulerService(service.db, provider, service.GetCache(), nil, nil)
+	require.NoError(t, scheduler.runManualSync(context.Background(), "AAPL", SyncModeFull))
+
+	mock.ExpectQuery("FROM tiles").WithArgs(DefaultMaxChangePercent).WillReturnRows(heatmapRows())
+	_, err = service.GetMarketHeatmap(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// MoverWindows are the lookback windows market movers are ranked over
+var MoverWindows = []string{"1d", "7d", "30d", "90d"}
+
+// moverWindowDays is each window's length in calendar days
+var moverWindowDays = map[string]int{"1d": 1, "7d": 7, "30d": 30, "90d": 90}
+
+// moverDateTolerance is how many calendar days before a window's end or start
+// the closest stored close may be. It spans a weekend next to a holiday; a
+// stock whose closes are further off lacks the history for the window.
+const moverDateTolerance = 4
+
+// ErrUnknownMoverWindow is returned for a window not in MoverWindows
+var ErrUnknownMoverWindow = errors.New("unknown market movers window")
+
+// WindowMover is a stock's return over a lookback window, between the closest
+// stored closes to the window's start and end
+type WindowMover struct {
+	Symbol        string    `json:"symbol"`
+	CompanyName   string    `json:"company_name"`
+	Sector        string    `json:"sector"`
+	StartDate     time.Time `json:"start_date"`
+	StartPrice    float64   `json:"start_price"`
+	EndDate       time.Time `json:"end_date"`
+	EndPrice      float64   `json:"end_price"`
+	Change        float64   `json:"change"`
+	ChangePercent float64   `json:"change_percent"`
+	// Volume is the shares traded after the start close, up to and including the end close
+	Volume int64 `json:"volume"`
+}
+
+// WindowMovers ranks stocks by their return over Window, which ends on AsOf,
+// the latest stored trading day. Excluded counts the active stocks without
+// closes near both ends of the window.
+type WindowMovers struct {
+	Window     string        `json:"window"`
+	AsOf       time.Time     `json:"as_of"`
+	TopGainers []WindowMover `json:"top_gainers"`
+	TopLosers  []WindowMover `json:"top_losers"`
+	MostActive []WindowMover `json:"most_active"`
+	Excluded   int           `json:"excluded"`
+}
+
+// windowReturnsQuery finds, for every active stock, the closest close on or
+// before the window's end ($1 days after its start) and on or before its
+// start, each within moverDateTolerance ($2) days
+const windowReturnsQuery = `
+		WITH as_of AS (SELECT MAX(date) AS date FROM daily_prices)
+		SELECT s.symbol, s.company_name, COALESCE(s.sector, ''), as_of.date,
+		       window_start.date, window_start.close_price,
+		       window_end.date, window_end.close_price,
+		       (SELECT COALESCE(SUM(dp.volume), 0) FROM daily_prices dp
+		        WHERE dp.stock_id = s.id AND dp.date > window_start.date AND dp.date <= window_end.date) AS volume
+		FROM stocks s
+		CROSS JOIN as_of
+		LEFT JOIN LATERAL (
+		    SELECT date, close_price
+		    FROM daily_prices
+		    WHERE stock_id = s.id AND date <= as_of.date AND date >= as_of.date - $2::int
+		    ORDER BY date DESC
+		    LIMIT 1
+		) window_end ON true
+		LEFT JOIN LATERAL (
+		    SELECT date, close_price
+		    FROM daily_prices
+		    WHERE stock_id = s.id AND date <= as_of.date - $1::int AND date >= as_of.date - $1::int - $2::int
+		    ORDER BY date DESC
+		    LIMIT 1
+		) window_start ON true
+		WHERE s.is_active = true
+		ORDER BY s.symbol`
+
+// GetWindowMovers returns the TopMoversLimit biggest gainers and losers and
+// the most traded stocks over window. Returns are computed in the database;
+// stocks missing a close near either end of the window are only counted.
+func (d *DatabaseStockService) GetWindowMovers(ctx context.Context, window string) (*WindowMovers, error) {
+	days, ok := moverWindowDays[window]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMoverWindow, window)
+	}
+
+	if d.cache != nil {
+		var cached WindowMovers
+		if err := d.cache.GetWindowMovers(ctx, window, &cached); err == nil {
+			return &cached, nil
+		}
+	}
+
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.router.Reader().QueryContext(ctx, windowReturnsQuery, days, moverDateTolerance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute window returns: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+
+	result := &WindowMovers{Window: window}
+	var movers []WindowMover
+	for rows.Next() {
+		var mover WindowMover
+		var asOf, startDate, endDate sql.NullTime
+		var startPrice, endPrice sql.NullFloat64
+		if err := rows.Scan(&mover.Symbol, &mover.CompanyName, &mover.Sector, &asOf,
+			&startDate, &startPrice, &endDate, &endPrice, &mover.Volume); err != nil {
+			return nil, fmt.Errorf("failed to scan window return: %w", err)
+		}
+		result.AsOf = asOf.Time
+		if !startPrice.Valid || !endPrice.Valid || startPrice.Float64 <= 0 {
+			result.Excluded++
+			continue
+		}
+
+		mover.StartDate, mover.StartPrice = startDate.Time, startPrice.Float64
+		mover.EndDate, mover.EndPrice = endDate.Time, endPrice.Float64
+		mover.Change = RoundPrice(mover.EndPrice - mover.StartPrice)
+		mover.ChangePercent = RoundPercent((mover.EndPrice - mover.StartPrice) / mover.StartPrice * 100)
+		movers = append(movers, mover)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read window returns: %w", queryError(ctx, err))
+	}
+
+	result.TopGainers = rankWindowMovers(movers, func(m WindowMover) bool { return m.ChangePercent > 0 },
+		func(a, b WindowMover) bool { return a.ChangePercent > b.ChangePercent })
+	result.TopLosers = rankWindowMovers(movers, func(m WindowMover) bool { return m.ChangePercent < 0 },
+		func(a, b WindowMover) bool { return a.ChangePercent < b.ChangePercent })
+	result.MostActive = rankWindowMovers(movers, func(WindowMover) bool { return true },
+		func(a, b WindowMover) bool { return a.Volume > b.Volume })
+
+	if d.cache != nil {
+		if err := d.cache.SetWindowMovers(ctx, window, result, MarketAggregateTTL); err != nil {
+			slog.WarnContext(ctx, "Failed to cache market movers", "window", window, "error", err)
+		}
+	}
+	return result, nil
+}
+
+// rankWindowMovers returns up to TopMoversLimit of the movers keep accepts,
+// ordered by before and then by symbol
+func rankWindowMovers(movers []WindowMover, keep func(WindowMover) bool, before func(a, b WindowMover) bool) []WindowMover {
+	ranked := []WindowMover{}
+	for _, mover := range movers {
+		if keep(mover) {
+			ranked = append(ranked, mover)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if before(ranked[i], ranked[j]) {
+			return true
+		}
+		if before(ranked[j], ranked[i]) {
+			return false
+		}
+		return ranked[i].Symbol < ranked[j].Symbol
+	})
+	if len(ranked) > TopMoversLimit {
+		ranked = ranked[:TopMoversLimit]
+	}
+	return ranked
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var windowReturnColumns = []string{
+	"symbol", "company_name", "sector", "as_of",
+	"start_date", "start_price", "end_date", "end_price", "volume",
+}
+
+func TestGetWindowMovers_RanksReturnsAndCountsExcluded(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+
+	asOf := time.Date(2026, time.March, 31, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2026, time.March, 24, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`LEFT JOIN LATERAL[\s\S]+window_end[\s\S]+LEFT JOIN LATERAL[\s\S]+window_start`).
+		WithArgs(7, moverDateTolerance).
+		WillReturnRows(sqlmock.NewRows(windowReturnColumns).
+			AddRow("AAPL", "Apple Inc.", "Technology", asOf, start, 200.0, asOf, 220.0, int64(300)).
+			AddRow("KO", "Coca-Cola Company", "Consumer Defensive", asOf, start, 60.0, asOf, 57.0, int64(100)).
+			AddRow("MSFT", "Microsoft Corporation", "Technology", asOf, start, 400.0, asOf, 404.0, int64(900)).
+			// Listed last week: no close near the window's start
+			AddRow("NEWCO", "New Co", "", asOf, nil, nil, asOf, 10.0, int64(0)).
+			// No prices at all
+			AddRow("NODATA", "No Data Inc.", "", asOf, nil, nil, nil, nil, int64(0)))
+
+	movers, err := service.GetWindowMovers(context.Background(), "7d")
+	require.NoError(t, err)
+	assert.Equal(t, "7d", movers.Window)
+	assert.Equal(t, asOf, movers.AsOf)
+	assert.Equal(t, 2, movers.Excluded)
+
+	require.Len(t, movers.TopGainers, 2)
+	assert.Equal(t, "AAPL", movers.TopGainers[0].Symbol)
+	assert.InDelta(t, 10.0, movers.TopGainers[0].ChangePercent, 1e-9)
+	assert.InDelta(t, 20.0, movers.TopGainers[0].Change, 1e-9)
+	assert.Equal(t, start, movers.TopGainers[0].StartDate)
+	assert.Equal(t, "MSFT", movers.TopGainers[1].Symbol)
+
+	require.Len(t, movers.TopLosers, 1)
+	assert.Equal(t, "KO", movers.TopLosers[0].Symbol)
+	assert.InDelta(t, -5.0, movers.TopLosers[0].ChangePercent, 1e-9)
+
+	var active []string
+	for _, mover := range movers.MostActive {
+		active = append(active, mover.Symbol)
+	}
+	assert.Equal(t, []string{"MSFT", "AAPL", "KO"}, active)
+
+	// Each window is cached on its own
+	cached, err := service.GetWindowMovers(context.Background(), "7d")
+	require.NoError(t, err)
+	assert.Equal(t, movers.TopGainers[0].Symbol, cached.TopGainers[0].Symbol)
+
+	mock.ExpectQuery(`window_start`).WithArgs(90, moverDateTolerance).
+		WillReturnRows(sqlmock.NewRows(windowReturnColumns))
+	quarter, err := service.GetWindowMovers(context.Background(), "90d")
+	require.NoError(t, err)
+	assert.Empty(t, quarter.TopGainers)
+	assert.NotNil(t, quarter.TopGainers)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWindowMovers_RoundsReturns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	service := NewDatabaseStockService(db, nil)
+
+	asOf := time.Date(2026, time.March, 31, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2026, time.March, 24, 0, 0, 0, 0, time.UTC)
+	// 3.0 to 3.1 is a change of 0.10000000000000009 and 3.3333...%
+	mock.ExpectQuery(`window_start`).WithArgs(7, moverDateTolerance).
+		WillReturnRows(sqlmock.NewRows(windowReturnColumns).
+			AddRow("PENNY", "Penny Co", "", asOf, start, 3.0, asOf, 3.1, int64(100)))
+
+	movers, err := service.GetWindowMovers(context.Background(), "7d")
+	require.NoError(t, err)
+	require.Len(t, movers.TopGainers, 1)
+	assert.Equal(t, 0.1, movers.TopGainers[0].Change)
+	assert.Equal(t, 3.33, movers.TopGainers[0].ChangePercent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWindowMovers_RejectsUnknownWindow(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+
+	_, err := service.GetWindowMovers(context.Background(), "2w")
+	assert.ErrorIs(t, err, ErrUnknownMoverWindow)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRankWindowMovers_LimitsAndBreaksTiesBySymbol(t *testing.T) {
+	var movers []WindowMover
+	for _, symbol := range []string{"L", "K", "J", "I", "H", "G", "F", "E", "D", "C", "B", "A"} {
+		movers = append(movers, WindowMover{Symbol: symbol, ChangePercent: 1})
+	}
+
+	ranked := rankWindowMovers(movers, func(m WindowMover) bool { return m.ChangePercent > 0 },
+		func(a, b WindowMover) bool { return a.ChangePercent > b.ChangePercent })
+	require.Len(t, ranked, TopMoversLimit)
+	assert.Equal(t, "A", ranked[0].Symbol)
+	assert.Equal(t, "J", ranked[TopMoversLimit-1].Symbol)
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"stock-intelligence-backend/internal/cache"
+	"stock-intelligence-backend/internal/models"
+)
+
+// TopMoversLimit is how many stocks each performance ranking lists
+const TopMoversLimit = 10
+
+// MarketAggregateTTL is how long the market overview, performance rankings,
+// heatmap, window movers and dividend leaders are cached. Syncs, quote
+// refreshes and stock listing changes invalidate the cache, so it bounds how
+// long prices written outside the server, as by the task runner, go unseen.
+const MarketAggregateTTL = 55 * time.Minute
+
+// unchangedPercent is the change, either way, below which a stock counts as unchanged
+const unchangedPercent = 0.01
+
+// stockChangesQuery selects every active stock with its latest close and daily
+// change, in the columns scanStockRows reads. Market aggregates and rankings
+// are computed over it as the changes CTE.
+const stockChangesQuery = `
+		SELECT s.id, s.symbol, s.company_name, s.sector, s.industry, s.market_cap,
+		       s.price_range, s.exchange, s.is_active, s.created_at, s.updated_at,
+		       s.first_price_date, s.last_price_date,
+		       COALESCE(latest.close_price, 0) as current_price,
+		       COALESCE(latest.close_price - previous.close_price, 0) as daily_change,
+		       COALESCE(
+		           CASE WHEN previous.close_price > 0 THEN
+		               ((latest.close_price - previous.close_price) / previous.close_price * 100)
+		           ELSE 0 END, 0
+		       ) as change_percent,
+		       COALESCE(latest.volume, 0) as volume,
+		       COALESCE(latest.date, s.updated_at) as last_updated,
+		       COALESCE(latest.date - previous.date, 0) as change_period_days
+		FROM stocks s` + latestPriceJoins + `
+		WHERE s.is_active = true`
+
+// MarketOverviewAggregate is the market overview with the change outliers left out of it
+type MarketOverviewAggregate struct {
+	models.MarketOverview
+	ExcludedCount int               `json:"excluded_count"`
+	Diagnostics   ChangeDiagnostics `json:"diagnostics"`
+}
+
+// TopMovers is the performance rankings with the change outliers left out of
+// them. MostActive, ranked by relative volume, stands in for the embedded
+// ranking by volume.
+type TopMovers struct {
+	models.StockPerformance
+	MostActive  []ActiveStock     `json:"most_active"`
+	Diagnostics ChangeDiagnostics `json:"diagnostics"`
+}
+
+// rankableChanges is the changes CTE and, as ranked, the stocks within the
+// change percent guard; its args are those the guard's placeholder needs
+func (d *DatabaseStockService) rankableChanges() (string, []interface{}) {
+	if d.maxChangePercent <= 0 {
+		return `WITH changes AS (` + stockChangesQuery + `),
+		ranked AS (SELECT * FROM changes)`, nil
+	}
+	return `WITH changes AS (` + stockChangesQuery + `),
+		ranked AS (SELECT * FROM changes WHERE ABS(change_percent) <= $1)`, []interface{}{d.maxChangePercent}
+}
+
+// GetMarketOverviewAggregate counts advancing, declining and unchanged stocks
+// and averages their change in the database. Stocks beyond the change percent
+// guard count towards the total only, and are listed in the diagnostics.
+func (d *DatabaseStockService) GetMarketOverviewAggregate(ctx context.Context) (*MarketOverviewAggregate, error) {
+	overview, err := loadShared(ctx, d, cache.MarketOverviewKey, MarketAggregateTTL, func(ctx context.Context) (MarketOverviewAggregate, bool, error) {
+		overview, err := d.computeMarketOverview(ctx)
+		return overview, err == nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &overview, nil
+}
+
+// computeMarketOverview runs GetMarketOverviewAggregate's queries
+func (d *DatabaseStockService) computeMarketOverview(ctx context.Context) (MarketOverviewAggregate, error) {
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+
+	with, args := d.rankableChanges()
+	query := with + fmt.Sprintf(`
+		SELECT (SELECT COUNT(*) FROM changes),
+		       COUNT(*) FILTER (WHERE change_percent > %[1]g),
+		       COUNT(*) FILTER (WHERE change_percent < -%[1]g),
+		       COUNT(*) FILTER (WHERE change_percent BETWEEN -%[1]g AND %[1]g),
+		       COALESCE(AVG(change_percent), 0)
+		FROM ranked
+	`, unchangedPercent)
+
+	var overview MarketOverviewAggregate
+	err := d.router.Reader().QueryRowContext(ctx, query, args...).Scan(
+		&overview.TotalStocks, &overview.AdvancingCount, &overview.DecliningCount,
+		&overview.UnchangedCount, &overview.AvgChange,
+	)
+	if err != nil {
+		return MarketOverviewAggregate{}, fmt.Errorf("failed to compute market overview: %w", queryError(ctx, err))
+	}
+	overview.AvgChange = RoundPercent(overview.AvgChange)
+
+	overview.Diagnostics, err = d.changeDiagnostics(ctx)
+	if err != nil {
+		return MarketOverviewAggregate{}, err
+	}
+	overview.ExcludedCount = len(overview.Diagnostics.ExcludedOutliers)
+	return overview, nil
+}
+
+// GetTopMovers returns the limit biggest gainers and losers and the most
+// active stocks, ranked in the database. Stocks beyond the change percent
+// guard are left out of the gainers and losers. The most active are those
+// trading furthest above their average volume; stocks without the history
+// for an average follow, by volume. Only TopMoversLimit rankings are cached.
+func (d *DatabaseStockService) GetTopMovers(ctx context.Context, limit int) (*TopMovers, error) {
+	if d.cache != nil && limit == TopMoversLimit {
+		var cached TopMovers
+		if err := d.cache.GetPerformanceData(ctx, &cached); err == nil {
+			return &cached, nil
+		}
+	}
+
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+
+	with, args := d.rankableChanges()
+	limitArg := fmt.Sprintf("$%d", len(args)+1)
+	args = append(args, limit)
+
+	var movers TopMovers
+	var err error
+	movers.TopGainers, err = d.rankStocks(ctx, with+`
+		SELECT * FROM ranked
+		WHERE change_percent > 0
+		ORDER BY change_percent DESC, symbol
+		LIMIT `+limitArg, args)
+	if err != nil {
+		return nil, err
+	}
+	movers.TopLosers, err = d.rankStocks(ctx, with+`
+		SELECT * FROM ranked
+		WHERE change_percent < 0
+		ORDER BY change_percent ASC, symbol
+		LIMIT `+limitArg, args)
+	if err != nil {
+		return nil, err
+	}
+	// Volume is real even when the previous close is not, so outliers stay in
+	movers.MostActive, err = d.rankActiveStocks(ctx, with+relativeVolumes+`
+		SELECT * FROM volumes
+		ORDER BY relative_volume DESC NULLS LAST, volume DESC, symbol
+		LIMIT `+limitArg, args)
+	if err != nil {
+		return nil, err
+	}
+
+	movers.Diagnostics, err = d.changeDiagnostics(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.cache != nil && limit == TopMoversLimit {
+		if err := d.cache.SetPerformanceData(ctx, movers, MarketAggregateTTL); err != nil {
+			slog.WarnContext(ctx, "Failed to cache performance rankings", "error", err)
+		}
+	}
+
+	return &movers, nil
+}
+
+// rankStocks runs one ranking query, returning an empty list rather than nil
+func (d *DatabaseStockService) rankStocks(ctx context.Context, query string, args []interface{}) ([]models.Stock, error) {
+	rows, err := d.router.Reader().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rank stocks: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+
+	stocks := d.scanStockRows(ctx, rows)
+	if stocks == nil {
+		stocks = []models.Stock{}
+	}
+	return stocks, queryError(ctx, rows.Err())
+}
+
+// changeDiagnostics lists the stocks whose change percent is beyond the guard
+func (d *DatabaseStockService) changeDiagnostics(ctx context.Context) (ChangeDiagnostics, error) {
+	diagnostics := ChangeDiagnostics{
+		MaxChangePercent: d.maxChangePercent,
+		ExcludedOutliers: []ChangeOutlier{},
+	}
+	if d.maxChangePercent <= 0 {
+		return diagnostics, nil
+	}
+
+	query := `WITH changes AS (` + stockChangesQuery + `)
+		SELECT symbol, change_percent, current_price, daily_change
+		FROM changes
+		WHERE ABS(change_percent) > $1
+		ORDER BY symbol
+	`
+	rows, err := d.router.Reader().QueryContext(ctx, query, d.maxChangePercent)
+	if err != nil {
+		return diagnostics, fmt.Errorf("failed to find change outliers: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		outlier := ChangeOutlier{Reason: outlierReason}
+		if err := rows.Scan(&outlier.Symbol, &outlier.ChangePercent, &outlier.CurrentPrice, &outlier.DailyChange); err != nil {
+			return diagnostics, fmt.Errorf("failed to scan change outlier: %w", err)
+		}
+		diagnostics.ExcludedOutliers = append(diagnostics.ExcludedOutliers, outlier)
+	}
+	return diagnostics, queryError(ctx, rows.Err())
+}
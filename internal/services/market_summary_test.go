@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/cache"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var rankedStockColumns = []string{
+	"id", "symbol", "company_name", "sector", "industry", "market_cap",
+	"price_range", "exchange", "is_active", "created_at", "updated_at",
+	"first_price_date", "last_price_date",
+	"current_price", "daily_change", "change_percent", "volume", "last_updated", "change_period_days",
+}
+
+// rankedStocks is a ranking query's result for symbols, in the order given
+func rankedStocks(changePercent float64, symbols ...string) *sqlmock.Rows {
+	rows := sqlmock.NewRows(rankedStockColumns)
+	now := time.Now()
+	for i, symbol := range symbols {
+		rows.AddRow(i+1, symbol, symbol+" Inc.", "Technology", "Software", nil,
+			"$100+", "NASDAQ", true, now, now, nil, nil, 100.0, changePercent, changePercent, int64(1000), now, 1)
+	}
+	return rows
+}
+
+// activeStocks is a most active ranking's result: symbol, volume, average and
+// relative volume, the last two nil for a stock without the history
+func activeStocks(rows ...[]interface{}) *sqlmock.Rows {
+	result := sqlmock.NewRows(append(slices.Clone(rankedStockColumns), "avg_volume", "relative_volume"))
+	now := time.Now()
+	for i, row := range rows {
+		result.AddRow(i+1, row[0], row[0].(string)+" Inc.", "Technology", "Software", nil,
+			"$100+", "NASDAQ", true, now, now, nil, nil, 100.0, 1.0, 1.0, row[1], now, 1, row[2], row[3])
+	}
+	return result
+}
+
+func newMarketSummaryService(t *testing.T) (*DatabaseStockService, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache("redis://"+server.Addr(), "test:")
+	require.NoError(t, err)
+	t.Cleanup(func() { redisCache.Close() })
+
+	return NewDatabaseStockService(db, redisCache), mock
+}
+
+func TestGetMarketOverviewAggregate_CountsAndCaches(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+
+	mock.ExpectQuery(`FILTER \(WHERE change_percent > 0.01\)[\s\S]+FROM ranked`).WithArgs(DefaultMaxChangePercent).
+		WillReturnRows(sqlmock.NewRows([]string{"total", "advancing", "declining", "unchanged", "avg"}).
+			AddRow(20, 12, 6, 1, 5.25))
+	mock.ExpectQuery(`WHERE ABS\(change_percent\) > \$1`).WithArgs(DefaultMaxChangePercent).
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "change_percent", "current_price", "daily_change"}).
+			AddRow("MKT20", 80.0, 180.0, 80.0))
+
+	overview, err := service.GetMarketOverviewAggregate(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 20, overview.TotalStocks)
+	assert.Equal(t, 12, overview.AdvancingCount)
+	assert.Equal(t, 6, overview.DecliningCount)
+	assert.Equal(t, 1, overview.UnchangedCount)
+	assert.Equal(t, 1, overview.ExcludedCount)
+	assert.Equal(t, "MKT20", overview.Diagnostics.ExcludedOutliers[0].Symbol)
+
+	// Served from market:overview without touching the database
+	cached, err := service.GetMarketOverviewAggregate(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, overview, cached)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMarketOverviewAggregate_AverageChangeIsStable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	service := NewDatabaseStockService(db, nil)
+
+	// The same three changes averaged in two orders differ in the last bits
+	changes := []float64{0.1, 0.2, 0.3}
+	forwards := (changes[0] + changes[1] + changes[2]) / 3
+	backwards := (changes[2] + changes[1] + changes[0]) / 3
+	require.NotEqual(t, forwards, backwards)
+
+	for _, avg := range []float64{forwards, backwards} {
+		mock.ExpectQuery(`FROM ranked`).WithArgs(DefaultMaxChangePercent).
+			WillReturnRows(sqlmock.NewRows([]string{"total", "advancing", "declining", "unchanged", "avg"}).
+				AddRow(3, 3, 0, 0, avg))
+		mock.ExpectQuery(`WHERE ABS\(change_percent\) > \$1`).WithArgs(DefaultMaxChangePercent).
+			WillReturnRows(sqlmock.NewRows([]string{"symbol", "change_percent", "current_price", "daily_change"}))
+
+		overview, err := service.GetMarketOverviewAggregate(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0.2, overview.AvgChange)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTopMovers_RanksInSQLAndCaches(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+
+	// 20 stocks: the database returns each ranking already ordered and limited
+	var gainers, losers, active []string
+	for i := 12; i > 2; i-- {
+		gainers = append(gainers, fmt.Sprintf("MKT%02d", i))
+	}
+	for i := 18; i > 12; i-- {
+		losers = append(losers, fmt.Sprintf("MKT%02d", i))
+	}
+	for i := 20; i > 10; i-- {
+		active = append(active, fmt.Sprintf("MKT%02d", i))
+	}
+	mock.ExpectQuery(`WHERE change_percent > 0\s+ORDER BY change_percent DESC, symbol\s+LIMIT \$2`).
+		WithArgs(DefaultMaxChangePercent, TopMoversLimit).WillReturnRows(rankedStocks(15, gainers...))
+	mock.ExpectQuery(`WHERE change_percent < 0\s+ORDER BY change_percent ASC, symbol\s+LIMIT \$2`).
+		WithArgs(DefaultMaxChangePercent, TopMoversLimit).WillReturnRows(rankedStocks(-12, losers...))
+	var activeRows [][]interface{}
+	for _, symbol := range active {
+		activeRows = append(activeRows, []interface{}{symbol, int64(1000), nil, nil})
+	}
+	mock.ExpectQuery(`FROM volumes\s+ORDER BY relative_volume DESC NULLS LAST, volume DESC, symbol\s+LIMIT \$2`).
+		WithArgs(DefaultMaxChangePercent, TopMoversLimit).WillReturnRows(activeStocks(activeRows...))
+	mock.ExpectQuery(`WHERE ABS\(change_percent\) > \$1`).WithArgs(DefaultMaxChangePercent).
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "change_percent", "current_price", "daily_change"}))
+
+	movers, err := service.GetTopMovers(context.Background(), TopMoversLimit)
+	require.NoError(t, err)
+
+	require.Len(t, movers.TopGainers, TopMoversLimit)
+	for i, symbol := range gainers {
+		assert.Equal(t, symbol, movers.TopGainers[i].Symbol)
+	}
+	require.Len(t, movers.TopLosers, len(losers))
+	assert.Equal(t, "MKT18", movers.TopLosers[0].Symbol)
+	require.Len(t, movers.MostActive, TopMoversLimit)
+	assert.Equal(t, "MKT20", movers.MostActive[0].Symbol)
+	assert.Empty(t, movers.Diagnostics.ExcludedOutliers)
+
+	// Served from performance:rankings without touching the database
+	cached, err := service.GetTopMovers(context.Background(), TopMoversLimit)
+	require.NoError(t, err)
+	assert.Equal(t, movers.TopGainers[0].Symbol, cached.TopGainers[0].Symbol)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTopMovers_WithoutChangeGuard(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewDatabaseStockService(db, nil)
+	service.SetMaxChangePercent(0)
+
+	// No guard: the limit is the only argument and nothing is listed as an outlier
+	mock.ExpectQuery(`WHERE change_percent > 0`).WithArgs(5).WillReturnRows(rankedStocks(2, "AAPL"))
+	mock.ExpectQuery(`WHERE change_percent < 0`).WithArgs(5).WillReturnRows(sqlmock.NewRows(rankedStockColumns))
+	mock.ExpectQuery(`ORDER BY relative_volume DESC`).WithArgs(5).WillReturnRows(activeStocks([]interface{}{"AAPL", int64(1000), nil, nil}))
+
+	movers, err := service.GetTopMovers(context.Background(), 5)
+	require.NoError(t, err)
+	assert.Len(t, movers.TopGainers, 1)
+	assert.NotNil(t, movers.TopLosers, "an empty ranking is a list, not null")
+	assert.Empty(t, movers.TopLosers)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"math"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// TradingDaysPerYear annualizes daily volatility
+const TradingDaysPerYear = 252
+
+// DayReturn is the percent change from the previous stored close to Date's close
+type DayReturn struct {
+	Date          string  `json:"date"`
+	ChangePercent float64 `json:"change_percent"`
+}
+
+// PerformanceMetrics summarizes a price series. A metric the series is too short
+// to support is nil, so it encodes as null rather than as a NaN JSON cannot carry.
+// Percentages are in percent; dates are formatted like chart data points.
+type PerformanceMetrics struct {
+	// AnnualizedVolatility is the sample standard deviation of daily log returns,
+	// scaled by the square root of TradingDaysPerYear
+	AnnualizedVolatility *float64 `json:"annualized_volatility"`
+	// MaxDrawdown is the largest fall from a peak close to a later trough, as a
+	// non-positive percent of the peak, running from MaxDrawdownStart to MaxDrawdownEnd
+	MaxDrawdown      *float64   `json:"max_drawdown"`
+	MaxDrawdownStart *string    `json:"max_drawdown_start"`
+	MaxDrawdownEnd   *string    `json:"max_drawdown_end"`
+	BestDay          *DayReturn `json:"best_day"`
+	WorstDay         *DayReturn `json:"worst_day"`
+	AverageVolume    *float64   `json:"average_volume"`
+}
+
+// ComputePerformanceMetrics summarizes points, oldest first. Returns are taken
+// between consecutive stored closes, so a missing day folds into the next
+// return instead of breaking the series; closes at or below zero are skipped.
+func ComputePerformanceMetrics(points []models.PricePoint) PerformanceMetrics {
+	var metrics PerformanceMetrics
+	if len(points) == 0 {
+		return metrics
+	}
+
+	var totalVolume float64
+	for _, point := range points {
+		totalVolume += float64(point.Volume)
+	}
+	averageVolume := totalVolume / float64(len(points))
+	metrics.AverageVolume = &averageVolume
+
+	var logReturns []float64
+	var previous, peak *models.PricePoint
+	maxDrawdown := 0.0
+	var drawdownStart, drawdownEnd string
+	for i := range points {
+		point := &points[i]
+		if point.Price <= 0 {
+			continue
+		}
+
+		if previous != nil {
+			logReturns = append(logReturns, math.Log(point.Price/previous.Price))
+			day := DayReturn{
+				Date:          point.Date.Format("2006-01-02"),
+				ChangePercent: (point.Price - previous.Price) / previous.Price * 100,
+			}
+			if metrics.BestDay == nil || day.ChangePercent > metrics.BestDay.ChangePercent {
+				best := day
+				metrics.BestDay = &best
+			}
+			if metrics.WorstDay == nil || day.ChangePercent < metrics.WorstDay.ChangePercent {
+				worst := day
+				metrics.WorstDay = &worst
+			}
+		}
+		previous = point
+
+		if peak == nil || point.Price > peak.Price {
+			peak = point
+			continue
+		}
+		if drawdown := (point.Price - peak.Price) / peak.Price * 100; drawdown < maxDrawdown {
+			maxDrawdown = drawdown
+			drawdownStart = peak.Date.Format("2006-01-02")
+			drawdownEnd = point.Date.Format("2006-01-02")
+		}
+	}
+
+	if len(logReturns) > 0 {
+		metrics.MaxDrawdown = &maxDrawdown
+		if drawdownStart != "" {
+			metrics.MaxDrawdownStart, metrics.MaxDrawdownEnd = &drawdownStart, &drawdownEnd
+		}
+	}
+	if len(logReturns) > 1 {
+		volatility := sampleStdDev(logReturns) * math.Sqrt(TradingDaysPerYear) * 100
+		metrics.AnnualizedVolatility = &volatility
+	}
+	return metrics
+}
+
+// sampleStdDev is the standard deviation of at least two values with Bessel's correction
+func sampleStdDev(values []float64) float64 {
+	var mean float64
+	for _, value := range values {
+		mean += value
+	}
+	mean /= float64(len(values))
+
+	var squares float64
+	for _, value := range values {
+		squares += (value - mean) * (value - mean)
+	}
+	return math.Sqrt(squares / float64(len(values)-1))
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// closesFrom builds a series of one close per listed day of March 2026
+func closesFrom(days []int, prices []float64) []models.PricePoint {
+	points := make([]models.PricePoint, len(prices))
+	for i, price := range prices {
+		points[i] = models.PricePoint{Date: date2026(time.March, days[i]), Price: price, Volume: int64(1000 * (i + 1))}
+	}
+	return points
+}
+
+func floatPtr(value float64) *float64 { return &value }
+func stringPtr(value string) *string  { return &value }
+
+func TestComputePerformanceMetrics(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []models.PricePoint
+		want   PerformanceMetrics
+	}{
+		{
+			name: "empty series",
+		},
+		{
+			name:   "single close",
+			points: closesFrom([]int{2}, []float64{100}),
+			want:   PerformanceMetrics{AverageVolume: floatPtr(1000)},
+		},
+		{
+			name:   "two closes without a drawdown",
+			points: closesFrom([]int{2, 3}, []float64{100, 110}),
+			want: PerformanceMetrics{
+				MaxDrawdown:   floatPtr(0),
+				BestDay:       &DayReturn{Date: "2026-03-03", ChangePercent: 10},
+				WorstDay:      &DayReturn{Date: "2026-03-03", ChangePercent: 10},
+				AverageVolume: floatPtr(1500),
+			},
+		},
+		{
+			// March 5 is missing; the return into March 6 spans it
+			name:   "gap in the series",
+			points: closesFrom([]int{2, 3, 4, 6, 9}, []float64{100, 110, 99, 104.5, 120}),
+			want: PerformanceMetrics{
+				AnnualizedVolatility: floatPtr(168.813101),
+				MaxDrawdown:          floatPtr(-10),
+				MaxDrawdownStart:     stringPtr("2026-03-03"),
+				MaxDrawdownEnd:       stringPtr("2026-03-04"),
+				BestDay:              &DayReturn{Date: "2026-03-09", ChangePercent: 14.832536},
+				WorstDay:             &DayReturn{Date: "2026-03-04", ChangePercent: -10},
+				AverageVolume:        floatPtr(3000),
+			},
+		},
+		{
+			name:   "deepest of several drawdowns",
+			points: closesFrom([]int{2, 3, 4, 5, 6, 9}, []float64{50, 40, 45, 30, 60, 55}),
+			want: PerformanceMetrics{
+				AnnualizedVolatility: floatPtr(670.815376),
+				MaxDrawdown:          floatPtr(-40),
+				MaxDrawdownStart:     stringPtr("2026-03-02"),
+				MaxDrawdownEnd:       stringPtr("2026-03-05"),
+				BestDay:              &DayReturn{Date: "2026-03-06", ChangePercent: 100},
+				WorstDay:             &DayReturn{Date: "2026-03-05", ChangePercent: -33.333333},
+				AverageVolume:        floatPtr(3500),
+			},
+		},
+		{
+			name:   "zero close is skipped",
+			points: closesFrom([]int{2, 3, 4}, []float64{100, 0, 50}),
+			want: PerformanceMetrics{
+				MaxDrawdown:      floatPtr(-50),
+				MaxDrawdownStart: stringPtr("2026-03-02"),
+				MaxDrawdownEnd:   stringPtr("2026-03-04"),
+				BestDay:          &DayReturn{Date: "2026-03-04", ChangePercent: -50},
+				WorstDay:         &DayReturn{Date: "2026-03-04", ChangePercent: -50},
+				AverageVolume:    floatPtr(2000),
+			},
+		},
+	}
+
+	const precision = 1e-6
+	assertFloat := func(t *testing.T, want, got *float64, metric string) {
+		if want == nil {
+			assert.Nil(t, got, metric)
+			return
+		}
+		require.NotNil(t, got, metric)
+		assert.InDelta(t, *want, *got, precision, metric)
+	}
+	assertDay := func(t *testing.T, want, got *DayReturn, metric string) {
+		if want == nil {
+			assert.Nil(t, got, metric)
+			return
+		}
+		require.NotNil(t, got, metric)
+		assert.Equal(t, want.Date, got.Date, metric)
+		assert.InDelta(t, want.ChangePercent, got.ChangePercent, precision, metric)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputePerformanceMetrics(tt.points)
+
+			assertFloat(t, tt.want.AnnualizedVolatility, got.AnnualizedVolatility, "annualized_volatility")
+			assertFloat(t, tt.want.MaxDrawdown, got.MaxDrawdown, "max_drawdown")
+			assert.Equal(t, tt.want.MaxDrawdownStart, got.MaxDrawdownStart, "max_drawdown_start")
+			assert.Equal(t, tt.want.MaxDrawdownEnd, got.MaxDrawdownEnd, "max_drawdown_end")
+			assertDay(t, tt.want.BestDay, got.BestDay, "best_day")
+			assertDay(t, tt.want.WorstDay, got.WorstDay, "worst_day")
+			assertFloat(t, tt.want.AverageVolume, got.AverageVolume, "average_volume")
+
+			// Short series encode as nulls rather than failing on NaN
+			_, err := json.Marshal(got)
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+var ErrPortfolioNotFound = errors.New("portfolio not found")
+
+// quantityDecimals is how many decimal places transaction quantities are
+// stored to, so fractional shares add up exactly
+const quantityDecimals = 6
+
+// InsufficientQuantityError is a sell of more shares than the portfolio
+// holds at the time it executed, or would leave a later sell short
+type InsufficientQuantityError struct {
+	Symbol string
+	// Available is the most that could be sold at the time
+	Available float64
+	Requested float64
+}
+
+func (e *InsufficientQuantityError) Error() string {
+	return fmt.Sprintf("cannot sell %g %s: only %g available", e.Requested, e.Symbol, e.Available)
+}
+
+// PortfolioService records portfolio transactions and values the positions
+// they add up to. Like watchlists it reads from the primary, since clients
+// read a summary straight after recording a trade.
+type PortfolioService struct {
+	db               *sql.DB
+	calendar         *MarketCalendar
+	staleTradingDays int
+	now              func() time.Time
+}
+
+func NewPortfolioService(db *sql.DB) *PortfolioService {
+	return &PortfolioService{
+		db:               db,
+		calendar:         NewMarketCalendar(),
+		staleTradingDays: DefaultStaleTradingDays,
+		now:              time.Now,
+	}
+}
+
+// SetMarketCalendar sets the calendar whose trading days decide when a position's price is stale
+func (p *PortfolioService) SetMarketCalendar(calendar *MarketCalendar) {
+	if calendar != nil {
+		p.calendar = calendar
+	}
+}
+
+// CreatePortfolio creates an empty portfolio
+func (p *PortfolioService) CreatePortfolio(ctx context.Context, name string) (*models.Portfolio, error) {
+	portfolio := &models.Portfolio{Name: name}
+	err := p.db.QueryRowContext(ctx, `
+		INSERT INTO portfolios (name) VALUES ($1)
+		RETURNING id, created_at, updated_at
+	`, name).Scan(&portfolio.ID, &portfolio.CreatedAt, &portfolio.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create portfolio: %w", err)
+	}
+	return portfolio, nil
+}
+
+// RecordTransaction records a buy or sell at its ExecutedAt, which may be in
+// the past. A sell is refused with an InsufficientQuantityError when the
+// portfolio holds fewer shares at that time, or when it would leave a later
+// sell short. The portfolio row stays locked until the transaction is stored,
+// so two sells of the same shares cannot both pass.
+func (p *PortfolioService) RecordTransaction(ctx context.Context, portfolioID int64, transaction models.Transaction) (*models.Transaction, error) {
+	transaction.Quantity = roundTo(transaction.Quantity, quantityDecimals)
+	transaction.Price = RoundPrice(transaction.Price)
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked int64
+	err = tx.QueryRowContext(ctx, "SELECT id FROM portfolios WHERE id = $1 FOR UPDATE", portfolioID).Scan(&locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPortfolioNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get portfolio: %w", err)
+	}
+
+	var stockID int
+	err = tx.QueryRowContext(ctx, "SELECT id FROM stocks WHERE symbol = $1", transaction.Symbol).Scan(&stockID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUnknownSymbol
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up stock: %w", err)
+	}
+
+	if transaction.Side == models.SideSell {
+		available, err := availableToSell(ctx, tx, portfolioID, stockID, transaction.ExecutedAt)
+		if err != nil {
+			return nil, err
+		}
+		if transaction.Quantity > available {
+			return nil, &InsufficientQuantityError{Symbol: transaction.Symbol, Available: available, Requested: transaction.Quantity}
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO transactions (portfolio_id, stock_id, side, quantity, price, executed_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, portfolioID, stockID, transaction.Side, transaction.Quantity, transaction.Price, transaction.ExecutedAt).Scan(&transaction.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record %s transaction: %w", transaction.Symbol, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit %s transaction: %w", transaction.Symbol, err)
+	}
+	return &transaction, nil
+}
+
+// availableToSell is the most of a stock that can be sold at executedAt: the
+// quantity held then, less whatever later sells still need
+func availableToSell(ctx context.Context, tx *sql.Tx, portfolioID int64, stockID int, executedAt time.Time) (float64, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT side, quantity, executed_at
+		FROM transactions
+		WHERE portfolio_id = $1 AND stock_id = $2
+		ORDER BY executed_at, id
+	`, portfolioID, stockID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get transactions: %w", err)
+	}
+	defer rows.Close()
+
+	// The held quantity after each transaction; from executedAt on, the lowest
+	// of them is what a sell then may take without leaving a later one short
+	held, available, started := 0.0, 0.0, false
+	for rows.Next() {
+		var side string
+		var quantity float64
+		var at time.Time
+		if err := rows.Scan(&side, &quantity, &at); err != nil {
+			return 0, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		if !started && at.After(executedAt) {
+			started, available = true, held
+		}
+		if side == models.SideSell {
+			quantity = -quantity
+		}
+		held = roundTo(held+quantity, quantityDecimals)
+		if started {
+			available = min(available, held)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to get transactions: %w", err)
+	}
+	if !started {
+		available = held
+	}
+	return max(available, 0), nil
+}
+
+// Summary returns a portfolio's open positions, valued at each stock's latest
+// stored close, and their totals
+func (p *PortfolioService) Summary(ctx context.Context, portfolioID int64) (*models.PortfolioSummary, error) {
+	summary := &models.PortfolioSummary{Portfolio: models.Portfolio{ID: portfolioID}, Positions: []models.Position{}}
+	err := p.db.QueryRowContext(ctx, `
+		SELECT name, created_at, updated_at FROM portfolios WHERE id = $1
+	`, portfolioID).Scan(&summary.Name, &summary.CreatedAt, &summary.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPortfolioNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get portfolio: %w", err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT t.id, s.symbol, t.side, t.quantity, t.price, t.executed_at,
+		       latest.close_price, latest.date
+		FROM transactions t
+		JOIN stocks s ON s.id = t.stock_id
+		LEFT JOIN LATERAL (
+		    SELECT close_price, date FROM daily_prices
+		    WHERE stock_id = t.stock_id
+		    ORDER BY date DESC
+		    LIMIT 1
+		) latest ON true
+		WHERE t.portfolio_id = $1
+		ORDER BY s.symbol, t.executed_at, t.id
+	`, portfolioID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var ledger *positionLedger
+	for rows.Next() {
+		var transaction models.Transaction
+		var closePrice sql.NullFloat64
+		var priceDate sql.NullTime
+		if err := rows.Scan(&transaction.ID, &transaction.Symbol, &transaction.Side, &transaction.Quantity,
+			&transaction.Price, &transaction.ExecutedAt, &closePrice, &priceDate); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		if ledger == nil || ledger.symbol != transaction.Symbol {
+			if ledger != nil {
+				p.addPosition(summary, ledger)
+			}
+			ledger = &positionLedger{symbol: transaction.Symbol}
+			if closePrice.Valid && priceDate.Valid {
+				ledger.latestPrice, ledger.priceDate = &closePrice.Float64, &priceDate.Time
+			}
+		}
+		ledger.apply(transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+	if ledger != nil {
+		p.addPosition(summary, ledger)
+	}
+
+	totals := &summary.Totals
+	totals.CostBasis = RoundPrice(totals.CostBasis)
+	totals.MarketValue = RoundPrice(totals.MarketValue)
+	totals.UnrealizedPnL = RoundPrice(totals.UnrealizedPnL)
+	totals.RealizedPnL = RoundPrice(totals.RealizedPnL)
+	return summary, nil
+}
+
+// addPosition values the ledger's open shares and adds them and its realized
+// P&L to the summary; a stock sold out of only adds its realized P&L
+func (p *PortfolioService) addPosition(summary *models.PortfolioSummary, ledger *positionLedger) {
+	summary.Totals.RealizedPnL += ledger.realized
+	quantity := ledger.quantity()
+	if quantity <= 0 {
+		return
+	}
+
+	costBasis := RoundPrice(ledger.costBasis())
+	position := models.Position{
+		Symbol:      ledger.symbol,
+		Quantity:    quantity,
+		AverageCost: RoundPrice(costBasis / quantity),
+		CostBasis:   costBasis,
+		RealizedPnL: RoundPrice(ledger.realized),
+		Stale:       true,
+	}
+	summary.Totals.CostBasis += costBasis
+
+	if ledger.latestPrice != nil {
+		price := RoundPrice(*ledger.latestPrice)
+		marketValue := RoundPrice(quantity * price)
+		unrealized := RoundPrice(marketValue - costBasis)
+		position.LatestPrice, position.PriceDate = &price, ledger.priceDate
+		position.MarketValue, position.UnrealizedPnL = &marketValue, &unrealized
+		if costBasis > 0 {
+			percent := RoundPercent(unrealized / costBasis * 100)
+			position.UnrealizedPnLPercent = &percent
+		}
+		position.Stale = p.calendar.TradingDaysSince(*ledger.priceDate, p.now()) >= p.staleTradingDays
+		summary.Totals.MarketValue += marketValue
+		summary.Totals.UnrealizedPnL += unrealized
+	}
+	if position.Stale {
+		summary.Totals.StalePositions++
+	}
+	summary.Positions = append(summary.Positions, position)
+}
+
+// lot is shares bought together and not yet sold
+type lot struct {
+	quantity float64
+	price    float64
+}
+
+// positionLedger replays one stock's transactions in the order executed,
+// selling from the oldest lots first
+type positionLedger struct {
+	symbol      string
+	lots        []lot
+	realized    float64
+	latestPrice *float64
+	priceDate   *time.Time
+}
+
+// apply adds a buy as a new lot, or takes a sell from the oldest lots and
+// books its gain or loss against what they cost
+func (l *positionLedger) apply(transaction models.Transaction) {
+	if transaction.Side == models.SideBuy {
+		l.lots = append(l.lots, lot{quantity: transaction.Quantity, price: transaction.Price})
+		return
+	}
+
+	remaining := transaction.Quantity
+	for remaining > 0 && len(l.lots) > 0 {
+		oldest := &l.lots[0]
+		sold := min(remaining, oldest.quantity)
+		l.realized += sold * (transaction.Price - oldest.price)
+		oldest.quantity = roundTo(oldest.quantity-sold, quantityDecimals)
+		remaining = roundTo(remaining-sold, quantityDecimals)
+		if oldest.quantity == 0 {
+			l.lots = l.lots[1:]
+		}
+	}
+}
+
+func (l *positionLedger) quantity() float64 {
+	total := 0.0
+	for _, lot := range l.lots {
+		total += lot.quantity
+	}
+	return roundTo(total, quantityDecimals)
+}
+
+func (l *positionLedger) costBasis() float64 {
+	total := 0.0
+	for _, lot := range l.lots {
+		total += lot.quantity * lot.price
+	}
+	return total
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var portfolioTransactionColumns = []string{"id", "symbol", "side", "quantity", "price", "executed_at", "close_price", "date"}
+
+// newTestPortfolioService returns a service whose clock is Wednesday
+// 2026-03-11, mid-session in New York
+func newTestPortfolioService(t *testing.T) (*PortfolioService, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	service := NewPortfolioService(db)
+	service.now = func() time.Time { return time.Date(2026, 3, 11, 15, 0, 0, 0, marketLocation) }
+	return service, mock
+}
+
+func expectPortfolioSummary(mock sqlmock.Sqlmock, id int64, transactions *sqlmock.Rows) {
+	created := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT name, created_at, updated_at FROM portfolios").WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "created_at", "updated_at"}).AddRow("Core", created, created))
+	mock.ExpectQuery(`FROM transactions t[\s\S]+LEFT JOIN LATERAL[\s\S]+latest ON true`).WithArgs(id).
+		WillReturnRows(transactions)
+}
+
+func findPosition(t *testing.T, summary *models.PortfolioSummary, symbol string) models.Position {
+	t.Helper()
+	for _, position := range summary.Positions {
+		if position.Symbol == symbol {
+			return position
+		}
+	}
+	t.Fatalf("no position in %s", symbol)
+	return models.Position{}
+}
+
+func TestPortfolioSummary_FIFOCostAndPnL(t *testing.T) {
+	service, mock := newTestPortfolioService(t)
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 15, 0, 0, 0, time.UTC) }
+	lastClose := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	expectPortfolioSummary(mock, 1, sqlmock.NewRows(portfolioTransactionColumns).
+		// AAPL: the sell takes all of the first lot and half of the second
+		AddRow(1, "AAPL", "buy", 10.0, 100.0, day(2), 125.0, lastClose).
+		AddRow(2, "AAPL", "buy", 10.0, 120.0, day(3), 125.0, lastClose).
+		AddRow(3, "AAPL", "sell", 15.0, 130.0, day(4), 125.0, lastClose).
+		// MSFT has never had a price stored
+		AddRow(4, "MSFT", "buy", 2.0, 300.0, day(5), nil, nil).
+		// TSLA was sold out of at a loss
+		AddRow(5, "TSLA", "buy", 1.0, 200.0, day(5), 190.0, lastClose).
+		AddRow(6, "TSLA", "sell", 1.0, 180.0, day(6), 190.0, lastClose))
+
+	summary, err := service.Summary(context.Background(), 1)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, "Core", summary.Name)
+	require.Len(t, summary.Positions, 2, "closed positions are left out")
+
+	aapl := findPosition(t, summary, "AAPL")
+	assert.Equal(t, 5.0, aapl.Quantity)
+	assert.Equal(t, 120.0, aapl.AverageCost, "the remaining shares are from the second lot")
+	assert.Equal(t, 600.0, aapl.CostBasis)
+	assert.Equal(t, 350.0, aapl.RealizedPnL)
+	require.NotNil(t, aapl.MarketValue)
+	assert.Equal(t, 625.0, *aapl.MarketValue)
+	assert.Equal(t, 25.0, *aapl.UnrealizedPnL)
+	assert.Equal(t, 4.17, *aapl.UnrealizedPnLPercent)
+	assert.False(t, aapl.Stale)
+
+	msft := findPosition(t, summary, "MSFT")
+	assert.Equal(t, 300.0, msft.AverageCost)
+	assert.Nil(t, msft.LatestPrice)
+	assert.Nil(t, msft.MarketValue)
+	assert.True(t, msft.Stale)
+
+	assert.Equal(t, models.PortfolioTotals{
+		CostBasis:      1200,
+		MarketValue:    625,
+		UnrealizedPnL:  25,
+		RealizedPnL:    330,
+		StalePositions: 1,
+	}, summary.Totals)
+}
+
+func TestPortfolioSummary_FlagsOldPriceStale(t *testing.T) {
+	service, mock := newTestPortfolioService(t)
+	// Six trading days before the service's Wednesday
+	oldClose := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	expectPortfolioSummary(mock, 1, sqlmock.NewRows(portfolioTransactionColumns).
+		AddRow(1, "IBM", "buy", 4.0, 250.0, oldClose, 240.0, oldClose))
+
+	summary, err := service.Summary(context.Background(), 1)
+	require.NoError(t, err)
+
+	require.Len(t, summary.Positions, 1)
+	position := summary.Positions[0]
+	assert.True(t, position.Stale)
+	require.NotNil(t, position.MarketValue, "a stale price still values the position")
+	assert.Equal(t, 960.0, *position.MarketValue)
+	assert.Equal(t, -40.0, *position.UnrealizedPnL)
+	assert.Equal(t, 1, summary.Totals.StalePositions)
+}
+
+func TestPortfolioSummary_EmptyPortfolio(t *testing.T) {
+	service, mock := newTestPortfolioService(t)
+	expectPortfolioSummary(mock, 1, sqlmock.NewRows(portfolioTransactionColumns))
+
+	summary, err := service.Summary(context.Background(), 1)
+	require.NoError(t, err)
+
+	assert.NotNil(t, summary.Positions)
+	assert.Empty(t, summary.Positions)
+	assert.Equal(t, models.PortfolioTotals{}, summary.Totals)
+}
+
+func TestPortfolioSummary_NotFound(t *testing.T) {
+	service, mock := newTestPortfolioService(t)
+	mock.ExpectQuery("SELECT name, created_at, updated_at FROM portfolios").WithArgs(int64(9)).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := service.Summary(context.Background(), 9)
+	assert.ErrorIs(t, err, ErrPortfolioNotFound)
+}
+
+// expectSellLookup expects a sell of AAPL in portfolio 1 to read the stock's
+// transactions, given as side, quantity and executed_at triples
+func expectSellLookup(mock sqlmock.Sqlmock, transactions ...[3]interface{}) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM portfolios WHERE id = \$1 FOR UPDATE`).WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT id FROM stocks WHERE symbol").WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	rows := sqlmock.NewRows([]string{"side", "quantity", "executed_at"})
+	for _, transaction := range transactions {
+		rows.AddRow(transaction[0], transaction[1], transaction[2])
+	}
+	mock.ExpectQuery("SELECT side, quantity, executed_at").WithArgs(int64(1), 7).WillReturnRows(rows)
+}
+
+func TestRecordTransaction_RejectsSellingMoreThanHeld(t *testing.T) {
+	service, mock := newTestPortfolioService(t)
+	bought := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	expectSellLookup(mock, [3]interface{}{"buy", 3.0, bought})
+	mock.ExpectRollback()
+
+	_, err := service.RecordTransaction(context.Background(), 1, models.Transaction{
+		Symbol: "AAPL", Side: models.SideSell, Quantity: 5, Price: 130, ExecutedAt: bought.AddDate(0, 0, 1),
+	})
+
+	var insufficient *InsufficientQuantityError
+	require.True(t, errors.As(err, &insufficient))
+	assert.Equal(t, 3.0, insufficient.Available)
+	assert.Equal(t, 5.0, insufficient.Requested)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordTransaction_BackdatedSellCannotLeaveLaterSellShort(t *testing.T) {
+	service, mock := newTestPortfolioService(t)
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 15, 0, 0, 0, time.UTC) }
+	// 10 held from the 2nd, 8 of them sold on the 6th: a sell on the 4th may
+	// only take the 2 the later sell leaves
+	expectSellLookup(mock,
+		[3]interface{}{"buy", 10.0, day(2)},
+		[3]interface{}{"sell", 8.0, day(6)})
+	mock.ExpectRollback()
+
+	_, err := service.RecordTransaction(context.Background(), 1, models.Transaction{
+		Symbol: "AAPL", Side: models.SideSell, Quantity: 5, Price: 130, ExecutedAt: day(4),
+	})
+
+	var insufficient *InsufficientQuantityError
+	require.True(t, errors.As(err, &insufficient))
+	assert.Equal(t, 2.0, insufficient.Available)
+}
+
+func TestRecordTransaction_SellWithinHoldings(t *testing.T) {
+	service, mock := newTestPortfolioService(t)
+	bought := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	sold := bought.AddDate(0, 0, 2)
+	expectSellLookup(mock, [3]interface{}{"buy", 2.5, bought})
+	mock.ExpectQuery("INSERT INTO transactions").
+		WithArgs(int64(1), 7, models.SideSell, 2.5, 130.1235, sold).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectCommit()
+
+	transaction, err := service.RecordTransaction(context.Background(), 1, models.Transaction{
+		Symbol: "AAPL", Side: models.SideSell, Quantity: 2.5, Price: 130.12349, ExecutedAt: sold,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), transaction.ID)
+	assert.Equal(t, 130.1235, transaction.Price)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
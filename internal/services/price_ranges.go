@@ -0,0 +1,129 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultPriceRangeBoundaries are the lower bounds of each price bucket above $0
+var DefaultPriceRangeBoundaries = []float64{10, 50, 100, 150}
+
+// PriceRanges assigns a stock's latest close to a price bucket. Buckets are
+// defined only by their boundaries, so labels like "$50-100" and "$150+" always
+// agree with the prices they contain.
+type PriceRanges struct {
+	boundaries []float64
+}
+
+// NewPriceRanges creates buckets from strictly increasing, positive boundaries
+func NewPriceRanges(boundaries []float64) (*PriceRanges, error) {
+	if len(boundaries) == 0 {
+		return nil, fmt.Errorf("at least one price range boundary is required")
+	}
+	for i, b := range boundaries {
+		if b <= 0 {
+			return nil, fmt.Errorf("price range boundary %v must be positive", b)
+		}
+		if i > 0 && b <= boundaries[i-1] {
+			return nil, fmt.Errorf("price range boundaries must be increasing: %v follows %v", b, boundaries[i-1])
+		}
+	}
+
+	return &PriceRanges{boundaries: append([]float64(nil), boundaries...)}, nil
+}
+
+// DefaultPriceRanges returns the buckets used when none are configured
+func DefaultPriceRanges() *PriceRanges {
+	ranges, _ := NewPriceRanges(DefaultPriceRangeBoundaries)
+	return ranges
+}
+
+// ParsePriceRangeBoundaries parses a comma-separated list such as "10,50,100,150"
+func ParsePriceRangeBoundaries(value string) ([]float64, error) {
+	var boundaries []float64
+	for _, part := range strings.Split(value, ",") {
+		b, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price range boundary %q: %w", part, err)
+		}
+		boundaries = append(boundaries, b)
+	}
+	return boundaries, nil
+}
+
+// Bucket returns the label of the bucket containing price. Each bucket
+// includes its lower boundary, so $100.00 is in "$100-150", not "$50-100".
+func (p *PriceRanges) Bucket(price float64) string {
+	i := sort.Search(len(p.boundaries), func(i int) bool { return p.boundaries[i] > price })
+	return p.label(i)
+}
+
+// Resolve returns the bucket for a stock's latest close. Stocks without price
+// data fall back to the legacy price_range column stored on the stocks row.
+func (p *PriceRanges) Resolve(price float64, stored string) string {
+	if price > 0 {
+		return p.Bucket(price)
+	}
+	return stored
+}
+
+// Labels lists every bucket from cheapest to most expensive
+func (p *PriceRanges) Labels() []string {
+	labels := make([]string, len(p.boundaries)+1)
+	for i := range labels {
+		labels[i] = p.label(i)
+	}
+	return labels
+}
+
+// IsValid reports whether label names one of the buckets
+func (p *PriceRanges) IsValid(label string) bool {
+	for _, l := range p.Labels() {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// SQLCase returns a CASE expression bucketing priceExpr in SQL, used to refresh
+// the cached price_range column when a sync writes new prices
+func (p *PriceRanges) SQLCase(priceExpr string) string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for i := len(p.boundaries) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, " WHEN %s >= %s THEN '%s'", priceExpr, formatPrice(p.boundaries[i]), p.label(i+1))
+	}
+	fmt.Fprintf(&b, " ELSE '%s' END", p.label(0))
+	return b.String()
+}
+
+// storedColumnAssignment is an UPDATE stocks SET fragment that refreshes the
+// cached price_range column from the stock's latest close
+func (p *PriceRanges) storedColumnAssignment() string {
+	return `price_range = COALESCE((
+			SELECT ` + p.SQLCase("dp.close_price") + `
+			FROM daily_prices dp
+			WHERE dp.stock_id = stocks.id
+			ORDER BY dp.date DESC
+			LIMIT 1
+		), price_range)`
+}
+
+// label names the bucket starting at boundary i-1 (bucket 0 starts at $0)
+func (p *PriceRanges) label(i int) string {
+	if i == len(p.boundaries) {
+		return "$" + formatPrice(p.boundaries[i-1]) + "+"
+	}
+	lower := "0"
+	if i > 0 {
+		lower = formatPrice(p.boundaries[i-1])
+	}
+	return "$" + lower + "-" + formatPrice(p.boundaries[i])
+}
+
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', -1, 64)
+}
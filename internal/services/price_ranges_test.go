@@ -0,0 +1,79 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceRanges_BucketBoundaries(t *testing.T) {
+	ranges := DefaultPriceRanges()
+
+	tests := []struct {
+		price    float64
+		expected string
+	}{
+		{0.01, "$0-10"},
+		{9.99, "$0-10"},
+		{10, "$10-50"},
+		{49.99, "$10-50"},
+		{50, "$50-100"},
+		{99.99, "$50-100"},
+		{100, "$100-150"},
+		{149.99, "$100-150"},
+		{150, "$150+"},
+		{3200, "$150+"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, ranges.Bucket(tt.price), "price %v", tt.price)
+	}
+}
+
+func TestPriceRanges_Labels(t *testing.T) {
+	assert.Equal(t, []string{"$0-10", "$10-50", "$50-100", "$100-150", "$150+"}, DefaultPriceRanges().Labels())
+	assert.True(t, DefaultPriceRanges().IsValid("$50-100"))
+	assert.False(t, DefaultPriceRanges().IsValid("$100+"))
+}
+
+func TestPriceRanges_ConfiguredBoundaries(t *testing.T) {
+	boundaries, err := ParsePriceRangeBoundaries(" 25, 250.5 ")
+	require.NoError(t, err)
+
+	ranges, err := NewPriceRanges(boundaries)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"$0-25", "$25-250.5", "$250.5+"}, ranges.Labels())
+	assert.Equal(t, "$25-250.5", ranges.Bucket(150))
+	assert.Equal(t, "$250.5+", ranges.Bucket(250.5))
+}
+
+func TestPriceRanges_InvalidBoundaries(t *testing.T) {
+	_, err := ParsePriceRangeBoundaries("10,fifty")
+	assert.Error(t, err)
+
+	for _, boundaries := range [][]float64{nil, {0, 10}, {-5}, {50, 10}, {10, 10}} {
+		_, err := NewPriceRanges(boundaries)
+		assert.Error(t, err, "%v", boundaries)
+	}
+}
+
+func TestPriceRanges_ResolveFallsBackToStoredColumn(t *testing.T) {
+	ranges := DefaultPriceRanges()
+
+	// A stale stored value is ignored once the stock has a price
+	assert.Equal(t, "$100-150", ranges.Resolve(125.5, "$50-100"))
+	// Without price data the legacy stored column is all we have
+	assert.Equal(t, "$50-100", ranges.Resolve(0, "$50-100"))
+	assert.Equal(t, "", ranges.Resolve(0, ""))
+}
+
+func TestPriceRanges_SQLCase(t *testing.T) {
+	ranges, err := NewPriceRanges([]float64{50, 100})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		"CASE WHEN p >= 100 THEN '$100+' WHEN p >= 50 THEN '$50-100' ELSE '$0-50' END",
+		ranges.SQLCase("p"))
+}
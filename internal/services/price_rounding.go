@@ -0,0 +1,48 @@
+package services
+
+import (
+	"math"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// Decimal places prices are kept to, as in the NUMERIC(12,4) price columns,
+// and change percents are reported to
+const (
+	PriceDecimals   = 4
+	PercentDecimals = 2
+)
+
+// RoundPrice rounds a price or price change to PriceDecimals places, half away
+// from zero as Postgres rounds NUMERIC, so float noise such as 150.24999999
+// is neither stored nor served
+func RoundPrice(value float64) float64 {
+	return roundTo(value, PriceDecimals)
+}
+
+// RoundPercent rounds a change percent to PercentDecimals places
+func RoundPercent(value float64) float64 {
+	return roundTo(value, PercentDecimals)
+}
+
+func roundTo(value float64, decimals int) float64 {
+	scale := math.Pow10(decimals)
+	return math.Round(value*scale) / scale
+}
+
+// RoundStockPrices rounds the stock's latest price, daily change and change percent
+func RoundStockPrices(stock *models.Stock) {
+	stock.CurrentPrice = RoundPrice(stock.CurrentPrice)
+	stock.DailyChange = RoundPrice(stock.DailyChange)
+	stock.ChangePercent = RoundPercent(stock.ChangePercent)
+}
+
+// rounded returns the bar with its prices rounded as they are stored
+func (b DailyBar) rounded() DailyBar {
+	b.Open = RoundPrice(b.Open)
+	b.High = RoundPrice(b.High)
+	b.Low = RoundPrice(b.Low)
+	b.Close = RoundPrice(b.Close)
+	b.AdjustedClose = RoundPrice(b.AdjustedClose)
+	return b
+}
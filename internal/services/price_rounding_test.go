@@ -0,0 +1,43 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"stock-intelligence-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundPrice(t *testing.T) {
+	assert.Equal(t, 150.25, RoundPrice(150.24999999))
+	assert.Equal(t, 0.3, RoundPrice(0.1+0.2))
+	assert.Equal(t, 187.1235, RoundPrice(187.12345))
+	assert.Equal(t, -1.51, RoundPrice(-1.5100000000000193))
+	assert.Equal(t, 0.0, RoundPrice(0.00004))
+}
+
+func TestRoundPercent(t *testing.T) {
+	assert.Equal(t, 0.67, RoundPercent(2.0/3.0))
+	assert.Equal(t, -0.81, RoundPercent(-0.8133992636))
+	assert.Equal(t, 12.0, RoundPercent(11.999999999))
+}
+
+func TestRoundStockPrices_JSONPrecision(t *testing.T) {
+	stock := models.Stock{Symbol: "AAPL", CurrentPrice: 150.24999999, DailyChange: 0.30000000000000004, ChangePercent: 0.20007984031936127}
+	RoundStockPrices(&stock)
+
+	body, err := json.Marshal(stock)
+	require.NoError(t, err)
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(body, &fields))
+	assert.Equal(t, "150.25", string(fields["current_price"]))
+	assert.Equal(t, "0.3", string(fields["daily_change"]))
+	assert.Equal(t, "0.2", string(fields["change_percent"]))
+}
+
+func TestDailyBarRounded(t *testing.T) {
+	bar := DailyBar{Open: 10.00001, High: 10.123449, Low: 9.99995, Close: 10.1, AdjustedClose: 0, Volume: 100}.rounded()
+	assert.Equal(t, DailyBar{Open: 10.0, High: 10.1234, Low: 10.0, Close: 10.1, Volume: 100}, bar)
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// RejectedRow is a provider's row for one day that was left out rather than
+// stored, because a field could not be parsed or the prices cannot be real
+type RejectedRow struct {
+	Date   string `json:"date"`
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+func (r *RejectedRow) Error() string {
+	return fmt.Sprintf("rejected %s: %s %q %s", r.Date, r.Field, r.Value, r.Reason)
+}
+
+// ParseBar converts one day of an Alpha Vantage daily series into a bar. A
+// field that is empty or malformed is returned as a *RejectedRow.
+func (e TimeSeriesEntry) ParseBar(dateStr string) (DailyBar, error) {
+	p := rowParser{date: dateStr}
+	bar := DailyBar{
+		Date:   p.parseDate(),
+		Open:   p.parseFloat("open", e.Open),
+		High:   p.parseFloat("high", e.High),
+		Low:    p.parseFloat("low", e.Low),
+		Close:  p.parseFloat("close", e.Close),
+		Volume: p.parseInt("volume", e.Volume),
+	}
+	if p.rejected != nil {
+		return DailyBar{}, p.rejected
+	}
+	return bar, nil
+}
+
+// ParseBar converts one day of an Alpha Vantage adjusted series into a bar. A
+// field that is empty or malformed is returned as a *RejectedRow.
+func (e AdjustedTimeSeriesEntry) ParseBar(dateStr string) (DailyBar, error) {
+	p := rowParser{date: dateStr}
+	bar := DailyBar{
+		Date:             p.parseDate(),
+		Open:             p.parseFloat("open", e.Open),
+		High:             p.parseFloat("high", e.High),
+		Low:              p.parseFloat("low", e.Low),
+		Close:            p.parseFloat("close", e.Close),
+		AdjustedClose:    p.parseFloat("adjusted close", e.AdjustedClose),
+		Volume:           p.parseInt("volume", e.Volume),
+		Dividend:         p.parseFloat("dividend amount", e.DividendAmount),
+		SplitCoefficient: p.parseFloat("split coefficient", e.SplitCoefficient),
+	}
+	if p.rejected != nil {
+		return DailyBar{}, p.rejected
+	}
+	return bar, nil
+}
+
+// Validate returns a *RejectedRow for a bar whose prices cannot be real: a
+// close that is not positive, or a high below the low. Either would poison
+// change calculations.
+func (b DailyBar) Validate() error {
+	date := b.Date.Format("2006-01-02")
+	if b.Close <= 0 {
+		return &RejectedRow{Date: date, Field: "close", Value: formatPrice(b.Close), Reason: "is not positive"}
+	}
+	if b.High < b.Low {
+		return &RejectedRow{Date: date, Field: "high", Value: formatPrice(b.High), Reason: "is below low " + formatPrice(b.Low)}
+	}
+	return nil
+}
+
+// rowParser parses a row's fields, keeping the first failure
+type rowParser struct {
+	date     string
+	rejected *RejectedRow
+}
+
+func (p *rowParser) reject(field, value, reason string) {
+	if p.rejected == nil {
+		p.rejected = &RejectedRow{Date: p.date, Field: field, Value: value, Reason: reason}
+	}
+}
+
+func (p *rowParser) parseDate() time.Time {
+	date, err := parseMarketDate(p.date)
+	if err != nil {
+		p.reject("date", p.date, "is not a date")
+	}
+	return date
+}
+
+func (p *rowParser) parseFloat(field, value string) float64 {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		p.reject(field, value, "is not a number")
+	}
+	return parsed
+}
+
+func (p *rowParser) parseInt(field, value string) int64 {
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		p.reject(field, value, "is not a whole number")
+	}
+	return parsed
+}
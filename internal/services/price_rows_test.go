@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// corruptedDailyFixture has two good days and one whose close is blank
+const corruptedDailyFixture = `{
+	"Meta Data": {"2. Symbol": "ACME"},
+	"Time Series (Daily)": {
+		"2024-01-02": {"1. open": "10.00", "2. high": "10.50", "3. low": "9.80", "4. close": "10.20", "5. volume": "1000"},
+		"2024-01-03": {"1. open": "10.20", "2. high": "10.90", "3. low": "10.10", "4. close": "", "5. volume": "1200"},
+		"2024-01-04": {"1. open": "10.40", "2. high": "10.60", "3. low": "10.00", "4. close": "10.30", "5. volume": "900"}
+	}
+}`
+
+func TestToDailySeries_RejectsMalformedRows(t *testing.T) {
+	var response AlphaVantageResponse
+	require.NoError(t, json.Unmarshal([]byte(corruptedDailyFixture), &response))
+
+	series := response.toDailySeries("ACME")
+
+	assert.Len(t, series.Bars, 2)
+	require.Len(t, series.Rejected, 1)
+	assert.Equal(t, RejectedRow{Date: "2024-01-03", Field: "close", Value: "", Reason: "is not a number"}, series.Rejected[0])
+}
+
+func TestDailyBarValidate(t *testing.T) {
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, DailyBar{Date: date, High: 11, Low: 9, Close: 10}.Validate())
+
+	err := DailyBar{Date: date, High: 11, Low: 9}.Validate()
+	assert.Equal(t, &RejectedRow{Date: "2024-01-02", Field: "close", Value: "0", Reason: "is not positive"}, err)
+
+	err = DailyBar{Date: date, High: 9, Low: 11, Close: 10}.Validate()
+	assert.Equal(t, &RejectedRow{Date: "2024-01-02", Field: "high", Value: "9", Reason: "is below low 11"}, err)
+}
+
+func TestSyncSingleStock_ReportsSkippedRows(t *testing.T) {
+	fixture := map[string]string{"ACME": corruptedDailyFixture}
+	service, mock := newBatchSyncService(t, fixture)
+
+	expectLatestPriceDate(mock, nil)
+	expectRateLimitQuery(mock)
+	mock.ExpectExec("INSERT INTO api_calls").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRequestRecorded(mock, ProviderAlphaVantage)
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("ACME").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expectAdvisoryLock(mock, 1, true)
+	expectSaveBegins(mock, 1, nil)
+	// Only the two good days are written
+	args := []driver.Value{1}
+	for i := 0; i < 2*7; i++ {
+		args = append(args, sqlmock.AnyArg())
+	}
+	mock.ExpectQuery("INSERT INTO daily_prices").WithArgs(args...).WillReturnRows(insertedRows(2))
+	expectPriceCoverage(mock, 1)
+	mock.ExpectCommit()
+	expectAdvisoryUnlock(mock, 1)
+	mock.ExpectExec("UPDATE stocks[\\s\\S]+has_sufficient_data").WithArgs("ACME").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	result := service.syncSingleStock(context.Background(), SP500Stock{Symbol: "ACME"})
+
+	assert.True(t, result.Success)
+	assert.Equal(t, 2, result.RecordsAdded)
+	require.Len(t, result.RowsSkipped, 1)
+	assert.Equal(t, "2024-01-03", result.RowsSkipped[0].Date)
+	assert.Equal(t, "close", result.RowsSkipped[0].Field)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveHistoricalData_RejectsImpossiblePrices(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	series := &DailySeries{Symbol: "ACME", Bars: []DailyBar{
+		{Date: day(2), Open: 10, High: 10.5, Low: 9.8, Close: 10.2},
+		{Date: day(3), Open: 10, High: 10.5, Low: 9.8, Close: 0},
+		{Date: day(4), Open: 10, High: 9.5, Low: 10.1, Close: 10},
+	}}
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("ACME").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expectAdvisoryLock(mock, 1, true)
+	expectSaveBegins(mock, 1, nil)
+	mock.ExpectQuery("INSERT INTO daily_prices").
+		WithArgs(1, day(2), 10.0, 10.5, 9.8, 10.2, 10.2, int64(0)).
+		WillReturnRows(insertedRows(1))
+	expectPriceCoverage(mock, 1)
+	mock.ExpectCommit()
+	expectAdvisoryUnlock(mock, 1)
+
+	stats, err := NewAlphaVantageClient("test-key", db).SaveHistoricalData(context.Background(), "ACME", series, SyncLockWait)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Inserted)
+	require.Len(t, stats.Rejected, 2)
+	assert.Equal(t, "close", stats.Rejected[0].Field)
+	assert.Equal(t, "high", stats.Rejected[1].Field)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
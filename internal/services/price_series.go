@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PriceResolution is the period each bar of a price series covers
+type PriceResolution string
+
+const (
+	// PriceResolutionDaily serves the stored daily bars as they are
+	PriceResolutionDaily PriceResolution = "daily"
+	// PriceResolutionWeekly aggregates ISO weeks, Monday to Sunday
+	PriceResolutionWeekly PriceResolution = "weekly"
+	// PriceResolutionMonthly aggregates calendar months
+	PriceResolutionMonthly PriceResolution = "monthly"
+)
+
+// PriceResolutions lists the resolutions a price series accepts
+var PriceResolutions = []string{string(PriceResolutionDaily), string(PriceResolutionWeekly), string(PriceResolutionMonthly)}
+
+// ParsePriceResolution returns the resolution called s, PriceResolutionDaily
+// when s is empty
+func ParsePriceResolution(s string) (PriceResolution, error) {
+	switch resolution := PriceResolution(strings.ToLower(strings.TrimSpace(s))); resolution {
+	case "":
+		return PriceResolutionDaily, nil
+	case PriceResolutionDaily, PriceResolutionWeekly, PriceResolutionMonthly:
+		return resolution, nil
+	default:
+		return "", fmt.Errorf("resolution must be one of %s", strings.Join(PriceResolutions, ", "))
+	}
+}
+
+// truncField is the date_trunc field grouping the resolution's days. Postgres
+// truncates to ISO weeks, so the days of a week spanning New Year share a bar.
+func (r PriceResolution) truncField() string {
+	switch r {
+	case PriceResolutionWeekly:
+		return "week"
+	case PriceResolutionMonthly:
+		return "month"
+	default:
+		return "day"
+	}
+}
+
+// periodEnd returns the last day of the period starting at start
+func (r PriceResolution) periodEnd(start time.Time) time.Time {
+	switch r {
+	case PriceResolutionWeekly:
+		return start.AddDate(0, 0, 6)
+	case PriceResolutionMonthly:
+		return start.AddDate(0, 1, -1)
+	default:
+		return start
+	}
+}
+
+// PriceBar is a stock's prices over one period: the first open, highest high,
+// lowest low and last close of the period's trading days, and their total
+// volume. A partial bar lacks some of the period's days, either because the
+// period has not ended yet or because the requested range cuts it short.
+type PriceBar struct {
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	Open        float64   `json:"open"`
+	High        float64   `json:"high"`
+	Low         float64   `json:"low"`
+	Close       float64   `json:"close"`
+	Volume      int64     `json:"volume"`
+	TradingDays int       `json:"trading_days"`
+	Partial     bool      `json:"partial"`
+}
+
+// GetPriceSeries returns a stock's prices between from and to, inclusive, as
+// one bar per period of resolution, oldest first. A zero from or to leaves
+// that end of the range open. Periods without a trading day in the range
+// have no bar. ErrStockNotFound is returned when symbol is neither an active
+// nor a delisted stock.
+func (d *DatabaseStockService) GetPriceSeries(ctx context.Context, symbol string, resolution PriceResolution, from, to time.Time) ([]PriceBar, error) {
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+
+	var fromDate, toDate interface{}
+	if !from.IsZero() {
+		fromDate = from.Format("2006-01-02")
+	}
+	if !to.IsZero() {
+		toDate = to.Format("2006-01-02")
+	}
+
+	// One row with NULL bar columns tells a stock without prices in range from an unknown symbol
+	rows, err := d.router.ReaderFor(symbol).QueryContext(ctx, `
+		SELECT p.period_start, p.open_price, p.high_price, p.low_price, p.close_price, p.volume, p.trading_days
+		FROM stocks s
+		LEFT JOIN LATERAL (
+			SELECT date_trunc($2, dp.date::timestamp)::date AS period_start,
+				(array_agg(dp.open_price ORDER BY dp.date))[1] AS open_price,
+				MAX(dp.high_price) AS high_price,
+				MIN(dp.low_price) AS low_price,
+				(array_agg(dp.close_price ORDER BY dp.date DESC))[1] AS close_price,
+				SUM(dp.volume)::bigint AS volume,
+				COUNT(*) AS trading_days
+			FROM daily_prices dp
+			WHERE dp.stock_id = s.id
+				AND ($3::date IS NULL OR dp.date >= $3::date)
+				AND ($4::date IS NULL OR dp.date <= $4::date)
+			GROUP BY 1
+		) p ON true
+		WHERE s.symbol = $1 AND (s.is_active = true OR s.delisted_at IS NOT NULL)
+		ORDER BY p.period_start
+	`, symbol, resolution.truncField(), fromDate, toDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price series: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+
+	found := false
+	bars := []PriceBar{}
+	for rows.Next() {
+		found = true
+		var periodStart sql.NullTime
+		var open, high, low, closePrice sql.NullFloat64
+		var volume, tradingDays sql.NullInt64
+		if err := rows.Scan(&periodStart, &open, &high, &low, &closePrice, &volume, &tradingDays); err != nil {
+			return nil, fmt.Errorf("failed to scan price bar: %w", err)
+		}
+		if periodStart.Valid {
+			bars = append(bars, PriceBar{
+				PeriodStart: periodStart.Time,
+				PeriodEnd:   resolution.periodEnd(periodStart.Time),
+				Open:        open.Float64,
+				High:        high.Float64,
+				Low:         low.Float64,
+				Close:       closePrice.Float64,
+				Volume:      volume.Int64,
+				TradingDays: int(tradingDays.Int64),
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read price series: %w", queryError(ctx, err))
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrStockNotFound, symbol)
+	}
+
+	markPartialBars(bars, from, to, time.Now().UTC())
+	return bars, nil
+}
+
+// markPartialBars flags the bars whose period has not ended before today or
+// reaches outside the from-to range
+func markPartialBars(bars []PriceBar, from, to, today time.Time) {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	for i := range bars {
+		bar := &bars[i]
+		bar.Partial = !bar.PeriodEnd.Before(today) ||
+			(!from.IsZero() && bar.PeriodStart.Before(from)) ||
+			(!to.IsZero() && bar.PeriodEnd.After(to))
+	}
+}
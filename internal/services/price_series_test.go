@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var priceBarColumns = []string{"period_start", "open_price", "high_price", "low_price", "close_price", "volume", "trading_days"}
+
+func TestParsePriceResolution(t *testing.T) {
+	for input, want := range map[string]PriceResolution{"": PriceResolutionDaily, "weekly": PriceResolutionWeekly, "Monthly": PriceResolutionMonthly} {
+		resolution, err := ParsePriceResolution(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, resolution, input)
+	}
+
+	_, err := ParsePriceResolution("hourly")
+	assert.EqualError(t, err, "resolution must be one of daily, weekly, monthly")
+}
+
+func TestGetPriceSeries_WeekSpanningYearEnd(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+	from := time.Date(2024, time.December, 23, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, time.January, 8, 0, 0, 0, 0, time.UTC)
+
+	// ISO week 1 of 2025 starts on Monday 2024-12-30
+	mock.ExpectQuery(`date_trunc\(\$2, dp.date::timestamp\)`).WithArgs("AAPL", "week", "2024-12-23", "2025-01-08").
+		WillReturnRows(sqlmock.NewRows(priceBarColumns).
+			AddRow(from, 255.49, 260.10, 252.06, 259.02, int64(150000000), 4).
+			AddRow(time.Date(2024, time.December, 30, 0, 0, 0, 0, time.UTC), 252.23, 253.50, 241.82, 243.36, int64(200000000), 4).
+			AddRow(time.Date(2025, time.January, 6, 0, 0, 0, 0, time.UTC), 244.31, 247.33, 241.35, 242.70, int64(90000000), 2))
+
+	bars, err := service.GetPriceSeries(context.Background(), "AAPL", PriceResolutionWeekly, from, to)
+
+	require.NoError(t, err)
+	require.Len(t, bars, 3)
+	assert.Equal(t, time.Date(2025, time.January, 5, 0, 0, 0, 0, time.UTC), bars[1].PeriodEnd)
+	assert.Equal(t, 243.36, bars[1].Close)
+	assert.False(t, bars[0].Partial, "the range starts on the week's Monday")
+	assert.False(t, bars[1].Partial)
+	assert.True(t, bars[2].Partial, "the range ends on the week's Wednesday")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPriceSeries_NoTradingDaysInRange(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+	february := time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)
+
+	// A stock without prices in range has no bars; an unknown one is not found
+	mock.ExpectQuery("LEFT JOIN LATERAL").WithArgs("AAPL", "month", "2025-02-01", nil).
+		WillReturnRows(sqlmock.NewRows(priceBarColumns).AddRow(nil, nil, nil, nil, nil, nil, nil))
+	bars, err := service.GetPriceSeries(context.Background(), "AAPL", PriceResolutionMonthly, february, time.Time{})
+	require.NoError(t, err)
+	assert.NotNil(t, bars)
+	assert.Empty(t, bars)
+
+	mock.ExpectQuery("LEFT JOIN LATERAL").WithArgs("ZZZZ", "day", nil, nil).WillReturnRows(sqlmock.NewRows(priceBarColumns))
+	_, err = service.GetPriceSeries(context.Background(), "ZZZZ", PriceResolutionDaily, time.Time{}, time.Time{})
+	assert.ErrorIs(t, err, ErrStockNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkPartialBars_CurrentPeriod(t *testing.T) {
+	march := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	bars := []PriceBar{{PeriodStart: march, PeriodEnd: PriceResolutionMonthly.periodEnd(march)}}
+	assert.Equal(t, time.Date(2026, time.March, 31, 0, 0, 0, 0, time.UTC), bars[0].PeriodEnd)
+
+	markPartialBars(bars, time.Time{}, time.Time{}, time.Date(2026, time.March, 31, 18, 0, 0, 0, time.UTC))
+	assert.True(t, bars[0].Partial, "the month's last day is still running")
+
+	markPartialBars(bars, time.Time{}, time.Time{}, time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC))
+	assert.False(t, bars[0].Partial)
+}
@@ -0,0 +1,441 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// Formats daily prices are exported and imported in
+const (
+	PriceFormatCSV  = "csv"
+	PriceFormatJSON = "json"
+)
+
+// PriceFormats lists the formats daily prices can be exported and imported in
+var PriceFormats = []string{PriceFormatCSV, PriceFormatJSON}
+
+// PriceFormatForPath returns the format a file's extension names, CSV unless it is .json
+func PriceFormatForPath(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return PriceFormatJSON
+	}
+	return PriceFormatCSV
+}
+
+// priceColumns are an export's columns, in order; adjusted_close may be left
+// out of an import, which stores the prices as unadjusted
+var priceColumns = []string{"symbol", "date", "open", "high", "low", "close", "adjusted_close", "volume"}
+
+// priceRecord is one stock's prices for a day as exported. Numbers are kept
+// as text, so prices are written exactly as stored.
+type priceRecord struct {
+	Symbol        string      `json:"symbol"`
+	Date          string      `json:"date"`
+	Open          json.Number `json:"open"`
+	High          json.Number `json:"high"`
+	Low           json.Number `json:"low"`
+	Close         json.Number `json:"close"`
+	AdjustedClose json.Number `json:"adjusted_close,omitempty"`
+	Volume        json.Number `json:"volume"`
+}
+
+// bar parses and validates a record as SaveHistoricalData does a provider's
+// row; a malformed or impossible record is a *RejectedRow
+func (r priceRecord) bar() (DailyBar, error) {
+	p := rowParser{date: r.Date}
+	bar := DailyBar{
+		Date:   p.parseDate(),
+		Open:   p.parseFloat("open", string(r.Open)),
+		High:   p.parseFloat("high", string(r.High)),
+		Low:    p.parseFloat("low", string(r.Low)),
+		Close:  p.parseFloat("close", string(r.Close)),
+		Volume: p.parseInt("volume", string(r.Volume)),
+	}
+	if r.AdjustedClose != "" {
+		bar.AdjustedClose = p.parseFloat("adjusted_close", string(r.AdjustedClose))
+	}
+	if p.rejected != nil {
+		return DailyBar{}, p.rejected
+	}
+	bar = bar.rounded()
+	return bar, bar.Validate()
+}
+
+// ExportDailyPrices writes the daily prices of symbol, or of every stock when
+// symbol is empty, to w as CSV or a JSON array, by symbol and then date. Every
+// row names its symbol, so an export of any scope can be imported. Rows are
+// streamed from the database as they are written. It returns how many rows
+// were written.
+func ExportDailyPrices(ctx context.Context, db *sql.DB, w io.Writer, symbol, format string) (int, error) {
+	if !slices.Contains(PriceFormats, format) {
+		return 0, fmt.Errorf("unsupported price format %q", format)
+	}
+	if symbol != "" {
+		var exists bool
+		if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM stocks WHERE symbol = $1)`, symbol).Scan(&exists); err != nil {
+			return 0, fmt.Errorf("failed to look up %s: %w", symbol, err)
+		}
+		if !exists {
+			return 0, fmt.Errorf("%w: %s", ErrStockNotFound, symbol)
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.symbol, dp.date, dp.open_price, dp.high_price, dp.low_price,
+		       dp.close_price, dp.adjusted_close, dp.volume
+		FROM daily_prices dp
+		JOIN stocks s ON s.id = dp.stock_id
+		WHERE $1 = '' OR s.symbol = $1
+		ORDER BY s.symbol, dp.date
+	`, symbol)
+	if err != nil {
+		return 0, fmt.Errorf("failed to export daily prices: %w", err)
+	}
+	defer rows.Close()
+
+	encoder := newPriceEncoder(w, format)
+	written := 0
+	for rows.Next() {
+		var record priceRecord
+		var date time.Time
+		var open, high, low, closePrice, adjustedClose, volume string
+		if err := rows.Scan(&record.Symbol, &date, &open, &high, &low, &closePrice, &adjustedClose, &volume); err != nil {
+			return written, fmt.Errorf("failed to scan daily price: %w", err)
+		}
+		record.Date = date.Format("2006-01-02")
+		record.Open, record.High, record.Low = json.Number(open), json.Number(high), json.Number(low)
+		record.Close, record.AdjustedClose, record.Volume = json.Number(closePrice), json.Number(adjustedClose), json.Number(volume)
+		if err := encoder.write(record); err != nil {
+			return written, fmt.Errorf("failed to write daily price: %w", err)
+		}
+		written++
+	}
+	if err := rows.Err(); err != nil {
+		return written, fmt.Errorf("failed to export daily prices: %w", err)
+	}
+	return written, encoder.close()
+}
+
+// priceEncoder writes an export one record at a time
+type priceEncoder interface {
+	write(record priceRecord) error
+	close() error
+}
+
+func newPriceEncoder(w io.Writer, format string) priceEncoder {
+	if format == PriceFormatJSON {
+		return &jsonPriceEncoder{w: w}
+	}
+	return &csvPriceEncoder{w: csv.NewWriter(w)}
+}
+
+type csvPriceEncoder struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (e *csvPriceEncoder) write(r priceRecord) error {
+	if !e.headerWritten {
+		e.headerWritten = true
+		if err := e.w.Write(priceColumns); err != nil {
+			return err
+		}
+	}
+	return e.w.Write([]string{r.Symbol, r.Date, string(r.Open), string(r.High), string(r.Low),
+		string(r.Close), string(r.AdjustedClose), string(r.Volume)})
+}
+
+func (e *csvPriceEncoder) close() error {
+	if !e.headerWritten {
+		e.headerWritten = true
+		if err := e.w.Write(priceColumns); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// jsonPriceEncoder writes a JSON array, one record per line
+type jsonPriceEncoder struct {
+	w       io.Writer
+	written int
+}
+
+func (e *jsonPriceEncoder) write(r priceRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	separator := ",\n"
+	if e.written == 0 {
+		separator = "[\n"
+	}
+	e.written++
+	_, err = fmt.Fprintf(e.w, "%s%s", separator, data)
+	return err
+}
+
+func (e *jsonPriceEncoder) close() error {
+	end := "\n]\n"
+	if e.written == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// PriceImportStats counts what an import did with its rows
+type PriceImportStats struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	// Skipped rows had a malformed field or prices that cannot be real
+	Skipped int `json:"skipped"`
+}
+
+// ImportDailyPrices upserts daily prices in the form ExportDailyPrices writes
+// them, with the bulk writer SaveHistoricalData uses, in one transaction. Rows
+// are read as they are written, savePriceBatchSize days of a stock at a time.
+// A row with a malformed date or number, or prices that cannot be real, is
+// logged and skipped. A symbol missing from stocks fails the import, and
+// nothing is written.
+func ImportDailyPrices(ctx context.Context, db *sql.DB, r io.Reader, format string) (PriceImportStats, error) {
+	var stats PriceImportStats
+	decoder, err := newPriceDecoder(r, format)
+	if err != nil {
+		return stats, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return stats, fmt.Errorf("failed to begin price import: %w", err)
+	}
+	defer tx.Rollback()
+
+	stockIDs := make(map[string]int)
+	var symbol string
+	var bars []DailyBar
+	flush := func() error {
+		if len(bars) == 0 {
+			return nil
+		}
+		unique := uniqueBarsByDate(bars)
+		bars = bars[:0]
+		inserted, failed, err := writeDailyPrices(tx, stockIDs[symbol], unique)
+		if err != nil {
+			return fmt.Errorf("failed to import %s prices from %s to %s: %w", symbol,
+				failed[0].Date.Format("2006-01-02"), failed[len(failed)-1].Date.Format("2006-01-02"), err)
+		}
+		stats.Inserted += inserted
+		stats.Updated += len(unique) - inserted
+
+		first, last := unique[0].Date, unique[0].Date
+		for _, bar := range unique {
+			if bar.Date.Before(first) {
+				first = bar.Date
+			}
+			if bar.Date.After(last) {
+				last = bar.Date
+			}
+		}
+		if err := updatePriceCoverage(tx, stockIDs[symbol], first, last, false); err != nil {
+			return fmt.Errorf("failed to update price coverage for %s: %w", symbol, err)
+		}
+		return nil
+	}
+
+	for {
+		record, line, err := decoder.next()
+		if err == io.EOF {
+			break
+		}
+		var rejected *RejectedRow
+		if errors.As(err, &rejected) {
+			slog.WarnContext(ctx, "Skipped price row", "line", line, "date", rejected.Date,
+				"field", rejected.Field, "value", rejected.Value, "reason", rejected.Reason)
+			stats.Skipped++
+			continue
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		rowSymbol, err := models.NormalizeSymbol(record.Symbol)
+		if err != nil {
+			slog.WarnContext(ctx, "Skipped price row", "line", line, "date", record.Date, "field", "symbol", "error", err)
+			stats.Skipped++
+			continue
+		}
+		bar, err := record.bar()
+		if err != nil {
+			rejected := err.(*RejectedRow)
+			slog.WarnContext(ctx, "Skipped price row", "line", line, "symbol", rowSymbol, "date", rejected.Date,
+				"field", rejected.Field, "value", rejected.Value, "reason", rejected.Reason)
+			stats.Skipped++
+			continue
+		}
+
+		if rowSymbol != symbol || len(bars) == savePriceBatchSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+			symbol = rowSymbol
+		}
+		if _, ok := stockIDs[symbol]; !ok {
+			var id int
+			err := tx.QueryRowContext(ctx, `SELECT id FROM stocks WHERE symbol = $1`, symbol).Scan(&id)
+			if errors.Is(err, sql.ErrNoRows) {
+				return stats, fmt.Errorf("line %d: %w: %s is not in stocks", line, ErrStockNotFound, symbol)
+			}
+			if err != nil {
+				return stats, fmt.Errorf("failed to look up %s: %w", symbol, err)
+			}
+			stockIDs[symbol] = id
+		}
+		bars = append(bars, bar)
+	}
+	if err := flush(); err != nil {
+		return stats, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return stats, fmt.Errorf("failed to commit price import: %w", err)
+	}
+	return stats, nil
+}
+
+// priceDecoder reads an import one record at a time, with the line it started
+// on, or for JSON its position in the array. A record that cannot be read is a
+// *RejectedRow; io.EOF ends the import.
+type priceDecoder interface {
+	next() (priceRecord, int, error)
+}
+
+func newPriceDecoder(r io.Reader, format string) (priceDecoder, error) {
+	switch format {
+	case PriceFormatCSV:
+		return newCSVPriceDecoder(r)
+	case PriceFormatJSON:
+		return newJSONPriceDecoder(r)
+	default:
+		return nil, fmt.Errorf("unsupported price format %q", format)
+	}
+}
+
+type csvPriceDecoder struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVPriceDecoder(r io.Reader) (*csvPriceDecoder, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("price CSV is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(priceColumns, name) {
+			return nil, fmt.Errorf("price CSV has an unknown column %q; expected %s", name, strings.Join(priceColumns, ", "))
+		}
+		columns[name] = i
+	}
+	for _, name := range priceColumns {
+		if _, ok := columns[name]; !ok && name != "adjusted_close" {
+			return nil, fmt.Errorf("price CSV has no %s column", name)
+		}
+	}
+	return &csvPriceDecoder{reader: reader, columns: columns}, nil
+}
+
+func (d *csvPriceDecoder) next() (priceRecord, int, error) {
+	fields, err := d.reader.Read()
+	if err == io.EOF {
+		return priceRecord{}, 0, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return priceRecord{}, parseErr.Line, &RejectedRow{Field: "row", Reason: parseErr.Err.Error()}
+	}
+	if err != nil {
+		return priceRecord{}, 0, fmt.Errorf("failed to read prices: %w", err)
+	}
+	line, _ := d.reader.FieldPos(0)
+
+	field := func(name string) string {
+		if i, ok := d.columns[name]; ok {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+	record := priceRecord{Date: field("date")}
+	if len(fields) != len(d.columns) {
+		return record, line, &RejectedRow{Date: record.Date, Field: "row",
+			Reason: fmt.Sprintf("has %d fields, expected %d", len(fields), len(d.columns))}
+	}
+	record.Symbol = field("symbol")
+	record.Open, record.High, record.Low = json.Number(field("open")), json.Number(field("high")), json.Number(field("low"))
+	record.Close, record.AdjustedClose = json.Number(field("close")), json.Number(field("adjusted_close"))
+	record.Volume = json.Number(field("volume"))
+	return record, line, nil
+}
+
+type jsonPriceDecoder struct {
+	decoder  *json.Decoder
+	position int
+	done     bool
+}
+
+func newJSONPriceDecoder(r io.Reader) (*jsonPriceDecoder, error) {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err == io.EOF {
+		return nil, errors.New("price JSON is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price JSON: %w", err)
+	}
+	if token != json.Delim('[') {
+		return nil, errors.New("price JSON is not an array")
+	}
+	return &jsonPriceDecoder{decoder: decoder}, nil
+}
+
+func (d *jsonPriceDecoder) next() (priceRecord, int, error) {
+	if d.done || !d.decoder.More() {
+		d.done = true
+		return priceRecord{}, 0, io.EOF
+	}
+	d.position++
+	var record priceRecord
+	err := d.decoder.Decode(&record)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return priceRecord{}, d.position, fmt.Errorf("failed to read price %d: %w", d.position, err)
+	}
+	if err != nil {
+		// A well-formed value that does not fit is skipped; the rest can still be read
+		return record, d.position, &RejectedRow{Date: record.Date, Field: "record", Reason: err.Error()}
+	}
+	return record, d.position, nil
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var exportColumns = []string{"symbol", "date", "open_price", "high_price", "low_price", "close_price", "adjusted_close", "volume"}
+
+func exportRows() *sqlmock.Rows {
+	return sqlmock.NewRows(exportColumns).
+		AddRow("AAPL", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "187.1500", "188.4400", "183.8900", "185.6400", "184.9000", "82488700").
+		AddRow("MSFT", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "373.8600", "375.9000", "366.7700", "370.8700", "370.8700", "25258600")
+}
+
+func TestExportDailyPrices(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: PriceFormatCSV, want: `symbol,date,open,high,low,close,adjusted_close,volume
+AAPL,2024-01-02,187.1500,188.4400,183.8900,185.6400,184.9000,82488700
+MSFT,2024-01-02,373.8600,375.9000,366.7700,370.8700,370.8700,25258600
+`},
+		{format: PriceFormatJSON, want: `[
+{"symbol":"AAPL","date":"2024-01-02","open":187.1500,"high":188.4400,"low":183.8900,"close":185.6400,"adjusted_close":184.9000,"volume":82488700},
+{"symbol":"MSFT","date":"2024-01-02","open":373.8600,"high":375.9000,"low":366.7700,"close":370.8700,"adjusted_close":370.8700,"volume":25258600}
+]
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			mock.ExpectQuery("FROM daily_prices dp").WithArgs("").WillReturnRows(exportRows())
+
+			var out bytes.Buffer
+			written, err := ExportDailyPrices(context.Background(), db, &out, "", tt.format)
+
+			require.NoError(t, err)
+			assert.Equal(t, 2, written)
+			assert.Equal(t, tt.want, out.String())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestExportDailyPrices_UnknownSymbol(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("NOPE").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	var out bytes.Buffer
+	_, err = ExportDailyPrices(context.Background(), db, &out, "NOPE", PriceFormatCSV)
+
+	assert.ErrorIs(t, err, ErrStockNotFound)
+	assert.Empty(t, out.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportDailyPrices_SkipsMalformedRows(t *testing.T) {
+	tests := []struct {
+		format string
+		input  string
+	}{
+		{format: PriceFormatCSV, input: `symbol,date,open,high,low,close,adjusted_close,volume
+aapl,2024-01-02,187.15,188.44,183.89,185.64,184.90,82488700
+AAPL,2024-01-03,184.22,185.88,183.43,184.25,,58414500
+AAPL,2024-02-30,184.22,185.88,183.43,184.25,,58414500
+AAPL,2024-01-04,182.15,183.08,180.88,lots,,71983600
+AAPL,2024-01-05,181.99,179.00,182.76,181.18,,62303300
+AAPL,2024-01-08
+`},
+		{format: PriceFormatJSON, input: `[
+{"symbol":"aapl","date":"2024-01-02","open":187.15,"high":188.44,"low":183.89,"close":185.64,"adjusted_close":184.90,"volume":82488700},
+{"symbol":"AAPL","date":"2024-01-03","open":184.22,"high":185.88,"low":183.43,"close":184.25,"volume":58414500},
+{"symbol":"AAPL","date":"2024-02-30","open":184.22,"high":185.88,"low":183.43,"close":184.25,"volume":58414500},
+{"symbol":"AAPL","date":"2024-01-04","open":182.15,"high":183.08,"low":180.88,"close":"lots","volume":71983600},
+{"symbol":"AAPL","date":"2024-01-05","open":181.99,"high":179.00,"low":182.76,"close":181.18,"volume":62303300},
+{"symbol":"AAPL","date":"2024-01-08","open":{}}
+]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			// The unadjusted day is written before the adjusted one
+			mock.ExpectQuery("INSERT INTO daily_prices").
+				WithArgs(1, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), 184.22, 185.88, 183.43, 184.25, 184.25, int64(58414500)).
+				WillReturnRows(insertedRow())
+			mock.ExpectQuery("INSERT INTO daily_prices").
+				WithArgs(1, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), 187.15, 188.44, 183.89, 185.64, 184.90, int64(82488700)).
+				WillReturnRows(sqlmock.NewRows([]string{"inserted"}).AddRow(false))
+			mock.ExpectExec(`UPDATE stocks\s+SET first_price_date`).
+				WithArgs(1, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), false).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			stats, err := ImportDailyPrices(context.Background(), db, strings.NewReader(tt.input), tt.format)
+
+			require.NoError(t, err)
+			assert.Equal(t, PriceImportStats{Inserted: 1, Updated: 1, Skipped: 4}, stats)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImportDailyPrices_SymbolMissingFromStocks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO daily_prices").WillReturnRows(insertedRow())
+	expectPriceCoverage(mock, 1)
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("GONE").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	_, err = ImportDailyPrices(context.Background(), db, strings.NewReader(`symbol,date,open,high,low,close,volume
+AAPL,2024-01-02,187.15,188.44,183.89,185.64,82488700
+GONE,2024-01-02,10,11,9,10.5,1000
+`), PriceFormatCSV)
+
+	assert.ErrorIs(t, err, ErrStockNotFound)
+	assert.ErrorContains(t, err, "line 3")
+	// The AAPL rows written before GONE was reached are rolled back with it
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportDailyPrices_RejectsFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		want   string
+	}{
+		{name: "empty csv", format: PriceFormatCSV, input: "", want: "empty"},
+		{name: "missing column", format: PriceFormatCSV, input: "symbol,date,open,high,low,close\n", want: "no volume column"},
+		{name: "unknown column", format: PriceFormatCSV, input: "symbol,date,price\n", want: `unknown column "price"`},
+		{name: "empty json", format: PriceFormatJSON, input: "", want: "empty"},
+		{name: "json object", format: PriceFormatJSON, input: `{"symbol":"AAPL"}`, want: "not an array"},
+		{name: "unknown format", format: "xml", input: "", want: "unsupported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			_, err = ImportDailyPrices(context.Background(), db, strings.NewReader(tt.input), tt.format)
+
+			assert.ErrorContains(t, err, tt.want)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPriceFormatForPath(t *testing.T) {
+	assert.Equal(t, PriceFormatJSON, PriceFormatForPath("prices.JSON"))
+	assert.Equal(t, PriceFormatCSV, PriceFormatForPath("prices.csv"))
+	assert.Equal(t, PriceFormatCSV, PriceFormatForPath("-"))
+}
@@ -0,0 +1,640 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// providerUsage records API calls, enforces rate limits and stores fetched prices
+// for one market data provider. Its api_rate_limits and api_calls rows are keyed
+// by the provider's service name so each provider has its own budget.
+type providerUsage struct {
+	serviceName    string
+	db             *sql.DB
+	rateLimiter    *RateLimiter
+	pacer          *TokenBucket
+	client         *http.Client
+	callsPerMinute int
+	maxAttempts    int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+	lockTimeout    time.Duration
+	// maxBodyBytes is how much of each response body api_calls keeps
+	maxBodyBytes int
+	logger       *slog.Logger
+}
+
+func newProviderUsage(serviceName string, db *sql.DB, callsPerMinute int) *providerUsage {
+	return &providerUsage{
+		serviceName: serviceName,
+		db:          db,
+		rateLimiter: NewRateLimiter(db, serviceName),
+		pacer:       NewTokenBucket(callsPerMinute),
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		callsPerMinute: callsPerMinute,
+		maxAttempts:    DefaultMaxAttempts,
+		retryBaseDelay: DefaultRetryBaseDelay,
+		retryMaxDelay:  DefaultRetryMaxDelay,
+		lockTimeout:    DefaultSyncLockTimeout,
+		maxBodyBytes:   DefaultMaxStoredBodyBytes,
+		logger:         slog.Default(),
+	}
+}
+
+// Name returns the provider's service name, as used in api_rate_limits
+func (u *providerUsage) Name() string {
+	return u.serviceName
+}
+
+// SetCallsPerMinute sets the pacing requests are held to and that is used to
+// compute when the next call is allowed
+func (u *providerUsage) SetCallsPerMinute(callsPerMinute int) {
+	if callsPerMinute > 0 {
+		u.callsPerMinute = callsPerMinute
+		u.pacer.SetRate(callsPerMinute)
+	}
+}
+
+// SetRetryPolicy sets how many attempts a request gets and the backoff between them
+func (u *providerUsage) SetRetryPolicy(maxAttempts int, baseDelay, maxDelay time.Duration) {
+	if maxAttempts > 0 {
+		u.maxAttempts = maxAttempts
+	}
+	if baseDelay > 0 {
+		u.retryBaseDelay = baseDelay
+	}
+	if maxDelay > 0 {
+		u.retryMaxDelay = maxDelay
+	}
+}
+
+// SetLogger sets the logger for the provider's requests and saves
+func (u *providerUsage) SetLogger(logger *slog.Logger) {
+	u.logger = logger
+}
+
+// SetSyncLockTimeout sets how long SaveHistoricalData waits for another writer of the same symbol
+func (u *providerUsage) SetSyncLockTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		u.lockTimeout = timeout
+	}
+}
+
+// SetMaxStoredBodyBytes sets how much of each response body is kept in api_calls
+func (u *providerUsage) SetMaxStoredBodyBytes(maxBytes int) {
+	if maxBytes > 0 {
+		u.maxBodyBytes = maxBytes
+	}
+}
+
+// CanMakeRequest checks if we can make an API call based on rate limits
+func (u *providerUsage) CanMakeRequest() (bool, error) {
+	return u.rateLimiter.CanMakeRequest(u.callsPerMinute)
+}
+
+// GetAPIBudget returns remaining daily and hourly calls and when the next call is allowed
+func (u *providerUsage) GetAPIBudget() (*APIBudget, error) {
+	rateLimit, err := u.GetRateLimit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate limit: %w", err)
+	}
+
+	var lastCall sql.NullTime
+	err = u.db.QueryRow(`
+		SELECT MAX(created_at) FROM api_calls WHERE service_name = $1
+	`, u.serviceName).Scan(&lastCall)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last API call time: %w", err)
+	}
+
+	var lastCallAt *time.Time
+	if lastCall.Valid {
+		lastCallAt = &lastCall.Time
+	}
+
+	budget := ComputeAPIBudget(rateLimit, lastCallAt, u.callsPerMinute, time.Now())
+	return &budget, nil
+}
+
+// LogAPICall logs an API call to the database, attributed to its initiator. It
+// counts against the rate limit only when the provider answered (status is not
+// zero); a request that never left, e.g. on a connection error, is free.
+func (u *providerUsage) LogAPICall(initiator Initiator, endpoint string, params map[string]string,
+	status int, responseBody, errorMsg string, processingTime time.Duration) error {
+
+	if err := u.insertAPICall(initiator, endpoint, params, status, responseBody, errorMsg, processingTime); err != nil {
+		return err
+	}
+
+	if status == 0 {
+		return nil
+	}
+	_, _, err := u.rateLimiter.RecordRequest()
+	return err
+}
+
+// insertAPICall records an API call without touching the rate limit counters.
+// The response body is cut to the stored maximum, keeping its whole length.
+func (u *providerUsage) insertAPICall(initiator Initiator, endpoint string, params map[string]string,
+	status int, responseBody, errorMsg string, processingTime time.Duration) error {
+
+	paramsJSON, _ := json.Marshal(params)
+
+	query := `
+		INSERT INTO api_calls (service_name, endpoint, request_params, response_status,
+		                      response_body, error_message, processing_time_ms, initiated_by,
+		                      response_body_length)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := u.db.Exec(query, u.serviceName, endpoint, paramsJSON, status,
+		truncateBody(responseBody, u.maxBodyBytes), errorMsg,
+		int(processingTime.Milliseconds()), string(initiator), len(responseBody))
+
+	if err != nil {
+		u.logger.Error("Failed to log API call", "provider", u.serviceName, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// makeRequest requests baseURL with params, retrying transient failures with
+// exponential backoff. Every attempt waits its turn under the per-minute pacing
+// and is logged, but a logical fetch spends a single call of budget, and none
+// when no attempt reached the provider. A cancelled ctx ends the request and
+// is not retried.
+func (u *providerUsage) makeRequest(ctx context.Context, initiator Initiator, endpoint, baseURL string, params map[string]string) ([]byte, error) {
+	answered := false
+	for attempt := 1; ; attempt++ {
+		if err := u.pacer.Wait(ctx); err != nil {
+			if answered {
+				u.recordRequest(ctx)
+			}
+			if errors.Is(err, ErrRateLimited) {
+				return nil, &APIError{Provider: u.serviceName, Kind: APIErrorRateLimited, Symbol: params["symbol"], Message: "per-minute budget exhausted"}
+			}
+			return nil, err
+		}
+
+		start := time.Now()
+		body, status, err := u.doRequest(ctx, baseURL, params)
+		processingTime := time.Since(start)
+		answered = answered || status != 0
+
+		var responseBody string
+		var errorMsg string
+		if err != nil {
+			errorMsg = err.Error()
+		} else {
+			responseBody = string(body)
+		}
+
+		if err != nil && ctx.Err() == nil && attempt < u.maxAttempts && shouldRetry(status, body, err) {
+			u.insertAPICall(initiator, endpoint, params, status, responseBody, errorMsg, processingTime)
+
+			delay := retryDelay(attempt, u.retryBaseDelay, u.retryMaxDelay)
+			u.logger.WarnContext(ctx, "Request attempt failed, retrying", "provider", u.serviceName, "endpoint", endpoint,
+				"attempt", attempt, "max_attempts", u.maxAttempts, "retry_in", delay, "error", err)
+			time.Sleep(delay)
+			continue
+		}
+
+		u.insertAPICall(initiator, endpoint, params, status, responseBody, errorMsg, processingTime)
+		if answered {
+			u.recordRequest(ctx)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		return body, nil
+	}
+}
+
+// recordRequest counts a fetch against the rate limit, logging rather than
+// failing the fetch when the counter cannot be written
+func (u *providerUsage) recordRequest(ctx context.Context) {
+	if _, _, err := u.rateLimiter.RecordRequest(); err != nil {
+		u.logger.ErrorContext(ctx, "Failed to count API call", "provider", u.serviceName, "error", err)
+	}
+}
+
+// doRequest makes a single HTTP request, returning the status code (0 on network errors)
+func (u *providerUsage) doRequest(ctx context.Context, baseURL string, params map[string]string) ([]byte, int, error) {
+	reqURL, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid base URL: %w", err)
+	}
+
+	query := reqURL.Query()
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	reqURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL.String(), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "Stock-Intelligence-Backend/1.0")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return body, resp.StatusCode, &APIError{Provider: u.serviceName, Kind: APIErrorRateLimited, Symbol: params["symbol"], Message: "API returned status 429"}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return body, resp.StatusCode, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	return body, resp.StatusCode, nil
+}
+
+// SaveStats counts what a save did with each bar of a series
+type SaveStats struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	// Skipped bars are older than the latest stored date and left untouched
+	Skipped int `json:"skipped"`
+	// Rejected bars have prices that cannot be real and are not stored
+	Rejected []RejectedRow `json:"rejected,omitempty"`
+}
+
+// SaveHistoricalData saves a provider's daily series to the database. Only one
+// writer per symbol proceeds at a time, in this process and across processes;
+// mode decides whether a second writer waits its turn or gets ErrSyncInProgress.
+// ctx carries the request ID into log records; a save that has started is not
+// cancelled with it, so a symbol's prices are never left half written.
+//
+// The series is written in one transaction, savePriceBatchSize days per
+// statement; if any day fails nothing is saved and the error names that day.
+// Bars older than the latest stored date are already stored and are skipped,
+// unless they carry an adjusted close, which rewrites the stored history. Bars
+// that fail Validate are left out and reported in the stats. The splits and
+// cash dividends an adjusted series reports are stored with its prices.
+func (u *providerUsage) SaveHistoricalData(ctx context.Context, symbol string, data *DailySeries, mode SyncLockMode) (SaveStats, error) {
+	var stats SaveStats
+	release, err := syncLocks.acquire(symbol, mode, u.lockTimeout)
+	if err != nil {
+		return stats, err
+	}
+	defer release()
+
+	// Get stock ID
+	var stockID int
+	err = u.db.QueryRow("SELECT id FROM stocks WHERE symbol = $1", symbol).Scan(&stockID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return stats, fmt.Errorf("stock with symbol %s not found", symbol)
+		}
+		return stats, fmt.Errorf("failed to get stock ID: %w", err)
+	}
+
+	// Writers in other processes (tasks CLI, seed) are kept out by an advisory lock
+	conn, unlock, err := acquireAdvisoryLock(u.db, stockID, mode, u.lockTimeout)
+	if err != nil {
+		return stats, err
+	}
+	defer unlock()
+
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return stats, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var latest sql.NullTime
+	err = tx.QueryRow("SELECT MAX(date) FROM daily_prices WHERE stock_id = $1", stockID).Scan(&latest)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get latest stored date: %w", err)
+	}
+
+	var bars, splits, dividends []DailyBar
+	var firstDate, lastDate time.Time
+	for _, bar := range uniqueBarsByDate(data.Bars) {
+		// Rounded as the price columns store them, so validation sees the saved values
+		bar = bar.rounded()
+		if err := bar.Validate(); err != nil {
+			rejected := err.(*RejectedRow)
+			u.logger.WarnContext(ctx, "Rejected price row", "symbol", symbol, "date", rejected.Date, "field", rejected.Field, "value", rejected.Value, "reason", rejected.Reason)
+			stats.Rejected = append(stats.Rejected, *rejected)
+			continue
+		}
+		if latest.Valid && bar.Date.Before(latest.Time) && bar.AdjustedClose == 0 {
+			stats.Skipped++
+			continue
+		}
+
+		bars = append(bars, bar)
+		if firstDate.IsZero() || bar.Date.Before(firstDate) {
+			firstDate = bar.Date
+		}
+		if bar.Date.After(lastDate) {
+			lastDate = bar.Date
+		}
+		if bar.isSplit() {
+			splits = append(splits, bar)
+		}
+		if bar.Dividend > 0 {
+			dividends = append(dividends, bar)
+		}
+	}
+
+	inserted, failed, err := writeDailyPrices(tx, stockID, bars)
+	if err != nil {
+		tx.Rollback()
+		return SaveStats{}, describeSaveFailure(conn, symbol, stockID, failed, err)
+	}
+	stats.Inserted += inserted
+	stats.Updated += len(bars) - inserted
+
+	for _, split := range splits {
+		if err := recordSplit(tx, stockID, split.Date, split.SplitCoefficient); err != nil {
+			return SaveStats{}, fmt.Errorf("failed to record %s split on %s: %w", symbol, split.Date.Format("2006-01-02"), err)
+		}
+	}
+
+	for _, dividend := range dividends {
+		if err := recordDividend(tx, stockID, dividend.Date, dividend.Dividend); err != nil {
+			return SaveStats{}, fmt.Errorf("failed to record %s dividend on %s: %w", symbol, dividend.Date.Format("2006-01-02"), err)
+		}
+	}
+
+	if stats.Inserted+stats.Updated > 0 {
+		if err := updatePriceCoverage(tx, stockID, firstDate, lastDate, data.Provisional); err != nil {
+			return SaveStats{}, fmt.Errorf("failed to update price coverage for %s: %w", symbol, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return SaveStats{}, fmt.Errorf("failed to commit %s prices: %w", symbol, err)
+	}
+
+	u.logger.InfoContext(ctx, "Saved daily prices", "symbol", symbol, "inserted", stats.Inserted, "updated", stats.Updated, "skipped", stats.Skipped, "rejected", len(stats.Rejected))
+	return stats, nil
+}
+
+// savePriceBatchSize is how many days one upsert statement writes, well under
+// Postgres's limit of 65535 parameters at 7 per day
+const savePriceBatchSize = 500
+
+// writeDailyPrices is the bulk writer of a stock's prices: it upserts the
+// bars, the unadjusted before the adjusted, savePriceBatchSize days per
+// statement, and returns how many were new days. The bars must have unique
+// dates. When a statement fails, its batch is returned with the error.
+func writeDailyPrices(tx *sql.Tx, stockID int, bars []DailyBar) (int, []DailyBar, error) {
+	var adjusted, unadjusted []DailyBar
+	for _, bar := range bars {
+		if bar.AdjustedClose == 0 {
+			unadjusted = append(unadjusted, bar)
+		} else {
+			adjusted = append(adjusted, bar)
+		}
+	}
+
+	inserted := 0
+	for _, bars := range [][]DailyBar{unadjusted, adjusted} {
+		for start := 0; start < len(bars); start += savePriceBatchSize {
+			batch := bars[start:min(start+savePriceBatchSize, len(bars))]
+			n, err := upsertDailyPrices(tx, stockID, batch)
+			if err != nil {
+				return 0, batch, err
+			}
+			inserted += n
+		}
+	}
+	return inserted, nil, nil
+}
+
+// upsertDailyPrices writes bars that are all adjusted or all unadjusted and
+// returns how many were new days. An unadjusted bar's adjusted close is its
+// close for a new day, while an existing day keeps the adjustment an adjusted
+// fetch stored. xmax is zero only for a newly inserted row.
+func upsertDailyPrices(tx *sql.Tx, stockID int, bars []DailyBar) (int, error) {
+	adjustedCloseUpdate := "daily_prices.adjusted_close"
+	if bars[0].AdjustedClose != 0 {
+		adjustedCloseUpdate = "EXCLUDED.adjusted_close"
+	}
+
+	values := make([]string, 0, len(bars))
+	args := make([]interface{}, 0, 1+7*len(bars))
+	args = append(args, stockID)
+	for _, bar := range bars {
+		n := len(args)
+		values = append(values, fmt.Sprintf("($1, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7))
+		adjustedClose := bar.AdjustedClose
+		if adjustedClose == 0 {
+			adjustedClose = bar.Close
+		}
+		args = append(args, bar.Date, bar.Open, bar.High, bar.Low, bar.Close, adjustedClose, bar.Volume)
+	}
+
+	rows, err := tx.Query(`
+		INSERT INTO daily_prices (stock_id, date, open_price, high_price, low_price,
+		                         close_price, adjusted_close, volume)
+		VALUES `+strings.Join(values, ", ")+`
+		ON CONFLICT (stock_id, date)
+		DO UPDATE SET
+			open_price = EXCLUDED.open_price,
+			high_price = EXCLUDED.high_price,
+			low_price = EXCLUDED.low_price,
+			close_price = EXCLUDED.close_price,
+			adjusted_close = `+adjustedCloseUpdate+`,
+			volume = EXCLUDED.volume,
+			created_at = CURRENT_TIMESTAMP
+		RETURNING (xmax = 0)
+	`, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	inserted := 0
+	for rows.Next() {
+		var isNew bool
+		if err := rows.Scan(&isNew); err != nil {
+			return 0, err
+		}
+		if isNew {
+			inserted++
+		}
+	}
+	return inserted, rows.Err()
+}
+
+// describeSaveFailure names the day a failed batch could not store. The batch
+// is replayed one day at a time in a transaction that is rolled back; if every
+// day succeeds alone the error covers the batch's dates instead.
+func describeSaveFailure(conn *sql.Conn, symbol string, stockID int, batch []DailyBar, batchErr error) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err == nil {
+		defer tx.Rollback()
+		for _, bar := range batch {
+			if _, err := upsertDailyPrices(tx, stockID, []DailyBar{bar}); err != nil {
+				return fmt.Errorf("failed to save %s price for %s: %w", symbol, bar.Date.Format("2006-01-02"), err)
+			}
+		}
+	}
+	return fmt.Errorf("failed to save %s prices from %s to %s: %w", symbol,
+		batch[0].Date.Format("2006-01-02"), batch[len(batch)-1].Date.Format("2006-01-02"), batchErr)
+}
+
+// uniqueBarsByDate drops all but the last bar for each date, as one upsert
+// statement cannot write the same row twice
+func uniqueBarsByDate(bars []DailyBar) []DailyBar {
+	index := make(map[time.Time]int, len(bars))
+	unique := make([]DailyBar, 0, len(bars))
+	for _, bar := range bars {
+		if i, ok := index[bar.Date]; ok {
+			unique[i] = bar
+			continue
+		}
+		index[bar.Date] = len(unique)
+		unique = append(unique, bar)
+	}
+	return unique
+}
+
+// recordSplit stores a split that took effect on date, ratio being new shares per old share
+func recordSplit(tx *sql.Tx, stockID int, date time.Time, ratio float64) error {
+	_, err := tx.Exec(`
+		INSERT INTO stock_splits (stock_id, split_date, ratio)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (stock_id, split_date)
+		DO UPDATE SET ratio = EXCLUDED.ratio
+	`, stockID, date, ratio)
+	return err
+}
+
+// recordDividend stores a cash dividend per share going ex on date; a day
+// fetched again replaces the amount stored for it
+func recordDividend(tx *sql.Tx, stockID int, date time.Time, amount float64) error {
+	_, err := tx.Exec(`
+		INSERT INTO dividends (stock_id, ex_date, amount)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (stock_id, ex_date)
+		DO UPDATE SET amount = EXCLUDED.amount
+	`, stockID, date, amount)
+	return err
+}
+
+// isSplit reports whether a split took effect on the bar's date
+func (b DailyBar) isSplit() bool {
+	return b.SplitCoefficient > 0 && b.SplitCoefficient != 1
+}
+
+// updatePriceCoverage widens a stock's first/last price dates to include the
+// saved range. LEAST and GREATEST ignore NULL, so a stock's first save sets both.
+// A provisional save marks its day as written from a quote; any other save
+// that reaches that day clears the mark.
+func updatePriceCoverage(tx *sql.Tx, stockID int, firstDate, lastDate time.Time, provisional bool) error {
+	_, err := tx.Exec(`
+		UPDATE stocks
+		SET first_price_date = LEAST(first_price_date, $2::date),
+		    last_price_date = GREATEST(last_price_date, $3::date),
+		    quote_price_date = CASE
+		        WHEN $4 THEN $3::date
+		        WHEN quote_price_date <= $3::date THEN NULL
+		        ELSE quote_price_date
+		    END
+		WHERE id = $1
+	`, stockID, firstDate, lastDate, provisional)
+	return err
+}
+
+// GetRateLimit returns current rate limit status
+func (u *providerUsage) GetRateLimit() (*models.APIRateLimit, error) {
+	return u.rateLimiter.Status()
+}
+
+// GetAPICallStats returns API call statistics
+func (u *providerUsage) GetAPICallStats(days int) ([]models.APICallStats, error) {
+	query := `
+		SELECT service_name, endpoint, total_calls, successful_calls, failed_calls,
+		       avg_processing_time_ms, last_call_at, call_date
+		FROM api_call_stats
+		WHERE service_name = $1
+		  AND call_date >= CURRENT_DATE - INTERVAL '%d days'
+		ORDER BY call_date DESC, endpoint
+	`
+
+	rows, err := u.db.Query(fmt.Sprintf(query, days), u.serviceName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.APICallStats
+	for rows.Next() {
+		var stat models.APICallStats
+		err := rows.Scan(&stat.ServiceName, &stat.Endpoint, &stat.TotalCalls,
+			&stat.SuccessfulCalls, &stat.FailedCalls, &stat.AvgProcessingTimeMs,
+			&stat.LastCallAt, &stat.CallDate)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+// GetAPICallStatsByInitiator returns API call counts per initiator per day
+func (u *providerUsage) GetAPICallStatsByInitiator(days int) ([]models.APICallInitiatorStats, error) {
+	query := `
+		SELECT service_name, initiated_by,
+		       COUNT(*) as total_calls,
+		       COUNT(*) FILTER (WHERE response_status = 200) as successful_calls,
+		       COUNT(*) FILTER (WHERE response_status >= 400 OR response_status = 0) as failed_calls,
+		       MAX(created_at) as last_call_at,
+		       DATE(created_at) as call_date
+		FROM api_calls
+		WHERE service_name = $1
+		  AND created_at >= CURRENT_DATE - $2 * INTERVAL '1 day'
+		GROUP BY service_name, initiated_by, DATE(created_at)
+		ORDER BY call_date DESC, total_calls DESC, initiated_by
+	`
+
+	rows, err := u.db.Query(query, u.serviceName, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API call stats by initiator: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []models.APICallInitiatorStats
+	for rows.Next() {
+		var stat models.APICallInitiatorStats
+		err := rows.Scan(&stat.ServiceName, &stat.InitiatedBy, &stat.TotalCalls,
+			&stat.SuccessfulCalls, &stat.FailedCalls, &stat.LastCallAt, &stat.CallDate)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
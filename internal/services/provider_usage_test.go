@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// consecutiveBars returns n days of prices starting on 2 January 2000
+func consecutiveBars(n int) []DailyBar {
+	bars := make([]DailyBar, n)
+	for i := range bars {
+		bars[i] = DailyBar{Date: time.Date(2000, 1, 2+i, 0, 0, 0, 0, time.UTC), Open: 10, High: 11, Low: 9, Close: 10.5, Volume: 1000}
+	}
+	return bars
+}
+
+// insertedRows is the RETURNING rows of n price upserts that all added new days
+func insertedRows(n int) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"inserted"})
+	for i := 0; i < n; i++ {
+		rows.AddRow(true)
+	}
+	return rows
+}
+
+func TestSaveHistoricalData_WritesInBatches(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expectAdvisoryLock(mock, 1, true)
+	expectSaveBegins(mock, 1, nil)
+	mock.ExpectQuery("INSERT INTO daily_prices").WillReturnRows(insertedRows(savePriceBatchSize))
+	mock.ExpectQuery("INSERT INTO daily_prices").WillReturnRows(insertedRows(savePriceBatchSize))
+	mock.ExpectQuery("INSERT INTO daily_prices").WillReturnRows(insertedRows(200))
+	expectPriceCoverage(mock, 1)
+	mock.ExpectCommit()
+	expectAdvisoryUnlock(mock, 1)
+
+	series := &DailySeries{Symbol: "AAPL", Bars: consecutiveBars(2*savePriceBatchSize + 200)}
+	stats, err := NewAlphaVantageClient("test-key", db).SaveHistoricalData(context.Background(), "AAPL", series, SyncLockWait)
+
+	require.NoError(t, err)
+	assert.Equal(t, SaveStats{Inserted: 2*savePriceBatchSize + 200}, stats)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveHistoricalData_FailureRollsBackAndNamesDate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	bars := consecutiveBars(3)
+	refused := errors.New(`numeric field overflow`)
+
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expectAdvisoryLock(mock, 1, true)
+	expectSaveBegins(mock, 1, nil)
+	mock.ExpectQuery("INSERT INTO daily_prices").WillReturnError(refused)
+	mock.ExpectRollback()
+	// The batch is replayed a day at a time to find the one refused, then discarded
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO daily_prices").WithArgs(1, bars[0].Date, sqlmock.AnyArg(), sqlmock.AnyArg(),
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnRows(insertedRows(1))
+	mock.ExpectQuery("INSERT INTO daily_prices").WithArgs(1, bars[1].Date, sqlmock.AnyArg(), sqlmock.AnyArg(),
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnError(refused)
+	mock.ExpectRollback()
+	expectAdvisoryUnlock(mock, 1)
+
+	series := &DailySeries{Symbol: "AAPL", Bars: bars}
+	stats, err := NewAlphaVantageClient("test-key", db).SaveHistoricalData(context.Background(), "AAPL", series, SyncLockWait)
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, refused)
+	assert.Contains(t, err.Error(), "2000-01-03")
+	assert.Zero(t, stats)
+	assert.NoError(t, mock.ExpectationsWereMet(), "coverage must not be updated and nothing committed")
+}
+
+func TestUniqueBarsByDate_KeepsLastBarPerDate(t *testing.T) {
+	bars := consecutiveBars(2)
+	revised := bars[0]
+	revised.Close = 12
+
+	unique := uniqueBarsByDate(append(bars, revised))
+
+	require.Len(t, unique, 2)
+	assert.Equal(t, 12.0, unique[0].Close)
+	assert.Equal(t, bars[1].Date, unique[1].Date)
+}
+
+// BenchmarkSaveHistoricalData_5000Rows saves 20 years of prices with each
+// statement taking a simulated 100µs database round trip. Batching makes this
+// 10 round trips where a statement per day made 5000.
+func BenchmarkSaveHistoricalData_5000Rows(b *testing.B) {
+	const rows = 5000
+	roundTrip := 100 * time.Microsecond
+	series := &DailySeries{Symbol: "BENCH", Bars: consecutiveBars(rows)}
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		db, mock, err := sqlmock.New()
+		require.NoError(b, err)
+		mock.ExpectQuery("SELECT id FROM stocks").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		expectAdvisoryLock(mock, 1, true)
+		expectSaveBegins(mock, 1, nil)
+		for saved := 0; saved < rows; saved += savePriceBatchSize {
+			mock.ExpectQuery("INSERT INTO daily_prices").WillDelayFor(roundTrip).
+				WillReturnRows(insertedRows(min(savePriceBatchSize, rows-saved)))
+		}
+		expectPriceCoverage(mock, 1)
+		mock.ExpectCommit()
+		expectAdvisoryUnlock(mock, 1)
+		client := NewAlphaVantageClient("test-key", db)
+		client.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+		b.StartTimer()
+
+		if _, err := client.SaveHistoricalData(context.Background(), "BENCH", series, SyncLockWait); err != nil {
+			b.Fatal(err)
+		}
+
+		b.StopTimer()
+		db.Close()
+		b.StartTimer()
+	}
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultQueryTimeout bounds the database work of each DatabaseStockService call
+const DefaultQueryTimeout = 5 * time.Second
+
+// ErrQueryTimeout is returned when a call's queries outlast the query timeout
+var ErrQueryTimeout = errors.New("database query timed out")
+
+// SetQueryTimeout sets how long each call's queries may run before they are
+// cancelled; zero or less leaves them bounded only by the caller's context
+func (d *DatabaseStockService) SetQueryTimeout(timeout time.Duration) {
+	d.queryTimeout = timeout
+}
+
+// withQueryTimeout derives the context a call's queries run under
+func (d *DatabaseStockService) withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d.queryTimeout)
+}
+
+// queryError marks err as an ErrQueryTimeout when ctx's deadline cut the query
+// short. Drivers report a cancelled query in their own words, so the deadline
+// is what decides.
+func queryError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && !errors.Is(err, ErrQueryTimeout) {
+		return fmt.Errorf("%w: %w", ErrQueryTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAllStocksPaginated_QueryTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewDatabaseStockService(db, nil)
+	service.SetQueryTimeout(20 * time.Millisecond)
+
+	mock.ExpectQuery(`SELECT COUNT\(DISTINCT s.id\)`).WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(500))
+
+	start := time.Now()
+	stocks, total, err := service.GetAllStocksPaginated(context.Background(), StockFilter{}, 50, 0)
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "the query is cancelled, not waited out")
+	assert.Nil(t, stocks)
+	assert.Zero(t, total)
+}
+
+func TestGetStockBySymbol_CallerCancellationIsNotATimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewDatabaseStockService(db, nil)
+
+	mock.ExpectQuery(`FROM stocks s\s+WHERE s.symbol = \$1`).WithArgs("AAPL").WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	_, err = service.GetStockBySymbol(ctx, "AAPL")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrQueryTimeout)
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// SyncMode is how much of a stock's prices a sync fetches
+type SyncMode string
+
+const (
+	// SyncModeFull fetches the daily history the stock is missing
+	SyncModeFull SyncMode = "full"
+	// SyncModeQuote fetches one quote and updates only the latest trading day
+	SyncModeQuote SyncMode = "quote"
+)
+
+// SyncModes lists the modes a manual sync accepts
+var SyncModes = []string{string(SyncModeFull), string(SyncModeQuote)}
+
+// ParseSyncMode returns the mode called s, SyncModeFull when s is empty
+func ParseSyncMode(s string) (SyncMode, error) {
+	switch mode := SyncMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return SyncModeFull, nil
+	case SyncModeFull, SyncModeQuote:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("mode must be one of %s", strings.Join(SyncModes, ", "))
+	}
+}
+
+// QuoteRefreshService keeps the latest trading day's prices current during
+// the session, one quote per stock, at a fraction of a daily fetch's size
+type QuoteRefreshService struct {
+	db       *sql.DB
+	provider MarketDataProvider
+	calendar *MarketCalendar
+	now      func() time.Time
+}
+
+// NewQuoteRefreshService creates a quote refresh for provider
+func NewQuoteRefreshService(db *sql.DB, provider MarketDataProvider) *QuoteRefreshService {
+	return &QuoteRefreshService{
+		db:       db,
+		provider: provider,
+		calendar: NewMarketCalendar(),
+		now:      time.Now,
+	}
+}
+
+// RefreshQuote fetches symbol's quote and upserts it as the daily_prices row
+// of its trading day, leaving every other day alone. A quote taken while the
+// market is open is saved as provisional, so the scheduled sync stores that
+// day again once it has closed. Errors saving the quote are APIErrors of kind
+// APIErrorSaveFailed, except ErrSyncInProgress.
+func (q *QuoteRefreshService) RefreshQuote(ctx context.Context, symbol string, initiator Initiator, mode SyncLockMode) (SaveStats, error) {
+	quote, err := q.provider.FetchQuote(symbol, initiator)
+	if err != nil {
+		return SaveStats{}, err
+	}
+
+	series := &DailySeries{
+		Symbol:      symbol,
+		Bars:        []DailyBar{quote.DailyBar},
+		Provisional: q.calendar == nil || q.calendar.IsMarketOpen(q.now()),
+	}
+	stats, err := q.provider.SaveHistoricalData(ctx, symbol, series, mode)
+	if errors.Is(err, ErrSyncInProgress) {
+		return stats, err
+	}
+	if err != nil {
+		return stats, &APIError{Provider: q.provider.Name(), Kind: APIErrorSaveFailed, Symbol: symbol, Message: err.Error()}
+	}
+
+	// Only decides which stocks the next run picks, so a failure costs nothing but order
+	if _, err := q.db.ExecContext(ctx, "UPDATE stocks SET quote_refreshed_at = CURRENT_TIMESTAMP WHERE symbol = $1", symbol); err != nil {
+		slog.WarnContext(ctx, "Failed to record quote refresh", "symbol", symbol, "error", err)
+	}
+	return stats, nil
+}
+
+// NextToRefresh returns up to limit active stocks whose quote was refreshed
+// longest ago, largest first among those never refreshed. Stocks without
+// stored prices are left to a full sync, as one quote would start their
+// history at today.
+func (q *QuoteRefreshService) NextToRefresh(ctx context.Context, limit int) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT s.symbol
+		FROM stocks s
+		WHERE s.is_active = true AND s.delisted_at IS NULL AND s.last_price_date IS NOT NULL
+		ORDER BY s.quote_refreshed_at ASC NULLS FIRST, s.market_cap DESC NULLS LAST, s.symbol
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			return nil, fmt.Errorf("failed to scan stock to refresh: %w", err)
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols, rows.Err()
+}
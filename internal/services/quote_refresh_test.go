@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSyncMode(t *testing.T) {
+	for input, want := range map[string]SyncMode{"": SyncModeFull, "full": SyncModeFull, "Quote": SyncModeQuote} {
+		mode, err := ParseSyncMode(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, mode, input)
+	}
+
+	_, err := ParseSyncMode("intraday")
+	assert.EqualError(t, err, "mode must be one of full, quote")
+}
+
+func TestParseQuoteResponse_ChangeFields(t *testing.T) {
+	quote, err := parseQuoteResponse("AAPL", []byte(`{"Global Quote": {
+		"01. symbol": "AAPL", "02. open": "182.1500", "03. high": "183.0872", "04. low": "180.8800",
+		"05. price": "181.9100", "06. volume": "71983570", "07. latest trading day": "2024-01-04",
+		"08. previous close": "184.2500", "09. change": "-2.3400", "10. change percent": "-1.2700%"
+	}}`))
+
+	require.NoError(t, err)
+	assert.Equal(t, 184.25, quote.PreviousClose)
+	assert.Equal(t, -2.34, quote.Change)
+	assert.Equal(t, -1.27, quote.ChangePercent)
+
+	_, err = parseQuoteResponse("AAPL", []byte(`{"Global Quote": {"01. symbol": "AAPL", "05. price": "", "07. latest trading day": "2024-01-04"}}`))
+	assert.ErrorIs(t, err, ErrNoData)
+}
+
+func TestRefreshQuote_UpsertsOnlyTheQuotedDay(t *testing.T) {
+	quotedDay := time.Date(2024, time.January, 4, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		now         time.Time
+		provisional bool
+	}{
+		{name: "market open", now: time.Date(2024, time.January, 4, 18, 0, 0, 0, time.UTC), provisional: true},
+		{name: "after the close", now: time.Date(2024, time.January, 4, 22, 0, 0, 0, time.UTC), provisional: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mock := newRetryTestClient(t, serveFixture(t, "alphavantage_quote_aapl.json", nil))
+			expectRateLimitQuery(mock)
+			mock.ExpectExec("INSERT INTO api_calls").
+				WithArgs(ProviderAlphaVantage, "GLOBAL_QUOTE", sqlmock.AnyArg(), http.StatusOK, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), string(InitiatorScheduler), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectRateLimitIncrement(mock)
+
+			mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			expectAdvisoryLock(mock, 1, true)
+			expectSaveBegins(mock, 1, quotedDay.AddDate(0, 0, -1))
+			// One row, the quoted day, with the quote's price as its close
+			mock.ExpectQuery("INSERT INTO daily_prices").
+				WithArgs(1, quotedDay, 182.15, 183.0872, 180.88, 181.91, 181.91, int64(71983570)).
+				WillReturnRows(insertedRow())
+			mock.ExpectExec(`UPDATE stocks\s+SET first_price_date`).WithArgs(1, quotedDay, quotedDay, tt.provisional).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+			expectAdvisoryUnlock(mock, 1)
+			mock.ExpectExec("UPDATE stocks SET quote_refreshed_at").WithArgs("AAPL").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			quotes := NewQuoteRefreshService(client.db, client)
+			quotes.now = func() time.Time { return tt.now }
+			stats, err := quotes.RefreshQuote(context.Background(), "AAPL", InitiatorScheduler, SyncLockSkip)
+
+			require.NoError(t, err)
+			assert.Equal(t, 1, stats.Inserted)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRefreshQuote_SaveFailureIsClassified(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	provider := &stubQuoteProvider{stubSyncProvider: stubSyncProvider{saveErr: assert.AnError}}
+	_, err = NewQuoteRefreshService(db, provider).RefreshQuote(context.Background(), "AAPL", InitiatorScheduler, SyncLockSkip)
+
+	assert.Equal(t, APIErrorSaveFailed, ErrorKindOf(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// stubQuoteProvider serves a quote for the latest trading day
+type stubQuoteProvider struct {
+	stubSyncProvider
+	quotes int
+}
+
+func (p *stubQuoteProvider) Name() string { return "stub" }
+
+func (p *stubQuoteProvider) FetchQuote(symbol string, initiator Initiator) (*Quote, error) {
+	p.quotes++
+	return &Quote{Symbol: symbol, DailyBar: DailyBar{Date: time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC), Close: 181.91}}, nil
+}
+
+func TestQuoteRefreshJob_RunsOnlyWhileMarketOpen(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	provider := &stubQuoteProvider{}
+	scheduler := NewSchedulerService(db, provider, nil, nil, nil)
+	scheduler.SetQuoteRefreshPerHour(2)
+
+	// After the close nothing is fetched
+	expectSchedulerRun(mock, SchedulerJobQuoteRefresh, RunStatusSkipped)
+	scheduler.now = duringSyncWindow
+	scheduler.quoteRefreshJob()
+	assert.Zero(t, provider.quotes)
+
+	// 14:00 in New York, with the two stocks refreshed longest ago updated
+	mock.ExpectQuery(`ORDER BY s.quote_refreshed_at ASC NULLS FIRST`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"symbol"}).AddRow("AAPL").AddRow("MSFT"))
+	for _, symbol := range []string{"AAPL", "MSFT"} {
+		mock.ExpectExec("UPDATE stocks SET quote_refreshed_at").WithArgs(symbol).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO sync_history").
+			WithArgs(symbol, true, "", "", 1, sqlmock.AnyArg(), string(InitiatorScheduler)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	expectSchedulerRun(mock, SchedulerJobQuoteRefresh, RunStatusSucceeded)
+	scheduler.now = func() time.Time { return time.Date(2026, time.March, 10, 18, 0, 0, 0, time.UTC) }
+	scheduler.quoteRefreshJob()
+
+	assert.Equal(t, 2, provider.quotes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
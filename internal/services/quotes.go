@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MaxQuoteSymbols caps how many symbols one bulk quote request may ask for
+const MaxQuoteSymbols = 100
+
+// QuotesCacheTTL is how long the quotes of a symbol set are cached; short, as
+// watchlists poll and a sync only invalidates the cache as a whole
+const QuotesCacheTTL = 30 * time.Second
+
+// StockQuote is a stock's latest close and its change from the close before
+type StockQuote struct {
+	Symbol        string    `json:"symbol"`
+	CompanyName   string    `json:"company_name"`
+	CurrentPrice  float64   `json:"current_price"`
+	Change        float64   `json:"change"`
+	ChangePercent float64   `json:"change_percent"`
+	Volume        int64     `json:"volume"`
+	HasPriceData  bool      `json:"has_price_data"`
+	LastUpdated   time.Time `json:"last_updated"`
+}
+
+// QuotesResult holds the quotes of the known symbols asked for, in symbol
+// order, and the symbols that are not active stocks
+type QuotesResult struct {
+	Quotes   []StockQuote `json:"quotes"`
+	NotFound []string     `json:"not_found"`
+}
+
+// GetQuotes returns quotes for symbols, which must be normalized, in one
+// query. Unknown symbols are listed in NotFound rather than failing the call.
+func (d *DatabaseStockService) GetQuotes(ctx context.Context, symbols []string) (*QuotesResult, error) {
+	sorted := make([]string, 0, len(symbols))
+	seen := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		if !seen[symbol] {
+			seen[symbol] = true
+			sorted = append(sorted, symbol)
+		}
+	}
+	sort.Strings(sorted)
+
+	if d.cache != nil {
+		var cached QuotesResult
+		if err := d.cache.GetQuotes(ctx, sorted, &cached); err == nil {
+			slog.DebugContext(ctx, "Loaded quotes from cache", "count", len(cached.Quotes))
+			return &cached, nil
+		}
+	}
+
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT s.symbol, s.company_name,
+		       latest.close_price,
+		       latest.close_price - previous.close_price as daily_change,
+		       CASE WHEN previous.close_price > 0 THEN
+		           ((latest.close_price - previous.close_price) / previous.close_price * 100)
+		       END as change_percent,
+		       COALESCE(latest.volume, 0) as volume,
+		       COALESCE(latest.date, s.updated_at) as last_updated
+		FROM stocks s` + latestPriceJoins + `
+		WHERE s.symbol = ANY($1) AND s.is_active = true
+		ORDER BY s.symbol
+	`
+
+	rows, err := d.router.Reader().QueryContext(ctx, query, pq.Array(sorted))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quotes: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+
+	result := &QuotesResult{Quotes: []StockQuote{}, NotFound: []string{}}
+	found := make(map[string]bool, len(sorted))
+	for rows.Next() {
+		var quote StockQuote
+		var currentPrice, dailyChange, changePercent sql.NullFloat64
+		if err := rows.Scan(&quote.Symbol, &quote.CompanyName, &currentPrice, &dailyChange,
+			&changePercent, &quote.Volume, &quote.LastUpdated); err != nil {
+			return nil, fmt.Errorf("failed to scan quote: %w", err)
+		}
+		if currentPrice.Valid && currentPrice.Float64 > 0 {
+			quote.HasPriceData = true
+			quote.CurrentPrice = RoundPrice(currentPrice.Float64)
+			quote.Change = RoundPrice(dailyChange.Float64)
+			quote.ChangePercent = RoundPercent(changePercent.Float64)
+		} else {
+			quote.Volume = 0
+		}
+		found[quote.Symbol] = true
+		result.Quotes = append(result.Quotes, quote)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read quotes: %w", queryError(ctx, err))
+	}
+
+	for _, symbol := range sorted {
+		if !found[symbol] {
+			result.NotFound = append(result.NotFound, symbol)
+		}
+	}
+
+	if d.cache != nil {
+		if err := d.cache.SetQuotes(ctx, sorted, result, QuotesCacheTTL); err != nil {
+			slog.WarnContext(ctx, "Failed to cache quotes", "error", err)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetQuotes_CachesBySymbolSet(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+
+	mock.ExpectQuery(`WHERE s.symbol = ANY\(\$1\)`).WithArgs(pq.Array([]string{"AAPL", "MSFT", "XYZ"})).
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "company_name", "close_price", "daily_change", "change_percent", "volume", "last_updated"}).
+			AddRow("AAPL", "Apple Inc.", 190.0, 2.0, 1.0638, int64(52000000), time.Now()).
+			AddRow("MSFT", "Microsoft Corporation", 410.0, -4.1, -0.99, int64(21000000), time.Now()))
+
+	first, err := service.GetQuotes(context.Background(), []string{"MSFT", "XYZ", "AAPL"})
+	require.NoError(t, err)
+	require.Len(t, first.Quotes, 2)
+	assert.Equal(t, []string{"XYZ"}, first.NotFound)
+	assert.InDelta(t, -0.99, first.Quotes[1].ChangePercent, 0.0001)
+
+	// The same set in another order is served from the cache
+	second, err := service.GetQuotes(context.Background(), []string{"XYZ", "AAPL", "MSFT", "AAPL"})
+	require.NoError(t, err)
+	assert.Equal(t, first.NotFound, second.NotFound)
+	assert.Equal(t, first.Quotes[0].Symbol, second.Quotes[0].Symbol)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetQuotes_RoundsPricesAndChanges(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	service := NewDatabaseStockService(db, nil)
+
+	mock.ExpectQuery(`WHERE s.symbol = ANY\(\$1\)`).WithArgs(pq.Array([]string{"AAPL"})).
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "company_name", "close_price", "daily_change", "change_percent", "volume", "last_updated"}).
+			AddRow("AAPL", "Apple Inc.", 190.24999999, 2.00000001, 1.0638297872, int64(52000000), time.Now()))
+
+	quotes, err := service.GetQuotes(context.Background(), []string{"AAPL"})
+	require.NoError(t, err)
+	require.Len(t, quotes.Quotes, 1)
+	assert.Equal(t, 190.25, quotes.Quotes[0].CurrentPrice)
+	assert.Equal(t, 2.0, quotes.Quotes[0].Change)
+	assert.Equal(t, 1.06, quotes.Quotes[0].ChangePercent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
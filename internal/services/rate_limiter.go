@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// RateLimiter keeps one provider's api_rate_limits counters. It and
+// RollOverRateLimits are the only code that moves them, so the server's
+// clients and the standalone data fetcher count a request the same way and
+// exactly once.
+type RateLimiter struct {
+	serviceName string
+	db          *sql.DB
+	now         func() time.Time
+}
+
+func NewRateLimiter(db *sql.DB, serviceName string) *RateLimiter {
+	return &RateLimiter{serviceName: serviceName, db: db, now: time.Now}
+}
+
+// Status returns the provider's limits and counters as stored. Counters from an
+// earlier hour or day are not reset until the next request is recorded, so use
+// ComputeAPIBudget to read them as of now.
+func (r *RateLimiter) Status() (*models.APIRateLimit, error) {
+	var rateLimit models.APIRateLimit
+
+	query := `
+		SELECT id, service_name, daily_limit, hourly_limit, current_daily_count,
+		       current_hourly_count, last_reset_date, last_reset_hour, created_at, updated_at
+		FROM api_rate_limits
+		WHERE service_name = $1
+	`
+
+	err := r.db.QueryRow(query, r.serviceName).Scan(
+		&rateLimit.ID, &rateLimit.ServiceName, &rateLimit.DailyLimit,
+		&rateLimit.HourlyLimit, &rateLimit.CurrentDailyCount,
+		&rateLimit.CurrentHourlyCount, &rateLimit.LastResetDate,
+		&rateLimit.LastResetHour, &rateLimit.CreatedAt, &rateLimit.UpdatedAt,
+	)
+
+	return &rateLimit, err
+}
+
+// CanMakeRequest reports whether the daily and hourly limits leave room for another request
+func (r *RateLimiter) CanMakeRequest(callsPerMinute int) (bool, error) {
+	var rateLimit models.APIRateLimit
+
+	query := `
+		SELECT id, service_name, daily_limit, hourly_limit, current_daily_count,
+		       current_hourly_count, last_reset_date, last_reset_hour
+		FROM api_rate_limits
+		WHERE service_name = $1
+	`
+
+	err := r.db.QueryRow(query, r.serviceName).Scan(
+		&rateLimit.ID, &rateLimit.ServiceName, &rateLimit.DailyLimit,
+		&rateLimit.HourlyLimit, &rateLimit.CurrentDailyCount,
+		&rateLimit.CurrentHourlyCount, &rateLimit.LastResetDate,
+		&rateLimit.LastResetHour,
+	)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to get rate limit: %w", err)
+	}
+
+	// Both the daily and hourly limits apply, with counters rolled over to the current hour
+	budget := ComputeAPIBudget(&rateLimit, nil, callsPerMinute, r.now())
+	return budget.CanMakeRequest, nil
+}
+
+// RecordRequest counts one request that reached the provider and returns the
+// counters after it. The increment and the roll-over of counters left from an
+// earlier day or hour happen in one statement, so concurrent writers in any
+// process never lose or double a count. Days and hours are Eastern time,
+// whatever the database server's zone.
+func (r *RateLimiter) RecordRequest() (dailyCount, hourlyCount int, err error) {
+	query := `
+		UPDATE api_rate_limits
+		SET current_daily_count = CASE
+		        WHEN last_reset_date < $2 THEN 1
+		        ELSE current_daily_count + 1 END,
+		    current_hourly_count = CASE
+		        WHEN last_reset_date < $2
+		          OR last_reset_hour < $3 THEN 1
+		        ELSE current_hourly_count + 1 END,
+		    last_reset_date = $2,
+		    last_reset_hour = $3,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE service_name = $1
+		RETURNING current_daily_count, current_hourly_count
+	`
+
+	now := r.now()
+	err = r.db.QueryRow(query, r.serviceName, marketDate(now), marketHour(now)).Scan(&dailyCount, &hourlyCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, fmt.Errorf("no api_rate_limits row for %s", r.serviceName)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to update rate limit: %w", err)
+	}
+	return dailyCount, hourlyCount, nil
+}
+
+// RollOverRateLimits zeroes the counters every provider's api_rate_limits row
+// carries over from an earlier Eastern-time hour, as RecordRequest would on
+// the next request, so Status reads them as of now. The daily count is only
+// zeroed for a row from an earlier day. It returns how many rows rolled over.
+func RollOverRateLimits(ctx context.Context, db *sql.DB, now time.Time) (int64, error) {
+	result, err := db.ExecContext(ctx, `
+		UPDATE api_rate_limits
+		SET current_daily_count = CASE
+		        WHEN last_reset_date < $1 THEN 0
+		        ELSE current_daily_count END,
+		    current_hourly_count = 0,
+		    last_reset_date = $1,
+		    last_reset_hour = $2,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE (last_reset_date < $1
+		       OR (last_reset_date = $1
+		           AND last_reset_hour < $2))
+	`, marketDate(now), marketHour(now))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
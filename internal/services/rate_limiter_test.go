@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectRequestRecorded expects one request to be counted against serviceName's budget row
+func expectRequestRecorded(mock sqlmock.Sqlmock, serviceName string) {
+	mock.ExpectQuery(`UPDATE api_rate_limits[\s\S]+RETURNING current_daily_count, current_hourly_count`).
+		WithArgs(serviceName, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"current_daily_count", "current_hourly_count"}).AddRow(1, 1))
+}
+
+func TestRateLimiter_RecordRequest(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// 01:30 UTC on March 11 is 21:30 on March 10 in New York, where the day's
+	// counts carry on rather than restarting at midnight UTC
+	mock.ExpectQuery(`UPDATE api_rate_limits[\s\S]+WHEN last_reset_date < \$2 THEN 1[\s\S]+RETURNING`).
+		WithArgs(ProviderAlphaVantage, time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), 21).
+		WillReturnRows(sqlmock.NewRows([]string{"current_daily_count", "current_hourly_count"}).AddRow(7, 3))
+
+	limiter := NewRateLimiter(db, ProviderAlphaVantage)
+	limiter.now = func() time.Time { return time.Date(2026, 3, 11, 1, 30, 0, 0, time.UTC) }
+	daily, hourly, err := limiter.RecordRequest()
+
+	require.NoError(t, err)
+	assert.Equal(t, 7, daily)
+	assert.Equal(t, 3, hourly)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRollOverRateLimits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Rolls over on the Eastern-time day and hour, as RecordRequest does
+	mock.ExpectExec(`UPDATE api_rate_limits[\s\S]+WHEN last_reset_date < \$1 THEN 0[\s\S]+AND last_reset_hour < \$2`).
+		WithArgs(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), 21).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	rolled, err := RollOverRateLimits(context.Background(), db, time.Date(2026, 3, 11, 1, 30, 0, 0, time.UTC))
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rolled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRateLimiter_RecordRequestWithoutRow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE api_rate_limits").WithArgs(ProviderStooq, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"current_daily_count", "current_hourly_count"}))
+
+	_, _, err = NewRateLimiter(db, ProviderStooq).RecordRequest()
+
+	assert.ErrorContains(t, err, "no api_rate_limits row for stooq")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRateLimiter_OneIncrementPerRequestForEveryInitiator(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, dailySeriesFixture)
+	}))
+	defer server.Close()
+
+	initiators := []Initiator{InitiatorScheduler, InitiatorManualSync, InitiatorBatchSync, InitiatorTasksCLI}
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	client := NewAlphaVantageClient("test-key", db)
+	client.baseURL = server.URL
+
+	for _, initiator := range initiators {
+		expectRateLimitQuery(mock)
+		mock.ExpectExec("INSERT INTO api_calls").
+			WithArgs(ProviderAlphaVantage, "TIME_SERIES_DAILY", sqlmock.AnyArg(), http.StatusOK, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), string(initiator), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectRequestRecorded(mock, ProviderAlphaVantage)
+
+		_, err := client.FetchDailyData(context.Background(), "AAPL", initiator)
+		require.NoError(t, err, initiator)
+	}
+
+	assert.Equal(t, int32(len(initiators)), atomic.LoadInt32(&requests))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRateLimiter_LoggedCallWithoutResponseIsFree(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	usage := newProviderUsage(ProviderSandbox, db, DefaultCallsPerMinute)
+	mock.ExpectExec("INSERT INTO api_calls").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = usage.LogAPICall(InitiatorManualSync, "TIME_SERIES_DAILY", nil, 0, "", "connection refused", 0)
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// VolumeAverageDays is how many trading days before the latest a stock's
+// volume is averaged over to judge how unusual the latest volume is
+const VolumeAverageDays = 30
+
+// DefaultVolumeSpikeRatio is the relative volume from which a stock's
+// latest volume counts as a spike
+const DefaultVolumeSpikeRatio = 3.0
+
+// ActiveStock is a stock with its latest volume relative to its average
+type ActiveStock struct {
+	models.Stock
+	// AvgVolume is the average volume over the VolumeAverageDays trading days
+	// before the latest; nil for a stock without that much history
+	AvgVolume *int64 `json:"avg_volume_30d"`
+	// RelativeVolume is the latest volume over AvgVolume
+	RelativeVolume *float64 `json:"relative_volume"`
+	// RankedByVolume flags a stock without AvgVolume, which is ranked by its
+	// volume alone after the stocks ranked by relative volume
+	RankedByVolume bool `json:"ranked_by_volume"`
+}
+
+// relativeVolumes extends the changes CTE, as volumes, with each stock's
+// average volume over the VolumeAverageDays trading days before its latest,
+// and its latest volume relative to that. Both are null for stocks without
+// that much history or that traded nothing over it.
+var relativeVolumes = fmt.Sprintf(`,
+		volumes AS (
+			SELECT changes.*, history.avg_volume,
+			       volume / history.avg_volume AS relative_volume
+			FROM changes
+			LEFT JOIN LATERAL (
+			    SELECT CASE WHEN COUNT(*) = %[1]d AND AVG(recent.volume) > 0 THEN AVG(recent.volume) END AS avg_volume
+			    FROM (
+			        SELECT dp.volume
+			        FROM daily_prices dp
+			        WHERE dp.stock_id = changes.id AND dp.date < changes.last_updated
+			        ORDER BY dp.date DESC
+			        LIMIT %[1]d
+			    ) recent
+			) history ON true
+		)`, VolumeAverageDays)
+
+// rankActiveStocks runs a query over the volumes CTE, reading each stock
+// with its average and relative volume
+func (d *DatabaseStockService) rankActiveStocks(ctx context.Context, query string, args []interface{}) ([]ActiveStock, error) {
+	rows, err := d.router.Reader().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rank stocks by volume: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+
+	stocks := []ActiveStock{}
+	for rows.Next() {
+		var avgVolume, relativeVolume sql.NullFloat64
+		stock, err := d.scanStockRow(rows, &avgVolume, &relativeVolume)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stock volume: %w", err)
+		}
+		active := ActiveStock{Stock: stock, RankedByVolume: !relativeVolume.Valid}
+		if relativeVolume.Valid {
+			average := int64(avgVolume.Float64 + 0.5)
+			ratio := roundTo(relativeVolume.Float64, 2)
+			active.AvgVolume, active.RelativeVolume = &average, &ratio
+		}
+		stocks = append(stocks, active)
+	}
+	return stocks, queryError(ctx, rows.Err())
+}
+
+// GetVolumeSpikes lists the stocks whose latest volume is at least minRatio
+// times their average, most unusual first. Stocks without the history for
+// an average are left out.
+func (d *DatabaseStockService) GetVolumeSpikes(ctx context.Context, minRatio float64) ([]ActiveStock, error) {
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+
+	// Volume is real even when the previous close is not, so outliers stay in
+	return d.rankActiveStocks(ctx, `WITH changes AS (`+stockChangesQuery+`)`+relativeVolumes+`
+		SELECT * FROM volumes
+		WHERE relative_volume >= $1
+		ORDER BY relative_volume DESC, symbol`, []interface{}{minRatio})
+}
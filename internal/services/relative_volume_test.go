@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTopMovers_MostActiveByRelativeVolume(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewDatabaseStockService(db, nil)
+	service.SetMaxChangePercent(0)
+
+	mock.ExpectQuery(`WHERE change_percent > 0`).WillReturnRows(sqlmock.NewRows(rankedStockColumns))
+	mock.ExpectQuery(`WHERE change_percent < 0`).WillReturnRows(sqlmock.NewRows(rankedStockColumns))
+	// By volume the order would be NEW, BIG, SPIKE: a fifth of BIG's volume is
+	// five times SPIKE's usual, and NEW lacks the history for an average
+	mock.ExpectQuery(`COUNT\(\*\) = 30 AND AVG\(recent.volume\) > 0`).WithArgs(TopMoversLimit).WillReturnRows(activeStocks(
+		[]interface{}{"SPIKE", int64(5000000), 1000000.4, 5.0},
+		[]interface{}{"BIG", int64(900000000), 900000000.0, 1.0},
+		[]interface{}{"NEW", int64(2000000000), nil, nil},
+	))
+
+	movers, err := service.GetTopMovers(context.Background(), TopMoversLimit)
+	require.NoError(t, err)
+	require.Len(t, movers.MostActive, 3)
+
+	spike := movers.MostActive[0]
+	assert.Equal(t, "SPIKE", spike.Symbol)
+	require.NotNil(t, spike.RelativeVolume)
+	assert.Equal(t, 5.0, *spike.RelativeVolume)
+	require.NotNil(t, spike.AvgVolume)
+	assert.Equal(t, int64(1000000), *spike.AvgVolume)
+	assert.False(t, spike.RankedByVolume)
+
+	fallback := movers.MostActive[2]
+	assert.Equal(t, "NEW", fallback.Symbol)
+	assert.Equal(t, int64(2000000000), fallback.Volume)
+	assert.Nil(t, fallback.AvgVolume)
+	assert.Nil(t, fallback.RelativeVolume)
+	assert.True(t, fallback.RankedByVolume)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetVolumeSpikes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewDatabaseStockService(db, nil)
+
+	mock.ExpectQuery(`WHERE relative_volume >= \$1\s+ORDER BY relative_volume DESC, symbol`).WithArgs(DefaultVolumeSpikeRatio).
+		WillReturnRows(activeStocks([]interface{}{"SPIKE", int64(5000000), 1000000.0, 5.0}))
+
+	spikes, err := service.GetVolumeSpikes(context.Background(), DefaultVolumeSpikeRatio)
+	require.NoError(t, err)
+	require.Len(t, spikes, 1)
+	assert.Equal(t, "SPIKE", spikes[0].Symbol)
+	assert.Equal(t, 5.0, *spikes[0].RelativeVolume)
+
+	mock.ExpectQuery(`WHERE relative_volume >= \$1`).WithArgs(10.0).WillReturnRows(activeStocks())
+	spikes, err = service.GetVolumeSpikes(context.Background(), 10)
+	require.NoError(t, err)
+	assert.NotNil(t, spikes, "no spikes is an empty list, not null")
+	assert.Empty(t, spikes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"math"
+	"regexp"
+	"time"
+)
+
+// sandboxCallsPerMinute paces sandbox calls; nothing is fetched so it only shapes the budget
+const sandboxCallsPerMinute = 600
+
+// sandboxHistoryDays is how many trading days of history the sandbox serves
+const sandboxHistoryDays = 120
+
+// sandboxSymbolPattern is what the sandbox accepts as a ticker; anything else is an invalid symbol
+var sandboxSymbolPattern = regexp.MustCompile(`^[A-Z][A-Z.]{0,9}$`)
+
+// SandboxClient is an offline MarketDataProvider serving synthetic prices for
+// development and end-to-end tests. A symbol's bar for a given date never
+// changes, so repeated syncs are idempotent; calls are still budgeted and
+// logged in api_calls like a real provider's.
+type SandboxClient struct {
+	*providerUsage
+	now func() time.Time
+}
+
+func NewSandboxClient(db *sql.DB) *SandboxClient {
+	return &SandboxClient{
+		providerUsage: newProviderUsage(ProviderSandbox, db, sandboxCallsPerMinute),
+		now:           time.Now,
+	}
+}
+
+// FetchDailyData returns the last sandboxHistoryDays trading days for a stock
+func (s *SandboxClient) FetchDailyData(ctx context.Context, symbol string, initiator Initiator) (*DailySeries, error) {
+	if err := s.serve(symbol, initiator, "daily"); err != nil {
+		return nil, err
+	}
+
+	series := &DailySeries{Symbol: symbol, Bars: make([]DailyBar, 0, sandboxHistoryDays)}
+	date := sandboxLastTradingDay(s.now())
+	for len(series.Bars) < sandboxHistoryDays {
+		series.Bars = append(series.Bars, sandboxBar(symbol, date))
+		date = sandboxPreviousTradingDay(date)
+	}
+
+	s.logger.InfoContext(ctx, "Generated sandbox daily data", "symbol", symbol, "days", len(series.Bars))
+	return series, nil
+}
+
+// FetchQuote returns the latest trading day's bar for a stock
+func (s *SandboxClient) FetchQuote(symbol string, initiator Initiator) (*Quote, error) {
+	if err := s.serve(symbol, initiator, "quote"); err != nil {
+		return nil, err
+	}
+	return &Quote{Symbol: symbol, DailyBar: sandboxBar(symbol, sandboxLastTradingDay(s.now()))}, nil
+}
+
+// serve checks the budget and records the call as a real provider would
+func (s *SandboxClient) serve(symbol string, initiator Initiator, endpoint string) error {
+	if err := initiator.Validate(); err != nil {
+		return err
+	}
+
+	canMake, err := s.CanMakeRequest()
+	if err != nil {
+		return err
+	}
+	if !canMake {
+		return &APIError{Provider: ProviderSandbox, Kind: APIErrorRateLimited, Symbol: symbol, Message: "local API budget exhausted"}
+	}
+
+	params := map[string]string{"symbol": symbol}
+	if !sandboxSymbolPattern.MatchString(symbol) {
+		s.LogAPICall(initiator, endpoint, params, 404, "", "unknown symbol", 0)
+		return &APIError{Provider: ProviderSandbox, Kind: APIErrorInvalidSymbol, Symbol: symbol, Message: "unknown symbol"}
+	}
+	if err := s.LogAPICall(initiator, endpoint, params, 200, "", "", 0); err != nil {
+		s.logger.Error("Failed to log API call", "provider", ProviderSandbox, "error", err)
+	}
+	return nil
+}
+
+// sandboxBar derives a bar from the symbol and date alone: each symbol gets its
+// own price level and cycle, with a little day-to-day noise on top
+func sandboxBar(symbol string, date time.Time) DailyBar {
+	seed := sandboxHash(symbol, 0)
+	day := date.Unix() / 86400
+
+	base := 20 + float64(seed%480)
+	phase := float64(seed%628) / 100
+	cycle := 0.08 * math.Sin(float64(day)/15+phase)
+	noise := sandboxNoise(symbol, day)
+
+	close := base * (1 + cycle + 0.02*noise)
+	open := close * (1 - 0.01*sandboxNoise(symbol, -day))
+	high := math.Max(open, close) * 1.01
+	low := math.Min(open, close) * 0.99
+
+	return DailyBar{
+		Date:   date,
+		Open:   sandboxRound(open),
+		High:   sandboxRound(high),
+		Low:    sandboxRound(low),
+		Close:  sandboxRound(close),
+		Volume: int64(1000000 + sandboxHash(symbol, day)%9000000),
+	}
+}
+
+// sandboxNoise is a repeatable value in [-1, 1] for the symbol and day
+func sandboxNoise(symbol string, day int64) float64 {
+	return float64(sandboxHash(symbol, day)%2001)/1000 - 1
+}
+
+func sandboxHash(symbol string, day int64) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(symbol))
+	var b [8]byte
+	for i := range b {
+		b[i] = byte(day >> (8 * i))
+	}
+	h.Write(b[:])
+	return h.Sum64()
+}
+
+func sandboxRound(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// sandboxLastTradingDay is the most recent weekday on or before now, as a UTC date
+func sandboxLastTradingDay(now time.Time) time.Time {
+	date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		date = date.AddDate(0, 0, -1)
+	}
+	return date
+}
+
+func sandboxPreviousTradingDay(date time.Time) time.Time {
+	return sandboxLastTradingDay(date.AddDate(0, 0, -1))
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectSandboxCall expects a fetch to check, log and count against the sandbox budget row
+func expectSandboxCall(mock sqlmock.Sqlmock, endpoint string, status int) {
+	mock.ExpectQuery("FROM api_rate_limits").WithArgs(ProviderSandbox).WillReturnRows(sqlmock.NewRows([]string{
+		"id", "service_name", "daily_limit", "hourly_limit", "current_daily_count",
+		"current_hourly_count", "last_reset_date", "last_reset_hour",
+	}).AddRow(3, ProviderSandbox, 10000, 1000, 0, 0, marketDate(time.Now()), marketHour(time.Now())))
+	mock.ExpectExec("INSERT INTO api_calls").
+		WithArgs(ProviderSandbox, endpoint, sqlmock.AnyArg(), status, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), string(InitiatorManualSync), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRequestRecorded(mock, ProviderSandbox)
+}
+
+func newSandboxTestClient(t *testing.T, now time.Time) (*SandboxClient, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	client := NewSandboxClient(db)
+	client.now = func() time.Time { return now }
+	return client, mock
+}
+
+func TestSandboxFetchDailyData_IsDeterministic(t *testing.T) {
+	// A Sunday: the series ends on the Friday before
+	sunday := time.Date(2024, 5, 5, 15, 0, 0, 0, time.UTC)
+	client, mock := newSandboxTestClient(t, sunday)
+	expectSandboxCall(mock, "daily", http.StatusOK)
+	expectSandboxCall(mock, "daily", http.StatusOK)
+
+	first, err := client.FetchDailyData(context.Background(), "AAPL", InitiatorManualSync)
+	require.NoError(t, err)
+	second, err := client.FetchDailyData(context.Background(), "AAPL", InitiatorManualSync)
+	require.NoError(t, err)
+
+	require.Len(t, first.Bars, sandboxHistoryDays)
+	assert.Equal(t, first.Bars, second.Bars)
+	assert.Equal(t, time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC), first.Bars[0].Date)
+	for _, bar := range first.Bars {
+		assert.NotContains(t, []time.Weekday{time.Saturday, time.Sunday}, bar.Date.Weekday())
+		assert.Greater(t, bar.Close, 0.0)
+		assert.GreaterOrEqual(t, bar.High, bar.Close)
+		assert.LessOrEqual(t, bar.Low, bar.Close)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSandboxFetchQuote_MatchesLatestBar(t *testing.T) {
+	now := time.Date(2024, 5, 3, 21, 0, 0, 0, time.UTC)
+	client, mock := newSandboxTestClient(t, now)
+	expectSandboxCall(mock, "quote", http.StatusOK)
+
+	quote, err := client.FetchQuote("MSFT", InitiatorManualSync)
+
+	require.NoError(t, err)
+	assert.Equal(t, sandboxBar("MSFT", time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)), quote.DailyBar)
+	assert.NotEqual(t, sandboxBar("AAPL", quote.Date).Close, quote.Close, "symbols get their own prices")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSandboxFetchDailyData_UnknownSymbol(t *testing.T) {
+	client, mock := newSandboxTestClient(t, time.Now())
+	expectSandboxCall(mock, "daily", http.StatusNotFound)
+
+	_, err := client.FetchDailyData(context.Background(), "not a symbol", InitiatorManualSync)
+
+	assert.ErrorIs(t, err, ErrInvalidSymbol)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,12 +3,19 @@ package services
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"stock-intelligence-backend/internal/cache"
+	"stock-intelligence-backend/internal/database"
+	"stock-intelligence-backend/internal/jobs"
+	"stock-intelligence-backend/internal/logging"
+	"stock-intelligence-backend/internal/models"
 
 	"github.com/robfig/cron/v3"
 )
@@ -16,26 +23,123 @@ import (
 type SchedulerService struct {
 	cron             *cron.Cron
 	db               *sql.DB
-	alphaVantageClient *AlphaVantageClient
+	provider         MarketDataProvider
+	history          *SyncHistoryService
+	runs             *SchedulerRunService
+	settings         *SystemSettingsService
+	paused           atomic.Bool
 	cache            *cache.RedisCache
+	jobQueue         *jobs.Queue
+	events           *StockEventBus
+	readRouter       *database.ReadRouter
+	priceRanges      *PriceRanges
+	intradayRetention time.Duration
+	apiCallRetention time.Duration
+	syncHistoryRetention time.Duration
+	syncBatchPerHour int
+	quotes           *QuoteRefreshService
+	quoteRefreshPerHour int
+	lastRunProcessed int
+	calendar         *MarketCalendar
+	quality          *DataQualityService
+	queue            *SyncQueue
+	now              func() time.Time
 	mu               sync.RWMutex
 	isRunning        bool
 	ctx              context.Context
 	cancel           context.CancelFunc
 	lastDataSync     time.Time
+	lastSyncSymbol   string
+	lastFundamentalsRefresh time.Time
 	syncErrors       []string
+	logger           *slog.Logger
+	
+	// Webhooks are told when a job's consecutive failures reach the threshold
+	webhooks         *WebhookDispatcher
+	failureThreshold int
+	failureStreaks   map[string]int
+	
+	// Manual sync deduplication: latest manual sync job per symbol
+	manualSyncMu     sync.Mutex
+	manualSyncJobs   map[string]int64
+	dedupWindow      time.Duration
+	dedupHits        int64
 }
 
 type DataSyncStatus struct {
 	IsRunning     bool      `json:"is_running"`
+	// Paused jobs still fire but do nothing until the scheduler is resumed
+	Paused        bool      `json:"paused"`
 	LastSync      time.Time `json:"last_sync"`
 	NextSync      time.Time `json:"next_sync"`
 	TotalStocks   int       `json:"total_stocks"`
 	ProcessedToday int      `json:"processed_today"`
+	// LastRunProcessed is how many stocks the most recent hourly run synced
+	LastRunProcessed int    `json:"last_run_processed"`
 	Errors        []string  `json:"errors,omitempty"`
 }
 
-func NewSchedulerService(db *sql.DB, alphaVantageClient *AlphaVantageClient, redisCache *cache.RedisCache) *SchedulerService {
+// DefaultSyncBatchPerHour caps how many stocks one hourly run syncs
+const DefaultSyncBatchPerHour = 3
+
+// DefaultJobFailureThreshold is how many runs of a scheduled job in a row
+// must fail before webhooks are told
+const DefaultJobFailureThreshold = 3
+
+// JobTypeManualSync is the job queue type for single-symbol manual syncs
+const JobTypeManualSync = "manual_sync"
+
+// DefaultManualSyncDedupWindow is how long a successful manual sync satisfies repeat requests
+const DefaultManualSyncDedupWindow = 10 * time.Minute
+
+// ManualSyncDedupStats describes manual sync deduplication for the API status endpoint
+type ManualSyncDedupStats struct {
+	Window        string `json:"window"`
+	WindowSeconds int    `json:"window_seconds"`
+	Hits          int64  `json:"hits"`
+}
+
+// manualSyncPayload is the job payload for a manual sync
+type manualSyncPayload struct {
+	Symbol string `json:"symbol"`
+	// Mode is empty for jobs queued before modes existed, which were full syncs
+	Mode SyncMode `json:"mode,omitempty"`
+	// RequestID is the ID of the HTTP request that queued the sync, for its log records
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ManualSyncResult is stored as the result of a completed manual sync job
+type ManualSyncResult struct {
+	Symbol   string    `json:"symbol"`
+	Mode     SyncMode  `json:"mode"`
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// States of a symbol's latest manual sync
+const (
+	ManualSyncQueued  = "queued"
+	ManualSyncRunning = "running"
+	ManualSyncDone    = "done"
+	ManualSyncFailed  = "failed"
+)
+
+// ErrNoManualSync is returned for a symbol with no manual sync since the server started
+var ErrNoManualSync = errors.New("no manual sync for this symbol")
+
+// ManualSyncStatus reports a symbol's latest manual sync
+type ManualSyncStatus struct {
+	Symbol     string     `json:"symbol"`
+	State      string     `json:"state"`
+	JobID      int64      `json:"job_id"`
+	QueuedAt   time.Time  `json:"queued_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Result is set once the sync is done, Error once it has failed
+	Result *ManualSyncResult `json:"result,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+func NewSchedulerService(db *sql.DB, provider MarketDataProvider, redisCache *cache.RedisCache, jobQueue *jobs.Queue, events *StockEventBus) *SchedulerService {
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	// Create cron with seconds precision for more flexible scheduling
@@ -44,16 +148,50 @@ func NewSchedulerService(db *sql.DB, alphaVantageClient *AlphaVantageClient, red
 	service := &SchedulerService{
 		cron:               c,
 		db:                 db,
-		alphaVantageClient: alphaVantageClient,
+		provider:           provider,
+		history:            NewSyncHistoryService(db),
+		runs:               NewSchedulerRunService(db),
+		settings:           NewSystemSettingsService(db),
 		cache:              redisCache,
+		jobQueue:           jobQueue,
+		events:             events,
 		ctx:                ctx,
 		cancel:             cancel,
 		syncErrors:         make([]string, 0),
+		manualSyncJobs:     make(map[string]int64),
+		dedupWindow:        DefaultManualSyncDedupWindow,
+		priceRanges:        DefaultPriceRanges(),
+		intradayRetention:  DefaultIntradayRetention,
+		apiCallRetention:   DefaultAPICallRetention,
+		syncHistoryRetention: SyncHistoryRetention,
+		syncBatchPerHour:   DefaultSyncBatchPerHour,
+		calendar:           NewMarketCalendar(),
+		queue:              NewSyncQueue(db),
+		quotes:             NewQuoteRefreshService(db, provider),
+		now:                time.Now,
+		logger:             slog.Default(),
+		failureThreshold:   DefaultJobFailureThreshold,
+		failureStreaks:     make(map[string]int),
+	}
+	service.quality = NewDataQualityService(db, service.calendar)
+	service.queue.quality = service.quality
+	
+	if jobQueue != nil {
+		// Manual syncs spend API budget, so never retry and run one at a time
+		jobQueue.Register(JobTypeManualSync, service.runManualSyncJob, jobs.Options{
+			Concurrency: 1,
+			MaxAttempts: 1,
+		})
 	}
 	
 	return service
 }
 
+// SetLogger sets the logger for scheduled jobs and manual syncs
+func (s *SchedulerService) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
 // Start initializes and starts the scheduler
 func (s *SchedulerService) Start() error {
 	s.mu.Lock()
@@ -63,6 +201,14 @@ func (s *SchedulerService) Start() error {
 		return nil
 	}
 	
+	// A pause set before a restart still applies
+	if value, _, err := s.settings.Get(s.ctx, SettingSchedulerPaused); err != nil {
+		s.logger.Warn("Failed to load paused state, starting unpaused", "error", err)
+	} else if value == "true" {
+		s.paused.Store(true)
+		s.logger.Warn("Scheduler is paused; jobs will not run until it is resumed")
+	}
+	
 	// Schedule hourly data sync job at the top of each hour
 	_, err := s.cron.AddFunc("0 0 * * * *", s.syncStockDataJob)
 	if err != nil {
@@ -81,14 +227,35 @@ func (s *SchedulerService) Start() error {
 		return err
 	}
 	
+	// Schedule intra-day quote refresh at half past each hour, clear of the :00 jobs
+	quoteRefresh := "off"
+	if s.quoteRefreshPerHour > 0 {
+		_, err = s.cron.AddFunc("0 30 * * * *", s.quoteRefreshJob)
+		if err != nil {
+			return err
+		}
+		quoteRefresh = "every hour at :30 while the market is open"
+	}
+	
+	// Schedule news refresh at a quarter to each hour, after the price jobs
+	news := "off"
+	if _, ok := s.provider.(NewsProvider); ok {
+		_, err = s.cron.AddFunc("0 45 * * * *", s.newsRefreshJob)
+		if err != nil {
+			return err
+		}
+		news = "every hour at :45 with budget to spare"
+	}
+	
 	s.cron.Start()
 	s.isRunning = true
 	
-	log.Println("Scheduler service started successfully")
-	log.Println("Jobs scheduled:")
-	log.Println("  - Stock data sync: Every hour at :00 minutes")
-	log.Println("  - Cleanup old data: Daily at 2:00 AM")
-	log.Println("  - Rate limit reset: Every hour at :00 minutes")
+	s.logger.Info("Scheduler service started",
+		"stock_data_sync", "every hour at :00 in the trading-day sync window",
+		"cleanup_old_data", "daily at 2:00 AM",
+		"rate_limit_reset", "every hour at :00",
+		"quote_refresh", quoteRefresh,
+		"news_refresh", news)
 	
 	return nil
 }
@@ -106,127 +273,594 @@ func (s *SchedulerService) Stop() {
 	s.cron.Stop()
 	s.isRunning = false
 	
-	log.Println("Scheduler service stopped")
+	s.logger.Info("Scheduler service stopped")
 }
 
-// syncStockDataJob fetches data for one stock per hour to respect rate limits
+// syncStockDataJob syncs the stalest stocks, as many per hour as the rate
+// limit and the per-run cap allow
 func (s *SchedulerService) syncStockDataJob() {
-	log.Println("Starting hourly stock data sync job")
+	run := s.startRun(SchedulerJobSync)
+	defer s.finishRun(run)
+	if s.skipIfPaused(run) {
+		return
+	}
 	
-	select {
-	case <-s.ctx.Done():
-		log.Println("Sync job cancelled")
+	// Outside the window the provider returns the same data as at the last run
+	if now := s.now(); s.calendar != nil && !s.calendar.InSyncWindow(now) {
+		s.logger.Info("Outside the sync window, skipping this cycle", "next_sync", s.calendar.NextSyncRun(now))
+		run.Detail = "Outside the sync window"
 		return
-	default:
 	}
 	
-	// Check if we can make an API request
-	canMake, err := s.alphaVantageClient.CanMakeRequest()
+	s.logger.Info("Starting hourly stock data sync job", "max_stocks", s.syncBatchPerHour)
+	
+	// Fundamentals only get whatever budget is left once this price sync is done
+	defer s.refreshFundamentalsIfDue()
+	
+	processed := 0
+	defer func() {
+		s.mu.Lock()
+		s.lastRunProcessed = processed
+		s.mu.Unlock()
+		if run.Status != RunStatusFailed {
+			if processed > 0 {
+				run.Status = RunStatusSucceeded
+			}
+			run.Detail = fmt.Sprintf("Synced %d stocks", processed)
+		}
+		s.logger.Info("Finished hourly stock data sync job", "processed", processed)
+	}()
+	
+	attempted := make(map[string]bool)
+	for processed < s.syncBatchPerHour {
+		select {
+		case <-s.ctx.Done():
+			s.logger.Info("Sync job cancelled")
+			return
+		default:
+		}
+		
+		// Check if we can make an API request
+		canMake, err := s.provider.CanMakeRequest()
+		if err != nil {
+			s.failRun(run, "Failed to check rate limit: "+err.Error())
+			return
+		}
+		
+		if !canMake {
+			s.logger.Warn("Rate limit reached, ending this sync cycle", "processed", processed)
+			return
+		}
+		
+		// Get next stock to sync
+		symbol, err := s.getNextStockToSync(s.ctx)
+		if err != nil {
+			s.failRun(run, "Failed to get next stock to sync: "+err.Error())
+			return
+		}
+		
+		if symbol == "" {
+			s.logger.Info("No stocks need syncing at this time")
+			return
+		}
+		
+		// A stock that gained no newer prices stays the stalest; leave it for the next cycle
+		if attempted[symbol] {
+			s.logger.Info("Next stock was already synced this cycle, stopping", "symbol", symbol)
+			return
+		}
+		attempted[symbol] = true
+		
+		// Don't spend budget on a symbol a manual or batch sync is already writing
+		if SymbolSyncInProgress(symbol) {
+			s.logger.Info("Sync already in progress, skipping this cycle", "symbol", symbol)
+			return
+		}
+		
+		if !s.syncStock(run, symbol) {
+			return
+		}
+		processed++
+	}
+}
+
+// syncStock fetches and saves one stock's missing prices, reporting whether it
+// succeeded. Failures are recorded against run and end the current cycle.
+func (s *SchedulerService) syncStock(run *SchedulerRun, symbol string) bool {
+	s.logger.Info("Syncing data", "symbol", symbol)
+	result := StockSyncResult{Symbol: symbol, StartTime: time.Now()}
+	
+	// Queries run under the scheduler's context so Stop cancels them
+	data, err := fetchMissingDailyData(s.ctx, s.db, s.provider, symbol, InitiatorScheduler)
+	if err != nil {
+		result.ErrorKind = ErrorKindOf(err)
+		result.ErrorMessage = err.Error()
+		s.recordSync(result)
+		if errors.Is(err, ErrRateLimited) {
+			// Expected when the allowance is spent; the next cycle picks the symbol up again
+			s.logger.Warn("Rate limited while syncing, will retry next cycle", "symbol", symbol, "error", err)
+			return false
+		}
+		s.failRun(run, "Failed to fetch data for "+symbol+": "+err.Error())
+		return false
+	}
+	
+	result.RowsSkipped = data.Rejected
+	stats, err := s.provider.SaveHistoricalData(s.ctx, symbol, data, SyncLockSkip)
+	result.RowsSkipped = append(result.RowsSkipped, stats.Rejected...)
+	if errors.Is(err, ErrSyncInProgress) {
+		s.logger.Info("Sync started elsewhere while fetching, skipping save", "symbol", symbol)
+		return false
+	}
+	if err != nil {
+		result.ErrorKind = APIErrorSaveFailed
+		result.ErrorMessage = fmt.Sprintf("Failed to save data: %v", err)
+		s.recordSync(result)
+		s.failRun(run, "Failed to save data for "+symbol+": "+err.Error())
+		return false
+	}
+	result.Success = true
+	result.RecordsAdded = stats.Inserted
+	s.recordSync(result)
+	
+	// Invalidate all caches immediately when new data arrives, even if Stop
+	// cancels the scheduler now that the data is saved
+	if s.cache != nil {
+		err = s.cache.InvalidateAll(context.WithoutCancel(s.ctx))
+		if err != nil {
+			s.logger.Warn("Failed to invalidate cache after data update", "symbol", symbol, "error", err)
+		} else {
+			s.logger.Debug("Cache invalidated after data update", "symbol", symbol)
+		}
+	}
+	
+	// Update stock's last sync time
+	err = s.updateStockSyncTime(s.ctx, symbol)
 	if err != nil {
-		s.addError("Failed to check rate limit: " + err.Error())
+		s.addError("Failed to update sync time for " + symbol + ": " + err.Error())
+	}
+	
+	s.mu.Lock()
+	s.lastDataSync = time.Now()
+	s.lastSyncSymbol = symbol
+	s.mu.Unlock()
+	run.Symbol = symbol
+	
+	// Keep reads of this symbol off the replica until it has caught up
+	s.readRouter.MarkWritten(symbol)
+	
+	// Let live clients know real data is available
+	s.events.PublishStockUpdated(symbol)
+	
+	s.logger.Info("Successfully synced data", "symbol", symbol)
+	return true
+}
+
+// quoteRefreshJob updates the latest price of the stocks refreshed longest
+// ago, one quote each, while the market is open
+func (s *SchedulerService) quoteRefreshJob() {
+	run := s.startRun(SchedulerJobQuoteRefresh)
+	defer s.finishRun(run)
+	if s.skipIfPaused(run) {
 		return
 	}
 	
-	if !canMake {
-		log.Println("Rate limit reached, skipping this sync cycle")
+	if now := s.now(); s.calendar != nil && !s.calendar.IsMarketOpen(now) {
+		run.Detail = "Market closed"
 		return
 	}
 	
-	// Get next stock to sync
-	symbol, err := s.getNextStockToSync()
+	symbols, err := s.quotes.NextToRefresh(s.ctx, s.quoteRefreshPerHour)
 	if err != nil {
-		s.addError("Failed to get next stock to sync: " + err.Error())
+		s.failRun(run, "Failed to get stocks to refresh: "+err.Error())
 		return
 	}
 	
+	refreshed := 0
+	defer func() {
+		if run.Status != RunStatusFailed {
+			if refreshed > 0 {
+				run.Status = RunStatusSucceeded
+			}
+			run.Detail = fmt.Sprintf("Refreshed %d quotes", refreshed)
+		}
+		s.logger.Info("Finished quote refresh job", "refreshed", refreshed)
+	}()
+	
+	for _, symbol := range symbols {
+		select {
+		case <-s.ctx.Done():
+			s.logger.Info("Quote refresh cancelled")
+			return
+		default:
+		}
+		
+		canMake, err := s.provider.CanMakeRequest()
+		if err != nil {
+			s.failRun(run, "Failed to check rate limit: "+err.Error())
+			return
+		}
+		if !canMake {
+			s.logger.Warn("Rate limit reached, ending this quote refresh", "refreshed", refreshed)
+			return
+		}
+		
+		if SymbolSyncInProgress(symbol) {
+			s.logger.Info("Sync already in progress, skipping quote", "symbol", symbol)
+			continue
+		}
+		
+		if !s.refreshQuote(run, symbol) {
+			return
+		}
+		refreshed++
+	}
+}
+
+// refreshQuote updates one stock's latest trading day from a quote, reporting
+// whether it succeeded. Failures are recorded against run.
+func (s *SchedulerService) refreshQuote(run *SchedulerRun, symbol string) bool {
+	result := StockSyncResult{Symbol: symbol, StartTime: time.Now()}
+	
+	stats, err := s.quotes.RefreshQuote(s.ctx, symbol, InitiatorScheduler, SyncLockSkip)
+	if errors.Is(err, ErrSyncInProgress) {
+		s.logger.Info("Sync started elsewhere while fetching, skipping quote", "symbol", symbol)
+		return true
+	}
+	if err != nil {
+		result.ErrorKind = ErrorKindOf(err)
+		result.ErrorMessage = err.Error()
+		s.recordSync(result)
+		if errors.Is(err, ErrRateLimited) {
+			s.logger.Warn("Rate limited while refreshing quotes, will retry next hour", "symbol", symbol, "error", err)
+			return false
+		}
+		s.failRun(run, "Failed to refresh quote for "+symbol+": "+err.Error())
+		return false
+	}
+	result.Success = true
+	result.RecordsAdded = stats.Inserted
+	result.RowsSkipped = stats.Rejected
+	s.recordSync(result)
+	
+	if s.cache != nil {
+		if err := s.cache.InvalidateAll(context.WithoutCancel(s.ctx)); err != nil {
+			s.logger.Warn("Failed to invalidate cache after quote refresh", "symbol", symbol, "error", err)
+		}
+	}
+	run.Symbol = symbol
+	s.readRouter.MarkWritten(symbol)
+	s.events.PublishStockUpdated(symbol)
+	return true
+}
+
+// newsRefreshJob fetches the news of the stock fetched longest ago. It has
+// the lowest priority of the jobs: a call is only spent while at least
+// NewsMinDailyCallsLeft remain, and never one the hourly price syncs still need today.
+func (s *SchedulerService) newsRefreshJob() {
+	run := s.startRun(SchedulerJobNews)
+	defer s.finishRun(run)
+	if s.skipIfPaused(run) {
+		return
+	}
+	
+	newsProvider, ok := s.provider.(NewsProvider)
+	if !ok {
+		run.Detail = "Provider serves no news"
+		return
+	}
+	
+	budget, err := s.provider.GetAPIBudget()
+	if err != nil {
+		s.failRun(run, "Failed to check budget for news refresh: "+err.Error())
+		return
+	}
+	reservedForPrices := 23 - s.now().Hour()
+	if !budget.CanMakeRequest || budget.DailyRemaining < NewsMinDailyCallsLeft || budget.DailyRemaining <= reservedForPrices {
+		s.logger.Info("Skipping news refresh", "calls_left", budget.DailyRemaining, "reserved_for_prices", reservedForPrices)
+		run.Detail = fmt.Sprintf("Only %d calls left today", budget.DailyRemaining)
+		return
+	}
+	
+	symbol, err := s.getNextNewsToRefresh(s.ctx)
+	if err != nil {
+		s.failRun(run, "Failed to get next stock for news refresh: "+err.Error())
+		return
+	}
 	if symbol == "" {
-		log.Println("No stocks need syncing at this time")
+		run.Detail = "No stocks to refresh"
 		return
 	}
+	run.Symbol = symbol
 	
-	// Fetch and save data for the stock
-	log.Printf("Syncing data for %s", symbol)
+	articles, err := newsProvider.FetchNewsSentiment(s.ctx, symbol, InitiatorScheduler)
+	if errors.Is(err, ErrRateLimited) {
+		s.logger.Warn("Rate limited while refreshing news", "symbol", symbol, "error", err)
+		run.Detail = "Rate limited"
+		return
+	}
+	if err != nil {
+		s.failRun(run, "Failed to fetch news for "+symbol+": "+err.Error())
+		return
+	}
 	
-	data, err := s.alphaVantageClient.FetchDailyData(symbol)
+	inserted, err := saveStockNews(s.ctx, s.db, symbol, articles)
 	if err != nil {
-		s.addError("Failed to fetch data for " + symbol + ": " + err.Error())
+		s.failRun(run, "Failed to save news for "+symbol+": "+err.Error())
+		return
+	}
+	
+	run.Status = RunStatusSucceeded
+	run.Detail = fmt.Sprintf("Stored %d new of %d articles", inserted, len(articles))
+	s.logger.Info("Refreshed news", "symbol", symbol, "articles", len(articles), "new", inserted)
+}
+
+// getNextNewsToRefresh returns the active stock whose news was fetched longest
+// ago, largest first among those never fetched, or "" when there is none
+func (s *SchedulerService) getNextNewsToRefresh(ctx context.Context) (string, error) {
+	query := `
+		SELECT s.symbol
+		FROM stocks s
+		WHERE s.is_active = true AND s.delisted_at IS NULL
+		ORDER BY s.news_fetched_at ASC NULLS FIRST, s.market_cap DESC NULLS LAST, s.symbol
+		LIMIT 1
+	`
+	
+	var symbol string
+	err := s.db.QueryRowContext(ctx, query).Scan(&symbol)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return symbol, err
+}
+
+// Pause stops scheduled jobs from doing any work, including spending API
+// budget, until Resume. The jobs keep firing and record skipped runs. The
+// pause is saved so it survives a restart; if saving fails the scheduler is
+// still paused until the process exits.
+func (s *SchedulerService) Pause(ctx context.Context) error {
+	s.paused.Store(true)
+	s.logger.Warn("Scheduler paused")
+	return s.settings.Set(ctx, SettingSchedulerPaused, "true")
+}
+
+// Resume lets scheduled jobs run again from their next firing
+func (s *SchedulerService) Resume(ctx context.Context) error {
+	s.paused.Store(false)
+	s.logger.Info("Scheduler resumed")
+	return s.settings.Set(ctx, SettingSchedulerPaused, "false")
+}
+
+// skipIfPaused marks run skipped and reports true while the scheduler is paused
+func (s *SchedulerService) skipIfPaused(run *SchedulerRun) bool {
+	if !s.paused.Load() {
+		return false
+	}
+	s.logger.Info("Scheduler paused, skipping job", "job", run.JobName)
+	run.Status = RunStatusSkipped
+	run.Detail = "Scheduler paused"
+	return true
+}
+
+// startRun begins recording a run of job; it counts as skipped unless marked otherwise
+func (s *SchedulerService) startRun(job string) *SchedulerRun {
+	return &SchedulerRun{JobName: job, StartedAt: time.Now(), Status: RunStatusSkipped}
+}
+
+// failRun marks run failed with msg, which also goes to the recent errors
+func (s *SchedulerService) failRun(run *SchedulerRun, msg string) {
+	s.addError(msg)
+	run.Status = RunStatusFailed
+	run.Detail = msg
+}
+
+// finishRun stores run in scheduler_runs
+func (s *SchedulerService) finishRun(run *SchedulerRun) {
+	run.FinishedAt = time.Now()
+	// Stored even when Stop cancelled the job part-way
+	if err := s.runs.Record(context.WithoutCancel(s.ctx), run); err != nil {
+		s.logger.Warn("Failed to record scheduler run", "job", run.JobName, "error", err)
+	}
+	s.trackFailures(run)
+}
+
+// JobFailingEvent is the data of a scheduler.job_failing webhook
+type JobFailingEvent struct {
+	Job                 string    `json:"job"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error"`
+	FailedAt            time.Time `json:"failed_at"`
+}
+
+// trackFailures counts a job's failed runs in a row, which a success ends,
+// and tells webhooks once when the count reaches the threshold. Skipped runs
+// leave the count as it is.
+func (s *SchedulerService) trackFailures(run *SchedulerRun) {
+	s.mu.Lock()
+	switch run.Status {
+	case RunStatusFailed:
+		s.failureStreaks[run.JobName]++
+	case RunStatusSucceeded:
+		s.failureStreaks[run.JobName] = 0
+	}
+	streak := s.failureStreaks[run.JobName]
+	webhooks, threshold := s.webhooks, s.failureThreshold
+	s.mu.Unlock()
+
+	if webhooks != nil && run.Status == RunStatusFailed && streak == threshold {
+		webhooks.Dispatch(WebhookEventSchedulerJobFailing, JobFailingEvent{
+			Job:                 run.JobName,
+			ConsecutiveFailures: streak,
+			LastError:           run.Detail,
+			FailedAt:            run.FinishedAt,
+		})
+	}
+}
+
+// ListRuns returns up to limit recent runs of job, or of every job when job is empty
+func (s *SchedulerService) ListRuns(ctx context.Context, job string, limit int) ([]SchedulerRun, error) {
+	return s.runs.List(ctx, job, limit)
+}
+
+// recordSync stores the outcome of a scheduled sync in the sync history
+func (s *SchedulerService) recordSync(result StockSyncResult) {
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime)
+	if err := s.history.Record(s.ctx, InitiatorScheduler, result); err != nil {
+		s.logger.Warn("Failed to record sync history", "symbol", result.Symbol, "error", err)
+	}
+}
+
+// refreshFundamentalsIfDue refreshes one stock's fundamentals per day. Prices take
+// priority: a call is only spent if the budget still covers a price sync for every
+// remaining hour of the day.
+func (s *SchedulerService) refreshFundamentalsIfDue() {
+	fundamentalsProvider, ok := s.provider.(FundamentalsProvider)
+	if !ok {
+		return
+	}
+	
+	now := time.Now()
+	s.mu.RLock()
+	lastRefresh := s.lastFundamentalsRefresh
+	s.mu.RUnlock()
+	if lastRefresh.Year() == now.Year() && lastRefresh.YearDay() == now.YearDay() {
 		return
 	}
 	
-	err = s.alphaVantageClient.SaveHistoricalData(symbol, data)
+	budget, err := s.provider.GetAPIBudget()
 	if err != nil {
-		s.addError("Failed to save data for " + symbol + ": " + err.Error())
+		s.addError("Failed to check budget for fundamentals refresh: " + err.Error())
 		return
 	}
 	
-	// Invalidate all caches immediately when new data arrives
-	if s.cache != nil {
-		err = s.cache.InvalidateAll()
-		if err != nil {
-			log.Printf("Warning: Failed to invalidate cache after data update: %v", err)
-		} else {
-			log.Printf("🔄 Cache invalidated after data update for %s", symbol)
-		}
+	reservedForPrices := 23 - now.Hour()
+	if !budget.CanMakeRequest || budget.DailyRemaining <= reservedForPrices {
+		s.logger.Info("Skipping fundamentals refresh", "calls_left", budget.DailyRemaining, "reserved_for_prices", reservedForPrices)
+		return
 	}
 	
-	// Update stock's last sync time
-	err = s.updateStockSyncTime(symbol)
+	symbol, err := s.getNextFundamentalsToRefresh(s.ctx)
 	if err != nil {
-		s.addError("Failed to update sync time for " + symbol + ": " + err.Error())
+		s.addError("Failed to get next stock for fundamentals refresh: " + err.Error())
+		return
+	}
+	if symbol == "" {
+		return
 	}
 	
+	fundamentals, err := fundamentalsProvider.FetchCompanyOverview(symbol, InitiatorScheduler)
+	if errors.Is(err, ErrRateLimited) {
+		s.logger.Warn("Rate limited while refreshing fundamentals", "symbol", symbol, "error", err)
+		return
+	}
+	
+	// The call is spent either way; don't try another stock until tomorrow
 	s.mu.Lock()
-	s.lastDataSync = time.Now()
+	s.lastFundamentalsRefresh = now
 	s.mu.Unlock()
 	
-	log.Printf("✅ Successfully synced data for %s", symbol)
+	if err != nil {
+		s.addError("Failed to fetch fundamentals for " + symbol + ": " + err.Error())
+		return
+	}
+	
+	if err := fundamentalsProvider.SaveCompanyFundamentals(s.ctx, fundamentals); err != nil {
+		s.addError("Failed to save fundamentals for " + symbol + ": " + err.Error())
+		return
+	}
+	
+	s.logger.Info("Refreshed fundamentals", "symbol", symbol)
 }
 
-// getNextStockToSync returns the stock symbol that needs syncing most urgently
-func (s *SchedulerService) getNextStockToSync() (string, error) {
+// getNextFundamentalsToRefresh returns the active stock with missing or oldest fundamentals
+func (s *SchedulerService) getNextFundamentalsToRefresh(ctx context.Context) (string, error) {
 	query := `
-		SELECT s.symbol 
+		SELECT s.symbol
 		FROM stocks s
-		LEFT JOIN daily_prices dp ON s.id = dp.stock_id
+		LEFT JOIN company_fundamentals cf ON cf.stock_id = s.id
 		WHERE s.is_active = true
-		GROUP BY s.id, s.symbol
-		ORDER BY MAX(dp.date) ASC NULLS FIRST, s.symbol
+		ORDER BY cf.fetched_at ASC NULLS FIRST, s.symbol
 		LIMIT 1
 	`
 	
 	var symbol string
-	err := s.db.QueryRow(query).Scan(&symbol)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", nil
-		}
+	err := s.db.QueryRowContext(ctx, query).Scan(&symbol)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return symbol, err
+}
+
+// getNextStockToSync returns the top of the sync queue, or "" when every stock is up to date
+func (s *SchedulerService) getNextStockToSync(ctx context.Context) (string, error) {
+	candidates, err := s.queue.Next(ctx, s.now(), 1)
+	if err != nil || len(candidates) == 0 {
 		return "", err
 	}
-	
-	return symbol, nil
+	return candidates[0].Symbol, nil
+}
+
+// GetSyncQueue returns the next limit stocks the scheduled sync would pick, in order
+func (s *SchedulerService) GetSyncQueue(ctx context.Context, limit int) ([]SyncCandidate, error) {
+	return s.queue.Next(ctx, s.now(), limit)
+}
+
+// GetDataGaps returns the active stocks with gaps of at least minGapDays
+// trading days in their stored prices; the sync queue backfills them
+func (s *SchedulerService) GetDataGaps(ctx context.Context, minGapDays int) ([]SymbolGaps, error) {
+	return s.quality.GapReport(ctx, minGapDays)
 }
 
 // updateStockSyncTime updates the updated_at timestamp for a stock
-func (s *SchedulerService) updateStockSyncTime(symbol string) error {
-	query := `UPDATE stocks SET updated_at = CURRENT_TIMESTAMP WHERE symbol = $1`
-	_, err := s.db.Exec(query, symbol)
+func (s *SchedulerService) updateStockSyncTime(ctx context.Context, symbol string) error {
+	query := `UPDATE stocks SET updated_at = CURRENT_TIMESTAMP, ` + s.priceRanges.storedColumnAssignment() + ` WHERE symbol = $1`
+	_, err := s.db.ExecContext(ctx, query, symbol)
 	return err
 }
 
 // cleanupOldDataJob removes old API call logs and performs maintenance
 func (s *SchedulerService) cleanupOldDataJob() {
-	log.Println("Starting daily cleanup job")
+	s.logger.Info("Starting daily cleanup job")
 	
-	// Keep API call logs for last 30 days
-	query := `DELETE FROM api_calls WHERE created_at < CURRENT_TIMESTAMP - INTERVAL '30 days'`
-	result, err := s.db.Exec(query)
+	run := s.startRun(SchedulerJobCleanup)
+	defer s.finishRun(run)
+	if s.skipIfPaused(run) {
+		return
+	}
+	run.Status = RunStatusSucceeded
+	
+	// API call logs are only kept for the retention window
+	rowsDeleted, err := pruneAPICalls(s.ctx, s.db, s.apiCallRetention)
 	if err != nil {
-		s.addError("Failed to cleanup old API calls: " + err.Error())
+		s.failRun(run, "Failed to cleanup old API calls: "+err.Error())
 		return
 	}
+	s.logger.Info("Cleaned up old API call records", "rows", rowsDeleted, "retention_days", retentionDays(s.apiCallRetention))
+	
+	// Intraday bars are only kept for the retention window
+	intradayDays := retentionDays(s.intradayRetention)
+	result, err := s.db.ExecContext(s.ctx, `DELETE FROM intraday_prices WHERE "timestamp" < CURRENT_TIMESTAMP - $1 * INTERVAL '1 day'`, intradayDays)
+	if err != nil {
+		s.failRun(run, "Failed to cleanup old intraday prices: "+err.Error())
+	} else {
+		rowsDeleted, _ = result.RowsAffected()
+		s.logger.Info("Cleaned up old intraday price records", "rows", rowsDeleted, "retention_days", intradayDays)
+	}
 	
-	rowsDeleted, _ := result.RowsAffected()
-	log.Printf("Cleaned up %d old API call records", rowsDeleted)
+	rowsDeleted, err = s.history.Prune(s.ctx, s.syncHistoryRetention)
+	if err != nil {
+		s.failRun(run, "Failed to cleanup old sync history: "+err.Error())
+	} else {
+		s.logger.Info("Cleaned up old sync history records", "rows", rowsDeleted, "retention_days", retentionDays(s.syncHistoryRetention))
+	}
+	
+	rowsDeleted, err = s.runs.Prune(s.ctx, SchedulerRunRetention)
+	if err != nil {
+		s.failRun(run, "Failed to cleanup old scheduler runs: "+err.Error())
+	} else {
+		s.logger.Info("Cleaned up old scheduler runs", "rows", rowsDeleted)
+	}
 	
 	// Clear error list if it gets too long
 	s.mu.Lock()
@@ -235,35 +869,30 @@ func (s *SchedulerService) cleanupOldDataJob() {
 	}
 	s.mu.Unlock()
 	
-	log.Println("Daily cleanup job completed")
+	s.logger.Info("Daily cleanup job completed")
 }
 
-// resetRateLimitsJob ensures rate limits are properly reset
+// resetRateLimitsJob rolls over the rate limit counters left from an earlier hour
 func (s *SchedulerService) resetRateLimitsJob() {
-	// The database trigger handles most of this, but we can add extra validation here
-	query := `
-		UPDATE api_rate_limits 
-		SET current_daily_count = 0,
-		    current_hourly_count = 0,
-		    last_reset_date = CURRENT_DATE,
-		    last_reset_hour = EXTRACT(HOUR FROM CURRENT_TIMESTAMP),
-		    updated_at = CURRENT_TIMESTAMP
-		WHERE service_name = 'alphavantage' 
-		  AND (last_reset_date < CURRENT_DATE 
-		       OR (last_reset_date = CURRENT_DATE 
-		           AND last_reset_hour < EXTRACT(HOUR FROM CURRENT_TIMESTAMP)))
-	`
+	run := s.startRun(SchedulerJobRateLimitReset)
+	defer s.finishRun(run)
+	if s.skipIfPaused(run) {
+		return
+	}
 	
-	result, err := s.db.Exec(query)
+	rowsAffected, err := RollOverRateLimits(s.ctx, s.db, s.now())
 	if err != nil {
-		log.Printf("Failed to reset rate limits: %v", err)
+		s.logger.Error("Failed to reset rate limits", "error", err)
+		run.Status = RunStatusFailed
+		run.Detail = "Failed to reset rate limits: " + err.Error()
 		return
 	}
 	
-	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected > 0 {
-		log.Printf("Reset rate limits for %d services", rowsAffected)
+		s.logger.Info("Reset rate limits", "services", rowsAffected)
+		run.Status = RunStatusSucceeded
 	}
+	run.Detail = fmt.Sprintf("Reset %d services", rowsAffected)
 }
 
 // GetStatus returns the current status of the data sync service
@@ -275,18 +904,29 @@ func (s *SchedulerService) GetStatus() DataSyncStatus {
 	var totalStocks int
 	s.db.QueryRow("SELECT COUNT(*) FROM stocks WHERE is_active = true").Scan(&totalStocks)
 	
-	// Get stocks processed today
+	// Get stocks processed today. created_at holds the database server's
+	// local time, so it is converted to the Eastern-time date to compare.
+	now := s.now()
 	var processedToday int
 	query := `
 		SELECT COUNT(DISTINCT stock_id) 
 		FROM daily_prices 
-		WHERE DATE(created_at) = CURRENT_DATE
+		WHERE (created_at::timestamptz AT TIME ZONE 'America/New_York')::date = $1
 	`
-	s.db.QueryRow(query).Scan(&processedToday)
+	s.db.QueryRow(query, marketDate(now)).Scan(&processedToday)
 	
-	// Calculate next sync time (next hour)
-	now := time.Now()
+	// Scheduled syncs are read back from the stored runs so the last one
+	// survives restarts; a manual sync since then may be more recent
+	lastSync := s.lastDataSync
+	if stored, err := s.runs.LastSync(s.ctx); err == nil && stored.After(lastSync) {
+		lastSync = stored
+	}
+	
+	// Calculate next sync time: the next hourly run the calendar allows
 	nextSync := time.Date(now.Year(), now.Month(), now.Day(), now.Hour()+1, 0, 0, 0, now.Location())
+	if s.calendar != nil {
+		nextSync = s.calendar.NextSyncRun(now)
+	}
 	
 	// Copy errors to avoid holding lock too long
 	errors := make([]string, len(s.syncErrors))
@@ -294,14 +934,42 @@ func (s *SchedulerService) GetStatus() DataSyncStatus {
 	
 	return DataSyncStatus{
 		IsRunning:      s.isRunning,
-		LastSync:       s.lastDataSync,
+		Paused:         s.paused.Load(),
+		LastSync:       lastSync,
 		NextSync:       nextSync,
 		TotalStocks:    totalStocks,
 		ProcessedToday: processedToday,
+		LastRunProcessed: s.lastRunProcessed,
 		Errors:         errors,
 	}
 }
 
+// SchedulerActivity is the scheduler's in-memory state, available without querying the database
+type SchedulerActivity struct {
+	IsRunning      bool      `json:"is_running"`
+	LastSync       time.Time `json:"last_sync"`
+	LastSyncSymbol string    `json:"last_sync_symbol"`
+	RecentErrors   []string  `json:"recent_errors"`
+}
+
+// GetRecentActivity returns the last successful sync and up to maxErrors of the newest errors, newest first
+func (s *SchedulerService) GetRecentActivity(maxErrors int) SchedulerActivity {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	recent := make([]string, 0, maxErrors)
+	for i := len(s.syncErrors) - 1; i >= 0 && len(recent) < maxErrors; i-- {
+		recent = append(recent, s.syncErrors[i])
+	}
+	
+	return SchedulerActivity{
+		IsRunning:      s.isRunning,
+		LastSync:       s.lastDataSync,
+		LastSyncSymbol: s.lastSyncSymbol,
+		RecentErrors:   recent,
+	}
+}
+
 // addError adds an error to the error list with timestamp
 func (s *SchedulerService) addError(errorMsg string) {
 	s.mu.Lock()
@@ -315,12 +983,19 @@ func (s *SchedulerService) addError(errorMsg string) {
 		s.syncErrors = s.syncErrors[1:]
 	}
 	
-	log.Printf("Sync error: %s", errorMsg)
+	s.logger.Error("Sync error", "error", errorMsg)
 }
 
-// TriggerManualSync triggers a manual data sync for a specific stock
-func (s *SchedulerService) TriggerManualSync(symbol string) error {
-	canMake, err := s.alphaVantageClient.CanMakeRequest()
+// runManualSync fetches and saves a symbol's prices now; queued manual sync jobs run it.
+// A quote sync of a stock with no stored prices runs as a full sync, as one
+// quote would start its history at today.
+func (s *SchedulerService) runManualSync(ctx context.Context, symbol string, mode SyncMode) error {
+	symbol, err := models.NormalizeSymbol(symbol)
+	if err != nil {
+		return err
+	}
+	
+	canMake, err := s.provider.CanMakeRequest()
 	if err != nil {
 		return err
 	}
@@ -329,36 +1004,294 @@ func (s *SchedulerService) TriggerManualSync(symbol string) error {
 		return fmt.Errorf("rate limit exceeded, cannot perform manual sync")
 	}
 	
-	log.Printf("Manual sync triggered for %s", symbol)
+	if SymbolSyncInProgress(symbol) {
+		return ErrSyncInProgress
+	}
 	
-	data, err := s.alphaVantageClient.FetchDailyData(symbol)
-	if err != nil {
-		return err
+	if mode == SyncModeQuote {
+		latest, err := latestStoredPriceDate(ctx, s.db, symbol)
+		if err != nil {
+			return err
+		}
+		if latest.IsZero() {
+			s.logger.InfoContext(ctx, "No stored prices, running a full sync instead of a quote", "symbol", symbol)
+			mode = SyncModeFull
+		}
 	}
 	
-	err = s.alphaVantageClient.SaveHistoricalData(symbol, data)
-	if err != nil {
-		return err
+	s.logger.InfoContext(ctx, "Manual sync triggered", "symbol", symbol, "mode", mode)
+	
+	if mode == SyncModeQuote {
+		if _, err := s.quotes.RefreshQuote(ctx, symbol, InitiatorManualSync, SyncLockSkip); err != nil {
+			return err
+		}
+	} else {
+		data, err := s.provider.FetchDailyData(ctx, symbol, InitiatorManualSync)
+		if err != nil {
+			return err
+		}
+		
+		_, err = s.provider.SaveHistoricalData(ctx, symbol, data, SyncLockSkip)
+		if err != nil {
+			return err
+		}
 	}
 	
 	// Invalidate all caches immediately when new data arrives (manual sync)
 	if s.cache != nil {
-		err = s.cache.InvalidateAll()
+		err = s.cache.InvalidateAll(context.WithoutCancel(ctx))
 		if err != nil {
-			log.Printf("Warning: Failed to invalidate cache after manual sync: %v", err)
+			s.logger.WarnContext(ctx, "Failed to invalidate cache after manual sync", "symbol", symbol, "error", err)
 		} else {
-			log.Printf("🔄 Cache invalidated after manual sync for %s", symbol)
+			s.logger.DebugContext(ctx, "Cache invalidated after manual sync", "symbol", symbol)
 		}
 	}
 	
-	err = s.updateStockSyncTime(symbol)
-	if err != nil {
-		log.Printf("Failed to update sync time for %s: %v", symbol, err)
+	// A quote leaves the rest of the history as it was, so it does not count as a sync
+	if mode == SyncModeFull {
+		err = s.updateStockSyncTime(ctx, symbol)
+		if err != nil {
+			s.logger.WarnContext(ctx, "Failed to update sync time", "symbol", symbol, "error", err)
+		}
 	}
 	
 	s.mu.Lock()
 	s.lastDataSync = time.Now()
+	s.lastSyncSymbol = symbol
 	s.mu.Unlock()
 	
+	s.readRouter.MarkWritten(symbol)
+	s.events.PublishStockUpdated(symbol)
+	
 	return nil
-}
\ No newline at end of file
+}
+
+// SetReadRouter lets completed syncs pin reads of the synced symbol to the primary
+func (s *SchedulerService) SetReadRouter(router *database.ReadRouter) {
+	s.readRouter = router
+}
+
+// SetPriceRanges sets the buckets used to refresh the stored price_range after a sync
+func (s *SchedulerService) SetPriceRanges(ranges *PriceRanges) {
+	s.priceRanges = ranges
+}
+
+// SetIntradayRetention sets how long intraday bars are kept by the daily cleanup
+func (s *SchedulerService) SetIntradayRetention(retention time.Duration) {
+	if retention > 0 {
+		s.intradayRetention = retention
+	}
+}
+
+// SetAPICallRetention sets how long API call logs are kept by the daily cleanup
+func (s *SchedulerService) SetAPICallRetention(retention time.Duration) {
+	if retention > 0 {
+		s.apiCallRetention = retention
+	}
+}
+
+// SetSyncHistoryRetention sets how long sync outcomes are kept by the daily cleanup
+func (s *SchedulerService) SetSyncHistoryRetention(retention time.Duration) {
+	if retention > 0 {
+		s.syncHistoryRetention = retention
+	}
+}
+
+// SetMarketCalendar sets the calendar that decides when scheduled syncs run;
+// nil syncs every hour around the clock
+func (s *SchedulerService) SetMarketCalendar(calendar *MarketCalendar) {
+	s.calendar = calendar
+	s.quality.calendar = calendar
+	s.quotes.calendar = calendar
+}
+
+// SetSyncQueueWeights sets how the sync queue weighs staleness, gaps, S&P 500 priority and market cap
+func (s *SchedulerService) SetSyncQueueWeights(weights SyncQueueWeights) error {
+	return s.queue.SetWeights(weights)
+}
+
+// SetWebhookDispatcher sends scheduler.job_failing to webhooks once a job
+// has failed threshold runs in a row; a threshold below 1 keeps the default
+func (s *SchedulerService) SetWebhookDispatcher(webhooks *WebhookDispatcher, threshold int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhooks = webhooks
+	if threshold > 0 {
+		s.failureThreshold = threshold
+	}
+}
+
+// SetSyncBatchPerHour sets how many stocks one hourly run may sync
+func (s *SchedulerService) SetSyncBatchPerHour(n int) {
+	if n > 0 {
+		s.syncBatchPerHour = n
+	}
+}
+
+// SetQuoteRefreshPerHour sets how many stocks the intra-day quote refresh
+// updates each hour while the market is open; zero, the default, leaves the
+// job unscheduled. It must be set before Start.
+func (s *SchedulerService) SetQuoteRefreshPerHour(n int) {
+	if n >= 0 {
+		s.quoteRefreshPerHour = n
+	}
+}
+
+// SetManualSyncDedupWindow sets how long a successful manual sync is reused; zero disables reuse of finished syncs
+func (s *SchedulerService) SetManualSyncDedupWindow(window time.Duration) {
+	s.manualSyncMu.Lock()
+	defer s.manualSyncMu.Unlock()
+	
+	s.dedupWindow = window
+}
+
+// GetManualSyncDedupStats returns the dedup window and how many requests it has absorbed
+func (s *SchedulerService) GetManualSyncDedupStats() ManualSyncDedupStats {
+	s.manualSyncMu.Lock()
+	defer s.manualSyncMu.Unlock()
+	
+	return ManualSyncDedupStats{
+		Window:        s.dedupWindow.String(),
+		WindowSeconds: int(s.dedupWindow.Seconds()),
+		Hits:          s.dedupHits,
+	}
+}
+
+// TriggerManualSync queues a manual sync for a symbol on the background job
+// queue and returns without waiting for the provider.
+// If a sync for the symbol is already queued or running, or one succeeded within
+// the dedup window, that job is returned instead and deduplicated is true; a
+// quote sync does not stand in for a full one. While
+// another sync is writing the symbol, ErrSyncInProgress is returned. The
+// request ID in ctx is kept with a new job so the sync's log records carry it.
+func (s *SchedulerService) TriggerManualSync(ctx context.Context, symbol string, mode SyncMode) (job *jobs.Job, deduplicated bool, err error) {
+	if s.jobQueue == nil {
+		return nil, false, fmt.Errorf("job queue is not configured")
+	}
+	symbol, err = models.NormalizeSymbol(symbol)
+	if err != nil {
+		return nil, false, err
+	}
+	
+	// Held across the lookup and enqueue so racing requests see each other's job
+	s.manualSyncMu.Lock()
+	defer s.manualSyncMu.Unlock()
+	
+	if id, ok := s.manualSyncJobs[symbol]; ok {
+		existing, err := s.jobQueue.Get(id)
+		if err != nil && !errors.Is(err, jobs.ErrJobNotFound) {
+			return nil, false, err
+		}
+		if existing != nil && s.reusableManualSync(existing, mode) {
+			s.dedupHits++
+			s.logger.InfoContext(ctx, "Manual sync deduplicated onto existing job", "symbol", symbol, "job_id", existing.ID, "job_status", existing.Status)
+			return existing, true, nil
+		}
+	}
+	
+	// The scheduler or a batch sync is writing this symbol right now
+	if SymbolSyncInProgress(symbol) {
+		return nil, false, ErrSyncInProgress
+	}
+	
+	job, err = s.jobQueue.Enqueue(JobTypeManualSync, manualSyncPayload{
+		Symbol:    symbol,
+		Mode:      mode,
+		RequestID: logging.RequestIDFromContext(ctx),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	s.manualSyncJobs[symbol] = job.ID
+	
+	return job, false, nil
+}
+
+// reusableManualSync reports whether an earlier manual sync job can satisfy a new request in mode
+func (s *SchedulerService) reusableManualSync(job *jobs.Job, mode SyncMode) bool {
+	var payload manualSyncPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return false
+	}
+	if mode == SyncModeFull && payload.Mode == SyncModeQuote {
+		return false
+	}
+	
+	switch job.Status {
+	case jobs.StatusQueued, jobs.StatusRunning:
+		return true
+	case jobs.StatusSucceeded:
+		return job.FinishedAt != nil && time.Since(*job.FinishedAt) < s.dedupWindow
+	default:
+		return false
+	}
+}
+
+// GetManualSyncStatus returns the state of the latest manual sync of symbol,
+// or ErrNoManualSync if none has been triggered since the server started
+func (s *SchedulerService) GetManualSyncStatus(symbol string) (*ManualSyncStatus, error) {
+	symbol, err := models.NormalizeSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	
+	s.manualSyncMu.Lock()
+	id, ok := s.manualSyncJobs[symbol]
+	s.manualSyncMu.Unlock()
+	if !ok || s.jobQueue == nil {
+		return nil, ErrNoManualSync
+	}
+	
+	job, err := s.jobQueue.Get(id)
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		return nil, ErrNoManualSync
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	status := &ManualSyncStatus{
+		Symbol:     symbol,
+		JobID:      job.ID,
+		QueuedAt:   job.CreatedAt,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+	}
+	switch job.Status {
+	case jobs.StatusQueued:
+		status.State = ManualSyncQueued
+	case jobs.StatusRunning:
+		status.State = ManualSyncRunning
+	case jobs.StatusSucceeded:
+		status.State = ManualSyncDone
+		var result ManualSyncResult
+		if err := json.Unmarshal(job.Result, &result); err == nil {
+			status.Result = &result
+		}
+	default:
+		status.State = ManualSyncFailed
+		status.Error = job.Error
+	}
+	return status, nil
+}
+
+// runManualSyncJob executes a queued manual sync
+func (s *SchedulerService) runManualSyncJob(ctx context.Context, job *jobs.Job) (interface{}, error) {
+	var payload manualSyncPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return nil, fmt.Errorf("invalid manual sync payload: %w", err)
+	}
+	if payload.Symbol == "" {
+		return nil, fmt.Errorf("manual sync job has no symbol")
+	}
+	
+	if payload.Mode == "" {
+		payload.Mode = SyncModeFull
+	}
+	
+	if err := s.runManualSync(logging.WithRequestID(ctx, payload.RequestID), payload.Symbol, payload.Mode); err != nil {
+		return nil, err
+	}
+	
+	return ManualSyncResult{Symbol: payload.Symbol, Mode: payload.Mode, SyncedAt: time.Now()}, nil
+}
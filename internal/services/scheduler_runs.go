@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SchedulerRunRetention is how long scheduler runs are kept by the daily cleanup
+const SchedulerRunRetention = 30 * 24 * time.Hour
+
+// Scheduled jobs, as stored in scheduler_runs.job_name
+const (
+	SchedulerJobSync           = "sync"
+	SchedulerJobCleanup        = "cleanup"
+	SchedulerJobRateLimitReset = "rate_limit_reset"
+	SchedulerJobQuoteRefresh   = "quote_refresh"
+	SchedulerJobNews           = "news"
+)
+
+// Outcomes of a scheduler run
+const (
+	RunStatusSucceeded = "succeeded"
+	RunStatusFailed    = "failed"
+	// RunStatusSkipped is a run that had nothing to do, or was not allowed to do it
+	RunStatusSkipped = "skipped"
+)
+
+// SchedulerJobs lists the jobs that record runs
+var SchedulerJobs = []string{SchedulerJobSync, SchedulerJobCleanup, SchedulerJobRateLimitReset, SchedulerJobQuoteRefresh, SchedulerJobNews}
+
+// SchedulerRun is the stored outcome of one run of a scheduled job
+type SchedulerRun struct {
+	ID         int64     `json:"id"`
+	JobName    string    `json:"job_name"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Status     string    `json:"status"`
+	Detail     string    `json:"detail,omitempty"`
+	// Symbol is the last stock a sync run synced
+	Symbol string `json:"symbol,omitempty"`
+}
+
+// SchedulerRunService stores scheduler runs so their outcomes survive restarts
+type SchedulerRunService struct {
+	db *sql.DB
+}
+
+func NewSchedulerRunService(db *sql.DB) *SchedulerRunService {
+	return &SchedulerRunService{db: db}
+}
+
+// Record stores a finished run
+func (s *SchedulerRunService) Record(ctx context.Context, run *SchedulerRun) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO scheduler_runs (job_name, started_at, finished_at, status, detail, symbol)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''))
+	`, run.JobName, run.StartedAt, run.FinishedAt, run.Status, run.Detail, run.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to record %s run: %w", run.JobName, err)
+	}
+	return nil
+}
+
+// List returns up to limit runs, newest first, of job or of every job when job is empty
+func (s *SchedulerRunService) List(ctx context.Context, job string, limit int) ([]SchedulerRun, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, job_name, started_at, finished_at, status, COALESCE(detail, ''), COALESCE(symbol, '')
+		FROM scheduler_runs
+		WHERE $1 = '' OR job_name = $1
+		ORDER BY started_at DESC, id DESC
+		LIMIT $2
+	`, job, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduler runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []SchedulerRun{}
+	for rows.Next() {
+		var run SchedulerRun
+		if err := rows.Scan(&run.ID, &run.JobName, &run.StartedAt, &run.FinishedAt, &run.Status,
+			&run.Detail, &run.Symbol); err != nil {
+			return nil, fmt.Errorf("failed to scan scheduler run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// LastSync returns when a sync run last synced a stock, zero if none has
+func (s *SchedulerRunService) LastSync(ctx context.Context) (time.Time, error) {
+	var finishedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT MAX(finished_at) FROM scheduler_runs WHERE job_name = $1 AND symbol IS NOT NULL
+	`, SchedulerJobSync).Scan(&finishedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get last sync: %w", err)
+	}
+	return finishedAt.Time, nil
+}
+
+// Prune deletes runs older than retention and returns how many rows went
+func (s *SchedulerRunService) Prune(ctx context.Context, retention time.Duration) (int64, error) {
+	retentionDays := int(retention / (24 * time.Hour))
+	result, err := s.db.ExecContext(ctx, `DELETE FROM scheduler_runs WHERE started_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 day'`, retentionDays)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectSchedulerRun expects one run of job to be stored with status
+func expectSchedulerRun(mock sqlmock.Sqlmock, job, status string) {
+	mock.ExpectExec("INSERT INTO scheduler_runs").
+		WithArgs(job, sqlmock.AnyArg(), sqlmock.AnyArg(), status, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestSyncStockDataJob_RecordsSuccessfulRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectScheduledSync(mock, "AAPL")
+	mock.ExpectExec("INSERT INTO scheduler_runs").
+		WithArgs(SchedulerJobSync, sqlmock.AnyArg(), sqlmock.AnyArg(), RunStatusSucceeded, "Synced 1 stocks", "AAPL").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	scheduler := NewSchedulerService(db, &budgetedSyncProvider{allowed: 25}, nil, nil, nil)
+	scheduler.SetSyncBatchPerHour(1)
+	scheduler.now = duringSyncWindow
+	scheduler.syncStockDataJob()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncStockDataJob_RecordsFailedRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectConstituents(mock)
+	expectGaps(mock)
+	mock.ExpectQuery("SELECT s.symbol").WillReturnError(errors.New("connection reset"))
+	mock.ExpectExec("INSERT INTO scheduler_runs").
+		WithArgs(SchedulerJobSync, sqlmock.AnyArg(), sqlmock.AnyArg(), RunStatusFailed,
+			"Failed to get next stock to sync: connection reset", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	scheduler := NewSchedulerService(db, &budgetedSyncProvider{allowed: 25}, nil, nil, nil)
+	scheduler.now = duringSyncWindow
+	scheduler.syncStockDataJob()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResetRateLimitsJob_RecordsFailedRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE api_rate_limits").WillReturnError(errors.New("deadlock detected"))
+	mock.ExpectExec("INSERT INTO scheduler_runs").
+		WithArgs(SchedulerJobRateLimitReset, sqlmock.AnyArg(), sqlmock.AnyArg(), RunStatusFailed,
+			"Failed to reset rate limits: deadlock detected", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	NewSchedulerService(db, nil, nil, nil, nil).resetRateLimitsJob()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResetRateLimitsJob_UsesEasternDayAndHour(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// 00:30 UTC on March 11 is 20:30 on March 10 in New York
+	mock.ExpectExec(`UPDATE api_rate_limits[\s\S]+WHERE \(last_reset_date < \$1`).
+		WithArgs(time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC), 20).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO scheduler_runs").
+		WithArgs(SchedulerJobRateLimitReset, sqlmock.AnyArg(), sqlmock.AnyArg(), RunStatusSkipped, "Reset 0 services", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	scheduler := NewSchedulerService(db, nil, nil, nil, nil)
+	scheduler.now = func() time.Time { return time.Date(2026, time.March, 11, 0, 30, 0, 0, time.UTC) }
+	scheduler.resetRateLimitsJob()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResetRateLimitsJob_KeepsDailyCountWithinTheDay(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// An hourly run zeroes the hourly count of any row from an earlier hour,
+	// but the daily count only of a row from an earlier day
+	mock.ExpectExec(`SET current_daily_count = CASE\s+WHEN last_reset_date < \$1 THEN 0\s+ELSE current_daily_count END,\s+current_hourly_count = 0,`).
+		WithArgs(time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC), 14).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSchedulerRun(mock, SchedulerJobRateLimitReset, RunStatusSucceeded)
+
+	scheduler := NewSchedulerService(db, nil, nil, nil, nil)
+	scheduler.now = func() time.Time { return time.Date(2026, time.March, 10, 18, 0, 0, 0, time.UTC) }
+	scheduler.resetRateLimitsJob()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStatus_CountsProcessedTodayInEasternTime(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// 03:30 UTC on March 11: the evening's syncs in New York still count as March 10
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stocks").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(40))
+	mock.ExpectQuery(`SELECT COUNT\(DISTINCT stock_id\)[\s\S]+AT TIME ZONE 'America/New_York'\)::date = \$1`).
+		WithArgs(time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(6))
+	mock.ExpectQuery("SELECT MAX\\(finished_at\\) FROM scheduler_runs").WithArgs(SchedulerJobSync).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+
+	scheduler := NewSchedulerService(db, nil, nil, nil, nil)
+	scheduler.now = func() time.Time { return time.Date(2026, time.March, 11, 3, 30, 0, 0, time.UTC) }
+	status := scheduler.GetStatus()
+
+	assert.Equal(t, 6, status.ProcessedToday)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStatus_LastSyncFromStoredRuns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// A fresh scheduler, as after a restart, still reports the last sync
+	lastSync := time.Date(2026, time.March, 9, 22, 0, 5, 0, time.UTC)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM stocks").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(40))
+	mock.ExpectQuery("SELECT COUNT\\(DISTINCT stock_id\\)").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("SELECT MAX\\(finished_at\\) FROM scheduler_runs").WithArgs(SchedulerJobSync).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(lastSync))
+
+	scheduler := NewSchedulerService(db, nil, nil, nil, nil)
+	scheduler.now = duringSyncWindow
+	status := scheduler.GetStatus()
+
+	assert.True(t, lastSync.Equal(status.LastSync))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSchedulerRunList_FiltersByJob(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	startedAt := time.Date(2026, time.March, 9, 21, 0, 0, 0, time.UTC)
+	columns := []string{"id", "job_name", "started_at", "finished_at", "status", "detail", "symbol"}
+	mock.ExpectQuery("FROM scheduler_runs").WithArgs(SchedulerJobSync, 50).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(2, SchedulerJobSync, startedAt, startedAt.Add(time.Minute), RunStatusSucceeded, "Synced 2 stocks", "MSFT"))
+	mock.ExpectQuery("FROM scheduler_runs").WithArgs("", 10).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	runs, err := NewSchedulerRunService(db).List(context.Background(), SchedulerJobSync, 50)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, "MSFT", runs[0].Symbol)
+	assert.Equal(t, RunStatusSucceeded, runs[0].Status)
+
+	runs, err = NewSchedulerRunService(db).List(context.Background(), "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+	assert.NotNil(t, runs)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResetRateLimitsJob_WebhookAfterRepeatedFailures(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	dispatcher, webhookMock, _ := newTestWebhookDispatcher(t)
+	receiver := newWebhookReceiver(t)
+	// Only the third failure in a row is sent; the fourth is not sent again
+	expectSubscribers(webhookMock, WebhookEventSchedulerJobFailing, receiver.URL)
+
+	scheduler := NewSchedulerService(db, nil, nil, nil, nil)
+	scheduler.SetWebhookDispatcher(dispatcher, 3)
+	for i := 0; i < 4; i++ {
+		mock.ExpectExec("UPDATE api_rate_limits").WillReturnError(errors.New("deadlock detected"))
+		expectSchedulerRun(mock, SchedulerJobRateLimitReset, RunStatusFailed)
+		scheduler.resetRateLimitsJob()
+	}
+	dispatcher.wait()
+
+	deliveries := receiver.deliveries()
+	require.Len(t, deliveries, 1)
+	var payload struct {
+		Data JobFailingEvent `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(deliveries[0].body, &payload))
+	assert.Equal(t, SchedulerJobRateLimitReset, payload.Data.Job)
+	assert.Equal(t, 3, payload.Data.ConsecutiveFailures)
+	assert.Equal(t, "Failed to reset rate limits: deadlock detected", payload.Data.LastError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, webhookMock.ExpectationsWereMet())
+}
+
+func TestTrackFailures_SuccessEndsTheStreak(t *testing.T) {
+	dispatcher, webhookMock, _ := newTestWebhookDispatcher(t)
+	scheduler := NewSchedulerService(nil, nil, nil, nil, nil)
+	scheduler.SetWebhookDispatcher(dispatcher, 2)
+
+	for _, status := range []string{RunStatusFailed, RunStatusSkipped, RunStatusSucceeded, RunStatusFailed} {
+		scheduler.trackFailures(&SchedulerRun{JobName: SchedulerJobNews, Status: status})
+	}
+	dispatcher.wait()
+
+	assert.Equal(t, 1, scheduler.failureStreaks[SchedulerJobNews])
+	// Never reached two in a row, so no webhooks were looked up
+	assert.NoError(t, webhookMock.ExpectationsWereMet())
+}
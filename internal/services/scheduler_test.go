@@ -0,0 +1,431 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/jobs"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dailySeriesFixture = `{
+	"Meta Data": {"2. Symbol": "AAPL"},
+	"Time Series (Daily)": {
+		"2024-01-02": {"1. open": "187.15", "2. high": "188.44", "3. low": "183.89", "4. close": "185.64", "5. volume": "82488700"}
+	}
+}`
+
+func expectRateLimitQuery(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("FROM api_rate_limits").WillReturnRows(sqlmock.NewRows([]string{
+		"id", "service_name", "daily_limit", "hourly_limit", "current_daily_count",
+		"current_hourly_count", "last_reset_date", "last_reset_hour",
+	}).AddRow(1, "alphavantage", 25, 5, 0, 0, marketDate(time.Now()), marketHour(time.Now())))
+}
+
+func TestRunManualSync_PublishesStockUpdate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, dailySeriesFixture)
+	}))
+	defer server.Close()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectRateLimitQuery(mock) // TriggerManualSync
+	expectRateLimitQuery(mock) // FetchDailyData
+	mock.ExpectExec("INSERT INTO api_calls").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRequestRecorded(mock, ProviderAlphaVantage)
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expectAdvisoryLock(mock, 1, true)
+	expectSaveBegins(mock, 1, nil)
+	mock.ExpectQuery("INSERT INTO daily_prices").WillReturnRows(insertedRow())
+	expectPriceCoverage(mock, 1)
+	mock.ExpectCommit()
+	expectAdvisoryUnlock(mock, 1)
+	mock.ExpectExec("UPDATE stocks SET updated_at").WithArgs("AAPL").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	client := NewAlphaVantageClient("test-key", db)
+	client.baseURL = server.URL
+
+	events := NewStockEventBus()
+	updates, unsubscribe := events.Subscribe()
+	defer unsubscribe()
+
+	scheduler := NewSchedulerService(db, client, nil, nil, events)
+	require.NoError(t, scheduler.runManualSync(context.Background(), "AAPL", SyncModeFull))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	select {
+	case event := <-updates:
+		assert.Equal(t, "AAPL", event.Symbol)
+	case <-time.After(time.Second):
+		t.Fatal("expected a stock update event after manual sync")
+	}
+
+	select {
+	case event := <-updates:
+		t.Fatalf("expected exactly one event, got another for %s", event.Symbol)
+	default:
+	}
+}
+
+func TestRunManualSync_NoEventOnFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("FROM api_rate_limits").WillReturnRows(sqlmock.NewRows([]string{
+		"id", "service_name", "daily_limit", "hourly_limit", "current_daily_count",
+		"current_hourly_count", "last_reset_date", "last_reset_hour",
+	}).AddRow(1, "alphavantage", 25, 5, 25, 0, marketDate(time.Now()), marketHour(time.Now())))
+
+	events := NewStockEventBus()
+	updates, unsubscribe := events.Subscribe()
+	defer unsubscribe()
+
+	scheduler := NewSchedulerService(db, NewAlphaVantageClient("test-key", db), nil, nil, events)
+	assert.Error(t, scheduler.runManualSync(context.Background(), "AAPL", SyncModeFull))
+
+	select {
+	case event := <-updates:
+		t.Fatalf("unexpected stock update event for %s", event.Symbol)
+	default:
+	}
+}
+
+// fakeJobStore keeps jobs in memory so manual sync dedup can be tested without a database
+type fakeJobStore struct {
+	mu     sync.Mutex
+	nextID int64
+	jobs   map[int64]*jobs.Job
+}
+
+func newFakeJobStore() *fakeJobStore {
+	return &fakeJobStore{jobs: make(map[int64]*jobs.Job)}
+}
+
+func (f *fakeJobStore) Create(job *jobs.Job) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	job.ID = f.nextID
+	job.Status = jobs.StatusQueued
+	stored := *job
+	f.jobs[job.ID] = &stored
+	return nil
+}
+
+func (f *fakeJobStore) Get(id int64) (*jobs.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job, ok := f.jobs[id]
+	if !ok {
+		return nil, jobs.ErrJobNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (f *fakeJobStore) finish(id int64, status string, finishedAt time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jobs[id].Status = status
+	f.jobs[id].FinishedAt = &finishedAt
+}
+
+func (f *fakeJobStore) MarkRunning(id int64) error { return nil }
+func (f *fakeJobStore) MarkFinished(id int64, status string, result []byte, errMsg string) error {
+	return nil
+}
+func (f *fakeJobStore) Requeue(id int64, errMsg string) error { return nil }
+func (f *fakeJobStore) ListUnfinished() ([]*jobs.Job, error)  { return nil, nil }
+func (f *fakeJobStore) count() int                            { f.mu.Lock(); defer f.mu.Unlock(); return len(f.jobs) }
+
+func TestTriggerManualSync_ConcurrentRequestsShareOneJob(t *testing.T) {
+	store := newFakeJobStore()
+	// The queue is never started, so the first job stays queued while the others race
+	scheduler := NewSchedulerService(nil, nil, nil, jobs.NewQueue(store, 1), nil)
+
+	const requests = 20
+	var wg sync.WaitGroup
+	results := make(chan *jobs.Job, requests)
+	var fresh int32
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job, deduplicated, err := scheduler.TriggerManualSync(context.Background(), "AAPL", SyncModeFull)
+			require.NoError(t, err)
+			if !deduplicated {
+				atomic.AddInt32(&fresh, 1)
+			}
+			results <- job
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	assert.Equal(t, int32(1), fresh)
+	assert.Equal(t, 1, store.count())
+	for job := range results {
+		assert.Equal(t, int64(1), job.ID)
+	}
+	assert.Equal(t, int64(requests-1), scheduler.GetManualSyncDedupStats().Hits)
+
+	// Other symbols are not affected
+	_, deduplicated, err := scheduler.TriggerManualSync(context.Background(), "MSFT", SyncModeFull)
+	require.NoError(t, err)
+	assert.False(t, deduplicated)
+}
+
+func TestTriggerManualSync_ConcurrentRequestsFetchOnce(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectExec("UPDATE stocks SET updated_at").WithArgs("AAPL").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	provider := &budgetedSyncProvider{allowed: 25}
+	queue := jobs.NewQueue(newFakeJobStore(), 1)
+	scheduler := NewSchedulerService(db, provider, nil, queue, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := scheduler.TriggerManualSync(context.Background(), "AAPL", SyncModeFull)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	require.NoError(t, queue.Start())
+	require.Eventually(t, func() bool { return mock.ExpectationsWereMet() == nil }, time.Second, 10*time.Millisecond)
+	queue.Stop()
+
+	assert.Equal(t, 1, provider.fetches)
+}
+
+func TestGetManualSyncStatus(t *testing.T) {
+	store := newFakeJobStore()
+	scheduler := NewSchedulerService(nil, nil, nil, jobs.NewQueue(store, 1), nil)
+
+	_, err := scheduler.GetManualSyncStatus("AAPL")
+	assert.ErrorIs(t, err, ErrNoManualSync)
+
+	job, _, err := scheduler.TriggerManualSync(context.Background(), "aapl", SyncModeFull)
+	require.NoError(t, err)
+
+	status, err := scheduler.GetManualSyncStatus("AAPL")
+	require.NoError(t, err)
+	assert.Equal(t, ManualSyncQueued, status.State)
+	assert.Equal(t, job.ID, status.JobID)
+
+	store.finish(job.ID, jobs.StatusFailed, time.Now())
+	status, err = scheduler.GetManualSyncStatus("AAPL")
+	require.NoError(t, err)
+	assert.Equal(t, ManualSyncFailed, status.State)
+	assert.Nil(t, status.Result)
+}
+
+func TestTriggerManualSync_DedupWindow(t *testing.T) {
+	store := newFakeJobStore()
+	scheduler := NewSchedulerService(nil, nil, nil, jobs.NewQueue(store, 1), nil)
+
+	first, _, err := scheduler.TriggerManualSync(context.Background(), "AAPL", SyncModeFull)
+	require.NoError(t, err)
+
+	// Recently succeeded: reuse
+	store.finish(first.ID, jobs.StatusSucceeded, time.Now().Add(-time.Minute))
+	job, deduplicated, err := scheduler.TriggerManualSync(context.Background(), "AAPL", SyncModeFull)
+	require.NoError(t, err)
+	assert.True(t, deduplicated)
+	assert.Equal(t, first.ID, job.ID)
+	assert.Equal(t, jobs.StatusSucceeded, job.Status)
+
+	// Succeeded outside the window: sync again
+	store.finish(first.ID, jobs.StatusSucceeded, time.Now().Add(-DefaultManualSyncDedupWindow-time.Minute))
+	second, deduplicated, err := scheduler.TriggerManualSync(context.Background(), "AAPL", SyncModeFull)
+	require.NoError(t, err)
+	assert.False(t, deduplicated)
+	assert.NotEqual(t, first.ID, second.ID)
+
+	// Failed syncs are never reused
+	store.finish(second.ID, jobs.StatusFailed, time.Now())
+	_, deduplicated, err = scheduler.TriggerManualSync(context.Background(), "AAPL", SyncModeFull)
+	require.NoError(t, err)
+	assert.False(t, deduplicated)
+
+	stats := scheduler.GetManualSyncDedupStats()
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, 600, stats.WindowSeconds)
+}
+
+func TestTriggerManualSync_QuoteDoesNotStandInForFullSync(t *testing.T) {
+	store := newFakeJobStore()
+	scheduler := NewSchedulerService(nil, nil, nil, jobs.NewQueue(store, 1), nil)
+
+	quote, _, err := scheduler.TriggerManualSync(context.Background(), "AAPL", SyncModeQuote)
+	require.NoError(t, err)
+
+	full, deduplicated, err := scheduler.TriggerManualSync(context.Background(), "AAPL", SyncModeFull)
+	require.NoError(t, err)
+	assert.False(t, deduplicated)
+	assert.NotEqual(t, quote.ID, full.ID)
+
+	// The queued full sync covers the latest day too
+	job, deduplicated, err := scheduler.TriggerManualSync(context.Background(), "AAPL", SyncModeQuote)
+	require.NoError(t, err)
+	assert.True(t, deduplicated)
+	assert.Equal(t, full.ID, job.ID)
+}
+
+func TestRunManualSync_QuoteWithoutStoredPricesRunsFullSync(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT MAX\(dp.date\)`).WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+	mock.ExpectExec("UPDATE stocks SET updated_at").WithArgs("AAPL").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	provider := &budgetedSyncProvider{allowed: 25}
+	scheduler := NewSchedulerService(db, provider, nil, nil, nil)
+	require.NoError(t, scheduler.runManualSync(context.Background(), "AAPL", SyncModeQuote))
+
+	assert.Equal(t, 1, provider.fetches)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// duringSyncWindow is 18:00 ET on a regular trading day
+func duringSyncWindow() time.Time {
+	return time.Date(2026, time.March, 10, 22, 0, 0, 0, time.UTC)
+}
+
+// budgetedSyncProvider allows a fixed number of requests, then reports the
+// rate limit either from CanMakeRequest or, with failFetch, from the fetch itself
+type budgetedSyncProvider struct {
+	stubSyncProvider
+	allowed   int
+	failFetch bool
+	fetches   int
+}
+
+func (p *budgetedSyncProvider) CanMakeRequest() (bool, error) {
+	return p.failFetch || p.fetches < p.allowed, nil
+}
+
+func (p *budgetedSyncProvider) FetchDailyData(ctx context.Context, symbol string, initiator Initiator) (*DailySeries, error) {
+	p.fetches++
+	if p.fetches > p.allowed {
+		return nil, &APIError{Kind: APIErrorRateLimited, Symbol: symbol, Message: "daily budget exhausted"}
+	}
+	return &DailySeries{Symbol: symbol, Bars: []DailyBar{{}}}, nil
+}
+
+// expectScheduledSync expects symbol to be picked, synced and recorded
+func expectScheduledSync(mock sqlmock.Sqlmock, symbol string) {
+	expectNextStockToSync(mock, symbol)
+	expectSyncHistory(mock, symbol, true, "", InitiatorScheduler)
+	mock.ExpectExec("UPDATE stocks SET updated_at").WithArgs(symbol).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestSyncStockDataJob_SyncsBatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		batch     int
+		provider  *budgetedSyncProvider
+		expect    func(mock sqlmock.Sqlmock)
+		processed int
+		fetches   int
+	}{
+		{
+			name:     "stops at the per-run cap",
+			batch:    2,
+			provider: &budgetedSyncProvider{allowed: 25},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectScheduledSync(mock, "AAPL")
+				expectScheduledSync(mock, "MSFT")
+			},
+			processed: 2,
+			fetches:   2,
+		},
+		{
+			name:     "stops when the rate limit says no",
+			batch:    5,
+			provider: &budgetedSyncProvider{allowed: 2},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectScheduledSync(mock, "AAPL")
+				expectScheduledSync(mock, "MSFT")
+			},
+			processed: 2,
+			fetches:   2,
+		},
+		{
+			name:     "stops immediately when rate limited mid-run",
+			batch:    5,
+			provider: &budgetedSyncProvider{allowed: 1, failFetch: true},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectScheduledSync(mock, "AAPL")
+				expectNextStockToSync(mock, "MSFT")
+				expectSyncHistory(mock, "MSFT", false, APIErrorRateLimited, InitiatorScheduler)
+			},
+			processed: 1,
+			fetches:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tt.expect(mock)
+
+			scheduler := NewSchedulerService(db, tt.provider, nil, nil, nil)
+			scheduler.SetSyncBatchPerHour(tt.batch)
+			scheduler.now = duringSyncWindow
+			scheduler.syncStockDataJob()
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, tt.fetches, tt.provider.fetches)
+			assert.Equal(t, tt.processed, scheduler.lastRunProcessed)
+			assert.Empty(t, scheduler.GetRecentActivity(10).RecentErrors)
+		})
+	}
+}
+
+func TestSyncStockDataJob_StopsWhenStalestStockRepeats(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// AAPL gained no newer prices, so it is still the stalest stock
+	expectScheduledSync(mock, "AAPL")
+	expectNextStockToSync(mock, "AAPL")
+
+	provider := &budgetedSyncProvider{allowed: 25}
+	scheduler := NewSchedulerService(db, provider, nil, nil, nil)
+	scheduler.now = duringSyncWindow
+	scheduler.syncStockDataJob()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 1, provider.fetches)
+	assert.Equal(t, 1, scheduler.lastRunProcessed)
+}
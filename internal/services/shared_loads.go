@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// loadShared returns the value cached under key, or loads and caches it for
+// ttl. Concurrent callers missing the cache share a single load, so an expired
+// key costs one query rather than one per waiting request. load reports
+// whether its value may be cached.
+//
+// With a stale grace set, a value that expired within it is returned at once
+// while one background load refreshes it.
+//
+// The load runs detached from the caller's cancellation, as other callers may
+// be waiting on it; it is still bounded by the query timeout. Callers sharing
+// a load share its value, so they must copy it before changing it.
+func loadShared[T any](ctx context.Context, d *DatabaseStockService, key string, ttl time.Duration, load func(ctx context.Context) (T, bool, error)) (T, error) {
+	var cached T
+	if d.cache != nil {
+		if err := d.cache.GetStockData(ctx, key, &cached); err == nil {
+			slog.DebugContext(ctx, "Loaded from cache", "key", key)
+			return cached, nil
+		}
+	}
+
+	loadAndCache := func() (interface{}, error) {
+		ctx := context.WithoutCancel(ctx)
+		// A caller that missed the cache as the previous load finished finds its value here
+		if d.cache != nil {
+			var cached T
+			if err := d.cache.GetStockData(ctx, key, &cached); err == nil {
+				return cached, nil
+			}
+		}
+		value, cacheable, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if cacheable && d.cache != nil {
+			if err := d.cache.SetStockDataWithGrace(ctx, key, value, ttl, d.staleGrace); err != nil {
+				slog.WarnContext(ctx, "Failed to cache", "key", key, "error", err)
+			}
+		}
+		return value, nil
+	}
+
+	if d.cache != nil && d.staleGrace > 0 {
+		var stale T
+		if err := d.cache.GetStaleStockData(ctx, key, &stale); err == nil {
+			slog.DebugContext(ctx, "Serving stale cache while refreshing", "key", key)
+			d.flight.DoChan(key, loadAndCache)
+			return stale, nil
+		}
+	}
+
+	value, err, _ := d.flight.Do(key, loadAndCache)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return value.(T), nil
+}
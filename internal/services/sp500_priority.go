@@ -1,9 +1,10 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 )
 
 // SP500Stock represents a stock with priority information
@@ -15,6 +16,10 @@ type SP500Stock struct {
 	HasData     bool
 }
 
+// SufficientHistoryDays is the span of stored prices, in calendar days, at which a
+// stock no longer needs a historical sync: roughly 30 trading days
+const SufficientHistoryDays = 42
+
 // SP500PriorityService manages S&P 500 stock priorities for historical data fetching
 type SP500PriorityService struct {
 	db *sql.DB
@@ -27,8 +32,22 @@ func NewSP500PriorityService(db *sql.DB) *SP500PriorityService {
 	}
 }
 
-// GetTop500SP500Stocks returns the top S&P 500 stocks ordered by market cap priority
+// GetTop500SP500Stocks returns the S&P 500 constituents in priority order,
+// from index_constituents, or the built-in list until a list is imported
 func (s *SP500PriorityService) GetTop500SP500Stocks() []SP500Stock {
+	stocks, err := s.constituents(context.Background(), IndexSP500)
+	if err != nil {
+		slog.Warn("Using the built-in S&P 500 list", "error", err)
+	}
+	if len(stocks) == 0 {
+		return defaultSP500Stocks()
+	}
+	return stocks
+}
+
+// defaultSP500Stocks is the built-in fallback for when no constituents have
+// been imported: the largest S&P 500 stocks, not the full index
+func defaultSP500Stocks() []SP500Stock {
 	// Top S&P 500 stocks by market cap (as of 2024)
 	// Priority 1 = Highest priority (largest market cap)
 	return []SP500Stock{
@@ -92,43 +111,40 @@ func (s *SP500PriorityService) GetTop500SP500Stocks() []SP500Stock {
 
 // GetPendingStocksForSync returns stocks that need historical data, ordered by priority
 func (s *SP500PriorityService) GetPendingStocksForSync(limit int) ([]SP500Stock, error) {
-	// First, get all stocks from database that need data
+	sp500Priorities, err := s.priorities(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	
+	// Get the stocks that need data, judged by their stored price coverage, index
+	// constituents first; with no list imported this is market cap order
 	query := `
-		SELECT s.symbol, s.company_name, s.market_cap,
-		       CASE WHEN COUNT(dp.date) >= 30 THEN true ELSE false END as has_data,
-		       COUNT(dp.date) as price_count
+		SELECT s.symbol, s.company_name, COALESCE(s.market_cap, 0),
+		       COALESCE(s.last_price_date - s.first_price_date, 0) as history_days
 		FROM stocks s
-		LEFT JOIN daily_prices dp ON s.id = dp.stock_id
-		WHERE s.is_active = true
-		GROUP BY s.symbol, s.company_name, s.market_cap
-		HAVING COUNT(dp.date) < 30
-		ORDER BY s.market_cap DESC
+		LEFT JOIN index_constituents ic ON ic.index_name = $3 AND ic.symbol = s.symbol
+		WHERE s.is_active = true AND s.delisted_at IS NULL
+		  AND COALESCE(s.last_price_date - s.first_price_date, 0) < $2
+		ORDER BY ic.priority ASC NULLS LAST, s.market_cap DESC NULLS LAST
 		LIMIT $1
 	`
 	
-	rows, err := s.db.Query(query, limit)
+	rows, err := s.db.Query(query, limit, SufficientHistoryDays, IndexSP500)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query pending stocks: %w", err)
 	}
 	defer rows.Close()
 	
 	var pendingStocks []SP500Stock
-	sp500Map := make(map[string]SP500Stock)
-	
-	// Create a lookup map of S&P 500 stocks for priority assignment
-	for _, stock := range s.GetTop500SP500Stocks() {
-		sp500Map[stock.Symbol] = stock
-	}
 	
 	for rows.Next() {
 		var symbol, companyName string
 		var marketCap int64
-		var hasData bool
-		var priceCount int
+		var historyDays int
 		
-		err := rows.Scan(&symbol, &companyName, &marketCap, &hasData, &priceCount)
+		err := rows.Scan(&symbol, &companyName, &marketCap, &historyDays)
 		if err != nil {
-			log.Printf("Error scanning pending stock: %v", err)
+			slog.Error("Error scanning pending stock", "error", err)
 			continue
 		}
 		
@@ -137,30 +153,39 @@ func (s *SP500PriorityService) GetPendingStocksForSync(limit int) ([]SP500Stock,
 			Symbol:      symbol,
 			CompanyName: companyName,
 			MarketCap:   marketCap,
-			HasData:     hasData,
+			HasData:     historyDays >= SufficientHistoryDays,
 		}
 		
 		// Assign priority if it's in our S&P 500 list, otherwise use market cap based priority
-		if sp500Stock, exists := sp500Map[symbol]; exists {
-			stock.Priority = sp500Stock.Priority
+		if priority, exists := sp500Priorities[symbol]; exists {
+			stock.Priority = priority
 		} else {
-			// Assign priority based on market cap for non-S&P 500 stocks
-			stock.Priority = 500 + len(pendingStocks) // Lower priority
+			// Assign priority based on market cap for non-S&P 500 stocks, after every constituent
+			stock.Priority = len(sp500Priorities) + len(pendingStocks) + 1 // Lower priority
 		}
 		
 		pendingStocks = append(pendingStocks, stock)
 		
-		log.Printf("Found pending stock: %s (priority %d, %d days of data)", 
-			symbol, stock.Priority, priceCount)
+		slog.Debug("Found pending stock", "symbol", symbol, "priority", stock.Priority, "history_days", historyDays)
 	}
 	
 	return pendingStocks, rows.Err()
 }
 
-// GetStockPriority returns the priority of a given stock symbol
+// GetStockPriority returns a stock's S&P 500 priority, 999 when it is not in the index
 func (s *SP500PriorityService) GetStockPriority(symbol string) int {
-	stocks := s.GetTop500SP500Stocks()
-	for _, stock := range stocks {
+	priority, listed, imported, err := s.indexPriority(IndexSP500, symbol)
+	if err != nil {
+		slog.Warn("Using the built-in S&P 500 list", "error", err)
+	}
+	if err == nil && imported {
+		if listed {
+			return priority
+		}
+		return 999 // Low priority if not in S&P 500
+	}
+	
+	for _, stock := range defaultSP500Stocks() {
 		if stock.Symbol == symbol {
 			return stock.Priority
 		}
@@ -168,29 +193,32 @@ func (s *SP500PriorityService) GetStockPriority(symbol string) int {
 	return 999 // Low priority if not in S&P 500
 }
 
-// UpdateStockWithPriority updates a stock record with S&P 500 priority information
+// UpdateStockWithPriority logs a synced stock's S&P 500 priority. Market cap
+// is left to the stock's own data rather than the index list.
 func (s *SP500PriorityService) UpdateStockWithPriority(symbol string) error {
-	stocks := s.GetTop500SP500Stocks()
+	priority := s.GetStockPriority(symbol)
+	if priority == 999 {
+		return fmt.Errorf("stock %s not found in S&P 500 list", symbol)
+	}
 	
-	for _, stock := range stocks {
-		if stock.Symbol == symbol {
-			query := `
-				UPDATE stocks 
-				SET market_cap = $1, 
-				    updated_at = CURRENT_TIMESTAMP
-				WHERE symbol = $2
-			`
-			
-			_, err := s.db.Exec(query, stock.MarketCap, symbol)
-			if err != nil {
-				return fmt.Errorf("failed to update stock priority for %s: %w", symbol, err)
-			}
-			
-			log.Printf("Updated stock %s with priority %d and market cap %d", 
-				symbol, stock.Priority, stock.MarketCap)
-			return nil
-		}
+	slog.Info("Updated stock priority", "symbol", symbol, "priority", priority)
+	return nil
+}
+
+// priorities returns the S&P 500 priority of each constituent, from the
+// imported list or the built-in one until a list is imported
+func (s *SP500PriorityService) priorities(ctx context.Context) (map[string]int, error) {
+	stocks, err := s.constituents(ctx, IndexSP500)
+	if err != nil {
+		return nil, err
+	}
+	if len(stocks) == 0 {
+		stocks = defaultSP500Stocks()
 	}
 	
-	return fmt.Errorf("stock %s not found in S&P 500 list", symbol)
-}
\ No newline at end of file
+	priorities := make(map[string]int, len(stocks))
+	for _, stock := range stocks {
+		priorities[stock.Symbol] = stock.Priority
+	}
+	return priorities, nil
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// ErrInvalidCursor is returned for a stock list cursor that was not issued by
+// GetStocksAfter, or was altered since
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// StockCursor is the position just after a stock in the stock list, which
+// is ordered by market cap, largest first and unknown caps before all, then
+// by symbol. It holds the stock's sort key rather than an offset, so a page
+// starts where the previous one ended even when stocks are added or removed
+// between requests.
+type StockCursor struct {
+	MarketCap *int64 `json:"c"`
+	Symbol    string `json:"s"`
+}
+
+// Encode returns the cursor as an opaque URL-safe string
+func (c StockCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseStockCursor decodes a cursor returned by Encode. Anything else,
+// including a cursor whose decoded key could not belong to a stock, is an
+// ErrInvalidCursor.
+func ParseStockCursor(value string) (*StockCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not base64", ErrInvalidCursor)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var cursor StockCursor
+	if err := decoder.Decode(&cursor); err != nil || decoder.More() {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidCursor)
+	}
+	if _, err := models.NormalizeSymbol(cursor.Symbol); err != nil {
+		return nil, fmt.Errorf("%w: bad symbol", ErrInvalidCursor)
+	}
+	if cursor.MarketCap != nil && *cursor.MarketCap < 0 {
+		return nil, fmt.Errorf("%w: bad market cap", ErrInvalidCursor)
+	}
+	return &cursor, nil
+}
+
+// GetStocksAfter returns up to limit of the active stocks that filter keeps,
+// starting after cursor, or from the first stock when cursor is nil. The
+// returned cursor continues after the page's last stock, and is nil once the
+// list is exhausted.
+func (d *DatabaseStockService) GetStocksAfter(ctx context.Context, filter StockFilter, cursor *StockCursor, limit int) ([]models.Stock, *StockCursor, error) {
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+
+	conditions := d.filterSQL(filter)
+	switch {
+	case cursor == nil:
+	case cursor.MarketCap == nil:
+		// Unknown caps sort first, so every known cap is still ahead
+		conditions.add("(s.market_cap IS NOT NULL OR s.symbol > %s)", cursor.Symbol)
+	default:
+		conditions.add("(s.market_cap < %s OR (s.market_cap = %s AND s.symbol > %s))", *cursor.MarketCap, *cursor.MarketCap, cursor.Symbol)
+	}
+
+	// One stock more than the page tells whether another page follows
+	query := stockChangesQuery + conditions.where() + fmt.Sprintf(`
+		ORDER BY s.market_cap DESC NULLS FIRST, s.symbol
+		LIMIT $%d
+	`, len(conditions.args)+1)
+	rows, err := d.router.Reader().QueryContext(ctx, query, append(conditions.args, limit+1)...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch stocks: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+
+	stocks := d.scanStockRows(ctx, rows)
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read stocks: %w", queryError(ctx, err))
+	}
+	if stocks == nil {
+		stocks = []models.Stock{}
+	}
+
+	var next *StockCursor
+	if len(stocks) > limit {
+		stocks = stocks[:limit]
+		last := stocks[limit-1]
+		next = &StockCursor{MarketCap: last.MarketCap, Symbol: last.Symbol}
+	}
+
+	slog.DebugContext(ctx, "Loaded stocks from database", "count", len(stocks), "limit", limit, "more", next != nil)
+	return stocks, next, nil
+}
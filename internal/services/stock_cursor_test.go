@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStockCursor_RoundTrip(t *testing.T) {
+	marketCap := int64(2500000000)
+	for _, cursor := range []StockCursor{
+		{MarketCap: &marketCap, Symbol: "BRK.B"},
+		{Symbol: "NEWCO"},
+	} {
+		parsed, err := ParseStockCursor(cursor.Encode())
+		require.NoError(t, err)
+		assert.Equal(t, cursor, *parsed)
+	}
+}
+
+func TestParseStockCursor_RejectsTampering(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	for name, value := range map[string]string{
+		"not base64":     "%%%",
+		"not json":       encode("AAPL"),
+		"unknown field":  encode(`{"c":1,"s":"AAPL","o":100}`),
+		"trailing data":  encode(`{"c":1,"s":"AAPL"}{}`),
+		"missing symbol": encode(`{"c":1}`),
+		"bad symbol":     encode(`{"c":1,"s":"'; DROP TABLE stocks"}`),
+		"negative cap":   encode(`{"c":-1,"s":"AAPL"}`),
+		"fractional cap": encode(`{"c":1.5,"s":"AAPL"}`),
+	} {
+		_, err := ParseStockCursor(value)
+		assert.ErrorIs(t, err, ErrInvalidCursor, name)
+	}
+}
+
+func TestGetStocksAfter_CursorWithoutMarketCap(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Stocks without a cap come first, so the rest of them follow by symbol and then every known cap
+	mock.ExpectQuery(`s.sector = \$1 AND \(s.market_cap IS NOT NULL OR s.symbol > \$2\)\s+ORDER BY s.market_cap DESC NULLS FIRST, s.symbol\s+LIMIT \$3`).
+		WithArgs("Technology", "NEWCO", 11).
+		WillReturnRows(sqlmock.NewRows(rankedStockColumns))
+
+	service := NewDatabaseStockService(db, nil)
+	stocks, next, err := service.GetStocksAfter(context.Background(), StockFilter{Sector: "Technology"}, &StockCursor{Symbol: "NEWCO"}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, stocks)
+	assert.Nil(t, next)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// StockUpdatedEvent is published after fresh price data for a stock has been saved
+type StockUpdatedEvent struct {
+	Symbol    string    `json:"symbol"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StockEventBus fans out stock update events to subscribers
+type StockEventBus struct {
+	mu          sync.RWMutex
+	subscribers map[chan StockUpdatedEvent]struct{}
+}
+
+// NewStockEventBus creates an empty event bus
+func NewStockEventBus() *StockEventBus {
+	return &StockEventBus{
+		subscribers: make(map[chan StockUpdatedEvent]struct{}),
+	}
+}
+
+// Subscribe registers a new subscriber and returns its channel and an unsubscribe function
+func (b *StockEventBus) Subscribe() (<-chan StockUpdatedEvent, func()) {
+	ch := make(chan StockUpdatedEvent, 16)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// PublishStockUpdated notifies subscribers that a stock has new data.
+// Slow subscribers miss events rather than blocking the sync that published them.
+func (b *StockEventBus) PublishStockUpdated(symbol string) {
+	if b == nil {
+		return
+	}
+
+	event := StockUpdatedEvent{Symbol: symbol, UpdatedAt: time.Now()}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			slog.Warn("Dropping stock update event: subscriber is not keeping up", "symbol", symbol)
+		}
+	}
+}
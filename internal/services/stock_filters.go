@@ -0,0 +1,186 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MarketCapBand is a class of stocks by market capitalization
+type MarketCapBand string
+
+const (
+	// MarketCapLarge is stocks worth at least the large threshold
+	MarketCapLarge MarketCapBand = "large"
+	// MarketCapMid is stocks from the mid threshold up to the large one
+	MarketCapMid MarketCapBand = "mid"
+	// MarketCapSmall is stocks worth less than the mid threshold
+	MarketCapSmall MarketCapBand = "small"
+)
+
+// MarketCapBands lists the bands a stock list can be filtered by
+var MarketCapBands = []string{string(MarketCapLarge), string(MarketCapMid), string(MarketCapSmall)}
+
+// Exchanges lists the exchanges a stock list can be filtered by
+var Exchanges = []string{"NYSE", "NASDAQ"}
+
+// ParseMarketCapBand returns the band called s
+func ParseMarketCapBand(s string) (MarketCapBand, error) {
+	switch band := MarketCapBand(strings.ToLower(strings.TrimSpace(s))); band {
+	case MarketCapLarge, MarketCapMid, MarketCapSmall:
+		return band, nil
+	default:
+		return "", fmt.Errorf("market_cap must be one of %s", strings.Join(MarketCapBands, ", "))
+	}
+}
+
+// ParseExchange returns the exchange called s, uppercased
+func ParseExchange(s string) (string, error) {
+	exchange := strings.ToUpper(strings.TrimSpace(s))
+	for _, known := range Exchanges {
+		if exchange == known {
+			return exchange, nil
+		}
+	}
+	return "", fmt.Errorf("exchange must be one of %s", strings.Join(Exchanges, ", "))
+}
+
+// MarketCapThresholds are the smallest market caps of the mid and large
+// bands; a cap on a threshold belongs to the band above it
+type MarketCapThresholds struct {
+	Mid   int64
+	Large int64
+}
+
+// DefaultMarketCapThresholds put the bands at $2B and $10B
+var DefaultMarketCapThresholds = MarketCapThresholds{Mid: 2000000000, Large: 10000000000}
+
+// ParseMarketCapThresholds parses the mid and large thresholds from a
+// comma-separated pair such as "2000000000,10000000000"
+func ParseMarketCapThresholds(value string) (MarketCapThresholds, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return MarketCapThresholds{}, fmt.Errorf("expected the mid and large thresholds, got %q", value)
+	}
+	var bounds [2]int64
+	for i, part := range parts {
+		bound, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return MarketCapThresholds{}, fmt.Errorf("invalid market cap threshold %q: %w", part, err)
+		}
+		bounds[i] = bound
+	}
+	if bounds[0] <= 0 || bounds[1] <= bounds[0] {
+		return MarketCapThresholds{}, fmt.Errorf("market cap thresholds must be positive and increasing: %q", value)
+	}
+	return MarketCapThresholds{Mid: bounds[0], Large: bounds[1]}, nil
+}
+
+// StockFilter narrows a list of active stocks. Zero fields do not filter.
+type StockFilter struct {
+	Sector     string
+	PriceRange string
+	MarketCap  MarketCapBand
+	Exchange   string
+	// MinHistoryDays keeps only stocks whose stored prices span at least this many days
+	MinHistoryDays int
+	// Sort orders offset pages; cursor pages are always by market cap
+	Sort StockSort
+}
+
+// StockSortField is what a page of stocks can be ordered by
+type StockSortField string
+
+const (
+	StockSortMarketCap     StockSortField = "market_cap"
+	StockSortSymbol        StockSortField = "symbol"
+	StockSortPrice         StockSortField = "price"
+	StockSortChangePercent StockSortField = "change_percent"
+	StockSortVolume        StockSortField = "volume"
+)
+
+// stockSortColumns are the ORDER BY expressions of each sort field, over the
+// columns of a stock list query
+var stockSortColumns = map[StockSortField]string{
+	StockSortMarketCap:     "s.market_cap",
+	StockSortSymbol:        "s.symbol",
+	StockSortPrice:         "current_price",
+	StockSortChangePercent: "change_percent",
+	StockSortVolume:        "volume",
+}
+
+// StockSort orders a page of stocks. The zero value is the largest market cap first.
+type StockSort struct {
+	Field     StockSortField
+	Ascending bool
+}
+
+// orderBy is the ORDER BY list of s, ending with the symbol so that ties page stably
+func (s StockSort) orderBy() string {
+	column, ok := stockSortColumns[s.Field]
+	if !ok {
+		column = stockSortColumns[StockSortMarketCap]
+	}
+	direction := " DESC"
+	if s.Ascending {
+		direction = ""
+	}
+	if s.Field == StockSortSymbol {
+		return column + direction
+	}
+	return column + direction + ", s.symbol"
+}
+
+// stockFilterSQL builds the conditions of a stock list query, bound to
+// placeholders numbered from 1
+type stockFilterSQL struct {
+	conditions []string
+	args       []interface{}
+}
+
+// add appends a condition whose %s verbs are each replaced by the placeholder of the next arg
+func (b *stockFilterSQL) add(condition string, args ...interface{}) {
+	placeholders := make([]interface{}, len(args))
+	for i, arg := range args {
+		b.args = append(b.args, arg)
+		placeholders[i] = fmt.Sprintf("$%d", len(b.args))
+	}
+	b.conditions = append(b.conditions, fmt.Sprintf(condition, placeholders...))
+}
+
+// where is the conditions as a fragment following WHERE s.is_active = true
+func (b *stockFilterSQL) where() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " AND " + strings.Join(b.conditions, " AND ")
+}
+
+// filterSQL returns the conditions and args of filter for a query over
+// stocks s joined to its latest prices. Price ranges are bucketed by the
+// latest close as in Resolve, falling back to the stored column.
+func (d *DatabaseStockService) filterSQL(filter StockFilter) *stockFilterSQL {
+	b := &stockFilterSQL{}
+	if filter.Sector != "" {
+		b.add("s.sector = %s", filter.Sector)
+	}
+	if filter.PriceRange != "" {
+		bucket := strings.ReplaceAll(d.priceRanges.SQLCase("latest.close_price"), "%", "%%")
+		b.add("CASE WHEN latest.close_price > 0 THEN "+bucket+" ELSE s.price_range END = %s", filter.PriceRange)
+	}
+	switch filter.MarketCap {
+	case MarketCapLarge:
+		b.add("s.market_cap >= %s", d.marketCapThresholds.Large)
+	case MarketCapMid:
+		b.add("s.market_cap >= %s AND s.market_cap < %s", d.marketCapThresholds.Mid, d.marketCapThresholds.Large)
+	case MarketCapSmall:
+		b.add("s.market_cap < %s", d.marketCapThresholds.Mid)
+	}
+	if filter.Exchange != "" {
+		b.add("s.exchange = %s", filter.Exchange)
+	}
+	if filter.MinHistoryDays > 0 {
+		b.add("s.last_price_date - s.first_price_date >= %s", filter.MinHistoryDays)
+	}
+	return b
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMarketCapBand(t *testing.T) {
+	band, err := ParseMarketCapBand("Large")
+	require.NoError(t, err)
+	assert.Equal(t, MarketCapLarge, band)
+
+	_, err = ParseMarketCapBand("mega")
+	assert.EqualError(t, err, "market_cap must be one of large, mid, small")
+
+	exchange, err := ParseExchange("nasdaq")
+	require.NoError(t, err)
+	assert.Equal(t, "NASDAQ", exchange)
+
+	_, err = ParseExchange("LSE")
+	assert.EqualError(t, err, "exchange must be one of NYSE, NASDAQ")
+}
+
+func TestParseMarketCapThresholds(t *testing.T) {
+	thresholds, err := ParseMarketCapThresholds("300000000, 5000000000")
+	require.NoError(t, err)
+	assert.Equal(t, MarketCapThresholds{Mid: 300000000, Large: 5000000000}, thresholds)
+
+	for _, value := range []string{"2000000000", "10000000000,2000000000", "0,2000000000", "2B,10B"} {
+		_, err := ParseMarketCapThresholds(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestFilterSQL_MarketCapBandBoundaries(t *testing.T) {
+	service := NewDatabaseStockService(nil, nil)
+
+	// A cap on a threshold belongs to the band above it
+	tests := []struct {
+		band      MarketCapBand
+		condition string
+		args      []interface{}
+	}{
+		{MarketCapLarge, "s.market_cap >= $1", []interface{}{int64(10000000000)}},
+		{MarketCapMid, "s.market_cap >= $1 AND s.market_cap < $2", []interface{}{int64(2000000000), int64(10000000000)}},
+		{MarketCapSmall, "s.market_cap < $1", []interface{}{int64(2000000000)}},
+	}
+	for _, tt := range tests {
+		conditions := service.filterSQL(StockFilter{MarketCap: tt.band})
+		assert.Equal(t, " AND "+tt.condition, conditions.where(), tt.band)
+		assert.Equal(t, tt.args, conditions.args, tt.band)
+	}
+
+	service.SetMarketCapThresholds(MarketCapThresholds{Mid: 300000000, Large: 5000000000})
+	assert.Equal(t, []interface{}{int64(5000000000)}, service.filterSQL(StockFilter{MarketCap: MarketCapLarge}).args)
+	assert.Empty(t, service.filterSQL(StockFilter{}).where())
+}
+
+func TestGetAllStocksPaginated_CombinedFilters(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Sector, price range, band and exchange bound in order, for both the count and the page
+	filters := `s.sector = \$1 AND CASE WHEN latest.close_price > 0 THEN CASE .+ END ELSE s.price_range END = \$2 ` +
+		`AND s.market_cap >= \$3 AND s.market_cap < \$4 AND s.exchange = \$5`
+	args := []driver.Value{"Technology", "$100-150", int64(2000000000), int64(10000000000), "NASDAQ"}
+	mock.ExpectQuery(`SELECT COUNT\(DISTINCT s.id\)[\s\S]+` + filters).WithArgs(args...).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(filters + `\s+ORDER BY s.market_cap DESC, s.symbol\s+LIMIT \$6 OFFSET \$7`).
+		WithArgs(append(args, 20, 40)...).
+		WillReturnRows(sqlmock.NewRows(rankedStockColumns))
+
+	service := NewDatabaseStockService(db, nil)
+	stocks, total, err := service.GetAllStocksPaginated(context.Background(), StockFilter{
+		Sector:     "Technology",
+		PriceRange: "$100-150",
+		MarketCap:  MarketCapMid,
+		Exchange:   "NASDAQ",
+	}, 20, 40)
+
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	assert.Empty(t, stocks)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStockSort_OrderBy(t *testing.T) {
+	assert.Equal(t, "s.market_cap DESC, s.symbol", StockSort{}.orderBy())
+	assert.Equal(t, "change_percent, s.symbol", StockSort{Field: StockSortChangePercent, Ascending: true}.orderBy())
+	assert.Equal(t, "current_price DESC, s.symbol", StockSort{Field: StockSortPrice}.orderBy())
+	assert.Equal(t, "s.symbol", StockSort{Field: StockSortSymbol, Ascending: true}.orderBy())
+	assert.Equal(t, "s.market_cap DESC, s.symbol", StockSort{Field: "name"}.orderBy())
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"stock-intelligence-backend/internal/jobs"
+	"stock-intelligence-backend/internal/models"
+)
+
+// StockReader reads stocks and the market aggregates computed over them.
+// DatabaseStockService implements it.
+type StockReader interface {
+	GetAllStocks(ctx context.Context) []models.Stock
+	GetAllStocksPaginated(ctx context.Context, filter StockFilter, limit, offset int) ([]models.Stock, int, error)
+	GetStocksAfter(ctx context.Context, filter StockFilter, cursor *StockCursor, limit int) ([]models.Stock, *StockCursor, error)
+	GetStocksBySectorPaginated(ctx context.Context, sector string, limit, offset, minHistoryDays int) ([]models.Stock, int, error)
+	GetStocksByPriceRange(ctx context.Context, priceRange string) []models.Stock
+	GetStockBySymbol(ctx context.Context, symbol string) (*models.Stock, error)
+	GetQuotes(ctx context.Context, symbols []string) (*QuotesResult, error)
+	GetPriceRanges() *PriceRanges
+	GetMarketFilters(ctx context.Context) (*MarketFilters, error)
+	GetMarketOverviewAggregate(ctx context.Context) (*MarketOverviewAggregate, error)
+	GetMarketHeatmap(ctx context.Context) (MarketHeatmap, error)
+	GetTopMovers(ctx context.Context, limit int) (*TopMovers, error)
+	GetWindowMovers(ctx context.Context, window string) (*WindowMovers, error)
+	GetVolumeSpikes(ctx context.Context, minRatio float64) ([]ActiveStock, error)
+	GetCompanyFundamentals(ctx context.Context, symbol string) (*models.CompanyFundamentals, error)
+	GetDividends(ctx context.Context, symbol string) ([]Dividend, error)
+	GetDividendLeaders(ctx context.Context, limit int) ([]DividendLeader, error)
+	GetNews(ctx context.Context, symbol string, limit int) ([]NewsArticle, error)
+}
+
+// PriceReader reads a stock's stored prices. DatabaseStockService implements it.
+type PriceReader interface {
+	GetPriceHistory(ctx context.Context, symbol string, days int, adjusted bool) ([]models.PricePoint, error)
+	GetPriceHistories(ctx context.Context, symbols []string, days int, adjusted bool) (map[string][]models.PricePoint, error)
+	GetIntradayPrices(ctx context.Context, symbol, interval string, date time.Time) ([]models.IntradayPrice, error)
+	GetPriceSeries(ctx context.Context, symbol string, resolution PriceResolution, from, to time.Time) ([]PriceBar, error)
+}
+
+// StockWriter changes stored stock data on request. SchedulerService implements it.
+type StockWriter interface {
+	TriggerManualSync(ctx context.Context, symbol string, mode SyncMode) (job *jobs.Job, deduplicated bool, err error)
+}
+
+// SyncScheduler is a StockWriter that also reports on the scheduled syncs
+type SyncScheduler interface {
+	StockWriter
+	GetStatus() DataSyncStatus
+	GetManualSyncDedupStats() ManualSyncDedupStats
+	GetManualSyncStatus(symbol string) (*ManualSyncStatus, error)
+	ListRuns(ctx context.Context, job string, limit int) ([]SchedulerRun, error)
+	GetSyncQueue(ctx context.Context, limit int) ([]SyncCandidate, error)
+	GetDataGaps(ctx context.Context, minGapDays int) ([]SymbolGaps, error)
+	Pause(ctx context.Context) error
+	Resume(ctx context.Context) error
+}
+
+// StockFeed is the request-free view of stocks pushed to WebSocket clients.
+// HybridStockService implements it.
+type StockFeed interface {
+	GetAllStocks() []models.Stock
+	GetStockBySymbol(symbol string) *models.Stock
+	GetPerformanceData() models.StockPerformance
+	GetMarketOverview() models.MarketOverview
+}
+
+var (
+	_ StockReader   = (*DatabaseStockService)(nil)
+	_ PriceReader   = (*DatabaseStockService)(nil)
+	_ SyncScheduler = (*SchedulerService)(nil)
+	_ StockFeed     = (*HybridStockService)(nil)
+)
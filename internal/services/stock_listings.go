@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"stock-intelligence-backend/internal/cache"
+)
+
+// ErrStockExists is returned when adding a symbol the stocks table already lists
+var ErrStockExists = errors.New("stock already exists")
+
+// NewListing is a stock to start tracking, usually picked from a symbol search
+type NewListing struct {
+	Symbol      string `json:"symbol"`
+	CompanyName string `json:"company_name"`
+	Exchange    string `json:"exchange"`
+	Region      string `json:"region"`
+}
+
+// ListedStock is a stock as it was added
+type ListedStock struct {
+	ID          int       `json:"id"`
+	Symbol      string    `json:"symbol"`
+	CompanyName string    `json:"company_name"`
+	Exchange    string    `json:"exchange"`
+	Region      string    `json:"region,omitempty"`
+	IsActive    bool      `json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// StockListingService adds stocks without a seed change and redeploy
+type StockListingService struct {
+	db    *sql.DB
+	cache *cache.RedisCache
+}
+
+// NewStockListingService creates a listing service; cache may be nil
+func NewStockListingService(db *sql.DB, redisCache *cache.RedisCache) *StockListingService {
+	return &StockListingService{db: db, cache: redisCache}
+}
+
+// AddStock inserts an active stock, which has no prices yet and so is pending
+// its first sync straight away. listing must already be validated; a symbol
+// that is already listed, active or not, is ErrStockExists.
+func (s *StockListingService) AddStock(ctx context.Context, listing NewListing) (*ListedStock, error) {
+	stock := &ListedStock{
+		Symbol:      listing.Symbol,
+		CompanyName: listing.CompanyName,
+		Exchange:    listing.Exchange,
+		Region:      listing.Region,
+		IsActive:    true,
+	}
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO stocks (symbol, company_name, exchange, region, is_active)
+		VALUES ($1, $2, $3, NULLIF($4, ''), true)
+		ON CONFLICT (symbol) DO NOTHING
+		RETURNING id, created_at
+	`, listing.Symbol, listing.CompanyName, listing.Exchange, listing.Region).Scan(&stock.ID, &stock.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrStockExists, listing.Symbol)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to add stock %s: %w", listing.Symbol, err)
+	}
+
+	// Cached stock lists and counts do not know the new stock, and a lookup
+	// of it before it was added is cached as missing. Both go even if the
+	// request is gone now that it is added.
+	if s.cache != nil {
+		if err := s.cache.InvalidateAll(context.WithoutCancel(ctx)); err != nil {
+			slog.WarnContext(ctx, "Failed to invalidate cache after adding a stock", "symbol", listing.Symbol, "error", err)
+		}
+	}
+	return stock, nil
+}
+
+// StockUpdate is a partial edit of a stock's metadata; nil fields are left as they are
+type StockUpdate struct {
+	CompanyName *string `json:"company_name"`
+	Sector      *string `json:"sector"`
+	Industry    *string `json:"industry"`
+	Exchange    *string `json:"exchange"`
+	IsActive    *bool   `json:"is_active"`
+	MarketCap   *int64  `json:"market_cap"`
+}
+
+// StockMetadata is the editable description of a stock, active or not
+type StockMetadata struct {
+	ID          int        `json:"id"`
+	Symbol      string     `json:"symbol"`
+	CompanyName string     `json:"company_name"`
+	Sector      string     `json:"sector"`
+	Industry    string     `json:"industry"`
+	Exchange    string     `json:"exchange"`
+	Region      string     `json:"region,omitempty"`
+	MarketCap   *int64     `json:"market_cap"`
+	IsActive    bool       `json:"is_active"`
+	DelistedAt  *time.Time `json:"delisted_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// apply sets the fields update changes on stock, and returns their old and new
+// values by column name. Setting a field to its current value is no change.
+// Activating a delisted stock clears its delisting, which was a false positive.
+func (update StockUpdate) apply(stock *StockMetadata) (before, after map[string]interface{}) {
+	before, after = map[string]interface{}{}, map[string]interface{}{}
+	setString := func(column string, field *string, value *string) {
+		if value != nil && *value != *field {
+			before[column], after[column] = *field, *value
+			*field = *value
+		}
+	}
+	setString("company_name", &stock.CompanyName, update.CompanyName)
+	setString("sector", &stock.Sector, update.Sector)
+	setString("industry", &stock.Industry, update.Industry)
+	setString("exchange", &stock.Exchange, update.Exchange)
+
+	if update.MarketCap != nil && (stock.MarketCap == nil || *stock.MarketCap != *update.MarketCap) {
+		before["market_cap"], after["market_cap"] = stock.MarketCap, *update.MarketCap
+		marketCap := *update.MarketCap
+		stock.MarketCap = &marketCap
+	}
+	if update.IsActive != nil && *update.IsActive != stock.IsActive {
+		before["is_active"], after["is_active"] = stock.IsActive, *update.IsActive
+		stock.IsActive = *update.IsActive
+	}
+	if stock.IsActive && stock.DelistedAt != nil {
+		before["delisted_at"], after["delisted_at"] = stock.DelistedAt, nil
+		stock.DelistedAt = nil
+	}
+	return before, after
+}
+
+// UpdateStock applies update to symbol's metadata, active or not, and records
+// the change in stock_audit_log. An update that changes nothing writes
+// nothing. Only active stocks are listed and synced, so deactivating a stock
+// takes it out of the sync queue and reactivating puts it back.
+func (s *StockListingService) UpdateStock(ctx context.Context, symbol string, update StockUpdate) (*StockMetadata, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin stock update: %w", err)
+	}
+	defer tx.Rollback()
+
+	var stock StockMetadata
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, symbol, company_name, COALESCE(sector, ''), COALESCE(industry, ''), COALESCE(exchange, ''),
+		       COALESCE(region, ''), market_cap, COALESCE(is_active, false), delisted_at, updated_at
+		FROM stocks
+		WHERE symbol = $1
+		FOR UPDATE
+	`, symbol).Scan(&stock.ID, &stock.Symbol, &stock.CompanyName, &stock.Sector, &stock.Industry, &stock.Exchange,
+		&stock.Region, &stock.MarketCap, &stock.IsActive, &stock.DelistedAt, &stock.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrStockNotFound, symbol)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stock %s: %w", symbol, err)
+	}
+
+	before, after := update.apply(&stock)
+	if len(after) == 0 {
+		return &stock, nil
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE stocks
+		SET company_name = $2, sector = NULLIF($3, ''), industry = NULLIF($4, ''), exchange = $5,
+		    market_cap = $6, is_active = $7, delisted_at = $8
+		WHERE id = $1
+		RETURNING updated_at
+	`, stock.ID, stock.CompanyName, stock.Sector, stock.Industry, stock.Exchange, stock.MarketCap, stock.IsActive,
+		stock.DelistedAt).Scan(&stock.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update stock %s: %w", symbol, err)
+	}
+
+	beforeJSON, err := json.Marshal(before)
+	if err != nil {
+		return nil, err
+	}
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO stock_audit_log (stock_id, symbol, before, after) VALUES ($1, $2, $3, $4)
+	`, stock.ID, stock.Symbol, beforeJSON, afterJSON); err != nil {
+		return nil, fmt.Errorf("failed to record change to %s: %w", symbol, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit stock update: %w", err)
+	}
+
+	// Stock lists, sector pages, overviews and the stock's own entries all describe it
+	if s.cache != nil {
+		if err := s.cache.InvalidateAll(context.WithoutCancel(ctx)); err != nil {
+			slog.WarnContext(ctx, "Failed to invalidate cache after updating a stock", "symbol", symbol, "error", err)
+		}
+	}
+	return &stock, nil
+}
+
+// SetActive activates or deactivates symbol, as UpdateStock does
+func (s *StockListingService) SetActive(ctx context.Context, symbol string, active bool) (*StockMetadata, error) {
+	return s.UpdateStock(ctx, symbol, StockUpdate{IsActive: &active})
+}
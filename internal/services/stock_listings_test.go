@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/cache"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddStock_InsertsActiveStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	created := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`INSERT INTO stocks \(symbol, company_name, exchange, region, is_active\)\s+VALUES \(\$1, \$2, \$3, NULLIF\(\$4, ''\), true\)\s+ON CONFLICT \(symbol\) DO NOTHING`).
+		WithArgs("TSCO.LON", "Tesco PLC", "LSE", "United Kingdom").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(42, created))
+
+	stock, err := NewStockListingService(db, nil).AddStock(context.Background(), NewListing{
+		Symbol: "TSCO.LON", CompanyName: "Tesco PLC", Exchange: "LSE", Region: "United Kingdom",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, &ListedStock{
+		ID: 42, Symbol: "TSCO.LON", CompanyName: "Tesco PLC", Exchange: "LSE", Region: "United Kingdom",
+		IsActive: true, CreatedAt: created,
+	}, stock)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddStock_ClearsMissingRecord(t *testing.T) {
+	service, mock, redisCache := newListingServiceWithCache(t)
+	require.NoError(t, redisCache.MarkStockMissing(context.Background(), "TSCO.LON", MissingStockTTL))
+
+	mock.ExpectQuery(`INSERT INTO stocks`).WithArgs("TSCO.LON", "Tesco PLC", "LSE", "United Kingdom").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(42, time.Now()))
+	_, err := service.AddStock(context.Background(), NewListing{
+		Symbol: "TSCO.LON", CompanyName: "Tesco PLC", Exchange: "LSE", Region: "United Kingdom",
+	})
+	require.NoError(t, err)
+
+	missing, err := redisCache.IsStockMissing(context.Background(), "TSCO.LON")
+	require.NoError(t, err)
+	assert.False(t, missing, "the new stock is found at once")
+}
+
+func TestAddStock_ListedSymbolIsErrStockExists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("INSERT INTO stocks").
+		WithArgs("AAPL", "Apple Inc.", "NASDAQ", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}))
+
+	_, err = NewStockListingService(db, nil).AddStock(context.Background(), NewListing{
+		Symbol: "AAPL", CompanyName: "Apple Inc.", Exchange: "NASDAQ",
+	})
+
+	assert.ErrorIs(t, err, ErrStockExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPendingStocksForSync_IncludesStockWithoutMarketCap(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("FROM index_constituents ic").WithArgs(IndexSP500).
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "company_name", "priority", "market_cap"}).AddRow("AAPL", "Apple Inc.", 1, int64(3000000000000)))
+	// An added stock has no market cap until its fundamentals are fetched
+	mock.ExpectQuery(`SELECT s.symbol, s.company_name, COALESCE\(s.market_cap, 0\)`).
+		WithArgs(10, SufficientHistoryDays, IndexSP500).
+		WillReturnRows(sqlmock.NewRows([]string{"symbol", "company_name", "market_cap", "history_days"}).AddRow("TSCO.LON", "Tesco PLC", int64(0), 0))
+
+	pending, err := NewSP500PriorityService(db).GetPendingStocksForSync(10)
+
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "TSCO.LON", pending[0].Symbol)
+	assert.False(t, pending[0].HasData)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+var stockMetadataColumns = []string{
+	"id", "symbol", "company_name", "sector", "industry", "exchange", "region", "market_cap", "is_active", "delisted_at", "updated_at",
+}
+
+func newListingServiceWithCache(t *testing.T) (*StockListingService, sqlmock.Sqlmock, *cache.RedisCache) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache("redis://"+server.Addr(), "test:")
+	require.NoError(t, err)
+	t.Cleanup(func() { redisCache.Close() })
+
+	return NewStockListingService(db, redisCache), mock, redisCache
+}
+
+func expectStockLocked(mock sqlmock.Sqlmock, symbol string, marketCap interface{}, active bool, delistedAt interface{}) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM stocks\s+WHERE symbol = \$1\s+FOR UPDATE`).WithArgs(symbol).
+		WillReturnRows(sqlmock.NewRows(stockMetadataColumns).
+			AddRow(7, symbol, "Apple Inc.", "Technology", "", "NASDAQ", "", marketCap, active, delistedAt, time.Now()))
+}
+
+func TestUpdateStock_PartialUpdateKeepsOtherFields(t *testing.T) {
+	service, mock, redisCache := newListingServiceWithCache(t)
+	require.NoError(t, redisCache.SetStocksList(context.Background(), []string{"AAPL"}, time.Hour))
+	require.NoError(t, redisCache.SetSectorData(context.Background(), "Technology", []string{"AAPL"}, time.Hour))
+
+	sector, industry := "Information Technology", "Consumer Electronics"
+	expectStockLocked(mock, "AAPL", int64(3000000000000), true, nil)
+	mock.ExpectQuery("UPDATE stocks").
+		WithArgs(7, "Apple Inc.", sector, industry, "NASDAQ", int64(3000000000000), true, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(time.Now()))
+	mock.ExpectExec("INSERT INTO stock_audit_log").
+		WithArgs(7, "AAPL",
+			[]byte(`{"industry":"","sector":"Technology"}`),
+			[]byte(`{"industry":"Consumer Electronics","sector":"Information Technology"}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	stock, err := service.UpdateStock(context.Background(), "AAPL", StockUpdate{Sector: &sector, Industry: &industry})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Apple Inc.", stock.CompanyName)
+	assert.Equal(t, sector, stock.Sector)
+	assert.Equal(t, industry, stock.Industry)
+	assert.True(t, stock.IsActive)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The cached list and sector no longer know the stock as it was
+	var cached []string
+	assert.Error(t, redisCache.GetStocksList(context.Background(), &cached))
+	assert.Error(t, redisCache.GetSectorData(context.Background(), "Technology", &cached))
+}
+
+func TestUpdateStock_NoChangeWritesNothing(t *testing.T) {
+	service, mock, redisCache := newListingServiceWithCache(t)
+	require.NoError(t, redisCache.SetStocksList(context.Background(), []string{"AAPL"}, time.Hour))
+
+	name, active := "Apple Inc.", true
+	expectStockLocked(mock, "AAPL", nil, true, nil)
+	mock.ExpectRollback()
+
+	stock, err := service.UpdateStock(context.Background(), "AAPL", StockUpdate{CompanyName: &name, IsActive: &active})
+
+	require.NoError(t, err)
+	assert.Nil(t, stock.MarketCap)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	var cached []string
+	assert.NoError(t, redisCache.GetStocksList(context.Background(), &cached), "nothing changed, so nothing is invalidated")
+}
+
+func TestSetActive_DeactivatesAndRecordsChange(t *testing.T) {
+	service, mock, _ := newListingServiceWithCache(t)
+
+	expectStockLocked(mock, "TWTR", nil, true, nil)
+	mock.ExpectQuery("UPDATE stocks").
+		WithArgs(7, "Apple Inc.", "Technology", "", "NASDAQ", nil, false, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(time.Now()))
+	mock.ExpectExec("INSERT INTO stock_audit_log").
+		WithArgs(7, "TWTR", []byte(`{"is_active":true}`), []byte(`{"is_active":false}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	stock, err := service.SetActive(context.Background(), "TWTR", false)
+
+	require.NoError(t, err)
+	assert.False(t, stock.IsActive)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateStock_UnknownSymbol(t *testing.T) {
+	service, mock, _ := newListingServiceWithCache(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WithArgs("NOTREAL").WillReturnRows(sqlmock.NewRows(stockMetadataColumns))
+	mock.ExpectRollback()
+
+	_, err := service.SetActive(context.Background(), "NOTREAL", true)
+
+	assert.ErrorIs(t, err, ErrStockNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetActive_ClearsDelisting(t *testing.T) {
+	service, mock, _ := newListingServiceWithCache(t)
+
+	delistedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	expectStockLocked(mock, "TWTR", nil, false, delistedAt)
+	mock.ExpectQuery("UPDATE stocks").
+		WithArgs(7, "Apple Inc.", "Technology", "", "NASDAQ", nil, true, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(time.Now()))
+	mock.ExpectExec("INSERT INTO stock_audit_log").
+		WithArgs(7, "TWTR",
+			[]byte(`{"delisted_at":"2026-10-01T12:00:00Z","is_active":false}`),
+			[]byte(`{"delisted_at":null,"is_active":true}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	stock, err := service.SetActive(context.Background(), "TWTR", true)
+
+	require.NoError(t, err)
+	assert.True(t, stock.IsActive)
+	assert.Nil(t, stock.DelistedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
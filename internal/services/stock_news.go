@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// NewsMinDailyCallsLeft is how many calls of the daily budget must remain for
+// the news refresh to spend one
+const NewsMinDailyCallsLeft = 5
+
+// AverageSentiment is the mean sentiment score of articles, nil without any
+func AverageSentiment(articles []NewsArticle) *float64 {
+	if len(articles) == 0 {
+		return nil
+	}
+	var total float64
+	for _, article := range articles {
+		total += article.SentimentScore
+	}
+	average := total / float64(len(articles))
+	return &average
+}
+
+// saveStockNews stores a stock's articles and returns how many were new. An
+// article already stored for the stock, matched on its URL, is left as it is.
+func saveStockNews(ctx context.Context, db *sql.DB, symbol string, articles []NewsArticle) (int, error) {
+	var stockID int
+	err := db.QueryRowContext(ctx, "SELECT id FROM stocks WHERE symbol = $1", symbol).Scan(&stockID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("%w: %s", ErrStockNotFound, symbol)
+		}
+		return 0, fmt.Errorf("failed to get stock ID: %w", err)
+	}
+
+	values := make([]string, 0, len(articles))
+	args := []interface{}{stockID}
+	seen := make(map[string]bool, len(articles))
+	for _, article := range articles {
+		if seen[article.URL] {
+			continue
+		}
+		seen[article.URL] = true
+		n := len(args)
+		values = append(values, fmt.Sprintf("($1, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6))
+		args = append(args, article.Title, article.URL, article.Source, article.PublishedAt, article.SentimentScore, article.SentimentLabel)
+	}
+
+	inserted := 0
+	if len(values) > 0 {
+		result, err := db.ExecContext(ctx, `
+			INSERT INTO stock_news (stock_id, title, url, source, published_at, sentiment_score, sentiment_label)
+			VALUES `+strings.Join(values, ", ")+`
+			ON CONFLICT (stock_id, url) DO NOTHING
+		`, args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to save news for %s: %w", symbol, err)
+		}
+		affected, _ := result.RowsAffected()
+		inserted = int(affected)
+	}
+
+	// Recorded even without articles, so the refresh moves on to the next stock
+	if _, err := db.ExecContext(ctx, "UPDATE stocks SET news_fetched_at = CURRENT_TIMESTAMP WHERE id = $1", stockID); err != nil {
+		return inserted, fmt.Errorf("failed to record news fetch for %s: %w", symbol, err)
+	}
+	return inserted, nil
+}
+
+// GetNews returns up to limit of a stock's stored articles, latest first, or
+// ErrStockNotFound when symbol is neither an active nor a delisted stock
+func (d *DatabaseStockService) GetNews(ctx context.Context, symbol string, limit int) ([]NewsArticle, error) {
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+
+	// One row with NULL article columns tells a stock without news from an unknown symbol
+	rows, err := d.router.ReaderFor(symbol).QueryContext(ctx, `
+		SELECT n.title, n.url, COALESCE(n.source, ''), n.published_at, n.sentiment_score, n.sentiment_label
+		FROM stocks s
+		LEFT JOIN LATERAL (
+			SELECT title, url, source, published_at, sentiment_score, sentiment_label
+			FROM stock_news
+			WHERE stock_id = s.id
+			ORDER BY published_at DESC, id DESC
+			LIMIT $2
+		) n ON true
+		WHERE s.symbol = $1 AND (s.is_active = true OR s.delisted_at IS NOT NULL)
+	`, symbol, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query news: %w", queryError(ctx, err))
+	}
+	defer rows.Close()
+
+	found := false
+	articles := []NewsArticle{}
+	for rows.Next() {
+		found = true
+		var title, url, source, label sql.NullString
+		var publishedAt sql.NullTime
+		var score sql.NullFloat64
+		if err := rows.Scan(&title, &url, &source, &publishedAt, &score, &label); err != nil {
+			return nil, fmt.Errorf("failed to scan news article: %w", err)
+		}
+		if url.Valid {
+			articles = append(articles, NewsArticle{
+				Title:          title.String,
+				URL:            url.String,
+				Source:         source.String,
+				PublishedAt:    publishedAt.Time,
+				SentimentScore: score.Float64,
+				SentimentLabel: label.String,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read news: %w", queryError(ctx, err))
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrStockNotFound, symbol)
+	}
+	return articles, nil
+}
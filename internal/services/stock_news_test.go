@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var newsColumns = []string{"title", "url", "source", "published_at", "sentiment_score", "sentiment_label"}
+
+func TestSaveStockNews_RefetchStoresNoDuplicates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	published := time.Date(2024, time.January, 4, 21, 30, 0, 0, time.UTC)
+	article := NewsArticle{Title: "Apple Shares Slip", URL: "https://www.example.com/a", Source: "Benzinga", PublishedAt: published, SentimentScore: -0.312, SentimentLabel: "Somewhat-Bearish"}
+	// The feed repeats an article; it is sent once
+	articles := []NewsArticle{article, article}
+
+	for _, inserted := range []int64{1, 0} {
+		mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec(`INSERT INTO stock_news .+ VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7\)\s+ON CONFLICT \(stock_id, url\) DO NOTHING`).
+			WithArgs(1, article.Title, article.URL, article.Source, published, -0.312, "Somewhat-Bearish").
+			WillReturnResult(sqlmock.NewResult(0, inserted))
+		mock.ExpectExec("UPDATE stocks SET news_fetched_at = CURRENT_TIMESTAMP WHERE id = \\$1").WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	first, err := saveStockNews(context.Background(), db, "AAPL", articles)
+	require.NoError(t, err)
+	assert.Equal(t, 1, first)
+
+	second, err := saveStockNews(context.Background(), db, "AAPL", articles)
+	require.NoError(t, err)
+	assert.Zero(t, second, "the article was stored by the first fetch")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetNews(t *testing.T) {
+	service, mock := newMarketSummaryService(t)
+	published := time.Date(2024, time.January, 4, 21, 30, 0, 0, time.UTC)
+
+	mock.ExpectQuery("LEFT JOIN LATERAL").WithArgs("AAPL", 20).WillReturnRows(sqlmock.NewRows(newsColumns).
+		AddRow("Apple Shares Slip", "https://www.example.com/a", "Benzinga", published, -0.312, "Somewhat-Bearish"))
+	articles, err := service.GetNews(context.Background(), "AAPL", 20)
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	assert.Equal(t, -0.312, articles[0].SentimentScore)
+
+	// A stock without news has none; an unknown one is not found
+	mock.ExpectQuery("LEFT JOIN LATERAL").WithArgs("TSLA", 20).
+		WillReturnRows(sqlmock.NewRows(newsColumns).AddRow(nil, nil, nil, nil, nil, nil))
+	articles, err = service.GetNews(context.Background(), "TSLA", 20)
+	require.NoError(t, err)
+	assert.NotNil(t, articles)
+	assert.Empty(t, articles)
+
+	mock.ExpectQuery("LEFT JOIN LATERAL").WithArgs("ZZZZ", 20).WillReturnRows(sqlmock.NewRows(newsColumns))
+	_, err = service.GetNews(context.Background(), "ZZZZ", 20)
+	assert.ErrorIs(t, err, ErrStockNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAverageSentiment(t *testing.T) {
+	assert.Nil(t, AverageSentiment(nil))
+
+	average := AverageSentiment([]NewsArticle{{SentimentScore: -0.3}, {SentimentScore: 0.2}, {SentimentScore: 0.4}})
+	require.NotNil(t, average)
+	assert.InDelta(t, 0.1, *average, 1e-9)
+}
+
+func TestNewsRefreshJob_LeavesBudgetForPrices(t *testing.T) {
+	client, mock := newRetryTestClient(t, serveFixture(t, "alphavantage_news_sentiment_aapl.json", nil))
+	scheduler := NewSchedulerService(client.db, client, nil, nil, nil)
+	// 22:00, after the last hourly price sync but one
+	scheduler.now = func() time.Time { return time.Date(2026, time.March, 10, 22, 0, 0, 0, time.UTC) }
+
+	// Four calls left: below the news refresh's floor
+	expectBudget(mock, 25-(NewsMinDailyCallsLeft-1))
+	expectSchedulerRun(mock, SchedulerJobNews, RunStatusSkipped)
+	scheduler.newsRefreshJob()
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// Five calls left: one goes on the stock fetched longest ago
+	expectBudget(mock, 25-NewsMinDailyCallsLeft)
+	mock.ExpectQuery("ORDER BY s.news_fetched_at ASC NULLS FIRST").
+		WillReturnRows(sqlmock.NewRows([]string{"symbol"}).AddRow("AAPL"))
+	expectRateLimitQuery(mock)
+	mock.ExpectExec("INSERT INTO api_calls").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRateLimitIncrement(mock)
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("AAPL").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO stock_news").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE stocks SET news_fetched_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSchedulerRun(mock, SchedulerJobNews, RunStatusSucceeded)
+	scheduler.newsRefreshJob()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewsRefreshJob_ReservesTheDaysPriceSyncs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	scheduler := NewSchedulerService(db, NewAlphaVantageClient("test-key", db), nil, nil, nil)
+	// At 08:00 the hourly syncs still need 15 calls today, more than the floor
+	scheduler.now = func() time.Time { return time.Date(2026, time.March, 10, 8, 0, 0, 0, time.UTC) }
+
+	expectBudget(mock, 25-15)
+	expectSchedulerRun(mock, SchedulerJobNews, RunStatusSkipped)
+	scheduler.newsRefreshJob()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
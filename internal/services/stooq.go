@@ -0,0 +1,215 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// stooqCallsPerMinute paces Stooq requests; it has no documented burst limit
+const stooqCallsPerMinute = 30
+
+// StooqClient is a MarketDataProvider backed by Stooq's keyless CSV downloads
+type StooqClient struct {
+	*providerUsage
+	baseURL string
+}
+
+func NewStooqClient(db *sql.DB) *StooqClient {
+	return &StooqClient{
+		providerUsage: newProviderUsage(ProviderStooq, db, stooqCallsPerMinute),
+		baseURL:       "https://stooq.com",
+	}
+}
+
+// FetchDailyData fetches the full daily history for a stock as CSV
+func (s *StooqClient) FetchDailyData(ctx context.Context, symbol string, initiator Initiator) (*DailySeries, error) {
+	if err := initiator.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkBudget(symbol); err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{
+		"s": stooqSymbol(symbol),
+		"i": "d",
+	}
+
+	response, err := s.makeRequest(ctx, initiator, "daily", s.baseURL+"/q/d/l/", params)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Stooq API error", "symbol", symbol, "error", err)
+		return nil, err
+	}
+
+	series, err := parseStooqDaily(symbol, response)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "Successfully fetched daily data", "symbol", symbol, "days", len(series.Bars))
+	return series, nil
+}
+
+// FetchQuote fetches the latest trading day's prices for a stock
+func (s *StooqClient) FetchQuote(symbol string, initiator Initiator) (*Quote, error) {
+	if err := initiator.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkBudget(symbol); err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{
+		"s": stooqSymbol(symbol),
+		"f": "sd2t2ohlcv",
+		"h": "",
+		"e": "csv",
+	}
+
+	response, err := s.makeRequest(context.Background(), initiator, "quote", s.baseURL+"/q/l/", params)
+	if err != nil {
+		s.logger.Error("Stooq API error", "symbol", symbol, "error", err)
+		return nil, err
+	}
+
+	return parseStooqQuote(symbol, response)
+}
+
+func (s *StooqClient) checkBudget(symbol string) error {
+	canMake, err := s.CanMakeRequest()
+	if err != nil {
+		return fmt.Errorf("failed to check rate limit: %w", err)
+	}
+	if !canMake {
+		return &APIError{Provider: ProviderStooq, Kind: APIErrorRateLimited, Symbol: symbol, Message: "local API budget exhausted"}
+	}
+	return nil
+}
+
+// stooqSymbol maps a US ticker to Stooq's form, e.g. BRK.B becomes brk-b.us
+func stooqSymbol(symbol string) string {
+	return strings.ToLower(strings.ReplaceAll(symbol, ".", "-")) + ".us"
+}
+
+// stooqBodyError classifies the plain-text bodies Stooq returns with a 200 status
+func stooqBodyError(symbol string, body []byte) error {
+	text := strings.TrimSpace(string(body))
+	switch {
+	case text == "" || strings.EqualFold(text, "No data"):
+		return &APIError{Provider: ProviderStooq, Kind: APIErrorInvalidSymbol, Symbol: symbol, Message: "no data for symbol"}
+	case strings.Contains(strings.ToLower(text), "limit"):
+		return &APIError{Provider: ProviderStooq, Kind: APIErrorRateLimited, Symbol: symbol, Message: text}
+	}
+	return nil
+}
+
+// parseStooqDaily decodes a Date,Open,High,Low,Close,Volume CSV download
+func parseStooqDaily(symbol string, body []byte) (*DailySeries, error) {
+	if err := stooqBodyError(symbol, body); err != nil {
+		return nil, err
+	}
+
+	records, err := readStooqCSV(body)
+	if err != nil {
+		return nil, err
+	}
+
+	series := &DailySeries{Symbol: symbol, Bars: make([]DailyBar, 0, len(records))}
+	for _, record := range records {
+		bar, err := parseStooqBar(record["Date"], record)
+		if err != nil {
+			series.reject(err)
+			continue
+		}
+		series.Bars = append(series.Bars, bar)
+	}
+
+	if len(series.Bars) == 0 {
+		return nil, &APIError{Provider: ProviderStooq, Kind: APIErrorNoData, Symbol: symbol, Message: "no time series data returned"}
+	}
+	return series, nil
+}
+
+// parseStooqQuote decodes a Symbol,Date,Time,Open,High,Low,Close,Volume quote.
+// Unknown symbols come back as a row of N/D values.
+func parseStooqQuote(symbol string, body []byte) (*Quote, error) {
+	if err := stooqBodyError(symbol, body); err != nil {
+		return nil, err
+	}
+
+	records, err := readStooqCSV(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || records[0]["Date"] == "N/D" {
+		return nil, &APIError{Provider: ProviderStooq, Kind: APIErrorInvalidSymbol, Symbol: symbol, Message: "no quote returned"}
+	}
+
+	bar, err := parseStooqBar(records[0]["Date"], records[0])
+	if err != nil {
+		return nil, &APIError{Provider: ProviderStooq, Kind: APIErrorNoData, Symbol: symbol, Message: err.Error()}
+	}
+	return &Quote{Symbol: symbol, DailyBar: bar}, nil
+}
+
+// readStooqCSV returns each data row keyed by its header column
+func readStooqCSV(body []byte) ([]map[string]string, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Stooq response: %w", err)
+	}
+
+	var records []map[string]string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Stooq response: %w", err)
+		}
+
+		record := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(row) {
+				record[strings.TrimSpace(column)] = strings.TrimSpace(row[i])
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// parseStooqBar converts a CSV row into a bar; a malformed field is returned as a *RejectedRow
+func parseStooqBar(dateStr string, record map[string]string) (DailyBar, error) {
+	p := rowParser{date: dateStr}
+	bar := DailyBar{
+		Date:  p.parseDate(),
+		Open:  p.parseFloat("open", record["Open"]),
+		High:  p.parseFloat("high", record["High"]),
+		Low:   p.parseFloat("low", record["Low"]),
+		Close: p.parseFloat("close", record["Close"]),
+	}
+	if p.rejected != nil {
+		return DailyBar{}, p.rejected
+	}
+
+	// Indices and some thinly traded listings have no volume column
+	if volume, ok := record["Volume"]; ok && volume != "" {
+		if v, err := strconv.ParseFloat(volume, 64); err == nil {
+			bar.Volume = int64(v)
+		}
+	}
+	return bar, nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveFixture answers every request with a recorded response from testdata
+func serveFixture(t *testing.T, name string, requests *[]*http.Request) *httptest.Server {
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			*requests = append(*requests, r)
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newStooqTestClient(t *testing.T, server *httptest.Server) (*StooqClient, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	client := NewStooqClient(db)
+	client.baseURL = server.URL
+	client.SetRetryPolicy(1, 0, 0)
+	return client, mock
+}
+
+// expectStooqCall expects a fetch to check, log and count against the stooq budget row
+func expectStooqCall(mock sqlmock.Sqlmock, endpoint string) {
+	mock.ExpectQuery("FROM api_rate_limits").WithArgs(ProviderStooq).WillReturnRows(sqlmock.NewRows([]string{
+		"id", "service_name", "daily_limit", "hourly_limit", "current_daily_count",
+		"current_hourly_count", "last_reset_date", "last_reset_hour",
+	}).AddRow(2, ProviderStooq, 500, 100, 0, 0, marketDate(time.Now()), marketHour(time.Now())))
+	mock.ExpectExec("INSERT INTO api_calls").
+		WithArgs(ProviderStooq, endpoint, sqlmock.AnyArg(), http.StatusOK, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), string(InitiatorManualSync), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRequestRecorded(mock, ProviderStooq)
+}
+
+func TestStooqFetchDailyData_ParsesRecordedCSV(t *testing.T) {
+	var requests []*http.Request
+	client, mock := newStooqTestClient(t, serveFixture(t, "stooq_daily_aapl.csv", &requests))
+	expectStooqCall(mock, "daily")
+
+	data, err := client.FetchDailyData(context.Background(), "AAPL", InitiatorManualSync)
+
+	require.NoError(t, err)
+	require.Len(t, data.Bars, 3)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), data.Bars[0].Date)
+	assert.Equal(t, 187.15, data.Bars[0].Open)
+	assert.Equal(t, 185.64, data.Bars[0].Close)
+	assert.Equal(t, int64(82488674), data.Bars[0].Volume)
+
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/q/d/l/", requests[0].URL.Path)
+	assert.Equal(t, "aapl.us", requests[0].URL.Query().Get("s"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStooqFetchQuote_ParsesRecordedCSV(t *testing.T) {
+	client, mock := newStooqTestClient(t, serveFixture(t, "stooq_quote_aapl.csv", nil))
+	expectStooqCall(mock, "quote")
+
+	quote, err := client.FetchQuote("AAPL", InitiatorManualSync)
+
+	require.NoError(t, err)
+	assert.Equal(t, "AAPL", quote.Symbol)
+	assert.Equal(t, time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), quote.Date)
+	assert.Equal(t, 181.91, quote.Close)
+	assert.Equal(t, int64(71983570), quote.Volume)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStooqFetchQuote_UnknownSymbol(t *testing.T) {
+	client, mock := newStooqTestClient(t, serveFixture(t, "stooq_quote_unknown.csv", nil))
+	expectStooqCall(mock, "quote")
+
+	_, err := client.FetchQuote("NOTREAL", InitiatorManualSync)
+
+	assert.ErrorIs(t, err, ErrInvalidSymbol)
+	assert.Contains(t, err.Error(), "stooq")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParseStooqDaily_ClassifiesBodies(t *testing.T) {
+	_, err := parseStooqDaily("NOTREAL", []byte("No data"))
+	assert.ErrorIs(t, err, ErrInvalidSymbol)
+
+	_, err = parseStooqDaily("AAPL", []byte("Exceeded the daily hits limit"))
+	assert.ErrorIs(t, err, ErrRateLimited)
+
+	_, err = parseStooqDaily("AAPL", []byte("Date,Open,High,Low,Close,Volume\n"))
+	assert.ErrorIs(t, err, ErrNoData)
+}
+
+func TestStooqSymbol(t *testing.T) {
+	assert.Equal(t, "aapl.us", stooqSymbol("AAPL"))
+	assert.Equal(t, "brk-b.us", stooqSymbol("BRK.B"))
+}
+
+func TestAlphaVantageFetchQuote_ParsesRecordedJSON(t *testing.T) {
+	client, mock := newRetryTestClient(t, serveFixture(t, "alphavantage_quote_aapl.json", nil))
+	expectRateLimitQuery(mock)
+	mock.ExpectExec("INSERT INTO api_calls").
+		WithArgs(ProviderAlphaVantage, "GLOBAL_QUOTE", sqlmock.AnyArg(), http.StatusOK, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), string(InitiatorManualSync), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRateLimitIncrement(mock)
+
+	quote, err := client.FetchQuote("AAPL", InitiatorManualSync)
+
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), quote.Date)
+	assert.Equal(t, 181.91, quote.Close)
+	assert.Equal(t, int64(71983570), quote.Volume)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewMarketDataProvider(t *testing.T) {
+	for name, want := range map[string]string{
+		"":             ProviderAlphaVantage,
+		"alphavantage": ProviderAlphaVantage,
+		"Stooq":        ProviderStooq,
+		"sandbox":      ProviderSandbox,
+	} {
+		provider, err := NewMarketDataProvider(name, "test-key", nil)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, provider.Name(), name)
+	}
+
+	_, err := NewMarketDataProvider("yahoo", "", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// SyncHistoryRetention is how long sync outcomes are kept by the daily cleanup
+const SyncHistoryRetention = 90 * 24 * time.Hour
+
+// DelistingFailureThreshold is how many syncs in a row must fail with an
+// invalid symbol or no data before the stock is marked delisted
+const DelistingFailureThreshold = 3
+
+// SyncHistoryEntry is the stored outcome of syncing one stock
+type SyncHistoryEntry struct {
+	ID           int64        `json:"id"`
+	Symbol       string       `json:"symbol"`
+	Success      bool         `json:"success"`
+	ErrorKind    APIErrorKind `json:"error_kind,omitempty"`
+	ErrorMessage string       `json:"error_message,omitempty"`
+	RecordsAdded int          `json:"records_added"`
+	DurationMs   int64        `json:"duration_ms"`
+	TriggeredBy  Initiator    `json:"triggered_by"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+// SyncHistoryFilter narrows a history listing; zero values do not filter
+type SyncHistoryFilter struct {
+	Symbol string
+	// Days keeps entries from the last Days days
+	Days int
+	// Success keeps only successful (true) or failed (false) syncs
+	Success *bool
+	Limit   int
+	Offset  int
+}
+
+// SyncHistoryService stores the outcome of every stock the scheduler or a batch syncs
+type SyncHistoryService struct {
+	db *sql.DB
+}
+
+func NewSyncHistoryService(db *sql.DB) *SyncHistoryService {
+	return &SyncHistoryService{db: db}
+}
+
+// Record stores one stock's sync outcome, attributed to the initiator. A
+// failure that the provider will keep giving may mark the stock delisted.
+func (s *SyncHistoryService) Record(ctx context.Context, initiator Initiator, result StockSyncResult) error {
+	duration := result.EndTime.Sub(result.StartTime)
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO sync_history
+		(symbol, success, error_kind, error_message, records_added, duration_ms, triggered_by)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6, $7)
+	`, result.Symbol, result.Success, string(result.ErrorKind), result.ErrorMessage,
+		result.RecordsAdded, duration.Milliseconds(), string(initiator))
+	if err != nil {
+		return fmt.Errorf("failed to record sync history for %s: %w", result.Symbol, err)
+	}
+
+	if !result.Success && !result.ErrorKind.Retryable() {
+		if err := s.markDelistedAfterFailures(ctx, result.Symbol); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// looksDelisted reports whether the latest outcomes, newest first, are at
+// least threshold failures in a row that retrying will not fix
+func looksDelisted(outcomes []SyncHistoryEntry, threshold int) bool {
+	if len(outcomes) < threshold {
+		return false
+	}
+	for _, outcome := range outcomes[:threshold] {
+		if outcome.Success || outcome.ErrorKind.Retryable() {
+			return false
+		}
+	}
+	return true
+}
+
+// markDelistedAfterFailures marks symbol delisted and inactive when its last
+// DelistingFailureThreshold syncs all failed with an invalid symbol or no
+// data, as an acquired company's ticker does. Delisted stocks leave the sync
+// queue; activating one through the admin API clears the mark.
+func (s *SyncHistoryService) markDelistedAfterFailures(ctx context.Context, symbol string) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT success, COALESCE(error_kind, '')
+		FROM sync_history
+		WHERE symbol = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`, symbol, DelistingFailureThreshold)
+	if err != nil {
+		return fmt.Errorf("failed to check %s for delisting: %w", symbol, err)
+	}
+	defer rows.Close()
+
+	var outcomes []SyncHistoryEntry
+	for rows.Next() {
+		var outcome SyncHistoryEntry
+		var errorKind string
+		if err := rows.Scan(&outcome.Success, &errorKind); err != nil {
+			return fmt.Errorf("failed to scan sync history: %w", err)
+		}
+		outcome.ErrorKind = APIErrorKind(errorKind)
+		outcomes = append(outcomes, outcome)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if !looksDelisted(outcomes, DelistingFailureThreshold) {
+		return nil
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE stocks SET delisted_at = CURRENT_TIMESTAMP, is_active = false
+		WHERE symbol = $1 AND delisted_at IS NULL
+	`, symbol)
+	if err != nil {
+		return fmt.Errorf("failed to mark %s delisted: %w", symbol, err)
+	}
+	delisted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if delisted > 0 {
+		slog.WarnContext(ctx, "Marked stock delisted after repeated sync failures", "symbol", symbol, "failures", DelistingFailureThreshold)
+	}
+	return nil
+}
+
+// List returns a page of history, newest first, with the total matching the filter
+func (s *SyncHistoryService) List(ctx context.Context, filter SyncHistoryFilter) ([]SyncHistoryEntry, int, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.Symbol != "" {
+		args = append(args, filter.Symbol)
+		conditions = append(conditions, fmt.Sprintf("symbol = $%d", len(args)))
+	}
+	if filter.Days > 0 {
+		args = append(args, filter.Days)
+		conditions = append(conditions, fmt.Sprintf("created_at >= CURRENT_TIMESTAMP - $%d * INTERVAL '1 day'", len(args)))
+	}
+	if filter.Success != nil {
+		args = append(args, *filter.Success)
+		conditions = append(conditions, fmt.Sprintf("success = $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sync_history`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count sync history: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, symbol, success, COALESCE(error_kind, ''), COALESCE(error_message, ''),
+		       records_added, duration_ms, triggered_by, created_at
+		FROM sync_history%s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+	rows, err := s.db.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list sync history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []SyncHistoryEntry{}
+	for rows.Next() {
+		var entry SyncHistoryEntry
+		var errorKind, triggeredBy string
+		if err := rows.Scan(&entry.ID, &entry.Symbol, &entry.Success, &errorKind, &entry.ErrorMessage,
+			&entry.RecordsAdded, &entry.DurationMs, &triggeredBy, &entry.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan sync history: %w", err)
+		}
+		entry.ErrorKind = APIErrorKind(errorKind)
+		entry.TriggeredBy = Initiator(triggeredBy)
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
+
+// Prune deletes history older than retention and returns how many rows went
+func (s *SyncHistoryService) Prune(ctx context.Context, retention time.Duration) (int64, error) {
+	retentionDays := int(retention / (24 * time.Hour))
+	result, err := s.db.ExecContext(ctx, `DELETE FROM sync_history WHERE created_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 day'`, retentionDays)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectSyncHistory expects one stock's outcome to be recorded, and for a
+// failure retrying will not fix, the delisting check to find it the first
+func expectSyncHistory(mock sqlmock.Sqlmock, symbol string, success bool, kind APIErrorKind, initiator Initiator) {
+	mock.ExpectExec("INSERT INTO sync_history").
+		WithArgs(symbol, success, string(kind), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), string(initiator)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	if !success && !kind.Retryable() {
+		expectDelistingCheck(mock, symbol, kind)
+	}
+}
+
+// expectDelistingCheck expects the latest outcomes of symbol to be read,
+// returning failures of the given kinds, newest first
+func expectDelistingCheck(mock sqlmock.Sqlmock, symbol string, kinds ...APIErrorKind) {
+	rows := sqlmock.NewRows([]string{"success", "error_kind"})
+	for _, kind := range kinds {
+		rows.AddRow(false, string(kind))
+	}
+	mock.ExpectQuery(`FROM sync_history\s+WHERE symbol = \$1\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$2`).
+		WithArgs(symbol, DelistingFailureThreshold).WillReturnRows(rows)
+}
+
+// stubSyncProvider serves the scheduler's sync from canned results
+type stubSyncProvider struct {
+	MarketDataProvider
+	data    *DailySeries
+	err     error
+	saveErr error
+}
+
+func (p *stubSyncProvider) CanMakeRequest() (bool, error) { return true, nil }
+
+func (p *stubSyncProvider) FetchDailyData(ctx context.Context, symbol string, initiator Initiator) (*DailySeries, error) {
+	return p.data, p.err
+}
+
+func (p *stubSyncProvider) SaveHistoricalData(ctx context.Context, symbol string, data *DailySeries, mode SyncLockMode) (SaveStats, error) {
+	return SaveStats{Inserted: len(data.Bars)}, p.saveErr
+}
+
+func expectNextStockToSync(mock sqlmock.Sqlmock, symbol string) {
+	expectConstituents(mock)
+	expectGaps(mock)
+	mock.ExpectQuery("SELECT s.symbol").WillReturnRows(sqlmock.NewRows(syncCandidateColumns).
+		AddRow(symbol, symbol+" Inc.", nil, 0, nil, nil))
+}
+
+func TestSyncStockDataJob_RecordsHistory(t *testing.T) {
+	series := &DailySeries{Symbol: "AAPL", Bars: []DailyBar{{}, {}, {}}}
+
+	tests := []struct {
+		name     string
+		provider *stubSyncProvider
+		expect   func(mock sqlmock.Sqlmock)
+	}{
+		{
+			name:     "success",
+			provider: &stubSyncProvider{data: series},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO sync_history").
+					WithArgs("AAPL", true, "", "", 3, sqlmock.AnyArg(), string(InitiatorScheduler)).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("UPDATE stocks SET updated_at").WithArgs("AAPL").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:     "rate limited",
+			provider: &stubSyncProvider{err: &APIError{Kind: APIErrorRateLimited, Symbol: "AAPL", Message: "per-minute budget exhausted"}},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectSyncHistory(mock, "AAPL", false, APIErrorRateLimited, InitiatorScheduler)
+			},
+		},
+		{
+			name:     "save failed",
+			provider: &stubSyncProvider{data: series, saveErr: errors.New("disk full")},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectSyncHistory(mock, "AAPL", false, APIErrorSaveFailed, InitiatorScheduler)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			expectNextStockToSync(mock, "AAPL")
+			tt.expect(mock)
+
+			scheduler := NewSchedulerService(db, tt.provider, nil, nil, nil)
+			scheduler.now = duringSyncWindow
+			scheduler.syncStockDataJob()
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSyncStockDataJob_SkippedSyncIsNotRecorded(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectNextStockToSync(mock, "AAPL")
+
+	provider := &stubSyncProvider{data: &DailySeries{Symbol: "AAPL"}, saveErr: ErrSyncInProgress}
+	scheduler := NewSchedulerService(db, provider, nil, nil, nil)
+	scheduler.now = duringSyncWindow
+	scheduler.syncStockDataJob()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncHistoryList_Filters(t *testing.T) {
+	succeeded := true
+	failed := false
+
+	tests := []struct {
+		name   string
+		filter SyncHistoryFilter
+		where  string
+		args   []interface{}
+	}{
+		{
+			name:   "no filter",
+			filter: SyncHistoryFilter{Limit: 50},
+			where:  "",
+		},
+		{
+			name:   "symbol",
+			filter: SyncHistoryFilter{Symbol: "AAPL", Limit: 50},
+			where:  ` WHERE symbol = \$1`,
+			args:   []interface{}{"AAPL"},
+		},
+		{
+			name:   "days and status",
+			filter: SyncHistoryFilter{Days: 7, Success: &failed, Limit: 50},
+			where:  ` WHERE created_at >= CURRENT_TIMESTAMP - \$1 \* INTERVAL '1 day' AND success = \$2`,
+			args:   []interface{}{7, false},
+		},
+		{
+			name:   "all filters",
+			filter: SyncHistoryFilter{Symbol: "AAPL", Days: 7, Success: &succeeded, Limit: 10, Offset: 20},
+			where:  ` WHERE symbol = \$1 AND created_at >= CURRENT_TIMESTAMP - \$2 \* INTERVAL '1 day' AND success = \$3`,
+			args:   []interface{}{"AAPL", 7, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			countArgs := make([]driver.Value, len(tt.args))
+			for i, arg := range tt.args {
+				countArgs[i] = arg
+			}
+			mock.ExpectQuery(`SELECT COUNT\(\*\) FROM sync_history` + tt.where + "$").WithArgs(countArgs...).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			pageArgs := append(countArgs, tt.filter.Limit, tt.filter.Offset)
+			mock.ExpectQuery("ORDER BY created_at DESC").WithArgs(pageArgs...).
+				WillReturnRows(sqlmock.NewRows([]string{
+					"id", "symbol", "success", "error_kind", "error_message",
+					"records_added", "duration_ms", "triggered_by", "created_at",
+				}).AddRow(1, "AAPL", false, "rate_limited", "per-minute budget exhausted", 0, 120, "scheduler", time.Now()))
+
+			entries, total, err := NewSyncHistoryService(db).List(context.Background(), tt.filter)
+
+			require.NoError(t, err)
+			assert.Equal(t, 1, total)
+			require.Len(t, entries, 1)
+			assert.Equal(t, APIErrorKind("rate_limited"), entries[0].ErrorKind)
+			assert.Equal(t, InitiatorScheduler, entries[0].TriggeredBy)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCleanupOldDataJob_PrunesSyncHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM api_calls").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM intraday_prices").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM sync_history").WithArgs(90).WillReturnResult(sqlmock.NewResult(0, 12))
+	mock.ExpectExec("DELETE FROM scheduler_runs").WithArgs(30).WillReturnResult(sqlmock.NewResult(0, 4))
+	expectSchedulerRun(mock, SchedulerJobCleanup, RunStatusSucceeded)
+
+	scheduler := NewSchedulerService(db, NewAlphaVantageClient("test-key", db), nil, nil, nil)
+	scheduler.cleanupOldDataJob()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, scheduler.GetRecentActivity(1).RecentErrors)
+}
+
+func TestLooksDelisted(t *testing.T) {
+	failure := func(kind APIErrorKind) SyncHistoryEntry { return SyncHistoryEntry{ErrorKind: kind} }
+	success := SyncHistoryEntry{Success: true}
+
+	tests := []struct {
+		name     string
+		outcomes []SyncHistoryEntry
+		want     bool
+	}{
+		{"too few failures", []SyncHistoryEntry{failure(APIErrorInvalidSymbol), failure(APIErrorInvalidSymbol)}, false},
+		{"invalid symbol and no data in a row", []SyncHistoryEntry{failure(APIErrorNoData), failure(APIErrorInvalidSymbol), failure(APIErrorInvalidSymbol)}, true},
+		{"success in between", []SyncHistoryEntry{failure(APIErrorInvalidSymbol), success, failure(APIErrorInvalidSymbol)}, false},
+		{"rate limit in between", []SyncHistoryEntry{failure(APIErrorInvalidSymbol), failure(APIErrorRateLimited), failure(APIErrorInvalidSymbol)}, false},
+		{"older outcomes are not looked at", []SyncHistoryEntry{failure(APIErrorNoData), failure(APIErrorNoData), failure(APIErrorNoData), success}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, looksDelisted(tt.outcomes, 3))
+		})
+	}
+}
+
+func TestRecord_MarksStockDelistedAtFailureThreshold(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	history := NewSyncHistoryService(db)
+	failure := StockSyncResult{Symbol: "TWTR", ErrorKind: APIErrorInvalidSymbol, ErrorMessage: "Invalid API call."}
+
+	// Each failure in a row adds to the outcomes the check reads back
+	var recorded []APIErrorKind
+	for i := 1; i <= DelistingFailureThreshold; i++ {
+		recorded = append(recorded, APIErrorInvalidSymbol)
+		mock.ExpectExec("INSERT INTO sync_history").WillReturnResult(sqlmock.NewResult(int64(i), 1))
+		expectDelistingCheck(mock, "TWTR", recorded...)
+	}
+	mock.ExpectExec(`UPDATE stocks SET delisted_at = CURRENT_TIMESTAMP, is_active = false\s+WHERE symbol = \$1 AND delisted_at IS NULL`).
+		WithArgs("TWTR").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	for i := 0; i < DelistingFailureThreshold; i++ {
+		require.NoError(t, history.Record(context.Background(), InitiatorScheduler, failure))
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecord_RetryableFailureSkipsDelistingCheck(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectSyncHistory(mock, "AAPL", false, APIErrorRequestFailed, InitiatorScheduler)
+
+	err = NewSyncHistoryService(db).Record(context.Background(), InitiatorScheduler,
+		StockSyncResult{Symbol: "AAPL", ErrorKind: APIErrorRequestFailed, ErrorMessage: "timeout"})
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
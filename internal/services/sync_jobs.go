@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"stock-intelligence-backend/internal/jobs"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrSyncJobNotFound       = errors.New("sync job not found")
+	ErrSyncJobFinished       = errors.New("sync job has already finished")
+	ErrSyncJobNotCancellable = errors.New("sync job is not queued or running on this server")
+	ErrBatchSyncActive       = errors.New("a batch sync is already queued or running")
+)
+
+// BatchSyncActiveError refuses a batch sync while another one, JobID, is unfinished
+type BatchSyncActiveError struct {
+	JobID int64
+}
+
+func (e *BatchSyncActiveError) Error() string {
+	return fmt.Sprintf("batch sync %d is already queued or running", e.JobID)
+}
+
+// Is lets callers use errors.Is(err, ErrBatchSyncActive)
+func (e *BatchSyncActiveError) Is(target error) bool {
+	return target == ErrBatchSyncActive
+}
+
+// SyncJob is the progress of a batch sync as stored in sync_jobs. Its status
+// follows the job queue's; JobID is the queue job running it.
+type SyncJob struct {
+	ID         int64      `json:"id"`
+	JobID      *int64     `json:"job_id,omitempty"`
+	Status     string     `json:"status"`
+	MaxStocks  int        `json:"max_stocks"`
+	Attempted  int        `json:"attempted"`
+	Successful int        `json:"successful"`
+	Failed     int        `json:"failed"`
+	Message    string     `json:"message,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// GetSyncJob returns a batch sync's progress
+func (h *HistoricalDataSyncService) GetSyncJob(ctx context.Context, id int64) (*SyncJob, error) {
+	var job SyncJob
+	var jobID sql.NullInt64
+	var startedAt, finishedAt sql.NullTime
+
+	err := h.db.QueryRowContext(ctx, `
+		SELECT id, job_id, status, max_stocks, attempted, successful, failed,
+		       COALESCE(message, ''), created_at, started_at, finished_at
+		FROM sync_jobs
+		WHERE id = $1
+	`, id).Scan(&job.ID, &jobID, &job.Status, &job.MaxStocks, &job.Attempted, &job.Successful,
+		&job.Failed, &job.Message, &job.CreatedAt, &startedAt, &finishedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSyncJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync job %d: %w", id, err)
+	}
+
+	if jobID.Valid {
+		job.JobID = &jobID.Int64
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+// CancelSyncJob stops a batch sync. A queued batch is cancelled at once; a
+// running one finishes the stock in progress, attempts no more and then ends
+// as cancelled with its counts, so the returned job may still be running.
+func (h *HistoricalDataSyncService) CancelSyncJob(ctx context.Context, id int64) (*SyncJob, error) {
+	job, err := h.GetSyncJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.FinishedAt != nil {
+		return nil, ErrSyncJobFinished
+	}
+	if job.JobID == nil || h.jobQueue == nil {
+		return nil, ErrSyncJobNotCancellable
+	}
+
+	running, err := h.jobQueue.Cancel(*job.JobID)
+	if errors.Is(err, jobs.ErrJobNotActive) {
+		return nil, ErrSyncJobNotCancellable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !running {
+		// Its queue job never started, so nothing else will finish the sync job
+		if err := h.finishSyncJob(id, jobs.StatusCancelled, nil, "Batch sync cancelled before it started"); err != nil {
+			return nil, fmt.Errorf("failed to cancel sync job %d: %w", id, err)
+		}
+	}
+	return h.GetSyncJob(ctx, id)
+}
+
+// createSyncJob records a queued batch sync. The one-active index refuses it
+// while another is unfinished, which is reported as a BatchSyncActiveError.
+func (h *HistoricalDataSyncService) createSyncJob(ctx context.Context, maxStocks int) (*SyncJob, error) {
+	job := &SyncJob{Status: jobs.StatusQueued, MaxStocks: maxStocks}
+	err := h.db.QueryRowContext(ctx, `
+		INSERT INTO sync_jobs (max_stocks, status) VALUES ($1, $2)
+		RETURNING id, created_at
+	`, maxStocks, jobs.StatusQueued).Scan(&job.ID, &job.CreatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		var activeID int64
+		err := h.db.QueryRowContext(ctx, `
+			SELECT id FROM sync_jobs WHERE status IN ('queued', 'running') ORDER BY id LIMIT 1
+		`).Scan(&activeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the active sync job: %w", err)
+		}
+		return nil, &BatchSyncActiveError{JobID: activeID}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sync job: %w", err)
+	}
+	return job, nil
+}
+
+// setSyncJobQueueJob links a sync job to the queue job that runs it
+func (h *HistoricalDataSyncService) setSyncJobQueueJob(id, jobID int64) error {
+	_, err := h.db.Exec(`UPDATE sync_jobs SET job_id = $1 WHERE id = $2`, jobID, id)
+	return err
+}
+
+// markSyncJobRunning starts a sync job's counters afresh, as a resumed job syncs again from the start
+func (h *HistoricalDataSyncService) markSyncJobRunning(id int64) error {
+	_, err := h.db.Exec(`
+		UPDATE sync_jobs
+		SET status = $1, attempted = 0, successful = 0, failed = 0, started_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`, jobs.StatusRunning, id)
+	return err
+}
+
+// updateSyncJobProgress stores the counts of a running batch
+func (h *HistoricalDataSyncService) updateSyncJobProgress(id int64, result *SyncResult) error {
+	_, err := h.db.Exec(`
+		UPDATE sync_jobs SET attempted = $1, successful = $2, failed = $3 WHERE id = $4
+	`, result.TotalAttempted, result.Successful, result.Failed, id)
+	return err
+}
+
+// finishSyncJob records a sync job's terminal status; result is nil when the batch never ran
+func (h *HistoricalDataSyncService) finishSyncJob(id int64, status string, result *SyncResult, message string) error {
+	if result == nil {
+		result = &SyncResult{}
+	}
+	_, err := h.db.Exec(`
+		UPDATE sync_jobs
+		SET status = $1, attempted = $2, successful = $3, failed = $4,
+		    message = NULLIF($5, ''), finished_at = CURRENT_TIMESTAMP
+		WHERE id = $6
+	`, status, result.TotalAttempted, result.Successful, result.Failed, message, id)
+	return err
+}
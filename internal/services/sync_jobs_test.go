@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/jobs"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSyncJobService(t *testing.T) (*HistoricalDataSyncService, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// The queue is never started, so enqueued jobs only reach the store
+	queue := jobs.NewQueue(jobs.NewPostgresStore(db), 1)
+	return NewHistoricalDataSyncService(db, NewAlphaVantageClient("test-key", db), queue), mock
+}
+
+func TestEnqueueBatchSync_CreatesSyncJob(t *testing.T) {
+	service, mock := newSyncJobService(t)
+
+	mock.ExpectQuery("INSERT INTO sync_jobs").WithArgs(3, jobs.StatusQueued).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(5, time.Now()))
+	mock.ExpectQuery("INSERT INTO jobs").WithArgs(JobTypeBatchSync, sqlmock.AnyArg(), jobs.StatusQueued, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(9, time.Now()))
+	mock.ExpectExec("UPDATE sync_jobs SET job_id").WithArgs(int64(9), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	job, err := service.EnqueueBatchSync(context.Background(), 3)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), job.ID)
+	assert.Equal(t, jobs.StatusQueued, job.Status)
+	require.NotNil(t, job.JobID)
+	assert.Equal(t, int64(9), *job.JobID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnqueueBatchSync_RejectsWhileAnotherIsActive(t *testing.T) {
+	service, mock := newSyncJobService(t)
+
+	mock.ExpectQuery("INSERT INTO sync_jobs").WithArgs(3, jobs.StatusQueued).
+		WillReturnError(&pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"})
+	mock.ExpectQuery(`SELECT id FROM sync_jobs WHERE status IN \('queued', 'running'\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+
+	_, err := service.EnqueueBatchSync(context.Background(), 3)
+
+	assert.ErrorIs(t, err, ErrBatchSyncActive)
+	var active *BatchSyncActiveError
+	require.True(t, errors.As(err, &active))
+	assert.Equal(t, int64(4), active.JobID)
+	assert.NoError(t, mock.ExpectationsWereMet(), "no queue job may be created")
+}
+
+func TestRunBatchSyncJob_ReportsProgressAfterEachStock(t *testing.T) {
+	service, mock := newBatchSyncService(t, map[string]string{
+		"BADSYM": `{"Error Message": "Invalid API call."}`,
+		"EMPTY":  `{"Meta Data": {}, "Time Series (Daily)": {}}`,
+	})
+
+	mock.ExpectExec("UPDATE sync_jobs[\\s\\S]+attempted = 0").WithArgs(jobs.StatusRunning, int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectBatchBudget(mock)
+	expectPendingStocks(mock, "BADSYM", "EMPTY")
+	expectFailedFetch(mock)
+	expectSyncHistory(mock, "BADSYM", false, APIErrorInvalidSymbol, InitiatorBatchSync)
+	// The first stock's outcome is stored before the second is fetched
+	mock.ExpectExec("UPDATE sync_jobs SET attempted").WithArgs(1, 0, 1, int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectFailedFetch(mock)
+	expectSyncHistory(mock, "EMPTY", false, APIErrorNoData, InitiatorBatchSync)
+	mock.ExpectExec("UPDATE sync_jobs SET attempted").WithArgs(2, 0, 2, int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE sync_jobs[\\s\\S]+finished_at").
+		WithArgs(jobs.StatusSucceeded, 2, 0, 2, sqlmock.AnyArg(), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	payload, err := json.Marshal(batchSyncPayload{MaxStocks: 2, SyncJobID: 5})
+	require.NoError(t, err)
+	result, err := service.runBatchSyncJob(context.Background(), &jobs.Job{ID: 9, Type: JobTypeBatchSync, Payload: payload})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.(*SyncResult).Failed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunBatchSyncJob_CancelledAfterFirstStock(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		// Cancelled while the first fetch is in flight, which still completes
+		cancel(jobs.ErrJobCancelled)
+		fmt.Fprint(w, `{"Error Message": "Invalid API call."}`)
+	}))
+	defer server.Close()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	client := NewAlphaVantageClient("test-key", db)
+	client.baseURL = server.URL
+	service := NewHistoricalDataSyncService(db, client, nil)
+
+	mock.ExpectExec("UPDATE sync_jobs[\\s\\S]+attempted = 0").WithArgs(jobs.StatusRunning, int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectBatchBudget(mock)
+	expectPendingStocks(mock, "BADSYM", "NEXT", "LATER")
+	expectFailedFetch(mock)
+	expectSyncHistory(mock, "BADSYM", false, APIErrorInvalidSymbol, InitiatorBatchSync)
+	mock.ExpectExec("UPDATE sync_jobs SET attempted").WithArgs(1, 0, 1, int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE sync_jobs[\\s\\S]+finished_at").
+		WithArgs(jobs.StatusCancelled, 1, 0, 1, sqlmock.AnyArg(), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	payload, err := json.Marshal(batchSyncPayload{MaxStocks: 3, SyncJobID: 5})
+	require.NoError(t, err)
+	result, err := service.runBatchSyncJob(ctx, &jobs.Job{ID: 9, Type: JobTypeBatchSync, Payload: payload})
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	syncResult := result.(*SyncResult)
+	assert.True(t, syncResult.Cancelled)
+	assert.Equal(t, 1, syncResult.TotalAttempted)
+	assert.Equal(t, 2, syncResult.Skipped)
+	assert.True(t, strings.HasPrefix(syncResult.Message, "Batch sync cancelled"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCancelSyncJob_QueuedBatch(t *testing.T) {
+	service, mock := newSyncJobService(t)
+	syncJobRow := func(status string, finishedAt interface{}) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{
+			"id", "job_id", "status", "max_stocks", "attempted", "successful", "failed",
+			"message", "created_at", "started_at", "finished_at",
+		}).AddRow(5, 9, status, 3, 0, 0, 0, "", time.Now(), nil, finishedAt)
+	}
+
+	// Queue the batch; the queue is not started so it stays pending
+	mock.ExpectQuery("INSERT INTO sync_jobs").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(5, time.Now()))
+	mock.ExpectQuery("INSERT INTO jobs").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(9, time.Now()))
+	mock.ExpectExec("UPDATE sync_jobs SET job_id").WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err := service.EnqueueBatchSync(context.Background(), 3)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("FROM sync_jobs").WithArgs(int64(5)).WillReturnRows(syncJobRow(jobs.StatusQueued, nil))
+	mock.ExpectExec("UPDATE jobs").WithArgs(jobs.StatusCancelled, nil, jobs.ErrJobCancelled.Error(), int64(9)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE sync_jobs[\\s\\S]+finished_at").
+		WithArgs(jobs.StatusCancelled, 0, 0, 0, sqlmock.AnyArg(), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM sync_jobs").WithArgs(int64(5)).WillReturnRows(syncJobRow(jobs.StatusCancelled, time.Now()))
+
+	job, err := service.CancelSyncJob(context.Background(), 5)
+
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusCancelled, job.Status)
+
+	// A finished batch cannot be cancelled again
+	mock.ExpectQuery("FROM sync_jobs").WithArgs(int64(5)).WillReturnRows(syncJobRow(jobs.StatusCancelled, time.Now()))
+	_, err = service.CancelSyncJob(context.Background(), 5)
+	assert.ErrorIs(t, err, ErrSyncJobFinished)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunBatchSyncJob_FailedBatchFinishesSyncJob(t *testing.T) {
+	service, mock := newBatchSyncService(t, nil)
+
+	mock.ExpectExec("UPDATE sync_jobs[\\s\\S]+attempted = 0").WithArgs(jobs.StatusRunning, int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM api_rate_limits").WillReturnError(errors.New("connection refused"))
+	mock.ExpectExec("UPDATE sync_jobs[\\s\\S]+finished_at").
+		WithArgs(jobs.StatusFailed, 0, 0, 0, sqlmock.AnyArg(), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	payload, err := json.Marshal(batchSyncPayload{MaxStocks: 2, SyncJobID: 5})
+	require.NoError(t, err)
+	_, err = service.runBatchSyncJob(context.Background(), &jobs.Job{ID: 9, Type: JobTypeBatchSync, Payload: payload})
+
+	assert.ErrorContains(t, err, "failed to check API availability")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunBatchSyncJob_WebhookOnCompletion(t *testing.T) {
+	service, mock := newBatchSyncService(t, nil)
+	dispatcher, webhookMock, _ := newTestWebhookDispatcher(t)
+	receiver := newWebhookReceiver(t)
+	service.SetWebhookDispatcher(dispatcher)
+	expectSubscribers(webhookMock, WebhookEventBatchSyncCompleted, receiver.URL)
+
+	mock.ExpectExec("UPDATE sync_jobs[\\s\\S]+attempted = 0").WithArgs(jobs.StatusRunning, int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM api_rate_limits").WillReturnError(errors.New("connection refused"))
+	mock.ExpectExec("UPDATE sync_jobs[\\s\\S]+finished_at").
+		WithArgs(jobs.StatusFailed, 0, 0, 0, sqlmock.AnyArg(), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	now := time.Now()
+	mock.ExpectQuery("FROM sync_jobs").WithArgs(int64(5)).WillReturnRows(sqlmock.NewRows([]string{
+		"id", "job_id", "status", "max_stocks", "attempted", "successful", "failed", "message", "created_at", "started_at", "finished_at",
+	}).AddRow(5, 9, jobs.StatusFailed, 2, 0, 0, 0, "failed to check API availability", now, now, now))
+
+	payload, err := json.Marshal(batchSyncPayload{MaxStocks: 2, SyncJobID: 5})
+	require.NoError(t, err)
+	_, err = service.runBatchSyncJob(context.Background(), &jobs.Job{ID: 9, Type: JobTypeBatchSync, Payload: payload})
+	require.Error(t, err)
+	dispatcher.wait()
+
+	deliveries := receiver.deliveries()
+	require.Len(t, deliveries, 1)
+	var sent struct {
+		Event string  `json:"event"`
+		Data  SyncJob `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(deliveries[0].body, &sent))
+	assert.Equal(t, WebhookEventBatchSyncCompleted, sent.Event)
+	assert.Equal(t, int64(5), sent.Data.ID)
+	assert.Equal(t, jobs.StatusFailed, sent.Data.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
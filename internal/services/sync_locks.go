@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrSyncInProgress means another writer is already saving the symbol's prices
+var ErrSyncInProgress = errors.New("a sync for this symbol is already in progress")
+
+// DefaultSyncLockTimeout bounds how long a waiting writer queues behind another
+const DefaultSyncLockTimeout = 30 * time.Second
+
+// syncAdvisoryLockClass namespaces the Postgres advisory locks taken per stock_id
+const syncAdvisoryLockClass = 2265
+
+// syncLockPollInterval is how often a waiting writer retries the advisory lock
+const syncLockPollInterval = 100 * time.Millisecond
+
+// SyncLockMode says what a writer does when the symbol is already being saved
+type SyncLockMode int
+
+const (
+	// SyncLockWait queues behind the current writer for up to the lock timeout
+	SyncLockWait SyncLockMode = iota
+	// SyncLockSkip returns ErrSyncInProgress straight away
+	SyncLockSkip
+)
+
+// symbolLocks serialises writers of the same symbol within this process
+type symbolLocks struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+}
+
+// syncLocks is shared by every writer in the process: the scheduler, manual and batch syncs
+var syncLocks = &symbolLocks{locks: make(map[string]chan struct{})}
+
+func (l *symbolLocks) get(symbol string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock, ok := l.locks[symbol]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		l.locks[symbol] = lock
+	}
+	return lock
+}
+
+// acquire takes the symbol's lock, returning ErrSyncInProgress if it is held and
+// mode is SyncLockSkip or the timeout passes first
+func (l *symbolLocks) acquire(symbol string, mode SyncLockMode, timeout time.Duration) (release func(), err error) {
+	lock := l.get(symbol)
+	release = func() { <-lock }
+
+	select {
+	case lock <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if mode == SyncLockSkip {
+		return nil, ErrSyncInProgress
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case lock <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, ErrSyncInProgress
+	}
+}
+
+func (l *symbolLocks) held(symbol string) bool {
+	return len(l.get(symbol)) > 0
+}
+
+// SymbolSyncInProgress reports whether a writer in this process is saving symbol
+func SymbolSyncInProgress(symbol string) bool {
+	return syncLocks.held(symbol)
+}
+
+// acquireAdvisoryLock takes a session-level advisory lock on stockID so writers
+// in other processes are serialised too. The returned connection holds the lock
+// and must be used for the writes; release unlocks it and returns it to the pool.
+func acquireAdvisoryLock(db *sql.DB, stockID int, mode SyncLockMode, timeout time.Duration) (*sql.Conn, func(), error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get connection for sync lock: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var locked bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1, $2)", syncAdvisoryLockClass, stockID).Scan(&locked)
+		if err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("failed to take sync lock: %w", err)
+		}
+		if locked {
+			break
+		}
+		if mode == SyncLockSkip || time.Now().After(deadline) {
+			conn.Close()
+			return nil, nil, ErrSyncInProgress
+		}
+		time.Sleep(syncLockPollInterval)
+	}
+
+	release := func() {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1, $2)", syncAdvisoryLockClass, stockID); err != nil {
+			// A session that may still hold the lock must not go back to the pool
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return conn, release, nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/jobs"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func expectAdvisoryLock(mock sqlmock.Sqlmock, stockID int, locked bool) {
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1, \$2\)`).WithArgs(syncAdvisoryLockClass, stockID).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(locked))
+}
+
+func expectAdvisoryUnlock(mock sqlmock.Sqlmock, stockID int) {
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1, \$2\)`).WithArgs(syncAdvisoryLockClass, stockID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+// expectSaveBegins expects a save to open its transaction and look up the
+// stock's newest stored price, nil for none
+func expectSaveBegins(mock sqlmock.Sqlmock, stockID int, latest interface{}) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT MAX\(date\) FROM daily_prices WHERE stock_id = \$1`).WithArgs(stockID).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(latest))
+}
+
+// insertedRow is the RETURNING row of a price upsert that added a new day
+func insertedRow() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"inserted"}).AddRow(true)
+}
+
+func expectPriceCoverage(mock sqlmock.Sqlmock, stockID int) {
+	mock.ExpectExec(`UPDATE stocks\s+SET first_price_date`).WithArgs(stockID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// expectSave expects one complete save of a single bar, with the insert taking delay
+func expectSave(mock sqlmock.Sqlmock, symbol string, stockID int, delay time.Duration) {
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs(symbol).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(stockID))
+	expectAdvisoryLock(mock, stockID, true)
+	expectSaveBegins(mock, stockID, nil)
+	mock.ExpectQuery("INSERT INTO daily_prices").WillDelayFor(delay).WillReturnRows(insertedRow())
+	expectPriceCoverage(mock, stockID)
+	mock.ExpectCommit()
+	expectAdvisoryUnlock(mock, stockID)
+}
+
+func singleBarSeries(symbol string) *DailySeries {
+	return &DailySeries{Symbol: symbol, Bars: []DailyBar{
+		{Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Open: 187.15, High: 188.44, Low: 183.89, Close: 185.64, Volume: 82488700},
+	}}
+}
+
+func TestSaveHistoricalData_RacingWritersAreSerialised(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// In-order expectations fail if the second save's queries interleave with the first's
+	expectSave(mock, "RACE", 7, 50*time.Millisecond)
+	expectSave(mock, "RACE", 7, 0)
+
+	client := NewAlphaVantageClient("test-key", db)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.SaveHistoricalData(context.Background(), "RACE", singleBarSeries("RACE"), SyncLockWait)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.False(t, SymbolSyncInProgress("RACE"))
+}
+
+func TestSaveHistoricalData_SkipReturnsAlreadySyncing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectSave(mock, "BUSY", 8, 100*time.Millisecond)
+	client := NewAlphaVantageClient("test-key", db)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.SaveHistoricalData(context.Background(), "BUSY", singleBarSeries("BUSY"), SyncLockWait)
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return SymbolSyncInProgress("BUSY") }, time.Second, time.Millisecond)
+
+	// The second writer gives up without touching the database
+	_, err = client.SaveHistoricalData(context.Background(), "BUSY", singleBarSeries("BUSY"), SyncLockSkip)
+	assert.ErrorIs(t, err, ErrSyncInProgress)
+
+	require.NoError(t, <-done)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveHistoricalData_WaitIsBounded(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	release, err := syncLocks.acquire("SLOW", SyncLockSkip, 0)
+	require.NoError(t, err)
+	defer release()
+
+	client := NewAlphaVantageClient("test-key", db)
+	client.SetSyncLockTimeout(20 * time.Millisecond)
+
+	start := time.Now()
+	_, err = client.SaveHistoricalData(context.Background(), "SLOW", singleBarSeries("SLOW"), SyncLockWait)
+
+	assert.ErrorIs(t, err, ErrSyncInProgress)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveHistoricalData_AdvisoryLockHeldByAnotherProcess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id FROM stocks").WithArgs("REMOTE").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	expectAdvisoryLock(mock, 9, false)
+
+	client := NewAlphaVantageClient("test-key", db)
+	_, err = client.SaveHistoricalData(context.Background(), "REMOTE", singleBarSeries("REMOTE"), SyncLockSkip)
+
+	assert.ErrorIs(t, err, ErrSyncInProgress)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	// The in-process lock is released even though the advisory lock was not taken
+	assert.False(t, SymbolSyncInProgress("REMOTE"))
+}
+
+func TestTriggerManualSync_ConflictsWithRunningSync(t *testing.T) {
+	store := newFakeJobStore()
+	scheduler := NewSchedulerService(nil, nil, nil, jobs.NewQueue(store, 1), nil)
+
+	release, err := syncLocks.acquire("HELD", SyncLockSkip, 0)
+	require.NoError(t, err)
+
+	_, _, err = scheduler.TriggerManualSync(context.Background(), "HELD", SyncModeFull)
+	assert.ErrorIs(t, err, ErrSyncInProgress)
+	assert.Equal(t, 0, store.count())
+
+	release()
+	_, deduplicated, err := scheduler.TriggerManualSync(context.Background(), "HELD", SyncModeFull)
+	require.NoError(t, err)
+	assert.False(t, deduplicated)
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// syncStalenessCapDays is the staleness or total gap, in trading days, past
+// which a stock scores no higher; a stock with no prices at all scores the cap
+const syncStalenessCapDays = 20
+
+// gapBackfillRetryInterval is how often an otherwise up-to-date stock with gaps
+// is queued, so gaps the provider cannot fill do not take every run's budget
+const gapBackfillRetryInterval = 24 * time.Hour
+
+// syncMarketCapCeiling is the log10 market cap that scores in full, about $10T
+const syncMarketCapCeiling = 13.0
+
+// SyncQueueWeights weigh the parts of a stock's sync score. Each part is
+// scaled to 0-1 before weighting, so the weights compare directly.
+type SyncQueueWeights struct {
+	// Staleness weighs trading days since the last stored price
+	Staleness float64 `json:"staleness"`
+	// SP500 weighs the stock's rank in the S&P 500 priority list, zero when not on it
+	SP500 float64 `json:"sp500"`
+	// MarketCap weighs market cap on a log scale
+	MarketCap float64 `json:"market_cap"`
+	// Gaps weighs trading days missing inside the stored history
+	Gaps float64 `json:"gaps"`
+}
+
+// DefaultSyncQueueWeights let a well-known stock a few days stale go ahead of
+// an obscure one with no data, while a week or more of staleness still wins;
+// gaps count as much as staleness
+var DefaultSyncQueueWeights = SyncQueueWeights{Staleness: 1, SP500: 1, MarketCap: 0.5, Gaps: 1}
+
+// Validate rejects negative weights and weights that are all zero
+func (w SyncQueueWeights) Validate() error {
+	if w.Staleness < 0 || w.SP500 < 0 || w.MarketCap < 0 || w.Gaps < 0 {
+		return fmt.Errorf("sync queue weights must not be negative")
+	}
+	if w.Staleness == 0 && w.SP500 == 0 && w.MarketCap == 0 && w.Gaps == 0 {
+		return fmt.Errorf("at least one sync queue weight must be positive")
+	}
+	return nil
+}
+
+// SyncCandidate is a stock waiting for the scheduled sync, with its score
+type SyncCandidate struct {
+	Symbol        string     `json:"symbol"`
+	CompanyName   string     `json:"company_name"`
+	LastPriceDate *time.Time `json:"last_price_date"`
+	// DaysStale is trading days since LastPriceDate, the cap when there is none
+	DaysStale int `json:"days_stale"`
+	// GapDays is trading days missing between the oldest and newest stored prices
+	GapDays       int     `json:"gap_days"`
+	SP500Priority int     `json:"sp500_priority,omitempty"`
+	MarketCap     int64   `json:"market_cap"`
+	Score         float64 `json:"score"`
+}
+
+// SyncQueue orders active stocks by how much a scheduled sync is worth,
+// combining staleness, gaps, S&P 500 priority and market cap
+type SyncQueue struct {
+	db       *sql.DB
+	weights  SyncQueueWeights
+	sp500    *SP500PriorityService
+	quality  *DataQualityService
+	location *time.Location
+}
+
+// NewSyncQueue returns a queue with the default weights, ranking stocks by the S&P 500 list of SP500PriorityService
+func NewSyncQueue(db *sql.DB) *SyncQueue {
+	return &SyncQueue{
+		db:       db,
+		weights:  DefaultSyncQueueWeights,
+		sp500:    NewSP500PriorityService(db),
+		quality:  NewDataQualityService(db, nil),
+		location: NewMarketCalendar().location,
+	}
+}
+
+// SetWeights replaces the weights, keeping the old ones if the new are invalid
+func (q *SyncQueue) SetWeights(weights SyncQueueWeights) error {
+	if err := weights.Validate(); err != nil {
+		return err
+	}
+	q.weights = weights
+	return nil
+}
+
+// Weights returns the weights in use
+func (q *SyncQueue) Weights() SyncQueueWeights {
+	return q.weights
+}
+
+// Next returns up to limit stocks, highest score first, that are missing at
+// least one trading day of prices as of now. An up-to-date stock with gaps is
+// included when it has not been synced for gapBackfillRetryInterval.
+func (q *SyncQueue) Next(ctx context.Context, now time.Time, limit int) ([]SyncCandidate, error) {
+	priorities, err := q.sp500.priorities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	maxPriority := 0
+	for _, priority := range priorities {
+		maxPriority = max(maxPriority, priority)
+	}
+
+	gapDays, err := q.quality.missingDays(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT s.symbol, COALESCE(s.company_name, ''), s.last_price_date, COALESCE(s.market_cap, 0), s.updated_at,
+		       s.quote_price_date
+		FROM stocks s
+		WHERE s.is_active = true AND s.delisted_at IS NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Dates are compared on the Eastern calendar, so a run late in the evening is still that trading day
+	today := now.In(q.location)
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+
+	candidates := []SyncCandidate{}
+	for rows.Next() {
+		var candidate SyncCandidate
+		var lastPriceDate, syncedAt, quotePriceDate sql.NullTime
+		if err := rows.Scan(&candidate.Symbol, &candidate.CompanyName, &lastPriceDate, &candidate.MarketCap, &syncedAt, &quotePriceDate); err != nil {
+			return nil, fmt.Errorf("failed to scan sync candidate: %w", err)
+		}
+
+		candidate.GapDays = gapDays[candidate.Symbol]
+		candidate.DaysStale = syncStalenessCapDays
+		if lastPriceDate.Valid {
+			candidate.LastPriceDate = &lastPriceDate.Time
+			candidate.DaysStale = tradingDaysSince(lastPriceDate.Time, today)
+			// A day written from an intra-day quote still needs its final prices
+			if candidate.DaysStale == 0 && quotePriceDate.Valid {
+				candidate.DaysStale = 1
+			}
+			recentlySynced := syncedAt.Valid && now.Sub(syncedAt.Time) < gapBackfillRetryInterval
+			if candidate.DaysStale == 0 && (candidate.GapDays == 0 || recentlySynced) {
+				continue // Up to date
+			}
+		}
+		candidate.SP500Priority = priorities[candidate.Symbol]
+		candidate.Score = q.score(candidate, maxPriority)
+		candidates = append(candidates, candidate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Symbol < candidates[j].Symbol
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+// score weighs a candidate's staleness, gaps, S&P 500 rank and market cap, each scaled to 0-1
+func (q *SyncQueue) score(candidate SyncCandidate, maxPriority int) float64 {
+	staleness := float64(min(candidate.DaysStale, syncStalenessCapDays)) / syncStalenessCapDays
+	gaps := float64(min(candidate.GapDays, syncStalenessCapDays)) / syncStalenessCapDays
+
+	var rank float64
+	if candidate.SP500Priority > 0 && maxPriority > 0 {
+		rank = 1 - float64(candidate.SP500Priority-1)/float64(maxPriority)
+	}
+
+	var size float64
+	if candidate.MarketCap > 1 {
+		size = math.Min(math.Log10(float64(candidate.MarketCap))/syncMarketCapCeiling, 1)
+	}
+
+	score := q.weights.Staleness*staleness + q.weights.Gaps*gaps + q.weights.SP500*rank + q.weights.MarketCap*size
+	// Rounded so the API shows stable values and ties compare equal
+	return math.Round(score*10000) / 10000
+}
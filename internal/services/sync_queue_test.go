@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var syncCandidateColumns = []string{"symbol", "company_name", "last_price_date", "market_cap", "updated_at", "quote_price_date"}
+
+// expectSyncCandidates expects the sync queue query to return AAPL two trading
+// days stale, MSFT up to date, an obscure stock with no prices and a mid-sized
+// one not on the S&P 500 list twenty trading days stale
+func expectSyncCandidates(mock sqlmock.Sqlmock) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+	expectConstituents(mock)
+	expectGaps(mock)
+	mock.ExpectQuery("SELECT s.symbol").WillReturnRows(sqlmock.NewRows(syncCandidateColumns).
+		AddRow("AAPL", "Apple Inc.", day(time.March, 6), int64(3000000000000), day(time.March, 7), nil).
+		AddRow("MSFT", "Microsoft Corporation", day(time.March, 10), int64(2800000000000), day(time.March, 10), nil).
+		AddRow("TINY", "Tiny Holdings", nil, int64(50000000), nil, nil).
+		AddRow("OLDCO", "Old Company", day(time.February, 10), int64(2000000000), day(time.February, 10), nil))
+}
+
+func TestSyncQueue_WeightsDecideBetweenStalenessAndPriority(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights SyncQueueWeights
+		want    []string
+	}{
+		{name: "default", weights: DefaultSyncQueueWeights, want: []string{"AAPL", "OLDCO", "TINY"}},
+		{name: "staleness first", weights: SyncQueueWeights{Staleness: 5, SP500: 1, MarketCap: 0.5}, want: []string{"OLDCO", "TINY", "AAPL"}},
+		{name: "market cap only", weights: SyncQueueWeights{MarketCap: 1}, want: []string{"AAPL", "OLDCO", "TINY"}},
+		{name: "staleness only", weights: SyncQueueWeights{Staleness: 1}, want: []string{"OLDCO", "TINY", "AAPL"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			expectSyncCandidates(mock)
+
+			queue := NewSyncQueue(db)
+			require.NoError(t, queue.SetWeights(tt.weights))
+			candidates, err := queue.Next(context.Background(), duringSyncWindow(), 10)
+			require.NoError(t, err)
+
+			symbols := make([]string, len(candidates))
+			for i, candidate := range candidates {
+				symbols[i] = candidate.Symbol
+			}
+			assert.Equal(t, tt.want, symbols, "MSFT is up to date and never queued")
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSyncQueue_Candidate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectSyncCandidates(mock)
+
+	candidates, err := NewSyncQueue(db).Next(context.Background(), duringSyncWindow(), 2)
+	require.NoError(t, err)
+	require.Len(t, candidates, 2)
+
+	aapl := candidates[0]
+	assert.Equal(t, "AAPL", aapl.Symbol)
+	assert.Equal(t, 2, aapl.DaysStale)
+	assert.Equal(t, 1, aapl.SP500Priority)
+	assert.InDelta(t, 1.58, aapl.Score, 0.01)
+
+	oldco := candidates[1]
+	assert.Equal(t, 20, oldco.DaysStale)
+	assert.Zero(t, oldco.SP500Priority)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncQueue_LateEveningIsStillTheTradingDay(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// 23:00 on Tuesday in New York is already Wednesday in UTC
+	expectConstituents(mock)
+	expectGaps(mock)
+	mock.ExpectQuery("SELECT s.symbol").WillReturnRows(sqlmock.NewRows(syncCandidateColumns).
+		AddRow("AAPL", "Apple Inc.", time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC), int64(3000000000000), nil, nil))
+
+	candidates, err := NewSyncQueue(db).Next(context.Background(), time.Date(2026, time.March, 11, 3, 0, 0, 0, time.UTC), 10)
+	require.NoError(t, err)
+	assert.Empty(t, candidates)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncQueue_QuotedDayIsSyncedAgainAfterTheClose(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	today := time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC)
+	expectConstituents(mock)
+	expectGaps(mock)
+	mock.ExpectQuery("SELECT s.symbol").WillReturnRows(sqlmock.NewRows(syncCandidateColumns).
+		AddRow("AAPL", "Apple Inc.", today, int64(3000000000000), nil, today).
+		AddRow("MSFT", "Microsoft Corporation", today, int64(2800000000000), nil, nil))
+
+	candidates, err := NewSyncQueue(db).Next(context.Background(), duringSyncWindow(), 10)
+	require.NoError(t, err)
+	require.Len(t, candidates, 1, "MSFT's day came from a full sync")
+	assert.Equal(t, "AAPL", candidates[0].Symbol)
+	assert.Equal(t, 1, candidates[0].DaysStale)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncQueue_BackfillsGaps(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := duringSyncWindow()
+	upToDate := time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC)
+	expectConstituents(mock)
+	// AAPL and MSFT are missing the week of March 2; NVDA has no gaps
+	expectGaps(mock,
+		storedGap{"AAPL", date2026(time.February, 27), date2026(time.March, 9)},
+		storedGap{"MSFT", date2026(time.February, 27), date2026(time.March, 9)})
+	mock.ExpectQuery("SELECT s.symbol").WillReturnRows(sqlmock.NewRows(syncCandidateColumns).
+		AddRow("AAPL", "Apple Inc.", upToDate, int64(3000000000000), now.Add(-48*time.Hour), nil).
+		AddRow("MSFT", "Microsoft Corporation", upToDate, int64(2800000000000), now.Add(-time.Hour), nil).
+		AddRow("NVDA", "NVIDIA Corporation", upToDate, int64(2500000000000), now.Add(-48*time.Hour), nil))
+
+	candidates, err := NewSyncQueue(db).Next(context.Background(), now, 10)
+	require.NoError(t, err)
+	require.Len(t, candidates, 1, "MSFT was synced within the retry interval and NVDA has nothing missing")
+	assert.Equal(t, "AAPL", candidates[0].Symbol)
+	assert.Equal(t, 5, candidates[0].GapDays)
+	assert.Zero(t, candidates[0].DaysStale)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncQueueWeights_Validate(t *testing.T) {
+	assert.NoError(t, DefaultSyncQueueWeights.Validate())
+	assert.Error(t, SyncQueueWeights{Staleness: -1, SP500: 1}.Validate())
+	assert.Error(t, SyncQueueWeights{}.Validate())
+
+	queue := NewSyncQueue(nil)
+	assert.Error(t, queue.SetWeights(SyncQueueWeights{}))
+	assert.Equal(t, DefaultSyncQueueWeights, queue.Weights())
+}
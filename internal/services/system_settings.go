@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SettingSchedulerPaused holds "true" while the scheduler is paused
+const SettingSchedulerPaused = "scheduler_paused"
+
+// SystemSettingsService stores runtime switches that must survive restarts
+type SystemSettingsService struct {
+	db *sql.DB
+}
+
+func NewSystemSettingsService(db *sql.DB) *SystemSettingsService {
+	return &SystemSettingsService{db: db}
+}
+
+// Get returns the value of key, and false when it has never been set
+func (s *SystemSettingsService) Get(ctx context.Context, key string) (string, bool, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, `SELECT value FROM system_settings WHERE key = $1`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get setting %s: %w", key, err)
+	}
+	return value, true, nil
+}
+
+// Set stores value under key
+func (s *SystemSettingsService) Set(ctx context.Context, key, value string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO system_settings (key, value, updated_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = CURRENT_TIMESTAMP
+	`, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to save setting %s: %w", key, err)
+	}
+	return nil
+}
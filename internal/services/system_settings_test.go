@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func expectPausedSaved(mock sqlmock.Sqlmock, value string) {
+	mock.ExpectExec("INSERT INTO system_settings").WithArgs(SettingSchedulerPaused, value).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestPause_SkipsSyncUntilResumed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	provider := &budgetedSyncProvider{allowed: 25}
+	scheduler := NewSchedulerService(db, provider, nil, nil, nil)
+	scheduler.SetSyncBatchPerHour(1)
+	scheduler.now = duringSyncWindow
+
+	// Paused: the job fires but picks no stock and spends no call
+	expectPausedSaved(mock, "true")
+	mock.ExpectExec("INSERT INTO scheduler_runs").
+		WithArgs(SchedulerJobSync, sqlmock.AnyArg(), sqlmock.AnyArg(), RunStatusSkipped, "Scheduler paused", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	require.NoError(t, scheduler.Pause(context.Background()))
+	scheduler.syncStockDataJob()
+	assert.Zero(t, provider.fetches)
+	assert.True(t, scheduler.paused.Load())
+
+	// Resumed: the next firing syncs as usual
+	expectPausedSaved(mock, "false")
+	expectScheduledSync(mock, "AAPL")
+	expectSchedulerRun(mock, SchedulerJobSync, RunStatusSucceeded)
+
+	require.NoError(t, scheduler.Resume(context.Background()))
+	scheduler.syncStockDataJob()
+	assert.Equal(t, 1, provider.fetches)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPause_SkipsMaintenanceJobs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	scheduler := NewSchedulerService(db, nil, nil, nil, nil)
+	scheduler.paused.Store(true)
+
+	expectSchedulerRun(mock, SchedulerJobCleanup, RunStatusSkipped)
+	expectSchedulerRun(mock, SchedulerJobRateLimitReset, RunStatusSkipped)
+
+	scheduler.cleanupOldDataJob()
+	scheduler.resetRateLimitsJob()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStart_RestoresPausedState(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT value FROM system_settings").WithArgs(SettingSchedulerPaused).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("true"))
+
+	scheduler := NewSchedulerService(db, nil, nil, nil, nil)
+	require.NoError(t, scheduler.Start())
+	defer scheduler.Stop()
+
+	assert.True(t, scheduler.paused.Load())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStart_UnpausedWithoutSetting(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT value FROM system_settings").WithArgs(SettingSchedulerPaused).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}))
+
+	scheduler := NewSchedulerService(db, nil, nil, nil, nil)
+	require.NoError(t, scheduler.Start())
+	defer scheduler.Stop()
+
+	assert.False(t, scheduler.paused.Load())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
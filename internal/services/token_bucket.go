@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultTokenBucketMaxWait is how long a request may wait for a token before
+// it is refused, enough to ride out a full minute's refill
+const DefaultTokenBucketMaxWait = time.Minute
+
+// TokenBucket paces requests to a per-minute allowance in process. It holds up
+// to one minute's calls as tokens, refilled one per interval (12s at 5 calls
+// per minute); each request takes a token, waiting for the next refill when
+// none are left. It is safe for concurrent use: a waiting caller reserves its
+// token before sleeping, so overlapping callers are served in turn rather than
+// all waking for the same token.
+type TokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	interval time.Duration
+	maxWait  time.Duration
+	tokens   float64
+	last     time.Time
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewTokenBucket creates a full bucket for callsPerMinute, falling back to
+// DefaultCallsPerMinute when it is not positive
+func NewTokenBucket(callsPerMinute int) *TokenBucket {
+	b := &TokenBucket{
+		maxWait: DefaultTokenBucketMaxWait,
+		now:     time.Now,
+		sleep:   sleepContext,
+	}
+	b.SetRate(callsPerMinute)
+	b.tokens = b.capacity
+	b.last = b.now()
+	return b
+}
+
+// SetRate changes the allowance; tokens already in the bucket are kept up to the new capacity
+func (b *TokenBucket) SetRate(callsPerMinute int) {
+	if callsPerMinute <= 0 {
+		callsPerMinute = DefaultCallsPerMinute
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.capacity = float64(callsPerMinute)
+	b.interval = time.Minute / time.Duration(callsPerMinute)
+	b.tokens = min(b.tokens, b.capacity)
+}
+
+// SetMaxWait sets how long Wait may block for a token
+func (b *TokenBucket) SetMaxWait(maxWait time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.maxWait = maxWait
+}
+
+// Wait takes a token, blocking until one is available. When the token is
+// further off than the max wait it returns ErrRateLimited at once instead, and
+// when ctx ends first it returns ctx's error; neither spends a token.
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	b.mu.Lock()
+	b.refill()
+	b.tokens--
+	if b.tokens >= 0 {
+		b.mu.Unlock()
+		return nil
+	}
+
+	wait := time.Duration(-b.tokens * float64(b.interval))
+	if wait > b.maxWait {
+		b.tokens++
+		b.mu.Unlock()
+		return ErrRateLimited
+	}
+	b.mu.Unlock()
+
+	if err := b.sleep(ctx, wait); err != nil {
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// refill adds the tokens earned since the last refill; the caller holds mu
+func (b *TokenBucket) refill() {
+	now := b.now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+float64(elapsed)/float64(b.interval))
+		b.last = now
+	}
+}
+
+// sleepContext sleeps for d or until ctx ends, returning ctx's error in that case
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock stands in for the bucket's clock; sleeping moves it forward
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.sleeps = append(c.sleeps, d)
+	return nil
+}
+
+func newFakeClockBucket(callsPerMinute int) (*TokenBucket, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)}
+	bucket := NewTokenBucket(callsPerMinute)
+	bucket.now = clock.Now
+	bucket.sleep = clock.Sleep
+	bucket.last = clock.Now()
+	return bucket, clock
+}
+
+func TestTokenBucket_PacesRapidCalls(t *testing.T) {
+	bucket, clock := newFakeClockBucket(5)
+	start := clock.Now()
+
+	for i := 0; i < 6; i++ {
+		require.NoError(t, bucket.Wait(context.Background()))
+	}
+
+	// Five calls drain the bucket, the sixth waits for a refill
+	assert.GreaterOrEqual(t, clock.Now().Sub(start), 12*time.Second)
+	assert.Equal(t, []time.Duration{12 * time.Second}, clock.sleeps)
+}
+
+func TestTokenBucket_Refills(t *testing.T) {
+	bucket, clock := newFakeClockBucket(5)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, bucket.Wait(context.Background()))
+	}
+
+	// A minute later the bucket is full again, never more
+	clock.now = clock.now.Add(5 * time.Minute)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, bucket.Wait(context.Background()))
+	}
+	assert.Empty(t, clock.sleeps)
+
+	require.NoError(t, bucket.Wait(context.Background()))
+	assert.Equal(t, []time.Duration{12 * time.Second}, clock.sleeps)
+}
+
+func TestTokenBucket_ConcurrentCallersTakeTurns(t *testing.T) {
+	bucket, clock := newFakeClockBucket(5)
+	// Sleeping callers do not move the clock, so each waits from the same instant
+	bucket.sleep = func(ctx context.Context, d time.Duration) error {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		clock.sleeps = append(clock.sleeps, d)
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, bucket.Wait(context.Background()))
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(clock.sleeps, func(i, j int) bool { return clock.sleeps[i] < clock.sleeps[j] })
+	assert.Equal(t, []time.Duration{12 * time.Second, 24 * time.Second, 36 * time.Second}, clock.sleeps)
+}
+
+func TestTokenBucket_RefusesBeyondMaxWait(t *testing.T) {
+	bucket, clock := newFakeClockBucket(5)
+	bucket.SetMaxWait(10 * time.Second)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, bucket.Wait(context.Background()))
+	}
+
+	assert.ErrorIs(t, bucket.Wait(context.Background()), ErrRateLimited)
+	assert.Empty(t, clock.sleeps)
+
+	// The refused call spent nothing: the next token is still one interval away
+	bucket.SetMaxWait(DefaultTokenBucketMaxWait)
+	require.NoError(t, bucket.Wait(context.Background()))
+	assert.Equal(t, []time.Duration{12 * time.Second}, clock.sleeps)
+}
+
+func TestTokenBucket_CancelledWaitReturnsToken(t *testing.T) {
+	bucket, clock := newFakeClockBucket(5)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, bucket.Wait(context.Background()))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, bucket.Wait(ctx), context.Canceled)
+
+	require.NoError(t, bucket.Wait(context.Background()))
+	assert.Equal(t, []time.Duration{12 * time.Second}, clock.sleeps)
+}
+
+func TestFetchDailyData_RefusedByPerMinuteLimit(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, dailySeriesFixture)
+	}))
+	defer server.Close()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	client := NewAlphaVantageClient("test-key", db)
+	client.baseURL = server.URL
+	client.SetCallsPerMinute(1)
+	client.pacer.SetMaxWait(0)
+
+	expectRateLimitQuery(mock)
+	mock.ExpectExec("INSERT INTO api_calls").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRequestRecorded(mock, ProviderAlphaVantage)
+	_, err = client.FetchDailyData(context.Background(), "AAPL", InitiatorManualSync)
+	require.NoError(t, err)
+
+	// The second call within the minute never reaches the provider
+	expectRateLimitQuery(mock)
+	_, err = client.FetchDailyData(context.Background(), "MSFT", InitiatorManualSync)
+
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.ErrorContains(t, err, "MSFT")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+var (
+	ErrWatchlistNotFound = errors.New("watchlist not found")
+	ErrUnknownSymbol     = errors.New("symbol is not a known stock")
+)
+
+// WatchlistService manages watchlists. It reads from the primary, since
+// clients read a watchlist straight after changing it.
+type WatchlistService struct {
+	db *sql.DB
+}
+
+func NewWatchlistService(db *sql.DB) *WatchlistService {
+	return &WatchlistService{db: db}
+}
+
+// CreateWatchlist creates an empty watchlist
+func (w *WatchlistService) CreateWatchlist(name string) (*models.Watchlist, error) {
+	watchlist := &models.Watchlist{Name: name, Items: []models.WatchlistItem{}}
+	err := w.db.QueryRow(`
+		INSERT INTO watchlists (name) VALUES ($1)
+		RETURNING id, created_at, updated_at
+	`, name).Scan(&watchlist.ID, &watchlist.CreatedAt, &watchlist.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watchlist: %w", err)
+	}
+	return watchlist, nil
+}
+
+// GetWatchlist returns a watchlist with each stock's current price and daily change
+func (w *WatchlistService) GetWatchlist(id int64) (*models.Watchlist, error) {
+	watchlist := &models.Watchlist{ID: id, Items: []models.WatchlistItem{}}
+	err := w.db.QueryRow(`
+		SELECT name, created_at, updated_at FROM watchlists WHERE id = $1
+	`, id).Scan(&watchlist.Name, &watchlist.CreatedAt, &watchlist.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWatchlistNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watchlist: %w", err)
+	}
+
+	query := `
+		SELECT s.symbol, s.company_name,
+		       COALESCE(latest.close_price, 0) as current_price,
+		       COALESCE(
+		           CASE WHEN previous.close_price > 0 THEN
+		               ((latest.close_price - previous.close_price) / previous.close_price * 100)
+		           ELSE 0 END, 0
+		       ) as change_percent,
+		       wi.added_at
+		FROM watchlist_items wi
+		JOIN stocks s ON s.id = wi.stock_id` + latestPriceJoins + `
+		WHERE wi.watchlist_id = $1
+		ORDER BY wi.added_at, s.symbol
+	`
+
+	rows, err := w.db.Query(query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watchlist items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.WatchlistItem
+		if err := rows.Scan(&item.Symbol, &item.CompanyName, &item.CurrentPrice, &item.ChangePercent, &item.AddedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan watchlist item: %w", err)
+		}
+		item.CurrentPrice = RoundPrice(item.CurrentPrice)
+		item.ChangePercent = RoundPercent(item.ChangePercent)
+		watchlist.Items = append(watchlist.Items, item)
+	}
+	return watchlist, rows.Err()
+}
+
+// AddSymbol adds a stock to a watchlist; adding a stock already on it is a no-op
+func (w *WatchlistService) AddSymbol(id int64, symbol string) error {
+	if err := w.ensureExists(id); err != nil {
+		return err
+	}
+
+	var stockID int
+	err := w.db.QueryRow("SELECT id FROM stocks WHERE symbol = $1", symbol).Scan(&stockID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUnknownSymbol
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up stock: %w", err)
+	}
+
+	_, err = w.db.Exec(`
+		INSERT INTO watchlist_items (watchlist_id, stock_id) VALUES ($1, $2)
+		ON CONFLICT (watchlist_id, stock_id) DO NOTHING
+	`, id, stockID)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to watchlist: %w", symbol, err)
+	}
+	return nil
+}
+
+// RemoveSymbol removes a stock from a watchlist; removing one that is not on it is a no-op
+func (w *WatchlistService) RemoveSymbol(id int64, symbol string) error {
+	if err := w.ensureExists(id); err != nil {
+		return err
+	}
+
+	_, err := w.db.Exec(`
+		DELETE FROM watchlist_items wi
+		USING stocks s
+		WHERE wi.stock_id = s.id AND wi.watchlist_id = $1 AND s.symbol = $2
+	`, id, symbol)
+	if err != nil {
+		return fmt.Errorf("failed to remove %s from watchlist: %w", symbol, err)
+	}
+	return nil
+}
+
+func (w *WatchlistService) ensureExists(id int64) error {
+	var exists bool
+	err := w.db.QueryRow("SELECT EXISTS(SELECT 1 FROM watchlists WHERE id = $1)", id).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to get watchlist: %w", err)
+	}
+	if !exists {
+		return ErrWatchlistNotFound
+	}
+	return nil
+}
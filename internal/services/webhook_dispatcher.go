@@ -0,0 +1,214 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Headers sent with every webhook delivery
+const (
+	// WebhookSignatureHeader is "sha256=" and the hex HMAC-SHA256 of the body keyed with the webhook's secret
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookEventHeader     = "X-Webhook-Event"
+	// WebhookDeliveryHeader is the payload's ID, the same on every attempt
+	WebhookDeliveryHeader = "X-Webhook-Delivery"
+)
+
+// DefaultWebhookMaxAttempts is how many times a delivery is tried before it is given up
+const DefaultWebhookMaxAttempts = 4
+
+// Default backoff between delivery attempts; the delay doubles each retry up to the max
+const (
+	DefaultWebhookRetryBaseDelay = 2 * time.Second
+	DefaultWebhookRetryMaxDelay  = 1 * time.Minute
+)
+
+// webhookTimeout bounds each delivery attempt, so a receiver that hangs
+// cannot hold up the ones after it
+const webhookTimeout = 10 * time.Second
+
+// WebhookPayload is the JSON body POSTed to a webhook
+type WebhookPayload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookDelivery is the outcome of delivering one payload to one webhook
+type WebhookDelivery struct {
+	WebhookID  int64  `json:"webhook_id"`
+	Event      string `json:"event"`
+	PayloadID  string `json:"payload_id"`
+	Delivered  bool   `json:"delivered"`
+	Attempts   int    `json:"attempts"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// WebhookDispatcher POSTs signed event payloads to the webhooks subscribed to
+// them, retrying with exponential backoff until a receiver answers 2xx
+type WebhookDispatcher struct {
+	webhooks       *WebhookService
+	client         *http.Client
+	maxAttempts    int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+	sleep          func(ctx context.Context, d time.Duration) error
+	now            func() time.Time
+	logger         *slog.Logger
+	// inFlight tracks dispatches still delivering
+	inFlight sync.WaitGroup
+}
+
+func NewWebhookDispatcher(webhooks *WebhookService) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		webhooks:       webhooks,
+		client:         &http.Client{Timeout: webhookTimeout},
+		maxAttempts:    DefaultWebhookMaxAttempts,
+		retryBaseDelay: DefaultWebhookRetryBaseDelay,
+		retryMaxDelay:  DefaultWebhookRetryMaxDelay,
+		sleep:          sleepContext,
+		now:            time.Now,
+		logger:         slog.Default(),
+	}
+}
+
+// SetLogger sets the logger for failed deliveries
+func (d *WebhookDispatcher) SetLogger(logger *slog.Logger) {
+	d.logger = logger
+}
+
+// SetRetryPolicy sets how many times a delivery is tried; zero delays keep their defaults
+func (d *WebhookDispatcher) SetRetryPolicy(maxAttempts int, baseDelay, maxDelay time.Duration) {
+	if maxAttempts > 0 {
+		d.maxAttempts = maxAttempts
+	}
+	if baseDelay > 0 {
+		d.retryBaseDelay = baseDelay
+	}
+	if maxDelay > 0 {
+		d.retryMaxDelay = maxDelay
+	}
+}
+
+// Dispatch sends event to every active webhook subscribed to it, in the
+// background so the job that raised it is not held up by slow receivers
+func (d *WebhookDispatcher) Dispatch(event string, data interface{}) {
+	d.inFlight.Add(1)
+	go func() {
+		defer d.inFlight.Done()
+		ctx := context.Background()
+
+		webhooks, err := d.webhooks.subscribers(ctx, event)
+		if err != nil {
+			d.logger.Warn("Failed to load webhooks", "event", event, "error", err)
+			return
+		}
+		if len(webhooks) == 0 {
+			return
+		}
+		payload := d.newPayload(event, data)
+		for _, webhook := range webhooks {
+			delivery := d.deliver(ctx, webhook, payload, d.maxAttempts)
+			if !delivery.Delivered {
+				d.logger.Warn("Webhook delivery failed", "webhook_id", webhook.ID, "event", event,
+					"attempts", delivery.Attempts, "status_code", delivery.StatusCode, "error", delivery.Error)
+			}
+		}
+	}()
+}
+
+// Test sends a webhook.test event to a webhook, active or not, once, and
+// returns the outcome
+func (d *WebhookDispatcher) Test(ctx context.Context, id int64) (*WebhookDelivery, error) {
+	webhook, err := d.webhooks.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	payload := d.newPayload(WebhookEventTest, map[string]interface{}{"webhook_id": webhook.ID})
+	return d.deliver(ctx, *webhook, payload, 1), nil
+}
+
+// wait blocks until every dispatch so far has finished delivering
+func (d *WebhookDispatcher) wait() {
+	d.inFlight.Wait()
+}
+
+func (d *WebhookDispatcher) newPayload(event string, data interface{}) WebhookPayload {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return WebhookPayload{ID: hex.EncodeToString(id), Event: event, CreatedAt: d.now().UTC(), Data: data}
+}
+
+// deliver POSTs payload to webhook up to maxAttempts times, backing off after
+// each network error or non-2xx answer
+func (d *WebhookDispatcher) deliver(ctx context.Context, webhook Webhook, payload WebhookPayload, maxAttempts int) *WebhookDelivery {
+	delivery := &WebhookDelivery{WebhookID: webhook.ID, Event: payload.Event, PayloadID: payload.ID}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		delivery.Error = fmt.Sprintf("failed to encode payload: %v", err)
+		return delivery
+	}
+	signature := SignWebhookPayload(webhook.Secret, body)
+
+	for attempt := 1; ; attempt++ {
+		delivery.Attempts = attempt
+		delivery.StatusCode, err = d.post(ctx, webhook.URL, payload, body, signature)
+		if err == nil {
+			delivery.Delivered, delivery.Error = true, ""
+			return delivery
+		}
+		delivery.Error = err.Error()
+		if attempt >= maxAttempts {
+			return delivery
+		}
+		if err := d.sleep(ctx, retryDelay(attempt, d.retryBaseDelay, d.retryMaxDelay)); err != nil {
+			return delivery
+		}
+	}
+}
+
+// post makes one delivery attempt; a non-2xx answer is an error
+func (d *WebhookDispatcher) post(ctx context.Context, url string, payload WebhookPayload, body []byte, signature string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, signature)
+	req.Header.Set(WebhookEventHeader, payload.Event)
+	req.Header.Set(WebhookDeliveryHeader, payload.ID)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drained so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook answered %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload returns the signature header value for body: "sha256="
+// and the hex HMAC-SHA256 of body keyed with secret
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testWebhookSecret = "0123456789abcdef0123456789abcdef"
+
+// webhookReceiver is an httptest server answering each delivery with the next
+// of its statuses, then 200, and keeping what it was sent
+type webhookReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	received []receivedWebhook
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	receiver := &webhookReceiver{statuses: statuses}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		receiver.received = append(receiver.received, receivedWebhook{header: r.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(receiver.statuses) > 0 {
+			status, receiver.statuses = receiver.statuses[0], receiver.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) deliveries() []receivedWebhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedWebhook(nil), r.received...)
+}
+
+// newTestWebhookDispatcher returns a dispatcher that records its backoff
+// delays instead of sleeping
+func newTestWebhookDispatcher(t *testing.T) (*WebhookDispatcher, sqlmock.Sqlmock, *[]time.Duration) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	dispatcher := NewWebhookDispatcher(NewWebhookService(db))
+	var delays []time.Duration
+	dispatcher.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	return dispatcher, mock, &delays
+}
+
+var webhookRowColumns = []string{"id", "url", "secret", "events", "active", "created_at", "updated_at"}
+
+// expectSubscribers expects the webhooks subscribed to event to be loaded, each posting to url
+func expectSubscribers(mock sqlmock.Sqlmock, event string, urls ...string) {
+	rows := sqlmock.NewRows(webhookRowColumns)
+	for i, url := range urls {
+		rows.AddRow(i+1, url, testWebhookSecret, "{"+event+"}", true, time.Now(), time.Now())
+	}
+	mock.ExpectQuery(`FROM webhooks WHERE active AND \$1 = ANY\(events\)`).WithArgs(event).WillReturnRows(rows)
+}
+
+func TestWebhookDispatcher_SignsPayload(t *testing.T) {
+	dispatcher, mock, _ := newTestWebhookDispatcher(t)
+	receiver := newWebhookReceiver(t)
+	expectSubscribers(mock, WebhookEventBatchSyncCompleted, receiver.URL)
+
+	dispatcher.Dispatch(WebhookEventBatchSyncCompleted, map[string]interface{}{"sync_job_id": 5})
+	dispatcher.wait()
+
+	deliveries := receiver.deliveries()
+	require.Len(t, deliveries, 1)
+	delivery := deliveries[0]
+	assert.Equal(t, "application/json", delivery.header.Get("Content-Type"))
+	assert.Equal(t, WebhookEventBatchSyncCompleted, delivery.header.Get(WebhookEventHeader))
+	assert.Equal(t, SignWebhookPayload(testWebhookSecret, delivery.body), delivery.header.Get(WebhookSignatureHeader))
+	assert.NotEqual(t, SignWebhookPayload("another secret", delivery.body), delivery.header.Get(WebhookSignatureHeader))
+
+	var payload struct {
+		ID    string `json:"id"`
+		Event string `json:"event"`
+		Data  struct {
+			SyncJobID int `json:"sync_job_id"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(delivery.body, &payload))
+	assert.Equal(t, WebhookEventBatchSyncCompleted, payload.Event)
+	assert.Equal(t, 5, payload.Data.SyncJobID)
+	assert.Equal(t, payload.ID, delivery.header.Get(WebhookDeliveryHeader))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSignWebhookPayload_KnownVector(t *testing.T) {
+	// HMAC-SHA256 test case 2 of RFC 4231
+	assert.Equal(t, "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		SignWebhookPayload("Jefe", []byte("what do ya want for nothing?")))
+}
+
+func TestWebhookDispatcher_RetriesWithBackoffUntil2xx(t *testing.T) {
+	dispatcher, _, delays := newTestWebhookDispatcher(t)
+	dispatcher.SetRetryPolicy(4, time.Second, time.Minute)
+	receiver := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+
+	webhook := Webhook{ID: 1, URL: receiver.URL, Secret: testWebhookSecret}
+	delivery := dispatcher.deliver(context.Background(), webhook, dispatcher.newPayload(WebhookEventTest, nil), dispatcher.maxAttempts)
+
+	assert.True(t, delivery.Delivered)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Equal(t, http.StatusOK, delivery.StatusCode)
+
+	// Every attempt carries the same payload and signature
+	deliveries := receiver.deliveries()
+	require.Len(t, deliveries, 3)
+	for _, d := range deliveries[1:] {
+		assert.Equal(t, deliveries[0].body, d.body)
+		assert.Equal(t, deliveries[0].header.Get(WebhookSignatureHeader), d.header.Get(WebhookSignatureHeader))
+	}
+
+	// The delay doubles: drawn from the upper half of 1s, then of 2s
+	require.Len(t, *delays, 2)
+	assert.True(t, (*delays)[0] >= 500*time.Millisecond && (*delays)[0] <= time.Second, (*delays)[0])
+	assert.True(t, (*delays)[1] >= time.Second && (*delays)[1] <= 2*time.Second, (*delays)[1])
+}
+
+func TestWebhookDispatcher_GivesUpAfterMaxAttempts(t *testing.T) {
+	dispatcher, _, delays := newTestWebhookDispatcher(t)
+	dispatcher.SetRetryPolicy(3, 0, 0)
+	receiver := newWebhookReceiver(t, http.StatusNotFound, http.StatusNotFound, http.StatusNotFound, http.StatusNotFound)
+
+	webhook := Webhook{ID: 1, URL: receiver.URL, Secret: testWebhookSecret}
+	delivery := dispatcher.deliver(context.Background(), webhook, dispatcher.newPayload(WebhookEventTest, nil), dispatcher.maxAttempts)
+
+	assert.False(t, delivery.Delivered)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Equal(t, http.StatusNotFound, delivery.StatusCode)
+	assert.Equal(t, "webhook answered 404", delivery.Error)
+	assert.Len(t, receiver.deliveries(), 3)
+	assert.Len(t, *delays, 2)
+}
+
+func TestWebhookDispatcher_TestSendsOnce(t *testing.T) {
+	dispatcher, mock, delays := newTestWebhookDispatcher(t)
+	receiver := newWebhookReceiver(t, http.StatusServiceUnavailable)
+	mock.ExpectQuery("FROM webhooks WHERE id = \\$1").WithArgs(int64(3)).WillReturnRows(sqlmock.NewRows(webhookRowColumns).
+		AddRow(3, receiver.URL, testWebhookSecret, "{sync.batch_completed}", false, time.Now(), time.Now()))
+
+	delivery, err := dispatcher.Test(context.Background(), 3)
+	require.NoError(t, err)
+
+	assert.False(t, delivery.Delivered)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Empty(t, *delays)
+	deliveries := receiver.deliveries()
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, WebhookEventTest, deliveries[0].header.Get(WebhookEventHeader))
+}
+
+func TestWebhookDispatcher_TestUnknownWebhook(t *testing.T) {
+	dispatcher, mock, _ := newTestWebhookDispatcher(t)
+	mock.ExpectQuery("FROM webhooks WHERE id = \\$1").WithArgs(int64(3)).WillReturnRows(sqlmock.NewRows(webhookRowColumns))
+
+	_, err := dispatcher.Test(context.Background(), 3)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+}
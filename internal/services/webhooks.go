@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Webhook events
+const (
+	// WebhookEventBatchSyncCompleted is sent with the sync job when a batch sync ends
+	WebhookEventBatchSyncCompleted = "sync.batch_completed"
+	// WebhookEventSchedulerJobFailing is sent when a scheduled job's consecutive
+	// failures reach the threshold
+	WebhookEventSchedulerJobFailing = "scheduler.job_failing"
+	// WebhookEventTest is only sent by a test fire
+	WebhookEventTest = "webhook.test"
+)
+
+// WebhookEvents lists the events a webhook may subscribe to
+var WebhookEvents = []string{WebhookEventBatchSyncCompleted, WebhookEventSchedulerJobFailing}
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// Webhook is an endpoint sent a signed POST for each event it subscribes to
+type Webhook struct {
+	ID  int64  `json:"id"`
+	URL string `json:"url"`
+	// Secret is only returned when the webhook is created or its secret changed
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookUpdate changes the fields that are set and keeps the others
+type WebhookUpdate struct {
+	URL    *string
+	Secret *string
+	Events []string
+	Active *bool
+}
+
+// WebhookService stores webhooks
+type WebhookService struct {
+	db *sql.DB
+}
+
+func NewWebhookService(db *sql.DB) *WebhookService {
+	return &WebhookService{db: db}
+}
+
+const webhookColumns = "id, url, secret, events, active, created_at, updated_at"
+
+func scanWebhook(row interface{ Scan(...interface{}) error }) (*Webhook, error) {
+	var webhook Webhook
+	err := row.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, pq.Array(&webhook.Events), &webhook.Active,
+		&webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// List returns every webhook without its secret, oldest first
+func (w *WebhookService) List(ctx context.Context) ([]Webhook, error) {
+	rows, err := w.db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhook.Secret = ""
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// Get returns a webhook without its secret
+func (w *WebhookService) Get(ctx context.Context, id int64) (*Webhook, error) {
+	webhook, err := w.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+// get returns a webhook with its secret, for signing
+func (w *WebhookService) get(ctx context.Context, id int64) (*Webhook, error) {
+	webhook, err := scanWebhook(w.db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook %d: %w", id, err)
+	}
+	return webhook, nil
+}
+
+// Create stores an active webhook. An empty secret is generated; the
+// returned webhook carries it, as it is not shown again.
+func (w *WebhookService) Create(ctx context.Context, url, secret string, events []string) (*Webhook, error) {
+	if secret == "" {
+		var err error
+		if secret, err = generateWebhookSecret(); err != nil {
+			return nil, err
+		}
+	}
+	webhook, err := scanWebhook(w.db.QueryRowContext(ctx, `
+		INSERT INTO webhooks (url, secret, events) VALUES ($1, $2, $3)
+		RETURNING `+webhookColumns, url, secret, pq.Array(events)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// Update changes a webhook; the secret is only returned when it was changed
+func (w *WebhookService) Update(ctx context.Context, id int64, update WebhookUpdate) (*Webhook, error) {
+	var events interface{}
+	if update.Events != nil {
+		events = pq.Array(update.Events)
+	}
+	webhook, err := scanWebhook(w.db.QueryRowContext(ctx, `
+		UPDATE webhooks
+		SET url = COALESCE($2, url), secret = COALESCE($3, secret),
+		    events = COALESCE($4, events), active = COALESCE($5, active)
+		WHERE id = $1
+		RETURNING `+webhookColumns, id, update.URL, update.Secret, events, update.Active))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook %d: %w", id, err)
+	}
+	if update.Secret == nil {
+		webhook.Secret = ""
+	}
+	return webhook, nil
+}
+
+// Delete removes a webhook
+func (w *WebhookService) Delete(ctx context.Context, id int64) error {
+	result, err := w.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook %d: %w", id, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// subscribers returns the active webhooks subscribed to event, with their secrets
+func (w *WebhookService) subscribers(ctx context.Context, event string) ([]Webhook, error) {
+	rows, err := w.db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE active AND $1 = ANY(events) ORDER BY id", event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s webhooks: %w", event, err)
+	}
+	defer rows.Close()
+
+	var webhooks []Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// IsWebhookEvent reports whether a webhook may subscribe to event
+func IsWebhookEvent(event string) bool {
+	return slices.Contains(WebhookEvents, event)
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
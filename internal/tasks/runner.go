@@ -1,29 +1,37 @@
 package tasks
 
 import (
+	"bufio"
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
 	"time"
 
+	"stock-intelligence-backend/internal/auth"
+	"stock-intelligence-backend/internal/cache"
+	"stock-intelligence-backend/internal/database"
 	"stock-intelligence-backend/internal/services"
 )
 
 type TaskRunner struct {
 	db                 *sql.DB
-	alphaVantageClient *services.AlphaVantageClient
+	provider           services.MarketDataProvider
 }
 
-func NewTaskRunner(db *sql.DB, alphaVantageClient *services.AlphaVantageClient) *TaskRunner {
+func NewTaskRunner(db *sql.DB, provider services.MarketDataProvider) *TaskRunner {
 	return &TaskRunner{
 		db:                 db,
-		alphaVantageClient: alphaVantageClient,
+		provider:           provider,
 	}
 }
 
 // SeedDatabase seeds the database with initial stock symbols and sample historical data
 func (t *TaskRunner) SeedDatabase() error {
-	log.Println("Starting database seeding...")
+	slog.Info("Starting database seeding")
 	
 	// First seed the stocks
 	if err := t.SeedStocks(); err != nil {
@@ -31,29 +39,26 @@ func (t *TaskRunner) SeedDatabase() error {
 	}
 	
 	// Then fetch some sample historical data (limited by rate limits)
-	log.Println("Fetching sample historical data for top 5 stocks...")
+	slog.Info("Fetching sample historical data", "stocks", 5)
 	topStocks := []string{"AAPL", "MSFT", "GOOGL", "AMZN", "TSLA"}
 	
 	for i, symbol := range topStocks {
 		// Respect rate limits - only fetch if we can make requests
-		canMake, err := t.alphaVantageClient.CanMakeRequest()
+		canMake, err := t.provider.CanMakeRequest()
 		if err != nil {
-			log.Printf("Failed to check rate limit: %v", err)
+			slog.Error("Failed to check rate limit", "error", err)
 			break
 		}
 		if !canMake {
-			log.Printf("Rate limit reached after %d stocks. Run 'data:fetch:all' later to get remaining data.", i)
+			slog.Warn("Rate limit reached, run data:fetch:all later to get the remaining data", "fetched", i)
 			break
 		}
 		
-		log.Printf("Fetching historical data for %s (%d/%d)...", symbol, i+1, len(topStocks))
+		slog.Info("Fetching historical data", "symbol", symbol, "index", i+1, "total", len(topStocks))
 		if err := t.fetchHistoricalDataForSymbol(symbol); err != nil {
-			log.Printf("Warning: Failed to fetch data for %s: %v", symbol, err)
+			slog.Warn("Failed to fetch historical data", "symbol", symbol, "error", err)
 			continue
 		}
-		
-		// Small delay between requests
-		time.Sleep(2 * time.Second)
 	}
 	
 	return nil
@@ -61,10 +66,43 @@ func (t *TaskRunner) SeedDatabase() error {
 
 // SeedStocks seeds the database with stock symbols (S&P 500 subset)
 func (t *TaskRunner) SeedStocks() error {
-	log.Println("Seeding stock symbols...")
+	slog.Info("Seeding stock symbols")
 	
-	stocks := getStockSeeds()
+	return t.upsertStockSeeds(getStockSeeds())
+}
+
+// SeedStocksFromFile seeds the stock symbols listed in a CSV file instead of
+// the embedded list. Rows that cannot be read are reported and skipped, and
+// the rest are still seeded; the task then fails so the rejects are noticed.
+func (t *TaskRunner) SeedStocksFromFile(path string) error {
+	slog.Info("Seeding stock symbols", "file", path)
 	
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	stocks, rowErrs, err := ParseStockSeedsCSV(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for _, rowErr := range rowErrs {
+		slog.Warn("Rejected stock seed row", "file", path, "error", rowErr)
+	}
+	
+	if err := t.upsertStockSeeds(stocks); err != nil {
+		return err
+	}
+	if len(rowErrs) > 0 {
+		return fmt.Errorf("%s: %d rows rejected, %d stocks seeded", path, len(rowErrs), len(stocks))
+	}
+	return nil
+}
+
+// upsertStockSeeds inserts the stocks, updating those already stored. A stock
+// that fails is logged and the rest are still seeded.
+func (t *TaskRunner) upsertStockSeeds(stocks []StockSeed) error {
 	// Prepare insert statement
 	insertQuery := `
 		INSERT INTO stocks (symbol, company_name, sector, industry, exchange, market_cap, is_active)
@@ -98,7 +136,7 @@ func (t *TaskRunner) SeedStocks() error {
 			stock.IsActive,
 		)
 		if err != nil {
-			log.Printf("Failed to insert stock %s: %v", stock.Symbol, err)
+			slog.Error("Failed to insert stock", "symbol", stock.Symbol, "error", err)
 			continue
 		}
 		
@@ -110,7 +148,7 @@ func (t *TaskRunner) SeedStocks() error {
 		}
 	}
 	
-	log.Printf("Stock seeding completed: %d inserted, %d updated", inserted, updated)
+	slog.Info("Stock seeding completed", "inserted", inserted, "updated", updated)
 	return nil
 }
 
@@ -124,9 +162,48 @@ func (t *TaskRunner) FetchHistoricalData(symbol string) error {
 	return t.FetchAllHistoricalData()
 }
 
+// FetchIntradayPrices fetches and saves intraday bars for a symbol at the given interval
+func (t *TaskRunner) FetchIntradayPrices(symbol, interval string) error {
+	intraday, ok := t.provider.(services.IntradayProvider)
+	if !ok {
+		return fmt.Errorf("%s does not provide intraday data", t.provider.Name())
+	}
+	
+	data, err := intraday.FetchIntradayData(symbol, interval, services.InitiatorTasksCLI)
+	if err != nil {
+		return fmt.Errorf("failed to fetch intraday data from %s: %w", t.provider.Name(), err)
+	}
+	
+	if err := intraday.SaveIntradayData(context.Background(), symbol, data); err != nil {
+		return fmt.Errorf("failed to save intraday data to database: %w", err)
+	}
+	
+	return nil
+}
+
+// FetchAdjustedHistory fetches and saves a symbol's split- and dividend-adjusted
+// daily history, recording any splits it reports
+func (t *TaskRunner) FetchAdjustedHistory(symbol string) error {
+	adjusted, ok := t.provider.(services.AdjustedDataProvider)
+	if !ok {
+		return fmt.Errorf("%s does not provide adjusted data", t.provider.Name())
+	}
+	
+	data, err := adjusted.FetchDailyAdjustedData(symbol, services.InitiatorTasksCLI)
+	if err != nil {
+		return fmt.Errorf("failed to fetch adjusted data from %s: %w", t.provider.Name(), err)
+	}
+	
+	if _, err := t.provider.SaveHistoricalData(context.Background(), symbol, data, services.SyncLockWait); err != nil {
+		return fmt.Errorf("failed to save data to database: %w", err)
+	}
+	
+	return nil
+}
+
 // FetchAllHistoricalData fetches historical data for all active stocks (respects rate limits)
 func (t *TaskRunner) FetchAllHistoricalData() error {
-	log.Println("Fetching historical data for all active stocks...")
+	slog.Info("Fetching historical data for all active stocks")
 	
 	// Get all active stock symbols
 	query := `SELECT symbol FROM stocks WHERE is_active = true ORDER BY symbol`
@@ -145,42 +222,37 @@ func (t *TaskRunner) FetchAllHistoricalData() error {
 		symbols = append(symbols, symbol)
 	}
 	
-	log.Printf("Found %d active stocks to fetch data for", len(symbols))
+	slog.Info("Found active stocks to fetch data for", "stocks", len(symbols))
 	
 	fetched := 0
 	skipped := 0
 	
 	for i, symbol := range symbols {
 		// Check rate limits before each request
-		canMake, err := t.alphaVantageClient.CanMakeRequest()
+		canMake, err := t.provider.CanMakeRequest()
 		if err != nil {
-			log.Printf("Failed to check rate limit: %v", err)
+			slog.Error("Failed to check rate limit", "error", err)
 			break
 		}
 		if !canMake {
-			log.Printf("Rate limit reached after %d stocks. %d stocks skipped.", fetched, len(symbols)-i)
+			slog.Warn("Rate limit reached", "fetched", fetched, "skipped", len(symbols)-i)
 			skipped = len(symbols) - i
 			break
 		}
 		
-		log.Printf("Fetching data for %s (%d/%d)...", symbol, i+1, len(symbols))
+		slog.Info("Fetching historical data", "symbol", symbol, "index", i+1, "total", len(symbols))
 		if err := t.fetchHistoricalDataForSymbol(symbol); err != nil {
-			log.Printf("Warning: Failed to fetch data for %s: %v", symbol, err)
+			slog.Warn("Failed to fetch historical data", "symbol", symbol, "error", err)
 			continue
 		}
 		
 		fetched++
-		
-		// Respectful delay between requests
-		if i < len(symbols)-1 {
-			time.Sleep(3 * time.Second)
-		}
 	}
 	
-	log.Printf("Historical data fetch completed: %d successful, %d skipped due to rate limits", fetched, skipped)
+	slog.Info("Historical data fetch completed", "fetched", fetched, "skipped", skipped)
 	
 	if skipped > 0 {
-		log.Printf("To fetch remaining data, run this task again tomorrow or upgrade to Alpha Vantage premium.")
+		slog.Warn("Stocks were skipped for rate limits; run this task again tomorrow or upgrade the market data plan", "skipped", skipped)
 	}
 	
 	return nil
@@ -188,12 +260,12 @@ func (t *TaskRunner) FetchAllHistoricalData() error {
 
 // fetchHistoricalDataForSymbol fetches and saves historical data for a specific symbol
 func (t *TaskRunner) fetchHistoricalDataForSymbol(symbol string) error {
-	data, err := t.alphaVantageClient.FetchDailyData(symbol)
+	data, err := t.provider.FetchDailyData(context.Background(), symbol, services.InitiatorTasksCLI)
 	if err != nil {
-		return fmt.Errorf("failed to fetch data from Alpha Vantage: %w", err)
+		return fmt.Errorf("failed to fetch data from %s: %w", t.provider.Name(), err)
 	}
 	
-	if err := t.alphaVantageClient.SaveHistoricalData(symbol, data); err != nil {
+	if _, err := t.provider.SaveHistoricalData(context.Background(), symbol, data, services.SyncLockWait); err != nil {
 		return fmt.Errorf("failed to save data to database: %w", err)
 	}
 	
@@ -202,34 +274,28 @@ func (t *TaskRunner) fetchHistoricalDataForSymbol(symbol string) error {
 
 // DatabaseStatus shows current database statistics
 func (t *TaskRunner) DatabaseStatus() error {
-	log.Println("=== Database Status ===")
-	
 	// Stock count
 	var stockCount int
 	if err := t.db.QueryRow("SELECT COUNT(*) FROM stocks").Scan(&stockCount); err != nil {
 		return err
 	}
-	log.Printf("Total stocks: %d", stockCount)
 	
 	var activeStockCount int
 	if err := t.db.QueryRow("SELECT COUNT(*) FROM stocks WHERE is_active = true").Scan(&activeStockCount); err != nil {
 		return err
 	}
-	log.Printf("Active stocks: %d", activeStockCount)
 	
 	// Historical data count
 	var priceCount int
 	if err := t.db.QueryRow("SELECT COUNT(*) FROM daily_prices").Scan(&priceCount); err != nil {
 		return err
 	}
-	log.Printf("Historical price records: %d", priceCount)
 	
 	// Stocks with data
 	var stocksWithData int
 	if err := t.db.QueryRow("SELECT COUNT(DISTINCT stock_id) FROM daily_prices").Scan(&stocksWithData); err != nil {
 		return err
 	}
-	log.Printf("Stocks with historical data: %d", stocksWithData)
 	
 	// Date range
 	var minDate, maxDate sql.NullTime
@@ -237,20 +303,171 @@ func (t *TaskRunner) DatabaseStatus() error {
 		return err
 	}
 	
+	attrs := []any{
+		"stocks", stockCount,
+		"active_stocks", activeStockCount,
+		"price_records", priceCount,
+		"stocks_with_prices", stocksWithData,
+	}
 	if minDate.Valid && maxDate.Valid {
-		log.Printf("Data date range: %s to %s", 
-			minDate.Time.Format("2006-01-02"), 
-			maxDate.Time.Format("2006-01-02"))
-	} else {
-		log.Printf("No historical data found")
+		attrs = append(attrs, "from", minDate.Time.Format("2006-01-02"), "to", maxDate.Time.Format("2006-01-02"))
+	}
+	slog.Info("Database status", attrs...)
+	
+	return nil
+}
+
+// VerifyData reports the active stocks with gaps of at least minGapDays
+// trading days in their stored daily prices
+func (t *TaskRunner) VerifyData(minGapDays int) error {
+	calendar := services.NewMarketCalendar()
+	if _, err := calendar.LoadHolidays(context.Background(), t.db); err != nil {
+		slog.Warn("Checking against the built-in holidays only", "error", err)
+	}
+	
+	report, err := services.NewDataQualityService(t.db, calendar).GapReport(context.Background(), minGapDays)
+	if err != nil {
+		return err
+	}
+	
+	if len(report) == 0 {
+		slog.Info("No data gaps found", "min_gap_days", minGapDays)
+		return nil
 	}
 	
+	missing := 0
+	for _, stock := range report {
+		slog.Warn("Stock has data gaps", "symbol", stock.Symbol, "missing_days", stock.MissingDays, "gaps", len(stock.Gaps))
+		for _, gap := range stock.Gaps {
+			slog.Warn("Data gap", "symbol", stock.Symbol, "from", gap.From.Format("2006-01-02"), "to", gap.To.Format("2006-01-02"), "trading_days", gap.TradingDays)
+		}
+		missing += stock.MissingDays
+	}
+	slog.Info("Data gap check completed", "min_gap_days", minGapDays, "stocks_with_gaps", len(report), "missing_days", missing)
+	
 	return nil
 }
 
+// RollbackMigrations reverts the steps most recently applied migrations
+func (t *TaskRunner) RollbackMigrations(steps int) error {
+	return database.NewMigrator(t.db, "./migrations").Down(steps)
+}
+
+// ImportIndex replaces an index's constituents with those listed in a CSV file
+func (t *TaskRunner) ImportIndex(indexName, path string) error {
+	if !slices.Contains(services.SupportedIndexes, indexName) {
+		return fmt.Errorf("unsupported index %q", indexName)
+	}
+	
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	constituents, err := services.ParseConstituentsCSV(file, indexName, today)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	
+	removed, err := services.NewSP500PriorityService(t.db).ImportConstituents(context.Background(), indexName, constituents)
+	if err != nil {
+		return err
+	}
+	
+	slog.Info("Imported index constituents", "index", indexName, "constituents", len(constituents), "removed", removed)
+	return nil
+}
+
+// ExportPrices writes the daily prices of symbol, or of every stock when
+// symbol is empty, to the file out, or to stdout when out is empty. The
+// format defaults to the one out's extension names.
+func (t *TaskRunner) ExportPrices(symbol, format, out string) error {
+	if format == "" {
+		format = services.PriceFormatForPath(out)
+	}
+	
+	var dest io.Writer = os.Stdout
+	if out != "" {
+		file, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		dest = file
+	}
+	buffered := bufio.NewWriter(dest)
+	
+	written, err := services.ExportDailyPrices(context.Background(), t.db, buffered, symbol, format)
+	if err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	
+	scope := "every stock"
+	if symbol != "" {
+		scope = symbol
+	}
+	// Progress goes to stderr, so an export to stdout can be piped
+	slog.Info("Exported daily prices", "prices", written, "scope", scope, "format", format)
+	return nil
+}
+
+// ImportPrices upserts the daily prices in a file written by ExportPrices.
+// The format defaults to the one the file's extension names.
+func (t *TaskRunner) ImportPrices(path, format string) error {
+	if format == "" {
+		format = services.PriceFormatForPath(path)
+	}
+	
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	stats, err := services.ImportDailyPrices(context.Background(), t.db, bufio.NewReader(file), format)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	
+	fmt.Printf("Inserted: %d\nUpdated:  %d\nSkipped:  %d\n", stats.Inserted, stats.Updated, stats.Skipped)
+	return nil
+}
+
+// PruneData deletes the daily prices, API call logs and sync history older
+// than policy keeps them, printing how many rows of each table went. With
+// dryRun it prints how many would go and deletes nothing.
+func (t *TaskRunner) PruneData(policy services.RetentionPolicy, dryRun bool) error {
+	pruner := services.NewDataPruner(t.db)
+	slog.Info("Pruning old data",
+		"prices_from", policy.PriceCutoff(time.Now()).Format("2006-01-02"),
+		"api_call_days", int(policy.APICalls.Hours()/24),
+		"sync_history_days", int(policy.SyncHistory.Hours()/24),
+		"dry_run", dryRun)
+	
+	var counts []services.PruneCount
+	var err error
+	verb := "Deleted"
+	if dryRun {
+		verb = "Would delete"
+		counts, err = pruner.Count(context.Background(), policy)
+	} else {
+		counts, err = pruner.Prune(context.Background(), policy)
+	}
+	// A failed prune still reports the batches it committed
+	for _, count := range counts {
+		fmt.Printf("%s %d rows from %s\n", verb, count.Rows, count.Table)
+	}
+	return err
+}
+
 // ClearCache clears various cached data
 func (t *TaskRunner) ClearCache() error {
-	log.Println("Clearing cache...")
+	slog.Info("Clearing cache")
 	
 	// Clear old API call logs (keep last 7 days)
 	result, err := t.db.Exec("DELETE FROM api_calls WHERE created_at < CURRENT_TIMESTAMP - INTERVAL '7 days'")
@@ -259,37 +476,90 @@ func (t *TaskRunner) ClearCache() error {
 	}
 	
 	rowsDeleted, _ := result.RowsAffected()
-	log.Printf("Cleared %d old API call records", rowsDeleted)
+	slog.Info("Cleared old API call records", "rows", rowsDeleted)
 	
 	return nil
 }
 
-// APIStatus shows Alpha Vantage API status
-func (t *TaskRunner) APIStatus() error {
-	log.Println("=== Alpha Vantage API Status ===")
+// WarmCache loads the stock lists, market overview and performance rankings into redisCache
+func (t *TaskRunner) WarmCache(redisCache *cache.RedisCache, maxChangePercent float64) error {
+	slog.Info("Warming cache")
 	
-	rateLimit, err := t.alphaVantageClient.GetRateLimit()
+	stockService := services.NewDatabaseStockService(t.db, redisCache)
+	stockService.SetMaxChangePercent(maxChangePercent)
+	return stockService.WarmCache(context.Background())
+}
+
+// CacheStats shows how many requests the response cache has served
+func CacheStats(redisCache *cache.RedisCache) error {
+	ctx := context.Background()
+	hits, misses, err := redisCache.ResponseStats(ctx)
+	if err != nil {
+		return err
+	}
+	generation, err := redisCache.ResponseGeneration(ctx)
 	if err != nil {
 		return err
 	}
 	
-	log.Printf("Daily limit: %d", rateLimit.DailyLimit)
-	log.Printf("Daily used: %d", rateLimit.CurrentDailyCount)
-	log.Printf("Daily remaining: %d", rateLimit.RemainingDaily())
-	log.Printf("Can make request: %t", rateLimit.CanMakeRequest())
-	log.Printf("Last reset: %s", rateLimit.LastResetDate.Format("2006-01-02"))
+	// The generation is bumped by every sync
+	attrs := []any{"hits", hits, "misses", misses, "generation", generation}
+	if lookups := hits + misses; lookups > 0 {
+		attrs = append(attrs, "hit_ratio", fmt.Sprintf("%.1f%%", float64(hits)/float64(lookups)*100))
+	}
+	slog.Info("Response cache", attrs...)
+	
+	return nil
+}
+
+// CreateAPIKey mints an API key for the write and admin endpoints
+func (t *TaskRunner) CreateAPIKey(name string) (string, error) {
+	return auth.NewAPIKeyStore(t.db).Create(name)
+}
+
+// APIStatus shows the market data provider's API status
+func (t *TaskRunner) APIStatus() error {
+	budget, err := t.provider.GetAPIBudget()
+	if err != nil {
+		return err
+	}
+	
+	attrs := []any{
+		"provider", t.provider.Name(),
+		"daily_limit", budget.DailyLimit,
+		"daily_used", budget.DailyUsed,
+		"daily_remaining", budget.DailyRemaining,
+	}
+	if budget.HourlyLimit != nil {
+		attrs = append(attrs, "hourly_limit", *budget.HourlyLimit, "hourly_used", budget.HourlyUsed, "hourly_remaining", *budget.HourlyRemaining)
+	}
+	attrs = append(attrs,
+		"calls_per_minute", budget.CallsPerMinute,
+		"can_make_request", budget.CanMakeRequest,
+		"next_call_allowed_at", budget.NextCallAllowedAt.Format("2006-01-02 15:04:05"))
+	slog.Info("API status", attrs...)
 	
 	// Recent API calls
-	stats, err := t.alphaVantageClient.GetAPICallStats(1)
+	stats, err := t.provider.GetAPICallStats(1)
 	if err != nil {
 		return err
 	}
 	
 	if len(stats) > 0 {
-		log.Printf("Today's API calls: %d successful, %d failed", 
-			stats[0].SuccessfulCalls, stats[0].FailedCalls)
+		slog.Info("Today's API calls", "successful", stats[0].SuccessfulCalls, "failed", stats[0].FailedCalls)
 	} else {
-		log.Printf("No API calls made today")
+		slog.Info("No API calls made today")
+	}
+	
+	// Who spent today's budget
+	usage, err := t.provider.GetAPICallStatsByInitiator(0)
+	if err != nil {
+		return err
+	}
+	
+	for _, u := range usage {
+		slog.Info("Today's API calls by initiator",
+			"initiated_by", u.InitiatedBy, "calls", u.TotalCalls, "successful", u.SuccessfulCalls, "failed", u.FailedCalls)
 	}
 	
 	return nil
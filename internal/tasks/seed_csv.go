@@ -0,0 +1,126 @@
+package tasks
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"stock-intelligence-backend/internal/models"
+)
+
+// stockSeedColumns are the columns a stock universe CSV must have, in any order
+var stockSeedColumns = []string{"symbol", "name", "sector", "industry", "exchange", "market_cap"}
+
+// ParseStockSeedsCSV reads a stock universe from CSV with a header row naming
+// exactly the stockSeedColumns. A malformed row, or one repeating an earlier
+// row's symbol, is left out and reported, with its line number, among the
+// row errors rather than failing the whole file; a bad header or a file
+// without rows is the returned error. Every stock read is active.
+func ParseStockSeedsCSV(r io.Reader) (seeds []StockSeed, rowErrs []error, err error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	// Rows are checked against the header below, so a short row is reported like any other
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("stock universe CSV is empty")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read stock universe header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(stockSeedColumns, name) {
+			return nil, nil, fmt.Errorf("stock universe CSV has an unknown column %q; expected %s", name, strings.Join(stockSeedColumns, ", "))
+		}
+		if _, ok := columns[name]; ok {
+			return nil, nil, fmt.Errorf("stock universe CSV has the %s column twice", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range stockSeedColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("stock universe CSV has no %s column", name)
+		}
+	}
+
+	seen := make(map[string]int)
+	rows := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows++
+			rowErrs = append(rowErrs, fmt.Errorf("line %d: %w", parseErr.Line, parseErr.Err))
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read stock universe: %w", err)
+		}
+		rows++
+		line, _ := reader.FieldPos(0)
+
+		seed, err := parseStockSeed(record, columns)
+		if err != nil {
+			rowErrs = append(rowErrs, fmt.Errorf("line %d: %w", line, err))
+			continue
+		}
+		if first, ok := seen[seed.Symbol]; ok {
+			rowErrs = append(rowErrs, fmt.Errorf("line %d: %s is already listed on line %d", line, seed.Symbol, first))
+			continue
+		}
+		seen[seed.Symbol] = line
+		seeds = append(seeds, seed)
+	}
+
+	if rows == 0 {
+		return nil, nil, errors.New("stock universe CSV has no rows")
+	}
+	return seeds, rowErrs, nil
+}
+
+// parseStockSeed reads one row of a stock universe CSV
+func parseStockSeed(record []string, columns map[string]int) (StockSeed, error) {
+	if len(record) != len(columns) {
+		return StockSeed{}, fmt.Errorf("has %d fields, expected %d", len(record), len(columns))
+	}
+	field := func(name string) string {
+		return strings.TrimSpace(record[columns[name]])
+	}
+
+	symbol, err := models.NormalizeSymbol(field("symbol"))
+	if err != nil {
+		return StockSeed{}, err
+	}
+	seed := StockSeed{
+		Symbol:      symbol,
+		CompanyName: field("name"),
+		Sector:      field("sector"),
+		Industry:    field("industry"),
+		Exchange:    strings.ToUpper(field("exchange")),
+		IsActive:    true,
+	}
+	if seed.CompanyName == "" {
+		return StockSeed{}, fmt.Errorf("%s has no name", symbol)
+	}
+	if seed.Exchange == "" {
+		return StockSeed{}, fmt.Errorf("%s has no exchange", symbol)
+	}
+	if value := field("market_cap"); value != "" {
+		marketCap, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || marketCap <= 0 {
+			return StockSeed{}, fmt.Errorf("%s market_cap %q is not a positive whole number", symbol, value)
+		}
+		seed.MarketCap = &marketCap
+	}
+	return seed, nil
+}
@@ -0,0 +1,77 @@
+package tasks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStockSeedsCSV(t *testing.T) {
+	seeds, rowErrs, err := ParseStockSeedsCSV(strings.NewReader(`Exchange,Symbol,Name,Sector,Industry,Market_Cap
+NASDAQ,aapl,Apple Inc.,Technology,Consumer Electronics,3000000000000
+nyse,BRK-B,Berkshire Hathaway Inc.,Financial Services,Insurance,
+`))
+
+	require.NoError(t, err)
+	assert.Empty(t, rowErrs)
+	require.Len(t, seeds, 2)
+	marketCap := int64(3000000000000)
+	assert.Equal(t, StockSeed{Symbol: "AAPL", CompanyName: "Apple Inc.", Sector: "Technology",
+		Industry: "Consumer Electronics", Exchange: "NASDAQ", MarketCap: &marketCap, IsActive: true}, seeds[0])
+	assert.Equal(t, "BRK.B", seeds[1].Symbol)
+	assert.Equal(t, "NYSE", seeds[1].Exchange)
+	assert.Nil(t, seeds[1].MarketCap, "an empty market cap is unknown")
+}
+
+func TestParseStockSeedsCSV_ReportsBadRowsAndKeepsTheRest(t *testing.T) {
+	seeds, rowErrs, err := ParseStockSeedsCSV(strings.NewReader(`symbol,name,sector,industry,exchange,market_cap
+AAPL,Apple Inc.,Technology,Consumer Electronics,NASDAQ,3000000000000
+NOT A TICKER,Bad Symbol,Technology,Software,NASDAQ,1
+MSFT,Microsoft Corporation,Technology,Software,NASDAQ,lots
+GOOGL,Alphabet Inc.,Technology
+TSLA,,Consumer Discretionary,Electric Vehicles,NASDAQ,800000000000
+aapl,Apple Again,Technology,Consumer Electronics,NASDAQ,1
+XOM,Exxon Mobil Corporation,Energy,Oil & Gas,NYSE,460000000000
+`))
+
+	require.NoError(t, err)
+	require.Len(t, seeds, 2)
+	assert.Equal(t, "AAPL", seeds[0].Symbol)
+	assert.Equal(t, "XOM", seeds[1].Symbol)
+
+	messages := make([]string, len(rowErrs))
+	for i, rowErr := range rowErrs {
+		messages[i] = rowErr.Error()
+	}
+	require.Len(t, messages, 5)
+	assert.Contains(t, messages[0], "line 3: invalid stock symbol")
+	assert.Contains(t, messages[1], `line 4: MSFT market_cap "lots"`)
+	assert.Contains(t, messages[2], "line 5: has 3 fields, expected 6")
+	assert.Contains(t, messages[3], "line 6: TSLA has no name")
+	assert.Equal(t, "line 7: AAPL is already listed on line 2", messages[4])
+}
+
+func TestParseStockSeedsCSV_RejectsFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "empty", input: "", want: "empty"},
+		{name: "header only", input: "symbol,name,sector,industry,exchange,market_cap\n", want: "no rows"},
+		{name: "missing column", input: "symbol,name,sector,industry,exchange\nAAPL,Apple,Technology,Hardware,NASDAQ\n", want: "no market_cap column"},
+		{name: "unknown column", input: "symbol,name,sector,industry,exchange,marketcap\n", want: `unknown column "marketcap"`},
+		{name: "repeated column", input: "symbol,name,sector,industry,exchange,market_cap,symbol\n", want: "symbol column twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seeds, rowErrs, err := ParseStockSeedsCSV(strings.NewReader(tt.input))
+
+			assert.ErrorContains(t, err, tt.want)
+			assert.Nil(t, seeds)
+			assert.Nil(t, rowErrs)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Package version identifies the running build. Release builds set Version,
+// Commit and BuildTime with the linker:
+//
+//	go build -ldflags "-X stock-intelligence-backend/internal/version.Version=v1.4.0 \
+//	  -X stock-intelligence-backend/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X stock-intelligence-backend/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// A plain go build or go run leaves Version as "dev" and takes the commit
+// from the VCS stamp Go embeds, when there is one.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Set with -ldflags -X at build time
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// unknown stands in for build details that were neither set nor embedded
+const unknown = "unknown"
+
+// startedAt is when the process started, near enough
+var startedAt = time.Now()
+
+// Info describes the running build and process
+type Info struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
+	BuildTime string    `json:"build_time"`
+	GoVersion string    `json:"go_version"`
+	StartedAt time.Time `json:"started_at"`
+	// UptimeSeconds is how long the process has been running
+	UptimeSeconds float64 `json:"uptime_seconds"`
+}
+
+// Get returns the build information and the uptime as of now
+func Get() Info {
+	commit, buildTime := Commit, BuildTime
+	if commit == "" {
+		commit = vcsSetting("vcs.revision")
+	}
+	if buildTime == "" {
+		buildTime = unknown
+	}
+	return Info{
+		Version:       Version,
+		Commit:        commit,
+		BuildTime:     buildTime,
+		GoVersion:     runtime.Version(),
+		StartedAt:     startedAt,
+		UptimeSeconds: time.Since(startedAt).Seconds(),
+	}
+}
+
+// vcsSetting reads a VCS stamp from the build info, marking a build from a
+// modified tree as dirty
+func vcsSetting(key string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return unknown
+	}
+	value, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case key:
+			value = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if value == "" {
+		return unknown
+	}
+	if modified {
+		value += "-dirty"
+	}
+	return value
+}
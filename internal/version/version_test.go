@@ -0,0 +1,37 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet_DefaultsWithoutLdflags(t *testing.T) {
+	info := Get()
+
+	assert.Equal(t, "dev", info.Version)
+	assert.NotEmpty(t, info.Commit, "the VCS stamp, or unknown without one")
+	assert.Equal(t, unknown, info.BuildTime)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Equal(t, startedAt, info.StartedAt)
+}
+
+func TestGet_UsesLdflagsValues(t *testing.T) {
+	defer func(version, commit, buildTime string) {
+		Version, Commit, BuildTime = version, commit, buildTime
+	}(Version, Commit, BuildTime)
+	Version, Commit, BuildTime = "v1.4.0", "0a1b2c3", "2026-03-02T15:00:00Z"
+
+	info := Get()
+	assert.Equal(t, "v1.4.0", info.Version)
+	assert.Equal(t, "0a1b2c3", info.Commit)
+	assert.Equal(t, "2026-03-02T15:00:00Z", info.BuildTime)
+}
+
+func TestGet_UptimeIncreases(t *testing.T) {
+	first := Get().UptimeSeconds
+	time.Sleep(5 * time.Millisecond)
+	assert.Greater(t, Get().UptimeSeconds, first)
+}
@@ -1,142 +1,355 @@
 package main
 
 import (
-	"log"
+	"context"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
+	"stock-intelligence-backend/internal/auth"
 	"stock-intelligence-backend/internal/cache"
+	"stock-intelligence-backend/internal/config"
 	"stock-intelligence-backend/internal/database"
 	"stock-intelligence-backend/internal/handlers"
+	"stock-intelligence-backend/internal/jobs"
+	"stock-intelligence-backend/internal/logging"
+	"stock-intelligence-backend/internal/ratelimit"
+	"stock-intelligence-backend/internal/responsecache"
 	"stock-intelligence-backend/internal/services"
+	"stock-intelligence-backend/internal/version"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
 func main() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+	envErr := godotenv.Load()
+
+	// Configure structured logging from LOG_FORMAT and LOG_LEVEL
+	logger := logging.FromEnv()
+	if envErr != nil {
+		logger.Info("No .env file found")
 	}
+	build := version.Get()
+	logger.Info("Starting stock-intelligence-backend", "version", build.Version, "commit", build.Commit,
+		"build_time", build.BuildTime, "go_version", build.GoVersion)
 
 	// Set Gin mode
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.DebugMode)
 	}
 
+	// Load CORS and proxy settings first; an unsafe combination must stop startup
+	httpConfig, err := config.LoadHTTPConfig()
+	if err != nil {
+		logging.Fatal(logger, "Invalid HTTP configuration", "error", err)
+	}
+
 	// Initialize database
 	db, err := database.InitializeDatabase()
 	if err != nil {
-		log.Fatal("Failed to initialize database:", err)
+		logging.Fatal(logger, "Failed to initialize database", "error", err)
 	}
 	defer db.Close()
 
+	// Connect the optional read replica; reads fall back to the primary without one
+	dbConfig, err := database.LoadConfig()
+	if err != nil {
+		logging.Fatal(logger, "Invalid database configuration", "error", err)
+	}
+	replica, err := database.ConnectReplica(dbConfig)
+	if err != nil {
+		logger.Warn("Failed to connect to read replica, serving reads from the primary", "error", err)
+		replica = nil
+	} else if replica != nil {
+		defer replica.Close()
+	}
+	readRouter := database.NewReadRouter(db, replica, dbConfig.ReplicaStickyWindow)
+
 	// Initialize Redis cache
 	redisURL := os.Getenv("REDIS_URL")
-	redisCache, err := cache.NewRedisCache(redisURL)
+	redisCache, err := cache.NewRedisCache(redisURL, os.Getenv("REDIS_KEY_PREFIX"))
 	if err != nil {
-		log.Printf("Warning: Failed to connect to Redis: %v", err)
-		log.Println("Continuing without cache...")
+		logger.Warn("Failed to connect to Redis, continuing without cache", "error", err)
 		redisCache = nil
 	} else {
 		defer redisCache.Close()
+		if v := os.Getenv("REDIS_OP_TIMEOUT"); v != "" {
+			if timeout, err := time.ParseDuration(v); err == nil && timeout >= 0 {
+				redisCache.SetOperationTimeout(timeout)
+			} else {
+				logger.Warn("Ignoring invalid REDIS_OP_TIMEOUT", "value", v)
+			}
+		}
 	}
 	
 	// Initialize services
 	apiKey := os.Getenv("ALPHA_VANTAGE_API_KEY")
 	
-	// Create Alpha Vantage client
-	alphaVantageClient := services.NewAlphaVantageClient(apiKey, db)
+	// Create the market data provider; Alpha Vantage unless MARKET_DATA_PROVIDER names another
+	marketDataProvider, err := services.NewMarketDataProvider(os.Getenv("MARKET_DATA_PROVIDER"), apiKey, db)
+	if err != nil {
+		logging.Fatal(logger, "Failed to configure market data provider", "error", err)
+	}
+	useAlphaVantage := marketDataProvider.Name() == services.ProviderAlphaVantage
+	if useAlphaVantage {
+		if n, err := strconv.Atoi(os.Getenv("ALPHA_VANTAGE_CALLS_PER_MINUTE")); err == nil && n > 0 {
+			marketDataProvider.SetCallsPerMinute(n)
+		}
+		if n, err := strconv.Atoi(os.Getenv("ALPHA_VANTAGE_MAX_ATTEMPTS")); err == nil && n > 0 {
+			marketDataProvider.SetRetryPolicy(n, 0, 0)
+		}
+	}
+	marketDataProvider.SetLogger(logger)
+	logger.Info("Using market data provider", "provider", marketDataProvider.Name())
+	if v := os.Getenv("SYNC_LOCK_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			marketDataProvider.SetSyncLockTimeout(d)
+		} else {
+			logger.Warn("Ignoring invalid SYNC_LOCK_TIMEOUT", "value", v)
+		}
+	}
+	if v := os.Getenv("API_CALL_BODY_MAX_BYTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			marketDataProvider.SetMaxStoredBodyBytes(n)
+		} else {
+			logger.Warn("Ignoring invalid API_CALL_BODY_MAX_BYTES", "value", v)
+		}
+	}
+	
+	// Create background job queue; services register their job types on construction
+	jobWorkers := 4
+	if n, err := strconv.Atoi(os.Getenv("JOB_WORKERS")); err == nil && n > 0 {
+		jobWorkers = n
+	}
+	jobQueue := jobs.NewQueue(jobs.NewPostgresStore(db), jobWorkers)
+	
+	// Stock update events let WebSocket clients receive real data as soon as a sync saves it
+	stockEvents := services.NewStockEventBus()
+	
+	// Price range buckets are computed from the latest close; boundaries may be overridden
+	priceRanges := services.DefaultPriceRanges()
+	if v := os.Getenv("PRICE_RANGE_BOUNDARIES"); v != "" {
+		boundaries, err := services.ParsePriceRangeBoundaries(v)
+		if err == nil {
+			var configured *services.PriceRanges
+			if configured, err = services.NewPriceRanges(boundaries); err == nil {
+				priceRanges = configured
+			}
+		}
+		if err != nil {
+			logger.Warn("Ignoring invalid PRICE_RANGE_BOUNDARIES", "value", v, "error", err)
+		}
+	}
 	
 	// Create scheduler service with cache for invalidation
-	schedulerService := services.NewSchedulerService(db, alphaVantageClient, redisCache)
+	schedulerService := services.NewSchedulerService(db, marketDataProvider, redisCache, jobQueue, stockEvents)
+	schedulerService.SetReadRouter(readRouter)
+	schedulerService.SetLogger(logger)
+	schedulerService.SetPriceRanges(priceRanges)
+	if window := os.Getenv("MANUAL_SYNC_DEDUP_WINDOW"); window != "" {
+		if d, err := time.ParseDuration(window); err == nil && d >= 0 {
+			schedulerService.SetManualSyncDedupWindow(d)
+		} else {
+			logger.Warn("Ignoring invalid MANUAL_SYNC_DEDUP_WINDOW", "value", window)
+		}
+	}
+	marketCalendar := services.NewMarketCalendar()
+	if v := os.Getenv("SYNC_WINDOW"); v != "" {
+		start, end, err := services.ParseSyncWindow(v)
+		if err == nil {
+			err = marketCalendar.SetSyncWindow(start, end)
+		}
+		if err != nil {
+			logger.Warn("Ignoring invalid SYNC_WINDOW", "value", v, "error", err)
+		}
+	}
+	if n, err := marketCalendar.LoadHolidays(context.Background(), db); err != nil {
+		logger.Warn("Using built-in market holidays only", "error", err)
+	} else if n > 0 {
+		logger.Info("Loaded market holidays", "count", n)
+	}
+	schedulerService.SetMarketCalendar(marketCalendar)
+	if v := os.Getenv("SYNC_BATCH_PER_HOUR"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			schedulerService.SetSyncBatchPerHour(n)
+		} else {
+			logger.Warn("Ignoring invalid SYNC_BATCH_PER_HOUR", "value", v)
+		}
+	}
+	if v := os.Getenv("QUOTE_REFRESH_PER_HOUR"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			schedulerService.SetQuoteRefreshPerHour(n)
+		} else {
+			logger.Warn("Ignoring invalid QUOTE_REFRESH_PER_HOUR", "value", v)
+		}
+	}
+	syncWeights := services.DefaultSyncQueueWeights
+	for name, weight := range map[string]*float64{
+		"SYNC_WEIGHT_STALENESS":  &syncWeights.Staleness,
+		"SYNC_WEIGHT_SP500":      &syncWeights.SP500,
+		"SYNC_WEIGHT_MARKET_CAP": &syncWeights.MarketCap,
+		"SYNC_WEIGHT_GAPS":       &syncWeights.Gaps,
+	} {
+		if v := os.Getenv(name); v != "" {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				*weight = f
+			} else {
+				logger.Warn("Ignoring invalid "+name, "value", v)
+			}
+		}
+	}
+	if err := schedulerService.SetSyncQueueWeights(syncWeights); err != nil {
+		logger.Warn("Ignoring invalid sync queue weights", "error", err)
+	}
+	for name, setRetention := range map[string]func(time.Duration){
+		"INTRADAY_RETENTION_DAYS":     schedulerService.SetIntradayRetention,
+		"API_CALL_RETENTION_DAYS":     schedulerService.SetAPICallRetention,
+		"SYNC_HISTORY_RETENTION_DAYS": schedulerService.SetSyncHistoryRetention,
+	} {
+		// Unset or invalid leaves the default in place
+		if retention, err := services.RetentionDaysFromEnv(name, 0); err == nil {
+			setRetention(retention)
+		} else {
+			logger.Warn("Ignoring invalid "+name, "error", err)
+		}
+	}
 	
-	// Start scheduler if API key is configured
-	if apiKey != "" && apiKey != "your_api_key_here" {
+	// Start scheduler if the provider is usable; only Alpha Vantage needs an API key
+	if !useAlphaVantage || (apiKey != "" && apiKey != "your_api_key_here") {
 		if err := schedulerService.Start(); err != nil {
-			log.Printf("Failed to start scheduler: %v", err)
+			logger.Error("Failed to start scheduler", "error", err)
 		} else {
-			log.Println("Data synchronization scheduler started")
+			logger.Info("Data synchronization scheduler started")
 		}
 	}
 	
 	// Initialize database stock service with Redis cache
 	databaseStockService := services.NewDatabaseStockService(db, redisCache)
+	databaseStockService.SetReadRouter(readRouter)
+	databaseStockService.SetPriceRanges(priceRanges)
+	if v := os.Getenv("MAX_CHANGE_PERCENT"); v != "" {
+		if maxChange, err := strconv.ParseFloat(v, 64); err == nil {
+			databaseStockService.SetMaxChangePercent(maxChange)
+		} else {
+			logger.Warn("Ignoring invalid MAX_CHANGE_PERCENT", "value", v)
+		}
+	}
+	if v := os.Getenv("MARKET_CAP_THRESHOLDS"); v != "" {
+		if thresholds, err := services.ParseMarketCapThresholds(v); err == nil {
+			databaseStockService.SetMarketCapThresholds(thresholds)
+		} else {
+			logger.Warn("Ignoring invalid MARKET_CAP_THRESHOLDS", "value", v, "error", err)
+		}
+	}
+	if v := os.Getenv("QUERY_TIMEOUT"); v != "" {
+		if timeout, err := time.ParseDuration(v); err == nil && timeout >= 0 {
+			databaseStockService.SetQueryTimeout(timeout)
+		} else {
+			logger.Warn("Ignoring invalid QUERY_TIMEOUT", "value", v)
+		}
+	}
+	if v := os.Getenv("CACHE_STALE_GRACE"); v != "" {
+		if grace, err := time.ParseDuration(v); err == nil && grace >= 0 {
+			databaseStockService.SetStaleWhileRefresh(grace)
+		} else {
+			logger.Warn("Ignoring invalid CACHE_STALE_GRACE", "value", v)
+		}
+	}
 	
 	// Initialize historical data sync service
-	historicalDataSyncService := services.NewHistoricalDataSyncService(db, alphaVantageClient)
+	historicalDataSyncService := services.NewHistoricalDataSyncService(db, marketDataProvider, jobQueue)
+	historicalDataSyncService.SetReadRouter(readRouter)
+	historicalDataSyncService.SetPriceRanges(priceRanges)
+	historicalDataSyncService.SetCache(redisCache)
+	historicalDataSyncService.SetStockEvents(stockEvents)
+	
+	// Webhooks hear about finished batch syncs and scheduled jobs that keep failing
+	webhookService := services.NewWebhookService(db)
+	webhookDispatcher := services.NewWebhookDispatcher(webhookService)
+	webhookDispatcher.SetLogger(logger)
+	if n, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); err == nil && n > 0 {
+		webhookDispatcher.SetRetryPolicy(n, 0, 0)
+	}
+	failureThreshold := services.DefaultJobFailureThreshold
+	if v := os.Getenv("WEBHOOK_FAILURE_THRESHOLD"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			failureThreshold = n
+		} else {
+			logger.Warn("Ignoring invalid WEBHOOK_FAILURE_THRESHOLD", "value", v)
+		}
+	}
+	historicalDataSyncService.SetWebhookDispatcher(webhookDispatcher)
+	schedulerService.SetWebhookDispatcher(webhookDispatcher, failureThreshold)
+	
+	// Start the job queue, resuming any jobs interrupted by the last shutdown
+	if err := jobQueue.Start(); err != nil {
+		logger.Error("Failed to start job queue", "error", err)
+	}
 	
 	// Initialize handlers
-	databaseStockHandler := handlers.NewDatabaseStockHandler(databaseStockService)
-	wsHandler := handlers.NewWebSocketHandler(services.NewHybridStockService(databaseStockService))
-	systemHandler := handlers.NewSystemHandler(alphaVantageClient, schedulerService)
+	databaseStockHandler := handlers.NewDatabaseStockHandler(databaseStockService, databaseStockService)
+	databaseStockHandler.SetLogger(logger)
+	wsHandler := handlers.NewWebSocketHandler(services.NewHybridStockService(databaseStockService), stockEvents, logger)
+	systemHandler := handlers.NewSystemHandler(marketDataProvider, schedulerService, jobQueue)
+	healthChecker := services.NewHealthChecker(db, redisCache)
+	healthChecker.SetMarketCalendar(marketCalendar)
+	if v := os.Getenv("HEALTH_STALE_TRADING_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days > 0 {
+			healthChecker.SetStaleTradingDays(days)
+		} else {
+			logger.Warn("Ignoring invalid HEALTH_STALE_TRADING_DAYS", "value", v)
+		}
+	}
+	systemHandler.SetHealthChecker(healthChecker)
+	systemHandler.SetCache(redisCache)
 	syncHandler := handlers.NewHistoricalDataSyncHandler(historicalDataSyncService)
+	summaryHandler := handlers.NewStatusSummaryHandler(marketDataProvider, schedulerService, historicalDataSyncService, redisCache, wsHandler.GetConnectedClients)
+	watchlistHandler := handlers.NewWatchlistHandler(services.NewWatchlistService(db))
+	portfolioService := services.NewPortfolioService(db)
+	portfolioService.SetMarketCalendar(marketCalendar)
+	portfolioHandler := handlers.NewPortfolioHandler(portfolioService)
+	adminHandler := handlers.NewAdminHandler(marketDataProvider, services.NewStockListingService(db, redisCache))
 
-	// Initialize router
-	r := gin.Default()
-
-	// CORS middleware
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:3001"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-	}))
-
-	// Health check endpoint
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":           "ok",
-			"service":          "stock-intelligence-backend",
-			"websocket_clients": wsHandler.GetConnectedClients(),
-		})
-	})
-
-	// WebSocket endpoint
-	r.GET("/ws", wsHandler.HandleWebSocket)
-
-	// API v1 routes
-	v1 := r.Group("/api/v1")
-	{
-		// Stock endpoints
-		stocks := v1.Group("/stocks")
-		{
-			stocks.GET("", databaseStockHandler.GetAllStocks)
-			stocks.GET("/:symbol", databaseStockHandler.GetStockBySymbol)
-			stocks.GET("/:symbol/performance", databaseStockHandler.GetStockHistoricalPerformance)
-			stocks.GET("/price-range", databaseStockHandler.GetStocksByPriceRange)
-		}
-
-		// Market data endpoints
-		market := v1.Group("/market")
-		{
-			market.GET("/performance", databaseStockHandler.GetPerformanceData)
-			market.GET("/overview", databaseStockHandler.GetMarketOverview)
-			market.GET("/sectors", databaseStockHandler.GetSectors)
-			market.GET("/data-source", databaseStockHandler.GetDataSourceInfo)
-		}
-		
-		// System monitoring endpoints
-		system := v1.Group("/system")
-		{
-			system.GET("/health", systemHandler.GetSystemHealth)
-			system.GET("/api-status", systemHandler.GetAPIStatus)
-			system.GET("/sync-status", systemHandler.GetDataSyncStatus)
-			system.GET("/api-history", systemHandler.GetAPICallHistory)
-			system.POST("/sync/:symbol", systemHandler.TriggerManualSync)
-		}
-		
-		// Historical data sync endpoints
-		sync := v1.Group("/sync")
-		{
-			sync.POST("/batch", syncHandler.TriggerBatchSync)
-			sync.GET("/status", syncHandler.GetSyncStatus)
-			sync.GET("/pending", syncHandler.GetPendingStocks)
+	// Per-IP rate limit on the API, shared between servers through Redis when it is up
+	var rateLimit gin.HandlerFunc
+	if httpConfig.RateLimitPerMinute > 0 {
+		var store ratelimit.Store = ratelimit.NewMemoryStore()
+		if redisCache != nil {
+			store = redisCache
 		}
+		rateLimit = ratelimit.NewLimiter(store, httpConfig.RateLimitPerMinute, logger).Middleware()
+	}
+
+	// Whole stock and market responses are cached in Redis until the next sync
+	var responseCache gin.HandlerFunc
+	if redisCache != nil && httpConfig.ResponseCacheTTL > 0 {
+		responseCache = responsecache.New(redisCache, httpConfig.ResponseCacheTTL, logger).Middleware()
+	}
+
+	// Initialize router
+	r, err := newRouter(routeHandlers{
+		stocks:     databaseStockHandler,
+		graphql:    handlers.NewGraphQLHandler(databaseStockService, databaseStockService),
+		ws:         wsHandler,
+		system:     systemHandler,
+		sync:       syncHandler,
+		summary:    summaryHandler,
+		watchlists: watchlistHandler,
+		portfolios: portfolioHandler,
+		admin:      adminHandler,
+		webhooks:   handlers.NewWebhookHandler(webhookService, webhookDispatcher),
+		docs:       handlers.NewOpenAPIHandler(priceRanges, auth.APIKeyHeader),
+
+		requireAPIKey: auth.RequireAPIKey(auth.NewAPIKeyStore(db)),
+		rateLimit:     rateLimit,
+		responseCache: responseCache,
+	}, httpConfig, logger)
+	if err != nil {
+		logging.Fatal(logger, "Failed to configure router", "error", err)
 	}
 
 	// Start server
@@ -145,9 +358,17 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("Starting server on port %s", port)
-	log.Println("Database-only mode: Using database as primary data source")
-	log.Printf("Stock data service ready with %d stocks", len(databaseStockService.GetAllStocks()))
+	logger.Info("Starting server", "port", port)
+	logger.Info("Database-only mode: Using database as primary data source")
+	logger.Info("Stock data service ready", "stocks", len(databaseStockService.GetAllStocks(context.Background())))
+	if warm, _ := strconv.ParseBool(os.Getenv("CACHE_WARM_ON_START")); warm && redisCache != nil {
+		// Warming runs alongside the server; a failure only leaves part of the cache cold
+		go func() {
+			if err := databaseStockService.WarmCache(context.Background()); err != nil {
+				logger.Warn("Cache warming incomplete", "error", err)
+			}
+		}()
+	}
 	
 	// Setup graceful shutdown
 	c := make(chan os.Signal, 1)
@@ -155,13 +376,14 @@ func main() {
 	
 	go func() {
 		<-c
-		log.Println("Shutting down gracefully...")
+		logger.Info("Shutting down gracefully")
 		schedulerService.Stop()
+		jobQueue.Stop()
 		db.Close()
 		os.Exit(0)
 	}()
 	
 	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+		logging.Fatal(logger, "Failed to start server", "error", err)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- Migration: 006_api_call_initiator
+-- Description: Attribute external API calls to the component that made them
+
+ALTER TABLE api_calls ADD COLUMN IF NOT EXISTS initiated_by VARCHAR(32) NOT NULL DEFAULT 'unknown';
+
+CREATE INDEX IF NOT EXISTS idx_api_calls_initiator_created ON api_calls(initiated_by, created_at DESC);
+
+COMMENT ON COLUMN api_calls.initiated_by IS 'Component that spent the call: scheduler, manual_sync, batch_sync, tasks_cli, data_fetcher or seed';
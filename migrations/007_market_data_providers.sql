@@ -0,0 +1,9 @@
+-- Migration: 007_market_data_providers
+-- Description: Track rate limits for each market data provider by service name
+
+-- Stooq publishes no quota; this ceiling keeps a runaway sync polite
+INSERT INTO api_rate_limits (service_name, daily_limit, hourly_limit)
+VALUES ('stooq', 500, 100)
+ON CONFLICT (service_name) DO NOTHING;
+
+COMMENT ON COLUMN api_rate_limits.service_name IS 'Market data provider name, matching MARKET_DATA_PROVIDER: alphavantage or stooq';
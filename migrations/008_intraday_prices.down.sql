@@ -0,0 +1,4 @@
+-- Migration: 008_intraday_prices (down)
+-- Description: Drop stored intraday bars
+
+DROP TABLE IF EXISTS intraday_prices;
@@ -0,0 +1,22 @@
+-- Migration: 008_intraday_prices
+-- Description: Store intraday OHLCV bars fetched from TIME_SERIES_INTRADAY
+
+CREATE TABLE IF NOT EXISTS intraday_prices (
+    id BIGSERIAL PRIMARY KEY,
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    bar_interval VARCHAR(8) NOT NULL,
+    "timestamp" TIMESTAMP NOT NULL,
+    open_price NUMERIC(12,4) NOT NULL,
+    high_price NUMERIC(12,4) NOT NULL,
+    low_price NUMERIC(12,4) NOT NULL,
+    close_price NUMERIC(12,4) NOT NULL,
+    volume BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(stock_id, bar_interval, "timestamp")
+);
+
+-- Retention cleanup deletes by age across all stocks
+CREATE INDEX IF NOT EXISTS idx_intraday_prices_timestamp ON intraday_prices("timestamp");
+
+COMMENT ON TABLE intraday_prices IS 'Intraday bars per stock and interval, kept for a limited retention window';
+COMMENT ON COLUMN intraday_prices."timestamp" IS 'Bar start time in exchange local time (US/Eastern), as reported by Alpha Vantage';
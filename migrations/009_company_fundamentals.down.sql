@@ -0,0 +1,4 @@
+-- Migration: 009_company_fundamentals (down)
+-- Description: Drop stored company fundamentals
+
+DROP TABLE IF EXISTS company_fundamentals;
@@ -0,0 +1,18 @@
+-- Migration: 009_company_fundamentals
+-- Description: Store company fundamentals fetched from the Alpha Vantage OVERVIEW endpoint
+
+CREATE TABLE IF NOT EXISTS company_fundamentals (
+    stock_id INTEGER PRIMARY KEY REFERENCES stocks(id) ON DELETE CASCADE,
+    pe_ratio NUMERIC(14,4),
+    eps NUMERIC(14,4),
+    dividend_yield NUMERIC(10,6),
+    beta NUMERIC(10,4),
+    shares_outstanding BIGINT,
+    description TEXT,
+    fetched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The scheduler refreshes the stock whose fundamentals are oldest
+CREATE INDEX IF NOT EXISTS idx_company_fundamentals_fetched_at ON company_fundamentals(fetched_at);
+
+COMMENT ON TABLE company_fundamentals IS 'Latest company overview per stock; served even when stale, with fetched_at';
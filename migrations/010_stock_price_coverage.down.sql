@@ -0,0 +1,6 @@
+-- Migration: 010_stock_price_coverage (down)
+-- Description: Stop tracking daily price coverage per stock
+
+DROP INDEX IF EXISTS idx_stocks_price_coverage;
+ALTER TABLE stocks DROP COLUMN IF EXISTS last_price_date;
+ALTER TABLE stocks DROP COLUMN IF EXISTS first_price_date;
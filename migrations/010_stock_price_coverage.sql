@@ -0,0 +1,22 @@
+-- Migration: 010_stock_price_coverage
+-- Description: Track the oldest and newest daily price date per stock
+
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS first_price_date DATE;
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS last_price_date DATE;
+
+-- Backfill from prices already stored; new saves keep the columns current
+UPDATE stocks s
+SET first_price_date = coverage.first_date,
+    last_price_date = coverage.last_date
+FROM (
+    SELECT stock_id, MIN(date) AS first_date, MAX(date) AS last_date
+    FROM daily_prices
+    GROUP BY stock_id
+) coverage
+WHERE coverage.stock_id = s.id;
+
+-- Supports ?min_history_days filters on the stock list
+CREATE INDEX IF NOT EXISTS idx_stocks_price_coverage ON stocks((last_price_date - first_price_date)) WHERE is_active = true;
+
+COMMENT ON COLUMN stocks.first_price_date IS 'Oldest daily_prices date for the stock, maintained by the save path';
+COMMENT ON COLUMN stocks.last_price_date IS 'Newest daily_prices date for the stock, maintained by the save path';
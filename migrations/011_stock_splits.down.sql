@@ -0,0 +1,4 @@
+-- Migration: 011_stock_splits (down)
+-- Description: Drop recorded stock splits
+
+DROP TABLE IF EXISTS stock_splits;
@@ -0,0 +1,13 @@
+-- Migration: 011_stock_splits
+-- Description: Record stock splits reported by TIME_SERIES_DAILY_ADJUSTED
+
+CREATE TABLE IF NOT EXISTS stock_splits (
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    split_date DATE NOT NULL,
+    ratio NUMERIC(12,6) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (stock_id, split_date)
+);
+
+COMMENT ON TABLE stock_splits IS 'Splits per stock; ratio is new shares per old share, e.g. 4 for a 4:1 split';
+COMMENT ON COLUMN daily_prices.adjusted_close IS 'Split- and dividend-adjusted close; equals close_price until an adjusted fetch stores the adjustment';
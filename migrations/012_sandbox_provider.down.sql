@@ -0,0 +1,4 @@
+-- Migration: 012_sandbox_provider (down)
+-- Description: Remove the sandbox provider's rate limit row
+
+DELETE FROM api_rate_limits WHERE service_name = 'sandbox';
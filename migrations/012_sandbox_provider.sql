@@ -0,0 +1,9 @@
+-- Migration: 012_sandbox_provider
+-- Description: Rate limit row for the offline sandbox market data provider
+
+-- Generous enough for end-to-end runs while still exercising the budget checks
+INSERT INTO api_rate_limits (service_name, daily_limit, hourly_limit)
+VALUES ('sandbox', 10000, 1000)
+ON CONFLICT (service_name) DO NOTHING;
+
+COMMENT ON COLUMN api_rate_limits.service_name IS 'Market data provider name, matching MARKET_DATA_PROVIDER: alphavantage, stooq or sandbox';
@@ -0,0 +1,5 @@
+-- Migration: 013_watchlists (down)
+-- Description: Drop watchlists and their items
+
+DROP TABLE IF EXISTS watchlist_items;
+DROP TABLE IF EXISTS watchlists;
@@ -0,0 +1,27 @@
+-- Migration: 013_watchlists
+-- Description: Named watchlists of stocks
+
+CREATE TABLE IF NOT EXISTS watchlists (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+DROP TRIGGER IF EXISTS update_watchlists_updated_at ON watchlists;
+CREATE TRIGGER update_watchlists_updated_at
+    BEFORE UPDATE ON watchlists
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- A stock appears at most once per watchlist, so adding it again is a no-op
+CREATE TABLE IF NOT EXISTS watchlist_items (
+    watchlist_id INTEGER NOT NULL REFERENCES watchlists(id) ON DELETE CASCADE,
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    added_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (watchlist_id, stock_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_watchlist_items_stock_id ON watchlist_items(stock_id);
+
+COMMENT ON TABLE watchlist_items IS 'Stocks on each watchlist; prices are joined from daily_prices when read';
@@ -0,0 +1,4 @@
+-- Migration: 014_api_keys (down)
+-- Description: Drop API keys; the write and admin endpoints reject every request until keys are created again
+
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,14 @@
+-- Migration: 014_api_keys
+-- Description: API keys for the write and admin endpoints, stored as SHA-256 hashes
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    key_hash CHAR(64) UNIQUE NOT NULL,
+    key_prefix VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP
+);
+
+COMMENT ON TABLE api_keys IS 'Keys accepted in the X-API-Key header; the raw key is shown once when created and never stored';
+COMMENT ON COLUMN api_keys.key_prefix IS 'Leading characters of the raw key, to tell keys apart in listings and logs';
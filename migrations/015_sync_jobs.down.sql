@@ -0,0 +1,4 @@
+-- Migration: 015_sync_jobs (down)
+-- Description: Drop batch sync progress tracking
+
+DROP TABLE IF EXISTS sync_jobs;
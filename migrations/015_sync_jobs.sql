@@ -0,0 +1,31 @@
+-- Migration: 015_sync_jobs
+-- Description: Track progress of background batch syncs, one active at a time
+
+CREATE TABLE IF NOT EXISTS sync_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    job_id BIGINT REFERENCES jobs(id) ON DELETE SET NULL,
+    max_stocks INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    attempted INTEGER NOT NULL DEFAULT 0,
+    successful INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    message TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+DROP TRIGGER IF EXISTS update_sync_jobs_updated_at ON sync_jobs;
+CREATE TRIGGER update_sync_jobs_updated_at
+    BEFORE UPDATE ON sync_jobs
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Every queued or running row has the same key, so a second one is refused
+-- even when two servers start a batch at the same moment
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sync_jobs_one_active
+    ON sync_jobs ((status IN ('queued', 'running')))
+    WHERE status IN ('queued', 'running');
+
+COMMENT ON TABLE sync_jobs IS 'Progress of batch syncs run on the job queue; polled through /api/v1/sync/jobs/:id';
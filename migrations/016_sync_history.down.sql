@@ -0,0 +1,4 @@
+-- Migration: 016_sync_history (down)
+-- Description: Drop per-stock sync history
+
+DROP TABLE IF EXISTS sync_history;
@@ -0,0 +1,19 @@
+-- Migration: 016_sync_history
+-- Description: Keep the outcome of every stock synced by the scheduler or a batch
+
+CREATE TABLE IF NOT EXISTS sync_history (
+    id BIGSERIAL PRIMARY KEY,
+    symbol VARCHAR(10) NOT NULL,
+    success BOOLEAN NOT NULL,
+    error_kind VARCHAR(30),
+    error_message TEXT,
+    records_added INTEGER NOT NULL DEFAULT 0,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    triggered_by VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_history_created_at ON sync_history(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sync_history_symbol_created_at ON sync_history(symbol, created_at DESC);
+
+COMMENT ON TABLE sync_history IS 'Per-stock sync outcomes; the daily cleanup prunes rows older than 90 days';
@@ -0,0 +1,4 @@
+-- Migration: 017_market_holidays (down)
+-- Description: Drop the market closures table
+
+DROP TABLE IF EXISTS market_holidays;
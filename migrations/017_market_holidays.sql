@@ -0,0 +1,8 @@
+-- Migration: 017_market_holidays
+-- Description: Market closures beyond the NYSE holidays built into the scheduler's calendar
+
+CREATE TABLE IF NOT EXISTS market_holidays (
+    date DATE PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,4 @@
+-- Migration: 018_scheduler_runs (down)
+-- Description: Drop scheduled job run history
+
+DROP TABLE IF EXISTS scheduler_runs;
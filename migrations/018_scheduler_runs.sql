@@ -0,0 +1,17 @@
+-- Migration: 018_scheduler_runs
+-- Description: Keep the outcome of every scheduled job run so it survives restarts
+
+CREATE TABLE IF NOT EXISTS scheduler_runs (
+    id BIGSERIAL PRIMARY KEY,
+    job_name VARCHAR(50) NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    detail TEXT,
+    symbol VARCHAR(10)
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduler_runs_job_started_at ON scheduler_runs(job_name, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_scheduler_runs_started_at ON scheduler_runs(started_at DESC);
+
+COMMENT ON TABLE scheduler_runs IS 'Scheduled job runs; the daily cleanup prunes rows older than 30 days';
@@ -0,0 +1,4 @@
+-- Migration: 019_system_settings (down)
+-- Description: Drop runtime settings
+
+DROP TABLE IF EXISTS system_settings;
@@ -0,0 +1,8 @@
+-- Migration: 019_system_settings
+-- Description: Runtime switches that survive restarts, such as pausing the scheduler
+
+CREATE TABLE IF NOT EXISTS system_settings (
+    key VARCHAR(100) PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
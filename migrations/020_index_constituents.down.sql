@@ -0,0 +1,4 @@
+-- Migration: 020_index_constituents (down)
+-- Description: Drop imported index constituents
+
+DROP TABLE IF EXISTS index_constituents;
@@ -0,0 +1,14 @@
+-- Migration: 020_index_constituents
+-- Description: Index membership and sync priority, imported from constituent lists
+
+CREATE TABLE IF NOT EXISTS index_constituents (
+    index_name VARCHAR(20) NOT NULL,
+    symbol VARCHAR(10) NOT NULL,
+    priority INTEGER NOT NULL CHECK (priority > 0),
+    weight DECIMAL(10,6),
+    as_of_date DATE NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (index_name, symbol)
+);
+
+CREATE INDEX IF NOT EXISTS idx_index_constituents_priority ON index_constituents(index_name, priority);
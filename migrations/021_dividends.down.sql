@@ -0,0 +1,4 @@
+-- Migration: 021_dividends (down)
+-- Description: Drop recorded dividends
+
+DROP TABLE IF EXISTS dividends;
@@ -0,0 +1,12 @@
+-- Migration: 021_dividends
+-- Description: Record cash dividends reported by TIME_SERIES_DAILY_ADJUSTED
+
+CREATE TABLE IF NOT EXISTS dividends (
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    ex_date DATE NOT NULL,
+    amount NUMERIC(12,6) NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (stock_id, ex_date)
+);
+
+COMMENT ON TABLE dividends IS 'Cash dividends per share by ex-dividend date; days without a dividend are not stored';
@@ -0,0 +1,4 @@
+-- Migration: 022_stock_region (down)
+-- Description: Drop the stock region
+
+ALTER TABLE stocks DROP COLUMN IF EXISTS region;
@@ -0,0 +1,6 @@
+-- Migration: 022_stock_region
+-- Description: Record the market region of stocks added through symbol search
+
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS region VARCHAR(50);
+
+COMMENT ON COLUMN stocks.region IS 'Market region reported by the symbol search the stock was added from, e.g. United States';
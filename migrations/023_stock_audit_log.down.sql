@@ -0,0 +1,4 @@
+-- Migration: 023_stock_audit_log (down)
+-- Description: Drop the stock audit log
+
+DROP TABLE IF EXISTS stock_audit_log;
@@ -0,0 +1,15 @@
+-- Migration: 023_stock_audit_log
+-- Description: Record admin changes to stock metadata with their before and after values
+
+CREATE TABLE IF NOT EXISTS stock_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    symbol VARCHAR(10) NOT NULL,
+    before JSONB NOT NULL,
+    after JSONB NOT NULL,
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_audit_log_stock ON stock_audit_log(stock_id, changed_at DESC);
+
+COMMENT ON TABLE stock_audit_log IS 'One row per admin edit of a stock; before and after hold only the fields that changed';
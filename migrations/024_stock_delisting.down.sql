@@ -0,0 +1,4 @@
+-- Migration: 024_stock_delisting (down)
+-- Description: Drop the delisting mark
+
+ALTER TABLE stocks DROP COLUMN IF EXISTS delisted_at;
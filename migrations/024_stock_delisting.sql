@@ -0,0 +1,6 @@
+-- Migration: 024_stock_delisting
+-- Description: Mark stocks whose symbol the market data provider no longer serves
+
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS delisted_at TIMESTAMP;
+
+COMMENT ON COLUMN stocks.delisted_at IS 'When repeated invalid symbol or no data sync failures marked the stock delisted; delisted stocks are inactive but still readable by symbol';
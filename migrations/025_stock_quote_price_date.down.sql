@@ -0,0 +1,5 @@
+-- Migration: 025_stock_quote_price_date (down)
+-- Description: Drop the intra-day quote tracking
+
+ALTER TABLE stocks DROP COLUMN IF EXISTS quote_refreshed_at;
+ALTER TABLE stocks DROP COLUMN IF EXISTS quote_price_date;
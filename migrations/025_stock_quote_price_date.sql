@@ -0,0 +1,8 @@
+-- Migration: 025_stock_quote_price_date
+-- Description: Track daily prices written from intra-day quotes until a full sync replaces them
+
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS quote_price_date DATE;
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS quote_refreshed_at TIMESTAMP;
+
+COMMENT ON COLUMN stocks.quote_price_date IS 'Date of the daily_prices row last written from an intra-day quote; cleared once a full sync stores that date, until then the scheduled sync treats the stock as stale';
+COMMENT ON COLUMN stocks.quote_refreshed_at IS 'When the quote refresh last updated the stock, so each run picks the stocks refreshed longest ago';
@@ -0,0 +1,5 @@
+-- Migration: 026_stock_news (down)
+-- Description: Drop stored news
+
+ALTER TABLE stocks DROP COLUMN IF EXISTS news_fetched_at;
+DROP TABLE IF EXISTS stock_news;
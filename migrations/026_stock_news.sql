@@ -0,0 +1,23 @@
+-- Migration: 026_stock_news
+-- Description: Store news articles about each stock with their sentiment from NEWS_SENTIMENT
+
+CREATE TABLE IF NOT EXISTS stock_news (
+    id BIGSERIAL PRIMARY KEY,
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    url TEXT NOT NULL,
+    source VARCHAR(255),
+    published_at TIMESTAMP NOT NULL,
+    sentiment_score NUMERIC(6,4) NOT NULL,
+    sentiment_label VARCHAR(30) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (stock_id, url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_news_stock_published ON stock_news(stock_id, published_at DESC);
+
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS news_fetched_at TIMESTAMP;
+
+COMMENT ON TABLE stock_news IS 'News articles mentioning a stock; an article fetched again is not stored twice';
+COMMENT ON COLUMN stock_news.sentiment_score IS 'Sentiment towards this stock from -1 (bearish) to 1 (bullish), or the article''s overall sentiment when not scored per ticker';
+COMMENT ON COLUMN stocks.news_fetched_at IS 'When the news refresh last fetched the stock''s articles, so each run picks the stock fetched longest ago';
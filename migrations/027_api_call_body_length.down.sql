@@ -0,0 +1,4 @@
+-- Migration: 027_api_call_body_length (down)
+-- Description: Stop recording response body lengths; cut bodies stay cut
+
+ALTER TABLE api_calls DROP COLUMN IF EXISTS response_body_length;
@@ -0,0 +1,16 @@
+-- Migration: 027_api_call_body_length
+-- Description: Record the whole length of provider response bodies, which are now stored cut to a maximum
+
+ALTER TABLE api_calls ADD COLUMN IF NOT EXISTS response_body_length INTEGER;
+
+-- Cut the bodies already stored to the default maximum; the space returns to
+-- the database once the table is vacuumed
+UPDATE api_calls
+SET response_body_length = octet_length(response_body),
+    response_body = left(response_body, 2048)
+WHERE octet_length(response_body) > 2048;
+
+-- Listing a provider's calls newest first; 001 creates this on new databases
+CREATE INDEX IF NOT EXISTS idx_api_calls_service_created ON api_calls(service_name, created_at DESC);
+
+COMMENT ON COLUMN api_calls.response_body_length IS 'Size in bytes of the whole response body, of which response_body keeps the start; NULL for calls logged before it was recorded';
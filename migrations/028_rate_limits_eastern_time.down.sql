@@ -0,0 +1,29 @@
+-- Migration: 028_rate_limits_eastern_time (down)
+-- Description: Roll API rate limit counters over on the database server's day and hour again
+
+CREATE OR REPLACE FUNCTION update_api_rate_limit()
+RETURNS TRIGGER AS $$
+BEGIN
+    -- Reset daily count if date changed
+    IF NEW.last_reset_date < CURRENT_DATE THEN
+        NEW.current_daily_count = 0;
+        NEW.current_hourly_count = 0;
+        NEW.last_reset_date = CURRENT_DATE;
+        NEW.last_reset_hour = EXTRACT(HOUR FROM CURRENT_TIMESTAMP);
+    -- Reset hourly count if hour changed
+    ELSIF NEW.last_reset_hour < EXTRACT(HOUR FROM CURRENT_TIMESTAMP) THEN
+        NEW.current_hourly_count = 0;
+        NEW.last_reset_hour = EXTRACT(HOUR FROM CURRENT_TIMESTAMP);
+    END IF;
+
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+ALTER TABLE api_rate_limits
+    ALTER COLUMN last_reset_date SET DEFAULT CURRENT_DATE,
+    ALTER COLUMN last_reset_hour SET DEFAULT EXTRACT(HOUR FROM CURRENT_TIMESTAMP);
+
+COMMENT ON COLUMN api_rate_limits.last_reset_date IS NULL;
+COMMENT ON COLUMN api_rate_limits.last_reset_hour IS NULL;
@@ -0,0 +1,34 @@
+-- Migration: 028_rate_limits_eastern_time
+-- Description: Roll API rate limit counters over on the Eastern-time day and hour, as the application records them
+
+-- The application writes last_reset_date and last_reset_hour in Eastern time.
+-- Comparing them with the server's CURRENT_DATE reset the counts between
+-- midnight UTC and midnight Eastern.
+CREATE OR REPLACE FUNCTION update_api_rate_limit()
+RETURNS TRIGGER AS $$
+DECLARE
+    market_now TIMESTAMP := CURRENT_TIMESTAMP AT TIME ZONE 'America/New_York';
+BEGIN
+    -- Reset daily count if date changed
+    IF NEW.last_reset_date < market_now::date THEN
+        NEW.current_daily_count = 0;
+        NEW.current_hourly_count = 0;
+        NEW.last_reset_date = market_now::date;
+        NEW.last_reset_hour = EXTRACT(HOUR FROM market_now);
+    -- Reset hourly count if hour changed
+    ELSIF NEW.last_reset_hour < EXTRACT(HOUR FROM market_now) THEN
+        NEW.current_hourly_count = 0;
+        NEW.last_reset_hour = EXTRACT(HOUR FROM market_now);
+    END IF;
+
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+ALTER TABLE api_rate_limits
+    ALTER COLUMN last_reset_date SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'America/New_York')::date,
+    ALTER COLUMN last_reset_hour SET DEFAULT EXTRACT(HOUR FROM CURRENT_TIMESTAMP AT TIME ZONE 'America/New_York');
+
+COMMENT ON COLUMN api_rate_limits.last_reset_date IS 'Eastern-time date the counters were last written';
+COMMENT ON COLUMN api_rate_limits.last_reset_hour IS 'Eastern-time hour the counters were last written';
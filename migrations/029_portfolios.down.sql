@@ -0,0 +1,5 @@
+-- Migration: 029_portfolios (down)
+-- Description: Drop portfolios and their transactions
+
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS portfolios;
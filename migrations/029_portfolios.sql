@@ -0,0 +1,32 @@
+-- Migration: 029_portfolios
+-- Description: Portfolios and the buys and sells recorded in them
+
+CREATE TABLE IF NOT EXISTS portfolios (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+DROP TRIGGER IF EXISTS update_portfolios_updated_at ON portfolios;
+CREATE TRIGGER update_portfolios_updated_at
+    BEFORE UPDATE ON portfolios
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Positions are not stored: they are worked out from the transactions, lot by
+-- lot in the order executed, each time a summary is read
+CREATE TABLE IF NOT EXISTS transactions (
+    id SERIAL PRIMARY KEY,
+    portfolio_id INTEGER NOT NULL REFERENCES portfolios(id) ON DELETE CASCADE,
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE RESTRICT,
+    side VARCHAR(4) NOT NULL CHECK (side IN ('buy', 'sell')),
+    quantity NUMERIC(18,6) NOT NULL CHECK (quantity > 0),
+    price NUMERIC(12,4) NOT NULL CHECK (price > 0),
+    executed_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_portfolio_stock ON transactions(portfolio_id, stock_id, executed_at, id);
+
+COMMENT ON TABLE transactions IS 'Buys and sells of stocks in each portfolio; positions and P&L are computed from them first in, first out';
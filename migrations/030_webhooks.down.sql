@@ -0,0 +1,4 @@
+-- Migration: 030_webhooks (down)
+-- Description: Drop webhooks
+
+DROP TABLE IF EXISTS webhooks;
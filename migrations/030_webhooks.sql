@@ -0,0 +1,21 @@
+-- Migration: 030_webhooks
+-- Description: Webhooks notified of batch sync completion and failing scheduled jobs
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    -- Signs each payload with HMAC-SHA256, so receivers can check it came from us
+    secret VARCHAR(128) NOT NULL,
+    events TEXT[] NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+DROP TRIGGER IF EXISTS update_webhooks_updated_at ON webhooks;
+CREATE TRIGGER update_webhooks_updated_at
+    BEFORE UPDATE ON webhooks
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON TABLE webhooks IS 'HTTP endpoints sent a signed JSON POST for each subscribed event';
@@ -0,0 +1,4 @@
+-- Migration: 031_fetch_runs (down)
+-- Description: Drop fetch_runs
+
+DROP TABLE IF EXISTS fetch_runs;
@@ -0,0 +1,20 @@
+-- Migration: 031_fetch_runs
+-- Description: Checkpoint data fetcher runs so an interrupted run resumes where it stopped
+
+CREATE TABLE IF NOT EXISTS fetch_runs (
+    id BIGSERIAL PRIMARY KEY,
+    -- Eastern-time day the run was planned; only a run from today is resumed
+    run_date DATE NOT NULL,
+    -- Every stock the run planned to fetch, in priority order
+    symbols TEXT[] NOT NULL,
+    -- Outcome of each symbol fetched so far, succeeded or failed; the rest are pending
+    statuses JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'running',
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_fetch_runs_status_run_date ON fetch_runs(status, run_date DESC);
+
+COMMENT ON TABLE fetch_runs IS 'Data fetcher runs: running, completed, or abandoned when a fresh plan replaced them';
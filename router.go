@@ -0,0 +1,199 @@
+package main
+
+import (
+	"log/slog"
+
+	"stock-intelligence-backend/internal/auth"
+	"stock-intelligence-backend/internal/compression"
+	"stock-intelligence-backend/internal/config"
+	"stock-intelligence-backend/internal/handlers"
+	"stock-intelligence-backend/internal/logging"
+	"stock-intelligence-backend/internal/ratelimit"
+	"stock-intelligence-backend/internal/responsecache"
+	"stock-intelligence-backend/internal/version"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// routeHandlers are the handlers newRouter mounts; requireAPIKey guards the
+// /system and /admin groups and every route that changes data. When set,
+// rateLimit limits every /api/v1 route and responseCache caches the stock
+// and market routes.
+type routeHandlers struct {
+	stocks     *handlers.DatabaseStockHandler
+	graphql    *handlers.GraphQLHandler
+	ws         *handlers.WebSocketHandler
+	system     *handlers.SystemHandler
+	sync       *handlers.HistoricalDataSyncHandler
+	summary    *handlers.StatusSummaryHandler
+	watchlists *handlers.WatchlistHandler
+	portfolios *handlers.PortfolioHandler
+	admin      *handlers.AdminHandler
+	webhooks   *handlers.WebhookHandler
+	docs       *handlers.OpenAPIHandler
+
+	requireAPIKey gin.HandlerFunc
+	rateLimit     gin.HandlerFunc
+	responseCache gin.HandlerFunc
+}
+
+// newRouter wires the HTTP routes; the server and the e2e tests share it.
+// Each request gets an ID and is logged as one structured record through logger.
+func newRouter(h routeHandlers, httpConfig *config.HTTPConfig, logger *slog.Logger) (*gin.Engine, error) {
+	r := gin.New()
+	r.Use(logging.AssignRequestID(), logging.RequestLogger(logger), handlers.Recovery())
+	r.NoRoute(handlers.NotFound)
+
+	// Only listed proxies may set the client IP through X-Forwarded-For
+	if err := r.SetTrustedProxies(httpConfig.TrustedProxies); err != nil {
+		return nil, err
+	}
+
+	// CORS middleware
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     httpConfig.AllowedOrigins,
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", logging.RequestIDHeader, auth.APIKeyHeader},
+		ExposeHeaders:    []string{"Content-Length", logging.RequestIDHeader, ratelimit.LimitHeader, ratelimit.RemainingHeader, "Retry-After", "Age", responsecache.StatusHeader},
+		AllowCredentials: httpConfig.AllowCredentials,
+	}))
+
+	// After CORS, whose Vary: Origin would replace the gzip Vary header
+	r.Use(compression.Gzip(httpConfig.GzipMinSize))
+
+	// Health check endpoint
+	r.GET("/health", func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"status":            "ok",
+			"service":           "stock-intelligence-backend",
+			"websocket_clients": h.ws.GetConnectedClients(),
+			"sse_clients":       h.ws.GetStreamClients(),
+			"build":             version.Get(),
+		})
+	})
+
+	// WebSocket endpoint
+	r.GET("/ws", h.ws.HandleWebSocket)
+
+	// API v1 routes; /health and /ws above are outside the rate limit
+	v1 := r.Group("/api/v1")
+	if h.rateLimit != nil {
+		v1.Use(h.rateLimit)
+	}
+	var cached []gin.HandlerFunc
+	if h.responseCache != nil {
+		cached = append(cached, h.responseCache)
+	}
+	{
+		// API description; TestRouter_OpenAPIDocumentCoversEveryRoute keeps it in step with the routes below
+		v1.GET("/openapi.json", h.docs.GetSpec)
+		v1.GET("/docs", h.docs.GetDocs)
+
+		// Server-Sent Events alternative to /ws
+		v1.GET("/stream/prices", h.ws.HandlePriceStream)
+
+		// GraphQL over the stock and market reads, for clients that would otherwise make several requests.
+		// Like POST /stocks/quotes it only reads, taking its query in the body, so it needs no API key.
+		v1.POST("/graphql", h.graphql.ServeGraphQL)
+
+		// Stock endpoints
+		stocks := v1.Group("/stocks", cached...)
+		{
+			stocks.GET("", h.stocks.GetAllStocks)
+			stocks.GET("/:symbol", h.stocks.GetStockBySymbol)
+			stocks.GET("/:symbol/performance", h.stocks.GetStockHistoricalPerformance)
+			stocks.GET("/:symbol/beta", h.stocks.GetStockBeta)
+			stocks.GET("/:symbol/prices", h.stocks.GetStockPrices)
+			stocks.GET("/:symbol/dividends", h.stocks.GetStockDividends)
+			stocks.GET("/:symbol/news", h.stocks.GetStockNews)
+			stocks.GET("/:symbol/intraday", h.stocks.GetStockIntraday)
+			stocks.GET("/:symbol/fundamentals", h.stocks.GetStockFundamentals)
+			stocks.GET("/price-range", h.stocks.GetStocksByPriceRange)
+			stocks.POST("/quotes", h.stocks.GetQuotes)
+			stocks.GET("/correlation", h.stocks.GetCorrelation)
+		}
+
+		// Market data endpoints
+		market := v1.Group("/market", cached...)
+		{
+			market.GET("/performance", h.stocks.GetPerformanceData)
+			market.GET("/overview", h.stocks.GetMarketOverview)
+			market.GET("/heatmap", h.stocks.GetMarketHeatmap)
+			market.GET("/movers", h.stocks.GetMarketMovers)
+			market.GET("/volume-spikes", h.stocks.GetVolumeSpikes)
+			market.GET("/dividend-leaders", h.stocks.GetDividendLeaders)
+			market.GET("/sectors", h.stocks.GetSectors)
+			market.GET("/filters", h.stocks.GetMarketFilters)
+			market.GET("/data-source", h.stocks.GetDataSourceInfo)
+		}
+
+		// System monitoring endpoints
+		system := v1.Group("/system", h.requireAPIKey)
+		{
+			system.GET("/health", h.system.GetSystemHealth)
+			system.GET("/version", h.system.GetVersion)
+			system.GET("/summary", h.summary.GetSummary)
+			system.GET("/api-status", h.system.GetAPIStatus)
+			system.GET("/sync-status", h.system.GetDataSyncStatus)
+			system.GET("/api-history", h.system.GetAPICallHistory)
+			system.GET("/api-calls", h.system.GetAPICalls)
+			system.GET("/scheduler/runs", h.system.GetSchedulerRuns)
+			system.GET("/sync-queue", h.system.GetSyncQueue)
+			system.GET("/data-gaps", h.system.GetDataGaps)
+			system.GET("/cache", h.system.GetCacheStats)
+			system.POST("/cache/reset", h.system.ResetCacheStats)
+			system.POST("/scheduler/pause", h.system.PauseScheduler)
+			system.POST("/scheduler/resume", h.system.ResumeScheduler)
+			system.POST("/sync/:symbol", h.system.TriggerManualSync)
+			system.GET("/sync/:symbol/status", h.system.GetManualSyncStatus)
+			system.GET("/jobs/:id", h.system.GetJob)
+		}
+
+		// Historical data sync endpoints
+		sync := v1.Group("/sync")
+		{
+			sync.POST("/batch", h.requireAPIKey, h.sync.TriggerBatchSync)
+			sync.GET("/status", h.sync.GetSyncStatus)
+			sync.GET("/jobs/:id", h.sync.GetSyncJob)
+			sync.DELETE("/jobs/:id", h.requireAPIKey, h.sync.CancelSyncJob)
+			sync.GET("/history", h.sync.GetSyncHistory)
+			sync.GET("/pending", h.sync.GetPendingStocks)
+		}
+
+		// Watchlist endpoints
+		watchlists := v1.Group("/watchlists")
+		{
+			watchlists.POST("", h.requireAPIKey, h.watchlists.CreateWatchlist)
+			watchlists.GET("/:id", h.watchlists.GetWatchlist)
+			watchlists.POST("/:id/symbols", h.requireAPIKey, h.watchlists.AddSymbol)
+			watchlists.DELETE("/:id/symbols/:symbol", h.requireAPIKey, h.watchlists.RemoveSymbol)
+		}
+
+		// Portfolio endpoints
+		portfolios := v1.Group("/portfolios")
+		{
+			portfolios.POST("", h.requireAPIKey, h.portfolios.CreatePortfolio)
+			portfolios.POST("/:id/transactions", h.requireAPIKey, h.portfolios.RecordTransaction)
+			portfolios.GET("/:id/summary", h.portfolios.GetSummary)
+		}
+
+		// Stock administration endpoints
+		admin := v1.Group("/admin", h.requireAPIKey)
+		{
+			admin.GET("/symbol-search", h.admin.SearchSymbols)
+			admin.POST("/stocks", h.admin.AddStock)
+			admin.PATCH("/stocks/:symbol", h.admin.UpdateStock)
+			admin.POST("/stocks/:symbol/deactivate", h.admin.DeactivateStock)
+			admin.POST("/stocks/:symbol/activate", h.admin.ActivateStock)
+			admin.GET("/webhooks", h.webhooks.ListWebhooks)
+			admin.POST("/webhooks", h.webhooks.CreateWebhook)
+			admin.GET("/webhooks/:id", h.webhooks.GetWebhook)
+			admin.PATCH("/webhooks/:id", h.webhooks.UpdateWebhook)
+			admin.DELETE("/webhooks/:id", h.webhooks.DeleteWebhook)
+			admin.POST("/webhooks/:id/test", h.webhooks.TestWebhook)
+		}
+	}
+
+	return r, nil
+}
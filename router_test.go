@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"stock-intelligence-backend/internal/auth"
+	"stock-intelligence-backend/internal/config"
+	"stock-intelligence-backend/internal/handlers"
+	"stock-intelligence-backend/internal/logging"
+	"stock-intelligence-backend/internal/ratelimit"
+	"stock-intelligence-backend/internal/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_CreateWatchlistRequiresAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router, err := newRouter(routeHandlers{
+		watchlists:    handlers.NewWatchlistHandler(services.NewWatchlistService(db)),
+		requireAPIKey: auth.RequireAPIKey(auth.NewAPIKeyStore(db)),
+	}, &config.HTTPConfig{AllowedOrigins: config.DefaultAllowedOrigins}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	createWatchlist := func(key string) int {
+		req := httptest.NewRequest("POST", "/api/v1/watchlists", strings.NewReader(`{"name": "Tech"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(auth.APIKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Refused before the handler touches the database
+	assert.Equal(t, http.StatusUnauthorized, createWatchlist(""))
+
+	mock.ExpectQuery("UPDATE api_keys SET last_used_at").WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	assert.Equal(t, http.StatusUnauthorized, createWatchlist("si_unknown"))
+
+	now := time.Now()
+	mock.ExpectQuery("UPDATE api_keys SET last_used_at").WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "frontend"))
+	mock.ExpectQuery("INSERT INTO watchlists").WithArgs("Tech").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(1, now, now))
+	assert.Equal(t, http.StatusCreated, createWatchlist("si_valid"))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRouter_ErrorResponsesCarryRequestID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	var logs bytes.Buffer
+	logger, err := logging.New(&logs, "json", "info")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router, err := newRouter(routeHandlers{
+		watchlists:    handlers.NewWatchlistHandler(services.NewWatchlistService(db)),
+		requireAPIKey: auth.RequireAPIKey(auth.NewAPIKeyStore(db)),
+	}, &config.HTTPConfig{AllowedOrigins: config.DefaultAllowedOrigins}, logger)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT name, created_at, updated_at FROM watchlists").WithArgs(int64(404)).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "created_at", "updated_at"}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/watchlists/404", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	requestID := w.Header().Get(logging.RequestIDHeader)
+	require.NotEmpty(t, requestID)
+
+	var body struct {
+		Error handlers.ErrorResponse `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, handlers.CodeNotFound, body.Error.Code)
+	assert.Equal(t, requestID, body.Error.RequestID)
+
+	var record struct {
+		Status    int    `json:"status"`
+		RequestID string `json:"request_id"`
+	}
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &record))
+	assert.Equal(t, http.StatusNotFound, record.Status)
+	assert.Equal(t, requestID, record.RequestID)
+
+	// A caller's own ID is kept, including on responses the API key check refuses
+	req := httptest.NewRequest("POST", "/api/v1/watchlists", strings.NewReader(`{"name": "Tech"}`))
+	req.Header.Set(logging.RequestIDHeader, "client-trace-1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "client-trace-1", w.Header().Get(logging.RequestIDHeader))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, handlers.CodeUnauthorized, body.Error.Code)
+	assert.Equal(t, "client-trace-1", body.Error.RequestID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRouter_GzipsStockListButNotWebSocket(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	stockService := services.NewDatabaseStockService(db, nil)
+	router, err := newRouter(routeHandlers{
+		stocks:        handlers.NewDatabaseStockHandler(stockService, stockService),
+		ws:            handlers.NewWebSocketHandler(services.NewHybridStockService(stockService), nil, nil),
+		requireAPIKey: func(c *gin.Context) { c.Next() },
+	}, &config.HTTPConfig{AllowedOrigins: config.DefaultAllowedOrigins, GzipMinSize: 1024},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	now := time.Now()
+	listStocks := func(acceptEncoding string) *httptest.ResponseRecorder {
+		rows := sqlmock.NewRows([]string{
+			"id", "symbol", "company_name", "sector", "industry", "market_cap",
+			"price_range", "exchange", "is_active", "created_at", "updated_at",
+			"first_price_date", "last_price_date",
+			"current_price", "daily_change", "change_percent", "volume", "last_updated", "change_period_days",
+		})
+		for i := 0; i < 50; i++ {
+			rows.AddRow(i+1, fmt.Sprintf("S%03d", i), "Company Inc.", "Technology", "Software", int64(1000000000),
+				"$100+", "NASDAQ", true, now, now, nil, nil, 150.0, 1.5, 1.0, int64(1000000), now, 1)
+		}
+		mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(50))
+		mock.ExpectQuery("FROM stocks s").WithArgs(50, 0).WillReturnRows(rows)
+
+		req := httptest.NewRequest("GET", "/api/v1/stocks", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w
+	}
+
+	plain := listStocks("")
+	compressed := listStocks("gzip")
+	assert.Empty(t, plain.Header().Get("Content-Encoding"))
+	assert.Equal(t, "gzip", compressed.Header().Get("Content-Encoding"))
+	assert.Contains(t, compressed.Header().Values("Vary"), "Accept-Encoding")
+	assert.Less(t, compressed.Body.Len()*4, plain.Body.Len(), "the stock list compresses well")
+
+	reader, err := gzip.NewReader(compressed.Body)
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.JSONEq(t, plain.Body.String(), string(decompressed))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The upgrade reaches the WebSocket handler with a writer it can hijack
+	server := httptest.NewServer(router)
+	defer server.Close()
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws",
+		http.Header{"Accept-Encoding": []string{"gzip"}})
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+
+	var initial struct {
+		Type string `json:"type"`
+	}
+	require.NoError(t, conn.ReadJSON(&initial))
+	assert.Equal(t, "initial", initial.Type)
+}
+
+func TestRouter_OpenAPIDocumentCoversEveryRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router, err := newRouter(routeHandlers{
+		docs:          handlers.NewOpenAPIHandler(nil, auth.APIKeyHeader),
+		requireAPIKey: func(c *gin.Context) { c.Next() },
+	}, &config.HTTPConfig{AllowedOrigins: config.DefaultAllowedOrigins, GzipMinSize: 1024},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var doc handlers.OpenAPIDocument
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+
+	documented := map[string]bool{}
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		segments := strings.Split(route.Path, "/")
+		for i, segment := range segments {
+			if name, ok := strings.CutPrefix(segment, ":"); ok {
+				segments[i] = "{" + name + "}"
+			}
+		}
+		registered[route.Method+" "+strings.Join(segments, "/")] = true
+	}
+
+	for route := range registered {
+		assert.True(t, documented[route], "%s is not in the OpenAPI document", route)
+	}
+	for route := range documented {
+		assert.True(t, registered[route], "%s is documented but not registered", route)
+	}
+}
+
+func TestRouter_AdminRoutesRequireAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router, err := newRouter(routeHandlers{
+		admin:         handlers.NewAdminHandler(services.NewSandboxClient(db), services.NewStockListingService(db, nil)),
+		webhooks:      handlers.NewWebhookHandler(services.NewWebhookService(db), services.NewWebhookDispatcher(services.NewWebhookService(db))),
+		requireAPIKey: auth.RequireAPIKey(auth.NewAPIKeyStore(db)),
+	}, &config.HTTPConfig{AllowedOrigins: config.DefaultAllowedOrigins}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/api/v1/admin/symbol-search?q=tesco", nil),
+		httptest.NewRequest("POST", "/api/v1/admin/stocks", strings.NewReader(`{"symbol": "TSCO.LON", "company_name": "Tesco PLC", "exchange": "LSE"}`)),
+		httptest.NewRequest("GET", "/api/v1/admin/webhooks", nil),
+		httptest.NewRequest("POST", "/api/v1/admin/webhooks/1/test", nil),
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, req.URL.Path)
+	}
+
+	// Refused before the handlers touch the database
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRouter_ReadOnlyPostsNeedNoAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	stockService := services.NewDatabaseStockService(db, nil)
+	router, err := newRouter(routeHandlers{
+		stocks:        handlers.NewDatabaseStockHandler(stockService, stockService),
+		graphql:       handlers.NewGraphQLHandler(stockService, stockService),
+		requireAPIKey: auth.RequireAPIKey(auth.NewAPIKeyStore(db)),
+	}, &config.HTTPConfig{AllowedOrigins: config.DefaultAllowedOrigins}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without a key both reach their handlers rather than a 401
+	w := post("/api/v1/graphql", `{"query": "{ __typename }"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"__typename"`)
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/stocks/quotes", `{"symbols": []}`).Code)
+
+	// No key was looked up
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRouter_CORSPreflightAllowsAPIKeyHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router, err := newRouter(routeHandlers{
+		requireAPIKey: func(c *gin.Context) { c.Next() },
+	}, &config.HTTPConfig{AllowedOrigins: config.DefaultAllowedOrigins}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("OPTIONS", "/api/v1/sync/batch", nil)
+	req.Header.Set("Origin", config.DefaultAllowedOrigins[0])
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "content-type,"+strings.ToLower(auth.APIKeyHeader))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	allowed := strings.Split(strings.ToLower(w.Header().Get("Access-Control-Allow-Headers")), ",")
+	assert.Contains(t, allowed, strings.ToLower(auth.APIKeyHeader))
+}
+
+func TestRouter_RateLimitsAPIButNotHealth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stockService := services.NewDatabaseStockService(nil, nil)
+	router, err := newRouter(routeHandlers{
+		ws:            handlers.NewWebSocketHandler(services.NewHybridStockService(stockService), nil, nil),
+		docs:          handlers.NewOpenAPIHandler(nil, auth.APIKeyHeader),
+		requireAPIKey: func(c *gin.Context) { c.Next() },
+		rateLimit:     ratelimit.NewLimiter(ratelimit.NewMemoryStore(), 2, nil).Middleware(),
+	}, &config.HTTPConfig{AllowedOrigins: config.DefaultAllowedOrigins}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, get("/api/v1/openapi.json").Code)
+	assert.Equal(t, http.StatusOK, get("/api/v1/openapi.json").Code)
+	limited := get("/api/v1/openapi.json")
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.NotEmpty(t, limited.Header().Get("Retry-After"))
+
+	for i := 0; i < 3; i++ {
+		w := get("/health")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(ratelimit.RemainingHeader))
+	}
+}